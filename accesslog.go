@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// accessLogFile is the default access log, next to the config file. It is
+// rotated like the application log.
+const accessLogFile = "access.log"
+
+// accessLogPath returns the access log file for cfg, or "" when access
+// logging is off.
+func accessLogPath(cfg *AppConfig) string {
+	if !cfg.EnableAccessLog {
+		return ""
+	}
+	if cfg.AccessLogPath == "" {
+		return filepath.Join(filepath.Dir(getConfigPath()), accessLogFile)
+	}
+	return cfg.AccessLogPath
+}
+
+// accessLog writes one line per HTTP request to a rotating file, opened on
+// first use and reopened when the configured path changes. A nil log
+// writes nothing.
+type accessLog struct {
+	mu   sync.Mutex
+	path string
+	file *rotatingFile
+	// failed is set when path couldn't be opened, so the error is
+	// reported once rather than for every request.
+	failed bool
+}
+
+// write appends line to the file at path, closing the file when path is
+// empty.
+func (l *accessLog) write(path, line string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if path != l.path {
+		l.closeLocked()
+		l.path, l.failed = path, false
+	}
+	if path == "" || l.failed {
+		return
+	}
+	if l.file == nil {
+		file, err := openRotatingFile(path, appLogMaxSize, appLogBackups)
+		if err != nil {
+			slog.Error("Failed to open the access log", "path", path, "err", err)
+			l.failed = true
+			return
+		}
+		l.file = file
+	}
+	l.file.Write([]byte(line))
+}
+
+func (l *accessLog) closeLocked() {
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// close closes the file.
+func (l *accessLog) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closeLocked()
+	l.path = ""
+}
+
+// RouteMetrics counts the requests one server answered for one route.
+type RouteMetrics struct {
+	Server    string           `json:"server"`
+	Route     string           `json:"route"`
+	Requests  int64            `json:"requests"`
+	Statuses  map[string]int64 `json:"statuses"`
+	Bytes     int64            `json:"bytes"`
+	AvgMillis float64          `json:"avgMillis"`
+	MaxMillis float64          `json:"maxMillis"`
+
+	total time.Duration
+}
+
+// requestMetrics aggregates requests per server and route since start. A
+// nil value records nothing.
+type requestMetrics struct {
+	mu     sync.Mutex
+	routes map[string]*RouteMetrics
+}
+
+func newRequestMetrics() *requestMetrics {
+	return &requestMetrics{routes: make(map[string]*RouteMetrics)}
+}
+
+// record adds a request. route is the mux pattern that matched, so the
+// number of entries stays bounded.
+func (m *requestMetrics) record(server, route string, status int, bytes int64, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := server + " " + route
+	rm := m.routes[key]
+	if rm == nil {
+		rm = &RouteMetrics{Server: server, Route: route, Statuses: make(map[string]int64)}
+		m.routes[key] = rm
+	}
+	rm.Requests++
+	rm.Statuses[fmt.Sprintf("%dxx", status/100)]++
+	rm.Bytes += bytes
+	rm.total += elapsed
+	if ms := millis(elapsed); ms > rm.MaxMillis {
+		rm.MaxMillis = ms
+	}
+	rm.AvgMillis = millis(rm.total / time.Duration(rm.Requests))
+}
+
+// snapshot returns a copy of the metrics, sorted by server and route.
+func (m *requestMetrics) snapshot() []RouteMetrics {
+	result := make([]RouteMetrics, 0)
+	if m == nil {
+		return result
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rm := range m.routes {
+		c := *rm
+		c.Statuses = make(map[string]int64, len(rm.Statuses))
+		for k, v := range rm.Statuses {
+			c.Statuses[k] = v
+		}
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Server != result[j].Server {
+			return result[i].Server < result[j].Server
+		}
+		return result[i].Route < result[j].Route
+	})
+	return result
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// statusRecorder captures the status code and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps Server-Sent Events streaming through the recorder.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// withAccessLog records every request to next in the request metrics and,
+// when enabled, the access log: time, remote address, server, method, path
+// (without the query, which may carry tokens or message text), status,
+// bytes and latency.
+func (a *App) withAccessLog(server string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		elapsed := time.Since(start)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		a.requestMetrics.record(server, route, rec.status, rec.bytes, elapsed)
+
+		a.configMu.RLock()
+		path := accessLogPath(a.config)
+		a.configMu.RUnlock()
+		a.accessLog.write(path, fmt.Sprintf("%s %s %s %s %s %d %dB %.1fms %q\n",
+			start.Format(time.RFC3339), r.RemoteAddr, server, r.Method, r.URL.Path,
+			rec.status, rec.bytes, millis(elapsed), r.UserAgent()))
+	})
+}
+
+// handleAPIMetrics returns the request counts, status classes, bytes and
+// latencies per server and route since start.
+func (a *App) handleAPIMetrics(w http.ResponseWriter, r *http.Request) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	writeJSON(w, http.StatusOK, apiMetrics{Since: a.started, Routes: a.requestMetrics.snapshot(), Deliveries: a.deliveries.stats(),
+		Discord: discordStats.snapshot(&cfg)})
+}
+
+type apiMetrics struct {
+	Since      time.Time      `json:"since"`
+	Routes     []RouteMetrics `json:"routes"`
+	Deliveries deliveryStats  `json:"deliveries"`
+	Discord    []WebhookStats `json:"discord"` // per webhook or bot channel
+}
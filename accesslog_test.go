@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithAccessLog(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.accessLog = &accessLog{}
+	defer a.accessLog.close()
+	a.requestMetrics = newRequestMetrics()
+	path := filepath.Join(t.TempDir(), "access.log")
+	a.config.EnableAccessLog = true
+	a.config.AccessLogPath = path
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /message", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+	handler := a.withAccessLog("ingestion", mux)
+
+	for _, target := range []string{"/message?sender=Alice&message=secret", "/message", "/nope"} {
+		req := httptest.NewRequest("POST", target, nil)
+		req.RemoteAddr = "203.0.113.7:5000"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %q", data)
+	}
+	for _, want := range []string{"203.0.113.7:5000", "ingestion", "POST /message 200 15B"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("expected %q in %q", want, lines[0])
+		}
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Error("the query string must not be logged")
+	}
+	if !strings.Contains(lines[2], "/nope 404") {
+		t.Errorf("expected the 404 to be logged, got %q", lines[2])
+	}
+
+	metrics := a.requestMetrics.snapshot()
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 routes, got %+v", metrics)
+	}
+	if m := metrics[0]; m.Route != "POST /message" || m.Requests != 2 || m.Statuses["2xx"] != 2 || m.Bytes != 30 {
+		t.Errorf("unexpected metrics %+v", m)
+	}
+	if m := metrics[1]; m.Route != "unmatched" || m.Statuses["4xx"] != 1 {
+		t.Errorf("unexpected metrics %+v", m)
+	}
+
+	// Turning the access log off stops writing.
+	a.config.EnableAccessLog = false
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/message", nil))
+	if after, _ := os.ReadFile(path); len(after) != len(data) {
+		t.Error("expected no more lines once disabled")
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// annotationsFile, at the top of each log folder, holds the GM's
+// annotations on the messages logged there, so they travel with the logs
+// into backups, uploads and retention archives. It is JSON, but not named
+// like a log file, so the tools that read, rewrite or prune logs pass it
+// by. The logs themselves are never changed.
+const annotationsFile = "annotations.notes"
+
+// Annotation kinds: a note shown with the message, or a correction that
+// replaces its text in exports.
+const (
+	annotationNote       = "note"
+	annotationCorrection = "correction"
+)
+
+// maxAnnotationText bounds an annotation's text.
+const maxAnnotationText = 2000
+
+// recentAnnotatable is how many recent messages the annotation form offers.
+const recentAnnotatable = 30
+
+// errNotAnnotatable is returned for message IDs without a logged entry.
+var errNotAnnotatable = errors.New("No recent logged message with that ID")
+
+// Annotation is a GM's note on, or correction of, a logged message. The
+// message is found again in the logs by its timestamp, sender and text.
+type Annotation struct {
+	ID        string    `json:"id"`
+	MessageID string    `json:"messageId,omitempty"` // its delivery receipt
+	Timestamp string    `json:"timestamp"`
+	Sender    string    `json:"sender"`
+	Message   string    `json:"message"` // as logged
+	Kind      string    `json:"kind"`
+	Text      string    `json:"text"`
+	Created   time.Time `json:"created"`
+	// DiscordEdited tells whether the bot's Discord post was corrected too,
+	// and DiscordError why it couldn't be when that was asked for.
+	DiscordEdited bool   `json:"discordEdited,omitempty"`
+	DiscordError  string `json:"discordError,omitempty"`
+}
+
+// apiAnnotationRequest is the body of POST /api/v1/annotations.
+type apiAnnotationRequest struct {
+	MessageID string `json:"messageId"`
+	Kind      string `json:"kind"` // note (default) or correction
+	Text      string `json:"text"`
+	// EditDiscord also corrects the message on Discord, when the bot
+	// posted it in one piece.
+	EditDiscord bool `json:"editDiscord,omitempty"`
+}
+
+type apiAnnotationList struct {
+	Annotations []Annotation `json:"annotations"`
+}
+
+// loadAnnotations reads the annotations file at path, oldest first. A
+// missing file yields none.
+func loadAnnotations(path string) ([]Annotation, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading annotations: %w", err)
+	}
+	var annotations []Annotation
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return nil, fmt.Errorf("parsing annotations: %w", err)
+	}
+	return annotations, nil
+}
+
+// saveAnnotations replaces the annotations file at path.
+func saveAnnotations(path string, annotations []Annotation) error {
+	data, err := json.MarshalIndent(annotations, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding annotations: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating log directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing annotations: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replacing annotations: %w", err)
+	}
+	return nil
+}
+
+// annotationsPath returns the annotations file of the log folder dir.
+func annotationsPath(dir string) string {
+	return filepath.Join(dir, annotationsFile)
+}
+
+// annotationConfig returns the config. Only logged messages can be
+// annotated, so file logging must be on.
+func (a *App) annotationConfig() (*AppConfig, error) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	if !cfg.EnableLocalSave || cfg.Path == "" {
+		return nil, fmt.Errorf("Annotations are made on logged messages. Enable file logging first.")
+	}
+	return &cfg, nil
+}
+
+// loadAllAnnotations reads the annotations of every log folder cfg writes
+// to (see archiveFolders), oldest first.
+func loadAllAnnotations(cfg *AppConfig) ([]Annotation, error) {
+	var all []Annotation
+	for _, dir := range archiveFolders(cfg) {
+		annotations, err := loadAnnotations(annotationsPath(dir))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, annotations...)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Created.Before(all[j].Created) })
+	return all, nil
+}
+
+// annotate adds a note or correction to the logged message messageID. With
+// editDiscord, a correction also edits the message on Discord; when that
+// fails the annotation is kept, with the reason in its DiscordError.
+func (a *App) annotate(messageID, kind, text string, editDiscord bool) (Annotation, error) {
+	cfg, err := a.annotationConfig()
+	if err != nil {
+		return Annotation{}, err
+	}
+	if kind == "" {
+		kind = annotationNote
+	}
+	if kind != annotationNote && kind != annotationCorrection {
+		return Annotation{}, fmt.Errorf("Unknown annotation kind %q", kind)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > maxAnnotationText {
+		return Annotation{}, fmt.Errorf("Annotations must be 1 to %d characters", maxAnnotationText)
+	}
+	receipt, ok := a.receipts.get(messageID)
+	if !ok || receipt.Entry == nil {
+		return Annotation{}, errNotAnnotatable
+	}
+	entry := *receipt.Entry
+	ann := Annotation{
+		ID:        newMessageID(),
+		MessageID: messageID,
+		Timestamp: entry.Timestamp,
+		Sender:    entry.Sender,
+		Message:   entry.Message,
+		Kind:      kind,
+		Text:      text,
+		Created:   time.Now(),
+	}
+	if editDiscord && kind == annotationCorrection {
+		if err := a.editAnnotatedMessage(cfg, receipt, text); err != nil {
+			ann.DiscordError = err.Error()
+			a.logger.Log("warning", traced(receipt.Trace, fmt.Sprintf("Correction not made on Discord: %v", err)))
+		} else {
+			ann.DiscordEdited = true
+		}
+	}
+
+	// Kept in the folder the message was logged to.
+	path := annotationsPath(sourceLogConfig(cfg, receipt.Source).Path)
+	a.annotationsMu.Lock()
+	defer a.annotationsMu.Unlock()
+	annotations, err := loadAnnotations(path)
+	if err != nil {
+		return Annotation{}, err
+	}
+	if err := saveAnnotations(path, append(annotations, ann)); err != nil {
+		return Annotation{}, err
+	}
+	a.logger.Log("info", traced(receipt.Trace, fmt.Sprintf("%s added to %s's message of %s", strings.ToUpper(kind[:1])+kind[1:], entry.Sender, entry.Timestamp)))
+	return ann, nil
+}
+
+// editAnnotatedMessage replaces the text of the bot's Discord post of a
+// message with text, rendered as the message was.
+func (a *App) editAnnotatedMessage(cfg *AppConfig, receipt Receipt, text string) error {
+	if cfg.DiscordMode != discordModeBot || len(receipt.DiscordMessages) == 0 {
+		return fmt.Errorf("Only messages posted by the Discord bot can be edited")
+	}
+	if len(receipt.DiscordMessages) > 1 {
+		return fmt.Errorf("The message was posted in %d parts and can't be edited", len(receipt.DiscordMessages))
+	}
+	entry := *receipt.Entry
+	body := discordText(cfg, text)
+	if entry.Kind == kindEmote {
+		body = "*" + body + "*"
+	}
+	base, suffix := discordPostParts(cfg.DiscordTemplate, false, displayName(cfg, entry.Sender), receipt.Received, time.Now())
+	content := base + body + suffix
+	if len(content) > discordMessageLimit {
+		return fmt.Errorf("The correction is too long for one Discord message")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	return editDiscordMessage(ctx, cfg.BotToken, receipt.DiscordMessages[0], content)
+}
+
+// deleteAnnotation removes annotation id, from whichever log folder holds
+// it. Discord edits stay as they are.
+func (a *App) deleteAnnotation(id string) error {
+	cfg, err := a.annotationConfig()
+	if err != nil {
+		return err
+	}
+	a.annotationsMu.Lock()
+	defer a.annotationsMu.Unlock()
+	for _, dir := range archiveFolders(cfg) {
+		path := annotationsPath(dir)
+		annotations, err := loadAnnotations(path)
+		if err != nil {
+			return err
+		}
+		for i, ann := range annotations {
+			if ann.ID == id {
+				return saveAnnotations(path, append(annotations[:i], annotations[i+1:]...))
+			}
+		}
+	}
+	return fmt.Errorf("No annotation with that ID")
+}
+
+// renameAnnotations changes the sender of the annotations in the log
+// folder dir on messages sent as from (ignoring case) to to, so they stay
+// on the renamed messages.
+func (a *App) renameAnnotations(dir, from, to string) error {
+	path := annotationsPath(dir)
+	a.annotationsMu.Lock()
+	defer a.annotationsMu.Unlock()
+	annotations, err := loadAnnotations(path)
+	if err != nil {
+		return err
+	}
+	renamed := false
+	for i := range annotations {
+		if strings.EqualFold(annotations[i].Sender, from) {
+			annotations[i].Sender = to
+			renamed = true
+		}
+	}
+	if !renamed {
+		return nil
+	}
+	return saveAnnotations(path, annotations)
+}
+
+// annotates reports whether ann is on entry. Plain-text logs can't tell
+// where an emote's sender ends, so the sender and text are compared
+// together.
+func annotates(ann Annotation, entry LogEntry) bool {
+	if ann.Timestamp != entry.Timestamp {
+		return false
+	}
+	return ann.Sender == entry.Sender && ann.Message == entry.Message ||
+		ann.Sender+" "+ann.Message == entry.Sender+" "+entry.Message
+}
+
+// applyAnnotations replaces the text of the corrected entries with their
+// latest correction and returns the notes on the entries by noteKey.
+func applyAnnotations(entries []LogEntry, annotations []Annotation) map[string][]string {
+	byTime := make(map[string][]Annotation)
+	for _, ann := range annotations {
+		byTime[ann.Timestamp] = append(byTime[ann.Timestamp], ann)
+	}
+	notes := make(map[string][]string)
+	for i := range entries {
+		logged := entries[i]
+		var entryNotes []string
+		for _, ann := range byTime[logged.Timestamp] {
+			if !annotates(ann, logged) {
+				continue
+			}
+			if ann.Kind == annotationCorrection {
+				entries[i].Message = ann.Text
+			} else {
+				entryNotes = append(entryNotes, ann.Text)
+			}
+		}
+		if len(entryNotes) > 0 {
+			key := noteKey(entries[i])
+			notes[key] = append(notes[key], entryNotes...)
+		}
+	}
+	return notes
+}
+
+// noteKey identifies an entry in the notes returned by applyAnnotations,
+// whatever name its sender is shown under.
+func noteKey(entry LogEntry) string {
+	return entry.Timestamp + "\x00" + entry.Message
+}
+
+// annotationRow is a recent logged message the annotation form offers.
+type annotationRow struct {
+	ID    string
+	Label string
+}
+
+// annotationListData is the annotation panel's data: the recent logged
+// messages and the annotations, newest first.
+func (a *App) annotationListData(message, errMsg string) map[string]interface{} {
+	var recent []annotationRow
+	for _, r := range a.receipts.list("", maxReceipts) {
+		if r.Entry == nil {
+			continue
+		}
+		_, clock, _ := strings.Cut(r.Entry.Timestamp, " ")
+		recent = append(recent, annotationRow{ID: r.ID, Label: fmt.Sprintf("%s %s: %s", clock, r.Entry.Sender, truncateForDisplay(r.Entry.Message, 60))})
+		if len(recent) == recentAnnotatable {
+			break
+		}
+	}
+	var annotations []Annotation
+	if cfg, err := a.annotationConfig(); err == nil {
+		list, err := loadAllAnnotations(cfg)
+		if err != nil && errMsg == "" {
+			errMsg = err.Error()
+		}
+		for i := len(list) - 1; i >= 0; i-- {
+			annotations = append(annotations, list[i])
+		}
+	}
+	return map[string]interface{}{
+		"Recent":      recent,
+		"Annotations": annotations,
+		"Message":     message,
+		"Error":       errMsg,
+	}
+}
+
+// handleAnnotations returns the annotation panel as an HTML partial.
+func (a *App) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	a.renderAnnotationList(w, "", "")
+}
+
+// handleAnnotate adds an annotation from the panel's form.
+func (a *App) handleAnnotate(w http.ResponseWriter, r *http.Request) {
+	ann, err := a.annotate(r.FormValue("messageId"), r.FormValue("kind"), r.FormValue("text"), r.FormValue("editDiscord") == "on")
+	switch {
+	case err != nil:
+		a.renderAnnotationList(w, "", err.Error())
+	case ann.DiscordError != "":
+		a.renderAnnotationList(w, "", "Correction saved, but not made on Discord: "+ann.DiscordError)
+	case ann.DiscordEdited:
+		a.renderAnnotationList(w, "Correction saved and made on Discord", "")
+	default:
+		a.renderAnnotationList(w, "Annotation saved", "")
+	}
+}
+
+// handleDeleteAnnotation removes an annotation from the panel.
+func (a *App) handleDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	if err := a.deleteAnnotation(r.PathValue("id")); err != nil {
+		a.renderAnnotationList(w, "", err.Error())
+		return
+	}
+	a.renderAnnotationList(w, "Annotation deleted", "")
+}
+
+func (a *App) renderAnnotationList(w http.ResponseWriter, message, errMsg string) {
+	tmpl, err := a.parseTemplates("templates/partials/annotation_list.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "annotation-list", a.annotationListData(message, errMsg)); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// handleAPIAnnotations lists the annotations, oldest first; ?message=
+// keeps those on one message.
+func (a *App) handleAPIAnnotations(w http.ResponseWriter, r *http.Request) {
+	cfg, err := a.annotationConfig()
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	annotations, err := loadAllAnnotations(cfg)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	list := apiAnnotationList{Annotations: []Annotation{}}
+	message := r.URL.Query().Get("message")
+	for _, ann := range annotations {
+		if message == "" || ann.MessageID == message {
+			list.Annotations = append(list.Annotations, ann)
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleAPIAnnotate adds an annotation to a recent logged message.
+func (a *App) handleAPIAnnotate(w http.ResponseWriter, r *http.Request) {
+	var body apiAnnotationRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	if body.MessageID == "" {
+		writeJSONError(w, http.StatusBadRequest, "messageId is required")
+		return
+	}
+	ann, err := a.annotate(body.MessageID, body.Kind, body.Text, body.EditDiscord)
+	switch {
+	case errors.Is(err, errNotAnnotatable):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		writeJSON(w, http.StatusCreated, ann)
+	}
+}
+
+// handleAPIDeleteAnnotation removes an annotation.
+func (a *App) handleAPIDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	if err := a.deleteAnnotation(r.PathValue("id")); err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
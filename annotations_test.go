@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyAnnotations(t *testing.T) {
+	entries := []LogEntry{
+		{Timestamp: "2024-03-09 20:00:00", Sender: "Conan", Message: "I draw my swrod"},
+		{Timestamp: "2024-03-09 20:00:05", Sender: "Valeria", Message: "looks around", Kind: kindEmote},
+		{Timestamp: "2024-03-09 20:00:09", Sender: "Conan", Message: "Crom!"},
+	}
+	annotations := []Annotation{
+		{Timestamp: "2024-03-09 20:00:00", Sender: "Conan", Message: "I draw my swrod", Kind: annotationCorrection, Text: "I draw my sword"},
+		{Timestamp: "2024-03-09 20:00:00", Sender: "Conan", Message: "I draw my swrod", Kind: annotationNote, Text: "The sword from the tomb"},
+		// A plain-text emote line is read back with the action's first word
+		// in the sender.
+		{Timestamp: "2024-03-09 20:00:05", Sender: "Valeria looks", Message: "around", Kind: annotationNote, Text: "She is lying"},
+		{Timestamp: "2024-03-09 20:00:09", Sender: "Conan", Message: "Crom?", Kind: annotationNote, Text: "Not this one"},
+	}
+
+	notes := applyAnnotations(entries, annotations)
+
+	if entries[0].Message != "I draw my sword" {
+		t.Errorf("corrected message = %q", entries[0].Message)
+	}
+	tests := []struct {
+		entry LogEntry
+		want  string
+	}{
+		{entries[0], "The sword from the tomb"},
+		{entries[1], "She is lying"},
+		{entries[2], ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(notes[noteKey(tt.entry)], "|"); got != tt.want {
+			t.Errorf("notes on %q = %q, want %q", tt.entry.Message, got, tt.want)
+		}
+	}
+}
+
+func TestAnnotate_EditsBotPost(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	bot := newFakeDiscordBot(t)
+	a := setupTestApp()
+	a.receipts = newReceiptTable(maxReceipts)
+	a.discordQueue = NewDiscordQueue(a.logger, a.receipts)
+	defer func() { a.discordQueue.Stop(); a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	tmpDir := t.TempDir()
+	a.config.EnableDiscord = true
+	a.config.DiscordMode = discordModeBot
+	a.config.BotToken = "secret"
+	a.config.BotChannelID = "100"
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+
+	id := a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "I draw my swrod"})
+	waitForDeliveries(t, a)
+
+	ann, err := a.annotate(id, annotationCorrection, "I draw my sword", true)
+	if err != nil {
+		t.Fatalf("annotate: %v", err)
+	}
+	if !ann.DiscordEdited || ann.DiscordError != "" {
+		t.Errorf("Discord not edited: %+v", ann)
+	}
+	if edited := bot.edits["m1"]; !strings.Contains(edited, "I draw my sword") || !strings.Contains(edited, "Conan") {
+		t.Errorf("Discord post edited to %q", edited)
+	}
+	if _, err := a.annotate(id, annotationNote, "From the tomb", false); err != nil {
+		t.Fatalf("annotate note: %v", err)
+	}
+
+	saved, err := loadAnnotations(annotationsPath(tmpDir))
+	if err != nil || len(saved) != 2 {
+		t.Fatalf("saved annotations %+v, %v", saved, err)
+	}
+	if saved[0].Message != "I draw my swrod" || saved[0].Sender != "Conan" {
+		t.Errorf("annotation not linked to the logged message: %+v", saved[0])
+	}
+
+	if _, err := a.annotate("nope", annotationNote, "text", false); err != errNotAnnotatable {
+		t.Errorf("unknown message: got %v", err)
+	}
+	if _, err := a.annotate(id, "rewrite", "text", false); err == nil {
+		t.Error("unknown kind accepted")
+	}
+
+	if err := a.deleteAnnotation(saved[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := loadAnnotations(annotationsPath(tmpDir)); len(left) != 1 || left[0].ID != saved[0].ID {
+		t.Errorf("after deleting, annotations are %+v", left)
+	}
+}
+
+func TestAnnotate_WebhookPostNotEdited(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.receipts = newReceiptTable(10)
+	a.config.EnableLocalSave = true
+	a.config.Path = t.TempDir()
+	id := a.receipts.add("Conan", "", "")
+	a.receipts.setEntry(id, LogEntry{Timestamp: "2024-03-09 20:00:00", Sender: "Conan", Message: "Crom"})
+
+	ann, err := a.annotate(id, annotationCorrection, "Crom!", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ann.DiscordEdited || ann.DiscordError == "" {
+		t.Errorf("expected the annotation kept without a Discord edit, got %+v", ann)
+	}
+
+	rec := httptest.NewRecorder()
+	a.handleAnnotations(rec, httptest.NewRequest("GET", "/api/annotations", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `value="`+id+`"`) || !strings.Contains(body, "<s>Crom</s> Crom!") {
+		t.Errorf("annotation panel missing the message or its correction:\n%s", body)
+	}
+}
+
+func TestAnnotate_KeptInTheMessagesLogFolder(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.receipts = newReceiptTable(10)
+	main, tavern := t.TempDir(), t.TempDir()
+	a.config.EnableLocalSave = true
+	a.config.Path = main
+	a.config.Sources = map[string]SourceProfile{"tavern": {Path: tavern}}
+	first := a.receipts.add("Conan", "", "")
+	a.receipts.setEntry(first, LogEntry{Timestamp: "2024-03-09 20:00:00", Sender: "Conan", Message: "Crom"})
+	second := a.receipts.add("Valeria", "tavern", "")
+	a.receipts.setEntry(second, LogEntry{Timestamp: "2024-03-09 20:01:00", Sender: "Valeria", Message: "Ale!"})
+
+	if _, err := a.annotate(first, annotationNote, "At the gate", false); err != nil {
+		t.Fatal(err)
+	}
+	tavernNote, err := a.annotate(second, annotationNote, "In the tavern", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for dir, want := range map[string]string{main: "At the gate", tavern: "In the tavern"} {
+		if saved, err := loadAnnotations(annotationsPath(dir)); err != nil || len(saved) != 1 || saved[0].Text != want {
+			t.Errorf("annotations in %s = %+v, %v; want %q", dir, saved, err, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	a.handleAPIAnnotations(rec, httptest.NewRequest("GET", "/api/v1/annotations", nil))
+	var list apiAnnotationList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list.Annotations) != 2 || list.Annotations[0].Text != "At the gate" {
+		t.Errorf("listed annotations %+v, %v", list.Annotations, err)
+	}
+	if err := a.deleteAnnotation(tavernNote.ID); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := loadAnnotations(annotationsPath(tavern)); len(left) != 0 {
+		t.Errorf("annotation not deleted from the source's folder: %+v", left)
+	}
+}
+
+func TestHandleExportEPUB_Annotations(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.receipts = newReceiptTable(10)
+	tmpDir := t.TempDir()
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+	a.config.FileFormat = "jsonl"
+
+	id := a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "Crom!"})
+	waitForDeliveries(t, a)
+	if _, err := a.annotate(id, annotationNote, "Said at the gate <of Tarantia>", false); err != nil {
+		t.Fatal(err)
+	}
+
+	chapters := campaignChapters([]LogEntry{*mustReceipt(t, a, id).Entry})
+	annotations, _ := loadAnnotations(annotationsPath(tmpDir))
+	page := epubChapterPage(chapters[0], nil, applyAnnotations(chapters[0].Entries, annotations))
+	if !strings.Contains(page, `<p class="note">GM note: Said at the gate &lt;of Tarantia&gt;</p>`) {
+		t.Errorf("note missing from the chapter:\n%s", page)
+	}
+
+	rec := httptest.NewRecorder()
+	day := strings.SplitN(chapters[0].Entries[0].Timestamp, " ", 2)[0]
+	a.handleExportEPUB(rec, httptest.NewRequest("GET", "/api/export/epub?from="+day+"&to="+day, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("export status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func mustReceipt(t *testing.T, a *App, id string) Receipt {
+	t.Helper()
+	r, ok := a.receipts.get(id)
+	if !ok || r.Entry == nil {
+		t.Fatalf("no logged receipt %s: %+v", id, r)
+	}
+	return r
+}
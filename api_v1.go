@@ -0,0 +1,501 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultAPILimit is how many log lines or messages the JSON API returns
+// when the request doesn't set ?limit=.
+const defaultAPILimit = 100
+
+// apiRoute describes a JSON API endpoint. The routes are both registered
+// and documented in the OpenAPI spec from apiV1Routes, so the two can't
+// drift apart.
+type apiRoute struct {
+	Method  string
+	Path    string
+	Summary string
+	Handler func(*App, http.ResponseWriter, *http.Request)
+	Params  []apiParam
+	// Body is a value of the request body's type, or nil.
+	Body interface{}
+	// Status is the success status code; Response is a value of its body's
+	// type, or nil for an empty response.
+	Status   int
+	Response interface{}
+	// Errors are the error status codes the endpoint answers with.
+	Errors []int
+}
+
+// apiParam is a path or query parameter of an API endpoint.
+type apiParam struct {
+	Name        string
+	In          string // "path" or "query"
+	Type        string // "string" or "integer"
+	Description string
+}
+
+var apiV1Routes = []apiRoute{
+	{Method: "GET", Path: "/api/v1/config", Summary: "Current configuration",
+		Handler: (*App).handleAPIGetConfig, Status: http.StatusOK, Response: AppConfig{}},
+	{Method: "PUT", Path: "/api/v1/config", Summary: "Update settings; fields left out keep their values",
+		Handler: (*App).handleAPIUpdateConfig, Body: AppConfig{}, Status: http.StatusOK, Response: AppConfig{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusInternalServerError}},
+	{Method: "GET", Path: "/api/v1/status", Summary: "Ingestion server, queue, tunnel, session and delivery status",
+		Handler: (*App).handleAPIStatus, Status: http.StatusOK, Response: apiStatus{}},
+	{Method: "POST", Path: "/api/v1/server/start", Summary: "Start the ingestion server",
+		Handler: (*App).handleAPIStartServer, Status: http.StatusOK, Response: apiStatus{},
+		Errors: []int{http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError}},
+	{Method: "POST", Path: "/api/v1/server/stop", Summary: "Stop the ingestion server",
+		Handler: (*App).handleAPIStopServer, Status: http.StatusOK, Response: apiStatus{},
+		Errors: []int{http.StatusConflict, http.StatusInternalServerError}},
+	{Method: "POST", Path: "/api/v1/discord/pause", Summary: "Pause posting to Discord; messages are still logged and their posts held in the retry queue",
+		Handler: (*App).handleAPIPauseDiscord, Status: http.StatusOK, Response: apiStatus{}},
+	{Method: "POST", Path: "/api/v1/discord/resume", Summary: "Resume posting to Discord, sending the held messages",
+		Handler: (*App).handleAPIResumeDiscord, Status: http.StatusOK, Response: apiStatus{}},
+	{Method: "GET", Path: "/api/v1/logs", Summary: "Recent application log lines, oldest first",
+		Handler: (*App).handleAPILogs, Status: http.StatusOK, Response: apiLogList{},
+		Params: []apiParam{
+			{Name: "level", In: "query", Type: "string", Description: "Minimum level (trace, debug, info, warn, error)"},
+			{Name: "contains", In: "query", Type: "string", Description: "Only lines containing this text, ignoring case"},
+			{Name: "after", In: "query", Type: "integer", Description: "Only lines after this log line ID"},
+			{Name: "limit", In: "query", Type: "integer", Description: "Maximum number of lines (default 100)"},
+		},
+		Errors: []int{http.StatusBadRequest}},
+	{Method: "GET", Path: "/api/v1/failures", Summary: "Failed deliveries, newest first",
+		Handler: (*App).handleAPIFailures, Status: http.StatusOK, Response: apiFailureList{}},
+	{Method: "DELETE", Path: "/api/v1/failures", Summary: "Dismiss all failures",
+		Handler: (*App).handleAPIClearFailures, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/api/v1/failures/{id}/retry", Summary: "Redeliver a failed message",
+		Handler: (*App).handleAPIRetryFailure, Status: http.StatusNoContent,
+		Params: []apiParam{{Name: "id", In: "path", Type: "integer", Description: "Failure ID"}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError}},
+	{Method: "DELETE", Path: "/api/v1/failures/{id}", Summary: "Dismiss a failure",
+		Handler: (*App).handleAPIDismissFailure, Status: http.StatusNoContent,
+		Params: []apiParam{{Name: "id", In: "path", Type: "integer", Description: "Failure ID"}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: "GET", Path: "/api/v1/queue", Summary: "Messages waiting in the Discord and forward retry queues",
+		Handler: (*App).handleAPIQueue, Status: http.StatusOK, Response: apiQueueList{}},
+	{Method: "DELETE", Path: "/api/v1/queue", Summary: "Delete every queued message",
+		Handler: (*App).handleAPIPurgeQueue, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/api/v1/queue/{id}/retry", Summary: "Send a queued message now",
+		Handler: (*App).handleAPIRetryQueued, Status: http.StatusNoContent,
+		Params: []apiParam{{Name: "id", In: "path", Type: "integer", Description: "Queue ID"}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: "DELETE", Path: "/api/v1/queue/{id}", Summary: "Delete a queued message without sending it",
+		Handler: (*App).handleAPIDeleteQueued, Status: http.StatusNoContent,
+		Params: []apiParam{{Name: "id", In: "path", Type: "integer", Description: "Queue ID"}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: "GET", Path: "/api/v1/messages", Summary: "Delivery summary and the most recent receipts, newest first",
+		Handler: (*App).handleAPIMessages, Status: http.StatusOK, Response: apiMessageList{},
+		Params: []apiParam{
+			{Name: "status", In: "query", Type: "string", Description: "Only messages with a sink in this state (pending, sent, failed)"},
+			{Name: "limit", In: "query", Type: "integer", Description: "Maximum number of receipts (default 100)"},
+		},
+		Errors: []int{http.StatusBadRequest}},
+	{Method: "GET", Path: "/api/v1/messages/{id}", Summary: "A message's delivery receipt",
+		Handler: (*App).handleAPIMessage, Status: http.StatusOK, Response: receiptStatus{},
+		Params: []apiParam{{Name: "id", In: "path", Type: "string", Description: "Message ID from the /message response"}},
+		Errors: []int{http.StatusNotFound}},
+	{Method: "GET", Path: "/api/v1/entities", Summary: "Characters and frequently mentioned names in the stored logs, when they appeared and with whom",
+		Handler: (*App).handleAPIEntities, Status: http.StatusOK, Response: apiEntityList{},
+		Params: []apiParam{
+			{Name: "days", In: "query", Type: "integer", Description: "Index the last this many days (default 30)"},
+			{Name: "q", In: "query", Type: "string", Description: "Only entities whose name contains this text, ignoring case"},
+			{Name: "kind", In: "query", Type: "string", Description: "Only characters (character) or mentioned names (name)"},
+			{Name: "limit", In: "query", Type: "integer", Description: "Maximum number of entities (default 100)"},
+		},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError}},
+	{Method: "POST", Path: "/api/v1/senders/rename", Summary: "Rename a sender across the stored logs, optionally keeping the old name as an alias",
+		Handler: (*App).handleAPIRenameSender, Body: apiSenderRename{}, Status: http.StatusOK, Response: RenameResult{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+	{Method: "POST", Path: "/api/v1/messages/delete", Summary: "Delete a sender's messages, or those in a time range, from the stored logs, receipts and retry queues, and the bot's Discord posts of them",
+		Handler: (*App).handleAPIDeleteMessages, Body: apiMessageDelete{}, Status: http.StatusOK, Response: DeleteResult{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+	{Method: "GET", Path: "/api/v1/annotations", Summary: "GM notes and corrections on logged messages, oldest first",
+		Handler: (*App).handleAPIAnnotations, Status: http.StatusOK, Response: apiAnnotationList{},
+		Params: []apiParam{{Name: "message", In: "query", Type: "string", Description: "Only annotations on this message ID"}},
+		Errors: []int{http.StatusConflict, http.StatusInternalServerError}},
+	{Method: "POST", Path: "/api/v1/annotations", Summary: "Add a GM note or correction to a recent logged message, optionally editing the bot's Discord post",
+		Handler: (*App).handleAPIAnnotate, Body: apiAnnotationRequest{}, Status: http.StatusCreated, Response: Annotation{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity}},
+	{Method: "DELETE", Path: "/api/v1/annotations/{id}", Summary: "Remove an annotation; Discord edits stay as they are",
+		Handler: (*App).handleAPIDeleteAnnotation, Status: http.StatusNoContent,
+		Params: []apiParam{{Name: "id", In: "path", Type: "string", Description: "Annotation ID"}},
+		Errors: []int{http.StatusNotFound}},
+	{Method: "GET", Path: "/api/v1/metrics", Summary: "Request counts, status classes, bytes and latencies per server and route, delivery queue stats, and Discord sends per webhook",
+		Handler: (*App).handleAPIMetrics, Status: http.StatusOK, Response: apiMetrics{}},
+}
+
+// scope is the API token scope the route needs when tokens are required:
+// read for GET requests, admin for changes.
+func (r apiRoute) scope() string {
+	if r.Method == http.MethodGet {
+		return scopeRead
+	}
+	return scopeAdmin
+}
+
+// registerAPIv1 adds the versioned JSON API, for scripts and dashboards,
+// to the web UI's mux. Unlike the HTMX endpoints under /api/ it answers in
+// JSON only, with an {"error": ...} body and a matching status code when a
+// request fails. With RequireAPIToken set every route but the OpenAPI
+// description needs an API token.
+func (a *App) registerAPIv1(mux *http.ServeMux) {
+	for _, route := range apiV1Routes {
+		handler := route.Handler
+		mux.Handle(route.Method+" "+route.Path, a.withToken(route.scope(), apiTokenRequired,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handler(a, w, r)
+			})))
+	}
+	mux.HandleFunc("GET "+openAPIPath, a.handleOpenAPI)
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes an {"error": ...} response.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// queryLimit returns the ?limit= parameter, def when it is missing, or an
+// error when it isn't a number between 1 and max.
+func queryLimit(r *http.Request, def, max int) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > max {
+		return 0, fmt.Errorf("limit must be a number between 1 and %d", max)
+	}
+	return limit, nil
+}
+
+// handleAPIGetConfig returns the current configuration.
+func (a *App) handleAPIGetConfig(w http.ResponseWriter, r *http.Request) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// handleAPIUpdateConfig merges the settings in the request body into the
+// current configuration, validates and saves the result and applies it to
+// the running app. Settings left out of the body keep their values.
+func (a *App) handleAPIUpdateConfig(w http.ResponseWriter, r *http.Request) {
+	// Start from a deep copy, so decoding into maps and slices doesn't
+	// touch the live config.
+	a.configMu.RLock()
+	current, err := json.Marshal(a.config)
+	a.configMu.RUnlock()
+	var cfg AppConfig
+	if err == nil {
+		err = json.Unmarshal(current, &cfg)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Copying config: %v", err))
+		return
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid config: %v", err))
+		return
+	}
+	if err := cfg.validate(); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err := checkWebhookURLs(&cfg); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err := a.previewDiscordTarget(r.Context(), &cfg, map[string]interface{}{}); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err := saveConfiguration(&cfg); err != nil {
+		a.logger.Log("error", fmt.Sprintf("Failed to save config: %v", err))
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save configuration")
+		return
+	}
+
+	a.applyConfig(&cfg, "Configuration updated through the API")
+	a.handleAPIGetConfig(w, r)
+}
+
+// apiStatus is the app's state as reported by GET /api/v1/status.
+type apiStatus struct {
+	healthReport
+	Message   string         `json:"message"`
+	Tunnel    apiTunnel      `json:"tunnel"`
+	Discovery apiDiscovery   `json:"discovery"`
+	Session   *apiSession    `json:"session,omitempty"`
+	Messages  map[string]int `json:"messages,omitempty"`
+}
+
+type apiTunnel struct {
+	URL     string `json:"url,omitempty"`
+	Pending bool   `json:"pending"`
+}
+
+type apiDiscovery struct {
+	LANURL      string `json:"lanURL,omitempty"`
+	InternetURL string `json:"internetURL,omitempty"`
+	Pending     bool   `json:"pending"`
+}
+
+type apiSession struct {
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// status gathers the app's state for the JSON API.
+func (a *App) status() apiStatus {
+	status := apiStatus{healthReport: a.health(), Message: a.statusMessage()}
+
+	tunnel := a.tunnelStatus()
+	status.Tunnel.URL, _ = tunnel["URL"].(string)
+	status.Tunnel.Pending, _ = tunnel["Pending"].(bool)
+	discovery := a.discoveryStatus()
+	status.Discovery.LANURL, _ = discovery["LANURL"].(string)
+	status.Discovery.InternetURL, _ = discovery["InternetURL"].(string)
+	status.Discovery.Pending, _ = discovery["Pending"].(bool)
+
+	if session := a.sessionData(); session != nil {
+		status.Session = &apiSession{Name: session.Name, StartedAt: session.StartedAt}
+	}
+	if a.receipts != nil {
+		status.Messages = a.receipts.summary()
+	}
+	return status
+}
+
+// handleAPIStatus returns the ingestion server, queue, session and
+// delivery state.
+func (a *App) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.status())
+}
+
+// handleAPIStartServer starts the ingestion server and returns the new
+// status. It answers 409 when the server is already running and 422 when
+// the config is invalid.
+func (a *App) handleAPIStartServer(w http.ResponseWriter, r *http.Request) {
+	if a.ingestionRunning.Load() {
+		writeJSONError(w, http.StatusConflict, "Ingestion server already running")
+		return
+	}
+
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	if err := cfg.validate(); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err := a.StartIngestionServer(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, a.status())
+}
+
+// handleAPIStopServer stops the ingestion server and returns the new
+// status. It answers 409 when the server isn't running.
+func (a *App) handleAPIStopServer(w http.ResponseWriter, r *http.Request) {
+	if !a.ingestionRunning.Load() {
+		writeJSONError(w, http.StatusConflict, "Ingestion server not running")
+		return
+	}
+	if err := a.StopIngestionServer(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to stop: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, a.status())
+}
+
+// apiLogLine is an application log line.
+type apiLogLine struct {
+	ID    int64  `json:"id"`
+	Level string `json:"level"`
+	Line  string `json:"line"`
+}
+
+type apiLogList struct {
+	Logs []apiLogLine `json:"logs"`
+}
+
+// handleAPILogs returns the most recent application log lines, oldest
+// first. They can be filtered like the live log with ?level= and
+// ?contains=, and ?after= skips lines up to a previously seen ID so a
+// script can poll for new ones.
+func (a *App) handleAPILogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, err := parseLogLineFilter(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := queryLimit(r, defaultAPILimit, maxHistorySize)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var after int64
+	if value := query.Get("after"); value != "" {
+		if after, err = strconv.ParseInt(value, 10, 64); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "after must be a log line ID")
+			return
+		}
+	}
+
+	lines := make([]apiLogLine, 0)
+	for _, ev := range a.logger.historySince(after) {
+		if !filter.match(ev.Data) {
+			continue
+		}
+		_, level := logLineLevel(ev.Data)
+		lines = append(lines, apiLogLine{ID: ev.ID, Level: level, Line: ev.Data})
+	}
+	if len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	writeJSON(w, http.StatusOK, apiLogList{Logs: lines})
+}
+
+// apiFailure is a recorded delivery failure.
+type apiFailure struct {
+	ID        int64  `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Sender    string `json:"sender,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Type      string `json:"type"`
+	Error     string `json:"error"`
+	Trace     string `json:"trace,omitempty"`
+	Retryable bool   `json:"retryable"`
+}
+
+type apiFailureList struct {
+	Failures []apiFailure `json:"failures"`
+}
+
+type apiMessageList struct {
+	Summary  map[string]int `json:"summary"`
+	Messages []Receipt      `json:"messages"`
+}
+
+// handleAPIFailures returns the recorded failures, newest first.
+func (a *App) handleAPIFailures(w http.ResponseWriter, r *http.Request) {
+	failures := a.logger.GetFailures()
+	result := make([]apiFailure, 0, len(failures))
+	for i := len(failures) - 1; i >= 0; i-- {
+		f := failures[i]
+		result = append(result, apiFailure{
+			ID:        f.ID,
+			Timestamp: f.Timestamp,
+			Sender:    f.Sender,
+			Message:   f.Message,
+			Type:      f.FailureType,
+			Error:     f.Error,
+			Trace:     f.Trace,
+			Retryable: f.Retry != nil,
+		})
+	}
+	writeJSON(w, http.StatusOK, apiFailureList{Failures: result})
+}
+
+// handleAPIRetryFailure redelivers a failure. It answers 404 for an
+// unknown failure and 409 for one that cannot be retried.
+func (a *App) handleAPIRetryFailure(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid failure ID")
+		return
+	}
+	if _, ok := a.logger.GetFailure(id); !ok {
+		writeJSONError(w, http.StatusNotFound, "Failure not found")
+		return
+	}
+	if err := a.retryFailure(id); errors.Is(err, errNotRetryable) {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Retry failed: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIDismissFailure removes a failure.
+func (a *App) handleAPIDismissFailure(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid failure ID")
+		return
+	}
+	if !a.logger.DismissFailure(id) {
+		writeJSONError(w, http.StatusNotFound, "Failure not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIClearFailures removes all failures.
+func (a *App) handleAPIClearFailures(w http.ResponseWriter, r *http.Request) {
+	a.logger.ClearFailures()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIMessages returns a per-status summary of the delivery receipts
+// and the most recent ones, optionally filtered by ?status=.
+func (a *App) handleAPIMessages(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r, defaultAPILimit, maxReceipts)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", deliveryPending, deliverySent, deliveryFailed:
+	default:
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown status %q", status))
+		return
+	}
+	if a.receipts == nil {
+		writeJSON(w, http.StatusOK, apiMessageList{Summary: map[string]int{}, Messages: []Receipt{}})
+		return
+	}
+	writeJSON(w, http.StatusOK, apiMessageList{
+		Summary:  a.receipts.summary(),
+		Messages: a.receipts.list(status, limit),
+	})
+}
+
+// handleAPIMessage returns a message's delivery receipt, with its Discord
+// reactions in bot mode.
+func (a *App) handleAPIMessage(w http.ResponseWriter, r *http.Request) {
+	if a.receipts == nil {
+		writeJSONError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+	receipt, ok := a.receipts.get(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, a.withReactions(r.Context(), receipt))
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func apiTestServer(a *App) *http.ServeMux {
+	mux := http.NewServeMux()
+	a.registerAPIv1(mux)
+	return mux
+}
+
+func TestAPIUpdateConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	setConfigPath(path)
+	defer setConfigPath("")
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableLocalSave = true
+	a.config.Path = t.TempDir()
+	mux := apiTestServer(a)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantLevel  string
+	}{
+		{"malformed body", `{"logLevel": `, http.StatusBadRequest, ""},
+		{"unknown field", `{"noSuchSetting": true}`, http.StatusBadRequest, ""},
+		{"invalid config", `{"enableLocalSave": false}`, http.StatusUnprocessableEntity, ""},
+		{"partial update", `{"logLevel": "debug"}`, http.StatusOK, "debug"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/api/v1/config", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected JSON response, got %q", ct)
+			}
+			if tt.wantStatus != http.StatusOK {
+				var body map[string]string
+				if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["error"] == "" {
+					t.Errorf("expected an error body, got %q", rec.Body.String())
+				}
+				return
+			}
+			var cfg AppConfig
+			if err := json.NewDecoder(rec.Body).Decode(&cfg); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.LogLevel != tt.wantLevel || !cfg.EnableLocalSave {
+				t.Errorf("unexpected config: %+v", cfg)
+			}
+		})
+	}
+
+	if a.config.LogLevel != "debug" || !a.config.EnableLocalSave {
+		t.Errorf("update not applied: %+v", a.config)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), `"logLevel": "debug"`) {
+		t.Errorf("config not saved: %s (%v)", data, err)
+	}
+}
+
+func TestAPILogs(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.logger.SetLogLevel("trace")
+	a.logger.Log("info", "ingestion started")
+	a.logger.Log("error", "webhook failed")
+	a.logger.Log("debug", "queue drained")
+	mux := apiTestServer(a)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantLines  []string
+	}{
+		{"all", "", http.StatusOK, []string{"ingestion started", "webhook failed", "queue drained"}},
+		{"level", "?level=error", http.StatusOK, []string{"webhook failed"}},
+		{"contains", "?contains=QUEUE", http.StatusOK, []string{"queue drained"}},
+		{"limit keeps the latest", "?limit=2", http.StatusOK, []string{"webhook failed", "queue drained"}},
+		{"unknown level", "?level=loud", http.StatusBadRequest, nil},
+		{"bad limit", "?limit=0", http.StatusBadRequest, nil},
+		{"bad after", "?after=x", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/logs"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body struct{ Logs []apiLogLine }
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Logs) != len(tt.wantLines) {
+				t.Fatalf("expected %d lines, got %+v", len(tt.wantLines), body.Logs)
+			}
+			for i, want := range tt.wantLines {
+				if !strings.Contains(body.Logs[i].Line, want) {
+					t.Errorf("line %d: expected %q, got %q", i, want, body.Logs[i].Line)
+				}
+			}
+		})
+	}
+
+	// Polling with the last seen ID returns only newer lines.
+	history := a.logger.historySince(0)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/logs?after="+strconv.FormatInt(history[1].ID, 10), nil))
+	var body struct{ Logs []apiLogLine }
+	json.NewDecoder(rec.Body).Decode(&body)
+	if len(body.Logs) != 1 || body.Logs[0].Level != "debug" {
+		t.Errorf("expected only the debug line, got %+v", body.Logs)
+	}
+}
+
+func TestAPIFailures(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.logger.LogFailure("digest", "summary", "discord", "bad webhook")
+	a.logger.LogFailure("Alice", "hello", "file", "disk full")
+	failures := a.logger.GetFailures()
+	mux := apiTestServer(a)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/failures", nil))
+	var body struct{ Failures []apiFailure }
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Failures) != 2 || body.Failures[0].Sender != "Alice" || body.Failures[0].Retryable {
+		t.Errorf("unexpected failures: %+v", body.Failures)
+	}
+
+	id := strconv.FormatInt(failures[0].ID, 10)
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"retry unknown", "POST", "/api/v1/failures/999/retry", http.StatusNotFound},
+		{"retry not retryable", "POST", "/api/v1/failures/" + id + "/retry", http.StatusConflict},
+		{"dismiss invalid ID", "DELETE", "/api/v1/failures/abc", http.StatusBadRequest},
+		{"dismiss", "DELETE", "/api/v1/failures/" + id, http.StatusNoContent},
+		{"dismiss again", "DELETE", "/api/v1/failures/" + id, http.StatusNotFound},
+		{"clear", "DELETE", "/api/v1/failures", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+	if n := len(a.logger.GetFailures()); n != 0 {
+		t.Errorf("expected failures to be cleared, got %d", n)
+	}
+}
+
+func TestAPIMessages(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.receipts = newReceiptTable(maxReceipts)
+	sent := a.receipts.add("Alice", "", "")
+	a.receipts.set(sent, sinkFile, deliverySent)
+	failed := a.receipts.add("Bob", "", "")
+	a.receipts.set(failed, sinkDiscord, deliveryFailed)
+	mux := apiTestServer(a)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{"all, newest first", "/api/v1/messages", http.StatusOK, []string{failed, sent}},
+		{"by status", "/api/v1/messages?status=sent", http.StatusOK, []string{sent}},
+		{"limit", "/api/v1/messages?limit=1", http.StatusOK, []string{failed}},
+		{"unknown status", "/api/v1/messages?status=lost", http.StatusBadRequest, nil},
+		{"bad limit", "/api/v1/messages?limit=many", http.StatusBadRequest, nil},
+		{"single", "/api/v1/messages/" + sent, http.StatusOK, []string{sent}},
+		{"unknown", "/api/v1/messages/nope", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				ID       string
+				Messages []Receipt
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			if body.ID != "" {
+				ids = []string{body.ID}
+			}
+			for _, m := range body.Messages {
+				ids = append(ids, m.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("expected %v, got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+}
+
+func TestAPIStatus(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	mux := apiTestServer(a)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/status", nil))
+	var status map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || status["ingestion"] != false || status["message"] != "Stopped" {
+		t.Errorf("unexpected status %d: %v", rec.Code, status)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/server/stop", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("stopping a stopped server: expected 409, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/server/start", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("starting with an invalid config: expected 422, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Application log file defaults. The file is rotated once it reaches
+// appLogMaxSize, keeping appLogBackups older files (app.log.1, app.log.2, ...).
+const (
+	appLogFile    = "app.log"
+	appLogMaxSize = 5 << 20
+	appLogBackups = 3
+	appLogOff     = "off"
+)
+
+// levelTrace is below slog.LevelDebug, for per-request and per-chunk
+// details.
+const levelTrace = slog.Level(-8)
+
+// appLevel is the minimum level written by the application logger. It
+// follows LogLevel.
+var appLevel = new(slog.LevelVar)
+
+// consoleOutput is where the application logger writes console output.
+// The Windows service points it at service.log.
+var consoleOutput = &switchWriter{w: os.Stderr}
+
+// switchWriter is an io.Writer whose destination can be replaced.
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// SetOutput replaces the destination.
+func (s *switchWriter) SetOutput(w io.Writer) {
+	s.mu.Lock()
+	s.w = w
+	s.mu.Unlock()
+}
+
+// appLogPath returns the application log file for cfg, or "" when file
+// output is turned off.
+func appLogPath(cfg *AppConfig) string {
+	switch cfg.AppLogPath {
+	case appLogOff:
+		return ""
+	case "":
+		return filepath.Join(filepath.Dir(getConfigPath()), appLogFile)
+	}
+	return cfg.AppLogPath
+}
+
+// setupAppLog installs the default slog logger, which writes text to the
+// console and, when path is non-empty, JSON or text records to a rotating
+// file. The standard log package is routed through it as well. It returns
+// a logger that writes only to the file (nil without one) and the file to
+// close on exit.
+func setupAppLog(path, format string) (*slog.Logger, io.Closer, error) {
+	opts := &slog.HandlerOptions{Level: appLevel, ReplaceAttr: replaceAttr}
+	console := slog.NewTextHandler(consoleOutput, opts)
+	if path == "" {
+		slog.SetDefault(slog.New(console))
+		return nil, nil, nil
+	}
+
+	file, err := openRotatingFile(path, appLogMaxSize, appLogBackups)
+	if err != nil {
+		slog.SetDefault(slog.New(console))
+		return nil, nil, err
+	}
+	var fileHandler slog.Handler
+	if format == "json" {
+		fileHandler = slog.NewJSONHandler(file, opts)
+	} else {
+		fileHandler = slog.NewTextHandler(file, opts)
+	}
+	slog.SetDefault(slog.New(multiHandler{console, fileHandler}))
+	return slog.New(fileHandler), file, nil
+}
+
+// parseLogLevel maps a level name (error, warn, info, debug or trace) to
+// its slog level. An empty name is info; ok is false for unknown names.
+func parseLogLevel(name string) (level slog.Level, ok bool) {
+	switch name {
+	case "trace":
+		return levelTrace, true
+	case "debug":
+		return slog.LevelDebug, true
+	case "", "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
+}
+
+// levelFor maps the level names used by SSELogger to slog levels.
+func levelFor(level string) slog.Level {
+	l, _ := parseLogLevel(level)
+	return l
+}
+
+// replaceAttr prepares application log attributes: the trace level gets
+// its name and secrets are redacted.
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	return redactAttr(replaceTraceLevel(groups, a))
+}
+
+// replaceTraceLevel prints levelTrace as TRACE instead of DEBUG-4.
+func replaceTraceLevel(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := a.Value.Any().(slog.Level); ok && level == levelTrace {
+			a.Value = slog.StringValue("TRACE")
+		}
+	}
+	return a
+}
+
+// multiHandler sends each record to every handler that accepts its level.
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range m {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(multiHandler, len(m))
+	for i, h := range m {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	out := make(multiHandler, len(m))
+	for i, h := range m {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// rotatingFile is an append-only file that is renamed to path.1 (shifting
+// older backups up) once it would grow past maxSize.
+type rotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating log directory: %w", err)
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening app log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("reading app log size: %w", err)
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+	for i := r.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if r.backups > 0 {
+		os.Rename(r.path, r.path+".1")
+	} else {
+		os.Remove(r.path)
+	}
+	return r.open()
+}
+
+// Close closes the current file.
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: got %q, want %q", filepath.Base(name), data, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, stat .3: %v", err)
+	}
+}
+
+func TestAppLogPath(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"default", "", filepath.Join(filepath.Dir(getConfigPath()), appLogFile)},
+		{"off", appLogOff, ""},
+		{"custom", "/var/log/rpcl.log", "/var/log/rpcl.log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appLogPath(&AppConfig{AppLogPath: tt.path}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSELoggerWritesFileLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	fileLog, closer, err := setupAppLog(path, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	defer setupAppLog("", "")
+
+	broker, failureBroker := NewSSEBroker(), NewSSEBroker()
+	defer func() { broker.Stop(); failureBroker.Stop() }()
+	logger := NewSSELogger(broker, failureBroker)
+	logger.SetFileLogger(fileLog)
+
+	logger.Log("warning", "disk almost full")
+	logger.Log("debug", "hidden")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"level":"WARN","msg":"disk almost full"`) {
+		t.Errorf("warning not written as JSON: %s", data)
+	}
+	if strings.Contains(string(data), "hidden") {
+		t.Errorf("debug line written without debug mode: %s", data)
+	}
+}
+
+func TestSSELoggerLevels(t *testing.T) {
+	broker, failureBroker := NewSSEBroker(), NewSSEBroker()
+	defer func() { broker.Stop(); failureBroker.Stop() }()
+	defer appLevel.Set(slog.LevelInfo)
+
+	tests := []struct {
+		level string
+		want  []string
+	}{
+		{"error", []string{"[ERROR] e"}},
+		{"warn", []string{"[ERROR] e", "[WARNING] w"}},
+		{"", []string{"[ERROR] e", "[WARNING] w", "[INFO] i"}},
+		{"trace", []string{"[ERROR] e", "[WARNING] w", "[INFO] i", "[DEBUG] d", "[TRACE] t"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			logger := NewSSELogger(broker, failureBroker)
+			logger.SetLogLevel(tt.level)
+			for _, level := range []string{"error", "warning", "info", "debug", "trace"} {
+				logger.Log(level, level[:1])
+			}
+			history := logger.GetHistory()
+			if len(history) != len(tt.want) {
+				t.Fatalf("got %d lines, want %d: %v", len(history), len(tt.want), history)
+			}
+			for i, want := range tt.want {
+				if !strings.HasSuffix(history[i], want) {
+					t.Errorf("line %d: got %q, want suffix %q", i, history[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// archiveUploadInterval is how often finished days are checked for upload.
+const archiveUploadInterval = 5 * time.Minute
+
+// maxUploadCatchUp bounds how many missed days one run goes back.
+const maxUploadCatchUp = 366
+
+// archiveSink is remote storage that finished daily log files are copied
+// to. key is the file's path relative to the log folder, with forward
+// slashes.
+type archiveSink interface {
+	name() string
+	put(ctx context.Context, key, path string) error
+}
+
+// archiveSinks returns the upload sinks enabled in config.
+func archiveSinks(config *AppConfig) []archiveSink {
+	var sinks []archiveSink
+	if config.EnableS3 {
+		sinks = append(sinks, newS3Sink(config))
+	}
+	if config.EnableWebDAV {
+		sinks = append(sinks, newWebDAVSink(config))
+	}
+	if config.EnableGDrive {
+		sinks = append(sinks, newGDriveSink(config))
+	}
+	return sinks
+}
+
+// runArchiveUploader uploads each day's log files once the day is over.
+// The config is read on every tick so changes apply without a restart.
+func (a *App) runArchiveUploader() {
+	ticker := time.NewTicker(archiveUploadInterval)
+	defer ticker.Stop()
+
+	for {
+		a.uploadFinishedDays(context.Background(), time.Now())
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// uploadFinishedDays uploads the log files of every day after LastUpload up
+// to yesterday to all sinks, recording progress in the config so a restart
+// doesn't upload a day twice. A failed day is retried on the next run.
+func (a *App) uploadFinishedDays(ctx context.Context, now time.Time) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	sinks := archiveSinks(&cfg)
+	if len(sinks) == 0 || cfg.Path == "" {
+		return
+	}
+
+	yesterday := truncateToDay(now).AddDate(0, 0, -1)
+	day := yesterday
+	if last, err := time.ParseInLocation("2006-01-02", cfg.LastUpload, time.Local); err == nil {
+		day = last.AddDate(0, 0, 1)
+	}
+	if oldest := yesterday.AddDate(0, 0, -maxUploadCatchUp); day.Before(oldest) {
+		day = oldest
+	}
+
+	for ; !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+		if err := a.uploadDay(ctx, &cfg, sinks, day); err != nil {
+			slog.Warn("Log upload failed", "day", day.Format("2006-01-02"), "err", err)
+			a.logger.Log("error", fmt.Sprintf("Log upload failed: %v", err))
+			return
+		}
+
+		a.configMu.Lock()
+		a.config.LastUpload = day.Format("2006-01-02")
+		saved := *a.config
+		a.configMu.Unlock()
+		if err := saveConfiguration(&saved); err != nil {
+			a.logger.Log("error", fmt.Sprintf("Failed to save upload state: %v", err))
+		}
+	}
+}
+
+// uploadDay copies one day's log files, including the OOC folder, and the
+// log folder's annotations to every sink, and then deletes the local log
+// files if configured to.
+func (a *App) uploadDay(ctx context.Context, config *AppConfig, sinks []archiveSink, day time.Time) error {
+	files, err := logFilesForDate(config, config.Path, day)
+	if err != nil {
+		return err
+	}
+	oocFiles, err := logFilesForDate(config, filepath.Join(config.Path, "ooc"), day)
+	if err != nil {
+		return err
+	}
+	files = append(files, oocFiles...)
+	if len(files) == 0 {
+		return nil
+	}
+
+	// The folder's annotations go along; they stay for the logs left.
+	uploads := files
+	if _, err := os.Stat(annotationsPath(config.Path)); err == nil {
+		uploads = append(files[:len(files):len(files)], annotationsPath(config.Path))
+	}
+	for _, sink := range sinks {
+		for _, file := range uploads {
+			rel, err := filepath.Rel(config.Path, file)
+			if err != nil {
+				return err
+			}
+			if err := sink.put(ctx, filepath.ToSlash(rel), file); err != nil {
+				return fmt.Errorf("%s: %w", sink.name(), err)
+			}
+		}
+		a.logger.Log("info", fmt.Sprintf("Uploaded %d log file(s) for %s to %s", len(files), day.Format("2006-01-02"), sink.name()))
+	}
+
+	if !config.UploadDeleteLocal {
+		return nil
+	}
+	a.lockArchive()
+	defer a.archiveMu.Unlock()
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			return fmt.Errorf("removing uploaded file: %w", err)
+		}
+		removeEmptyDirs(config.Path, filepath.Dir(file))
+	}
+	return nil
+}
+
+// uploadSessionTranscript copies a finished session's transcript to every
+// sink under "sessions/". It runs when a session ends.
+func (a *App) uploadSessionTranscript(ctx context.Context, session Session) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	sinks := archiveSinks(&cfg)
+	if len(sinks) == 0 || !cfg.EnableLocalSave || cfg.Path == "" {
+		return
+	}
+	path := sessionLogFilename(cfg.Path, session.Name, logFormat(&cfg))
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	key := "sessions/" + filepath.Base(path)
+	for _, sink := range sinks {
+		if err := sink.put(ctx, key, path); err != nil {
+			a.logger.Log("error", fmt.Sprintf("Session transcript upload to %s failed: %v", sink.name(), err))
+			continue
+		}
+		a.logger.Log("info", fmt.Sprintf("Uploaded session transcript %s to %s", session.Name, sink.name()))
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fakeSink records uploaded keys and can be made to fail.
+type fakeSink struct {
+	keys []string
+	err  error
+}
+
+func (s *fakeSink) name() string { return "fake" }
+
+func (s *fakeSink) put(ctx context.Context, key, path string) error {
+	if s.err != nil {
+		return s.err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+func TestUploadFinishedDays(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 17, 0, 10, 0, 0, time.Local)
+	cfg := &AppConfig{Path: dir, FileFormat: "txt", FolderLayout: folderMonth, LastUpload: "2026-10-14"}
+	for _, day := range []time.Time{now.AddDate(0, 0, -3), now.AddDate(0, 0, -2), now.AddDate(0, 0, -1), now} {
+		path := logFilePath(cfg, dir, day, LogEntry{Timestamp: "x"})
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x"), 0644)
+	}
+	ooc := logFilePath(cfg, filepath.Join(dir, "ooc"), now.AddDate(0, 0, -1), LogEntry{Timestamp: "x"})
+	os.MkdirAll(filepath.Dir(ooc), 0755)
+	os.WriteFile(ooc, []byte("x"), 0644)
+	os.WriteFile(annotationsPath(dir), []byte("[]"), 0644)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config = cfg
+	sink := &fakeSink{}
+	sinks := []archiveSink{sink}
+	for _, day := range []time.Time{now.AddDate(0, 0, -2), now.AddDate(0, 0, -1)} {
+		if err := a.uploadDay(context.Background(), cfg, sinks, day); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(sink.keys)
+	want := []string{
+		"2026/10/ConanExiles_log_2026-10-15.txt",
+		"2026/10/ConanExiles_log_2026-10-16.txt",
+		annotationsFile,
+		annotationsFile,
+		"ooc/2026/10/ConanExiles_log_2026-10-16.txt",
+	}
+	if len(sink.keys) != len(want) {
+		t.Fatalf("uploaded %v, want %v", sink.keys, want)
+	}
+	for i := range want {
+		if sink.keys[i] != want[i] {
+			t.Errorf("key %d = %s, want %s", i, sink.keys[i], want[i])
+		}
+	}
+
+	// With no sinks enabled nothing happens and progress isn't recorded.
+	a.uploadFinishedDays(context.Background(), now)
+	if cfg.LastUpload != "2026-10-14" {
+		t.Errorf("LastUpload = %s without sinks", cfg.LastUpload)
+	}
+
+	// Deleting after upload keeps today's file and a failure stops early.
+	cfg.UploadDeleteLocal = true
+	if err := a.uploadDay(context.Background(), cfg, []archiveSink{&fakeSink{err: errors.New("down")}}, now.AddDate(0, 0, -1)); err == nil {
+		t.Fatal("expected an upload error")
+	}
+	if files, _ := logFilesForDate(cfg, dir, now.AddDate(0, 0, -1)); len(files) != 1 {
+		t.Errorf("failed upload should keep local files, got %v", files)
+	}
+	if err := a.uploadDay(context.Background(), cfg, sinks, now.AddDate(0, 0, -1)); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := logFilesForDate(cfg, dir, now.AddDate(0, 0, -1)); len(files) != 0 {
+		t.Errorf("uploaded files should be deleted, got %v", files)
+	}
+	if files, _ := logFilesForDate(cfg, dir, now); len(files) != 1 {
+		t.Errorf("today's file should be kept, got %v", files)
+	}
+	if _, err := os.Stat(annotationsPath(dir)); err != nil {
+		t.Errorf("annotations should be kept for the logs left: %v", err)
+	}
+}
+
+func TestUploadFinishedDays_S3(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+
+	var puts []string
+	srv := newS3TestServer(t, &puts)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 17, 0, 10, 0, 0, time.Local)
+	cfg := &AppConfig{Path: dir, FileFormat: "txt", EnableS3: true, S3Endpoint: srv.URL, S3Bucket: "logs",
+		S3AccessKey: "AK", S3SecretKey: "SK", S3PathStyle: true, LastUpload: "2026-10-14"}
+	for _, day := range []time.Time{now.AddDate(0, 0, -2), now.AddDate(0, 0, -1), now} {
+		writeLogEntry(logFilenameForDate(dir, "txt", day), "txt", logLayout{}, LogEntry{Timestamp: day.Format(logTimestampLayout), Sender: "A", Message: "hi"})
+	}
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config = cfg
+	a.uploadFinishedDays(context.Background(), now)
+
+	if len(puts) != 2 || puts[0] != "/logs/ConanExiles_log_2026-10-15.txt" || puts[1] != "/logs/ConanExiles_log_2026-10-16.txt" {
+		t.Errorf("unexpected uploads %v", puts)
+	}
+	if cfg.LastUpload != "2026-10-16" {
+		t.Errorf("LastUpload = %s, want 2026-10-16", cfg.LastUpload)
+	}
+
+	// A second run has nothing left to upload.
+	a.uploadFinishedDays(context.Background(), now.Add(time.Hour))
+	if len(puts) != 2 {
+		t.Errorf("second run uploaded again: %v", puts)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backup defaults.
+const (
+	defaultBackupSchedule = "0 3 * * *"
+	defaultBackupKeep     = 7
+	backupDirName         = "backups"
+)
+
+var backupClient = &http.Client{
+	Timeout: 10 * time.Minute,
+}
+
+// backupDestination is somewhere a finished backup zip is copied to.
+type backupDestination interface {
+	name() string
+	upload(ctx context.Context, path string) error
+}
+
+// backupDestinations returns the upload destinations configured for
+// backups. The local backups folder is always written and isn't included.
+func backupDestinations(config *AppConfig) []backupDestination {
+	var dests []backupDestination
+	if config.BackupUploadURL != "" {
+		dests = append(dests, httpPutDestination{url: config.BackupUploadURL})
+	}
+	for _, sink := range archiveSinks(config) {
+		if dest, ok := sink.(backupDestination); ok {
+			dests = append(dests, dest)
+		}
+	}
+	return dests
+}
+
+// httpPutDestination uploads backups with an HTTP PUT. A URL ending in "/"
+// is treated as a folder and gets the zip's file name appended.
+type httpPutDestination struct {
+	url string
+}
+
+func (d httpPutDestination) name() string { return "upload URL" }
+
+func (d httpPutDestination) upload(ctx context.Context, path string) error {
+	target := d.url
+	if strings.HasSuffix(target, "/") {
+		target += url.PathEscape(filepath.Base(path))
+	}
+	return putFile(ctx, target, path, nil)
+}
+
+// putFile sends the file at path as the body of a PUT request to target,
+// with any extra headers (Content-Type defaults to application/zip), and
+// checks for a 2xx response.
+func putFile(ctx context.Context, target, path string, header http.Header) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening upload: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("opening upload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, f)
+	if err != nil {
+		return fmt.Errorf("creating upload request: %w", err)
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/zip")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := backupClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w", filepath.Base(path), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("uploading %s: status %d", filepath.Base(path), resp.StatusCode)
+	}
+	return nil
+}
+
+// backupDir returns the folder backups are written to: the configured one,
+// or "backups" next to the config file.
+func backupDir(config *AppConfig) string {
+	if config.BackupPath != "" {
+		return config.BackupPath
+	}
+	return filepath.Join(filepath.Dir(getConfigPath()), backupDirName)
+}
+
+// backupSchedule returns the configured schedule, or the default.
+func backupSchedule(config *AppConfig) string {
+	if config.BackupSchedule == "" {
+		return defaultBackupSchedule
+	}
+	return config.BackupSchedule
+}
+
+// runBackupScheduler checks once a minute whether a scheduled backup is
+// due. The config is read on every tick so changes apply without a
+// restart; backups missed while the app wasn't running are not made up.
+func (a *App) runBackupScheduler() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	var last time.Time
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-ticker.C:
+			minute := now.Truncate(time.Minute)
+			if minute.Equal(last) || !a.backupDue(now) {
+				continue
+			}
+			last = minute
+			if _, err := a.backup(context.Background(), now); err != nil {
+				slog.Error("Scheduled backup failed", "err", err)
+			}
+		}
+	}
+}
+
+// backupDue reports whether backups are enabled and scheduled for the
+// minute containing now.
+func (a *App) backupDue(now time.Time) bool {
+	a.configMu.RLock()
+	enabled := a.config.EnableBackup
+	spec := backupSchedule(a.config)
+	a.configMu.RUnlock()
+
+	if !enabled {
+		return false
+	}
+	schedule, err := parseCron(spec)
+	if err != nil {
+		return false
+	}
+	return schedule.matches(now)
+}
+
+// backup writes a backup zip, uploads it to the configured destinations and
+// prunes old local backups. It returns the zip's path; upload failures are
+// reported in the error but the local backup is kept.
+func (a *App) backup(ctx context.Context, now time.Time) (string, error) {
+	a.backupMu.Lock()
+	defer a.backupMu.Unlock()
+
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	dir := backupDir(&cfg)
+	a.lockArchive()
+	path, err := createBackup(&cfg, dir, now)
+	a.archiveMu.Unlock()
+	if err != nil {
+		a.logger.Log("error", fmt.Sprintf("Backup failed: %v", err))
+		return "", err
+	}
+	a.logger.Log("info", fmt.Sprintf("Backup written to %s", path))
+
+	var errs []error
+	for _, dest := range backupDestinations(&cfg) {
+		if err := dest.upload(ctx, path); err != nil {
+			a.logger.Log("error", fmt.Sprintf("Backup upload to %s failed: %v", dest.name(), err))
+			errs = append(errs, fmt.Errorf("%s: %w", dest.name(), err))
+			continue
+		}
+		a.logger.Log("info", fmt.Sprintf("Backup uploaded to %s", dest.name()))
+	}
+
+	keep := cfg.BackupKeep
+	if keep == 0 {
+		keep = defaultBackupKeep
+	}
+	if err := pruneBackups(dir, keep); err != nil {
+		slog.Warn("Failed to prune old backups", "err", err)
+	}
+	return path, errors.Join(errs...)
+}
+
+// createBackup zips the config file and the log folder into a new
+// timestamped file in dir and returns its path. Log files are stored under
+// "logs/"; the backups folder itself is skipped if it lies inside the log
+// folder.
+func createBackup(config *AppConfig, dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("creating backup directory: %w", err)
+	}
+	name := filepath.Join(dir, fmt.Sprintf("backup_%s.zip", now.Format("2006-01-02_150405")))
+	tmp := name + ".tmp"
+
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("creating backup: %w", err)
+	}
+	zw := zip.NewWriter(out)
+	err = writeBackup(zw, config, dir)
+	if cerr := zw.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("writing backup: %w", cerr)
+	}
+	if cerr := out.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("writing backup: %w", cerr)
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return name, nil
+}
+
+// writeBackup adds the config file and the log folder to zw.
+func writeBackup(zw *zip.Writer, config *AppConfig, dir string) error {
+	configPath := getConfigPath()
+	if _, err := os.Stat(configPath); err == nil {
+		if err := addToZipAs(zw, configPath, "config.json"); err != nil {
+			return err
+		}
+	}
+	if config.Path == "" {
+		return nil
+	}
+
+	skip, _ := filepath.Abs(dir)
+	err := filepath.WalkDir(config.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if abs, _ := filepath.Abs(path); abs == skip {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(path, ".tmp") || d.Name() == logFolderLockFile {
+			return nil
+		}
+		rel, err := filepath.Rel(config.Path, path)
+		if err != nil {
+			return err
+		}
+		return addToZipAs(zw, path, "logs/"+filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return fmt.Errorf("backing up log folder: %w", err)
+	}
+	return nil
+}
+
+// pruneBackups deletes all but the newest keep backups in dir.
+func pruneBackups(dir string, keep int) error {
+	matches, err := filepath.Glob(filepath.Join(globEscape(dir), "backup_*.zip"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(matches)
+	for len(matches) > keep {
+		if err := os.Remove(matches[0]); err != nil {
+			return err
+		}
+		slog.Info("Removed old backup", "file", filepath.Base(matches[0]))
+		matches = matches[1:]
+	}
+	return nil
+}
+
+// handleBackup makes a backup now and reports the result as an alert.
+func (a *App) handleBackup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	path, err := a.backup(r.Context(), time.Now())
+	switch {
+	case path == "":
+		fmt.Fprintf(w, `<div class="alert error">Backup failed: %s</div>`, template.HTMLEscapeString(err.Error()))
+	case err != nil:
+		fmt.Fprintf(w, `<div class="alert error">Backup saved to %s, but uploading failed: %s</div>`,
+			template.HTMLEscapeString(path), template.HTMLEscapeString(err.Error()))
+	default:
+		fmt.Fprintf(w, `<div class="alert success">Backup saved to %s</div>`, template.HTMLEscapeString(path))
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateBackup(t *testing.T) {
+	root := t.TempDir()
+	setConfigPath(filepath.Join(root, "config.json"))
+	defer setConfigPath("")
+	os.WriteFile(getConfigPath(), []byte(`{"path":"logs"}`), 0600)
+
+	logDir := filepath.Join(root, "logs")
+	os.MkdirAll(filepath.Join(logDir, "sessions"), 0755)
+	os.WriteFile(filepath.Join(logDir, "ConanExiles_log_2026-10-17.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(logDir, "sessions", "raid.txt"), []byte("b"), 0644)
+
+	// A backups folder inside the log folder must not back itself up.
+	backups := filepath.Join(logDir, "backups")
+	os.MkdirAll(backups, 0755)
+	os.WriteFile(filepath.Join(backups, "backup_old.zip"), []byte("old"), 0644)
+
+	path, err := createBackup(&AppConfig{Path: logDir}, backups, time.Date(2026, 10, 17, 3, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "backup_2026-10-17_030000.zip" {
+		t.Errorf("unexpected backup name %s", path)
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	want := "config.json,logs/ConanExiles_log_2026-10-17.txt,logs/sessions/raid.txt"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("backup contains %s, want %s", got, want)
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	for day := 1; day <= 5; day++ {
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("backup_2026-10-0%d_030000.zip", day)), nil, 0644)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644)
+
+	if err := pruneBackups(dir, 2); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := "backup_2026-10-04_030000.zip,backup_2026-10-05_030000.zip,notes.txt"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("left %s, want %s", got, want)
+	}
+}
+
+func TestBackup_Upload(t *testing.T) {
+	root := t.TempDir()
+	setConfigPath(filepath.Join(root, "config.json"))
+	defer setConfigPath("")
+
+	var gotPath string
+	var gotSize int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("expected PUT, got %s", r.Method)
+		}
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotSize = r.URL.Path, len(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.Path = t.TempDir()
+	a.config.BackupUploadURL = srv.URL + "/backups/"
+
+	path, err := a.backup(context.Background(), time.Date(2026, 10, 17, 3, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != filepath.Join(root, backupDirName) {
+		t.Errorf("backup written to %s, want the default folder", path)
+	}
+	if gotPath != "/backups/backup_2026-10-17_030000.zip" || gotSize == 0 {
+		t.Errorf("uploaded %d bytes to %s", gotSize, gotPath)
+	}
+
+	// A failing upload keeps the local backup and reports the error.
+	a.config.BackupUploadURL = srv.URL + "/missing"
+	srv.Config.Handler = http.NotFoundHandler()
+	path, err = a.backup(context.Background(), time.Date(2026, 10, 17, 4, 0, 0, 0, time.Local))
+	if err == nil || path == "" {
+		t.Fatalf("expected a kept backup and an upload error, got %q, %v", path, err)
+	}
+	if _, statErr := os.Stat(path); statErr != nil {
+		t.Errorf("local backup missing: %v", statErr)
+	}
+}
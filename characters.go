@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxCharacterName bounds a character's display name, Discord's limit
+// for the name a webhook posts under.
+const maxCharacterName = 80
+
+// Character is how a player character is shown on Discord, in campaign
+// books and on the live viewer. Messages sent under Name or any of Aliases
+// (ignoring case) are shown under Name, with the character's avatar and
+// color.
+type Character struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	// DiscordID is the player's Discord user ID: what they post in the
+	// relayed channel reaches the game under the character's name.
+	DiscordID string `json:"discordID,omitempty"`
+	AvatarURL string `json:"avatarURL,omitempty"`
+	Color     string `json:"color,omitempty"` // #rrggbb
+}
+
+// characterColor matches the colors a character may have.
+var characterColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// checkCharacters reports the first character that is incomplete, or whose
+// name or an alias another character already uses.
+func checkCharacters(characters []Character) error {
+	taken := make(map[string]string)
+	for _, ch := range characters {
+		if ch.Name == "" || len(ch.Name) > maxCharacterName {
+			return fmt.Errorf("Character names must be 1 to %d characters", maxCharacterName)
+		}
+		for _, name := range append([]string{ch.Name}, ch.Aliases...) {
+			key := strings.ToLower(name)
+			if other, ok := taken[key]; ok {
+				return fmt.Errorf("%q is used by both %s and %s", name, other, ch.Name)
+			}
+			taken[key] = ch.Name
+		}
+		if ch.DiscordID != "" && strings.Trim(ch.DiscordID, "0123456789") != "" {
+			return fmt.Errorf("Discord ID of %s must be a number", ch.Name)
+		}
+		if ch.AvatarURL != "" {
+			if u, err := url.Parse(ch.AvatarURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("Avatar of %s must be an http or https URL", ch.Name)
+			}
+		}
+		if ch.Color != "" && !characterColor.MatchString(ch.Color) {
+			return fmt.Errorf("Color of %s must look like #a1b2c3", ch.Name)
+		}
+	}
+	return nil
+}
+
+// findCharacter returns the character sender is the name or an alias of.
+func findCharacter(cfg *AppConfig, sender string) (Character, bool) {
+	sender = strings.TrimSpace(sender)
+	for _, ch := range cfg.Characters {
+		if strings.EqualFold(ch.Name, sender) {
+			return ch, true
+		}
+		for _, alias := range ch.Aliases {
+			if strings.EqualFold(alias, sender) {
+				return ch, true
+			}
+		}
+	}
+	return Character{}, false
+}
+
+// displayName returns the name sender is shown under: its character's
+// name, or sender itself.
+func displayName(cfg *AppConfig, sender string) string {
+	if ch, ok := findCharacter(cfg, sender); ok {
+		return ch.Name
+	}
+	return sender
+}
+
+// characterByDiscordID returns the character played by a Discord user.
+func characterByDiscordID(cfg *AppConfig, id string) (Character, bool) {
+	for _, ch := range cfg.Characters {
+		if id != "" && ch.DiscordID == id {
+			return ch, true
+		}
+	}
+	return Character{}, false
+}
+
+// characterColors maps the display names of the characters with a color to
+// it.
+func characterColors(cfg *AppConfig) map[string]string {
+	colors := make(map[string]string)
+	for _, ch := range cfg.Characters {
+		if ch.Color != "" {
+			colors[ch.Name] = ch.Color
+		}
+	}
+	return colors
+}
+
+// saveCharacter stores ch in the config, replacing the character named
+// original, or adding it when original is empty, and saves the config.
+func (a *App) saveCharacter(original string, ch Character) error {
+	return a.updateCharacters(func(characters []Character) ([]Character, error) {
+		if original == "" {
+			return append(characters, ch), nil
+		}
+		for i := range characters {
+			if characters[i].Name == original {
+				characters[i] = ch
+				return characters, nil
+			}
+		}
+		return nil, fmt.Errorf("No character named %s", original)
+	})
+}
+
+// deleteCharacter removes the character named name and saves the config.
+func (a *App) deleteCharacter(name string) error {
+	return a.updateCharacters(func(characters []Character) ([]Character, error) {
+		for i := range characters {
+			if characters[i].Name == name {
+				return append(characters[:i], characters[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("No character named %s", name)
+	})
+}
+
+// updateCharacters applies change to a copy of the characters and, when
+// the result is valid and saved, to the running config.
+func (a *App) updateCharacters(change func([]Character) ([]Character, error)) error {
+	a.charactersMu.Lock()
+	defer a.charactersMu.Unlock()
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	characters, err := change(append([]Character(nil), cfg.Characters...))
+	if err != nil {
+		return err
+	}
+	if err := checkCharacters(characters); err != nil {
+		return err
+	}
+	cfg.Characters = characters
+	if err := saveConfiguration(&cfg); err != nil {
+		a.logger.Log("error", fmt.Sprintf("Failed to save characters: %v", err))
+		return fmt.Errorf("Failed to save characters")
+	}
+	a.configMu.Lock()
+	a.config.Characters = characters
+	a.configMu.Unlock()
+	return nil
+}
+
+// characterForm reads a character from the add or edit form.
+func characterForm(r *http.Request) Character {
+	return Character{
+		Name:      strings.TrimSpace(r.FormValue("name")),
+		Aliases:   parseList(r.FormValue("aliases")),
+		DiscordID: strings.TrimSpace(r.FormValue("discordID")),
+		AvatarURL: strings.TrimSpace(r.FormValue("avatarURL")),
+		Color:     strings.TrimSpace(r.FormValue("color")),
+	}
+}
+
+// handleCharactersPage renders the characters page.
+func (a *App) handleCharactersPage(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := a.parseTemplates(
+		"templates/layout.html",
+		"templates/characters.html",
+		"templates/partials/character_list.html",
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := a.characterListData("", "")
+	data["Prefs"] = a.uiPreferences(w, r)
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// handleCharacters returns the character list as an HTML partial.
+func (a *App) handleCharacters(w http.ResponseWriter, r *http.Request) {
+	a.renderCharacterList(w, "", "")
+}
+
+// handleSaveCharacter adds a character, or edits the one named in the
+// original form field, and returns the updated list.
+func (a *App) handleSaveCharacter(w http.ResponseWriter, r *http.Request) {
+	ch := characterForm(r)
+	original := r.FormValue("original")
+	if err := a.saveCharacter(original, ch); err != nil {
+		a.renderCharacterList(w, "", err.Error())
+		return
+	}
+	a.logger.Log("info", fmt.Sprintf("Character %s saved", ch.Name))
+	a.renderCharacterList(w, fmt.Sprintf("Saved %s", ch.Name), "")
+}
+
+// handleDeleteCharacter removes a character and returns the updated list.
+func (a *App) handleDeleteCharacter(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := a.deleteCharacter(name); err != nil {
+		a.renderCharacterList(w, "", err.Error())
+		return
+	}
+	a.logger.Log("info", fmt.Sprintf("Character %s deleted", name))
+	a.renderCharacterList(w, fmt.Sprintf("Deleted %s", name), "")
+}
+
+// characterRow is a character as the list shows it; Path is its name
+// escaped for the delete URL.
+type characterRow struct {
+	Character
+	Path string
+}
+
+// characterListData is the character list's data.
+func (a *App) characterListData(message, errMsg string) map[string]interface{} {
+	a.configMu.RLock()
+	characters := a.config.Characters
+	a.configMu.RUnlock()
+	rows := make([]characterRow, len(characters))
+	for i, ch := range characters {
+		rows[i] = characterRow{Character: ch, Path: url.PathEscape(ch.Name)}
+	}
+	return map[string]interface{}{
+		"Characters": rows,
+		"Message":    message,
+		"Error":      errMsg,
+	}
+}
+
+func (a *App) renderCharacterList(w http.ResponseWriter, message, errMsg string) {
+	tmpl, err := a.parseTemplates("templates/partials/character_list.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "character-list", a.characterListData(message, errMsg)); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
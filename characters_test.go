@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckCharacters(t *testing.T) {
+	tests := []struct {
+		name       string
+		characters []Character
+		wantErr    string
+	}{
+		{"none", nil, ""},
+		{"complete", []Character{{Name: "Conan", Aliases: []string{"conan_cimmeria"}, DiscordID: "80351110224678912", AvatarURL: "https://example.com/conan.png", Color: "#C0392B"}}, ""},
+		{"no name", []Character{{Aliases: []string{"conan"}}}, "Character names must be"},
+		{"alias of another", []Character{{Name: "Conan"}, {Name: "Amra", Aliases: []string{"conan"}}}, `"conan" is used by both Conan and Amra`},
+		{"Discord ID", []Character{{Name: "Conan", DiscordID: "@conan"}}, "Discord ID of Conan"},
+		{"avatar", []Character{{Name: "Conan", AvatarURL: "conan.png"}}, "Avatar of Conan"},
+		{"color", []Character{{Name: "Conan", Color: "red"}}, "Color of Conan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCharacters(tt.characters)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFindCharacter(t *testing.T) {
+	cfg := &AppConfig{Characters: []Character{
+		{Name: "Conan", Aliases: []string{"conan_cimmeria", "Amra"}, DiscordID: "42"},
+		{Name: "Valeria"},
+	}}
+	tests := []struct {
+		sender string
+		want   string
+	}{
+		{"Conan", "Conan"},
+		{" AMRA ", "Conan"},
+		{"conan_cimmeria", "Conan"},
+		{"valeria", "Valeria"},
+		{"Bêlit", "Bêlit"},
+	}
+	for _, tt := range tests {
+		if got := displayName(cfg, tt.sender); got != tt.want {
+			t.Errorf("displayName(%q) = %q, want %q", tt.sender, got, tt.want)
+		}
+	}
+	if ch, ok := characterByDiscordID(cfg, "42"); !ok || ch.Name != "Conan" {
+		t.Errorf("characterByDiscordID(42) = %+v, %v", ch, ok)
+	}
+	if _, ok := characterByDiscordID(cfg, ""); ok {
+		t.Error("characterByDiscordID matched an empty ID")
+	}
+}
+
+func TestCharacterHandlers(t *testing.T) {
+	oldConfigPath := configPathOverride
+	configPathOverride = filepath.Join(t.TempDir(), "config.json")
+	defer func() { configPathOverride = oldConfigPath }()
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	handler := http.NewServeMux()
+	handler.HandleFunc("POST /api/characters", a.handleSaveCharacter)
+	handler.HandleFunc("DELETE /api/characters/{name}", a.handleDeleteCharacter)
+
+	post := func(form url.Values) string {
+		req := httptest.NewRequest("POST", "/api/characters", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	body := post(url.Values{"name": {"Conan of Cimmeria"}, "aliases": {"conan, Amra"}, "color": {"#c0392b"}})
+	if !strings.Contains(body, "Saved Conan of Cimmeria") || !strings.Contains(body, "background: #c0392b") {
+		t.Fatalf("add: %s", body)
+	}
+	if body := post(url.Values{"name": {"Valeria"}, "aliases": {"amra"}}); !strings.Contains(body, `is used by both`) {
+		t.Errorf("duplicate alias accepted: %s", body)
+	}
+	body = post(url.Values{"original": {"Conan of Cimmeria"}, "name": {"Conan of Cimmeria"}, "aliases": {"conan"}, "discordID": {"42"}})
+	if !strings.Contains(body, "Discord 42") {
+		t.Errorf("edit: %s", body)
+	}
+
+	saved, err := loadConfiguration(ConfigOverrides{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Characters) != 1 || saved.Characters[0].DiscordID != "42" || len(saved.Characters[0].Aliases) != 1 {
+		t.Errorf("saved characters = %+v", saved.Characters)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("DELETE", "/api/characters/"+url.PathEscape("Conan of Cimmeria"), nil))
+	if !strings.Contains(rec.Body.String(), "Deleted Conan of Cimmeria") || len(a.config.Characters) != 0 {
+		t.Errorf("delete: %s", rec.Body.String())
+	}
+}
+
+func TestEPUBCharacterColors(t *testing.T) {
+	chapter := epubChapter{Title: "Tavern", Entries: []LogEntry{
+		{Timestamp: "2026-10-17 21:04:05", Sender: "Conan", Message: "By Crom!"},
+		{Timestamp: "2026-10-17 21:04:06", Sender: "Conan", Message: "brb", Kind: kindOOC},
+		{Timestamp: "2026-10-17 21:04:07", Sender: "Valeria", Message: "Hush."},
+	}}
+	page := epubChapterPage(chapter, map[string]string{"Conan": "#c0392b"}, nil)
+	for _, want := range []string{
+		`<b><span style="color: #c0392b">Conan</span></b>: By Crom!`,
+		`(OOC) Conan: brb`,
+		`<b>Valeria</b>: Hush.`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %q:\n%s", want, page)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxChatHistory is how many recent chat lines a newly connected chat
+// stream viewer receives.
+const maxChatHistory = 100
+
+// chatFeed broadcasts received chat messages, formatted as transcript
+// lines, to the chat stream, separately from the application log. A nil
+// feed ignores messages.
+type chatFeed struct {
+	broker    *SSEBroker
+	historyMu sync.RWMutex
+	history   []sseEvent
+	nextID    int64
+}
+
+func newChatFeed() *chatFeed {
+	return &chatFeed{broker: NewSSEBroker(), nextID: firstEventID()}
+}
+
+// publish sends a chat line to every viewer and keeps it for new ones.
+func (f *chatFeed) publish(line string) {
+	if f == nil {
+		return
+	}
+	f.historyMu.Lock()
+	defer f.historyMu.Unlock()
+	f.nextID++
+	ev := sseEvent{ID: f.nextID, Data: line}
+	if len(f.history) >= maxChatHistory {
+		f.history = f.history[1:]
+	}
+	f.history = append(f.history, ev)
+	f.broker.Publish(ev)
+}
+
+// recent returns the latest chat lines after the given event ID (all of
+// them for 0), oldest first.
+func (f *chatFeed) recent(lastID int64) []sseEvent {
+	if f == nil {
+		return nil
+	}
+	f.historyMu.RLock()
+	defer f.historyMu.RUnlock()
+	return eventsAfter(f.history, lastID)
+}
+
+// stop shuts down the feed's broker.
+func (f *chatFeed) stop() {
+	if f != nil {
+		f.broker.Stop()
+	}
+}
+
+// chatLine renders an entry as an HTML transcript line for the chat
+// stream, using the configured text line template. The sender's color hue
+// is passed along as the --sender-hue CSS variable, and a character's own
+// color as --sender-color.
+func chatLine(config *AppConfig, entry LogEntry) string {
+	kind := entry.Kind
+	if kind == "" {
+		kind = kindSay
+	}
+	color := ""
+	if ch, ok := findCharacter(config, entry.Sender); ok {
+		entry.Sender = ch.Name
+		if ch.Color != "" {
+			color = "; --sender-color: " + ch.Color
+		}
+	}
+	text := strings.TrimRight(formatTextLineWith(config.TextTemplate, entry), "\r\n")
+	return fmt.Sprintf(`<div class="chat-line chat-%s" style="--sender-hue: %d%s">%s</div>`,
+		kind, senderHue(entry.Sender), color, template.HTMLEscapeString(text))
+}
+
+// publishChat sends a received message to the chat stream. OOC messages
+// kept out of the log files are kept out of the stream too.
+func (a *App) publishChat(config *AppConfig, in IncomingMessage) {
+	if a.chat == nil {
+		return
+	}
+	entry := newLogEntry(config, in.Sender, in.Message)
+	if entry.Kind == kindOOC && config.OOCFilePolicy == oocExclude {
+		return
+	}
+	entry.Scene = in.Scene
+	entry.Source = in.Source
+	if session, ok := a.CurrentSession(); ok {
+		entry.Session = session.Name
+	}
+	a.chat.publish(chatLine(config, entry))
+}
+
+// handleChatStream serves a Server-Sent Events stream of received chat
+// messages, starting with the most recent ones.
+func (a *App) handleChatStream(w http.ResponseWriter, r *http.Request) {
+	if a.chat == nil {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	streamEvents(w, r, a.chat.broker, a.chat.recent, func(ev sseEvent) (string, bool) {
+		return ev.Data, true
+	})
+}
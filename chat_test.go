@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChatLine(t *testing.T) {
+	entry := LogEntry{Timestamp: "2026-10-17 21:04:05", Sender: "Conan", Message: "<draws> his sword", Kind: kindEmote}
+	tests := []struct {
+		name string
+		cfg  AppConfig
+		want string
+	}{
+		{"default", AppConfig{}, `<div class="chat-line chat-emote" style="--sender-hue: %d">[2026-10-17 21:04:05] * Conan &lt;draws&gt; his sword</div>`},
+		{"template", AppConfig{TextTemplate: "{{.Sender}}: {{.Message}}"}, `<div class="chat-line chat-emote" style="--sender-hue: %d">Conan: &lt;draws&gt; his sword</div>`},
+		{"character", AppConfig{Characters: []Character{{Name: "Conan", Aliases: []string{"conan"}, Color: "#c0392b"}}},
+			`<div class="chat-line chat-emote" style="--sender-hue: %d; --sender-color: #c0392b">[2026-10-17 21:04:05] * Conan &lt;draws&gt; his sword</div>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := fmt.Sprintf(tt.want, senderHue("Conan"))
+			if got := chatLine(&tt.cfg, entry); got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	a := setupTestApp()
+	a.chat = newChatFeed()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop(); a.chat.stop() }()
+	a.config.OOCMarkers = []string{"(("}
+	a.config.OOCFilePolicy = oocExclude
+
+	a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "By Crom!"})
+	a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "(( brb ))"})
+	waitForDeliveries(t, a)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	a.handleChatStream(rec, httptest.NewRequest("GET", "/api/chat/stream", nil).WithContext(ctx))
+	body := rec.Body.String()
+	if !strings.Contains(body, `data: <div class="chat-line chat-say"`) || !strings.Contains(body, "Conan: By Crom!") {
+		t.Errorf("expected the chat line in the stream, got %q", body)
+	}
+	if strings.Contains(body, "brb") {
+		t.Errorf("expected the excluded OOC message to be left out, got %q", body)
+	}
+	if strings.Contains(body, "Message from") {
+		t.Errorf("expected no application log lines in the chat stream, got %q", body)
+	}
+}
+
+func TestChatFeedHistory(t *testing.T) {
+	f := newChatFeed()
+	defer f.stop()
+	for i := 0; i < maxChatHistory+5; i++ {
+		f.publish(fmt.Sprintf("line %d", i))
+	}
+	recent := f.recent(0)
+	if len(recent) != maxChatHistory || recent[0].Data != "line 5" {
+		t.Errorf("expected the latest %d lines starting at line 5, got %d starting at %q", maxChatHistory, len(recent), recent[0].Data)
+	}
+
+	var none *chatFeed
+	none.publish("ignored")
+	if none.recent(0) != nil {
+		t.Error("expected a nil feed to keep nothing")
+	}
+}
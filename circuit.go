@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBreakerAfter is how many Discord requests in a row must fail
+	// before the circuit to their target opens, when DiscordBreakerAfter
+	// is 0.
+	defaultBreakerAfter = 5
+	// defaultBreakerCooldown is how long an open circuit stays open before
+	// a request is let through to probe the target, when
+	// DiscordBreakerCooldown is 0.
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// errCircuitOpen is returned for requests to a Discord target while its
+// circuit is open.
+var errCircuitOpen = errors.New("Discord target is down, not sending while the circuit breaker is open")
+
+// discordCircuit guards every request made with discordClient.
+var discordCircuit = &circuitBreaker{}
+
+// circuitBreaker fails requests to a Discord target (a webhook or bot
+// channel) fast once requests to it have failed several times in a row,
+// so a dead webhook doesn't cost a timeout per message. After a cooldown
+// one request is let through: if it works the circuit closes, otherwise
+// it opens again. Rate limits and rejected messages don't count as
+// failures; errors, server errors and a missing or forbidden target do.
+// The zero value is ready to use.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	after    int
+	cooldown time.Duration
+	logger   *SSELogger
+	circuits map[string]*circuit
+}
+
+// circuit is the state of one target.
+type circuit struct {
+	failures int       // requests in a row that failed
+	openedAt time.Time // zero while closed
+	probing  bool      // the half-open probe is in flight
+}
+
+// configure applies the breaker settings of cfg.
+func (b *circuitBreaker) configure(cfg *AppConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.after = cfg.DiscordBreakerAfter
+	b.cooldown = time.Duration(cfg.DiscordBreakerCooldown) * time.Second
+}
+
+// setLogger sets where circuits opening and closing are logged.
+func (b *circuitBreaker) setLogger(logger *SSELogger) {
+	b.mu.Lock()
+	b.logger = logger
+	b.mu.Unlock()
+}
+
+// settings returns the breaker's threshold and cooldown. Call with b.mu
+// held.
+func (b *circuitBreaker) settings() (int, time.Duration) {
+	after, cooldown := b.after, b.cooldown
+	if after <= 0 {
+		after = defaultBreakerAfter
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return after, cooldown
+}
+
+// circuitKey identifies a request's target: its URL without the query, so
+// a webhook and its threads share a circuit.
+func circuitKey(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// allow reports whether a request to key may be sent now. Once the
+// cooldown of an open circuit is over, it lets one probe through.
+func (b *circuitBreaker) allow(key string, now time.Time) (bool, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuits[key]
+	if c == nil || c.openedAt.IsZero() {
+		return true, time.Time{}
+	}
+	_, cooldown := b.settings()
+	retryAt := c.openedAt.Add(cooldown)
+	if c.probing || now.Before(retryAt) {
+		return false, retryAt
+	}
+	c.probing = true
+	return true, time.Time{}
+}
+
+// record notes the outcome of a request to key.
+func (b *circuitBreaker) record(key string, failed bool, now time.Time) {
+	b.mu.Lock()
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+	c := b.circuits[key]
+	if c == nil {
+		if !failed {
+			b.mu.Unlock()
+			return
+		}
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	after, cooldown := b.settings()
+	logger := b.logger
+	wasOpen := !c.openedAt.IsZero()
+	c.probing = false
+	if !failed {
+		delete(b.circuits, key)
+		b.mu.Unlock()
+		if wasOpen {
+			logger.Log("info", "Discord target is responding again; circuit breaker closed")
+		}
+		return
+	}
+	c.failures++
+	failures := c.failures
+	opened := !wasOpen && failures >= after
+	if wasOpen || opened {
+		c.openedAt = now
+	}
+	b.mu.Unlock()
+	if opened {
+		logger.Log("warning", fmt.Sprintf("Discord target failed %d times in a row; circuit breaker open, posts to it fail at once and it is tried again every %v", failures, cooldown))
+	}
+}
+
+// release ends a probe of key without an outcome.
+func (b *circuitBreaker) release(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c := b.circuits[key]; c != nil {
+		c.probing = false
+	}
+}
+
+// open reports how many circuits are open.
+func (b *circuitBreaker) open() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, c := range b.circuits {
+		if !c.openedAt.IsZero() {
+			n++
+		}
+	}
+	return n
+}
+
+// circuitFailure reports whether a Discord response means its target is
+// down or gone, rather than busy or refusing one message.
+func circuitFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+// breakerTransport is an http.RoundTripper that sends requests through a
+// circuitBreaker.
+type breakerTransport struct {
+	breaker *circuitBreaker
+	base    http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := circuitKey(req.URL)
+	if ok, retryAt := t.breaker.allow(key, time.Now()); !ok {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%w (next try at %s)", errCircuitOpen, retryAt.Format("15:04:05"))
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+		// Canceled by the caller, which says nothing about the target.
+		t.breaker.release(key)
+		return resp, err
+	}
+	t.breaker.record(key, circuitFailure(resp, err), time.Now())
+	return resp, err
+}
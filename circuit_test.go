@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &circuitBreaker{after: 2, cooldown: time.Minute}
+	now := time.Now()
+	key := "https://discord.com/api/webhooks/1/abc"
+
+	b.record(key, true, now)
+	if ok, _ := b.allow(key, now); !ok {
+		t.Fatal("open after one failure")
+	}
+	b.record(key, false, now)
+	b.record(key, true, now)
+	if ok, _ := b.allow(key, now); !ok {
+		t.Fatal("a success didn't reset the failure count")
+	}
+	b.record(key, true, now)
+	if ok, retryAt := b.allow(key, now); ok || !retryAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("after two failures: allow = %v, %v", ok, retryAt)
+	}
+	if b.open() != 1 {
+		t.Errorf("open = %d", b.open())
+	}
+	if ok, _ := b.allow("https://discord.com/api/webhooks/2/def", now); !ok {
+		t.Error("another target was cut off")
+	}
+
+	later := now.Add(time.Minute)
+	if ok, _ := b.allow(key, later); !ok {
+		t.Fatal("no probe after the cooldown")
+	}
+	if ok, _ := b.allow(key, later); ok {
+		t.Fatal("two probes at once")
+	}
+	b.record(key, true, later)
+	if ok, _ := b.allow(key, later.Add(time.Second)); ok {
+		t.Fatal("a failed probe didn't reopen the circuit")
+	}
+
+	b.allow(key, later.Add(time.Minute))
+	b.release(key)
+	if ok, _ := b.allow(key, later.Add(time.Minute)); !ok {
+		t.Fatal("a canceled probe blocked the next one")
+	}
+	b.record(key, false, later.Add(time.Minute))
+	if b.open() != 0 {
+		t.Errorf("open after recovery = %d", b.open())
+	}
+}
+
+func TestCircuitFailure(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+		want   bool
+	}{
+		{http.StatusNoContent, nil, false},
+		{http.StatusBadRequest, nil, false},
+		{http.StatusTooManyRequests, nil, false},
+		{http.StatusNotFound, nil, true},
+		{http.StatusUnauthorized, nil, true},
+		{http.StatusBadGateway, nil, true},
+		{0, errors.New("dial tcp: connection refused"), true},
+	}
+	for _, tt := range tests {
+		var resp *http.Response
+		if tt.err == nil {
+			resp = &http.Response{StatusCode: tt.status}
+		}
+		if got := circuitFailure(resp, tt.err); got != tt.want {
+			t.Errorf("circuitFailure(%d, %v) = %v, want %v", tt.status, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBreakerTransport(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &breakerTransport{breaker: &circuitBreaker{after: 3}, base: http.DefaultTransport}}
+	for i := 0; i < 5; i++ {
+		resp, err := client.Post(srv.URL+"?wait=true", "application/json", strings.NewReader("{}"))
+		if i < 3 {
+			if err != nil {
+				t.Fatalf("request %d: %v", i, err)
+			}
+			resp.Body.Close()
+		} else if !errors.Is(err, errCircuitOpen) {
+			t.Fatalf("request %d: err = %v, want the circuit open", i, err)
+		}
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("server got %d requests, want 3", n)
+	}
+}
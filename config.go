@@ -2,15 +2,30 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // configPathOverride allows overriding the default config file location
 // (used for Docker volume mounts via --config flag).
 var configPathOverride string
 
+// configOverrides are the command-line flags the app was started with. They
+// are applied, after the environment, to every config the app runs with,
+// and kept out of the config file (see stripOverrides).
+var configOverrides ConfigOverrides
+
 // ServerConfig holds the webhook URL and username for Discord notifications.
 type ServerConfig struct {
 	WebhookURL string `json:"webhookURL"`
@@ -27,7 +42,723 @@ type AppConfig struct {
 	EnableLocalSave bool   `json:"enableLocalSave"`
 	ListenAddr      string `json:"listenAddr"`
 	FileFormat      string `json:"fileFormat"`
-	DebugMode       bool   `json:"debugMode"`
+	LogLevel        string `json:"logLevel,omitempty"`
+	EnableForward   bool   `json:"enableForward"`
+	ForwardURL      string `json:"forwardURL"`
+
+	// DebugMode is the switch LogLevel replaced. It is only read from old
+	// config files and turned into LogLevel "debug" on load.
+	DebugMode bool `json:"debugMode,omitempty"`
+
+	// DiscordMode is "webhook" (default) or "bot". Bot mode posts to
+	// BotChannelID, and separated OOC messages to BotOOCChannelID, as the
+	// bot, which can create threads in ordinary channels, edit its posts
+	// and read reactions.
+	DiscordMode     string `json:"discordMode,omitempty"`
+	BotToken        string `json:"botToken,omitempty"`
+	BotChannelID    string `json:"botChannelID,omitempty"`
+	BotOOCChannelID string `json:"botOOCChannelID,omitempty"`
+
+	// VerifyWebhook asks Discord whether the webhook (or the bot's
+	// channel) exists when the config is saved from the web UI, and shows
+	// where it posts. See webhook_check.go.
+	VerifyWebhook bool `json:"verifyWebhook,omitempty"`
+
+	// EnableRelay forwards messages people post in RelayChannelID
+	// (default BotChannelID) to RelayURL, in the same sender/message
+	// format the game sends, or through RCON when RelayURL is empty, so
+	// Discord can talk back to the game. It needs bot mode.
+	EnableRelay    bool   `json:"enableRelay,omitempty"`
+	RelayChannelID string `json:"relayChannelID,omitempty"`
+	RelayURL       string `json:"relayURL,omitempty"`
+
+	// EnableTunnel exposes the ingestion server through a tunnel while it
+	// runs, for game servers that can't reach this machine directly.
+	// TunnelProvider is "ngrok" (default, using TunnelAuthToken),
+	// "cloudflared" or "custom", which runs TunnelCommand. See tunnel.go.
+	EnableTunnel    bool   `json:"enableTunnel,omitempty"`
+	TunnelProvider  string `json:"tunnelProvider,omitempty"`
+	TunnelAuthToken string `json:"tunnelAuthToken,omitempty"`
+	TunnelCommand   string `json:"tunnelCommand,omitempty"`
+
+	// EnableMDNS advertises the ingestion server on the LAN as
+	// MDNSHostname.local (default rp-chat-logger.local) and as an
+	// _rp-chat-logger._tcp service. EnableUPnP asks the router to forward
+	// the ingestion port while the server runs. Both need a ListenAddr
+	// other than localhost. See mdns.go and upnp.go.
+	EnableMDNS   bool   `json:"enableMDNS,omitempty"`
+	MDNSHostname string `json:"mdnsHostname,omitempty"`
+	EnableUPnP   bool   `json:"enableUPnP,omitempty"`
+
+	// EnableLivePage serves a read-only transcript of incoming chat at
+	// /live on the ingestion server, for players who aren't on Discord.
+	// Anyone who can reach the server can read it. See live.go.
+	EnableLivePage bool `json:"enableLivePage,omitempty"`
+
+	// RequireIngestToken makes /message accept only requests carrying an
+	// API token with the ingest scope; RequireAPIToken does the same for
+	// the /api/v1 JSON API (read scope for GET, admin otherwise) and puts
+	// the web UI behind a sign-in with an admin token. Tokens are managed
+	// in the web UI. See tokens.go.
+	RequireIngestToken bool `json:"requireIngestToken,omitempty"`
+	RequireAPIToken    bool `json:"requireAPIToken,omitempty"`
+
+	// CORSOrigins are the web origins (e.g. https://overlay.example.com,
+	// or * for any) whose pages may call /message from the browser. See
+	// cors.go.
+	CORSOrigins []string `json:"corsOrigins,omitempty"`
+
+	// IngestAllowIPs, when set, are the only addresses and CIDR ranges
+	// that may call /message; IngestDenyIPs may never call it.
+	// WebUIAllowIPs limits the web UI the same way, except that the local
+	// machine is always let in. See ipfilter.go.
+	IngestAllowIPs []string `json:"ingestAllowIPs,omitempty"`
+	IngestDenyIPs  []string `json:"ingestDenyIPs,omitempty"`
+	WebUIAllowIPs  []string `json:"webUIAllowIPs,omitempty"`
+
+	// EnableRCON connects to the game server's RCON port for
+	// announcements. RCONCommand is the command run for each announcement,
+	// with {message} replaced (default "broadcast {message}");
+	// RCONStartMessage and RCONStopMessage are announced when the
+	// ingestion server starts and stops, if set.
+	EnableRCON       bool   `json:"enableRCON,omitempty"`
+	RCONAddr         string `json:"rconAddr,omitempty"`
+	RCONPassword     string `json:"rconPassword,omitempty"`
+	RCONCommand      string `json:"rconCommand,omitempty"`
+	RCONStartMessage string `json:"rconStartMessage,omitempty"`
+	RCONStopMessage  string `json:"rconStopMessage,omitempty"`
+
+	// SceneThreads posts each scene into its own Discord thread. The
+	// scene→thread ID map is persisted so threads are reused across restarts.
+	SceneThreads   bool              `json:"sceneThreads"`
+	SceneThreadIDs map[string]string `json:"sceneThreadIDs,omitempty"`
+
+	// SenderAsAuthor posts each message under the sender's name, using
+	// the optional per-character avatar URL from Avatars.
+	SenderAsAuthor bool              `json:"senderAsAuthor"`
+	Avatars        map[string]string `json:"avatars,omitempty"`
+
+	// Characters give senders a display name, aliases, avatar and color,
+	// managed on the Characters page. See characters.go.
+	Characters []Character `json:"characters,omitempty"`
+
+	// DiscordAttachAfter uploads a message as a .txt attachment instead of
+	// posting it in chunks when it would take more than this many posts
+	// (0 means never). DiscordSessionTranscript attaches the transcript of
+	// a session to Discord when it ends.
+	DiscordAttachAfter       int  `json:"discordAttachAfter,omitempty"`
+	DiscordSessionTranscript bool `json:"discordSessionTranscript,omitempty"`
+
+	// DiscordFailover are webhook URLs, or channel IDs in bot mode, that
+	// take the messages for a Discord target once sends to it have failed
+	// DiscordFailoverAfter times in a row (0 means 3), until it works
+	// again. See discordFailover.
+	DiscordFailover      []string `json:"discordFailover,omitempty"`
+	DiscordFailoverAfter int      `json:"discordFailoverAfter,omitempty"`
+
+	// DiscordBreakerAfter is how many requests in a row to a Discord
+	// target must fail before posts to it fail at once (0 means 5), and
+	// DiscordBreakerCooldown how many seconds until it is tried again (0
+	// means 30). See circuitBreaker.
+	DiscordBreakerAfter    int `json:"discordBreakerAfter,omitempty"`
+	DiscordBreakerCooldown int `json:"discordBreakerCooldown,omitempty"`
+
+	// OfflineBuffer buffers Discord messages to disk when a send fails and
+	// the internet is unreachable too, and posts them once it is back. See
+	// offlineBuffer.
+	OfflineBuffer bool `json:"offlineBuffer,omitempty"`
+
+	// HTTPTimeout is how many seconds a Discord or forward request may take
+	// (0 means 10). HTTPProxy sends them through a proxy (http, https or
+	// socks5; without it HTTPS_PROXY is used), and HTTPCABundle is a PEM
+	// file of certificate authorities to trust besides the system's, for
+	// proxies that intercept TLS. See outboundTransport.
+	HTTPTimeout  int    `json:"httpTimeout,omitempty"`
+	HTTPProxy    string `json:"httpProxy,omitempty"`
+	HTTPCABundle string `json:"httpCABundle,omitempty"`
+
+	// SummaryURL is an OpenAI-compatible API (its base URL, such as
+	// https://api.openai.com/v1, or its chat completions endpoint) that
+	// writes narrative summaries of session transcripts with SummaryModel.
+	// SummaryPrompt is the request, a template of summaryPromptData; empty
+	// uses defaultSummaryPrompt. Sessions are summarized on demand, and
+	// when they end with SummaryOnSessionEnd. SummaryDiscord posts the
+	// summary to Discord; SummaryFile saves it next to the transcript.
+	SummaryURL          string `json:"summaryURL,omitempty"`
+	SummaryAPIKey       string `json:"summaryAPIKey,omitempty"`
+	SummaryModel        string `json:"summaryModel,omitempty"`
+	SummaryPrompt       string `json:"summaryPrompt,omitempty"`
+	SummaryOnSessionEnd bool   `json:"summaryOnSessionEnd,omitempty"`
+	SummaryDiscord      bool   `json:"summaryDiscord,omitempty"`
+	SummaryFile         bool   `json:"summaryFile,omitempty"`
+
+	// DiscordMarkdown is escape (the default), which shows player text in
+	// Discord as typed and keeps it from pinging anyone, or passthrough,
+	// which lets its markdown format and its mentions ping.
+	DiscordMarkdown string `json:"discordMarkdown,omitempty"`
+
+	// DiscordMentions is none (the default), so relayed messages never
+	// ping, or users, which lets them ping the users they mention. Only
+	// MentionAlerts ping @everyone, @here or roles: when a message contains
+	// a keyword, its mention (@everyone, @here, <@&role> or <@user>) is
+	// added to the post and allowed to ping.
+	DiscordMentions string            `json:"discordMentions,omitempty"`
+	MentionAlerts   map[string]string `json:"mentionAlerts,omitempty"`
+
+	// TranslateProvider is "deepl" or "libretranslate" to translate
+	// messages into TranslateTarget (a language code such as "en") through
+	// TranslateURL, the provider's public API when empty, with
+	// TranslateAPIKey. TranslateDiscord adds the translation under each
+	// Discord post; TranslateFile writes a translated copy of the logs to
+	// the "translated" subfolder. See translation.
+	TranslateProvider string `json:"translateProvider,omitempty"`
+	TranslateURL      string `json:"translateURL,omitempty"`
+	TranslateAPIKey   string `json:"translateAPIKey,omitempty"`
+	TranslateTarget   string `json:"translateTarget,omitempty"`
+	TranslateDiscord  bool   `json:"translateDiscord,omitempty"`
+	TranslateFile     bool   `json:"translateFile,omitempty"`
+
+	// LanguageRoutes posts messages detectLanguage finds to be in a
+	// language (en, es, pt, fr, de or it) to that language's webhook URL,
+	// or channel ID in bot mode, instead of the usual one.
+	LanguageRoutes map[string]string `json:"languageRoutes,omitempty"`
+
+	// SenderRoutes also send a sender's messages, by sender or character
+	// name, to another webhook or log folder; see SenderRoute.
+	SenderRoutes map[string]SenderRoute `json:"senderRoutes,omitempty"`
+
+	// Transforms are the steps every message goes through, in order, before
+	// it is logged or sent anywhere; see parseTransforms.
+	Transforms []string `json:"transforms,omitempty"`
+	// RichText is what happens to rich-text tags such as <color=red>:
+	// "keep" (default), "strip" or "markdown"; see cleanRichText.
+	RichText string `json:"richText,omitempty"`
+
+	// EmoteDetection formats lines starting with one of EmotePrefixes
+	// (default "*" and "/me") as actions rather than speech.
+	EmoteDetection bool     `json:"emoteDetection"`
+	EmotePrefixes  []string `json:"emotePrefixes,omitempty"`
+
+	// OOC policies are "include", "exclude" or "separate". Separate sends
+	// OOC messages to OOCWebhookURL on Discord and to an "ooc" subfolder
+	// for file logging.
+	OOCMarkers       []string `json:"oocMarkers,omitempty"`
+	OOCDiscordPolicy string   `json:"oocDiscordPolicy,omitempty"`
+	OOCWebhookURL    string   `json:"oocWebhookURL,omitempty"`
+	OOCFilePolicy    string   `json:"oocFilePolicy,omitempty"`
+
+	// IgnoredSenders, such as server announcements, bots or admin
+	// commands, are never posted to Discord or forwarded; with
+	// IgnoredSenderPolicy "local" their messages are still logged, with
+	// "drop" (the default) they are dropped. See ignore.go.
+	IgnoredSenders      []string `json:"ignoredSenders,omitempty"`
+	IgnoredSenderPolicy string   `json:"ignoredSenderPolicy,omitempty"`
+
+	// QuietHours ("02:00-08:00", local time) pause posting to Discord
+	// every day; messages are still logged and forwarded. With
+	// QuietHoursPolicy "queue" the posts are made once quiet hours end,
+	// with "skip" (the default) they are not. See quiet_hours.go.
+	QuietHours       string `json:"quietHours,omitempty"`
+	QuietHoursPolicy string `json:"quietHoursPolicy,omitempty"`
+
+	// EnableDigest posts a summary of the previous 24 hours to Discord
+	// every day at DigestTime ("HH:MM", local time). DigestWebhookURL
+	// defaults to the main webhook when empty.
+	EnableDigest     bool   `json:"enableDigest"`
+	DigestTime       string `json:"digestTime,omitempty"`
+	DigestWebhookURL string `json:"digestWebhookURL,omitempty"`
+	LastDigest       string `json:"lastDigest,omitempty"`
+
+	// EnableEmailDigest emails the same digest to EmailTo through the SMTP
+	// server, optionally with the transcripts attached. SMTPSecurity is
+	// "starttls" (default), "tls" or "none".
+	EnableEmailDigest      bool     `json:"enableEmailDigest,omitempty"`
+	EmailTo                []string `json:"emailTo,omitempty"`
+	EmailAttachTranscripts bool     `json:"emailAttachTranscripts,omitempty"`
+	SMTPHost               string   `json:"smtpHost,omitempty"`
+	SMTPPort               int      `json:"smtpPort,omitempty"`
+	SMTPSecurity           string   `json:"smtpSecurity,omitempty"`
+	SMTPUser               string   `json:"smtpUser,omitempty"`
+	SMTPPassword           string   `json:"smtpPassword,omitempty"`
+	SMTPFrom               string   `json:"smtpFrom,omitempty"`
+
+	// Sources are named message sources (e.g. one per game server), each
+	// with an optional extra listen address and its own Discord webhook.
+	Sources map[string]SourceProfile `json:"sources,omitempty"`
+
+	// UDPListenAddr, when set, also accepts chat lines over UDP (plain or
+	// syslog-framed). UDPPattern is the regex that extracts the sender and
+	// message from each line.
+	UDPListenAddr string `json:"udpListenAddr,omitempty"`
+	UDPPattern    string `json:"udpPattern,omitempty"`
+
+	// TailPath, when set, follows the game's own log file (Conan Exiles'
+	// ConanSandbox.log) and picks out chat lines matching TailPattern, so
+	// no HTTP-calling mod is needed.
+	TailPath    string `json:"tailPath,omitempty"`
+	TailPattern string `json:"tailPattern,omitempty"`
+
+	// InputPreset selects how ingestion requests (and, by default, game
+	// log lines) are read: "conan" (default), "ark" or "generic".
+	// InputFields names the sender, message and scene fields for the
+	// generic preset.
+	InputPreset string   `json:"inputPreset,omitempty"`
+	InputFields []string `json:"inputFields,omitempty"`
+
+	// RateLimit and SenderRateLimit cap messages per minute from one
+	// client IP and one sender (0 disables), after a burst of
+	// RateLimitBurst. RateLimitPolicy is "drop" (default) or "delay".
+	RateLimit       int    `json:"rateLimit,omitempty"`
+	SenderRateLimit int    `json:"senderRateLimit,omitempty"`
+	RateLimitBurst  int    `json:"rateLimitBurst,omitempty"`
+	RateLimitPolicy string `json:"rateLimitPolicy,omitempty"`
+
+	// MaxMessageLength and MaxSenderLength limit text length in
+	// characters (0 uses the defaults). LengthPolicy is "truncate"
+	// (default) or "reject".
+	MaxMessageLength int    `json:"maxMessageLength,omitempty"`
+	MaxSenderLength  int    `json:"maxSenderLength,omitempty"`
+	LengthPolicy     string `json:"lengthPolicy,omitempty"`
+
+	// AppLogPath is the application log file (empty means app.log next to
+	// the config file, "off" disables it). AppLogFormat is "text" or "json".
+	AppLogPath   string `json:"appLogPath,omitempty"`
+	AppLogFormat string `json:"appLogFormat,omitempty"`
+
+	// EnableAccessLog writes a line per HTTP request to AccessLogPath
+	// (empty means access.log next to the config file), rotated like the
+	// application log. See accesslog.go.
+	EnableAccessLog bool   `json:"enableAccessLog,omitempty"`
+	AccessLogPath   string `json:"accessLogPath,omitempty"`
+
+	// FilenameTemplate names the log files; see renderFilename. Empty
+	// means defaultFilenameTemplate.
+	FilenameTemplate string `json:"filenameTemplate,omitempty"`
+
+	// DiscordTemplate and TextTemplate are Go templates for a message as
+	// posted to Discord and as written to txt and docx logs; see lineData.
+	// Empty means defaultDiscordTemplate and formatTextLine.
+	DiscordTemplate string `json:"discordTemplate,omitempty"`
+	TextTemplate    string `json:"textTemplate,omitempty"`
+
+	// CSVDelimiter is "comma" (default), "semicolon" or "tab", and CSVBOM
+	// starts CSV logs with a UTF-8 byte order mark; both only apply to new
+	// files. Excel in many European locales expects semicolons.
+	CSVDelimiter string `json:"csvDelimiter,omitempty"`
+	CSVBOM       bool   `json:"csvBom,omitempty"`
+
+	// FolderLayout is "flat" (default), "month" or "scene"; see logSubdir.
+	FolderLayout string `json:"folderLayout,omitempty"`
+
+	// FlushInterval keeps txt, csv and docx log files open and writes them out
+	// every this many seconds instead of opening them for every message
+	// (0, the default, writes each message right away).
+	FlushInterval int `json:"flushInterval,omitempty"`
+
+	// RetentionDays and RetentionMaxSizeMB limit how many days and how
+	// much disk the daily log files may use (0 means no limit).
+	// RetentionAction is "delete" (default) or "archive"; see pruneLogs.
+	RetentionDays      int    `json:"retentionDays,omitempty"`
+	RetentionMaxSizeMB int    `json:"retentionMaxSizeMB,omitempty"`
+	RetentionAction    string `json:"retentionAction,omitempty"`
+
+	// EnableBackup zips the config file and log folder on BackupSchedule
+	// (a cron expression) into BackupPath, keeping the newest BackupKeep,
+	// and uploads each zip to BackupUploadURL if set. See backup.go.
+	EnableBackup    bool   `json:"enableBackup,omitempty"`
+	BackupSchedule  string `json:"backupSchedule,omitempty"`
+	BackupPath      string `json:"backupPath,omitempty"`
+	BackupKeep      int    `json:"backupKeep,omitempty"`
+	BackupUploadURL string `json:"backupUploadURL,omitempty"`
+
+	// EnableS3 uploads each finished day's log files, and backups, to an
+	// S3-compatible bucket. S3PathStyle addresses the bucket as
+	// endpoint/bucket instead of bucket.endpoint (MinIO and similar).
+	EnableS3    bool   `json:"enableS3,omitempty"`
+	S3Endpoint  string `json:"s3Endpoint,omitempty"`
+	S3Region    string `json:"s3Region,omitempty"`
+	S3Bucket    string `json:"s3Bucket,omitempty"`
+	S3Prefix    string `json:"s3Prefix,omitempty"`
+	S3AccessKey string `json:"s3AccessKey,omitempty"`
+	S3SecretKey string `json:"s3SecretKey,omitempty"`
+	S3PathStyle bool   `json:"s3PathStyle,omitempty"`
+
+	// EnableWebDAV uploads the same files into a WebDAV folder.
+	EnableWebDAV   bool   `json:"enableWebDAV,omitempty"`
+	WebDAVURL      string `json:"webdavURL,omitempty"`
+	WebDAVUser     string `json:"webdavUser,omitempty"`
+	WebDAVPassword string `json:"webdavPassword,omitempty"`
+
+	// EnableGDrive uploads them into a Google Drive folder using a service
+	// account key file.
+	EnableGDrive      bool   `json:"enableGDrive,omitempty"`
+	GDriveCredentials string `json:"gdriveCredentials,omitempty"`
+	GDriveFolderID    string `json:"gdriveFolderID,omitempty"`
+
+	// UploadDeleteLocal removes log files once every upload sink has them.
+	// LastUpload is the last day uploaded, kept so restarts don't upload a
+	// day twice.
+	UploadDeleteLocal bool   `json:"uploadDeleteLocal,omitempty"`
+	LastUpload        string `json:"lastUpload,omitempty"`
+
+	// PersistReceipts saves the delivery receipt table on exit and loads
+	// it on start.
+	PersistReceipts bool `json:"persistReceipts,omitempty"`
+
+	// DeliveryQueueSize bounds the deliveries waiting to reach Discord,
+	// the log files and the forward target (0 means
+	// defaultDeliveryQueueSize). When it is full, DeliveryDropPolicy drops
+	// the oldest waiting delivery (the default) or the newest.
+	DeliveryQueueSize  int    `json:"deliveryQueueSize,omitempty"`
+	DeliveryDropPolicy string `json:"deliveryDropPolicy,omitempty"`
+
+	// LogHistorySize and FailureHistorySize are how many log lines and
+	// failures are kept for web UI pages opened later (0 means 500 and
+	// 100). PersistLogHistory saves the log lines on exit and loads them
+	// on start.
+	LogHistorySize     int  `json:"logHistorySize,omitempty"`
+	FailureHistorySize int  `json:"failureHistorySize,omitempty"`
+	PersistLogHistory  bool `json:"persistLogHistory,omitempty"`
+
+	// Profile is the name of the active config profile. Saving the config
+	// also updates that profile's file. See profiles.go.
+	Profile string `json:"profile,omitempty"`
+
+	// SecretStorage is how secrets are written to config files: plain
+	// (the default), keychain or passphrase. See secrets.go.
+	SecretStorage string `json:"secretStorage,omitempty"`
+
+	// UpdateChannel is stable (the default) or beta, which also offers
+	// prereleases. Updates are checked at startup and every
+	// UpdateCheckHours (0 means 24) unless DisableUpdateCheck is set.
+	// AutoUpdate installs them once the server has been idle for a while.
+	UpdateChannel      string `json:"updateChannel,omitempty"`
+	UpdateCheckHours   int    `json:"updateCheckHours,omitempty"`
+	DisableUpdateCheck bool   `json:"disableUpdateCheck,omitempty"`
+	AutoUpdate         bool   `json:"autoUpdate,omitempty"`
+}
+
+// DebugEnabled reports whether LogLevel includes debug lines, which also
+// shows the live log and failed message sections in the web UI.
+func (c AppConfig) DebugEnabled() bool {
+	level, _ := parseLogLevel(c.LogLevel)
+	return level <= slog.LevelDebug
+}
+
+// validate checks that at least one output is enabled and that every
+// enabled output has the settings it needs. The returned error message is
+// shown directly in the web UI.
+func (c *AppConfig) validate() error {
+	if !c.EnableDiscord && !c.EnableLocalSave && !c.EnableForward {
+		return fmt.Errorf("Enable at least one output option")
+	}
+	switch c.DiscordMode {
+	case "", discordModeWebhook:
+		if c.EnableDiscord && c.WebhookURL == "" {
+			return fmt.Errorf("Discord webhook URL required")
+		}
+	case discordModeBot:
+		if c.EnableDiscord && (c.BotToken == "" || c.BotChannelID == "") {
+			return fmt.Errorf("Discord bot token and channel ID required")
+		}
+	default:
+		return fmt.Errorf("Discord mode must be webhook or bot")
+	}
+	if c.EnableLocalSave && c.Path == "" {
+		return fmt.Errorf("File path required for local save")
+	}
+	if c.EnableForward && c.ForwardURL == "" {
+		return fmt.Errorf("Forward URL required")
+	}
+	if c.EnableDiscord && c.OOCDiscordPolicy == oocSeparate && oocDiscordTarget(c) == "" {
+		if c.DiscordMode == discordModeBot {
+			return fmt.Errorf("OOC channel ID required")
+		}
+		return fmt.Errorf("OOC webhook URL required")
+	}
+	if c.EnableTunnel {
+		switch tunnelProvider(c) {
+		case tunnelNgrok, tunnelCloudflared:
+		case tunnelCustom:
+			if strings.TrimSpace(c.TunnelCommand) == "" {
+				return fmt.Errorf("Tunnel command required")
+			}
+		default:
+			return fmt.Errorf("Tunnel provider must be ngrok, cloudflared or custom")
+		}
+	}
+	if c.EnableMDNS || c.EnableUPnP {
+		if loopbackListenAddr(c.ListenAddr) {
+			return fmt.Errorf("LAN discovery needs a listen address other than localhost, e.g. 0.0.0.0:3000")
+		}
+		if c.EnableMDNS && !validMDNSHostname(mdnsHostname(c)) {
+			return fmt.Errorf("mDNS hostname may only contain letters, digits and hyphens")
+		}
+	}
+	if c.EnableRCON {
+		if _, _, err := net.SplitHostPort(c.RCONAddr); err != nil {
+			return fmt.Errorf("RCON address must be host:port")
+		}
+		if c.RCONCommand != "" && !strings.Contains(c.RCONCommand, "{message}") {
+			return fmt.Errorf("RCON command must contain {message}")
+		}
+	}
+	if c.EnableRelay {
+		if c.DiscordMode != discordModeBot || c.BotToken == "" {
+			return fmt.Errorf("Discord relay requires bot mode")
+		}
+		if relayChannel(c) == "" {
+			return fmt.Errorf("Relay channel ID required")
+		}
+		if c.RelayURL == "" && !c.EnableRCON {
+			return fmt.Errorf("Relay URL or RCON required")
+		}
+		if u, err := url.Parse(c.RelayURL); c.RelayURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+			return fmt.Errorf("Relay URL must be an http or https URL")
+		}
+	}
+	if c.EnableDigest {
+		if _, err := time.Parse("15:04", c.DigestTime); err != nil {
+			return fmt.Errorf("Digest time must be in HH:MM format")
+		}
+		if c.DigestWebhookURL == "" && discordTarget(c) == "" {
+			return fmt.Errorf("Discord webhook URL required for daily digest")
+		}
+		if !c.EnableLocalSave {
+			return fmt.Errorf("Daily digest requires file logging")
+		}
+	}
+	if c.EnableEmailDigest {
+		if c.DigestTime != "" {
+			if _, err := time.Parse("15:04", c.DigestTime); err != nil {
+				return fmt.Errorf("Digest time must be in HH:MM format")
+			}
+		}
+		if c.SMTPHost == "" || c.SMTPFrom == "" {
+			return fmt.Errorf("SMTP server and sender address required for email digest")
+		}
+		if len(c.EmailTo) == 0 {
+			return fmt.Errorf("At least one email recipient required")
+		}
+		if !c.EnableLocalSave {
+			return fmt.Errorf("Daily digest requires file logging")
+		}
+	}
+	if c.SMTPPort < 0 || c.SMTPPort > 65535 {
+		return fmt.Errorf("SMTP port must be between 1 and 65535")
+	}
+	switch c.SMTPSecurity {
+	case "", smtpStartTLS, smtpTLS, smtpNone:
+	default:
+		return fmt.Errorf("Unknown SMTP security %q", c.SMTPSecurity)
+	}
+	if c.UDPListenAddr != "" {
+		if _, err := compileLinePattern(c.UDPPattern, defaultUDPPattern); err != nil {
+			return err
+		}
+	}
+	if c.RateLimit < 0 || c.SenderRateLimit < 0 || c.RateLimitBurst < 0 {
+		return fmt.Errorf("Rate limits cannot be negative")
+	}
+	if c.MaxMessageLength < 0 || c.MaxSenderLength < 0 {
+		return fmt.Errorf("Length limits cannot be negative")
+	}
+	if c.DiscordFailoverAfter < 0 || c.DiscordBreakerAfter < 0 || c.DiscordBreakerCooldown < 0 {
+		return fmt.Errorf("Failover and circuit breaker settings cannot be negative")
+	}
+	if c.HTTPTimeout < 0 {
+		return fmt.Errorf("HTTP timeout cannot be negative")
+	}
+	if _, err := newHTTPTransport(c); err != nil {
+		return err
+	}
+	if err := checkCharacters(c.Characters); err != nil {
+		return err
+	}
+	if err := checkIgnoredSenderPolicy(c.IgnoredSenderPolicy); err != nil {
+		return err
+	}
+	if err := checkQuietHours(c); err != nil {
+		return err
+	}
+	if err := validateFilenameTemplate(c.FilenameTemplate); err != nil {
+		return err
+	}
+	if err := validateLineTemplate("Discord template", c.DiscordTemplate); err != nil {
+		return err
+	}
+	if err := validateLineTemplate("Text line template", c.TextTemplate); err != nil {
+		return err
+	}
+	if _, ok := parseLogLevel(c.LogLevel); !ok {
+		return fmt.Errorf("Unknown log level %q", c.LogLevel)
+	}
+	switch c.AppLogFormat {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("Unknown app log format %q", c.AppLogFormat)
+	}
+	if _, err := parseTransforms(c.Transforms); err != nil {
+		return err
+	}
+	switch c.RichText {
+	case "", richTextKeep, richTextStrip, richTextMarkdown:
+	default:
+		return fmt.Errorf("Unknown rich text handling %q", c.RichText)
+	}
+	switch c.CSVDelimiter {
+	case "", csvDelimiterComma, csvDelimiterSemicolon, csvDelimiterTab:
+	default:
+		return fmt.Errorf("Unknown CSV delimiter %q", c.CSVDelimiter)
+	}
+	switch c.FolderLayout {
+	case "", folderFlat, folderMonth, folderScene:
+	default:
+		return fmt.Errorf("Unknown folder layout %q", c.FolderLayout)
+	}
+	if c.FlushInterval < 0 || c.FlushInterval > maxFlushInterval {
+		return fmt.Errorf("Flush interval must be 0 to %d seconds", maxFlushInterval)
+	}
+	if c.RetentionDays < 0 || c.RetentionMaxSizeMB < 0 {
+		return fmt.Errorf("Retention limits cannot be negative")
+	}
+	if c.LogHistorySize < 0 || c.LogHistorySize > maxHistorySize || c.FailureHistorySize < 0 || c.FailureHistorySize > maxHistorySize {
+		return fmt.Errorf("History sizes must be between 0 and %d", maxHistorySize)
+	}
+	if c.BackupSchedule != "" {
+		if _, err := parseCron(c.BackupSchedule); err != nil {
+			return fmt.Errorf("Invalid backup schedule: %v", err)
+		}
+	}
+	if c.BackupKeep < 0 {
+		return fmt.Errorf("Backups to keep cannot be negative")
+	}
+	if c.BackupUploadURL != "" {
+		if u, err := url.Parse(c.BackupUploadURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("Backup upload URL must be an http or https URL")
+		}
+	}
+	if c.EnableS3 {
+		if u, err := url.Parse(c.S3Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("S3 endpoint must be an http or https URL")
+		}
+		if c.S3Bucket == "" || c.S3AccessKey == "" || c.S3SecretKey == "" {
+			return fmt.Errorf("S3 bucket, access key and secret key required")
+		}
+	}
+	if c.EnableWebDAV {
+		if u, err := url.Parse(c.WebDAVURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("WebDAV URL must be an http or https URL")
+		}
+	}
+	if c.EnableGDrive && (c.GDriveCredentials == "" || c.GDriveFolderID == "") {
+		return fmt.Errorf("Google Drive credentials file and folder ID required")
+	}
+	switch c.RetentionAction {
+	case "", retentionDelete, retentionArchive:
+	default:
+		return fmt.Errorf("Unknown retention action %q", c.RetentionAction)
+	}
+	switch c.LengthPolicy {
+	case "", lengthTruncate, lengthReject:
+	default:
+		return fmt.Errorf("Unknown length policy %q", c.LengthPolicy)
+	}
+	if c.DeliveryQueueSize < 0 || c.DeliveryQueueSize > maxDeliveryQueueSize {
+		return fmt.Errorf("Delivery queue size must be 0 to %d", maxDeliveryQueueSize)
+	}
+	switch c.DeliveryDropPolicy {
+	case "", deliveryDropOldest, deliveryDropNewest:
+	default:
+		return fmt.Errorf("Delivery drop policy must be oldest or newest")
+	}
+	switch c.RateLimitPolicy {
+	case "", rateLimitDrop, rateLimitDelay:
+	default:
+		return fmt.Errorf("Unknown rate limit policy %q", c.RateLimitPolicy)
+	}
+	if _, err := presetFor(c); err != nil {
+		return err
+	}
+	for _, list := range [][]string{c.IngestAllowIPs, c.IngestDenyIPs, c.WebUIAllowIPs} {
+		if _, err := parsePrefixes(list); err != nil {
+			return err
+		}
+	}
+	for _, origin := range c.CORSOrigins {
+		if !validCORSOrigin(origin) {
+			return fmt.Errorf("CORS origin %q must be * or look like https://example.com", origin)
+		}
+	}
+	if c.TailPath != "" {
+		if _, err := compileLinePattern(c.TailPattern, tailPatternFor(c)); err != nil {
+			return err
+		}
+	}
+	if err := checkSecretStorage(c.SecretStorage); err != nil {
+		return err
+	}
+	if c.DiscordAttachAfter < 0 || c.DiscordAttachAfter > maxDiscordAttachAfter {
+		return fmt.Errorf("Attach after must be 0 to %d posts", maxDiscordAttachAfter)
+	}
+	switch c.DiscordMarkdown {
+	case "", discordMarkdownEscape, discordMarkdownPassthrough:
+	default:
+		return fmt.Errorf("Discord markdown must be escape or passthrough")
+	}
+	switch c.DiscordMentions {
+	case "", discordMentionsNone, discordMentionsUsers:
+	default:
+		return fmt.Errorf("Discord mentions must be none or users")
+	}
+	if err := checkMentionAlerts(c.MentionAlerts); err != nil {
+		return err
+	}
+	if err := checkLanguageRoutes(c.LanguageRoutes); err != nil {
+		return err
+	}
+	if err := checkSenderRoutes(c.SenderRoutes); err != nil {
+		return err
+	}
+	switch c.TranslateProvider {
+	case "":
+	case translateDeepL, translateLibre:
+		if c.TranslateTarget == "" {
+			return fmt.Errorf("Translation needs a target language")
+		}
+		if c.TranslateProvider == translateDeepL && c.TranslateAPIKey == "" {
+			return fmt.Errorf("DeepL translation needs an API key")
+		}
+		if c.TranslateURL != "" {
+			if u, err := url.Parse(c.TranslateURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("Translation URL must be an http or https URL")
+			}
+		}
+	default:
+		return fmt.Errorf("Unknown translation provider %q", c.TranslateProvider)
+	}
+	if c.SummaryURL != "" {
+		if u, err := url.Parse(c.SummaryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Summary API URL must be an http or https URL")
+		}
+		if c.SummaryModel == "" {
+			return fmt.Errorf("Summaries need a model name")
+		}
+	} else if c.SummaryOnSessionEnd {
+		return fmt.Errorf("Summarizing sessions when they end needs a summary API URL")
+	}
+	if err := checkSummaryPrompt(c.SummaryPrompt); err != nil {
+		return err
+	}
+	switch c.UpdateChannel {
+	case "", updateChannelStable, updateChannelBeta:
+	default:
+		return fmt.Errorf("Update channel must be stable or beta")
+	}
+	if c.UpdateCheckHours < 0 || c.UpdateCheckHours > maxUpdateCheckHours {
+		return fmt.Errorf("Update check interval must be 0 to %d hours", maxUpdateCheckHours)
+	}
+	return validateSources(c)
 }
 
 // setConfigPath overrides the default config file path.
@@ -54,6 +785,13 @@ func saveConfiguration(config *AppConfig) error {
 	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
 		return fmt.Errorf("creating config directory: %w", err)
 	}
+	plain := *config
+	stripOverrides(&plain)
+	// Encrypt before truncating, so a failure keeps the old file.
+	stored := plain
+	if err := encryptSecrets(&stored); err != nil {
+		return err
+	}
 	file, err := os.OpenFile(configPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return fmt.Errorf("opening config file for writing: %w", err)
@@ -62,25 +800,369 @@ func saveConfiguration(config *AppConfig) error {
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(config); err != nil {
+	if err := encoder.Encode(stored); err != nil {
 		return fmt.Errorf("encoding config: %w", err)
 	}
+	if config.Profile != "" {
+		return saveProfile(config.Profile, &plain)
+	}
 	return nil
 }
 
-// loadConfiguration reads the application config from a JSON file
-// in the user's config directory.
-func loadConfiguration() (*AppConfig, error) {
-	file, err := os.Open(getConfigPath())
+// stripOverrides puts the config file's own value back into every setting
+// the environment or command line overrides, unless it was changed since,
+// so saving doesn't make the overrides permanent.
+func stripOverrides(config *AppConfig) {
+	var file AppConfig
+	// An unreadable file leaves file empty, so overridden settings are
+	// saved unset.
+	readConfigFile(getConfigPath(), &file)
+	overridden := file
+	applyEnv(&overridden, os.Getenv)
+	applyOverrides(&overridden, configOverrides)
+
+	c, f, o := reflect.ValueOf(config).Elem(), reflect.ValueOf(&file).Elem(), reflect.ValueOf(&overridden).Elem()
+	for i := 0; i < c.NumField(); i++ {
+		if !reflect.DeepEqual(f.Field(i).Interface(), o.Field(i).Interface()) &&
+			reflect.DeepEqual(c.Field(i).Interface(), o.Field(i).Interface()) {
+			c.Field(i).Set(f.Field(i))
+		}
+	}
+}
+
+// ConfigOverrides holds configuration given on the command line. Empty
+// fields leave the value from the other sources unchanged.
+type ConfigOverrides struct {
+	ListenAddr string
+	WebhookURL string
+	Path       string
+	FileFormat string
+}
+
+// loadConfiguration builds the effective configuration by merging several
+// sources. Later sources take precedence over earlier ones:
+//
+//  1. built-in defaults
+//  2. the config file (a missing file is not an error)
+//  3. RPCL_* environment variables (see envOverrides)
+//  4. command-line flags
+//
+// If the config file exists but cannot be read, the error is returned
+// together with a config built from the remaining sources.
+func loadConfiguration(overrides ConfigOverrides) (*AppConfig, error) {
+	config := &AppConfig{}
+	fileErr := readConfigFile(getConfigPath(), config)
+	if fileErr != nil && errors.Is(fileErr, fs.ErrNotExist) {
+		fileErr = nil
+	}
+
+	applyEnv(config, os.Getenv)
+	applyOverrides(config, overrides)
+
+	if config.DebugMode {
+		if config.LogLevel == "" {
+			config.LogLevel = "debug"
+		}
+		config.DebugMode = false
+	}
+
+	if config.ListenAddr == "" {
+		config.ListenAddr = defaultListenAddr
+	}
+	if config.FileFormat == "" {
+		config.FileFormat = "txt"
+	}
+	return config, fileErr
+}
+
+// readConfigFile decodes the JSON config file at path into config.
+func readConfigFile(path string, config *AppConfig) error {
+	file, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("opening config file: %w", err)
+		return fmt.Errorf("opening config file: %w", err)
 	}
 	defer file.Close()
 
-	config := &AppConfig{}
 	decoder := json.NewDecoder(file)
 	if err := decoder.Decode(config); err != nil {
-		return nil, fmt.Errorf("decoding config: %w", err)
+		// Don't keep a half-decoded config around.
+		*config = AppConfig{}
+		return fmt.Errorf("decoding config: %w", err)
+	}
+	if err := decryptSecrets(config); err != nil {
+		return err
+	}
+	return nil
+}
+
+// envOverrides maps RPCL_* environment variables to the config fields they
+// set. Setting a webhook, path or forward URL also enables that output,
+// unless the matching RPCL_ENABLE_* variable says otherwise.
+var envOverrides = []struct {
+	name  string
+	apply func(c *AppConfig, value string)
+}{
+	{"RPCL_LISTEN_ADDR", func(c *AppConfig, v string) { c.ListenAddr = v }},
+	{"RPCL_WEBHOOK_URL", func(c *AppConfig, v string) { c.WebhookURL = v; c.EnableDiscord = true }},
+	{"RPCL_BOT_TOKEN", func(c *AppConfig, v string) { c.BotToken = v }},
+	{"RPCL_BOT_CHANNEL_ID", func(c *AppConfig, v string) {
+		c.BotChannelID = v
+		c.DiscordMode = discordModeBot
+		c.EnableDiscord = true
+	}},
+	{"RPCL_BOT_OOC_CHANNEL_ID", func(c *AppConfig, v string) { c.BotOOCChannelID = v }},
+	{"RPCL_RELAY", func(c *AppConfig, v string) { c.EnableRelay = parseEnvBool(v) }},
+	{"RPCL_RELAY_CHANNEL_ID", func(c *AppConfig, v string) { c.RelayChannelID = v }},
+	{"RPCL_RELAY_URL", func(c *AppConfig, v string) { c.RelayURL = v }},
+	{"RPCL_TUNNEL", func(c *AppConfig, v string) { c.EnableTunnel = parseEnvBool(v) }},
+	{"RPCL_TUNNEL_PROVIDER", func(c *AppConfig, v string) { c.TunnelProvider = strings.ToLower(v) }},
+	{"RPCL_TUNNEL_AUTH_TOKEN", func(c *AppConfig, v string) { c.TunnelAuthToken = v }},
+	{"RPCL_TUNNEL_COMMAND", func(c *AppConfig, v string) { c.TunnelCommand = v }},
+	{"RPCL_MDNS", func(c *AppConfig, v string) { c.EnableMDNS = parseEnvBool(v) }},
+	{"RPCL_MDNS_HOSTNAME", func(c *AppConfig, v string) { c.MDNSHostname = strings.ToLower(v) }},
+	{"RPCL_UPNP", func(c *AppConfig, v string) { c.EnableUPnP = parseEnvBool(v) }},
+	{"RPCL_LIVE_PAGE", func(c *AppConfig, v string) { c.EnableLivePage = parseEnvBool(v) }},
+	{"RPCL_REQUIRE_INGEST_TOKEN", func(c *AppConfig, v string) { c.RequireIngestToken = parseEnvBool(v) }},
+	{"RPCL_REQUIRE_API_TOKEN", func(c *AppConfig, v string) { c.RequireAPIToken = parseEnvBool(v) }},
+	{"RPCL_CORS_ORIGINS", func(c *AppConfig, v string) { c.CORSOrigins = parseList(v) }},
+	{"RPCL_INGEST_ALLOW_IPS", func(c *AppConfig, v string) { c.IngestAllowIPs = parseList(v) }},
+	{"RPCL_INGEST_DENY_IPS", func(c *AppConfig, v string) { c.IngestDenyIPs = parseList(v) }},
+	{"RPCL_WEB_UI_ALLOW_IPS", func(c *AppConfig, v string) { c.WebUIAllowIPs = parseList(v) }},
+	{"RPCL_RCON", func(c *AppConfig, v string) { c.EnableRCON = parseEnvBool(v) }},
+	{"RPCL_RCON_ADDR", func(c *AppConfig, v string) { c.RCONAddr = v }},
+	{"RPCL_RCON_PASSWORD", func(c *AppConfig, v string) { c.RCONPassword = v }},
+	{"RPCL_RCON_COMMAND", func(c *AppConfig, v string) { c.RCONCommand = v }},
+	{"RPCL_RCON_START_MESSAGE", func(c *AppConfig, v string) { c.RCONStartMessage = v }},
+	{"RPCL_RCON_STOP_MESSAGE", func(c *AppConfig, v string) { c.RCONStopMessage = v }},
+	{"RPCL_DISCORD_MODE", func(c *AppConfig, v string) { c.DiscordMode = strings.ToLower(v) }},
+	{"RPCL_PATH", func(c *AppConfig, v string) { c.Path = v; c.EnableLocalSave = true }},
+	{"RPCL_FORMAT", func(c *AppConfig, v string) { c.FileFormat = v }},
+	{"RPCL_FORWARD_URL", func(c *AppConfig, v string) { c.ForwardURL = v; c.EnableForward = true }},
+	{"RPCL_ENABLE_DISCORD", func(c *AppConfig, v string) { c.EnableDiscord = parseEnvBool(v) }},
+	{"RPCL_ENABLE_LOCAL_SAVE", func(c *AppConfig, v string) { c.EnableLocalSave = parseEnvBool(v) }},
+	{"RPCL_ENABLE_FORWARD", func(c *AppConfig, v string) { c.EnableForward = parseEnvBool(v) }},
+	{"RPCL_AUTO_START", func(c *AppConfig, v string) { c.AutoStart = parseEnvBool(v) }},
+	{"RPCL_DEBUG", func(c *AppConfig, v string) {
+		if parseEnvBool(v) {
+			c.LogLevel = "debug"
+		}
+	}},
+	{"RPCL_LOG_LEVEL", func(c *AppConfig, v string) { c.LogLevel = strings.ToLower(v) }},
+	{"RPCL_SCENE_THREADS", func(c *AppConfig, v string) { c.SceneThreads = parseEnvBool(v) }},
+	{"RPCL_SENDER_AS_AUTHOR", func(c *AppConfig, v string) { c.SenderAsAuthor = parseEnvBool(v) }},
+	{"RPCL_DISCORD_ATTACH_AFTER", func(c *AppConfig, v string) { c.DiscordAttachAfter = parseEnvInt(v) }},
+	{"RPCL_DISCORD_MARKDOWN", func(c *AppConfig, v string) { c.DiscordMarkdown = strings.ToLower(v) }},
+	{"RPCL_DISCORD_MENTIONS", func(c *AppConfig, v string) { c.DiscordMentions = strings.ToLower(v) }},
+	{"RPCL_DISCORD_SESSION_TRANSCRIPT", func(c *AppConfig, v string) { c.DiscordSessionTranscript = parseEnvBool(v) }},
+	{"RPCL_DISCORD_FAILOVER", func(c *AppConfig, v string) { c.DiscordFailover = parseList(v) }},
+	{"RPCL_DISCORD_FAILOVER_AFTER", func(c *AppConfig, v string) { c.DiscordFailoverAfter = parseEnvInt(v) }},
+	{"RPCL_DISCORD_BREAKER_AFTER", func(c *AppConfig, v string) { c.DiscordBreakerAfter = parseEnvInt(v) }},
+	{"RPCL_DISCORD_BREAKER_COOLDOWN", func(c *AppConfig, v string) { c.DiscordBreakerCooldown = parseEnvInt(v) }},
+	{"RPCL_OFFLINE_BUFFER", func(c *AppConfig, v string) { c.OfflineBuffer = parseEnvBool(v) }},
+	{"RPCL_HTTP_TIMEOUT", func(c *AppConfig, v string) { c.HTTPTimeout = parseEnvInt(v) }},
+	{"RPCL_HTTP_PROXY", func(c *AppConfig, v string) { c.HTTPProxy = v }},
+	{"RPCL_HTTP_CA_BUNDLE", func(c *AppConfig, v string) { c.HTTPCABundle = v }},
+	{"RPCL_SUMMARY_URL", func(c *AppConfig, v string) { c.SummaryURL = v }},
+	{"RPCL_SUMMARY_API_KEY", func(c *AppConfig, v string) { c.SummaryAPIKey = v }},
+	{"RPCL_SUMMARY_MODEL", func(c *AppConfig, v string) { c.SummaryModel = v }},
+	{"RPCL_SUMMARY_PROMPT", func(c *AppConfig, v string) { c.SummaryPrompt = v }},
+	{"RPCL_SUMMARY_ON_SESSION_END", func(c *AppConfig, v string) { c.SummaryOnSessionEnd = parseEnvBool(v) }},
+	{"RPCL_SUMMARY_DISCORD", func(c *AppConfig, v string) { c.SummaryDiscord = parseEnvBool(v) }},
+	{"RPCL_SUMMARY_FILE", func(c *AppConfig, v string) { c.SummaryFile = parseEnvBool(v) }},
+	{"RPCL_EMOTE_DETECTION", func(c *AppConfig, v string) { c.EmoteDetection = parseEnvBool(v) }},
+	{"RPCL_TRANSFORMS", func(c *AppConfig, v string) { c.Transforms = parseLines(v) }},
+	{"RPCL_TRANSLATE_PROVIDER", func(c *AppConfig, v string) { c.TranslateProvider = strings.ToLower(v) }},
+	{"RPCL_TRANSLATE_URL", func(c *AppConfig, v string) { c.TranslateURL = v }},
+	{"RPCL_TRANSLATE_API_KEY", func(c *AppConfig, v string) { c.TranslateAPIKey = v }},
+	{"RPCL_TRANSLATE_TARGET", func(c *AppConfig, v string) { c.TranslateTarget = v }},
+	{"RPCL_TRANSLATE_DISCORD", func(c *AppConfig, v string) { c.TranslateDiscord = parseEnvBool(v) }},
+	{"RPCL_TRANSLATE_FILE", func(c *AppConfig, v string) { c.TranslateFile = parseEnvBool(v) }},
+	{"RPCL_RICH_TEXT", func(c *AppConfig, v string) { c.RichText = strings.ToLower(v) }},
+	{"RPCL_EMOTE_PREFIXES", func(c *AppConfig, v string) { c.EmotePrefixes = parseList(v) }},
+	{"RPCL_OOC_MARKERS", func(c *AppConfig, v string) { c.OOCMarkers = parseList(v) }},
+	{"RPCL_OOC_DISCORD_POLICY", func(c *AppConfig, v string) { c.OOCDiscordPolicy = v }},
+	{"RPCL_OOC_WEBHOOK_URL", func(c *AppConfig, v string) { c.OOCWebhookURL = v }},
+	{"RPCL_OOC_FILE_POLICY", func(c *AppConfig, v string) { c.OOCFilePolicy = v }},
+	{"RPCL_IGNORED_SENDERS", func(c *AppConfig, v string) { c.IgnoredSenders = parseList(v) }},
+	{"RPCL_IGNORED_SENDER_POLICY", func(c *AppConfig, v string) { c.IgnoredSenderPolicy = v }},
+	{"RPCL_QUIET_HOURS", func(c *AppConfig, v string) { c.QuietHours = v }},
+	{"RPCL_QUIET_HOURS_POLICY", func(c *AppConfig, v string) { c.QuietHoursPolicy = v }},
+	{"RPCL_DIGEST", func(c *AppConfig, v string) { c.EnableDigest = parseEnvBool(v) }},
+	{"RPCL_DIGEST_TIME", func(c *AppConfig, v string) { c.DigestTime = v }},
+	{"RPCL_DIGEST_WEBHOOK_URL", func(c *AppConfig, v string) { c.DigestWebhookURL = v }},
+	{"RPCL_EMAIL_DIGEST", func(c *AppConfig, v string) { c.EnableEmailDigest = parseEnvBool(v) }},
+	{"RPCL_EMAIL_TO", func(c *AppConfig, v string) { c.EmailTo = parseList(v) }},
+	{"RPCL_EMAIL_ATTACH_TRANSCRIPTS", func(c *AppConfig, v string) { c.EmailAttachTranscripts = parseEnvBool(v) }},
+	{"RPCL_SMTP_HOST", func(c *AppConfig, v string) { c.SMTPHost = v }},
+	{"RPCL_SMTP_PORT", func(c *AppConfig, v string) { c.SMTPPort = parseEnvInt(v) }},
+	{"RPCL_SMTP_SECURITY", func(c *AppConfig, v string) { c.SMTPSecurity = v }},
+	{"RPCL_SMTP_USER", func(c *AppConfig, v string) { c.SMTPUser = v }},
+	{"RPCL_SMTP_PASSWORD", func(c *AppConfig, v string) { c.SMTPPassword = v }},
+	{"RPCL_SMTP_FROM", func(c *AppConfig, v string) { c.SMTPFrom = v }},
+	{"RPCL_UDP_LISTEN_ADDR", func(c *AppConfig, v string) { c.UDPListenAddr = v }},
+	{"RPCL_UDP_PATTERN", func(c *AppConfig, v string) { c.UDPPattern = v }},
+	{"RPCL_TAIL_PATH", func(c *AppConfig, v string) { c.TailPath = v }},
+	{"RPCL_TAIL_PATTERN", func(c *AppConfig, v string) { c.TailPattern = v }},
+	{"RPCL_INPUT_PRESET", func(c *AppConfig, v string) { c.InputPreset = v }},
+	{"RPCL_INPUT_FIELDS", func(c *AppConfig, v string) { c.InputFields = parseList(v) }},
+	{"RPCL_RATE_LIMIT", func(c *AppConfig, v string) { c.RateLimit = parseEnvInt(v) }},
+	{"RPCL_SENDER_RATE_LIMIT", func(c *AppConfig, v string) { c.SenderRateLimit = parseEnvInt(v) }},
+	{"RPCL_RATE_LIMIT_BURST", func(c *AppConfig, v string) { c.RateLimitBurst = parseEnvInt(v) }},
+	{"RPCL_RATE_LIMIT_POLICY", func(c *AppConfig, v string) { c.RateLimitPolicy = v }},
+	{"RPCL_MAX_MESSAGE_LENGTH", func(c *AppConfig, v string) { c.MaxMessageLength = parseEnvInt(v) }},
+	{"RPCL_MAX_SENDER_LENGTH", func(c *AppConfig, v string) { c.MaxSenderLength = parseEnvInt(v) }},
+	{"RPCL_LENGTH_POLICY", func(c *AppConfig, v string) { c.LengthPolicy = v }},
+	{"RPCL_APP_LOG", func(c *AppConfig, v string) { c.AppLogPath = v }},
+	{"RPCL_APP_LOG_FORMAT", func(c *AppConfig, v string) { c.AppLogFormat = v }},
+	{"RPCL_ACCESS_LOG", func(c *AppConfig, v string) { c.EnableAccessLog = parseEnvBool(v) }},
+	{"RPCL_ACCESS_LOG_PATH", func(c *AppConfig, v string) { c.AccessLogPath = v }},
+	{"RPCL_FILENAME_TEMPLATE", func(c *AppConfig, v string) { c.FilenameTemplate = v }},
+	{"RPCL_DISCORD_TEMPLATE", func(c *AppConfig, v string) { c.DiscordTemplate = v }},
+	{"RPCL_TEXT_TEMPLATE", func(c *AppConfig, v string) { c.TextTemplate = v }},
+	{"RPCL_FOLDER_LAYOUT", func(c *AppConfig, v string) { c.FolderLayout = v }},
+	{"RPCL_CSV_DELIMITER", func(c *AppConfig, v string) { c.CSVDelimiter = strings.ToLower(v) }},
+	{"RPCL_CSV_BOM", func(c *AppConfig, v string) { c.CSVBOM = parseEnvBool(v) }},
+	{"RPCL_FLUSH_INTERVAL", func(c *AppConfig, v string) { c.FlushInterval = parseEnvInt(v) }},
+	{"RPCL_RETENTION_DAYS", func(c *AppConfig, v string) { c.RetentionDays = parseEnvInt(v) }},
+	{"RPCL_RETENTION_MAX_SIZE_MB", func(c *AppConfig, v string) { c.RetentionMaxSizeMB = parseEnvInt(v) }},
+	{"RPCL_RETENTION_ACTION", func(c *AppConfig, v string) { c.RetentionAction = v }},
+	{"RPCL_BACKUP", func(c *AppConfig, v string) { c.EnableBackup = parseEnvBool(v) }},
+	{"RPCL_BACKUP_SCHEDULE", func(c *AppConfig, v string) { c.BackupSchedule = v }},
+	{"RPCL_BACKUP_PATH", func(c *AppConfig, v string) { c.BackupPath = v }},
+	{"RPCL_BACKUP_KEEP", func(c *AppConfig, v string) { c.BackupKeep = parseEnvInt(v) }},
+	{"RPCL_BACKUP_UPLOAD_URL", func(c *AppConfig, v string) { c.BackupUploadURL = v }},
+	{"RPCL_S3", func(c *AppConfig, v string) { c.EnableS3 = parseEnvBool(v) }},
+	{"RPCL_S3_ENDPOINT", func(c *AppConfig, v string) { c.S3Endpoint = v }},
+	{"RPCL_S3_REGION", func(c *AppConfig, v string) { c.S3Region = v }},
+	{"RPCL_S3_BUCKET", func(c *AppConfig, v string) { c.S3Bucket = v }},
+	{"RPCL_S3_PREFIX", func(c *AppConfig, v string) { c.S3Prefix = v }},
+	{"RPCL_S3_ACCESS_KEY", func(c *AppConfig, v string) { c.S3AccessKey = v }},
+	{"RPCL_S3_SECRET_KEY", func(c *AppConfig, v string) { c.S3SecretKey = v }},
+	{"RPCL_S3_PATH_STYLE", func(c *AppConfig, v string) { c.S3PathStyle = parseEnvBool(v) }},
+	{"RPCL_WEBDAV", func(c *AppConfig, v string) { c.EnableWebDAV = parseEnvBool(v) }},
+	{"RPCL_WEBDAV_URL", func(c *AppConfig, v string) { c.WebDAVURL = v }},
+	{"RPCL_WEBDAV_USER", func(c *AppConfig, v string) { c.WebDAVUser = v }},
+	{"RPCL_WEBDAV_PASSWORD", func(c *AppConfig, v string) { c.WebDAVPassword = v }},
+	{"RPCL_GDRIVE", func(c *AppConfig, v string) { c.EnableGDrive = parseEnvBool(v) }},
+	{"RPCL_GDRIVE_CREDENTIALS", func(c *AppConfig, v string) { c.GDriveCredentials = v }},
+	{"RPCL_GDRIVE_FOLDER_ID", func(c *AppConfig, v string) { c.GDriveFolderID = v }},
+	{"RPCL_UPLOAD_DELETE_LOCAL", func(c *AppConfig, v string) { c.UploadDeleteLocal = parseEnvBool(v) }},
+	{"RPCL_PERSIST_RECEIPTS", func(c *AppConfig, v string) { c.PersistReceipts = parseEnvBool(v) }},
+	{"RPCL_DELIVERY_QUEUE_SIZE", func(c *AppConfig, v string) { c.DeliveryQueueSize = parseEnvInt(v) }},
+	{"RPCL_DELIVERY_DROP_POLICY", func(c *AppConfig, v string) { c.DeliveryDropPolicy = strings.ToLower(v) }},
+	{"RPCL_LOG_HISTORY", func(c *AppConfig, v string) { c.LogHistorySize = parseEnvInt(v) }},
+	{"RPCL_FAILURE_HISTORY", func(c *AppConfig, v string) { c.FailureHistorySize = parseEnvInt(v) }},
+	{"RPCL_PERSIST_LOG_HISTORY", func(c *AppConfig, v string) { c.PersistLogHistory = parseEnvBool(v) }},
+	{"RPCL_SECRET_STORAGE", func(c *AppConfig, v string) { c.SecretStorage = strings.ToLower(v) }},
+	{"RPCL_UPDATE_CHANNEL", func(c *AppConfig, v string) { c.UpdateChannel = strings.ToLower(v) }},
+	{"RPCL_UPDATE_CHECK_HOURS", func(c *AppConfig, v string) { c.UpdateCheckHours = parseEnvInt(v) }},
+	{"RPCL_DISABLE_UPDATE_CHECK", func(c *AppConfig, v string) { c.DisableUpdateCheck = parseEnvBool(v) }},
+	{"RPCL_AUTO_UPDATE", func(c *AppConfig, v string) { c.AutoUpdate = parseEnvBool(v) }},
+}
+
+// applyEnv overlays the RPCL_* environment variables onto config. Unset or
+// empty variables are ignored.
+func applyEnv(config *AppConfig, getenv func(string) string) {
+	for _, env := range envOverrides {
+		if value := strings.TrimSpace(getenv(env.name)); value != "" {
+			env.apply(config, value)
+		}
+	}
+}
+
+// applyOverrides overlays command-line flags onto config. Like their
+// environment variables, a webhook or path flag enables that output.
+func applyOverrides(config *AppConfig, overrides ConfigOverrides) {
+	if overrides.ListenAddr != "" {
+		config.ListenAddr = overrides.ListenAddr
+	}
+	if overrides.WebhookURL != "" {
+		config.WebhookURL = overrides.WebhookURL
+		config.EnableDiscord = true
+	}
+	if overrides.Path != "" {
+		config.Path = overrides.Path
+		config.EnableLocalSave = true
+	}
+	if overrides.FileFormat != "" {
+		config.FileFormat = overrides.FileFormat
+	}
+}
+
+// parseEnvInt parses a whole number, treating invalid values as 0 (unset).
+func parseEnvInt(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// parseEnvBool interprets common truthy spellings ("1", "true", "yes", "on").
+func parseEnvBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseNameMap parses "Name = value" lines (as entered in the web UI) into
+// a map. Blank lines and lines without "=" are ignored.
+func parseNameMap(text string) map[string]string {
+	result := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if name == "" || value == "" {
+			continue
+		}
+		result[name] = value
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// parseList splits a comma-separated web UI value into trimmed, non-empty items.
+func parseList(text string) []string {
+	var items []string
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseLines splits text into its non-empty, trimmed lines.
+func parseLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// formatNameMap renders a map as sorted "Name = value" lines for the web UI.
+func formatNameMap(m map[string]string) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s = %s\n", name, m[name])
 	}
-	return config, nil
+	return b.String()
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfiguration_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	setConfigPath(path)
+	defer setConfigPath("")
+
+	data := `{"listenAddr": "file:1", "webhookURL": "https://file", "enableDiscord": true, "path": "/file", "fileFormat": "csv"}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("RPCL_LISTEN_ADDR", "env:2")
+	t.Setenv("RPCL_WEBHOOK_URL", "https://env")
+	t.Setenv("RPCL_ENABLE_LOCAL_SAVE", "true")
+
+	cfg, err := loadConfiguration(ConfigOverrides{ListenAddr: "flag:3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ListenAddr != "flag:3" {
+		t.Errorf("flags should override env: got listen address %q", cfg.ListenAddr)
+	}
+	if cfg.WebhookURL != "https://env" {
+		t.Errorf("env should override file: got webhook %q", cfg.WebhookURL)
+	}
+	if cfg.Path != "/file" || cfg.FileFormat != "csv" {
+		t.Errorf("file values should be kept when not overridden: got path %q, format %q", cfg.Path, cfg.FileFormat)
+	}
+	if !cfg.EnableLocalSave {
+		t.Error("expected RPCL_ENABLE_LOCAL_SAVE to enable file logging")
+	}
+}
+
+func TestLoadConfiguration_NoFile(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "missing.json"))
+	defer setConfigPath("")
+
+	t.Setenv("RPCL_PATH", "/logs")
+	t.Setenv("RPCL_DEBUG", "yes")
+
+	cfg, err := loadConfiguration(ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("missing config file should not be an error: %v", err)
+	}
+	if cfg.ListenAddr != defaultListenAddr || cfg.FileFormat != "txt" {
+		t.Errorf("expected defaults, got listen %q, format %q", cfg.ListenAddr, cfg.FileFormat)
+	}
+	if cfg.Path != "/logs" || !cfg.EnableLocalSave || cfg.LogLevel != "debug" {
+		t.Errorf("expected env values to apply, got %+v", cfg)
+	}
+}
+
+func TestLoadConfiguration_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	setConfigPath(path)
+	defer setConfigPath("")
+
+	if err := os.WriteFile(path, []byte(`{"listenAddr": "file:1", broken`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfiguration(ConfigOverrides{WebhookURL: "https://flag"})
+	if err == nil {
+		t.Error("expected error for invalid config file")
+	}
+	if cfg.ListenAddr != defaultListenAddr || cfg.WebhookURL != "https://flag" || !cfg.EnableDiscord {
+		t.Errorf("expected defaults plus overrides, got %+v", cfg)
+	}
+}
+
+func TestLoadConfiguration_LegacyDebugMode(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"debug mode on", `{"debugMode": true}`, "debug"},
+		{"debug mode off", `{"debugMode": false}`, ""},
+		{"log level wins", `{"debugMode": true, "logLevel": "trace"}`, "trace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			setConfigPath(path)
+			defer setConfigPath("")
+			if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := loadConfiguration(ConfigOverrides{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.LogLevel != tt.want || cfg.DebugMode {
+				t.Errorf("got level %q (debugMode %v), want %q", cfg.LogLevel, cfg.DebugMode, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	base := AppConfig{ListenAddr: "file:1", WebhookURL: "https://file", Path: "/file", FileFormat: "csv"}
+	tests := []struct {
+		name      string
+		overrides ConfigOverrides
+		want      AppConfig
+	}{
+		{"none", ConfigOverrides{}, base},
+		{"listen", ConfigOverrides{ListenAddr: "flag:3"},
+			AppConfig{ListenAddr: "flag:3", WebhookURL: "https://file", Path: "/file", FileFormat: "csv"}},
+		{"webhook enables Discord", ConfigOverrides{WebhookURL: "https://flag"},
+			AppConfig{ListenAddr: "file:1", WebhookURL: "https://flag", EnableDiscord: true, Path: "/file", FileFormat: "csv"}},
+		{"path enables file logging", ConfigOverrides{Path: "/flag", FileFormat: "json"},
+			AppConfig{ListenAddr: "file:1", WebhookURL: "https://file", Path: "/flag", EnableLocalSave: true, FileFormat: "json"}},
+	}
+	for _, tt := range tests {
+		cfg := base
+		applyOverrides(&cfg, tt.overrides)
+		if !reflect.DeepEqual(cfg, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, cfg, tt.want)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want AppConfig
+	}{
+		{map[string]string{}, AppConfig{}},
+		{map[string]string{"RPCL_LISTEN_ADDR": " env:2 "}, AppConfig{ListenAddr: "env:2"}},
+		{map[string]string{"RPCL_WEBHOOK_URL": "https://env"}, AppConfig{WebhookURL: "https://env", EnableDiscord: true}},
+		{map[string]string{"RPCL_PATH": "/logs", "RPCL_ENABLE_LOCAL_SAVE": "false"}, AppConfig{Path: "/logs"}},
+		{map[string]string{"RPCL_BOT_CHANNEL_ID": "100"}, AppConfig{BotChannelID: "100", DiscordMode: discordModeBot, EnableDiscord: true}},
+	}
+	for _, tt := range tests {
+		var cfg AppConfig
+		applyEnv(&cfg, func(name string) string { return tt.env[name] })
+		if !reflect.DeepEqual(cfg, tt.want) {
+			t.Errorf("env %v: got %+v, want %+v", tt.env, cfg, tt.want)
+		}
+	}
+}
+
+func TestSaveConfiguration_KeepsOverridesOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	setConfigPath(path)
+	defer setConfigPath("")
+	oldOverrides := configOverrides
+	configOverrides = ConfigOverrides{Path: "/flag", FileFormat: "json"}
+	defer func() { configOverrides = oldOverrides }()
+	t.Setenv("RPCL_LISTEN_ADDR", "env:2")
+
+	if err := os.WriteFile(path, []byte(`{"listenAddr": "file:1", "fileFormat": "csv"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfiguration(configOverrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.LogLevel = "debug" // changed in the web UI
+	cfg.FileFormat = "txt" // an overridden setting changed in the web UI
+	if err := saveConfiguration(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved AppConfig
+	if err := readConfigFile(path, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.ListenAddr != "file:1" || saved.Path != "" || saved.EnableLocalSave {
+		t.Errorf("overrides saved: listen %q, path %q, local save %v", saved.ListenAddr, saved.Path, saved.EnableLocalSave)
+	}
+	if saved.LogLevel != "debug" || saved.FileFormat != "txt" {
+		t.Errorf("changes lost: level %q, format %q", saved.LogLevel, saved.FileFormat)
+	}
+}
+
+func TestApplyConfig_KeepsOverrides(t *testing.T) {
+	oldOverrides := configOverrides
+	configOverrides = ConfigOverrides{ListenAddr: "127.0.0.1:3999"}
+	defer func() { configOverrides = oldOverrides }()
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	a.applyConfig(&AppConfig{ListenAddr: "127.0.0.1:4000", FileFormat: "txt", LogLevel: "debug"}, "Switched to profile")
+	if a.config.ListenAddr != "127.0.0.1:3999" || a.config.LogLevel != "debug" {
+		t.Errorf("after switching, listen %q, level %q", a.config.ListenAddr, a.config.LogLevel)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// redactedSecret replaces secrets in exported configs. Importing it
+	// keeps the importing machine's own value.
+	redactedSecret = "<redacted>"
+	// maxConfigImportSize bounds an uploaded config file.
+	maxConfigImportSize = 1 << 20
+	// configExportName is the file name offered for exported configs.
+	configExportName = "rp-chat-logger-config.json"
+)
+
+// mapSecrets replaces every non-empty credential in c with fn(key, value):
+// webhook URLs (which embed their token), tokens, passwords and keys,
+// including each source's, language's and failover webhook. key names the field, e.g. "botToken"
+// or "sources.Siptah.webhookURL". Sources gets a new map, so a shallow
+// copy of a config can be changed without touching the original.
+func mapSecrets(c *AppConfig, fn func(key, value string) string) {
+	fields := []struct {
+		key   string
+		value *string
+	}{
+		{"webhookURL", &c.WebhookURL},
+		{"botToken", &c.BotToken},
+		{"tunnelAuthToken", &c.TunnelAuthToken},
+		{"rconPassword", &c.RCONPassword},
+		{"oocWebhookURL", &c.OOCWebhookURL},
+		{"digestWebhookURL", &c.DigestWebhookURL},
+		{"smtpPassword", &c.SMTPPassword},
+		{"backupUploadURL", &c.BackupUploadURL},
+		{"s3SecretKey", &c.S3SecretKey},
+		{"webdavPassword", &c.WebDAVPassword},
+		{"translateAPIKey", &c.TranslateAPIKey},
+		{"summaryAPIKey", &c.SummaryAPIKey},
+	}
+	for _, f := range fields {
+		if *f.value != "" {
+			*f.value = fn(f.key, *f.value)
+		}
+	}
+	// A proxy URL is only secret with a password in it.
+	if strings.Contains(c.HTTPProxy, "@") || strings.HasPrefix(c.HTTPProxy, encryptedPrefix) {
+		c.HTTPProxy = fn("httpProxy", c.HTTPProxy)
+	}
+	if c.DiscordFailover != nil {
+		failover := make([]string, len(c.DiscordFailover))
+		for i, route := range c.DiscordFailover {
+			if strings.Trim(route, "0123456789") != "" {
+				route = fn(fmt.Sprintf("discordFailover.%d", i), route)
+			}
+			failover[i] = route
+		}
+		c.DiscordFailover = failover
+	}
+	if c.LanguageRoutes != nil {
+		routes := make(map[string]string, len(c.LanguageRoutes))
+		for lang, route := range c.LanguageRoutes {
+			// Webhook URLs are secret, bot mode's channel IDs aren't.
+			if strings.Trim(route, "0123456789") != "" {
+				route = fn("languageRoutes."+lang, route)
+			}
+			routes[lang] = route
+		}
+		c.LanguageRoutes = routes
+	}
+	if c.SenderRoutes != nil {
+		routes := make(map[string]SenderRoute, len(c.SenderRoutes))
+		for name, route := range c.SenderRoutes {
+			if strings.Trim(route.WebhookURL, "0123456789") != "" {
+				route.WebhookURL = fn("senderRoutes."+name+".webhookURL", route.WebhookURL)
+			}
+			routes[name] = route
+		}
+		c.SenderRoutes = routes
+	}
+	if c.Sources == nil {
+		return
+	}
+	sources := make(map[string]SourceProfile, len(c.Sources))
+	for name, profile := range c.Sources {
+		if profile.WebhookURL != "" {
+			profile.WebhookURL = fn("sources."+name+".webhookURL", profile.WebhookURL)
+		}
+		sources[name] = profile
+	}
+	c.Sources = sources
+}
+
+// exportConfig returns cfg for sharing with another machine: without the
+// state that only means something here (active profile, last digest and
+// upload), and with secrets replaced by redactedSecret when redact is set.
+func exportConfig(cfg AppConfig, redact bool) AppConfig {
+	cfg.Profile = ""
+	cfg.LastDigest = ""
+	cfg.LastUpload = ""
+	if redact {
+		mapSecrets(&cfg, func(string, string) string { return redactedSecret })
+	}
+	return cfg
+}
+
+// importConfig decodes a shared config and merges it with current: secrets
+// left out of the export keep their current values, as do the active
+// profile and the last digest and upload.
+func importConfig(data []byte, current AppConfig) (AppConfig, error) {
+	var cfg AppConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&cfg); err != nil {
+		return AppConfig{}, fmt.Errorf("decoding config: %w", err)
+	}
+	if err := decryptSecrets(&cfg); err != nil {
+		return AppConfig{}, err
+	}
+	secrets := make(map[string]string)
+	mapSecrets(&current, func(key, value string) string {
+		secrets[key] = value
+		return value
+	})
+	mapSecrets(&cfg, func(key, value string) string {
+		if value == redactedSecret {
+			return secrets[key]
+		}
+		return value
+	})
+	cfg.Profile = current.Profile
+	cfg.LastDigest = current.LastDigest
+	cfg.LastUpload = current.LastUpload
+	if cfg.DebugMode {
+		if cfg.LogLevel == "" {
+			cfg.LogLevel = "debug"
+		}
+		cfg.DebugMode = false
+	}
+	if cfg.ListenAddr == "" {
+		cfg.ListenAddr = defaultListenAddr
+	}
+	if cfg.FileFormat == "" {
+		cfg.FileFormat = "txt"
+	}
+	return cfg, nil
+}
+
+// handleExportConfig downloads the current config as a JSON file, with
+// secrets left out when ?redact is set.
+func (a *App) handleExportConfig(w http.ResponseWriter, r *http.Request) {
+	redact, _ := strconv.ParseBool(r.URL.Query().Get("redact"))
+	a.configMu.RLock()
+	cfg := exportConfig(*a.config, redact)
+	a.configMu.RUnlock()
+
+	// Keep the placeholder readable for people editing the file.
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(cfg); err != nil {
+		http.Error(w, "Failed to encode configuration", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(configExportName))
+	w.Write(buf.Bytes())
+	a.logger.Log("info", fmt.Sprintf("Configuration exported (secrets redacted: %v)", redact))
+}
+
+// handleImportConfig replaces the config with an uploaded one, once it
+// validates, and returns the refreshed config form.
+func (a *App) handleImportConfig(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxConfigImportSize)
+	a.configMu.RLock()
+	current := *a.config
+	a.configMu.RUnlock()
+
+	cfg, err := readConfigImport(r, current)
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err == nil {
+		err = checkWebhookURLs(&cfg)
+	}
+	if err == nil {
+		if err = saveConfiguration(&cfg); err != nil {
+			a.logger.Log("error", fmt.Sprintf("Failed to save config: %v", err))
+		}
+	}
+
+	data := map[string]interface{}{"Config": current}
+	if err != nil {
+		data["SaveError"] = fmt.Sprintf("Import failed: %v", err)
+	} else {
+		a.applyConfig(&cfg, "Configuration imported")
+		data["Config"] = cfg
+		data["SaveSuccess"] = true
+	}
+	tmpl, err := a.parseTemplates("templates/partials/config_form.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "config-form", data); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// readConfigImport reads the uploaded config file and merges it with
+// current.
+func readConfigImport(r *http.Request, current AppConfig) (AppConfig, error) {
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return AppConfig{}, fmt.Errorf("reading upload: %w", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return AppConfig{}, fmt.Errorf("reading upload: %w", err)
+	}
+	return importConfig(data, current)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportImportConfig(t *testing.T) {
+	source := AppConfig{
+		EnableDiscord: true,
+		WebhookURL:    "https://discord.com/api/webhooks/1/srctoken",
+		SMTPPassword:  "hunter2",
+		Sources:       map[string]SourceProfile{"Siptah": {WebhookURL: "https://discord.com/api/webhooks/2/siptahtoken"}},
+		OOCMarkers:    []string{"(("},
+		LastDigest:    "2026-10-16",
+		Profile:       "GM team",
+	}
+
+	exported := exportConfig(source, true)
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"srctoken", "siptahtoken", "hunter2", "GM team", "2026-10-16"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("export still contains %q: %s", secret, data)
+		}
+	}
+	if source.Sources["Siptah"].WebhookURL == redactedSecret {
+		t.Error("redacting must not change the exported config's source map")
+	}
+	if plain := exportConfig(source, false); plain.WebhookURL != source.WebhookURL {
+		t.Error("expected secrets to be kept without redaction")
+	}
+
+	current := AppConfig{
+		WebhookURL: "https://discord.com/api/webhooks/3/local",
+		Sources:    map[string]SourceProfile{"Siptah": {WebhookURL: "https://discord.com/api/webhooks/4/local-siptah"}},
+		LastDigest: "2026-10-17",
+	}
+	imported, err := importConfig(data, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.WebhookURL != current.WebhookURL || imported.Sources["Siptah"].WebhookURL != current.Sources["Siptah"].WebhookURL {
+		t.Errorf("expected redacted secrets to keep local values, got %+v", imported)
+	}
+	if imported.SMTPPassword != "" {
+		t.Errorf("expected a secret with no local value to be empty, got %q", imported.SMTPPassword)
+	}
+	if !imported.EnableDiscord || len(imported.OOCMarkers) != 1 || imported.LastDigest != "2026-10-17" || imported.ListenAddr != defaultListenAddr {
+		t.Errorf("unexpected imported config %+v", imported)
+	}
+
+	if _, err := importConfig([]byte("{not json"), current); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestHandleImportConfig(t *testing.T) {
+	dir := t.TempDir()
+	setConfigPath(filepath.Join(dir, "config.json"))
+	defer setConfigPath("")
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	upload := func(content string) string {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", "config.json")
+		fw.Write([]byte(content))
+		mw.Close()
+		req := httptest.NewRequest("POST", "/api/config/import", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rec := httptest.NewRecorder()
+		a.handleImportConfig(rec, req)
+		return rec.Body.String()
+	}
+
+	if body := upload(`{"enableDiscord": true, "webhookURL": "<redacted>"}`); !strings.Contains(body, "Import failed") {
+		t.Errorf("expected a missing webhook to be rejected, got %s", body)
+	}
+	logs := filepath.Join(dir, "logs")
+	if body := upload(`{"enableLocalSave": true, "path": ` + strings.ReplaceAll(`"`+logs+`"`, `\`, `\\`) + `, "fileFormat": "csv"}`); !strings.Contains(body, "Configuration saved") {
+		t.Fatalf("expected the import to succeed, got %s", body)
+	}
+	if a.config.Path != logs || a.config.FileFormat != "csv" {
+		t.Errorf("expected the imported config to be applied, got %+v", a.config)
+	}
+}
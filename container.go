@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDockerImage is the image --docker-compose refers to, as built
+	// from the Dockerfile: docker build -t rp-chat-logger .
+	defaultDockerImage = "rp-chat-logger:latest"
+
+	// Where the generated compose file mounts the config and log folders,
+	// and the web UI port inside the container.
+	containerConfigDir = "/config"
+	containerLogDir    = "/logs"
+	containerWebPort   = "8080"
+)
+
+// containerUpdateHelp tells container users how to update instead of
+// replacing the binary, which the next container start would undo.
+const containerUpdateHelp = "pull or rebuild the image and recreate the container (docker compose pull && docker compose up -d)"
+
+// detectContainer reports whether the process runs in a container.
+// RPCL_CONTAINER overrides the detection either way.
+func detectContainer() bool {
+	if v, ok := os.LookupEnv("RPCL_CONTAINER"); ok {
+		return parseEnvBool(v)
+	}
+	// Set by podman and systemd-nspawn
+	if os.Getenv("container") != "" {
+		return true
+	}
+	return inContainer("/")
+}
+
+// inContainer looks for the files container runtimes leave in the root
+// filesystem at root: Docker's /.dockerenv, Podman's /run/.containerenv,
+// or a container cgroup of PID 1.
+func inContainer(root string) bool {
+	for _, marker := range []string{".dockerenv", "run/.containerenv"} {
+		if _, err := os.Stat(filepath.Join(root, marker)); err == nil {
+			return true
+		}
+	}
+	cgroup, err := os.ReadFile(filepath.Join(root, "proc/1/cgroup"))
+	if err != nil {
+		return false
+	}
+	for _, runtime := range []string{"docker", "kubepods", "containerd", "libpod", "lxc"} {
+		if strings.Contains(string(cgroup), runtime) {
+			return true
+		}
+	}
+	return false
+}
+
+// composeFile returns a docker-compose.yml that runs image with the
+// current settings: the folder holding the config file is mounted at
+// /config and the log folder at /logs, and the ingestion port (plus the
+// UDP port, if set) and the web UI are published on the same host
+// addresses they listen on now.
+func composeFile(cfg *AppConfig, configPath, image string, now time.Time) (string, error) {
+	configDir, err := filepath.Abs(filepath.Dir(configPath))
+	if err != nil {
+		return "", fmt.Errorf("resolving config folder: %w", err)
+	}
+	ingestHost, ingestPort, err := net.SplitHostPort(cfg.ListenAddr)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", cfg.ListenAddr, err)
+	}
+
+	env := [][2]string{
+		{"RPCL_CONFIG", containerConfigDir + "/" + filepath.Base(configPath)},
+		{"RPCL_LISTEN_ADDR", ":" + ingestPort},
+		{"RPCL_WEB_ADDR", ":" + containerWebPort},
+		{"RPCL_NO_BROWSER", "true"},
+		{"RPCL_AUTO_START", "true"},
+	}
+	ports := []string{publishedPort(ingestHost, ingestPort, "")}
+	if cfg.UDPListenAddr != "" {
+		host, port, err := net.SplitHostPort(cfg.UDPListenAddr)
+		if err != nil {
+			return "", fmt.Errorf("invalid UDP listen address %q: %w", cfg.UDPListenAddr, err)
+		}
+		env = append(env, [2]string{"RPCL_UDP_LISTEN_ADDR", ":" + port})
+		ports = append(ports, publishedPort(host, port, "/udp"))
+	}
+	// The web UI stays on loopback, as it does outside a container.
+	ports = append(ports, publishedPort("127.0.0.1", containerWebPort, ""))
+
+	volumes := [][2]string{{configDir, containerConfigDir}}
+	if cfg.Path != "" {
+		logDir, err := filepath.Abs(cfg.Path)
+		if err != nil {
+			return "", fmt.Errorf("resolving log folder: %w", err)
+		}
+		volumes = append(volumes, [2]string{logDir, containerLogDir})
+		env = append(env, [2]string{"RPCL_PATH", containerLogDir})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by rp-chat-logger %s on %s from %s.\n", Version, now.Format("2006-01-02"), configPath)
+	if image == defaultDockerImage {
+		b.WriteString("# Build the image first, from the source folder: docker build -t rp-chat-logger .\n")
+	}
+	fmt.Fprintf(&b, "# Start with: docker compose up -d. To update, %s.\n", containerUpdateHelp)
+	b.WriteString("services:\n  rp-chat-logger:\n")
+	fmt.Fprintf(&b, "    image: %s\n", yamlString(image))
+	b.WriteString("    restart: unless-stopped\n")
+	b.WriteString("    environment:\n")
+	for _, kv := range env {
+		fmt.Fprintf(&b, "      %s: %s\n", kv[0], yamlString(kv[1]))
+	}
+	b.WriteString("    ports:\n")
+	for _, p := range ports {
+		fmt.Fprintf(&b, "      - %s\n", yamlString(p))
+	}
+	b.WriteString("    volumes:\n")
+	for _, v := range volumes {
+		fmt.Fprintf(&b, "      - type: bind\n        source: %s\n        target: %s\n", yamlString(v[0]), yamlString(v[1]))
+	}
+	b.WriteString("    healthcheck:\n")
+	fmt.Fprintf(&b, "      test: [\"CMD\", \"wget\", \"-qO-\", \"http://127.0.0.1:%s/readyz\"]\n", ingestPort)
+	b.WriteString("      interval: 30s\n")
+	return b.String(), nil
+}
+
+// publishedPort returns a compose port mapping that publishes port on the
+// host address the app would listen on itself; all addresses when the
+// host is empty or a wildcard.
+func publishedPort(host, port, proto string) string {
+	switch host {
+	case "", "0.0.0.0", "::":
+		return port + ":" + port + proto
+	case "localhost":
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port) + ":" + port + proto
+}
+
+// yamlString quotes s as a YAML double-quoted scalar, which JSON strings
+// are.
+func yamlString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInContainer(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{"host", map[string]string{"proc/1/cgroup": "0::/init.scope\n"}, false},
+		{"nothing readable", nil, false},
+		{"docker", map[string]string{".dockerenv": ""}, true},
+		{"podman", map[string]string{"run/.containerenv": ""}, true},
+		{"kubernetes cgroup", map[string]string{"proc/1/cgroup": "12:pids:/kubepods/besteffort/pod1234\n"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := inContainer(root); got != tt.want {
+				t.Errorf("inContainer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectContainerOverride(t *testing.T) {
+	t.Setenv("RPCL_CONTAINER", "true")
+	if !detectContainer() {
+		t.Error("RPCL_CONTAINER=true not detected")
+	}
+	t.Setenv("RPCL_CONTAINER", "false")
+	if detectContainer() {
+		t.Error("RPCL_CONTAINER=false detected as container")
+	}
+}
+
+func TestComposeFile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &AppConfig{
+		ListenAddr:    "0.0.0.0:3100",
+		UDPListenAddr: "localhost:3101",
+		Path:          filepath.Join(dir, "chat logs"),
+	}
+	got, err := composeFile(cfg, filepath.Join(dir, "conf", "config.json"), defaultDockerImage, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`image: "rp-chat-logger:latest"`,
+		"docker build -t rp-chat-logger .",
+		`RPCL_CONFIG: "/config/config.json"`,
+		`RPCL_LISTEN_ADDR: ":3100"`,
+		`RPCL_UDP_LISTEN_ADDR: ":3101"`,
+		`RPCL_PATH: "/logs"`,
+		`- "3100:3100"`,
+		`- "127.0.0.1:3101:3101/udp"`,
+		`- "127.0.0.1:8080:8080"`,
+		`source: ` + yamlString(filepath.Join(dir, "conf")),
+		`source: ` + yamlString(filepath.Join(dir, "chat logs")),
+		`http://127.0.0.1:3100/readyz`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("compose file missing %q:\n%s", want, got)
+		}
+	}
+
+	// Without a log folder there is nothing to mount for it.
+	got, err = composeFile(&AppConfig{ListenAddr: "localhost:3000"}, "config.json", "ghcr.io/example/rpcl:1.2", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, containerLogDir) || strings.Contains(got, "docker build") || !strings.Contains(got, `- "127.0.0.1:3000:3000"`) {
+		t.Errorf("unexpected compose file:\n%s", got)
+	}
+
+	if _, err := composeFile(&AppConfig{ListenAddr: "3000"}, "config.json", defaultDockerImage, time.Now()); err == nil {
+		t.Error("invalid listen address accepted")
+	}
+}
+
+func TestPerformUpdateInContainer(t *testing.T) {
+	u := &Updater{info: UpdateInfo{Available: true, DownloadURL: "http://example.invalid/x", Container: true}}
+	if err := u.PerformUpdate(); err == nil || !strings.Contains(err.Error(), "container") {
+		t.Errorf("PerformUpdate() = %v, want a container error", err)
+	}
+}
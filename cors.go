@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight
+// answer.
+const corsMaxAge = "600"
+
+// corsAllowHeaders are the request headers browser clients may send to
+// /message.
+var corsAllowHeaders = strings.Join([]string{"Content-Type", "Authorization", "X-API-Token", sourceHeader, traceHeader}, ", ")
+
+// validCORSOrigin reports whether origin is * or a bare scheme://host[:port]
+// origin as browsers send it.
+func validCORSOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+// corsAllowed reports whether a request from origin is allowed by the
+// configured origins. Origins compare without regard to case.
+func corsAllowed(allowed []string, origin string) bool {
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// withCORS lets pages from the configured CORSOrigins call next from the
+// browser, answering preflight requests itself. Requests from other
+// origins are passed on without CORS headers, so browsers keep the
+// response from the page; their preflights are refused.
+func (a *App) withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		a.configMu.RLock()
+		allowed := corsAllowed(a.config.CORSOrigins, origin)
+		a.configMu.RUnlock()
+
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !allowed {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if preflight {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", traceHeader)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidCORSOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"*", true},
+		{"https://overlay.example.com", true},
+		{"http://localhost:5173", true},
+		{"https://example.com/page", false},
+		{"example.com", false},
+		{"ftp://example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validCORSOrigin(tt.origin); got != tt.want {
+			t.Errorf("validCORSOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.CORSOrigins = []string{"https://overlay.example.com"}
+
+	called := false
+	handler := a.withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantAllowed string
+		wantCalled  bool
+	}{
+		{"no origin", "POST", "", false, http.StatusOK, "", true},
+		{"allowed origin", "POST", "https://OVERLAY.example.com", false, http.StatusOK, "https://OVERLAY.example.com", true},
+		{"other origin", "POST", "https://evil.example", false, http.StatusOK, "", true},
+		{"allowed preflight", "OPTIONS", "https://overlay.example.com", true, http.StatusNoContent, "https://overlay.example.com", false},
+		{"refused preflight", "OPTIONS", "https://evil.example", true, http.StatusForbidden, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			req := httptest.NewRequest(tt.method, "/message", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+				req.Header.Set("Access-Control-Request-Headers", "authorization")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowed {
+				t.Errorf("expected allowed origin %q, got %q", tt.wantAllowed, got)
+			}
+			if called != tt.wantCalled {
+				t.Errorf("expected handler called = %v", tt.wantCalled)
+			}
+			if tt.preflight && tt.wantAllowed != "" && rec.Header().Get("Access-Control-Allow-Headers") == "" {
+				t.Error("preflight answer is missing the allowed headers")
+			}
+		})
+	}
+
+	a.config.CORSOrigins = []string{"*"}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/message", nil)
+	req.Header.Set("Origin", "https://anything.example")
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://anything.example" {
+		t.Errorf("wildcard: expected the origin to be allowed, got %q", got)
+	}
+}
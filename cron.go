@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronShortcuts are the named schedules accepted in place of five fields.
+var cronShortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week (0 is Sunday).
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	anyDOM, anyDOW                bool
+}
+
+// parseCron parses a cron expression. Each field is "*", a number, a range
+// "a-b" or a list of those, optionally with a step ("*/15", "1-5/2").
+func parseCron(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if s, ok := cronShortcuts[spec]; ok {
+		spec = s
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q must have 5 fields (minute hour day month weekday)", spec)
+	}
+
+	limits := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]map[int]bool
+	for i, field := range fields {
+		set, err := parseCronField(field, limits[i][0], limits[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		sets[i] = set
+	}
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &cronSchedule{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		anyDOM: fields[2] == "*", anyDOW: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField expands one field into the set of values it matches.
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if base, s, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("bad step in %q", part)
+			}
+			part, step = base, n
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			from, to, isRange := strings.Cut(part, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("bad value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("bad value %q", part)
+				}
+			}
+			if lo < min || hi > max || lo > hi {
+				return nil, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether the schedule fires in the minute containing t.
+// As in cron, when both day of month and day of week are restricted a day
+// matching either one fires.
+func (c *cronSchedule) matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	domMatch := c.dom[t.Day()]
+	dowMatch := c.dow[int(t.Weekday())]
+	switch {
+	case c.anyDOM && c.anyDOW:
+		return true
+	case c.anyDOM:
+		return dowMatch
+	case c.anyDOW:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
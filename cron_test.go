@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@yearly"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) should fail", spec)
+		}
+	}
+}
+
+func TestCronSchedule_Matches(t *testing.T) {
+	// 2026-10-17 is a Saturday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 30, 0, time.Local)
+	}
+
+	tests := []struct {
+		spec string
+		t    time.Time
+		want bool
+	}{
+		{"0 3 * * *", at(17, 3, 0), true},
+		{"0 3 * * *", at(17, 3, 1), false},
+		{"@daily", at(17, 0, 0), true},
+		{"@hourly", at(17, 14, 0), true},
+		{"*/15 * * * *", at(17, 9, 45), true},
+		{"*/15 * * * *", at(17, 9, 50), false},
+		{"0 9-17/4 * * *", at(17, 13, 0), true},
+		{"0 9-17/4 * * *", at(17, 15, 0), false},
+		{"0 0 * * 6", at(17, 0, 0), true},
+		{"0 0 * * 1-5", at(17, 0, 0), false},
+		{"0 0 * * 0", at(18, 0, 0), true},
+		{"0 0 * * 7", at(18, 0, 0), true},
+		{"0 0 1,15 * *", at(15, 0, 0), true},
+		{"0 0 1 * 6", at(17, 0, 0), true}, // day of month or weekday
+		{"0 0 1 * 1", at(17, 0, 0), false},
+		{"0 0 * 11 *", at(17, 0, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := parseCron(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.matches(tt.t); got != tt.want {
+				t.Errorf("matches(%s) = %v, want %v", tt.t.Format("Mon 02 15:04"), got, tt.want)
+			}
+		})
+	}
+}
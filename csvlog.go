@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CSV delimiters, named for the config since a tab is awkward to type.
+const (
+	csvDelimiterComma     = "comma"
+	csvDelimiterSemicolon = "semicolon"
+	csvDelimiterTab       = "tab"
+)
+
+// utf8BOM marks a file as UTF-8 for Excel, which otherwise opens CSV
+// files in the system code page.
+const utf8BOM = "\ufeff"
+
+// csvFormulaPrefixes start cells that spreadsheets evaluate as formulas.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// logLayout is how entries are written to a log file: the text line
+// template for txt and docx logs, and the delimiter and byte order mark of
+// new CSV logs.
+type logLayout struct {
+	Line     string
+	CSVComma rune // 0 means ','
+	CSVBOM   bool
+}
+
+// layoutOf returns the log layout configured in cfg.
+func layoutOf(cfg *AppConfig) logLayout {
+	layout := logLayout{Line: cfg.TextTemplate, CSVBOM: cfg.CSVBOM}
+	switch cfg.CSVDelimiter {
+	case csvDelimiterSemicolon:
+		layout.CSVComma = ';'
+	case csvDelimiterTab:
+		layout.CSVComma = '\t'
+	}
+	return layout
+}
+
+// escapeCSVCell prefixes a cell that a spreadsheet would run as a formula
+// with a quote, so a message like "=HYPERLINK(...)" shows as text. Cells
+// already starting with quotes before such a character get one more, so
+// unescapeCSVCell restores every cell exactly.
+func escapeCSVCell(s string) string {
+	if t := strings.TrimLeft(s, "'"); t != "" && strings.ContainsRune(csvFormulaPrefixes, rune(t[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeCSVCell undoes escapeCSVCell.
+func unescapeCSVCell(s string) string {
+	if t := strings.TrimLeft(s, "'"); len(t) < len(s) && t != "" && strings.ContainsRune(csvFormulaPrefixes, rune(t[0])) {
+		return s[1:]
+	}
+	return s
+}
+
+// csvComma guesses the delimiter of a CSV file from its first line: the
+// most frequent of comma, semicolon and tab outside quotes.
+func csvComma(head []byte) rune {
+	head = bytes.TrimPrefix(head, []byte(utf8BOM))
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+	counts := map[rune]int{}
+	quoted := false
+	for _, c := range string(head) {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ',' || c == ';' || c == '\t'):
+			counts[c]++
+		}
+	}
+	comma := ','
+	for _, c := range []rune{';', '\t'} {
+		if counts[c] > counts[comma] {
+			comma = c
+		}
+	}
+	return comma
+}
+
+// encodeCSVEntry returns the CSV row of entry for a file that starts with
+// head, preceded by the header row (and byte order mark, if configured)
+// when the file is empty. Rows added to an existing file use its
+// delimiter, so changing the setting doesn't mix delimiters in one file.
+func encodeCSVEntry(head []byte, layout logLayout, entry LogEntry) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if len(head) > 0 {
+		w.Comma = csvComma(head)
+	} else {
+		if layout.CSVComma != 0 {
+			w.Comma = layout.CSVComma
+		}
+		if layout.CSVBOM {
+			b.WriteString(utf8BOM)
+		}
+		w.Write(csvHeader)
+	}
+	w.Write(csvRow(entry))
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("writing csv row: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// readHead returns the first bytes of file, enough to hold a CSV header.
+func readHead(file *os.File) ([]byte, error) {
+	head := make([]byte, 512)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// newCSVReader returns a reader for CSV data in any delimiter this app
+// writes, skipping a byte order mark. Records may vary in length.
+func newCSVReader(r io.Reader) *csv.Reader {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(br.Size())
+	if bytes.HasPrefix(head, []byte(utf8BOM)) {
+		br.Discard(len(utf8BOM))
+	}
+	reader := csv.NewReader(br)
+	reader.Comma = csvComma(head)
+	reader.FieldsPerRecord = -1
+	return reader
+}
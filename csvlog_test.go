@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEscapeCSVCell(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"hello", "hello"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+1", "'+1"},
+		{"-waves-", "'-waves-"},
+		{"@everyone", "'@everyone"},
+		{"\tcmd", "'\tcmd"},
+		{"'=already", "''=already"},
+		{"'quoted'", "'quoted'"},
+		{"'", "'"},
+		{"", ""},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		got := escapeCSVCell(tt.in)
+		if got != tt.want {
+			t.Errorf("escapeCSVCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if back := unescapeCSVCell(got); back != tt.in {
+			t.Errorf("unescapeCSVCell(%q) = %q, want %q", got, back, tt.in)
+		}
+	}
+}
+
+func TestCSVComma(t *testing.T) {
+	tests := []struct {
+		head string
+		want rune
+	}{
+		{"Timestamp,Sender,Message\n", ','},
+		{utf8BOM + "Timestamp;Sender;Message\n", ';'},
+		{"Timestamp\tSender\tMessage", '\t'},
+		{`"a;b;c",d` + "\n", ','},
+		{"", ','},
+	}
+	for _, tt := range tests {
+		if got := csvComma([]byte(tt.head)); got != tt.want {
+			t.Errorf("csvComma(%q) = %q, want %q", tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestCSVLog_LayoutRoundTrip(t *testing.T) {
+	entries := []LogEntry{
+		{Timestamp: "2026-10-18 12:00:00", Sender: "=Conan", Message: "-1 gold; by Crom"},
+		{Timestamp: "2026-10-18 12:00:01", Sender: "Bêlit", Message: "'=not a formula"},
+	}
+	layouts := []logLayout{{}, {CSVComma: ';', CSVBOM: true}, {CSVComma: '\t'}}
+	for _, layout := range layouts {
+		for _, buffered := range []bool{false, true} {
+			filename := filepath.Join(t.TempDir(), "log.csv")
+			write := writeLogEntry
+			if buffered {
+				write = writeLogEntryBuffered
+			}
+			for _, entry := range entries {
+				if err := write(filename, "csv", layout, entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			flushLogs()
+
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.HasPrefix(string(data), utf8BOM); got != layout.CSVBOM {
+				t.Errorf("%+v: BOM = %v", layout, got)
+			}
+			if !strings.Contains(string(data), "'=Conan") {
+				t.Errorf("%+v: formula not escaped in %q", layout, data)
+			}
+			got, err := readLogFile(filename, "csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(entries) {
+				t.Fatalf("%+v: read %d entries, want %d", layout, len(got), len(entries))
+			}
+			for i := range entries {
+				if got[i].Sender != entries[i].Sender || got[i].Message != entries[i].Message {
+					t.Errorf("%+v: entry %d = %+v, want %+v", layout, i, got[i], entries[i])
+				}
+			}
+		}
+	}
+}
+
+func TestLogToCsv_KeepsFileDelimiter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.csv")
+	entry := LogEntry{Timestamp: "2026-10-18 12:00:00", Sender: "Conan", Message: "By Crom"}
+	if err := writeLogEntry(filename, "csv", logLayout{CSVComma: ';'}, entry); err != nil {
+		t.Fatal(err)
+	}
+	// The setting changed; the file keeps its semicolons.
+	if err := writeLogEntry(filename, "csv", logLayout{}, entry); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Timestamp;Sender;Message;Type;Session;Source\n2026-10-18 12:00:00;Conan;By Crom;say;;\n2026-10-18 12:00:00;Conan;By Crom;say;;\n"
+	if got := strings.ReplaceAll(string(data), "\r\n", "\n"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseImportCSV_SemicolonBOM(t *testing.T) {
+	in := utf8BOM + "Timestamp;Sender;Message\n2026-10-18 12:00:00;Conan;'=1+1\n"
+	entries, err := parseImportCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Sender != "Conan" || entries[0].Message != "=1+1" {
+		t.Errorf("entries = %+v", entries)
+	}
+}
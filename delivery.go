@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// What a full delivery queue drops to take a new delivery: the oldest one
+// still waiting, or the new one.
+const (
+	deliveryDropOldest = "oldest"
+	deliveryDropNewest = "newest"
+)
+
+const (
+	// defaultDeliveryQueueSize is how many deliveries may wait at once
+	// when DeliveryQueueSize is 0.
+	defaultDeliveryQueueSize = 10000
+	maxDeliveryQueueSize     = 1000000
+	// deliveryDropWarnEvery limits the warnings about dropped deliveries.
+	deliveryDropWarnEvery = time.Minute
+)
+
+// deliveryJob is one delivery of a message to a sink. drop is called
+// instead of deliver when the queue is full and the job is dropped.
+type deliveryJob struct {
+	deliver func()
+	drop    func()
+	seq     uint64 // order of arrival, to find the oldest
+}
+
+// deliveryLanes runs sink deliveries off the ingestion path, one lane per
+// destination. Each lane delivers its jobs in the order they were added,
+// while different destinations deliver in parallel, so a slow webhook
+// neither delays the response to the game nor the other sinks. A lane's
+// goroutine exits once the lane is empty. The jobs waiting in all lanes
+// are bounded by setLimit, so an outage can't queue without end. The zero
+// value is ready to use and unbounded.
+type deliveryLanes struct {
+	mu         sync.Mutex
+	lanes      map[string][]deliveryJob
+	seq        uint64
+	queued     int           // jobs waiting in lanes
+	pending    int           // jobs waiting or running
+	idle       chan struct{} // closed when pending drops to 0
+	capacity   int           // 0 means unbounded
+	dropNewest bool
+	dropped    uint64
+	warned     time.Time
+}
+
+// deliveryStats describes the delivery queue for health checks and metrics.
+type deliveryStats struct {
+	Depth        int    `json:"depth"`    // deliveries waiting or running
+	Capacity     int    `json:"capacity"` // most deliveries that may wait
+	Dropped      uint64 `json:"dropped"`  // dropped because the queue was full
+	Destinations int    `json:"destinations"`
+}
+
+// setLimit bounds the deliveries waiting at once to capacity (0 for the
+// default) and sets what is dropped when they reach it.
+func (d *deliveryLanes) setLimit(capacity int, policy string) {
+	if capacity <= 0 {
+		capacity = defaultDeliveryQueueSize
+	}
+	d.mu.Lock()
+	d.capacity = capacity
+	d.dropNewest = policy == deliveryDropNewest
+	d.mu.Unlock()
+}
+
+// add queues job on the lane for dest, starting the lane if it is idle.
+// When the queue is full, either job or the oldest waiting job is dropped.
+func (d *deliveryLanes) add(dest string, job deliveryJob) {
+	d.mu.Lock()
+	if d.lanes == nil {
+		d.lanes = make(map[string][]deliveryJob)
+	}
+	if d.capacity > 0 && d.queued >= d.capacity {
+		d.dropped++
+		if d.dropNewest {
+			d.mu.Unlock()
+			job.drop()
+			return
+		}
+		victim := d.removeOldest()
+		defer victim.drop()
+	}
+	if d.pending == 0 {
+		d.idle = make(chan struct{})
+	}
+	d.pending++
+	d.queued++
+	d.seq++
+	job.seq = d.seq
+	jobs, running := d.lanes[dest]
+	d.lanes[dest] = append(jobs, job)
+	if !running {
+		go d.run(dest)
+	}
+	d.mu.Unlock()
+}
+
+// removeOldest takes the longest-waiting job off its lane. The queue must
+// not be empty; d.mu must be held.
+func (d *deliveryLanes) removeOldest() deliveryJob {
+	oldest := ""
+	for dest, jobs := range d.lanes {
+		if len(jobs) > 0 && (oldest == "" || jobs[0].seq < d.lanes[oldest][0].seq) {
+			oldest = dest
+		}
+	}
+	job := d.lanes[oldest][0]
+	d.lanes[oldest] = d.lanes[oldest][1:]
+	d.queued--
+	d.done()
+	return job
+}
+
+// done counts a job as finished; d.mu must be held.
+func (d *deliveryLanes) done() {
+	d.pending--
+	if d.pending == 0 {
+		close(d.idle)
+	}
+}
+
+// run delivers the jobs on dest's lane until it is empty.
+func (d *deliveryLanes) run(dest string) {
+	for {
+		d.mu.Lock()
+		jobs := d.lanes[dest]
+		if len(jobs) == 0 {
+			delete(d.lanes, dest)
+			d.mu.Unlock()
+			return
+		}
+		job := jobs[0]
+		d.lanes[dest] = jobs[1:]
+		d.queued--
+		d.mu.Unlock()
+
+		job.deliver()
+
+		d.mu.Lock()
+		d.done()
+		d.mu.Unlock()
+	}
+}
+
+// size returns the number of deliveries waiting or running.
+func (d *deliveryLanes) size() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.pending
+}
+
+func (d *deliveryLanes) stats() deliveryStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return deliveryStats{Depth: d.pending, Capacity: d.capacity, Dropped: d.dropped, Destinations: len(d.lanes)}
+}
+
+// shouldWarn reports whether a dropped delivery should be logged, at most
+// once per deliveryDropWarnEvery.
+func (d *deliveryLanes) shouldWarn(now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if now.Sub(d.warned) < deliveryDropWarnEvery {
+		return false
+	}
+	d.warned = now
+	return true
+}
+
+// wait blocks until every delivery added so far is done, or ctx ends.
+func (d *deliveryLanes) wait(ctx context.Context) error {
+	d.mu.Lock()
+	if d.pending == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	idle := d.idle
+	d.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
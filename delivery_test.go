@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testJob is a delivery that can't be dropped.
+func testJob(deliver func()) deliveryJob {
+	return deliveryJob{deliver: deliver, drop: func() { panic("dropped") }}
+}
+
+func TestDeliveryLanes_OrderedPerDestination(t *testing.T) {
+	var d deliveryLanes
+	var mu sync.Mutex
+	got := map[string][]int{}
+	for i := range 50 {
+		for _, dest := range []string{"a", "b"} {
+			d.add(dest, testJob(func() {
+				mu.Lock()
+				got[dest] = append(got[dest], i)
+				mu.Unlock()
+			}))
+		}
+	}
+	if err := d.wait(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	for _, dest := range []string{"a", "b"} {
+		if len(got[dest]) != 50 || !slices.IsSorted(got[dest]) {
+			t.Errorf("lane %s delivered %v, want 0..49 in order", dest, got[dest])
+		}
+	}
+	if n := d.size(); n != 0 {
+		t.Errorf("size after wait = %d, want 0", n)
+	}
+}
+
+func TestDeliveryLanes_SlowDestinationDoesNotBlockOthers(t *testing.T) {
+	var d deliveryLanes
+	release := make(chan struct{})
+	d.add("slow", testJob(func() { <-release }))
+	done := make(chan struct{})
+	d.add("fast", testJob(func() { close(done) }))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fast lane waited for the slow one")
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.wait(ctx); err == nil {
+		t.Error("wait returned while a delivery was still running")
+	}
+	if n := d.size(); n != 1 {
+		t.Errorf("size = %d, want 1", n)
+	}
+	close(release)
+	if err := d.wait(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeliveryLanes_DropPolicy(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantDropped []string
+		wantSent    []string
+	}{
+		{deliveryDropOldest, []string{"a1", "b1"}, []string{"a2", "b2"}},
+		{deliveryDropNewest, []string{"a2", "b2"}, []string{"a1", "b1"}},
+	}
+	for _, tt := range tests {
+		var d deliveryLanes
+		d.setLimit(2, tt.policy)
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var dropped, sent []string
+		job := func(name string) deliveryJob {
+			return deliveryJob{
+				deliver: func() {
+					mu.Lock()
+					sent = append(sent, name)
+					mu.Unlock()
+				},
+				drop: func() {
+					mu.Lock()
+					dropped = append(dropped, name)
+					mu.Unlock()
+				},
+			}
+		}
+		// Each lane is busy, so the rest waits.
+		d.add("a", testJob(func() { <-release }))
+		d.add("b", testJob(func() { <-release }))
+		waitUntil(t, func() bool { return d.stats().Depth == 2 && d.queuedJobs() == 0 })
+		for _, name := range []string{"a1", "b1", "a2", "b2"} {
+			d.add(name[:1], job(name))
+		}
+		close(release)
+		if err := d.wait(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(sent)
+		if !slices.Equal(dropped, tt.wantDropped) || !slices.Equal(sent, tt.wantSent) {
+			t.Errorf("%s: dropped %v, sent %v; want %v, %v", tt.policy, dropped, sent, tt.wantDropped, tt.wantSent)
+		}
+		if stats := d.stats(); stats.Dropped != 2 || stats.Capacity != 2 {
+			t.Errorf("%s: stats %+v", tt.policy, stats)
+		}
+	}
+}
+
+// queuedJobs returns the number of jobs waiting, not running.
+func (d *deliveryLanes) queuedJobs() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.queued
+}
+
+// waitUntil polls cond for up to five seconds.
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
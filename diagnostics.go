@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// diagnosticTimeout bounds each self-test.
+const diagnosticTimeout = 15 * time.Second
+
+// Self-tests run from the web UI so setup problems show up before a
+// session rather than during one. Each handler answers with an alert for
+// HTMX; failures are reported with status 200 so the alert is swapped in.
+
+// handleTestDiscord posts a test notice to the configured Discord channel.
+func (a *App) handleTestDiscord(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	target := discordTarget(&cfg)
+	if target == "" {
+		writeTestResult(w, fmt.Errorf("no Discord webhook or bot channel configured"), "")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), diagnosticTimeout)
+	defer cancel()
+	err := sendDiscordNotice(ctx, target, "--- RP Chat Logger test message: Discord notifications are working ---")
+	if err == nil {
+		a.discordQueue.markSent()
+	}
+	writeTestResult(w, err, "Test message posted to Discord.")
+}
+
+// handleTestFile writes a test line to the log path and removes it again.
+func (a *App) handleTestFile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	a.configMu.RLock()
+	path := a.config.Path
+	a.configMu.RUnlock()
+
+	if path == "" {
+		writeTestResult(w, fmt.Errorf("no log path configured"), "")
+		return
+	}
+	writeTestResult(w, checkWritable(path), "Log path "+path+" is writable.")
+}
+
+// handleTestIngestion posts a synthetic message to the running ingestion
+// server in the configured input format. The message goes through the
+// normal pipeline, so it is delivered and logged like any other.
+func (a *App) handleTestIngestion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	if !a.ingestionRunning.Load() {
+		writeTestResult(w, fmt.Errorf("the ingestion server is not running"), "")
+		return
+	}
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), diagnosticTimeout)
+	defer cancel()
+	id, err := postTestMessage(ctx, &cfg, a.tokens.self())
+	success := "Ingestion server accepted the test message."
+	if id != "" {
+		success = fmt.Sprintf("Ingestion server accepted the test message (receipt %s).", id)
+	}
+	writeTestResult(w, err, success)
+}
+
+// postTestMessage sends a test message to the ingestion server's /message
+// endpoint using the field names of the configured input preset, and
+// returns the receipt ID from the response, if any. token, when set, is
+// sent for servers that require an API token.
+func postTestMessage(ctx context.Context, cfg *AppConfig, token string) (string, error) {
+	addr, err := tunnelLocalAddr(cfg.ListenAddr)
+	if err != nil {
+		return "", err
+	}
+	senderField, messageField := "sender", "message"
+	if adapter, ok := inputAdapterFor(cfg).(fieldAdapter); ok {
+		senderField, messageField = adapter.sender[0], adapter.message
+	}
+	query := url.Values{
+		senderField:  {"RP Chat Logger"},
+		messageField: {"Ingestion test message"},
+	}
+	target := "http://" + addr + "/message?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating test request: %w", err)
+	}
+	req.Header.Set("User-Agent", "rp-chat-logger/"+Version)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := forwardClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("posting test message: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ingestion server returned status %d", resp.StatusCode)
+	}
+	var body struct {
+		Status string `json:"status"`
+		ID     string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Status != "ok" {
+		return "", fmt.Errorf("unexpected response from %s; is another program using that port?", addr)
+	}
+	return body.ID, nil
+}
+
+func writeTestResult(w http.ResponseWriter, err error, success string) {
+	if err != nil {
+		fmt.Fprintf(w, `<div class="alert error">Test failed: %s</div>`, template.HTMLEscapeString(redactSecrets(err.Error())))
+		return
+	}
+	fmt.Fprintf(w, `<div class="alert success">%s</div>`, template.HTMLEscapeString(success))
+}
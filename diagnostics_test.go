@@ -0,0 +1,167 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleTestDiscord(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+		if strings.Contains(r.URL.Path, "broken") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		config AppConfig
+		want   string
+	}{
+		{"posted", AppConfig{EnableDiscord: true, WebhookURL: srv.URL + "/webhook"}, "Test message posted to Discord"},
+		{"not configured", AppConfig{}, "no Discord webhook or bot channel configured"},
+		{"rejected", AppConfig{EnableDiscord: true, WebhookURL: srv.URL + "/broken"}, "Test failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := setupTestApp()
+			defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+			*a.config = tt.config
+			got = ""
+
+			rec := httptest.NewRecorder()
+			a.handleTestDiscord(rec, httptest.NewRequest("POST", "/api/test/discord", nil))
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("expected %q in response, got %q", tt.want, rec.Body.String())
+			}
+			if tt.name == "posted" && !strings.Contains(got, "test message") {
+				t.Errorf("expected the test notice to be posted, got %q", got)
+			}
+		})
+	}
+}
+
+func TestHandleTestFile(t *testing.T) {
+	dir := t.TempDir()
+	blocked := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"writable", filepath.Join(dir, "logs"), "is writable"},
+		{"not configured", "", "no log path configured"},
+		{"not a directory", filepath.Join(blocked, "logs"), "Test failed: creating log directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := setupTestApp()
+			defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+			a.config.Path = tt.path
+
+			rec := httptest.NewRecorder()
+			a.handleTestFile(rec, httptest.NewRequest("POST", "/api/test/file", nil))
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("expected %q in response, got %q", tt.want, rec.Body.String())
+			}
+		})
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "logs")); len(entries) != 0 {
+		t.Errorf("expected the test file to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestHandleTestIngestion(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset string
+		fields []string
+		want   url.Values
+	}{
+		{"conan", "", nil, url.Values{"sender": {"RP Chat Logger"}, "message": {"Ingestion test message"}}},
+		{"ark", presetARK, nil, url.Values{"CharacterName": {"RP Chat Logger"}, "Message": {"Ingestion test message"}}},
+		{"generic", presetGeneric, []string{"who", "text"}, url.Values{"who": {"RP Chat Logger"}, "text": {"Ingestion test message"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.URL.Query()
+				io.WriteString(w, `{"id":"abc123","status":"ok"}`)
+			}))
+			defer srv.Close()
+
+			a := setupTestApp()
+			defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+			a.config.ListenAddr = srv.Listener.Addr().String()
+			a.config.InputPreset = tt.preset
+			a.config.InputFields = tt.fields
+			a.ingestionRunning.Store(true)
+
+			rec := httptest.NewRecorder()
+			a.handleTestIngestion(rec, httptest.NewRequest("POST", "/api/test/ingestion", nil))
+			if !strings.Contains(rec.Body.String(), "accepted the test message (receipt abc123)") {
+				t.Errorf("unexpected response %q", rec.Body.String())
+			}
+			for k := range tt.want {
+				if got.Get(k) != tt.want.Get(k) {
+					t.Errorf("expected %s=%q, got %q", k, tt.want.Get(k), got.Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestHandleTestIngestion_Failures(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<html>some other app</html>")
+	}))
+	defer other.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		addr    string
+		running bool
+		want    string
+	}{
+		{"stopped", "127.0.0.1:3000", false, "the ingestion server is not running"},
+		{"other program", other.Listener.Addr().String(), true, "is another program using that port?"},
+		{"unreachable", closedAddr, true, "posting test message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := setupTestApp()
+			defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+			a.config.ListenAddr = tt.addr
+			a.ingestionRunning.Store(tt.running)
+
+			rec := httptest.NewRecorder()
+			a.handleTestIngestion(rec, httptest.NewRequest("POST", "/api/test/ingestion", nil))
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("expected %q in response, got %q", tt.want, rec.Body.String())
+			}
+		})
+	}
+}
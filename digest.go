@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultDigestTime is used when the digest is enabled without a time.
+const defaultDigestTime = "23:55"
+
+// runDigestScheduler checks once a minute whether the daily digest is due.
+// The config is read on every tick so changes apply without a restart.
+func (a *App) runDigestScheduler() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-ticker.C:
+			if a.digestDue(now) {
+				a.postDigest(now)
+			}
+		}
+	}
+}
+
+// digestDue reports whether today's digest time has passed and no digest
+// has been posted for today yet.
+func (a *App) digestDue(now time.Time) bool {
+	a.configMu.RLock()
+	enabled := a.config.EnableDigest || a.config.EnableEmailDigest
+	digestTime := a.config.DigestTime
+	lastDigest := a.config.LastDigest
+	a.configMu.RUnlock()
+
+	if !enabled {
+		return false
+	}
+	if digestTime == "" {
+		digestTime = defaultDigestTime
+	}
+	at, err := time.Parse("15:04", digestTime)
+	if err != nil {
+		return false
+	}
+	scheduled := truncateToDay(now).Add(time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute)
+	return !now.Before(scheduled) && lastDigest != now.Format("2006-01-02")
+}
+
+// postDigest posts a summary of the 24 hours before now to Discord and/or
+// emails it, with the transcripts covering that window attached, then
+// records the run in the config so a restart doesn't send the same digest
+// twice.
+func (a *App) postDigest(now time.Time) {
+	a.configMu.Lock()
+	a.config.LastDigest = now.Format("2006-01-02")
+	cfg := *a.config
+	a.configMu.Unlock()
+
+	if err := saveConfiguration(&cfg); err != nil {
+		a.logger.Log("error", fmt.Sprintf("Failed to save digest state: %v", err))
+	}
+
+	from := now.Add(-24 * time.Hour)
+	entries, files, err := entriesBetween(&cfg, from, now)
+	if err != nil {
+		a.logger.Log("error", fmt.Sprintf("Daily digest failed: %v", err))
+		return
+	}
+	if len(entries) == 0 {
+		a.logger.Log("info", "Daily digest skipped: no messages in the last 24 hours")
+		return
+	}
+
+	content := formatDigest(aggregateEntries(entries), now)
+	if cfg.EnableDigest {
+		a.postDigestToDiscord(&cfg, content, files, len(entries))
+	}
+	if cfg.EnableEmailDigest {
+		a.emailDigest(&cfg, content, files, len(entries), now)
+	}
+}
+
+// postDigestToDiscord posts the digest to the digest webhook, attaching
+// the transcripts when they fit.
+func (a *App) postDigestToDiscord(cfg *AppConfig, content string, files []string, messages int) {
+	webhookURL := cfg.DigestWebhookURL
+	if webhookURL == "" {
+		webhookURL = discordTarget(cfg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	var err error
+	if attachmentsSize(files) <= discordAttachmentLimit {
+		err = sendDiscordFiles(ctx, webhookURL, content, files)
+	} else {
+		err = sendDiscordNotice(ctx, webhookURL, content+"\n_Transcript too large to attach._")
+	}
+	if err != nil {
+		a.logger.Log("error", fmt.Sprintf("Daily digest failed: %v", err))
+		a.logger.LogFailure("digest", content, "discord", err.Error())
+		return
+	}
+	a.logger.Log("info", fmt.Sprintf("Daily digest posted (%d messages)", messages))
+}
+
+// emailDigest emails the digest to the configured recipients, attaching
+// the transcripts if enabled and they fit.
+func (a *App) emailDigest(cfg *AppConfig, content string, files []string, messages int, now time.Time) {
+	body := strings.ReplaceAll(content, "**", "")
+	var attachments []string
+	if cfg.EmailAttachTranscripts {
+		if attachmentsSize(files) <= emailAttachmentLimit {
+			attachments = files
+		} else {
+			body += "\nTranscript too large to attach.\n"
+		}
+	}
+
+	subject := "RP chat digest " + now.Format("2006-01-02")
+	if err := sendEmail(cfg, subject, body, attachments); err != nil {
+		a.logger.Log("error", fmt.Sprintf("Digest email failed: %v", err))
+		a.logger.LogFailure("digest", content, "email", err.Error())
+		return
+	}
+	a.logger.Log("info", fmt.Sprintf("Digest emailed to %d recipient(s) (%d messages)", len(cfg.EmailTo), messages))
+}
+
+// formatDigest renders the digest message posted to Discord.
+func formatDigest(stats LogStats, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**Daily digest — %s**\n", now.Format("2006-01-02"))
+	fmt.Fprintf(&b, "Messages: %d\n", stats.Messages)
+
+	top := stats.TopSenders(10)
+	names := make([]string, 0, len(top))
+	for _, sc := range top {
+		names = append(names, fmt.Sprintf("%s (%d)", sc.Sender, sc.Count))
+	}
+	fmt.Fprintf(&b, "Active senders (%d): %s", len(stats.Senders), strings.Join(names, ", "))
+	if len(stats.Senders) > len(top) {
+		fmt.Fprintf(&b, ", and %d more", len(stats.Senders)-len(top))
+	}
+	b.WriteString("\n")
+
+	if !stats.First.IsZero() {
+		fmt.Fprintf(&b, "First message: %s · Last message: %s\n",
+			stats.First.Format("Jan 2 15:04"), stats.Last.Format("Jan 2 15:04"))
+	}
+	return b.String()
+}
+
+// attachmentsSize returns the combined size of the given files in bytes.
+func attachmentsSize(paths []string) int64 {
+	var total int64
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestDue(t *testing.T) {
+	now := time.Date(2026, 10, 17, 23, 56, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		config   AppConfig
+		expected bool
+	}{
+		{name: "disabled", config: AppConfig{DigestTime: "23:55"}, expected: false},
+		{name: "time passed", config: AppConfig{EnableDigest: true, DigestTime: "23:55"}, expected: true},
+		{name: "time not reached", config: AppConfig{EnableDigest: true, DigestTime: "23:58"}, expected: false},
+		{name: "already posted today", config: AppConfig{EnableDigest: true, DigestTime: "23:55", LastDigest: "2026-10-17"}, expected: false},
+		{name: "posted yesterday", config: AppConfig{EnableDigest: true, DigestTime: "08:00", LastDigest: "2026-10-16"}, expected: true},
+		{name: "default time", config: AppConfig{EnableDigest: true}, expected: true},
+		{name: "email only", config: AppConfig{EnableEmailDigest: true, DigestTime: "23:55"}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.config
+			a := &App{config: &cfg}
+			if got := a.digestDue(now); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatDigest(t *testing.T) {
+	stats := aggregateEntries([]LogEntry{
+		{Timestamp: "2026-10-17 18:02:00", Sender: "Conan", Message: "Hi"},
+		{Timestamp: "2026-10-17 23:41:00", Sender: "Valeria", Message: "Bye"},
+		{Timestamp: "2026-10-17 20:00:00", Sender: "Conan", Message: "Hm"},
+	})
+	got := formatDigest(stats, time.Date(2026, 10, 17, 23, 55, 0, 0, time.Local))
+
+	for _, want := range []string{
+		"Daily digest — 2026-10-17",
+		"Messages: 3",
+		"Active senders (2): Conan (2), Valeria (1)",
+		"First message: Oct 17 18:02 · Last message: Oct 17 23:41",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected digest to contain %q, got:\n%s", want, got)
+		}
+	}
+}
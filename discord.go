@@ -5,8 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,17 +20,137 @@ import (
 
 const discordMessageLimit = 2000
 
+// maxDiscordAttachAfter bounds DiscordAttachAfter.
+const maxDiscordAttachAfter = 50
+
+// discordClient's timeout is set by outbound. Requests held back by the
+// pacer don't reach the circuit breaker.
 var discordClient = &http.Client{
-	Timeout: 10 * time.Second,
+	Transport: &discordPacer{base: &breakerTransport{breaker: discordCircuit, base: &statsTransport{stats: discordStats, base: outbound}}},
+}
+
+// DiscordAuthor overrides the webhook's default name and avatar for a post.
+// Empty fields fall back to the webhook's own settings.
+type DiscordAuthor struct {
+	Username  string
+	AvatarURL string
+}
+
+// discordAuthorFor returns the author override for a sender, or a zero
+// DiscordAuthor when senders should not be shown as individual authors.
+// A sender that is a character posts under its display name and avatar.
+func discordAuthorFor(cfg *AppConfig, sender string) DiscordAuthor {
+	if !cfg.SenderAsAuthor {
+		return DiscordAuthor{}
+	}
+	author := DiscordAuthor{AvatarURL: cfg.Avatars[sender]}
+	if ch, ok := findCharacter(cfg, sender); ok {
+		sender = ch.Name
+		switch {
+		case ch.AvatarURL != "":
+			author.AvatarURL = ch.AvatarURL
+		case author.AvatarURL == "":
+			author.AvatarURL = cfg.Avatars[ch.Name]
+		}
+	}
+	// Discord rejects usernames containing these words; keep the webhook's
+	// default name and show the sender in the message body instead.
+	lower := strings.ToLower(sender)
+	if !strings.Contains(lower, "discord") && !strings.Contains(lower, "clyde") {
+		author.Username = truncateMessage(sender, maxCharacterName)
+	}
+	return author
+}
+
+// discordContent returns the message body as it should appear in Discord.
+// Emotes are rendered in italics.
+func discordContent(cfg *AppConfig, message string) string {
+	if detectOOC(cfg, message) {
+		return discordText(cfg, message)
+	}
+	if text, ok := detectEmote(cfg, message); ok {
+		return "*" + discordText(cfg, text) + "*"
+	}
+	return discordText(cfg, message)
+}
+
+// How player markdown is posted to Discord: escaped (the default), or as
+// is.
+const (
+	discordMarkdownEscape      = "escape"
+	discordMarkdownPassthrough = "passthrough"
+)
+
+// discordText returns player text as Discord should show it, escaping its
+// markdown unless the config passes it through, and converting rich-text
+// tags to markdown when the config asks for it.
+func discordText(cfg *AppConfig, text string) string {
+	escape := escapeDiscordMarkdown
+	if cfg.DiscordMarkdown == discordMarkdownPassthrough {
+		escape = func(s string) string { return s }
+	}
+	if cfg.RichText == richTextMarkdown {
+		return richTextToMarkdown(text, escape)
+	}
+	return escape(text)
+}
+
+// discordURL matches the URLs escapeDiscordMarkdown leaves alone, so they
+// still link.
+var discordURL = regexp.MustCompile(`https?://[^\s<>]+`)
+
+// escapeDiscordMarkdown backslash-escapes the characters Discord reads as
+// markdown, so text shows as typed: emphasis, spoilers, code, masked links,
+// and quotes, headings and lists at the start of a line. URLs are kept.
+func escapeDiscordMarkdown(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range discordURL.FindAllStringIndex(s, -1) {
+		escapeMarkdownText(&b, s[last:loc[0]], last == 0 || s[last-1] == '\n')
+		b.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	escapeMarkdownText(&b, s[last:], last == 0 || s[last-1] == '\n')
+	return b.String()
+}
+
+// escapeMarkdownText writes s to b escaped; lineStart tells whether s
+// begins a line.
+func escapeMarkdownText(b *strings.Builder, s string, lineStart bool) {
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("\\*_~`|[]", r):
+			b.WriteByte('\\')
+		case lineStart && strings.ContainsRune(">#-", r):
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+		if r == '\n' {
+			lineStart = true
+		} else if r != ' ' && r != '\t' {
+			lineStart = false
+		}
+	}
 }
 
 // QueuedMessage represents a message waiting to be sent to Discord.
 type QueuedMessage struct {
-	WebhookURL string
-	Sender     string
-	Message    string
-	RetryAt    time.Time
-	Attempts   int
+	ID          string // receipt ID of the original message, if tracked
+	Trace       string // trace ID of the original message
+	WebhookURL  string
+	Author      DiscordAuthor
+	Template    string // Discord template; empty for the default
+	AttachAfter int    // DiscordAttachAfter when the message was sent
+	Mentions    *allowedMentions
+	Sender      string
+	Message     string
+	Source      string
+	// Time is shown in the Discord header instead of the send time, for
+	// messages replayed from the logs.
+	Time     time.Time
+	RetryAt  time.Time
+	Attempts int
+	seq      uint64 // see numberQueued
 }
 
 // DiscordQueue manages rate-limited Discord messages with automatic retry.
@@ -35,16 +160,23 @@ type DiscordQueue struct {
 	notify     chan struct{}
 	done       chan struct{}
 	logger     *SSELogger
+	receipts   *receiptTable
 	maxRetries int
+	stopOnce   sync.Once
+	lastSent   time.Time
+	// pausedSince is when posting was paused; see SetPaused.
+	pausedSince time.Time
 }
 
-// NewDiscordQueue creates a new Discord message queue with background processing.
-func NewDiscordQueue(logger *SSELogger) *DiscordQueue {
+// NewDiscordQueue creates a new Discord message queue with background
+// processing. Delivery results are recorded in receipts, which may be nil.
+func NewDiscordQueue(logger *SSELogger, receipts *receiptTable) *DiscordQueue {
 	q := &DiscordQueue{
 		messages:   make([]QueuedMessage, 0),
 		notify:     make(chan struct{}, 1),
 		done:       make(chan struct{}),
 		logger:     logger,
+		receipts:   receipts,
 		maxRetries: 5,
 	}
 	go q.processLoop()
@@ -53,13 +185,14 @@ func NewDiscordQueue(logger *SSELogger) *DiscordQueue {
 
 // Add queues a message for sending to Discord.
 func (q *DiscordQueue) Add(msg QueuedMessage) {
+	numberQueued(&msg)
 	q.mu.Lock()
 	q.messages = append(q.messages, msg)
 	count := len(q.messages)
 	q.mu.Unlock()
 
 	if q.logger != nil {
-		q.logger.Log("info", fmt.Sprintf("Message queued for Discord retry (queue size: %d)", count))
+		q.logger.Log("info", traced(msg.Trace, fmt.Sprintf("Message queued for Discord retry (queue size: %d)", count)))
 	}
 
 	// Non-blocking notify
@@ -69,6 +202,29 @@ func (q *DiscordQueue) Add(msg QueuedMessage) {
 	}
 }
 
+// AddBatch queues several messages at once, keeping their order.
+func (q *DiscordQueue) AddBatch(msgs []QueuedMessage) {
+	if len(msgs) == 0 {
+		return
+	}
+	for i := range msgs {
+		numberQueued(&msgs[i])
+	}
+	q.mu.Lock()
+	q.messages = append(q.messages, msgs...)
+	count := len(q.messages)
+	q.mu.Unlock()
+
+	if q.logger != nil {
+		q.logger.Log("info", fmt.Sprintf("%d message(s) queued for Discord (queue size: %d)", len(msgs), count))
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
 // QueueSize returns the current number of queued messages.
 func (q *DiscordQueue) QueueSize() int {
 	q.mu.Lock()
@@ -76,9 +232,45 @@ func (q *DiscordQueue) QueueSize() int {
 	return len(q.messages)
 }
 
-// Stop shuts down the queue processor.
+// markSent records a successful Discord send, queued or direct. It is a
+// no-op on a nil queue.
+func (q *DiscordQueue) markSent() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	q.lastSent = time.Now()
+	q.mu.Unlock()
+}
+
+// LastSent returns when a message was last sent to Discord successfully,
+// or the zero time if none has been.
+func (q *DiscordQueue) LastSent() time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.lastSent
+}
+
+// Stop shuts down the queue processor. It is safe to call more than once.
 func (q *DiscordQueue) Stop() {
-	close(q.done)
+	q.stopOnce.Do(func() { close(q.done) })
+}
+
+// Drain tries to deliver every queued message before ctx expires, waiting
+// for retry times that fall inside the deadline. Call it after Stop. The
+// messages that could not be delivered in time are removed from the queue
+// and returned.
+func (q *DiscordQueue) Drain(ctx context.Context) []QueuedMessage {
+	q.mu.Lock()
+	if !q.pausedSince.IsZero() {
+		// Held until resumed, after the next start if need be.
+		left := q.messages
+		q.messages = nil
+		q.mu.Unlock()
+		return left
+	}
+	q.mu.Unlock()
+	return drainQueue(ctx, &q.mu, &q.messages, q.processMessages)
 }
 
 func (q *DiscordQueue) processLoop() {
@@ -90,16 +282,16 @@ func (q *DiscordQueue) processLoop() {
 		case <-q.done:
 			return
 		case <-q.notify:
-			q.processMessages()
+			q.processMessages(context.Background())
 		case <-ticker.C:
-			q.processMessages()
+			q.processMessages(context.Background())
 		}
 	}
 }
 
-func (q *DiscordQueue) processMessages() {
+func (q *DiscordQueue) processMessages(ctx context.Context) {
 	q.mu.Lock()
-	if len(q.messages) == 0 {
+	if len(q.messages) == 0 || !q.pausedSince.IsZero() {
 		q.mu.Unlock()
 		return
 	}
@@ -119,110 +311,402 @@ func (q *DiscordQueue) processMessages() {
 	q.messages = pending
 	q.mu.Unlock()
 
+	var limitedUntil time.Time
 	for _, msg := range ready {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		retryAfter, err := sendToDiscordWithRetry(ctx, msg.WebhookURL, msg.Sender, msg.Message)
+		if ctx.Err() != nil {
+			// Out of time while draining: keep the rest for later.
+			q.mu.Lock()
+			q.messages = append(q.messages, msg)
+			q.mu.Unlock()
+			continue
+		}
+		if !limitedUntil.IsZero() {
+			// Stay behind the rate-limited message so order is kept.
+			msg.RetryAt = limitedUntil
+			q.mu.Lock()
+			q.messages = append(q.messages, msg)
+			q.mu.Unlock()
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		posted, retryAfter, err := sendToDiscordWithRetry(sendCtx, msg.WebhookURL, msg.Author, msg.Template, msg.AttachAfter, msg.Mentions, msg.Sender, msg.Message, msg.Time)
 		cancel()
 
+		if err != nil && ctx.Err() != nil {
+			// Interrupted by shutdown rather than a Discord failure.
+			q.mu.Lock()
+			q.messages = append(q.messages, msg)
+			q.mu.Unlock()
+			continue
+		}
+
 		if err != nil {
 			msg.Attempts++
 			if retryAfter > 0 && msg.Attempts < q.maxRetries {
 				// Rate limited - re-queue with retry time
 				msg.RetryAt = time.Now().Add(retryAfter)
+				limitedUntil = msg.RetryAt
 				q.Add(msg)
 				if q.logger != nil {
-					q.logger.Log("info", fmt.Sprintf("Discord rate limited, will retry in %v (attempt %d/%d)", retryAfter, msg.Attempts, q.maxRetries))
+					q.logger.Log("info", traced(msg.Trace, fmt.Sprintf("Discord rate limited, will retry in %v (attempt %d/%d)", retryAfter, msg.Attempts, q.maxRetries)))
 				}
 			} else if msg.Attempts >= q.maxRetries {
 				// Max retries exceeded
-				log.Printf("Discord send failed after %d attempts: %v", msg.Attempts, err)
+				q.receipts.set(msg.ID, sinkDiscord, deliveryFailed)
+				slog.Error("Discord send failed", "attempts", msg.Attempts, "err", err)
 				if q.logger != nil {
-					q.logger.Log("error", fmt.Sprintf("Discord send failed after %d attempts: %v", msg.Attempts, err))
-					q.logger.LogFailure(msg.Sender, msg.Message, "discord", fmt.Sprintf("max retries exceeded: %v", err))
+					q.logger.Log("error", traced(msg.Trace, fmt.Sprintf("Discord send failed after %d attempts: %v", msg.Attempts, err)))
+					q.logger.LogRetryableFailure(msg.Sender, msg.Message, "discord", fmt.Sprintf("max retries exceeded: %v", err), msg.Trace, &FailureRetry{Delivery: &msg})
 				}
 			} else {
 				// Non-rate-limit error
-				log.Printf("Discord send failed: %v", err)
+				q.receipts.set(msg.ID, sinkDiscord, deliveryFailed)
+				slog.Error("Discord send failed", "err", err)
 				if q.logger != nil {
-					q.logger.Log("error", fmt.Sprintf("Discord send failed: %v", err))
-					q.logger.LogFailure(msg.Sender, msg.Message, "discord", err.Error())
+					q.logger.Log("error", traced(msg.Trace, fmt.Sprintf("Discord send failed: %v", err)))
+					q.logger.LogRetryableFailure(msg.Sender, msg.Message, "discord", err.Error(), msg.Trace, &FailureRetry{Delivery: &msg})
 				}
 			}
-		} else if q.logger != nil {
-			q.logger.Log("info", fmt.Sprintf("Queued message sent to Discord successfully (attempt %d)", msg.Attempts+1))
+		} else {
+			q.markSent()
+			q.receipts.set(msg.ID, sinkDiscord, deliverySent)
+			q.receipts.addDiscordMessages(msg.ID, posted)
+			if q.logger != nil {
+				q.logger.Log("info", traced(msg.Trace, fmt.Sprintf("Queued message sent to Discord successfully (attempt %d)", msg.Attempts+1)))
+			}
 		}
 	}
 }
 
 // sendToDiscordWithRetry sends a message and returns retry duration if rate limited.
 // Returns (0, nil) on success, (retryAfter, error) on rate limit, (0, error) on other errors.
-func sendToDiscordWithRetry(ctx context.Context, webhookURL, sender, message string) (time.Duration, error) {
-	timestamp := time.Now().Format("15:04:05")
-	base := fmt.Sprintf("**[%s] %s:** \n", timestamp, sender)
+// Each post is rendered with the Discord template tmpl (the default when
+// empty). The time shown is at, or the current time when at is zero; the
+// date is included when at is not today. Bot posts return the messages created,
+// one per chunk; webhook posts return none. A message that would take more
+// than attachAfter posts is uploaded as a .txt attachment instead (never
+// when attachAfter is 0). Posts may ping only what mentions allows, or
+// what Discord allows by default when it is nil.
+func sendToDiscordWithRetry(ctx context.Context, webhookURL string, author DiscordAuthor, tmpl string, attachAfter int, mentions *allowedMentions, sender, message string, at time.Time) ([]DiscordMessageRef, time.Duration, error) {
+	if isBotURL(webhookURL) {
+		// Bots always post under their own name and avatar.
+		author = DiscordAuthor{}
+	}
+	now := time.Now()
+	if at.IsZero() {
+		at = now
+	}
+	// With an author override the sender already appears as the post
+	// author; the default template leaves it out of the header.
+	base, suffix := discordPostParts(tmpl, author.Username != "", sender, at, now)
 
-	chunks := splitMessage(base, message, discordMessageLimit-len(base))
-	log.Printf("[DEBUG] Discord: sending %d chunk(s), message length=%d", len(chunks), len(message))
+	chunks := splitMessage(base, message, discordMessageLimit-len(base)-len(suffix))
+	for i := range chunks {
+		chunks[i] += suffix
+	}
+	if attachAfter > 0 && len(chunks) > attachAfter && len(message) <= discordAttachmentLimit {
+		slog.Debug("Discord uploading message as attachment", "chunks", len(chunks), "length", len(message))
+		content := base + fmt.Sprintf("_Long message (%d characters), attached as %s._", len(message), longMessageFile) + suffix
+		files := []discordFile{{name: longMessageFile, data: []byte(message)}}
+		ref, retryAfter, err := postDiscordFiles(ctx, webhookURL, author, mentions, content, files)
+		if err != nil || ref.MessageID == "" {
+			return nil, retryAfter, err
+		}
+		return []DiscordMessageRef{ref}, 0, nil
+	}
+	slog.Debug("Discord sending message", "chunks", len(chunks), "length", len(message))
 
+	var posted []DiscordMessageRef
 	for i, chunk := range chunks {
-		payload := map[string]string{
+		payload := map[string]any{
 			"content": chunk,
 		}
+		if mentions != nil {
+			payload["allowed_mentions"] = mentions
+		}
+		if author.Username != "" {
+			payload["username"] = author.Username
+		}
+		if author.AvatarURL != "" {
+			payload["avatar_url"] = author.AvatarURL
+		}
 
 		jsonData, err := json.Marshal(payload)
 		if err != nil {
-			return 0, fmt.Errorf("marshaling discord payload: %w", err)
+			return posted, 0, fmt.Errorf("marshaling discord payload: %w", err)
 		}
 
-		log.Printf("[DEBUG] Discord: chunk %d/%d, payload size=%d bytes", i+1, len(chunks), len(jsonData))
+		slog.Log(context.Background(), levelTrace, "Discord sending chunk", "chunk", i+1, "chunks", len(chunks), "bytes", len(jsonData))
 
-		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(jsonData))
+		req, err := newDiscordRequest(ctx, "POST", webhookURL, bytes.NewBuffer(jsonData))
 		if err != nil {
-			return 0, fmt.Errorf("creating discord request: %w", err)
+			return posted, 0, fmt.Errorf("creating discord request: %w", err)
 		}
 		req.Header.Set("Content-Type", "application/json")
 
 		resp, err := discordClient.Do(req)
 		if err != nil {
-			return 0, fmt.Errorf("sending discord request: %w", err)
+			return posted, 0, fmt.Errorf("sending discord request: %w", err)
+		}
+		var ref DiscordMessageRef
+		if resp.StatusCode == http.StatusOK && isBotURL(webhookURL) {
+			ref, err = decodeDiscordMessage(resp.Body)
 		}
 		resp.Body.Close()
 
-		log.Printf("[DEBUG] Discord: chunk %d/%d response status=%d", i+1, len(chunks), resp.StatusCode)
+		slog.Log(context.Background(), levelTrace, "Discord chunk response", "chunk", i+1, "chunks", len(chunks), "status", resp.StatusCode)
 
 		if resp.StatusCode == http.StatusTooManyRequests {
-			// Rate limited - extract Retry-After header
-			retryAfterStr := resp.Header.Get("Retry-After")
-			retryAfter := 5 * time.Second // default
-			if retryAfterStr != "" {
-				if seconds, err := strconv.ParseFloat(retryAfterStr, 64); err == nil {
-					retryAfter = time.Duration(seconds*1000) * time.Millisecond
-				}
-			}
-			return retryAfter, fmt.Errorf("rate limited by Discord")
+			return posted, discordRetryAfter(resp), fmt.Errorf("rate limited by Discord")
 		}
 
-		if resp.StatusCode != http.StatusNoContent {
-			return 0, fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+			return posted, 0, fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+		}
+		if err != nil {
+			return posted, 0, err
+		}
+		if ref.MessageID != "" {
+			posted = append(posted, ref)
 		}
 	}
 
-	log.Printf("[DEBUG] Discord: all chunks sent successfully")
-	return 0, nil
+	slog.Debug("Discord message sent")
+	return posted, 0, nil
 }
 
 // sendToDiscord sends a chat message to a Discord webhook. If the message
 // exceeds Discord's character limit, it is split into multiple chunks.
-// Returns (rateLimited, retryAfter, error). If rateLimited is true, the caller
+// Returns (posted, rateLimited, retryAfter, error). If rateLimited is true, the caller
 // should queue the message for retry after retryAfter duration.
-func sendToDiscord(ctx context.Context, webhookURL, sender, message string) (bool, time.Duration, error) {
-	retryAfter, err := sendToDiscordWithRetry(ctx, webhookURL, sender, message)
+func sendToDiscord(ctx context.Context, webhookURL string, author DiscordAuthor, tmpl string, attachAfter int, mentions *allowedMentions, sender, message string) ([]DiscordMessageRef, bool, time.Duration, error) {
+	posted, retryAfter, err := sendToDiscordWithRetry(ctx, webhookURL, author, tmpl, attachAfter, mentions, sender, message, time.Time{})
 	if err != nil {
 		if retryAfter > 0 {
-			return true, retryAfter, err
+			return posted, true, retryAfter, err
+		}
+		return posted, false, 0, err
+	}
+	return posted, false, 0, nil
+}
+
+// sendDiscordNotice posts content to a webhook verbatim, without the
+// timestamp/sender header used for chat messages.
+func sendDiscordNotice(ctx context.Context, webhookURL, content string) error {
+	_, err := postDiscordNotice(ctx, webhookURL, content)
+	return err
+}
+
+// postDiscordNotice is sendDiscordNotice returning the message created,
+// which is only known for bot posts.
+func postDiscordNotice(ctx context.Context, webhookURL, content string) (DiscordMessageRef, error) {
+	jsonData, err := json.Marshal(map[string]string{"content": content})
+	if err != nil {
+		return DiscordMessageRef{}, fmt.Errorf("marshaling discord payload: %w", err)
+	}
+
+	req, err := newDiscordRequest(ctx, "POST", webhookURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return DiscordMessageRef{}, fmt.Errorf("creating discord request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return DiscordMessageRef{}, fmt.Errorf("sending discord request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return DiscordMessageRef{}, nil
+	case http.StatusOK:
+		if !isBotURL(webhookURL) {
+			return DiscordMessageRef{}, nil
+		}
+		return decodeDiscordMessage(resp.Body)
+	default:
+		return DiscordMessageRef{}, fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+	}
+}
+
+// discordAttachmentLimit is the largest total upload size accepted by
+// webhooks on servers without boosts.
+const discordAttachmentLimit = 8 * 1024 * 1024
+
+// longMessageFile names the attachment long messages are uploaded as.
+const longMessageFile = "message.txt"
+
+// discordFile is an attachment held in memory.
+type discordFile struct {
+	name string
+	data []byte
+}
+
+// sendDiscordFiles posts content with the given files attached as a
+// multipart request. Files are attached in order as files[0], files[1], ...
+func sendDiscordFiles(ctx context.Context, webhookURL, content string, paths []string) error {
+	files := make([]discordFile, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading attachment: %w", err)
+		}
+		files = append(files, discordFile{name: filepath.Base(path), data: data})
+	}
+	_, _, err := postDiscordFiles(ctx, webhookURL, DiscordAuthor{}, nil, content, files)
+	return err
+}
+
+// postDiscordFiles posts content with files attached, as author, allowing
+// mentions as sendToDiscordWithRetry does. It returns the message created (bot posts only) and, when rate limited, how
+// long to wait before retrying.
+func postDiscordFiles(ctx context.Context, webhookURL string, author DiscordAuthor, mentions *allowedMentions, content string, files []discordFile) (DiscordMessageRef, time.Duration, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	fields := map[string]any{"content": content}
+	if mentions != nil {
+		fields["allowed_mentions"] = mentions
+	}
+	if author.Username != "" {
+		fields["username"] = author.Username
+	}
+	if author.AvatarURL != "" {
+		fields["avatar_url"] = author.AvatarURL
+	}
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return DiscordMessageRef{}, 0, fmt.Errorf("marshaling discord payload: %w", err)
+	}
+	if err := writer.WriteField("payload_json", string(payload)); err != nil {
+		return DiscordMessageRef{}, 0, fmt.Errorf("writing discord payload: %w", err)
+	}
+
+	for i, file := range files {
+		part, err := writer.CreateFormFile(fmt.Sprintf("files[%d]", i), file.name)
+		if err != nil {
+			return DiscordMessageRef{}, 0, fmt.Errorf("creating attachment part: %w", err)
+		}
+		if _, err := part.Write(file.data); err != nil {
+			return DiscordMessageRef{}, 0, fmt.Errorf("writing attachment: %w", err)
 		}
-		return false, 0, err
 	}
-	return false, 0, nil
+	if err := writer.Close(); err != nil {
+		return DiscordMessageRef{}, 0, fmt.Errorf("closing multipart body: %w", err)
+	}
+	if body.Len() > discordAttachmentLimit {
+		return DiscordMessageRef{}, 0, fmt.Errorf("attachments exceed Discord's %d MB upload limit", discordAttachmentLimit/(1024*1024))
+	}
+
+	req, err := newDiscordRequest(ctx, "POST", webhookURL, &body)
+	if err != nil {
+		return DiscordMessageRef{}, 0, fmt.Errorf("creating discord request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return DiscordMessageRef{}, 0, fmt.Errorf("sending discord request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return DiscordMessageRef{}, discordRetryAfter(resp), fmt.Errorf("rate limited by Discord")
+	case http.StatusNoContent:
+		return DiscordMessageRef{}, 0, nil
+	case http.StatusOK:
+		if !isBotURL(webhookURL) {
+			return DiscordMessageRef{}, 0, nil
+		}
+		ref, err := decodeDiscordMessage(resp.Body)
+		return ref, 0, err
+	default:
+		return DiscordMessageRef{}, 0, fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+	}
+}
+
+// discordRetryAfter returns how long a rate-limited response asks to wait,
+// from its Retry-After header (5 seconds if missing).
+func discordRetryAfter(resp *http.Response) time.Duration {
+	retryAfter := 5 * time.Second // default
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil {
+		retryAfter = time.Duration(seconds*1000) * time.Millisecond
+	}
+	return retryAfter
+}
+
+// webhookThreadURL returns the webhook URL targeting the given thread.
+// Discord accepts the thread as a thread_id query parameter, so the result
+// can be used anywhere a plain webhook URL is expected (including the retry queue).
+// Bot targets post to the thread's channel directly.
+func webhookThreadURL(webhookURL, threadID string) (string, error) {
+	if isBotURL(webhookURL) {
+		return botThreadURL(webhookURL, threadID)
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing webhook URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("thread_id", threadID)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// createDiscordThread starts a new thread named after the scene and returns
+// its ID. Webhooks can only create threads in forum (and media) channels;
+// the starter post becomes the first message of the thread.
+func createDiscordThread(ctx context.Context, webhookURL, name string) (string, error) {
+	if isBotURL(webhookURL) {
+		return createBotThread(ctx, webhookURL, name)
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing webhook URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("wait", "true")
+	u.RawQuery = query.Encode()
+
+	// Thread names are capped at 100 characters by Discord.
+	payload := map[string]string{
+		"content":     fmt.Sprintf("--- Scene: %s ---", name),
+		"thread_name": truncateMessage(name, 100),
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("marshaling thread payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("creating thread request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sending thread request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+	}
+
+	// With wait=true Discord returns the created message; its channel_id is the thread.
+	var created struct {
+		ChannelID string `json:"channel_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("decoding thread response: %w", err)
+	}
+	if created.ChannelID == "" {
+		return "", fmt.Errorf("discord response did not include a thread ID")
+	}
+	return created.ChannelID, nil
 }
 
 // extractChunk splits a message at a word boundary within maxLength characters.
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+// Discord posting modes.
+const (
+	discordModeWebhook = "webhook"
+	discordModeBot     = "bot"
+)
+
+// discordAPI is the Discord REST API base; tests point it at a fake server.
+var discordAPI = "https://discord.com/api/v10"
+
+// DiscordMessageRef identifies a message posted by the bot, so it can be
+// edited or its reactions read later.
+type DiscordMessageRef struct {
+	ChannelID string `json:"channelId"`
+	MessageID string `json:"messageId"`
+}
+
+// discordTarget returns where chat messages are posted: the bot's channel
+// in bot mode, otherwise the webhook.
+func discordTarget(config *AppConfig) string {
+	if config.DiscordMode == discordModeBot {
+		return botChannelURL(config.BotToken, config.BotChannelID)
+	}
+	return config.WebhookURL
+}
+
+// oocDiscordTarget returns where OOC messages are posted under the
+// "separate" policy.
+func oocDiscordTarget(config *AppConfig) string {
+	if config.DiscordMode == discordModeBot {
+		return botChannelURL(config.BotToken, config.BotOOCChannelID)
+	}
+	return config.OOCWebhookURL
+}
+
+// botChannelURL returns the target for posting to channelID as the bot, or
+// "" when either is missing. The token travels in the URL's userinfo so a
+// bot target can be stored anywhere a webhook URL is (the retry queue,
+// failed messages, scene threads); newDiscordRequest moves it into the
+// Authorization header.
+func botChannelURL(token, channelID string) string {
+	if token == "" || channelID == "" {
+		return ""
+	}
+	u, err := url.Parse(discordAPI)
+	if err != nil {
+		return ""
+	}
+	u = u.JoinPath("channels", channelID, "messages")
+	u.User = url.UserPassword(discordModeBot, token)
+	return u.String()
+}
+
+// isBotURL reports whether target posts as the bot rather than through a
+// webhook.
+func isBotURL(target string) bool {
+	u, err := url.Parse(target)
+	return err == nil && u.User != nil && u.User.Username() == discordModeBot
+}
+
+// newDiscordRequest creates a request to a webhook or bot target. For bot
+// targets the token is taken out of the URL and sent as a Bot
+// Authorization header.
+func newDiscordRequest(ctx context.Context, method, target string, body io.Reader) (*http.Request, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Discord URL: %w", err)
+	}
+	var token string
+	if u.User != nil {
+		token, _ = u.User.Password()
+		u.User = nil
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bot "+token)
+	}
+	return req, nil
+}
+
+// botThreadURL returns the bot target for a thread. Threads are channels,
+// so the channel ID in the path is swapped for the thread's.
+func botThreadURL(target, threadID string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("parsing Discord URL: %w", err)
+	}
+	u.Path = path.Join(path.Dir(path.Dir(u.Path)), threadID, "messages")
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// createBotThread starts a public thread in the bot's text channel and
+// posts the scene header as its first message. Unlike webhooks, bots can
+// create threads in ordinary channels.
+func createBotThread(ctx context.Context, target, name string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("parsing Discord URL: %w", err)
+	}
+	u.Path = path.Join(path.Dir(u.Path), "threads")
+
+	// Type 11 is a public thread; 10080 minutes is the longest auto-archive.
+	jsonData, err := json.Marshal(map[string]any{
+		"name":                  truncateMessage(name, 100),
+		"type":                  11,
+		"auto_archive_duration": 10080,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshaling thread payload: %w", err)
+	}
+	req, err := newDiscordRequest(ctx, http.MethodPost, u.String(), bytes.NewReader(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("creating thread request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sending thread request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+	}
+	var thread struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&thread); err != nil {
+		return "", fmt.Errorf("decoding thread response: %w", err)
+	}
+	if thread.ID == "" {
+		return "", fmt.Errorf("discord response did not include a thread ID")
+	}
+
+	threadURL, err := botThreadURL(target, thread.ID)
+	if err != nil {
+		return "", err
+	}
+	if err := sendDiscordNotice(ctx, threadURL, fmt.Sprintf("--- Scene: %s ---", name)); err != nil {
+		return "", err
+	}
+	return thread.ID, nil
+}
+
+// botMessageURL returns the API URL of a posted message, carrying the token
+// like botChannelURL.
+func botMessageURL(token string, ref DiscordMessageRef) (string, error) {
+	u, err := url.Parse(discordAPI)
+	if err != nil {
+		return "", fmt.Errorf("parsing Discord URL: %w", err)
+	}
+	u = u.JoinPath("channels", ref.ChannelID, "messages", ref.MessageID)
+	u.User = url.UserPassword(discordModeBot, token)
+	return u.String(), nil
+}
+
+// editDiscordMessage replaces the content of a message the bot posted.
+func editDiscordMessage(ctx context.Context, token string, ref DiscordMessageRef, content string) error {
+	target, err := botMessageURL(token, ref)
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(map[string]string{"content": content})
+	if err != nil {
+		return fmt.Errorf("marshaling discord payload: %w", err)
+	}
+	req, err := newDiscordRequest(ctx, http.MethodPatch, target, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("creating discord request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending discord request: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deleteDiscordMessage deletes a message the bot posted. A message that is
+// already gone counts as deleted.
+func deleteDiscordMessage(ctx context.Context, token string, ref DiscordMessageRef) error {
+	target, err := botMessageURL(token, ref)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := newDiscordRequest(ctx, http.MethodDelete, target, nil)
+		if err != nil {
+			return fmt.Errorf("creating discord request: %w", err)
+		}
+		resp, err := discordClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("sending discord request: %w", err)
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotFound:
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < 3:
+			select {
+			case <-time.After(discordRetryAfter(resp)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		default:
+			return fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+		}
+	}
+}
+
+// discordReactions returns the reaction counts on a message the bot posted,
+// keyed by emoji name.
+func discordReactions(ctx context.Context, token string, ref DiscordMessageRef) (map[string]int, error) {
+	target, err := botMessageURL(token, ref)
+	if err != nil {
+		return nil, err
+	}
+	req, err := newDiscordRequest(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating discord request: %w", err)
+	}
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending discord request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+	}
+
+	var msg struct {
+		Reactions []struct {
+			Count int `json:"count"`
+			Emoji struct {
+				Name string `json:"name"`
+			} `json:"emoji"`
+		} `json:"reactions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("decoding discord message: %w", err)
+	}
+	reactions := make(map[string]int, len(msg.Reactions))
+	for _, r := range msg.Reactions {
+		reactions[r.Emoji.Name] += r.Count
+	}
+	return reactions, nil
+}
+
+// decodeDiscordMessage reads the message a bot post created from a 200
+// response.
+func decodeDiscordMessage(body io.Reader) (DiscordMessageRef, error) {
+	var msg struct {
+		ID        string `json:"id"`
+		ChannelID string `json:"channel_id"`
+	}
+	if err := json.NewDecoder(body).Decode(&msg); err != nil {
+		return DiscordMessageRef{}, fmt.Errorf("decoding discord message: %w", err)
+	}
+	return DiscordMessageRef{ChannelID: msg.ChannelID, MessageID: msg.ID}, nil
+}
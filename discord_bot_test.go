@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDiscordBot is a minimal Discord REST API that records what the bot
+// posted, edited and deleted.
+type fakeDiscordBot struct {
+	mu      sync.Mutex
+	posts   map[string][]map[string]any // channel ID -> message payloads
+	edits   map[string]string           // message ID -> new content
+	deleted []string                    // message IDs
+	nextID  int
+}
+
+func newFakeDiscordBot(t *testing.T) *fakeDiscordBot {
+	t.Helper()
+	bot := &fakeDiscordBot{posts: make(map[string][]map[string]any), edits: make(map[string]string)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /channels/{channel}/messages", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		channel := r.PathValue("channel")
+		bot.mu.Lock()
+		bot.nextID++
+		id := fmt.Sprintf("m%d", bot.nextID)
+		bot.posts[channel] = append(bot.posts[channel], payload)
+		bot.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "channel_id": channel})
+	})
+	mux.HandleFunc("POST /channels/{channel}/threads", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload["name"] == "" || payload["type"] != float64(11) {
+			t.Errorf("unexpected thread payload %v", payload)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": "900", "parent_id": r.PathValue("channel")})
+	})
+	mux.HandleFunc("PATCH /channels/{channel}/messages/{message}", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		bot.mu.Lock()
+		bot.edits[r.PathValue("message")] = payload["content"]
+		bot.mu.Unlock()
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("DELETE /channels/{channel}/messages/{message}", func(w http.ResponseWriter, r *http.Request) {
+		bot.mu.Lock()
+		bot.deleted = append(bot.deleted, r.PathValue("message"))
+		bot.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /channels/{channel}/messages/{message}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"m1","reactions":[{"count":2,"emoji":{"name":"✅"}},{"count":1,"emoji":{"id":"5","name":"heart"}}]}`))
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bot secret" {
+			t.Errorf("expected bot authorization, got %q", got)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.User != nil {
+			t.Errorf("token leaked into request URL")
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	old := discordAPI
+	discordAPI = srv.URL
+	t.Cleanup(func() {
+		discordAPI = old
+		srv.Close()
+	})
+	return bot
+}
+
+func (b *fakeDiscordBot) contents(channel string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []string
+	for _, p := range b.posts[channel] {
+		out = append(out, fmt.Sprint(p["content"]))
+	}
+	return out
+}
+
+func TestDiscordTarget(t *testing.T) {
+	webhook := &AppConfig{WebhookURL: "https://discord.com/api/webhooks/1/abc", OOCWebhookURL: "https://discord.com/api/webhooks/2/def"}
+	if got := discordTarget(webhook); got != webhook.WebhookURL {
+		t.Errorf("webhook mode: expected the webhook URL, got %q", got)
+	}
+	if got := oocDiscordTarget(webhook); got != webhook.OOCWebhookURL {
+		t.Errorf("webhook mode: expected the OOC webhook URL, got %q", got)
+	}
+
+	bot := &AppConfig{DiscordMode: discordModeBot, BotToken: "secret", BotChannelID: "100", WebhookURL: webhook.WebhookURL}
+	target := discordTarget(bot)
+	if !isBotURL(target) || !strings.Contains(target, "/channels/100/messages") {
+		t.Errorf("bot mode: unexpected target %q", target)
+	}
+	if got := oocDiscordTarget(bot); got != "" {
+		t.Errorf("bot mode without OOC channel: expected no target, got %q", got)
+	}
+	if isBotURL(webhook.WebhookURL) {
+		t.Error("webhook URL treated as a bot target")
+	}
+}
+
+func TestSendToDiscord_Bot(t *testing.T) {
+	bot := newFakeDiscordBot(t)
+
+	author := DiscordAuthor{Username: "Conan", AvatarURL: "https://example.com/conan.png"}
+	posted, _, err := sendToDiscordWithRetry(context.Background(), botChannelURL("secret", "100"), author, "", 0, nil, "Conan", "Hello", time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posted) != 1 || posted[0] != (DiscordMessageRef{ChannelID: "100", MessageID: "m1"}) {
+		t.Errorf("unexpected posted messages %+v", posted)
+	}
+
+	bot.mu.Lock()
+	payload := bot.posts["100"][0]
+	bot.mu.Unlock()
+	if _, ok := payload["username"]; ok {
+		t.Error("bot post should not override the username")
+	}
+	if content := fmt.Sprint(payload["content"]); !strings.Contains(content, "Conan:") || !strings.Contains(content, "Hello") {
+		t.Errorf("expected the sender in the message header, got %q", content)
+	}
+}
+
+func TestCreateDiscordThread_Bot(t *testing.T) {
+	bot := newFakeDiscordBot(t)
+
+	target := botChannelURL("secret", "100")
+	id, err := createDiscordThread(context.Background(), target, "Tavern Brawl")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "900" {
+		t.Errorf("expected thread ID 900, got %q", id)
+	}
+	if got := bot.contents("900"); len(got) != 1 || got[0] != "--- Scene: Tavern Brawl ---" {
+		t.Errorf("expected the scene header in the thread, got %q", got)
+	}
+
+	threadURL, err := webhookThreadURL(target, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sendDiscordNotice(context.Background(), threadURL, "in the thread"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := bot.contents("900"); len(got) != 2 {
+		t.Errorf("expected the notice to be posted in the thread, got %q", got)
+	}
+}
+
+func TestSessionDivider_BotEdit(t *testing.T) {
+	bot := newFakeDiscordBot(t)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableDiscord = true
+	a.config.DiscordMode = discordModeBot
+	a.config.BotToken = "secret"
+	a.config.BotChannelID = "100"
+
+	if _, err := a.StartSession("Raid"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.StopSession(); err != nil {
+		t.Fatal(err)
+	}
+
+	bot.mu.Lock()
+	edited := bot.edits["m1"]
+	bot.mu.Unlock()
+	if !strings.HasPrefix(edited, "--- Session: Raid (") {
+		t.Errorf("expected the start divider to be edited, got %q", edited)
+	}
+}
+
+func TestHandleMessageStatus_Reactions(t *testing.T) {
+	newFakeDiscordBot(t)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.BotToken = "secret"
+	a.receipts = newReceiptTable(10)
+	id := a.receipts.add("Conan", "", "")
+	a.receipts.addDiscordMessages(id, []DiscordMessageRef{{ChannelID: "100", MessageID: "m1"}})
+
+	rec := httptest.NewRecorder()
+	a.handleMessageStatus(rec, httptest.NewRequest("GET", "/api/messages/status?id="+id, nil))
+
+	var got struct {
+		ID           string         `json:"id"`
+		Reactions    map[string]int `json:"reactions"`
+		Acknowledged bool           `json:"acknowledged"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != id || !got.Acknowledged {
+		t.Errorf("expected an acknowledged receipt %s, got %+v", id, got)
+	}
+	if got.Reactions["✅"] != 2 || got.Reactions["heart"] != 1 {
+		t.Errorf("unexpected reactions %v", got.Reactions)
+	}
+}
+
+func TestValidate_DiscordMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AppConfig
+		wantErr string
+	}{
+		{
+			name:    "webhook requires URL",
+			cfg:     AppConfig{EnableDiscord: true},
+			wantErr: "Discord webhook URL required",
+		},
+		{
+			name:    "bot requires channel",
+			cfg:     AppConfig{EnableDiscord: true, DiscordMode: discordModeBot, BotToken: "secret"},
+			wantErr: "Discord bot token and channel ID required",
+		},
+		{
+			name: "bot",
+			cfg:  AppConfig{EnableDiscord: true, DiscordMode: discordModeBot, BotToken: "secret", BotChannelID: "100"},
+		},
+		{
+			name:    "bot separate OOC requires channel",
+			cfg:     AppConfig{EnableDiscord: true, DiscordMode: discordModeBot, BotToken: "secret", BotChannelID: "100", OOCDiscordPolicy: oocSeparate},
+			wantErr: "OOC channel ID required",
+		},
+		{
+			name:    "unknown mode",
+			cfg:     AppConfig{EnableDiscord: true, DiscordMode: "carrier pigeon"},
+			wantErr: "Discord mode must be webhook or bot",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
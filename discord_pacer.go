@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPacerRoutes bounds the rate limit state kept; stale entries are
+// dropped beyond it.
+const maxPacerRoutes = 256
+
+// discordPacer is an http.RoundTripper that keeps Discord requests under
+// Discord's rate limits instead of running into 429s: it reads the
+// X-RateLimit-Remaining and X-RateLimit-Reset-After headers of each
+// response, and once a bucket has no requests left, holds the next ones
+// for it until the bucket resets. A 429 for the global limit holds every
+// request for its Retry-After. Requests to routes Discord hasn't answered
+// yet go straight through. The zero value, with base set, is ready to use.
+type discordPacer struct {
+	base http.RoundTripper
+
+	mu          sync.Mutex
+	globalUntil time.Time
+	routes      map[string]string // route to the bucket Discord reports
+	buckets     map[string]*pacerBucket
+}
+
+// pacerBucket is what Discord last said of a rate limit bucket.
+type pacerBucket struct {
+	remaining int
+	resetAt   time.Time
+}
+
+// pacerRoute identifies a request's rate limit route: its method and path,
+// with message IDs left out, as Discord limits per channel or webhook.
+func pacerRoute(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for i := 1; i < len(segments); i++ {
+		if segments[i-1] == "messages" && strings.Trim(segments[i], "0123456789") == "" {
+			segments[i] = "{id}"
+		}
+	}
+	return req.Method + " " + req.URL.Host + strings.Join(segments, "/")
+}
+
+// bucket returns the bucket of route. Call with p.mu held.
+func (p *discordPacer) bucket(route string) string {
+	if bucket, ok := p.routes[route]; ok {
+		return bucket
+	}
+	return route
+}
+
+// wait blocks until a request to route may be sent, and counts it against
+// its bucket.
+func (p *discordPacer) wait(ctx context.Context, route string) error {
+	for {
+		p.mu.Lock()
+		now := time.Now()
+		until := p.globalUntil
+		if b := p.buckets[p.bucket(route)]; b != nil && now.Before(b.resetAt) && !now.Before(until) {
+			if b.remaining > 0 {
+				b.remaining--
+			} else {
+				until = b.resetAt
+			}
+		}
+		p.mu.Unlock()
+		if !now.Before(until) {
+			return nil
+		}
+
+		slog.Debug("Discord pacing request", "route", route, "wait", until.Sub(now))
+		timer := time.NewTimer(until.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// update records the rate limit headers of a response to route.
+func (p *discordPacer) update(route string, resp *http.Response, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if resp.StatusCode == http.StatusTooManyRequests &&
+		(resp.Header.Get("X-RateLimit-Global") == "true" || resp.Header.Get("X-RateLimit-Scope") == "global") {
+		p.globalUntil = now.Add(discordRetryAfter(resp))
+	}
+
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	resetAfter, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Reset-After"), 64)
+	if err != nil {
+		return
+	}
+	if p.buckets == nil || p.routes == nil || len(p.buckets) >= maxPacerRoutes || len(p.routes) >= maxPacerRoutes {
+		p.prune(now)
+	}
+	if bucket := resp.Header.Get("X-RateLimit-Bucket"); bucket != "" {
+		p.routes[route] = bucket
+	}
+	p.buckets[p.bucket(route)] = &pacerBucket{
+		remaining: remaining,
+		resetAt:   now.Add(time.Duration(resetAfter * float64(time.Second))),
+	}
+}
+
+// prune drops the buckets that have reset, and the routes when there are
+// too many. Call with p.mu held.
+func (p *discordPacer) prune(now time.Time) {
+	if p.buckets == nil {
+		p.buckets = make(map[string]*pacerBucket)
+	}
+	for key, b := range p.buckets {
+		if !now.Before(b.resetAt) {
+			delete(p.buckets, key)
+		}
+	}
+	if p.routes == nil || len(p.routes) >= maxPacerRoutes {
+		p.routes = make(map[string]string)
+	}
+}
+
+func (p *discordPacer) RoundTrip(req *http.Request) (*http.Response, error) {
+	route := pacerRoute(req)
+	if err := p.wait(req.Context(), route); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := p.base.RoundTrip(req)
+	if err == nil {
+		p.update(route, resp, time.Now())
+	}
+	return resp, err
+}
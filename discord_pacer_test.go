@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPacerRoute(t *testing.T) {
+	tests := []struct {
+		method, url string
+		want        string
+	}{
+		{"POST", "https://discord.com/api/webhooks/1/abc?wait=true&thread_id=5", "POST discord.com/api/webhooks/1/abc"},
+		{"PATCH", "https://discord.com/api/webhooks/1/abc/messages/123456", "PATCH discord.com/api/webhooks/1/abc/messages/{id}"},
+		{"POST", "https://discord.com/api/v10/channels/42/messages", "POST discord.com/api/v10/channels/42/messages"},
+	}
+	for _, tt := range tests {
+		if got := pacerRoute(httptest.NewRequest(tt.method, tt.url, nil)); got != tt.want {
+			t.Errorf("pacerRoute(%s %s) = %q, want %q", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
+
+// pacedServer answers with the rate limit headers set by headers for each
+// request, and records when requests arrive.
+func pacedServer(t *testing.T, headers func(n int, h http.Header) int) (*httptest.Server, func() []time.Time) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		n := len(arrivals)
+		mu.Unlock()
+		w.WriteHeader(headers(n, w.Header()))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), arrivals...)
+	}
+}
+
+func TestDiscordPacer_Bucket(t *testing.T) {
+	srv, arrivals := pacedServer(t, func(n int, h http.Header) int {
+		h.Set("X-RateLimit-Bucket", "abcd")
+		h.Set("X-RateLimit-Remaining", "0")
+		h.Set("X-RateLimit-Reset-After", "0.2")
+		return http.StatusNoContent
+	})
+	client := &http.Client{Transport: &discordPacer{base: http.DefaultTransport}}
+	for _, path := range []string{"/api/webhooks/1/abc", "/api/webhooks/1/abc", "/api/webhooks/1/abc/messages/9"} {
+		resp, err := client.Post(srv.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	got := arrivals()
+	if gap := got[1].Sub(got[0]); gap < 150*time.Millisecond {
+		t.Errorf("second request sent %v after the bucket ran out, want about 200ms", gap)
+	}
+	// A route not yet answered goes straight through, even in the same
+	// bucket.
+	if gap := got[2].Sub(got[1]); gap > 100*time.Millisecond {
+		t.Errorf("request to a new route held for %v", gap)
+	}
+}
+
+func TestDiscordPacer_Global(t *testing.T) {
+	srv, arrivals := pacedServer(t, func(n int, h http.Header) int {
+		if n == 1 {
+			h.Set("X-RateLimit-Global", "true")
+			h.Set("Retry-After", "0.2")
+			return http.StatusTooManyRequests
+		}
+		h.Set("X-RateLimit-Remaining", "4")
+		h.Set("X-RateLimit-Reset-After", "1")
+		return http.StatusNoContent
+	})
+	client := &http.Client{Transport: &discordPacer{base: http.DefaultTransport}}
+	for _, path := range []string{"/api/webhooks/1/abc", "/api/webhooks/2/def", "/api/webhooks/2/def"} {
+		resp, err := client.Post(srv.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	got := arrivals()
+	if gap := got[1].Sub(got[0]); gap < 150*time.Millisecond {
+		t.Errorf("request to another route sent %v after a global rate limit, want about 200ms", gap)
+	}
+	if gap := got[2].Sub(got[1]); gap > 100*time.Millisecond {
+		t.Errorf("request with requests left held for %v", gap)
+	}
+}
@@ -1,7 +1,15 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSplitMessage(t *testing.T) {
@@ -145,3 +153,295 @@ func TestExtractChunk(t *testing.T) {
 		})
 	}
 }
+
+func TestWebhookThreadURL(t *testing.T) {
+	got, err := webhookThreadURL("https://discord.com/api/webhooks/1/abc", "42")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "https://discord.com/api/webhooks/1/abc?thread_id=42"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCreateDiscordThread(t *testing.T) {
+	var payload map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") != "true" {
+			t.Errorf("expected wait=true query parameter")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","channel_id":"777"}`))
+	}))
+	defer srv.Close()
+
+	id, err := createDiscordThread(context.Background(), srv.URL, "Tavern Brawl")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "777" {
+		t.Errorf("expected thread ID 777, got %q", id)
+	}
+	if payload["thread_name"] != "Tavern Brawl" {
+		t.Errorf("expected thread_name %q, got %q", "Tavern Brawl", payload["thread_name"])
+	}
+}
+
+func TestDiscordAuthorFor(t *testing.T) {
+	cfg := &AppConfig{
+		SenderAsAuthor: true,
+		Avatars:        map[string]string{"Conan": "https://example.com/conan.png"},
+		Characters: []Character{
+			{Name: "Bêlit", Aliases: []string{"belit_pirate"}, AvatarURL: "https://example.com/belit.png"},
+			{Name: "Conan", Aliases: []string{"conan_cimmeria"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		cfg      *AppConfig
+		sender   string
+		expected DiscordAuthor
+	}{
+		{
+			name:     "disabled",
+			cfg:      &AppConfig{},
+			sender:   "Conan",
+			expected: DiscordAuthor{},
+		},
+		{
+			name:     "with avatar",
+			cfg:      cfg,
+			sender:   "Conan",
+			expected: DiscordAuthor{Username: "Conan", AvatarURL: "https://example.com/conan.png"},
+		},
+		{
+			name:     "without avatar",
+			cfg:      cfg,
+			sender:   "Valeria",
+			expected: DiscordAuthor{Username: "Valeria"},
+		},
+		{
+			name:     "character alias",
+			cfg:      cfg,
+			sender:   "BELIT_PIRATE",
+			expected: DiscordAuthor{Username: "Bêlit", AvatarURL: "https://example.com/belit.png"},
+		},
+		{
+			name:     "character without avatar keeps the avatar list's",
+			cfg:      cfg,
+			sender:   "conan_cimmeria",
+			expected: DiscordAuthor{Username: "Conan", AvatarURL: "https://example.com/conan.png"},
+		},
+		{
+			name:     "reserved word in name",
+			cfg:      cfg,
+			sender:   "DiscordFan",
+			expected: DiscordAuthor{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := discordAuthorFor(tt.cfg, tt.sender); got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseNameMap(t *testing.T) {
+	input := "Conan = https://a/conan.png\n\n  Valeria=https://a/v.png  \nbroken line\n= missing name\n"
+	got := parseNameMap(input)
+	if len(got) != 2 || got["Conan"] != "https://a/conan.png" || got["Valeria"] != "https://a/v.png" {
+		t.Errorf("unexpected result: %v", got)
+	}
+	if formatted := formatNameMap(got); formatted != "Conan = https://a/conan.png\nValeria = https://a/v.png\n" {
+		t.Errorf("unexpected formatting: %q", formatted)
+	}
+}
+
+func TestDiscordQueue_KeepsOrderWhenRateLimited(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	limited := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		if !limited && strings.Contains(payload["content"], "second") {
+			limited = true
+			w.Header().Set("Retry-After", "0.05")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		got = append(got, payload["content"])
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	q := NewDiscordQueue(nil, nil)
+	q.Stop()
+	var msgs []QueuedMessage
+	for _, text := range []string{"first", "second", "third", "fourth"} {
+		msgs = append(msgs, QueuedMessage{WebhookURL: srv.URL, Sender: "Alice", Message: text})
+	}
+	q.AddBatch(msgs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if left := q.Drain(ctx); len(left) != 0 {
+		t.Fatalf("%d message(s) not delivered", len(left))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"first", "second", "third", "fourth"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(got[i], w) {
+			t.Errorf("message %d: got %q, want %q", i, got[i], w)
+		}
+	}
+}
+
+func TestSendToDiscordAttachesLongMessages(t *testing.T) {
+	type post struct {
+		content, username, file string
+	}
+	var mu sync.Mutex
+	var posts []post
+	rateLimited := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if rateLimited {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		var p post
+		var payload map[string]string
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("parsing upload: %v", err)
+			}
+			json.Unmarshal([]byte(r.FormValue("payload_json")), &payload)
+			f, hdr, err := r.FormFile("files[0]")
+			if err != nil {
+				t.Errorf("no attachment: %v", err)
+			} else {
+				data, _ := io.ReadAll(f)
+				p.file = hdr.Filename + ":" + string(data)
+			}
+		} else {
+			json.NewDecoder(r.Body).Decode(&payload)
+		}
+		p.content, p.username = payload["content"], payload["username"]
+		posts = append(posts, p)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	long := strings.Repeat("word ", 1000) // three posts
+	author := DiscordAuthor{Username: "Conan"}
+	tests := []struct {
+		attachAfter int
+		message     string
+		wantPosts   int
+		wantFile    bool
+	}{
+		{0, long, 3, false},
+		{3, long, 3, false},
+		{2, long, 1, true},
+		{1, "short", 1, false},
+	}
+	for _, tt := range tests {
+		posts = nil
+		if _, _, err := sendToDiscordWithRetry(context.Background(), srv.URL, author, "", tt.attachAfter, nil, "Conan", tt.message, time.Time{}); err != nil {
+			t.Fatalf("attachAfter %d: %v", tt.attachAfter, err)
+		}
+		if len(posts) != tt.wantPosts {
+			t.Fatalf("attachAfter %d: %d posts, want %d", tt.attachAfter, len(posts), tt.wantPosts)
+		}
+		if !tt.wantFile {
+			if posts[0].file != "" {
+				t.Errorf("attachAfter %d: unexpected attachment", tt.attachAfter)
+			}
+			continue
+		}
+		p := posts[0]
+		if p.file != longMessageFile+":"+long || p.username != "Conan" || !strings.Contains(p.content, "attached as message.txt") {
+			t.Errorf("attachAfter %d: unexpected upload %+v", tt.attachAfter, p)
+		}
+	}
+
+	rateLimited = true
+	_, retryAfter, err := sendToDiscordWithRetry(context.Background(), srv.URL, author, "", 2, nil, "Conan", long, time.Time{})
+	if err == nil || retryAfter != 2*time.Second {
+		t.Errorf("rate-limited upload: retryAfter %v, err %v", retryAfter, err)
+	}
+}
+
+func TestEscapeDiscordMarkdown(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"*bold* _it_ ~~gone~~ ||spoiler||", `\*bold\* \_it\_ \~\~gone\~\~ \|\|spoiler\|\|`},
+		{"`code` and \\", "\\`code\\` and \\\\"},
+		{"[click](https://example.com/a_b)", `\[click\](https://example.com/a_b)`},
+		{"> quote\n# title\n  - item", "\\> quote\n\\# title\n  \\- item"},
+		{"well-known > 3 # 1", "well-known > 3 # 1"},
+		{"see https://example.com/*x* then *y*", `see https://example.com/*x* then \*y\*`},
+		{"@everyone look", "@everyone look"},
+	}
+	for _, tt := range tests {
+		if got := escapeDiscordMarkdown(tt.in); got != tt.want {
+			t.Errorf("escapeDiscordMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDiscordContentMarkdown(t *testing.T) {
+	cfg := &AppConfig{EmoteDetection: true}
+	if got := discordContent(cfg, "/me grins_wide"); got != `*grins\_wide*` {
+		t.Errorf("escaped emote = %q", got)
+	}
+
+	cfg.DiscordMarkdown = discordMarkdownPassthrough
+	if got := discordContent(cfg, "so **loud** @here"); got != "so **loud** @here" {
+		t.Errorf("passthrough content = %q", got)
+	}
+}
+
+func TestSendToDiscordAllowedMentions(t *testing.T) {
+	var payloads []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	none := &allowedMentions{Parse: []string{}}
+	for _, mentions := range []*allowedMentions{none, nil} {
+		if _, _, err := sendToDiscordWithRetry(context.Background(), srv.URL, DiscordAuthor{}, "", 0, mentions, "Conan", "@everyone hi", time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, ok := payloads[0]["allowed_mentions"].(map[string]any)
+	if !ok || got["parse"] == nil || len(got["parse"].([]any)) != 0 {
+		t.Errorf("allowed_mentions = %v, want {parse: []}", payloads[0]["allowed_mentions"])
+	}
+	if _, ok := payloads[1]["allowed_mentions"]; ok {
+		t.Errorf("allowed_mentions sent without mentions: %v", payloads[1])
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// discovery is the LAN advertisement and router port mapping for a running
+// ingestion server.
+type discovery struct {
+	mdns   *mdnsResponder
+	lanURL string
+	cancel context.CancelFunc
+	done   chan struct{} // closed once the UPnP attempt has finished
+
+	mu   sync.Mutex
+	upnp *upnpMapping
+}
+
+// loopbackListenAddr reports whether addr only accepts connections from
+// this machine.
+func loopbackListenAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// startDiscovery advertises the ingestion server over mDNS and asks the
+// router to forward its port, as configured. Failures are logged; the
+// server runs either way. The port mapping is made in the background since
+// finding the router takes a few seconds.
+func (a *App) startDiscovery(config *AppConfig) {
+	if !config.EnableMDNS && !config.EnableUPnP {
+		return
+	}
+	port, err := listenPort(config.ListenAddr)
+	if err != nil {
+		a.logger.Log("error", fmt.Sprintf("LAN discovery failed: %v", err))
+		return
+	}
+	a.stopDiscovery()
+
+	d := &discovery{done: make(chan struct{})}
+	if config.EnableMDNS {
+		r, err := startMDNS(config, port)
+		if err != nil {
+			slog.Error("mDNS failed", "err", err)
+			a.logger.Log("error", fmt.Sprintf("mDNS advertisement failed: %v", err))
+		} else {
+			d.mdns = r
+			d.lanURL = fmt.Sprintf("http://%s:%d/message", r.host, port)
+			a.logger.Log("info", fmt.Sprintf("Advertising %s on the LAN", d.lanURL))
+		}
+	}
+	if config.EnableUPnP {
+		ctx, cancel := context.WithCancel(context.Background())
+		d.cancel = cancel
+		go func() {
+			defer close(d.done)
+			m, err := startUPnP(ctx, port)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("UPnP failed", "err", err)
+					a.logger.Log("error", fmt.Sprintf("UPnP port mapping failed: %v", err))
+				}
+				return
+			}
+			d.mu.Lock()
+			d.upnp = m
+			d.mu.Unlock()
+			a.logger.Log("info", fmt.Sprintf("Router is forwarding port %d to %s", port, m.client))
+		}()
+	} else {
+		close(d.done)
+	}
+
+	a.discoveryMu.Lock()
+	a.discovery = d
+	a.discoveryMu.Unlock()
+}
+
+// stopDiscovery withdraws the mDNS advertisement and removes the port
+// mapping, if any.
+func (a *App) stopDiscovery() {
+	a.discoveryMu.Lock()
+	d := a.discovery
+	a.discovery = nil
+	a.discoveryMu.Unlock()
+	if d == nil {
+		return
+	}
+	if d.cancel != nil {
+		d.cancel()
+	}
+	<-d.done
+	if d.upnp != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := d.upnp.stop(ctx); err != nil {
+			slog.Warn("UPnP cleanup failed", "err", err)
+			a.logger.Log("warn", fmt.Sprintf("Could not remove the router port mapping: %v", err))
+		}
+		cancel()
+	}
+	if d.mdns != nil {
+		d.mdns.stop()
+	}
+}
+
+// discoveryStatus describes discovery for the status partial: the .local
+// and internet ingestion URLs, and Pending while the router is still being
+// asked for a port mapping.
+func (a *App) discoveryStatus() map[string]interface{} {
+	a.discoveryMu.Lock()
+	d := a.discovery
+	a.discoveryMu.Unlock()
+	status := map[string]interface{}{"LANURL": "", "InternetURL": "", "Pending": false}
+	if d == nil {
+		return status
+	}
+	status["LANURL"] = d.lanURL
+	select {
+	case <-d.done:
+	default:
+		status["Pending"] = true
+		return status
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.upnp != nil && d.upnp.externalIP != "" {
+		status["InternetURL"] = fmt.Sprintf("http://%s/message", net.JoinHostPort(d.upnp.externalIP, fmt.Sprint(d.upnp.port)))
+	}
+	return status
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP connection security modes.
+const (
+	smtpStartTLS = "starttls" // plain connection upgraded with STARTTLS (port 587)
+	smtpTLS      = "tls"      // TLS from the start (port 465)
+	smtpNone     = "none"     // no encryption; only for local relays
+)
+
+// Email defaults.
+const (
+	defaultSMTPPort      = 587
+	emailAttachmentLimit = 20 << 20
+	smtpTimeout          = time.Minute
+)
+
+// smtpSecurity returns the configured security mode, defaulting to STARTTLS.
+func smtpSecurity(config *AppConfig) string {
+	if config.SMTPSecurity == "" {
+		return smtpStartTLS
+	}
+	return config.SMTPSecurity
+}
+
+// sendEmail sends a plain-text email with the given files attached to every
+// address in EmailTo, using the SMTP settings in config.
+func sendEmail(config *AppConfig, subject, body string, attachments []string) error {
+	msg, err := buildEmail(config.SMTPFrom, config.EmailTo, subject, body, attachments, time.Now())
+	if err != nil {
+		return err
+	}
+
+	port := config.SMTPPort
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	addr := net.JoinHostPort(config.SMTPHost, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	tlsConfig := &tls.Config{ServerName: config.SMTPHost}
+
+	var conn net.Conn
+	if smtpSecurity(config) == smtpTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to SMTP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, config.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connecting to SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if smtpSecurity(config) == smtpStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starting TLS: %w", err)
+		}
+	}
+	if config.SMTPUser != "" {
+		auth := smtp.PlainAuth("", config.SMTPUser, config.SMTPPassword, config.SMTPHost)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP login failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(config.SMTPFrom); err != nil {
+		return fmt.Errorf("SMTP sender rejected: %w", err)
+	}
+	for _, to := range config.EmailTo {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP recipient %s rejected: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("sending email: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("sending email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("sending email: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildEmail renders a MIME message: the text body, followed by the
+// attachments when there are any.
+func buildEmail(from string, to []string, subject, body string, attachments []string, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	body = strings.ReplaceAll(body, "\n", "\r\n")
+	if len(attachments) == 0 {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		buf.WriteString(body)
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, fmt.Errorf("building email: %w", err)
+	}
+	part.Write([]byte(body))
+
+	for _, path := range attachments {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading attachment: %w", err)
+		}
+		name := filepath.Base(path)
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType + "; name=" + strconv.Quote(name)},
+			"Content-Disposition":       {"attachment; filename=" + strconv.Quote(name)},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("building email: %w", err)
+		}
+		encoded := base64.StdEncoding.EncodeToString(data)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("building email: %w", err)
+	}
+	return buf.Bytes(), nil
+}
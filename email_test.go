@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one message and records the commands and data.
+type fakeSMTP struct {
+	ln       net.Listener
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.commands = append(s.commands, line)
+		switch verb := strings.ToUpper(strings.Fields(line)[0]); verb {
+		case "EHLO":
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			reply("235 ok")
+		case "MAIL", "RCPT":
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.data = b.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSendEmail(t *testing.T) {
+	srv := newFakeSMTP(t)
+	defer srv.ln.Close()
+
+	transcript := filepath.Join(t.TempDir(), "ConanExiles_log_2026-10-17.txt")
+	os.WriteFile(transcript, []byte("[2026-10-17 20:00:00] Conan: Hi\n"), 0644)
+
+	cfg := &AppConfig{
+		SMTPHost: "127.0.0.1", SMTPPort: srv.port(), SMTPSecurity: smtpNone,
+		SMTPUser: "logger", SMTPPassword: "pw", SMTPFrom: "logger@example.com",
+		EmailTo: []string{"a@example.com", "b@example.com"},
+	}
+	if err := sendEmail(cfg, "RP chat digest 2026-10-17", "Messages: 1\n", []string{transcript}); err != nil {
+		t.Fatal(err)
+	}
+	<-srv.done
+
+	cmds := strings.Join(srv.commands, "\n")
+	for _, want := range []string{"AUTH PLAIN", "MAIL FROM:<logger@example.com>", "RCPT TO:<a@example.com>", "RCPT TO:<b@example.com>"} {
+		if !strings.Contains(cmds, want) {
+			t.Errorf("missing %q in commands:\n%s", want, cmds)
+		}
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(srv.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("Subject"); got != "RP chat digest 2026-10-17" {
+		t.Errorf("Subject = %q", got)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	body, _ := mr.NextPart()
+	text, _ := io.ReadAll(body)
+	if string(text) != "Messages: 1\r\n" {
+		t.Errorf("body = %q", text)
+	}
+	attachment, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.FileName() != filepath.Base(transcript) {
+		t.Errorf("attachment name = %q", attachment.FileName())
+	}
+	encoded, _ := io.ReadAll(attachment)
+	decoded, _ := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+	if string(decoded) != "[2026-10-17 20:00:00] Conan: Hi\n" {
+		t.Errorf("attachment = %q", decoded)
+	}
+}
+
+func TestSendEmail_RequiresStartTLS(t *testing.T) {
+	srv := newFakeSMTP(t)
+	defer srv.ln.Close()
+
+	cfg := &AppConfig{SMTPHost: "127.0.0.1", SMTPPort: srv.port(), SMTPFrom: "x@example.com", EmailTo: []string{"a@example.com"}}
+	err := sendEmail(cfg, "s", "b", nil)
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("expected a STARTTLS error, got %v", err)
+	}
+}
+
+func TestBuildEmail_PlainText(t *testing.T) {
+	now := time.Date(2026, 10, 17, 23, 55, 0, 0, time.UTC)
+	data, err := buildEmail("a@example.com", []string{"b@example.com"}, "Digest — Tavern", "line 1\nline 2\n", nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Digest — Tavern" {
+		t.Errorf("Subject = %q", subject)
+	}
+	if date, _ := msg.Header.Date(); !date.Equal(now) {
+		t.Errorf("Date = %v", date)
+	}
+	body, _ := io.ReadAll(msg.Body)
+	if string(body) != "line 1\r\nline 2\r\n" {
+		t.Errorf("body = %q", body)
+	}
+}
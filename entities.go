@@ -0,0 +1,311 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kinds of entities in the index.
+const (
+	entityCharacter = "character" // someone who sent messages
+	entityName      = "name"      // a proper noun mentioned in messages
+)
+
+const (
+	// minEntityMentions is how often a proper noun must be mentioned to be
+	// indexed; names that come up once or twice are mostly noise.
+	minEntityMentions = 3
+	// entityLinks is how many of the entities seen with it most often an
+	// entity lists.
+	entityLinks = 5
+	// defaultEntityDays and maxEntityDays bound the window indexed by the
+	// API.
+	defaultEntityDays = 30
+	maxEntityDays     = 3660
+)
+
+// Entity is a character or proper noun of the entity index: who or what
+// appeared in the logs, when, and with whom.
+type Entity struct {
+	Name      string       `json:"name"`
+	Kind      string       `json:"kind"`     // "character" or "name"
+	Messages  int          `json:"messages"` // sent by a character
+	Mentions  int          `json:"mentions"` // named in messages
+	FirstSeen string       `json:"firstSeen"`
+	LastSeen  string       `json:"lastSeen"`
+	Days      []string     `json:"days"`
+	Scenes    []string     `json:"scenes"` // sessions, scenes or days, as in campaignChapters
+	With      []EntityLink `json:"with,omitempty"`
+}
+
+// EntityLink counts the scenes an entity shares with another.
+type EntityLink struct {
+	Name   string `json:"name"`
+	Scenes int    `json:"scenes"`
+}
+
+// entityStopWords are capitalized words that aren't names: the ones
+// sentences start with, prepositions and the pronoun I.
+var entityStopWords = func() map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.Fields(`a an the i i'm i'll i've i'd oh ok okay yes yeah no nope hey hi hello
+		well so but and or then now what why how who where when which this that these those he she it we you
+		they me my your his her its our their let's lol omg ooc brb afk thanks thank please sorry if not is
+		are was do does did can will just maybe also there here all some one good great nice sure wait look
+		come go mr mrs ms sir lady lord to in at on of for with from by into onto over under after before`) {
+		words[word] = true
+	}
+	return words
+}()
+
+// properNoun is a run of capitalized words in a message.
+type properNoun struct {
+	name string
+	// start is set for runs opening a sentence, which may just be
+	// capitalized for that.
+	start bool
+}
+
+// properNouns finds the runs of capitalized words in a message, such as
+// "Black Tower" or "Valeria". Stop words are dropped from their ends, and
+// possessives and punctuation are removed.
+func properNouns(message string) []properNoun {
+	var nouns []properNoun
+	var run []string
+	runStart, sentenceStart := false, true
+	flush := func() {
+		for len(run) > 0 && entityStopWords[strings.ToLower(run[0])] {
+			run = run[1:]
+			runStart = false
+		}
+		for len(run) > 0 && entityStopWords[strings.ToLower(run[len(run)-1])] {
+			run = run[:len(run)-1]
+		}
+		if len(run) > 0 {
+			nouns = append(nouns, properNoun{name: strings.Join(run, " "), start: runStart})
+		}
+		run = nil
+	}
+	for _, field := range strings.Fields(message) {
+		word := strings.TrimLeftFunc(field, func(r rune) bool { return isEntityPunct(r) || r == '\'' || r == '‘' })
+		if word != field {
+			flush() // an opening quote or bracket
+		}
+		trimmed := strings.TrimRightFunc(word, isEntityPunct)
+		trailing := word[len(trimmed):]
+		word = strings.TrimSuffix(strings.TrimSuffix(trimmed, "'s"), "’s")
+
+		if isCapitalized(word) {
+			if len(run) == 0 {
+				runStart = sentenceStart
+			}
+			run = append(run, word)
+		} else {
+			flush()
+		}
+		sentenceStart = false
+		if trailing != "" {
+			flush()
+			sentenceStart = strings.ContainsAny(trailing, ".!?…")
+		}
+	}
+	flush()
+	return nouns
+}
+
+// isEntityPunct reports punctuation around words.
+func isEntityPunct(r rune) bool {
+	return unicode.IsPunct(r) && r != '\'' && r != '’' && r != '-' || unicode.IsSymbol(r)
+}
+
+// isCapitalized reports a word that starts with a capital letter and has
+// lowercase letters after it, which leaves out acronyms like OOC.
+func isCapitalized(word string) bool {
+	first, size := utf8.DecodeRuneInString(word)
+	if !unicode.IsUpper(first) {
+		return false
+	}
+	return strings.IndexFunc(word[size:], unicode.IsLower) >= 0
+}
+
+// buildEntityIndex indexes the characters who sent entries, in time
+// order, and the proper nouns they mention at least minEntityMentions
+// times, with the days and scenes each appeared in and whom they appeared
+// with. Names match characters regardless of case. A word only capitalized
+// at the start of sentences is not taken for a name. The index is sorted
+// by messages and mentions, most first.
+func buildEntityIndex(entries []LogEntry) []Entity {
+	characters := make(map[string]string)
+	named := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Sender != "" {
+			characters[strings.ToLower(entry.Sender)] = entry.Sender
+		}
+		for _, noun := range properNouns(entry.Message) {
+			if !noun.start {
+				named[strings.ToLower(noun.name)] = true
+			}
+		}
+	}
+
+	index := make(map[string]*Entity)
+	chapters := campaignChapters(entries)
+	present := make([]map[string]bool, len(chapters))
+	for i, chapter := range chapters {
+		present[i] = make(map[string]bool)
+		for _, entry := range chapter.Entries {
+			day, _, _ := strings.Cut(entry.Timestamp, " ")
+			note := func(key, name, kind string) *Entity {
+				entity := index[key]
+				if entity == nil {
+					entity = &Entity{Name: name, Kind: kind, FirstSeen: entry.Timestamp, Days: []string{}, Scenes: []string{}}
+					index[key] = entity
+				}
+				entity.LastSeen = entry.Timestamp
+				if n := len(entity.Days); n == 0 || entity.Days[n-1] != day {
+					entity.Days = append(entity.Days, day)
+				}
+				if !present[i][key] {
+					entity.Scenes = append(entity.Scenes, chapter.Title)
+					present[i][key] = true
+				}
+				return entity
+			}
+			if entry.Sender != "" {
+				note(strings.ToLower(entry.Sender), entry.Sender, entityCharacter).Messages++
+			}
+			for _, noun := range properNouns(entry.Message) {
+				key := strings.ToLower(noun.name)
+				if name, ok := characters[key]; ok {
+					note(key, name, entityCharacter).Mentions++
+				} else if named[key] {
+					note(key, noun.name, entityName).Mentions++
+				}
+			}
+		}
+	}
+	for key, entity := range index {
+		if entity.Kind == entityName && entity.Mentions < minEntityMentions {
+			delete(index, key)
+		}
+	}
+
+	shared := make(map[string]map[string]int)
+	for _, keys := range present {
+		for a := range keys {
+			if index[a] == nil {
+				continue
+			}
+			for b := range keys {
+				if a == b || index[b] == nil {
+					continue
+				}
+				if shared[a] == nil {
+					shared[a] = make(map[string]int)
+				}
+				shared[a][b]++
+			}
+		}
+	}
+
+	entities := make([]Entity, 0, len(index))
+	for key, entity := range index {
+		for other, scenes := range shared[key] {
+			entity.With = append(entity.With, EntityLink{Name: index[other].Name, Scenes: scenes})
+		}
+		sort.Slice(entity.With, func(i, j int) bool {
+			if entity.With[i].Scenes != entity.With[j].Scenes {
+				return entity.With[i].Scenes > entity.With[j].Scenes
+			}
+			return entity.With[i].Name < entity.With[j].Name
+		})
+		if len(entity.With) > entityLinks {
+			entity.With = entity.With[:entityLinks]
+		}
+		entities = append(entities, *entity)
+	}
+	sort.Slice(entities, func(i, j int) bool {
+		ci, cj := entities[i].Messages+entities[i].Mentions, entities[j].Messages+entities[j].Mentions
+		if ci != cj {
+			return ci > cj
+		}
+		return entities[i].Name < entities[j].Name
+	})
+	return entities
+}
+
+// searchEntities returns the entities of kind (any when empty) whose name
+// contains query, ignoring case.
+func searchEntities(entities []Entity, query, kind string) []Entity {
+	query = strings.ToLower(strings.TrimSpace(query))
+	found := make([]Entity, 0)
+	for _, entity := range entities {
+		if kind != "" && entity.Kind != kind {
+			continue
+		}
+		if strings.Contains(strings.ToLower(entity.Name), query) {
+			found = append(found, entity)
+		}
+	}
+	return found
+}
+
+// apiEntityList is the entity index of a window of days.
+type apiEntityList struct {
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Total    int      `json:"total"`
+	Entities []Entity `json:"entities"`
+}
+
+// handleAPIEntities returns the entity index of the last ?days= days
+// (default 30), searched with ?q= and ?kind=.
+func (a *App) handleAPIEntities(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	days := defaultEntityDays
+	if value := query.Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days < 1 || days > maxEntityDays {
+			writeJSONError(w, http.StatusBadRequest, "days must be a number between 1 and "+strconv.Itoa(maxEntityDays))
+			return
+		}
+	}
+	kind := query.Get("kind")
+	if kind != "" && kind != entityCharacter && kind != entityName {
+		writeJSONError(w, http.StatusBadRequest, "kind must be character or name")
+		return
+	}
+	limit, err := queryLimit(r, defaultAPILimit, 1000)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	if !cfg.EnableLocalSave || cfg.Path == "" {
+		writeJSONError(w, http.StatusConflict, "the index is built from the stored logs; enable file logging")
+		return
+	}
+
+	now := time.Now()
+	from := truncateToDay(now).AddDate(0, 0, 1-days)
+	entries, _, err := entriesBetween(&cfg, from, now.Add(time.Second))
+	if err != nil {
+		a.logger.Log("error", "Reading logs for the entity index failed: "+err.Error())
+		writeJSONError(w, http.StatusInternalServerError, "failed to read log files")
+		return
+	}
+	found := searchEntities(buildEntityIndex(entries), query.Get("q"), kind)
+	list := apiEntityList{From: from.Format("2006-01-02"), To: now.Format("2006-01-02"), Total: len(found), Entities: found}
+	if len(list.Entities) > limit {
+		list.Entities = list.Entities[:limit]
+	}
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProperNouns(t *testing.T) {
+	tests := []struct {
+		message string
+		want    []properNoun
+	}{
+		{"We ride for the Black Tower at dawn.", []properNoun{{name: "Black Tower"}}},
+		{"Valeria's blade is sharp", []properNoun{{name: "Valeria", start: true}}},
+		{"Hello Conan, meet Subotai!", []properNoun{{name: "Conan"}, {name: "Subotai"}}},
+		{"Look out. Thoth-Amon is here", []properNoun{{name: "Thoth-Amon", start: true}}},
+		{`She said "Khitai" twice`, []properNoun{{name: "Khitai"}}},
+		{"brb OOC, I'm AFK", nil},
+		{"The end", nil},
+	}
+	for _, tt := range tests {
+		if got := properNouns(tt.message); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("properNouns(%q) = %+v, want %+v", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestBuildEntityIndex(t *testing.T) {
+	entries := []LogEntry{
+		{Timestamp: "2026-10-16 20:00:00", Sender: "Conan", Message: "We ride for the Black Tower.", Session: "Chapter 1"},
+		{Timestamp: "2026-10-16 20:01:00", Sender: "Valeria", Message: "conan, the Black Tower is warded.", Session: "Chapter 1"},
+		{Timestamp: "2026-10-16 20:02:00", Sender: "Conan", Message: "Then we go around to Shadizar.", Session: "Chapter 1"},
+		{Timestamp: "2026-10-17 21:00:00", Sender: "Conan", Message: "Back at the Black Tower again", Session: "Chapter 2"},
+		{Timestamp: "2026-10-17 21:05:00", Sender: "Subotai", Message: "Tower? Which one?", Session: "Chapter 2"},
+	}
+	index := buildEntityIndex(entries)
+
+	byName := make(map[string]Entity)
+	for _, entity := range index {
+		byName[entity.Name] = entity
+	}
+	if _, ok := byName["Shadizar"]; ok {
+		t.Error("a name mentioned once was indexed")
+	}
+	if _, ok := byName["Tower"]; ok {
+		t.Error("a word only capitalized at the start of a sentence was indexed")
+	}
+
+	conan := byName["Conan"]
+	if conan.Kind != entityCharacter || conan.Messages != 3 || conan.Mentions != 0 {
+		t.Errorf("Conan = %+v", conan)
+	}
+	tower := byName["Black Tower"]
+	if tower.Kind != entityName || tower.Mentions != 3 || tower.FirstSeen != "2026-10-16 20:00:00" || tower.LastSeen != "2026-10-17 21:00:00" {
+		t.Errorf("Black Tower = %+v", tower)
+	}
+	if !reflect.DeepEqual(tower.Days, []string{"2026-10-16", "2026-10-17"}) || !reflect.DeepEqual(tower.Scenes, []string{"Chapter 1", "Chapter 2"}) {
+		t.Errorf("Black Tower appeared on %v in %v", tower.Days, tower.Scenes)
+	}
+	wantWith := []EntityLink{{Name: "Conan", Scenes: 2}, {Name: "Subotai", Scenes: 1}, {Name: "Valeria", Scenes: 1}}
+	if !reflect.DeepEqual(tower.With, wantWith) {
+		t.Errorf("Black Tower with %+v, want %+v", tower.With, wantWith)
+	}
+	for i := 1; i < len(index); i++ {
+		if index[i].Messages+index[i].Mentions > index[i-1].Messages+index[i-1].Mentions {
+			t.Errorf("index not sorted by activity: %q before %q", index[i-1].Name, index[i].Name)
+		}
+	}
+
+	if got := searchEntities(index, "tow", ""); len(got) != 1 || got[0].Name != "Black Tower" {
+		t.Errorf("search tow = %+v", got)
+	}
+	if got := searchEntities(index, "", entityCharacter); len(got) != 3 {
+		t.Errorf("characters = %+v", got)
+	}
+}
+
+func TestAPIEntities(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	mux := apiTestServer(a)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/entities", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("without file logging: status %d", rec.Code)
+	}
+
+	a.config.EnableLocalSave = true
+	a.config.Path = t.TempDir()
+	a.config.FileFormat = "json"
+	now := time.Now().Format(logTimestampLayout)
+	for _, msg := range []string{"To Zamora!", "Zamora awaits.", "In Zamora we trust"} {
+		if err := logToFile(a.config, LogEntry{Timestamp: now, Sender: "Conan", Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/entities?q=zam&days=7", nil))
+	var list apiEntityList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %v", rec.Code, err)
+	}
+	if list.Total != 1 || list.Entities[0].Name != "Zamora" || list.Entities[0].Mentions != 3 {
+		t.Errorf("entities = %+v", list)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/entities?kind=place", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad kind: status %d", rec.Code)
+	}
+}
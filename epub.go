@@ -0,0 +1,360 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxEPUBDays bounds the date range of a campaign book.
+	maxEPUBDays = 3660
+	// epubCastSize is how many of the most active senders the title page
+	// lists.
+	epubCastSize = 12
+	// epubDateLayout is how dates are written in a campaign book.
+	epubDateLayout = "Monday, 2 January 2006"
+)
+
+// epubChapter is one chapter of a campaign book: a session, a scene or,
+// for messages logged outside both, a day.
+type epubChapter struct {
+	Title   string
+	Entries []LogEntry
+}
+
+// epubBook is a campaign book as written by writeEPUB.
+type epubBook struct {
+	Title    string
+	From, To time.Time // first and last day covered
+	Modified time.Time
+	Chapters []epubChapter
+	Colors   map[string]string   // sender colors, from characterColors
+	Notes    map[string][]string // GM notes by noteKey, from applyAnnotations
+}
+
+// campaignChapters splits entries, in time order, into chapters. A run of
+// messages from the same session forms a chapter, as does a run from the
+// same scene outside sessions; everything else is grouped by day. txt and
+// docx logs don't record sessions or scenes, so they come out by day.
+func campaignChapters(entries []LogEntry) []epubChapter {
+	var chapters []epubChapter
+	lastKey := ""
+	for _, entry := range entries {
+		key, title := "", ""
+		switch {
+		case entry.Session != "":
+			key, title = "session\x00"+entry.Session, entry.Session
+		case entry.Scene != "":
+			key, title = "scene\x00"+entry.Scene, entry.Scene
+		default:
+			day, _, _ := strings.Cut(entry.Timestamp, " ")
+			key, title = "day\x00"+day, day
+			if t, err := time.ParseInLocation("2006-01-02", day, time.Local); err == nil {
+				title = t.Format(epubDateLayout)
+			}
+		}
+		if len(chapters) == 0 || key != lastKey {
+			chapters = append(chapters, epubChapter{Title: title})
+			lastKey = key
+		}
+		last := &chapters[len(chapters)-1]
+		last.Entries = append(last.Entries, entry)
+	}
+	return chapters
+}
+
+// writeEPUB writes book as an EPUB 3 file, with an EPUB 2 table of
+// contents for older readers.
+func writeEPUB(w io.Writer, book epubBook) error {
+	zw := zip.NewWriter(w)
+	// The mimetype comes first and uncompressed, so readers can identify
+	// the file from its first bytes.
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mw, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []struct{ name, content string }{
+		{"META-INF/container.xml", epubContainer},
+		{"OEBPS/content.opf", epubPackage(book)},
+		{"OEBPS/nav.xhtml", epubNav(book)},
+		{"OEBPS/toc.ncx", epubNCX(book)},
+		{"OEBPS/style.css", epubStyle},
+		{"OEBPS/title.xhtml", epubTitlePage(book)},
+	}
+	for i, chapter := range book.Chapters {
+		files = append(files, struct{ name, content string }{"OEBPS/" + epubChapterFile(i), epubChapterPage(chapter, book.Colors, book.Notes)})
+	}
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, file.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const epubStyle = `body { font-family: serif; line-height: 1.5; }
+h1, h2, h3 { text-align: center; }
+h3.day { font-size: 0.9em; font-style: italic; margin-top: 2em; }
+p { margin: 0 0 0.6em; text-indent: 0; }
+p.dates, p.cast { text-align: center; }
+p.emote { font-style: italic; }
+p.ooc { color: #666; font-size: 0.85em; }
+p.note { margin-left: 2em; font-size: 0.85em; font-style: italic; }
+.time { color: #888; font-size: 0.75em; }
+`
+
+func epubChapterFile(i int) string {
+	return fmt.Sprintf("chapter-%03d.xhtml", i+1)
+}
+
+// epubIdentifier derives a stable identifier from the book's title and
+// range, so exporting the same range again updates the book in a library
+// instead of adding a copy.
+func epubIdentifier(book epubBook) string {
+	sum := sha256.Sum256([]byte(book.Title + "\x00" + book.From.Format("2006-01-02") + "\x00" + book.To.Format("2006-01-02")))
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func epubPackage(book epubBook) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&b, "    <dc:identifier id=\"book-id\">%s</dc:identifier>\n", epubIdentifier(book))
+	fmt.Fprintf(&b, "    <dc:title>%s</dc:title>\n", xmlText(book.Title))
+	b.WriteString("    <dc:creator>RP Chat Logger</dc:creator>\n    <dc:language>en</dc:language>\n")
+	fmt.Fprintf(&b, "    <meta property=\"dcterms:modified\">%s</meta>\n", book.Modified.UTC().Format("2006-01-02T15:04:05Z"))
+	b.WriteString(`  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    <item id="title" href="title.xhtml" media-type="application/xhtml+xml"/>
+`)
+	for i := range book.Chapters {
+		fmt.Fprintf(&b, "    <item id=\"chapter-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, epubChapterFile(i))
+	}
+	b.WriteString("  </manifest>\n  <spine toc=\"ncx\">\n    <itemref idref=\"title\"/>\n")
+	for i := range book.Chapters {
+		fmt.Fprintf(&b, "    <itemref idref=\"chapter-%d\"/>\n", i+1)
+	}
+	b.WriteString("  </spine>\n</package>\n")
+	return b.String()
+}
+
+func epubNav(book epubBook) string {
+	var b strings.Builder
+	b.WriteString(epubPageStart("Contents"))
+	b.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n<h1>Contents</h1>\n<ol>\n")
+	for i, chapter := range book.Chapters {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", epubChapterFile(i), xmlText(chapter.Title))
+	}
+	b.WriteString("</ol>\n</nav>\n</body>\n</html>\n")
+	return b.String()
+}
+
+func epubNCX(book epubBook) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+`)
+	fmt.Fprintf(&b, "    <meta name=\"dtb:uid\" content=\"%s\"/>\n", epubIdentifier(book))
+	fmt.Fprintf(&b, "  </head>\n  <docTitle><text>%s</text></docTitle>\n  <navMap>\n", xmlText(book.Title))
+	for i, chapter := range book.Chapters {
+		fmt.Fprintf(&b, "    <navPoint id=\"nav-%d\" playOrder=\"%d\"><navLabel><text>%s</text></navLabel><content src=\"%s\"/></navPoint>\n",
+			i+1, i+1, xmlText(chapter.Title), epubChapterFile(i))
+	}
+	b.WriteString("  </navMap>\n</ncx>\n")
+	return b.String()
+}
+
+// epubTitlePage shows the title, the dates covered and the most active
+// senders.
+func epubTitlePage(book epubBook) string {
+	var b strings.Builder
+	b.WriteString(epubPageStart(book.Title))
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", xmlText(book.Title))
+	dates := book.From.Format("2 January 2006")
+	if !book.To.Equal(book.From) {
+		dates += " – " + book.To.Format("2 January 2006")
+	}
+	fmt.Fprintf(&b, "<p class=\"dates\">%s</p>\n", xmlText(dates))
+
+	var entries []LogEntry
+	for _, chapter := range book.Chapters {
+		entries = append(entries, chapter.Entries...)
+	}
+	var cast []string
+	for _, sender := range aggregateEntries(entries).TopSenders(epubCastSize) {
+		cast = append(cast, xmlText(sender.Sender))
+	}
+	if len(cast) > 0 {
+		fmt.Fprintf(&b, "<p class=\"cast\">With %s</p>\n", strings.Join(cast, ", "))
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// epubChapterPage renders a chapter, with a heading wherever a new day
+// starts inside it. Senders with a color in colors are shown in it, and
+// the GM's notes in notes follow their messages.
+func epubChapterPage(chapter epubChapter, colors map[string]string, notes map[string][]string) string {
+	var b strings.Builder
+	b.WriteString(epubPageStart(chapter.Title))
+	fmt.Fprintf(&b, "<h2>%s</h2>\n", xmlText(chapter.Title))
+	lastDay := ""
+	for _, entry := range chapter.Entries {
+		day, clock, _ := strings.Cut(entry.Timestamp, " ")
+		if day != lastDay {
+			// Day chapters are titled with the date already.
+			if t, err := time.ParseInLocation("2006-01-02", day, time.Local); err == nil && t.Format(epubDateLayout) != chapter.Title {
+				fmt.Fprintf(&b, "<h3 class=\"day\">%s</h3>\n", xmlText(t.Format(epubDateLayout)))
+			}
+		}
+		lastDay = day
+		if len(clock) > 5 {
+			clock = clock[:5]
+		}
+		sender, message := xmlText(entry.Sender), xmlText(entry.Message)
+		if color, ok := colors[entry.Sender]; ok && entry.Kind != kindOOC {
+			sender = fmt.Sprintf("<span style=\"color: %s\">%s</span>", color, sender)
+		}
+		switch entry.Kind {
+		case kindEmote:
+			fmt.Fprintf(&b, "<p class=\"emote\"><span class=\"time\">%s</span> %s %s</p>\n", clock, sender, message)
+		case kindOOC:
+			fmt.Fprintf(&b, "<p class=\"ooc\"><span class=\"time\">%s</span> (OOC) %s: %s</p>\n", clock, sender, message)
+		default:
+			fmt.Fprintf(&b, "<p><span class=\"time\">%s</span> <b>%s</b>: %s</p>\n", clock, sender, message)
+		}
+		for _, note := range notes[noteKey(entry)] {
+			fmt.Fprintf(&b, "<p class=\"note\">GM note: %s</p>\n", xmlText(note))
+		}
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+func epubPageStart(title string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="en">
+<head>
+<title>` + xmlText(title) + `</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+`
+}
+
+// xmlText escapes s for XHTML, dropping the control characters XML
+// doesn't allow.
+func xmlText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return -1
+		}
+		return r
+	}, s)
+	return html.EscapeString(s)
+}
+
+// handleExportEPUB downloads the logs of a date range as a campaign book.
+// from and to are days (YYYY-MM-DD), both included; OOC messages are left
+// out unless ooc is set.
+func (a *App) handleExportEPUB(w http.ResponseWriter, r *http.Request) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	if !cfg.EnableLocalSave || cfg.Path == "" {
+		http.Error(w, "Campaign books are made from the stored logs. Enable file logging first.", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := time.ParseInLocation("2006-01-02", query.Get("from"), time.Local)
+	if err != nil {
+		http.Error(w, "Invalid start date", http.StatusBadRequest)
+		return
+	}
+	to, err := time.ParseInLocation("2006-01-02", query.Get("to"), time.Local)
+	if err != nil || to.Before(from) {
+		http.Error(w, "Invalid end date", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxEPUBDays*24*time.Hour {
+		http.Error(w, fmt.Sprintf("A campaign book covers at most %d days", maxEPUBDays), http.StatusBadRequest)
+		return
+	}
+	title := strings.TrimSpace(query.Get("title"))
+	if title == "" {
+		title = "Campaign Log"
+	}
+
+	entries, _, err := entriesBetween(&cfg, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		a.logger.Log("error", fmt.Sprintf("Reading logs for a campaign book failed: %v", err))
+		http.Error(w, "Failed to read log files", http.StatusInternalServerError)
+		return
+	}
+	annotations, err := loadAnnotations(annotationsPath(cfg.Path))
+	if err != nil {
+		// The book is still worth having without them.
+		a.logger.Log("warning", fmt.Sprintf("Campaign book made without annotations: %v", err))
+	}
+	notes := applyAnnotations(entries, annotations)
+	if query.Get("ooc") != "on" {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.Kind != kindOOC {
+				kept = append(kept, entry)
+			}
+		}
+		entries = kept
+	}
+	if len(entries) == 0 {
+		http.Error(w, "No messages were logged in that range", http.StatusNotFound)
+		return
+	}
+	for i := range entries {
+		entries[i].Sender = displayName(&cfg, entries[i].Sender)
+	}
+
+	var buf bytes.Buffer
+	book := epubBook{Title: title, From: from, To: to, Modified: time.Now(), Chapters: campaignChapters(entries), Colors: characterColors(&cfg), Notes: notes}
+	if err := writeEPUB(&buf, book); err != nil {
+		http.Error(w, "Failed to write the campaign book", http.StatusInternalServerError)
+		return
+	}
+	name := fmt.Sprintf("%s_%s_%s.epub", sanitizeFilename(title), from.Format("2006-01-02"), to.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/epub+zip")
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(name))
+	w.Write(buf.Bytes())
+	a.logger.Log("info", fmt.Sprintf("Campaign book exported: %d messages in %d chapters", len(entries), len(book.Chapters)))
+}
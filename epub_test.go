@@ -0,0 +1,161 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCampaignChapters(t *testing.T) {
+	entries := []LogEntry{
+		{Timestamp: "2026-10-16 20:00:00", Sender: "Conan", Message: "a"},
+		{Timestamp: "2026-10-16 21:00:00", Sender: "Conan", Message: "b", Session: "The Tower"},
+		{Timestamp: "2026-10-17 00:30:00", Sender: "Bêlit", Message: "c", Session: "The Tower"},
+		{Timestamp: "2026-10-17 12:00:00", Sender: "Conan", Message: "d", Scene: "Tavern"},
+		{Timestamp: "2026-10-17 13:00:00", Sender: "Conan", Message: "e"},
+		{Timestamp: "2026-10-18 09:00:00", Sender: "Conan", Message: "f"},
+	}
+	want := []struct {
+		title   string
+		entries int
+	}{
+		{"Friday, 16 October 2026", 1},
+		{"The Tower", 2},
+		{"Tavern", 1},
+		{"Saturday, 17 October 2026", 1},
+		{"Sunday, 18 October 2026", 1},
+	}
+	got := campaignChapters(entries)
+	if len(got) != len(want) {
+		t.Fatalf("got %d chapters, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Title != w.title || len(got[i].Entries) != w.entries {
+			t.Errorf("chapter %d = %q with %d entries, want %q with %d", i, got[i].Title, len(got[i].Entries), w.title, w.entries)
+		}
+	}
+}
+
+func TestWriteEPUB(t *testing.T) {
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+	book := epubBook{
+		Title: "Tales & <Legends>",
+		From:  day, To: day,
+		Modified: day,
+		Chapters: campaignChapters([]LogEntry{
+			{Timestamp: "2026-10-17 20:00:00", Sender: "Conan", Message: "<b>By Crom</b> & \x01steel"},
+			{Timestamp: "2026-10-17 20:01:00", Sender: "Bêlit", Message: "smiles", Kind: kindEmote},
+		}),
+	}
+	var buf bytes.Buffer
+	if err := writeEPUB(&buf, book); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes()[30:], []byte("mimetypeapplication/epub+zip")) {
+		t.Error("mimetype is not the first, uncompressed entry")
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(data)
+		if strings.HasSuffix(f.Name, ".xhtml") || strings.HasSuffix(f.Name, ".opf") || strings.HasSuffix(f.Name, ".ncx") {
+			if err := wellFormed(data); err != nil {
+				t.Errorf("%s is not well-formed XML: %v", f.Name, err)
+			}
+		}
+	}
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/toc.ncx", "OEBPS/chapter-001.xhtml"} {
+		if _, ok := contents[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+	chapter := contents["OEBPS/chapter-001.xhtml"]
+	if !strings.Contains(chapter, "&lt;b&gt;By Crom&lt;/b&gt; &amp; steel") {
+		t.Errorf("message not escaped: %s", chapter)
+	}
+	if !strings.Contains(contents["OEBPS/title.xhtml"], "With Bêlit, Conan") {
+		t.Errorf("title page: %s", contents["OEBPS/title.xhtml"])
+	}
+}
+
+// wellFormed reports whether data parses as XML.
+func wellFormed(data []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = true
+	for {
+		if _, err := d.Token(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func TestHandleExportEPUB(t *testing.T) {
+	dir := t.TempDir()
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+	a.config.FileFormat = "json"
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+	for _, entry := range []LogEntry{
+		{Timestamp: "2026-10-17 20:00:00", Sender: "Conan", Message: "By Crom", Session: "The Tower"},
+		{Timestamp: "2026-10-17 20:01:00", Sender: "Conan", Message: "brb", Kind: kindOOC},
+	} {
+		if err := writeLogEntry(logFilePath(a.config, dir, day, entry), "json", logLayout{}, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"from=2026-10-17&to=2026-10-17&title=Tower", http.StatusOK},
+		{"from=2026-10-18&to=2026-10-18", http.StatusNotFound},
+		{"from=2026-10-18&to=2026-10-17", http.StatusBadRequest},
+		{"from=yesterday&to=2026-10-17", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		a.handleExportEPUB(rec, httptest.NewRequest("GET", "/api/export/epub?"+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.query, rec.Code, tt.status, rec.Body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	a.handleExportEPUB(rec, httptest.NewRequest("GET", "/api/export/epub?from=2026-10-17&to=2026-10-17&title=Tower", nil))
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="Tower_2026-10-17_2026-10-17.epub"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if filepath.Base(f.Name) == "chapter-002.xhtml" {
+			t.Error("OOC message made a chapter of its own")
+		}
+	}
+}
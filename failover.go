@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultFailoverAfter is how many sends in a row must fail before a
+	// Discord target fails over, when DiscordFailoverAfter is 0.
+	defaultFailoverAfter = 3
+	// failoverProbeInterval is how often a failed-over target is tried
+	// again, with the next message sent to it.
+	failoverProbeInterval = time.Minute
+)
+
+// discordFailover tracks the Discord targets that keep failing and, once
+// one has failed DiscordFailoverAfter times in a row, sends its messages
+// to the failover targets until it works again. The zero value is ready
+// to use.
+type discordFailover struct {
+	mu     sync.Mutex
+	states map[string]*failoverState
+}
+
+// failoverState is the health of one Discord target.
+type failoverState struct {
+	failures  int // sends in a row that failed
+	engaged   bool
+	since     time.Time // when it failed over
+	lastProbe time.Time
+}
+
+// discordRouteURL returns where a configured Discord route posts: the
+// route itself, or in bot mode, a channel ID's URL.
+func discordRouteURL(cfg *AppConfig, route string) string {
+	if cfg.DiscordMode == discordModeBot && !strings.HasPrefix(route, "http") {
+		return botChannelURL(cfg.BotToken, route)
+	}
+	return route
+}
+
+// failoverTargets returns the configured failover targets as URLs.
+func failoverTargets(cfg *AppConfig) []string {
+	targets := make([]string, 0, len(cfg.DiscordFailover))
+	for _, route := range cfg.DiscordFailover {
+		targets = append(targets, discordRouteURL(cfg, route))
+	}
+	return targets
+}
+
+// targets returns the URLs to try, in order, for a message to primary:
+// primary alone while it works, the failover targets once it has failed
+// over, and primary first when it is due to be tried again.
+func (f *discordFailover) targets(cfg *AppConfig, primary string, now time.Time) []string {
+	failovers := failoverTargets(cfg)
+	if len(failovers) == 0 {
+		return []string{primary}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := f.states[primary]
+	if state == nil || !state.engaged {
+		return []string{primary}
+	}
+	if now.Sub(state.lastProbe) >= failoverProbeInterval {
+		state.lastProbe = now
+		return append([]string{primary}, failovers...)
+	}
+	return failovers
+}
+
+// record notes the outcome of a send to primary. It reports whether this
+// failure made primary fail over or this success ended its failover, and
+// since when it had failed over.
+func (f *discordFailover) record(cfg *AppConfig, primary string, err error, now time.Time) (changed bool, since time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.states == nil {
+		f.states = make(map[string]*failoverState)
+	}
+	state := f.states[primary]
+	if state == nil {
+		if err == nil {
+			return false, time.Time{}
+		}
+		state = &failoverState{}
+		f.states[primary] = state
+	}
+	if err == nil {
+		delete(f.states, primary)
+		return state.engaged, state.since
+	}
+	state.failures++
+	if state.engaged || state.failures < failoverAfter(cfg) || len(cfg.DiscordFailover) == 0 {
+		return false, state.since
+	}
+	state.engaged, state.since, state.lastProbe = true, now, now
+	return true, now
+}
+
+// failoverAfter is how many sends in a row must fail before a target
+// fails over.
+func failoverAfter(cfg *AppConfig) int {
+	if cfg.DiscordFailoverAfter <= 0 {
+		return defaultFailoverAfter
+	}
+	return cfg.DiscordFailoverAfter
+}
+
+// engaged reports how many Discord targets have failed over.
+func (f *discordFailover) engaged() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, state := range f.states {
+		if state.engaged {
+			n++
+		}
+	}
+	return n
+}
+
+// sendWithFailover sends a message to primary through send, or to the
+// failover targets while primary has failed over, trying each until one
+// works or rate limits. It returns the target used. Rate limits don't
+// count as failures: the target is up, just busy.
+func (a *App) sendWithFailover(cfg *AppConfig, trace, primary string, send func(url string) (rateLimited bool, err error)) (string, bool, error) {
+	var rateLimited bool
+	var err error
+	urls := a.failover.targets(cfg, primary, time.Now())
+	for i := 0; i < len(urls); i++ {
+		url := urls[i]
+		rateLimited, err = send(url)
+		if url == primary && !rateLimited && a.recordFailover(cfg, trace, primary, err) && err != nil {
+			// This failure failed primary over: the message goes too.
+			urls = append(urls, failoverTargets(cfg)...)
+		}
+		if err == nil || rateLimited {
+			return url, rateLimited, err
+		}
+	}
+	return primary, rateLimited, err
+}
+
+// recordFailover records a send to primary and announces a failover
+// starting or ending, in the log and on Discord. It reports whether the
+// failover started or ended.
+func (a *App) recordFailover(cfg *AppConfig, trace, primary string, sendErr error) bool {
+	now := time.Now()
+	changed, since := a.failover.record(cfg, primary, sendErr, now)
+	if !changed {
+		return false
+	}
+	// The notices are posted before the message, so the channels read in
+	// order.
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if sendErr != nil {
+		a.logger.Log("warning", traced(trace, fmt.Sprintf("Discord target failed %d times in a row, failing over: %v", failoverAfter(cfg), sendErr)))
+		// The error may hold the webhook URL, so it stays out of Discord.
+		notice := "_The usual Discord channel is unreachable; messages are posted here until it is back._"
+		if err := sendDiscordNotice(ctx, failoverTargets(cfg)[0], notice); err != nil {
+			a.logger.Log("error", fmt.Sprintf("Failover notice failed: %v", err))
+		}
+		return true
+	}
+	a.logger.Log("info", traced(trace, fmt.Sprintf("Discord target recovered after %v; failover ended", now.Sub(since).Round(time.Second))))
+	notice := fmt.Sprintf("_Back online. Messages from %s to %s were posted to the failover channel._", since.Format("15:04"), now.Format("15:04"))
+	if err := sendDiscordNotice(ctx, primary, notice); err != nil {
+		a.logger.Log("error", fmt.Sprintf("Recovery notice failed: %v", err))
+	}
+	return true
+}
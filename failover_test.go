@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiscordFailover(t *testing.T) {
+	cfg := &AppConfig{DiscordFailover: []string{"https://b", "https://c"}, DiscordFailoverAfter: 2}
+	var f discordFailover
+	now := time.Now()
+	fail := errors.New("discord API returned status code: 500")
+
+	if changed, _ := f.record(cfg, "https://a", fail, now); changed {
+		t.Error("failed over after one failure")
+	}
+	if got := f.targets(cfg, "https://a", now); !reflect.DeepEqual(got, []string{"https://a"}) {
+		t.Errorf("before failover: targets = %v", got)
+	}
+	if changed, _ := f.record(cfg, "https://a", fail, now); !changed {
+		t.Error("not failed over after two failures")
+	}
+	if got := f.targets(cfg, "https://a", now); !reflect.DeepEqual(got, []string{"https://b", "https://c"}) {
+		t.Errorf("failed over: targets = %v", got)
+	}
+	if f.engaged() != 1 {
+		t.Errorf("engaged = %d", f.engaged())
+	}
+
+	later := now.Add(failoverProbeInterval)
+	if got := f.targets(cfg, "https://a", later); !reflect.DeepEqual(got, []string{"https://a", "https://b", "https://c"}) {
+		t.Errorf("probe: targets = %v", got)
+	}
+	if got := f.targets(cfg, "https://a", later); len(got) != 2 {
+		t.Errorf("probed twice: targets = %v", got)
+	}
+	if changed, since := f.record(cfg, "https://a", nil, later); !changed || !since.Equal(now) {
+		t.Errorf("recovery = %v, %v", changed, since)
+	}
+	if f.engaged() != 0 {
+		t.Errorf("engaged after recovery = %d", f.engaged())
+	}
+
+	if changed, _ := f.record(&AppConfig{DiscordFailoverAfter: 1}, "https://a", fail, now); changed {
+		t.Error("failed over without failover targets")
+	}
+}
+
+func TestDeliverToDiscord_Failover(t *testing.T) {
+	var mu sync.Mutex
+	primaryDown := true
+	var primary, backup []string
+	post := func(down *bool, got *[]string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var payload map[string]string
+			json.NewDecoder(r.Body).Decode(&payload)
+			mu.Lock()
+			defer mu.Unlock()
+			if down != nil && *down {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			*got = append(*got, payload["content"])
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	primarySrv := httptest.NewServer(post(&primaryDown, &primary))
+	defer primarySrv.Close()
+	backupSrv := httptest.NewServer(post(nil, &backup))
+	defer backupSrv.Close()
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableDiscord = true
+	a.config.WebhookURL = primarySrv.URL
+	a.config.DiscordFailover = []string{backupSrv.URL}
+	a.config.DiscordFailoverAfter = 2
+
+	send := func(message string) {
+		a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: message})
+		waitForDeliveries(t, a)
+	}
+	send("first")
+	send("second")
+	send("third")
+
+	mu.Lock()
+	if len(primary) != 0 || len(backup) != 3 || !strings.Contains(backup[0], "unreachable") ||
+		!strings.Contains(backup[1], "second") || !strings.Contains(backup[2], "third") {
+		t.Errorf("while down: primary got %q, backup got %q", primary, backup)
+	}
+	primaryDown = false
+	mu.Unlock()
+
+	// Probe the primary with the next message.
+	a.failover.mu.Lock()
+	a.failover.states[primarySrv.URL].lastProbe = time.Time{}
+	a.failover.mu.Unlock()
+	send("fourth")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(primary) != 2 || !strings.Contains(primary[0], "fourth") || !strings.Contains(primary[1], "Back online") {
+		t.Errorf("after recovery: primary got %q", primary)
+	}
+	if a.failover.engaged() != 0 {
+		t.Error("still failed over")
+	}
+}
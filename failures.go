@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// errNotRetryable is returned for failures recorded without retry details,
+// such as a failed digest.
+var errNotRetryable = errors.New("this failure cannot be retried")
+
+// retryFailure redelivers a recorded failure. Discord and forward
+// deliveries are re-queued with a fresh retry budget; file writes are
+// attempted again right away. The entry is removed once the message has
+// been handed off.
+func (a *App) retryFailure(id int64) error {
+	failure, ok := a.logger.GetFailure(id)
+	if !ok {
+		return fmt.Errorf("failure %d not found", id)
+	}
+	if failure.Retry == nil {
+		return errNotRetryable
+	}
+
+	switch retry := failure.Retry; {
+	case retry.Delivery != nil:
+		msg := *retry.Delivery
+		msg.Attempts = 0
+		msg.RetryAt = time.Time{}
+		if failure.FailureType == sinkForward {
+			a.receipts.set(msg.ID, sinkForward, deliveryPending)
+			a.forwardQueue.Add(msg)
+		} else {
+			a.receipts.set(msg.ID, sinkDiscord, deliveryPending)
+			a.discordQueue.Add(msg)
+		}
+	case retry.LogConfig != nil && retry.Entry != nil:
+		a.archiveMu.RLock()
+		err := logToFile(retry.LogConfig, *retry.Entry)
+		a.archiveMu.RUnlock()
+		if err != nil {
+			return fmt.Errorf("writing log entry: %w", err)
+		}
+		a.receipts.set(retry.MessageID, sinkFile, deliverySent)
+	default:
+		return errNotRetryable
+	}
+
+	a.logger.DismissFailure(id)
+	a.logger.Log("info", fmt.Sprintf("Retrying failed %s delivery from %s", failure.FailureType, failure.Sender))
+	return nil
+}
+
+// handleFailuresPage renders the failures page.
+func (a *App) handleFailuresPage(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := a.parseTemplates(
+		"templates/layout.html",
+		"templates/failures.html",
+		"templates/partials/failure_list.html",
+		"templates/partials/queue_list.html",
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := a.failuresData("", "")
+	data["QueuePanel"] = a.queueData("", "")
+	data["Prefs"] = a.uiPreferences(w, r)
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// handleFailures returns the failure list as an HTML partial.
+func (a *App) handleFailures(w http.ResponseWriter, r *http.Request) {
+	a.renderFailureList(w, "", "")
+}
+
+// handleRetryFailure re-queues a failed delivery and returns the updated list.
+func (a *App) handleRetryFailure(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		a.renderFailureList(w, "", "Invalid failure ID")
+		return
+	}
+	if err := a.retryFailure(id); err != nil {
+		a.renderFailureList(w, "", fmt.Sprintf("Retry failed: %v", err))
+		return
+	}
+	a.renderFailureList(w, "Message queued for redelivery", "")
+}
+
+// handleDismissFailure removes a failure and returns the updated list.
+func (a *App) handleDismissFailure(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || !a.logger.DismissFailure(id) {
+		a.renderFailureList(w, "", "Failure not found")
+		return
+	}
+	a.renderFailureList(w, "", "")
+}
+
+// handleClearFailures removes all failures and returns the empty list.
+func (a *App) handleClearFailures(w http.ResponseWriter, r *http.Request) {
+	a.logger.ClearFailures()
+	a.renderFailureList(w, "All failures dismissed", "")
+}
+
+func (a *App) renderFailureList(w http.ResponseWriter, message, errMsg string) {
+	tmpl, err := a.parseTemplates("templates/partials/failure_list.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "failure-list", a.failuresData(message, errMsg)); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// failuresData lists recorded failures, newest first.
+func (a *App) failuresData(message, errMsg string) map[string]interface{} {
+	failures := a.logger.GetFailures()
+	for i, j := 0, len(failures)-1; i < j; i, j = i+1, j-1 {
+		failures[i], failures[j] = failures[j], failures[i]
+	}
+	return map[string]interface{}{
+		"Failures": failures,
+		"Message":  message,
+		"Error":    errMsg,
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRetryFailure_File(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	tmpDir := t.TempDir()
+	logCfg := &AppConfig{EnableLocalSave: true, Path: tmpDir, FileFormat: "txt"}
+	entry := newLogEntry(logCfg, "Alice", "hello")
+	a.logger.LogRetryableFailure("Alice", "hello", "file", "disk full", "", &FailureRetry{LogConfig: logCfg, Entry: &entry})
+	a.logger.LogFailure("digest", "summary", "discord", "bad webhook")
+
+	failures := a.logger.GetFailures()
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures, got %d", len(failures))
+	}
+
+	if err := a.retryFailure(failures[1].ID); err != errNotRetryable {
+		t.Errorf("expected errNotRetryable for digest failure, got %v", err)
+	}
+	if err := a.retryFailure(failures[0].ID); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+
+	entries, err := readLogFile(generateLogFilename(tmpDir, "txt"), "txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Message != "hello" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+	if _, ok := a.logger.GetFailure(failures[0].ID); ok {
+		t.Error("retried failure should be removed")
+	}
+	if _, ok := a.logger.GetFailure(failures[1].ID); !ok {
+		t.Error("failure that could not be retried should be kept")
+	}
+}
+
+func TestHandleDismissFailure(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	a.logger.LogFailure("Alice", "first", "file", "disk full")
+	a.logger.LogFailure("Bob", "second", "file", "disk full")
+	id := a.logger.GetFailures()[0].ID
+
+	req := httptest.NewRequest("DELETE", "/api/failures/x", nil)
+	req.SetPathValue("id", strconv.FormatInt(id, 10))
+	rr := httptest.NewRecorder()
+	a.handleDismissFailure(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if _, ok := a.logger.GetFailure(id); ok {
+		t.Error("dismissed failure still listed")
+	}
+	body := rr.Body.String()
+	if strings.Contains(body, "first") || !strings.Contains(body, "second") {
+		t.Errorf("unexpected list after dismiss: %s", body)
+	}
+	if strings.Contains(body, "/retry") {
+		t.Error("failures without retry details should not offer a retry button")
+	}
+}
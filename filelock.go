@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// fileLocks serializes writes to each log file, so two messages written at
+// the same moment can't interleave, while writes to different files still
+// run in parallel. Unused locks are removed.
+type fileLocks struct {
+	mu    sync.Mutex
+	locks map[string]*fileLock
+}
+
+type fileLock struct {
+	sync.Mutex
+	users int // holders and waiters
+}
+
+// logFileLocks guards the log files of the process.
+var logFileLocks = &fileLocks{}
+
+// lock locks the file name and returns the function that unlocks it.
+func (l *fileLocks) lock(name string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*fileLock)
+	}
+	fl, ok := l.locks[name]
+	if !ok {
+		fl = &fileLock{}
+		l.locks[name] = fl
+	}
+	fl.users++
+	l.mu.Unlock()
+
+	fl.Lock()
+	return func() {
+		fl.Unlock()
+		l.mu.Lock()
+		if fl.users--; fl.users == 0 {
+			delete(l.locks, name)
+		}
+		l.mu.Unlock()
+	}
+}
+
+const (
+	// logFolderLockFile marks a log folder as in use by a running instance.
+	logFolderLockFile = ".rp-chat-logger.lock"
+	// logFolderLockRefresh is how often a held lock file is touched, and
+	// logFolderLockStale how old one may be before it is taken over, after
+	// a crash for example.
+	logFolderLockRefresh = time.Minute
+	logFolderLockStale   = 3 * time.Minute
+)
+
+// folderLockOwner is the content of a lock file.
+type folderLockOwner struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Version string    `json:"version"`
+	Started time.Time `json:"started"`
+}
+
+func (o folderLockOwner) String() string {
+	return fmt.Sprintf("process %d on %s, running since %s", o.PID, o.Host, o.Started.Format("2006-01-02 15:04"))
+}
+
+// folderLocks holds the advisory locks of the log folders this instance
+// writes to, and the folders another running instance already holds.
+type folderLocks struct {
+	mu        sync.Mutex
+	self      folderLockOwner
+	held      []string
+	conflicts map[string]folderLockOwner
+}
+
+func newFolderLocks() *folderLocks {
+	host, _ := os.Hostname()
+	return &folderLocks{
+		self:      folderLockOwner{PID: os.Getpid(), Host: host, Version: Version, Started: time.Now()},
+		conflicts: make(map[string]folderLockOwner),
+	}
+}
+
+// acquire locks dir unless another instance holds a fresh lock on it, in
+// which case it returns that instance. Own and stale locks are taken over.
+func (l *folderLocks) acquire(dir string, now time.Time) (*folderLockOwner, error) {
+	path := filepath.Join(dir, logFolderLockFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating log directory: %w", err)
+	}
+	data, err := json.Marshal(l.self)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		if owner, ok := readFolderLock(path, now); ok && (owner.PID != l.self.PID || owner.Host != l.self.Host) {
+			return owner, nil
+		}
+		// Stale, unreadable or our own: take it over.
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating lock file: %w", err)
+	}
+	_, err = file.Write(data)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("writing lock file: %w", err)
+	}
+	return nil, nil
+}
+
+// readFolderLock returns the owner of a lock file that was touched within
+// logFolderLockStale.
+func readFolderLock(path string, now time.Time) (*folderLockOwner, bool) {
+	info, err := os.Stat(path)
+	if err != nil || now.Sub(info.ModTime()) > logFolderLockStale {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var owner folderLockOwner
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return nil, false
+	}
+	return &owner, true
+}
+
+// lockLogFolders locks every folder in dirs, releasing the ones held
+// before that aren't among them, and warns about folders another instance
+// is writing to.
+func (a *App) lockLogFolders(dirs []string) {
+	l := a.folderLocks
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, dir := range l.held {
+		if !slices.Contains(dirs, dir) {
+			l.release(dir)
+		}
+	}
+	l.held = nil
+	clear(l.conflicts)
+	now := time.Now()
+	for _, dir := range dirs {
+		owner, err := l.acquire(dir, now)
+		switch {
+		case err != nil:
+			slog.Warn("Could not lock log folder", "dir", dir, "err", err)
+		case owner != nil:
+			l.conflicts[dir] = *owner
+			slog.Warn("Log folder in use by another instance", "dir", dir, "owner", owner.String())
+			a.logger.Log("warning", fmt.Sprintf("Another RP Chat Logger (%s) is writing to %s. Two instances logging to the same folder corrupt CSV and JSON logs; stop one or give each its own folder.", owner, dir))
+		default:
+			l.held = append(l.held, dir)
+		}
+	}
+}
+
+// unlockLogFolders releases every folder lock held.
+func (a *App) unlockLogFolders() {
+	l := a.folderLocks
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, dir := range l.held {
+		l.release(dir)
+	}
+	l.held = nil
+	clear(l.conflicts)
+}
+
+// release removes dir's lock file if this instance still owns it; l.mu
+// must be held.
+func (l *folderLocks) release(dir string) {
+	path := filepath.Join(dir, logFolderLockFile)
+	if owner, ok := readFolderLock(path, time.Now()); ok && owner.PID == l.self.PID && owner.Host == l.self.Host {
+		os.Remove(path)
+	}
+}
+
+// logFolderConflicts describes the folders another instance writes to.
+func (a *App) logFolderConflicts() []string {
+	l := a.folderLocks
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var conflicts []string
+	for dir, owner := range l.conflicts {
+		conflicts = append(conflicts, fmt.Sprintf("%s (%s)", dir, owner))
+	}
+	slices.Sort(conflicts)
+	return conflicts
+}
+
+// runFolderLockRefresher keeps the held lock files fresh, and retakes the
+// folders of instances that have stopped.
+func (a *App) runFolderLockRefresher() {
+	ticker := time.NewTicker(logFolderLockRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-ticker.C:
+			if !a.ingestionRunning.Load() {
+				continue
+			}
+			a.configMu.RLock()
+			dirs := logFolders(a.config)
+			a.configMu.RUnlock()
+			if !slices.Equal(dirs, a.lockedFolders()) {
+				// The log paths changed while running.
+				a.lockLogFolders(dirs)
+				continue
+			}
+			a.refreshFolderLocks(now)
+		}
+	}
+}
+
+// lockedFolders returns the folders locked or held by another instance.
+func (a *App) lockedFolders() []string {
+	l := a.folderLocks
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	dirs := slices.Clone(l.held)
+	for dir := range l.conflicts {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+	return dirs
+}
+
+func (a *App) refreshFolderLocks(now time.Time) {
+	l := a.folderLocks
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, dir := range l.held {
+		path := filepath.Join(dir, logFolderLockFile)
+		if err := os.Chtimes(path, now, now); err != nil {
+			// Deleted from under us: write it again.
+			if _, err := l.acquire(dir, now); err != nil {
+				slog.Warn("Could not refresh log folder lock", "dir", dir, "err", err)
+			}
+		}
+	}
+	for dir := range l.conflicts {
+		owner, err := l.acquire(dir, now)
+		if err != nil || owner != nil {
+			continue
+		}
+		delete(l.conflicts, dir)
+		l.held = append(l.held, dir)
+		a.logger.Log("info", fmt.Sprintf("The other instance stopped writing to %s; this one holds the folder now", dir))
+	}
+}
+
+// logFolders returns the folders cfg writes log files to: the log path,
+// the path of every source that has its own and the folders sender routes
+// copy entries to.
+func logFolders(cfg *AppConfig) []string {
+	if !cfg.EnableLocalSave {
+		return nil
+	}
+	return archiveFolders(cfg)
+}
+
+// archiveFolders returns the folders of logFolders whether or not file
+// logging is on, for the logs written before it was turned off.
+func archiveFolders(cfg *AppConfig) []string {
+	if cfg.Path == "" {
+		return nil
+	}
+	dirs := []string{filepath.Clean(cfg.Path)}
+	add := func(path string) {
+		if path != "" && !slices.Contains(dirs, filepath.Clean(path)) {
+			dirs = append(dirs, filepath.Clean(path))
+		}
+	}
+	for _, profile := range cfg.Sources {
+		add(profile.Path)
+	}
+	for _, route := range cfg.SenderRoutes {
+		add(route.Path)
+	}
+	slices.Sort(dirs)
+	return dirs
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileLocks_Serialize(t *testing.T) {
+	var l fileLocks
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	inside := map[string]int{}
+	for i := range 100 {
+		name := []string{"a", "b"}[i%2]
+		wg.Go(func() {
+			unlock := l.lock(name)
+			mu.Lock()
+			inside[name]++
+			if inside[name] > 1 {
+				t.Errorf("two writers inside %s", name)
+			}
+			mu.Unlock()
+			time.Sleep(time.Microsecond)
+			mu.Lock()
+			inside[name]--
+			mu.Unlock()
+			unlock()
+		})
+	}
+	wg.Wait()
+	if len(l.locks) != 0 {
+		t.Errorf("%d unused locks left", len(l.locks))
+	}
+}
+
+func TestWriteLogEntry_Concurrent(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.json")
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			entry := LogEntry{Timestamp: "2026-10-18 12:00:00", Sender: "Conan", Message: "By Crom"}
+			if err := writeLogEntry(filename, "json", logLayout{}, entry); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	entries, err := readLogFile(filename, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 20 {
+		t.Errorf("got %d entries, want 20", len(entries))
+	}
+}
+
+func TestFolderLocks_Acquire(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	first, second := newFolderLocks(), newFolderLocks()
+	second.self.PID++
+
+	if owner, err := first.acquire(dir, now); err != nil || owner != nil {
+		t.Fatalf("first acquire = %v, %v", owner, err)
+	}
+	// Taking our own lock again succeeds.
+	if owner, err := first.acquire(dir, now); err != nil || owner != nil {
+		t.Fatalf("own lock = %v, %v", owner, err)
+	}
+	owner, err := second.acquire(dir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner == nil || owner.PID != first.self.PID {
+		t.Fatalf("second instance got owner %v, want %d", owner, first.self.PID)
+	}
+
+	// A lock nobody touched for logFolderLockStale is taken over.
+	path := filepath.Join(dir, logFolderLockFile)
+	old := now.Add(-logFolderLockStale - time.Second)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if owner, err := second.acquire(dir, now); err != nil || owner != nil {
+		t.Fatalf("stale lock = %v, %v", owner, err)
+	}
+	if got, ok := readFolderLock(path, now); !ok || got.PID != second.self.PID {
+		t.Errorf("lock file names %v, want %d", got, second.self.PID)
+	}
+}
+
+func TestLockLogFolders(t *testing.T) {
+	dir := t.TempDir()
+	other := newFolderLocks()
+	other.self.PID++
+	if _, err := other.acquire(dir, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	a := setupTestApp()
+	a.folderLocks = newFolderLocks()
+	a.lockLogFolders([]string{dir})
+	if conflicts := a.logFolderConflicts(); len(conflicts) != 1 {
+		t.Fatalf("conflicts = %v", conflicts)
+	}
+
+	// The other instance stops: the refresh takes the folder.
+	other.release(dir)
+	a.refreshFolderLocks(time.Now())
+	if conflicts := a.logFolderConflicts(); len(conflicts) != 0 {
+		t.Errorf("conflicts after release = %v", conflicts)
+	}
+	a.unlockLogFolders()
+	if _, err := os.Stat(filepath.Join(dir, logFolderLockFile)); !os.IsNotExist(err) {
+		t.Errorf("lock file left after unlock: %v", err)
+	}
+}
+
+func TestLogFolders(t *testing.T) {
+	cfg := &AppConfig{EnableLocalSave: true, Path: "logs/", Sources: map[string]SourceProfile{
+		"a": {Path: "other"},
+		"b": {Path: "logs"},
+		"c": {},
+	}, SenderRoutes: map[string]SenderRoute{
+		"Villain": {Path: "gm/"},
+		"Bard":    {Path: "other"},
+		"Scout":   {WebhookURL: "https://discord.com/api/webhooks/1/x"},
+	}}
+	if got, want := logFolders(cfg), []string{"gm", "logs", "other"}; !slices.Equal(got, want) {
+		t.Errorf("logFolders = %v, want %v", got, want)
+	}
+	cfg.EnableLocalSave = false
+	if got := logFolders(cfg); got != nil {
+		t.Errorf("with logging off = %v", got)
+	}
+}
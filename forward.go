@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// forwardedHeader marks requests relayed by another logger instance so that
+// a receiving instance never forwards them again (prevents relay loops).
+const forwardedHeader = "X-RP-Forwarded"
+
+// forwardClient's timeout is set by outbound.
+var forwardClient = &http.Client{
+	Transport: outbound,
+}
+
+// forwardMessage relays a chat message to another ingestion endpoint using
+// the same sender/message query parameters the game sends to /message. A
+// non-empty source is passed on so the receiver can keep it apart.
+func forwardMessage(ctx context.Context, targetURL, sender, message, source string) error {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return fmt.Errorf("parsing forward URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("forward URL must use http or https")
+	}
+
+	query := u.Query()
+	query.Set("sender", sender)
+	query.Set("message", message)
+	if source != "" {
+		query.Set("source", source)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("creating forward request: %w", err)
+	}
+	req.Header.Set(forwardedHeader, "1")
+	if trace := traceFrom(ctx); trace != "" {
+		req.Header.Set(traceHeader, trace)
+	}
+	req.Header.Set("User-Agent", "rp-chat-logger/"+Version)
+
+	resp, err := forwardClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending forward request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("forward target returned status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ForwardQueue retries messages that could not be forwarded, backing off
+// between attempts until maxRetries is reached.
+type ForwardQueue struct {
+	messages   []QueuedMessage
+	mu         sync.Mutex
+	notify     chan struct{}
+	done       chan struct{}
+	logger     *SSELogger
+	receipts   *receiptTable
+	maxRetries int
+	stopOnce   sync.Once
+}
+
+// NewForwardQueue creates a new forward retry queue with background
+// processing. Delivery results are recorded in receipts, which may be nil.
+func NewForwardQueue(logger *SSELogger, receipts *receiptTable) *ForwardQueue {
+	q := &ForwardQueue{
+		messages:   make([]QueuedMessage, 0),
+		notify:     make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		logger:     logger,
+		receipts:   receipts,
+		maxRetries: 5,
+	}
+	go q.processLoop()
+	return q
+}
+
+// Add queues a message for forwarding. WebhookURL holds the forward target.
+func (q *ForwardQueue) Add(msg QueuedMessage) {
+	numberQueued(&msg)
+	q.mu.Lock()
+	q.messages = append(q.messages, msg)
+	count := len(q.messages)
+	q.mu.Unlock()
+
+	if q.logger != nil {
+		q.logger.Log("info", traced(msg.Trace, fmt.Sprintf("Message queued for forward retry (queue size: %d)", count)))
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// QueueSize returns the current number of queued messages.
+func (q *ForwardQueue) QueueSize() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// Stop shuts down the queue processor. It is safe to call more than once.
+func (q *ForwardQueue) Stop() {
+	q.stopOnce.Do(func() { close(q.done) })
+}
+
+// Drain tries to deliver every queued message before ctx expires, waiting
+// for retry times that fall inside the deadline. Call it after Stop. The
+// messages that could not be delivered in time are removed from the queue
+// and returned.
+func (q *ForwardQueue) Drain(ctx context.Context) []QueuedMessage {
+	return drainQueue(ctx, &q.mu, &q.messages, q.processMessages)
+}
+
+func (q *ForwardQueue) processLoop() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-q.notify:
+			q.processMessages(context.Background())
+		case <-ticker.C:
+			q.processMessages(context.Background())
+		}
+	}
+}
+
+func (q *ForwardQueue) processMessages(ctx context.Context) {
+	q.mu.Lock()
+	if len(q.messages) == 0 {
+		q.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	var ready []QueuedMessage
+	var pending []QueuedMessage
+
+	for _, msg := range q.messages {
+		if msg.RetryAt.Before(now) || msg.RetryAt.IsZero() {
+			ready = append(ready, msg)
+		} else {
+			pending = append(pending, msg)
+		}
+	}
+
+	q.messages = pending
+	q.mu.Unlock()
+
+	for _, msg := range ready {
+		if ctx.Err() != nil {
+			// Out of time while draining: keep the rest for later.
+			q.mu.Lock()
+			q.messages = append(q.messages, msg)
+			q.mu.Unlock()
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(withTrace(ctx, msg.Trace), 30*time.Second)
+		err := forwardMessage(sendCtx, msg.WebhookURL, msg.Sender, msg.Message, msg.Source)
+		cancel()
+
+		if err == nil {
+			q.receipts.set(msg.ID, sinkForward, deliverySent)
+			if q.logger != nil {
+				q.logger.Log("info", traced(msg.Trace, fmt.Sprintf("Queued message forwarded successfully (attempt %d)", msg.Attempts+1)))
+			}
+			continue
+		}
+
+		if ctx.Err() != nil {
+			// Interrupted by shutdown rather than a forward failure.
+			q.mu.Lock()
+			q.messages = append(q.messages, msg)
+			q.mu.Unlock()
+			continue
+		}
+
+		msg.Attempts++
+		if msg.Attempts >= q.maxRetries {
+			q.receipts.set(msg.ID, sinkForward, deliveryFailed)
+			slog.Error("Forward failed", "attempts", msg.Attempts, "err", err)
+			if q.logger != nil {
+				q.logger.Log("error", traced(msg.Trace, fmt.Sprintf("Forward failed after %d attempts: %v", msg.Attempts, err)))
+				q.logger.LogRetryableFailure(msg.Sender, msg.Message, "forward", fmt.Sprintf("max retries exceeded: %v", err), msg.Trace, &FailureRetry{Delivery: &msg})
+			}
+			continue
+		}
+
+		msg.RetryAt = time.Now().Add(forwardBackoff(msg.Attempts))
+		q.Add(msg)
+	}
+}
+
+// forwardBackoff returns the delay before the given retry attempt,
+// doubling from 2 seconds up to a one minute cap.
+func forwardBackoff(attempts int) time.Duration {
+	delay := 2 * time.Second
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= time.Minute {
+			return time.Minute
+		}
+	}
+	return delay
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestForwardMessage(t *testing.T) {
+	var gotSender, gotMessage, gotSource, gotHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSender = r.URL.Query().Get("sender")
+		gotMessage = r.URL.Query().Get("message")
+		gotSource = r.URL.Query().Get("source")
+		gotHeader = r.Header.Get(forwardedHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	if err := forwardMessage(context.Background(), srv.URL+"/message", "Test User", "Hello & <World>", "Siptah"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotSender != "Test User" {
+		t.Errorf("sender: expected %q, got %q", "Test User", gotSender)
+	}
+	if gotMessage != "Hello & <World>" {
+		t.Errorf("message: expected %q, got %q", "Hello & <World>", gotMessage)
+	}
+	if gotSource != "Siptah" {
+		t.Errorf("source: expected %q, got %q", "Siptah", gotSource)
+	}
+	if gotHeader == "" {
+		t.Error("expected forwarded header to be set")
+	}
+}
+
+func TestForwardMessage_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "non-2xx status", url: srv.URL + "/message"},
+		{name: "unsupported scheme", url: "ftp://example.com/message"},
+		{name: "invalid url", url: "://bad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := forwardMessage(context.Background(), tt.url, "a", "b", ""); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestForwardBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 2 * time.Second},
+		{attempts: 2, expected: 4 * time.Second},
+		{attempts: 4, expected: 16 * time.Second},
+		{attempts: 10, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := forwardBackoff(tt.attempts); got != tt.expected {
+			t.Errorf("attempts %d: expected %v, got %v", tt.attempts, tt.expected, got)
+		}
+	}
+}
+
+func TestForwardQueue_StopTwice(t *testing.T) {
+	q := NewForwardQueue(nil, nil)
+	q.Stop()
+	q.Stop()
+}
+
+func TestCreateHandler_SkipsForwardingRelayedMessages(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer srv.Close()
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableForward = true
+	a.config.ForwardURL = srv.URL + "/message"
+
+	req := httptest.NewRequest("POST", "/message?sender=A&message=B", nil)
+	createHandler(a).ServeHTTP(httptest.NewRecorder(), req)
+	waitForDeliveries(t, a)
+	if hits != 1 {
+		t.Fatalf("expected 1 forwarded request, got %d", hits)
+	}
+
+	req = httptest.NewRequest("POST", "/message?sender=A&message=B", nil)
+	req.Header.Set(forwardedHeader, "1")
+	createHandler(a).ServeHTTP(httptest.NewRecorder(), req)
+	waitForDeliveries(t, a)
+	if hits != 1 {
+		t.Errorf("expected relayed message not to be forwarded again, got %d requests", hits)
+	}
+}
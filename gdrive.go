@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gdriveAPI is the Google Drive API host; tests point it at a fake server.
+var gdriveAPI = "https://www.googleapis.com"
+
+const (
+	gdriveScope      = "https://www.googleapis.com/auth/drive"
+	gdriveFolderType = "application/vnd.google-apps.folder"
+)
+
+// gdriveCredentials is the part of a service account key file used here.
+type gdriveCredentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// gdriveSink uploads files into a Google Drive folder as a service
+// account, mirroring the key's folders and replacing files that already
+// exist. The folder should be on a shared drive (or shared with the
+// service account's owner), since service accounts have no storage of
+// their own.
+type gdriveSink struct {
+	credentialsFile string
+	folderID        string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	folders map[string]string // folder path -> ID
+}
+
+// gdriveSinks caches one sink per settings so access tokens and folder IDs
+// are reused across runs.
+var gdriveSinks sync.Map
+
+// newGDriveSink returns the sink for the Google Drive settings in config.
+func newGDriveSink(config *AppConfig) *gdriveSink {
+	key := config.GDriveCredentials + "\x00" + config.GDriveFolderID
+	sink, _ := gdriveSinks.LoadOrStore(key, &gdriveSink{
+		credentialsFile: config.GDriveCredentials,
+		folderID:        config.GDriveFolderID,
+		folders:         make(map[string]string),
+	})
+	return sink.(*gdriveSink)
+}
+
+func (s *gdriveSink) name() string { return "Google Drive" }
+
+// put uploads the file at path to key below the configured folder.
+func (s *gdriveSink) put(ctx context.Context, key, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	dir, name := "", key
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		dir, name = key[:i], key[i+1:]
+	}
+	parent, err := s.folder(ctx, token, dir)
+	if err != nil {
+		return err
+	}
+	existing, err := s.find(ctx, token, parent, name, false)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", key, err)
+	}
+	metadata := map[string]any{"name": name}
+	method, target := http.MethodPost, gdriveAPI+"/upload/drive/v3/files?uploadType=multipart&supportsAllDrives=true"
+	if existing != "" {
+		method, target = http.MethodPatch, gdriveAPI+"/upload/drive/v3/files/"+url.PathEscape(existing)+"?uploadType=multipart&supportsAllDrives=true"
+	} else {
+		metadata["parents"] = []string{parent}
+	}
+	body, contentType, err := gdriveMultipart(metadata, data)
+	if err != nil {
+		return err
+	}
+	if err := gdriveDo(ctx, token, method, target, contentType, body, nil); err != nil {
+		return fmt.Errorf("uploading %s to Google Drive: %w", key, err)
+	}
+	return nil
+}
+
+// upload makes gdriveSink a backup destination; backups go under "backups/".
+func (s *gdriveSink) upload(ctx context.Context, file string) error {
+	return s.put(ctx, "backups/"+filepath.Base(file), file)
+}
+
+// folder returns the ID of the folder at dir below the root folder,
+// creating missing folders.
+func (s *gdriveSink) folder(ctx context.Context, token, dir string) (string, error) {
+	id := s.folderID
+	if dir == "" {
+		return id, nil
+	}
+	walked := ""
+	for _, part := range strings.Split(dir, "/") {
+		walked += "/" + part
+		if cached, ok := s.folders[walked]; ok {
+			id = cached
+			continue
+		}
+		found, err := s.find(ctx, token, id, part, true)
+		if err != nil {
+			return "", err
+		}
+		if found == "" {
+			var created struct {
+				ID string `json:"id"`
+			}
+			meta, _ := json.Marshal(map[string]any{"name": part, "mimeType": gdriveFolderType, "parents": []string{id}})
+			if err := gdriveDo(ctx, token, http.MethodPost, gdriveAPI+"/drive/v3/files?supportsAllDrives=true",
+				"application/json", meta, &created); err != nil {
+				return "", fmt.Errorf("creating Google Drive folder %s: %w", part, err)
+			}
+			found = created.ID
+		}
+		s.folders[walked] = found
+		id = found
+	}
+	return id, nil
+}
+
+// find returns the ID of the file or folder called name in parent, or ""
+// if there is none.
+func (s *gdriveSink) find(ctx context.Context, token, parent, name string, folder bool) (string, error) {
+	q := fmt.Sprintf("name = '%s' and '%s' in parents and trashed = false", gdriveQuote(name), gdriveQuote(parent))
+	if folder {
+		q += " and mimeType = '" + gdriveFolderType + "'"
+	}
+	query := url.Values{
+		"q":                         {q},
+		"fields":                    {"files(id)"},
+		"supportsAllDrives":         {"true"},
+		"includeItemsFromAllDrives": {"true"},
+	}
+	var list struct {
+		Files []struct {
+			ID string `json:"id"`
+		} `json:"files"`
+	}
+	if err := gdriveDo(ctx, token, http.MethodGet, gdriveAPI+"/drive/v3/files?"+query.Encode(), "", nil, &list); err != nil {
+		return "", fmt.Errorf("searching Google Drive: %w", err)
+	}
+	if len(list.Files) == 0 {
+		return "", nil
+	}
+	return list.Files[0].ID, nil
+}
+
+// accessToken returns a cached OAuth token, fetching a new one with a
+// signed service account assertion when it is about to expire.
+func (s *gdriveSink) accessToken(ctx context.Context) (string, error) {
+	if s.token != "" && time.Until(s.expires) > time.Minute {
+		return s.token, nil
+	}
+
+	data, err := os.ReadFile(s.credentialsFile)
+	if err != nil {
+		return "", fmt.Errorf("reading Google credentials: %w", err)
+	}
+	var creds gdriveCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", fmt.Errorf("parsing Google credentials: %w", err)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	assertion, err := gdriveAssertion(creds, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, creds.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := backupClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting Google access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("requesting Google access token: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decoding Google access token: %w", err)
+	}
+	s.token = token.AccessToken
+	s.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// gdriveAssertion builds the RS256-signed JWT a service account exchanges
+// for an access token.
+func gdriveAssertion(creds gdriveCredentials, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("Google credentials contain no private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("parsing Google private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("Google private key is not an RSA key")
+	}
+
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   creds.ClientEmail,
+		"scope": gdriveScope,
+		"aud":   creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	signingInput := header + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing Google assertion: %w", err)
+	}
+	return signingInput + "." + enc.EncodeToString(signature), nil
+}
+
+// gdriveMultipart builds a multipart/related upload body of JSON metadata
+// followed by the file content.
+func gdriveMultipart(metadata map[string]any, data []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	meta, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, "", fmt.Errorf("encoding Drive metadata: %w", err)
+	}
+	part, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	part.Write(meta)
+	part, _ = mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+	part.Write(data)
+	if err := mw.Close(); err != nil {
+		return nil, "", fmt.Errorf("encoding Drive upload: %w", err)
+	}
+	return buf.Bytes(), "multipart/related; boundary=" + mw.Boundary(), nil
+}
+
+// gdriveDo sends an authorized Drive API request and decodes a JSON
+// response into out when it is non-nil.
+func gdriveDo(ctx context.Context, token, method, target, contentType string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := backupClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// gdriveQuote escapes a value for a single-quoted Drive query string.
+func gdriveQuote(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCredentials writes a service account key file for a new RSA key.
+func writeTestCredentials(t *testing.T, tokenURI string) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	data, _ := json.Marshal(gdriveCredentials{
+		ClientEmail: "logger@example.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    tokenURI,
+	})
+	path := filepath.Join(t.TempDir(), "key.json")
+	os.WriteFile(path, data, 0600)
+	return path, key
+}
+
+func TestGDriveAssertion(t *testing.T) {
+	path, key := writeTestCredentials(t, "https://oauth2.example/token")
+	data, _ := os.ReadFile(path)
+	var creds gdriveCredentials
+	json.Unmarshal(data, &creds)
+
+	jwt, err := gdriveAssertion(creds, time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed JWT %q", jwt)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("bad signature: %v", err)
+	}
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var c map[string]any
+	json.Unmarshal(claims, &c)
+	if c["iss"] != creds.ClientEmail || c["aud"] != creds.TokenURI || c["scope"] != gdriveScope {
+		t.Errorf("unexpected claims %v", c)
+	}
+}
+
+// fakeDrive is a minimal Drive API: files are keyed by parent and name.
+type fakeDrive struct {
+	files   map[string]string // parent + "/" + name -> id
+	content map[string]string // id -> content
+	tokens  int
+}
+
+func (d *fakeDrive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		d.tokens++
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "tok", "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/drive/v3/files":
+		// q is: name = 'N' and 'P' in parents and ...
+		var name, parent string
+		fmt.Sscanf(strings.ReplaceAll(r.URL.Query().Get("q"), "'", " "), "name = %s and %s in parents", &name, &parent)
+		var files []map[string]string
+		if id, ok := d.files[parent+"/"+name]; ok {
+			files = append(files, map[string]string{"id": id})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"files": files})
+	case r.Method == http.MethodPost && r.URL.Path == "/drive/v3/files":
+		var meta struct {
+			Name    string   `json:"name"`
+			Parents []string `json:"parents"`
+		}
+		json.NewDecoder(r.Body).Decode(&meta)
+		id := fmt.Sprintf("id%d", len(d.files)+1)
+		d.files[meta.Parents[0]+"/"+meta.Name] = id
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	case strings.HasPrefix(r.URL.Path, "/upload/drive/v3/files"):
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		part, _ := mr.NextPart()
+		var meta struct {
+			Name    string   `json:"name"`
+			Parents []string `json:"parents"`
+		}
+		json.NewDecoder(part).Decode(&meta)
+		part, _ = mr.NextPart()
+		data, _ := io.ReadAll(part)
+
+		id := strings.TrimPrefix(r.URL.Path, "/upload/drive/v3/files/")
+		if r.Method == http.MethodPost {
+			id = fmt.Sprintf("id%d", len(d.files)+1)
+			d.files[meta.Parents[0]+"/"+meta.Name] = id
+		}
+		d.content[id] = string(data)
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestGDriveSink_Put(t *testing.T) {
+	drive := &fakeDrive{files: map[string]string{}, content: map[string]string{}}
+	srv := httptest.NewServer(drive)
+	defer srv.Close()
+	oldAPI := gdriveAPI
+	gdriveAPI = srv.URL
+	defer func() { gdriveAPI = oldAPI }()
+
+	creds, _ := writeTestCredentials(t, srv.URL+"/token")
+	file := filepath.Join(t.TempDir(), "log.txt")
+	s := newGDriveSink(&AppConfig{GDriveCredentials: creds, GDriveFolderID: "root1"})
+
+	os.WriteFile(file, []byte("first"), 0644)
+	if err := s.put(context.Background(), "2026/10/log.txt", file); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(file, []byte("second"), 0644)
+	if err := s.put(context.Background(), "2026/10/log.txt", file); err != nil {
+		t.Fatal(err)
+	}
+
+	year, month := drive.files["root1/2026"], drive.files[drive.files["root1/2026"]+"/10"]
+	id := drive.files[month+"/log.txt"]
+	if year == "" || month == "" || id == "" {
+		t.Fatalf("folders or file missing: %v", drive.files)
+	}
+	if len(drive.files) != 3 {
+		t.Errorf("expected the second upload to replace the file, got %v", drive.files)
+	}
+	if drive.content[id] != "second" {
+		t.Errorf("content = %q, want second", drive.content[id])
+	}
+	if drive.tokens != 1 {
+		t.Errorf("expected the access token to be reused, fetched %d", drive.tokens)
+	}
+}
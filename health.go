@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// healthReport is the body of /healthz and /readyz.
+type healthReport struct {
+	Status             string     `json:"status"`
+	Version            string     `json:"version"`
+	Ingestion          bool       `json:"ingestion"`
+	DiscordQueue       int        `json:"discordQueue"`
+	ForwardQueue       int        `json:"forwardQueue"`
+	DeliveryQueue      int        `json:"deliveryQueue"`
+	DeliveryDropped    uint64     `json:"deliveryDropped"`
+	LastDiscordSend    *time.Time `json:"lastDiscordSend,omitempty"`
+	DiscordFailover    int        `json:"discordFailover,omitempty"` // targets failed over
+	DiscordCircuits    int        `json:"discordCircuitsOpen,omitempty"`
+	OfflineSince       *time.Time `json:"offlineSince,omitempty"`
+	OfflineBuffered    int        `json:"offlineBuffered,omitempty"` // Discord messages
+	DiscordPausedSince *time.Time `json:"discordPausedSince,omitempty"`
+	LogPathWritable    *bool      `json:"logPathWritable,omitempty"`
+	ConfigValid        bool       `json:"configValid"`
+	ConfigError        string     `json:"configError,omitempty"`
+	LogPathError       string     `json:"logPathError,omitempty"`
+	LogFolderInUse     []string   `json:"logFolderInUse,omitempty"` // by another instance
+	NotReadyBecause    []string   `json:"notReadyBecause,omitempty"`
+}
+
+// health checks the app's state. It is ready when the config is valid, the
+// ingestion server is running and, with file logging on, the log path can
+// be written to.
+func (a *App) health() healthReport {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	report := healthReport{
+		Status:    "ok",
+		Version:   Version,
+		Ingestion: a.ingestionRunning.Load(),
+	}
+	if a.discordQueue != nil {
+		report.DiscordQueue = a.discordQueue.QueueSize()
+		if sent := a.discordQueue.LastSent(); !sent.IsZero() {
+			report.LastDiscordSend = &sent
+		}
+	}
+	if a.forwardQueue != nil {
+		report.ForwardQueue = a.forwardQueue.QueueSize()
+	}
+	deliveries := a.deliveries.stats()
+	report.DeliveryQueue, report.DeliveryDropped = deliveries.Depth, deliveries.Dropped
+
+	if err := cfg.validate(); err != nil {
+		report.ConfigError = err.Error()
+		report.NotReadyBecause = append(report.NotReadyBecause, "config invalid")
+	} else {
+		report.ConfigValid = true
+	}
+	if !report.Ingestion {
+		report.NotReadyBecause = append(report.NotReadyBecause, "ingestion server stopped")
+	}
+	if cfg.EnableLocalSave && cfg.Path != "" {
+		writable := true
+		if err := checkWritable(cfg.Path); err != nil {
+			writable = false
+			report.LogPathError = err.Error()
+			report.NotReadyBecause = append(report.NotReadyBecause, "log path not writable")
+		}
+		report.LogPathWritable = &writable
+	}
+	report.LogFolderInUse = a.logFolderConflicts()
+	report.DiscordFailover = a.failover.engaged()
+	report.DiscordCircuits = discordCircuit.open()
+	if since, buffered := a.offline.status(); !since.IsZero() {
+		report.OfflineSince, report.OfflineBuffered = &since, buffered
+	}
+	if since := a.discordQueue.PausedSince(); !since.IsZero() {
+		report.DiscordPausedSince = &since
+	}
+	return report
+}
+
+// checkWritable writes a test line to a file in dir, reads it back and
+// removes it, creating dir first if it doesn't exist yet, as logToFile
+// would.
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating log directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, ".healthcheck-*")
+	if err != nil {
+		return fmt.Errorf("writing to log directory: %w", err)
+	}
+	name := f.Name()
+	defer os.Remove(name)
+	const line = "rp-chat-logger write test\n"
+	_, err = f.WriteString(line)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing to log directory: %w", err)
+	}
+	if data, err := os.ReadFile(name); err != nil || string(data) != line {
+		return fmt.Errorf("reading back the test line failed")
+	}
+	return nil
+}
+
+// handleHealthz reports liveness: it answers 200 whenever the process can
+// serve requests, with the same details as /readyz.
+func (a *App) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, a.health(), false)
+}
+
+// handleReadyz reports readiness, answering 503 when messages can't be
+// accepted and stored.
+func (a *App) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, a.health(), true)
+}
+
+func writeHealth(w http.ResponseWriter, report healthReport, readiness bool) {
+	status := http.StatusOK
+	if readiness && len(report.NotReadyBecause) > 0 {
+		report.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	blocked := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		config      AppConfig
+		running     bool
+		readyStatus int
+		notReady    []string
+		writable    *bool
+	}{
+		{
+			name:        "ready",
+			config:      AppConfig{EnableLocalSave: true, Path: filepath.Join(dir, "logs")},
+			running:     true,
+			readyStatus: http.StatusOK,
+			writable:    boolPtr(true),
+		},
+		{
+			name:        "server stopped",
+			config:      AppConfig{EnableLocalSave: true, Path: dir},
+			readyStatus: http.StatusServiceUnavailable,
+			notReady:    []string{"ingestion server stopped"},
+			writable:    boolPtr(true),
+		},
+		{
+			name:        "invalid config",
+			config:      AppConfig{},
+			running:     true,
+			readyStatus: http.StatusServiceUnavailable,
+			notReady:    []string{"config invalid"},
+		},
+		{
+			name:        "log path not writable",
+			config:      AppConfig{EnableLocalSave: true, Path: filepath.Join(blocked, "logs")},
+			running:     true,
+			readyStatus: http.StatusServiceUnavailable,
+			notReady:    []string{"log path not writable"},
+			writable:    boolPtr(false),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := setupTestApp()
+			defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+			*a.config = tt.config
+			a.ingestionRunning.Store(tt.running)
+
+			for _, endpoint := range []struct {
+				path   string
+				handle http.HandlerFunc
+				status int
+			}{
+				{"/healthz", a.handleHealthz, http.StatusOK},
+				{"/readyz", a.handleReadyz, tt.readyStatus},
+			} {
+				rec := httptest.NewRecorder()
+				endpoint.handle(rec, httptest.NewRequest("GET", endpoint.path, nil))
+				if rec.Code != endpoint.status {
+					t.Errorf("%s: expected status %d, got %d", endpoint.path, endpoint.status, rec.Code)
+				}
+				var report healthReport
+				if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+					t.Fatalf("%s: invalid JSON: %v", endpoint.path, err)
+				}
+				if len(report.NotReadyBecause) != len(tt.notReady) || (len(tt.notReady) > 0 && report.NotReadyBecause[0] != tt.notReady[0]) {
+					t.Errorf("%s: expected %v, got %v", endpoint.path, tt.notReady, report.NotReadyBecause)
+				}
+				if (report.LogPathWritable == nil) != (tt.writable == nil) || (tt.writable != nil && *report.LogPathWritable != *tt.writable) {
+					t.Errorf("%s: unexpected logPathWritable %v", endpoint.path, report.LogPathWritable)
+				}
+			}
+		})
+	}
+}
+
+func TestHealth_DiscordQueue(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.discordQueue = NewDiscordQueue(nil, nil)
+	defer a.discordQueue.Stop()
+
+	if report := a.health(); report.LastDiscordSend != nil {
+		t.Errorf("expected no last send before any message, got %v", report.LastDiscordSend)
+	}
+	a.discordQueue.markSent()
+	if report := a.health(); report.LastDiscordSend == nil {
+		t.Error("expected the last send time")
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
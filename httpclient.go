@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// defaultHTTPTimeout bounds a Discord or forward request, response
+// included, when HTTPTimeout is 0.
+const defaultHTTPTimeout = 10 * time.Second
+
+// outbound carries the requests of discordClient and forwardClient with the
+// HTTPTimeout, HTTPProxy and HTTPCABundle settings.
+var outbound = &outboundTransport{}
+
+// outboundTransport is an http.RoundTripper whose settings can be replaced
+// while requests are in flight. The zero value uses http.DefaultTransport,
+// which takes its proxy from HTTPS_PROXY and friends, and
+// defaultHTTPTimeout.
+type outboundTransport struct {
+	settings atomic.Pointer[outboundSettings]
+}
+
+// outboundSettings is the transport and timeout of outboundTransport.
+type outboundSettings struct {
+	transport http.RoundTripper
+	timeout   time.Duration
+}
+
+// configure applies the HTTP client settings of cfg. Requests in flight
+// finish with the old ones.
+func (t *outboundTransport) configure(cfg *AppConfig) error {
+	transport, err := newHTTPTransport(cfg)
+	if err != nil {
+		return err
+	}
+	timeout := time.Duration(cfg.HTTPTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	old := t.settings.Swap(&outboundSettings{transport: transport, timeout: timeout})
+	if old != nil && old.transport != http.DefaultTransport {
+		if closer, ok := old.transport.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+	return nil
+}
+
+// newHTTPTransport returns http.DefaultTransport for the default settings,
+// or a copy of it using cfg's proxy and CA bundle.
+func newHTTPTransport(cfg *AppConfig) (http.RoundTripper, error) {
+	if cfg.HTTPProxy == "" && cfg.HTTPCABundle == "" {
+		return http.DefaultTransport, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.HTTPProxy != "" {
+		proxy, err := parseHTTPProxy(cfg.HTTPProxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if cfg.HTTPCABundle != "" {
+		pool, err := loadCABundle(cfg.HTTPCABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return transport, nil
+}
+
+// parseHTTPProxy parses a proxy URL: http, https or socks5, with optional
+// user:password.
+func parseHTTPProxy(value string) (*url.URL, error) {
+	proxy, err := url.Parse(value)
+	if err != nil || proxy.Host == "" {
+		return nil, fmt.Errorf("Proxy must be a URL such as http://proxy:8080")
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return proxy, nil
+	}
+	return nil, fmt.Errorf("Proxy must use http, https or socks5")
+}
+
+// loadCABundle returns the system's certificate authorities with the PEM
+// certificates of path added, for a proxy or network that intercepts TLS.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA bundle %s holds no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+func (t *outboundTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	settings := t.settings.Load()
+	if settings == nil {
+		settings = &outboundSettings{transport: http.DefaultTransport, timeout: defaultHTTPTimeout}
+	}
+	// The timeout covers reading the response, as http.Client's does, so it
+	// ends when the body is closed.
+	ctx, cancel := context.WithTimeout(req.Context(), settings.timeout)
+	resp, err := settings.transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose is a response body that releases its request's timeout
+// when closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
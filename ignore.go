@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// What happens to messages from ignored senders: dropped (the default), or
+// logged locally but neither posted to Discord nor forwarded.
+const (
+	ignoreDrop  = "drop"
+	ignoreLocal = "local"
+)
+
+// checkIgnoredSenderPolicy reports an unknown IgnoredSenderPolicy.
+func checkIgnoredSenderPolicy(policy string) error {
+	switch policy {
+	case "", ignoreDrop, ignoreLocal:
+		return nil
+	}
+	return fmt.Errorf("Unknown ignored sender policy %q", policy)
+}
+
+// ignoredSender reports whether sender is on the ignore list, ignoring case.
+// A * in an entry matches any text, so "[Server]*" ignores every sender
+// starting with [Server].
+func ignoredSender(cfg *AppConfig, sender string) bool {
+	sender = strings.TrimSpace(sender)
+	for _, pattern := range cfg.IgnoredSenders {
+		if !strings.Contains(pattern, "*") {
+			if strings.EqualFold(pattern, sender) {
+				return true
+			}
+			continue
+		}
+		quoted := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+		if regexp.MustCompile(`(?is)^` + quoted + `$`).MatchString(sender) {
+			return true
+		}
+	}
+	return false
+}
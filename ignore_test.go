@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIgnoredSender(t *testing.T) {
+	cfg := &AppConfig{IgnoredSenders: []string{"AdminBot", "[Server]*", "*.exe"}}
+	tests := []struct {
+		sender string
+		want   bool
+	}{
+		{"AdminBot", true},
+		{"adminbot", true},
+		{" AdminBot ", true},
+		{"AdminBot2", false},
+		{"[Server]", true},
+		{"[server] Restart", true},
+		{"Server", false},
+		{"cron.exe", true},
+		{"cronXexe", false},
+		{"Conan", false},
+	}
+	for _, tt := range tests {
+		if got := ignoredSender(cfg, tt.sender); got != tt.want {
+			t.Errorf("ignoredSender(%q) = %v, want %v", tt.sender, got, tt.want)
+		}
+	}
+	if ignoredSender(&AppConfig{}, "AdminBot") {
+		t.Error("sender ignored with an empty list")
+	}
+}
+
+func TestProcessMessage_IgnoredSender(t *testing.T) {
+	tests := []struct {
+		policy string
+		logged bool
+	}{
+		{policy: ""},
+		{policy: ignoreDrop},
+		{policy: ignoreLocal, logged: true},
+	}
+	for _, tt := range tests {
+		t.Run("policy "+tt.policy, func(t *testing.T) {
+			bot := newFakeDiscordBot(t)
+			a := setupTestApp()
+			defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+			tmpDir := t.TempDir()
+			a.config.EnableDiscord = true
+			a.config.DiscordMode = discordModeBot
+			a.config.BotToken = "secret"
+			a.config.BotChannelID = "100"
+			a.config.EnableLocalSave = true
+			a.config.Path = tmpDir
+			a.config.IgnoredSenders = []string{"[Server]*"}
+			a.config.IgnoredSenderPolicy = tt.policy
+
+			a.processMessage(context.Background(), IncomingMessage{Sender: "[Server] Restart", Message: "Restarting in 5 minutes"})
+			a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "Hello"})
+			waitForDeliveries(t, a)
+
+			if got := bot.contents("100"); len(got) != 1 || !strings.Contains(got[0], "Hello") {
+				t.Errorf("Discord got %q, want only Conan's message", got)
+			}
+			data, err := os.ReadFile(generateLogFilename(tmpDir, "txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if logged := strings.Contains(string(data), "Restarting"); logged != tt.logged {
+				t.Errorf("ignored message logged: %v, want %v\n%s", logged, tt.logged, data)
+			}
+		})
+	}
+}
+
+func TestValidate_IgnoredSenderPolicy(t *testing.T) {
+	cfg := AppConfig{EnableLocalSave: true, Path: "/logs", IgnoredSenderPolicy: "shout"}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "ignored sender policy") {
+		t.Errorf("expected policy error, got %v", err)
+	}
+	cfg.IgnoredSenderPolicy = ignoreLocal
+	if err := cfg.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
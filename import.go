@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxImportSize limits transcripts uploaded through the web UI.
+const maxImportSize = 32 << 20
+
+// importTimestampLayouts are the timestamp formats accepted in imported
+// transcripts, tried in order. Times without a zone are local.
+var importTimestampLayouts = []string{
+	logTimestampLayout,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04:05",
+	"01/02/2006 15:04:05",
+	"02.01.2006 15:04:05",
+}
+
+// Column and field names recognized in imported CSV and JSON transcripts.
+var (
+	importTimestampFields = []string{"timestamp", "time", "date", "datetime"}
+	importSenderFields    = []string{"sender", "author", "name", "character", "user"}
+	importMessageFields   = []string{"message", "text", "content", "body"}
+	importKindFields      = []string{"kind", "type"}
+	importSourceFields    = []string{"source", "server"}
+)
+
+// ImportResult summarizes a transcript import.
+type ImportResult struct {
+	Added      int
+	Duplicates int
+	Invalid    int
+	Files      int
+}
+
+func (r ImportResult) String() string {
+	return fmt.Sprintf("%d added, %d duplicate(s) skipped, %d invalid line(s) skipped, %d file(s) updated",
+		r.Added, r.Duplicates, r.Invalid, r.Files)
+}
+
+// importFormat returns the transcript format for a file name, or "" when
+// the extension is not supported.
+func importFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt", ".log":
+		return "txt"
+	case ".csv":
+		return "csv"
+	case ".json":
+		return "json"
+	}
+	return ""
+}
+
+// parseTranscript reads an exported transcript in the given format. It
+// returns the entries with normalized timestamps and the number of records
+// that had no usable timestamp, sender or message.
+func parseTranscript(r io.Reader, format string) ([]LogEntry, int, error) {
+	var raw []LogEntry
+	var err error
+	switch format {
+	case "csv":
+		raw, err = parseImportCSV(r)
+	case "json":
+		raw, err = parseImportJSON(r)
+	case "txt":
+		raw, err = parseImportText(r)
+	default:
+		return nil, 0, fmt.Errorf("unsupported transcript format %q", format)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]LogEntry, 0, len(raw))
+	invalid := 0
+	for _, entry := range raw {
+		ts, ok := parseImportTimestamp(entry.Timestamp)
+		if !ok || entry.Sender == "" || entry.Message == "" {
+			invalid++
+			continue
+		}
+		entry.Timestamp = ts.Format(logTimestampLayout)
+		if entry.Kind == kindSay {
+			entry.Kind = ""
+		}
+		entries = append(entries, entry)
+	}
+	return entries, invalid, nil
+}
+
+func parseImportTimestamp(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range importTimestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Local(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseImportText reads "[timestamp] Sender: message" lines as written by
+// the txt format. Other lines are counted as invalid.
+func parseImportText(r io.Reader) ([]LogEntry, error) {
+	var entries []LogEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		entry, _ := parseTextLine(line)
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading transcript: %w", err)
+	}
+	return entries, nil
+}
+
+// parseImportCSV reads a CSV transcript. A header row naming the columns
+// is used when present; otherwise the columns are taken to be in this
+// app's order (Timestamp, Sender, Message, Type, Session, Source).
+func parseImportCSV(r io.Reader) ([]LogEntry, error) {
+	records, err := newCSVReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing csv transcript: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{"timestamp": 0, "sender": 1, "message": 2, "kind": 3, "source": 5}
+	if header, ok := csvHeaderColumns(records[0]); ok {
+		columns = header
+		records = records[1:]
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return unescapeCSVCell(strings.TrimSpace(record[i]))
+		}
+		return ""
+	}
+	entries := make([]LogEntry, 0, len(records))
+	for _, record := range records {
+		entries = append(entries, LogEntry{
+			Timestamp: field(record, "timestamp"),
+			Sender:    field(record, "sender"),
+			Message:   field(record, "message"),
+			Kind:      strings.ToLower(field(record, "kind")),
+			Source:    field(record, "source"),
+		})
+	}
+	return entries, nil
+}
+
+// csvHeaderColumns maps a header row to column indexes. It reports false
+// when the row does not name at least a timestamp, sender and message.
+func csvHeaderColumns(row []string) (map[string]int, bool) {
+	columns := make(map[string]int)
+	for i, name := range row {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for key, names := range map[string][]string{
+			"timestamp": importTimestampFields,
+			"sender":    importSenderFields,
+			"message":   importMessageFields,
+			"kind":      importKindFields,
+			"source":    importSourceFields,
+		} {
+			if _, seen := columns[key]; !seen && slices.Contains(names, name) {
+				columns[key] = i
+			}
+		}
+	}
+	_, hasTime := columns["timestamp"]
+	_, hasSender := columns["sender"]
+	_, hasMessage := columns["message"]
+	return columns, hasTime && hasSender && hasMessage
+}
+
+// parseImportJSON reads a JSON array of objects, matching field names
+// case-insensitively.
+func parseImportJSON(r io.Reader) ([]LogEntry, error) {
+	var records []map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("parsing json transcript: %w", err)
+	}
+
+	entries := make([]LogEntry, 0, len(records))
+	for _, record := range records {
+		lower := make(map[string]string, len(record))
+		for key, value := range record {
+			if s, ok := value.(string); ok {
+				lower[strings.ToLower(key)] = strings.TrimSpace(s)
+			}
+		}
+		first := func(names []string) string {
+			for _, name := range names {
+				if v := lower[name]; v != "" {
+					return v
+				}
+			}
+			return ""
+		}
+		entries = append(entries, LogEntry{
+			Timestamp: first(importTimestampFields),
+			Sender:    first(importSenderFields),
+			Message:   first(importMessageFields),
+			Kind:      strings.ToLower(first(importKindFields)),
+			Source:    first(importSourceFields),
+		})
+	}
+	return entries, nil
+}
+
+// mergeIntoArchive adds entries to the log files under cfg.Path, named by
+// the filename template and written in the configured format. Entries
+// already present (same timestamp, sender and message) are skipped, and
+// each file is rewritten in timestamp order.
+func mergeIntoArchive(cfg *AppConfig, entries []LogEntry) (ImportResult, error) {
+	var result ImportResult
+	if cfg.Path == "" {
+		return result, fmt.Errorf("no log folder configured")
+	}
+	format := logFormat(cfg)
+
+	byFile := make(map[string][]LogEntry)
+	for _, entry := range entries {
+		date, _ := time.ParseInLocation(logTimestampLayout, entry.Timestamp, time.Local)
+		filename := logFilePath(cfg, cfg.Path, date, entry)
+		byFile[filename] = append(byFile[filename], entry)
+	}
+
+	filenames := make([]string, 0, len(byFile))
+	for filename := range byFile {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		existing, err := readLogFile(filename, format)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return result, fmt.Errorf("reading %s: %w", filepath.Base(filename), err)
+		}
+
+		seen := make(map[string]bool, len(existing))
+		for _, entry := range existing {
+			seen[entryKey(entry)] = true
+		}
+		merged := existing
+		added := 0
+		for _, entry := range byFile[filename] {
+			key := entryKey(entry)
+			if seen[key] {
+				result.Duplicates++
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, entry)
+			added++
+		}
+		if added == 0 {
+			continue
+		}
+
+		sort.SliceStable(merged, func(i, j int) bool { return merged[i].Timestamp < merged[j].Timestamp })
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return result, fmt.Errorf("creating log directory: %w", err)
+		}
+		if err := writeLogFile(filename, format, layoutOf(cfg), merged); err != nil {
+			return result, err
+		}
+		result.Added += added
+		result.Files++
+	}
+	return result, nil
+}
+
+// entryKey identifies a log entry for deduplication.
+func entryKey(entry LogEntry) string {
+	return entry.Timestamp + "\x00" + entry.Sender + "\x00" + entry.Message
+}
+
+// writeLogFile replaces filename with entries in the given format. The new
+// file is written next to it first so a failure leaves the original intact.
+func writeLogFile(filename, format string, layout logLayout, entries []LogEntry) error {
+	tmp := filename + ".tmp"
+	os.Remove(tmp)
+
+	if format == "json" {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding json log entries: %w", err)
+		}
+		if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("writing json log file: %w", err)
+		}
+	} else {
+		for _, entry := range entries {
+			if err := writeLogEntry(tmp, format, layout, entry); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+		}
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replacing log file: %w", err)
+	}
+	return nil
+}
+
+// importTranscript parses a transcript and merges it into the archive.
+func (a *App) importTranscript(r io.Reader, format string) (ImportResult, error) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	if cfg.TextTemplate != "" && logFormat(&cfg) != "csv" && logFormat(&cfg) != "json" {
+		// Merging rewrites the day's files, and lines in a custom format
+		// can't be read back, so they would be lost.
+		return ImportResult{}, fmt.Errorf("importing into txt or docx logs needs the default line format; clear the text line template first")
+	}
+	entries, invalid, err := parseTranscript(r, format)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	a.lockArchive()
+	result, err := mergeIntoArchive(&cfg, entries)
+	a.archiveMu.Unlock()
+	result.Invalid = invalid
+	if err != nil {
+		return result, err
+	}
+	a.logger.Log("info", fmt.Sprintf("Imported transcript: %s", result))
+	return result, nil
+}
+
+// runImport imports a transcript file from the command line and shuts the
+// app down, which saves the queued messages NewApp restored for the next
+// start.
+func runImport(application *App, filename, format string) (ImportResult, error) {
+	defer application.Shutdown()
+	if format == "" {
+		format = importFormat(filename)
+	}
+	if format == "" {
+		return ImportResult{}, fmt.Errorf("cannot tell the format of %s; use --import-format txt, csv or json", filename)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("opening transcript: %w", err)
+	}
+	defer file.Close()
+	return application.importTranscript(file, format)
+}
+
+// handleImport merges an uploaded transcript into the archive.
+func (a *App) handleImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		fmt.Fprintf(w, `<div class="alert error">Import failed: %s</div>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
+	defer file.Close()
+
+	format := importFormat(header.Filename)
+	if format == "" {
+		fmt.Fprint(w, `<div class="alert error">Import failed: choose a .txt, .csv or .json file</div>`)
+		return
+	}
+	result, err := a.importTranscript(file, format)
+	if err != nil {
+		fmt.Fprintf(w, `<div class="alert error">Import failed: %s</div>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
+	fmt.Fprintf(w, `<div class="alert success">Imported %s: %s</div>`, template.HTMLEscapeString(header.Filename), result)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTranscript(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		input       string
+		want        []LogEntry
+		wantInvalid int
+	}{
+		{
+			name:   "txt",
+			format: "txt",
+			input:  "[2026-10-17 18:00:00] Alice: hello\n[2026-10-17 18:01:00] * Bob waves\nnot a log line\n",
+			want: []LogEntry{
+				{Timestamp: "2026-10-17 18:00:00", Sender: "Alice", Message: "hello"},
+				{Timestamp: "2026-10-17 18:01:00", Sender: "Bob", Message: "waves", Kind: kindEmote},
+			},
+			wantInvalid: 1,
+		},
+		{
+			name:   "csv with other tool's header",
+			format: "csv",
+			input:  "Author,Content,Date\nAlice,\"hi, all\",2026/10/17 18:00:00\nBob,,2026/10/17 18:01:00\n",
+			want: []LogEntry{
+				{Timestamp: "2026-10-17 18:00:00", Sender: "Alice", Message: "hi, all"},
+			},
+			wantInvalid: 1,
+		},
+		{
+			name:   "csv in this app's order",
+			format: "csv",
+			input:  "2026-10-17 18:00:00,Alice,hello,say,,Siptah\n",
+			want: []LogEntry{
+				{Timestamp: "2026-10-17 18:00:00", Sender: "Alice", Message: "hello", Source: "Siptah"},
+			},
+		},
+		{
+			name:   "json",
+			format: "json",
+			input:  `[{"Time": "2026-10-17T18:00:00", "Name": "Alice", "Text": "hello"}, {"name": "Bob", "text": "no time"}]`,
+			want: []LogEntry{
+				{Timestamp: "2026-10-17 18:00:00", Sender: "Alice", Message: "hello"},
+			},
+			wantInvalid: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, invalid, err := parseTranscript(strings.NewReader(tt.input), tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if invalid != tt.wantInvalid {
+				t.Errorf("invalid = %d, want %d", invalid, tt.wantInvalid)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d: got %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMergeIntoArchive(t *testing.T) {
+	for _, format := range []string{"txt", "csv", "json"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := &AppConfig{Path: dir, FileFormat: format}
+			day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+			filename := logFilenameForDate(dir, format, day)
+
+			for _, entry := range []LogEntry{
+				{Timestamp: "2026-10-17 18:00:00", Sender: "Alice", Message: "hello"},
+				{Timestamp: "2026-10-17 18:05:00", Sender: "Carol", Message: "late"},
+			} {
+				if err := writeLogEntry(filename, format, logLayout{}, entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result, err := mergeIntoArchive(cfg, []LogEntry{
+				{Timestamp: "2026-10-17 18:00:00", Sender: "Alice", Message: "hello"},
+				{Timestamp: "2026-10-17 18:02:00", Sender: "Bob", Message: "in between"},
+				{Timestamp: "2026-10-18 09:00:00", Sender: "Dave", Message: "next day"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Added != 2 || result.Duplicates != 1 || result.Files != 2 {
+				t.Errorf("unexpected result: %+v", result)
+			}
+
+			entries, err := readLogFile(filename, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var senders []string
+			for _, e := range entries {
+				senders = append(senders, e.Sender)
+			}
+			if got := strings.Join(senders, ","); got != "Alice,Bob,Carol" {
+				t.Errorf("got senders %s, want Alice,Bob,Carol", got)
+			}
+
+			next, err := readLogFile(logFilenameForDate(dir, format, day.AddDate(0, 0, 1)), format)
+			if err != nil || len(next) != 1 || next[0].Sender != "Dave" {
+				t.Errorf("next day file: %+v, %v", next, err)
+			}
+		})
+	}
+}
+
+func TestHandleImport(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.Path = t.TempDir()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "old.txt")
+	part.Write([]byte("[2026-10-17 18:00:00] Alice: hello\n"))
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rr := httptest.NewRecorder()
+	a.handleImport(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "1 added") {
+		t.Errorf("unexpected response: %s", rr.Body.String())
+	}
+}
+
+func TestRunImport_KeepsPendingMessages(t *testing.T) {
+	dir := t.TempDir()
+	setConfigPath(filepath.Join(dir, "config.json"))
+	defer setConfigPath("")
+
+	// Discord is paused, so the restored message stays queued until exit
+	if err := os.WriteFile(pendingPath(discordPausedFile), []byte(`{"since": "2026-10-17T18:00:00Z"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	held := []QueuedMessage{{WebhookURL: "https://discord.invalid/hook", Sender: "Alice", Message: "still queued"}}
+	if err := savePendingMessages(pendingPath(pendingDiscordFile), held); err != nil {
+		t.Fatal(err)
+	}
+	transcript := filepath.Join(dir, "old.txt")
+	if err := os.WriteFile(transcript, []byte("[2026-10-17 18:00:00] Alice: hello\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := filepath.Join(dir, "logs")
+	a := NewApp(&AppConfig{FileFormat: "txt", EnableLocalSave: true, Path: logs}, "")
+	result, err := runImport(a, transcript, "")
+	if err != nil || result.Added != 1 {
+		t.Fatalf("runImport = %+v, %v", result, err)
+	}
+
+	pending, err := loadPendingMessages(pendingPath(pendingDiscordFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Message != "still queued" {
+		t.Errorf("pending messages after import = %+v", pending)
+	}
+}
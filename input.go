@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// InputAdapter turns an ingestion HTTP request into a chat message. Each
+// game (or mod) has its own request format; an empty Message means the
+// request carried nothing to log.
+type InputAdapter interface {
+	Parse(r *http.Request) IncomingMessage
+}
+
+// InputAdapterFunc adapts a plain function to the InputAdapter interface.
+type InputAdapterFunc func(r *http.Request) IncomingMessage
+
+// Parse calls f(r).
+func (f InputAdapterFunc) Parse(r *http.Request) IncomingMessage {
+	return f(r)
+}
+
+// Input presets selectable in the config.
+const (
+	presetConan   = "conan"
+	presetARK     = "ark"
+	presetGeneric = "generic"
+)
+
+// maxInputBody bounds the request bodies read by the JSON and form adapters.
+const maxInputBody = 64 * 1024
+
+// arkTailPattern matches chat lines in ARK's ShooterGame log, e.g.
+// "2026.10.17_21.00.00: Survivor (Bob): Hello".
+const arkTailPattern = `^\d{4}\.\d{2}\.\d{2}_\d{2}\.\d{2}\.\d{2}: (?P<sender>[^:]+?): (?P<message>.+)$`
+
+// inputPreset bundles the request adapter and the game log pattern for one
+// game.
+type inputPreset struct {
+	adapter     func(cfg *AppConfig) InputAdapter
+	tailPattern string
+}
+
+// inputPresets lists the supported presets. Conan Exiles is the default.
+var inputPresets = map[string]inputPreset{
+	presetConan: {
+		adapter: func(*AppConfig) InputAdapter {
+			return InputAdapterFunc(func(r *http.Request) IncomingMessage {
+				sender, message := parseMessage(r)
+				return IncomingMessage{Sender: sender, Message: message, Scene: parseScene(r)}
+			})
+		},
+		tailPattern: defaultTailPattern,
+	},
+	presetARK: {
+		adapter: func(*AppConfig) InputAdapter {
+			return fieldAdapter{
+				sender:  []string{"CharacterName", "PlayerName", "SteamName"},
+				message: "Message",
+				scene:   "TribeName",
+			}
+		},
+		tailPattern: arkTailPattern,
+	},
+	presetGeneric: {
+		adapter: func(cfg *AppConfig) InputAdapter {
+			adapter := fieldAdapter{sender: []string{"sender"}, message: "message", scene: "scene"}
+			if len(cfg.InputFields) > 0 {
+				adapter.sender = cfg.InputFields[:1]
+			}
+			if len(cfg.InputFields) > 1 {
+				adapter.message = cfg.InputFields[1]
+			}
+			if len(cfg.InputFields) > 2 {
+				adapter.scene = cfg.InputFields[2]
+			}
+			return adapter
+		},
+		tailPattern: defaultUDPPattern,
+	},
+}
+
+// presetFor returns the config's input preset, defaulting to Conan Exiles.
+func presetFor(cfg *AppConfig) (inputPreset, error) {
+	name := cfg.InputPreset
+	if name == "" {
+		name = presetConan
+	}
+	preset, ok := inputPresets[name]
+	if !ok {
+		return inputPreset{}, fmt.Errorf("Unknown input preset %q", name)
+	}
+	return preset, nil
+}
+
+// inputAdapterFor returns the request adapter for the config's preset.
+func inputAdapterFor(cfg *AppConfig) InputAdapter {
+	preset, err := presetFor(cfg)
+	if err != nil {
+		preset = inputPresets[presetConan]
+	}
+	return preset.adapter(cfg)
+}
+
+// tailPatternFor returns the game log pattern used when none is configured.
+func tailPatternFor(cfg *AppConfig) string {
+	preset, err := presetFor(cfg)
+	if err != nil {
+		return defaultTailPattern
+	}
+	return preset.tailPattern
+}
+
+// fieldAdapter reads named fields from a JSON object body, or otherwise
+// from the query string and form body. The first non-empty sender field
+// wins.
+type fieldAdapter struct {
+	sender  []string
+	message string
+	scene   string
+}
+
+// Parse implements InputAdapter.
+func (f fieldAdapter) Parse(r *http.Request) IncomingMessage {
+	get := f.formValues(r)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		get = f.jsonValues(r)
+	}
+
+	var in IncomingMessage
+	for _, name := range f.sender {
+		if in.Sender = get(name); in.Sender != "" {
+			break
+		}
+	}
+	in.Message = get(f.message)
+	if f.scene != "" {
+		in.Scene = get(f.scene)
+	}
+	if in.Sender == "" {
+		in.Message = ""
+	}
+	return in
+}
+
+// formValues returns a lookup over the query string and form body.
+func (f fieldAdapter) formValues(r *http.Request) func(string) string {
+	values, _ := url.ParseQuery(r.URL.RawQuery)
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		r.Body = http.MaxBytesReader(nil, r.Body, maxInputBody)
+		if err := r.ParseForm(); err == nil {
+			values = r.Form
+		}
+	}
+	return func(name string) string {
+		return strings.TrimSpace(values.Get(name))
+	}
+}
+
+// jsonValues returns a lookup over a JSON object body. Non-string values
+// are rendered with fmt.
+func (f fieldAdapter) jsonValues(r *http.Request) func(string) string {
+	var body map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxInputBody)).Decode(&body); err != nil {
+		body = nil
+	}
+	return func(name string) string {
+		value, ok := body[name]
+		if !ok || value == nil {
+			return ""
+		}
+		if s, ok := value.(string); ok {
+			return strings.TrimSpace(s)
+		}
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInputAdapters(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   AppConfig
+		method   string
+		url      string
+		body     string
+		ctype    string
+		expected IncomingMessage
+	}{
+		{
+			name:     "conan query string",
+			method:   "GET",
+			url:      "/message?sender=Conan&message=Hello&channel=Tavern",
+			expected: IncomingMessage{Sender: "Conan", Message: "Hello", Scene: "Tavern"},
+		},
+		{
+			name:   "conan ignores body",
+			method: "POST",
+			url:    "/message",
+			body:   "sender=Conan&message=Hello",
+			ctype:  "application/x-www-form-urlencoded",
+		},
+		{
+			name:     "ark JSON body",
+			config:   AppConfig{InputPreset: presetARK},
+			method:   "POST",
+			url:      "/message",
+			body:     `{"PlayerName": "bob", "CharacterName": "Survivor", "Message": "Hello", "TribeName": "Raptors"}`,
+			ctype:    "application/json",
+			expected: IncomingMessage{Sender: "Survivor", Message: "Hello", Scene: "Raptors"},
+		},
+		{
+			name:     "ark falls back to player name",
+			config:   AppConfig{InputPreset: presetARK},
+			method:   "POST",
+			url:      "/message",
+			body:     `{"PlayerName": "bob", "Message": "Hi"}`,
+			ctype:    "application/json; charset=utf-8",
+			expected: IncomingMessage{Sender: "bob", Message: "Hi"},
+		},
+		{
+			name:     "generic custom query fields",
+			config:   AppConfig{InputPreset: presetGeneric, InputFields: []string{"name", "text", "room"}},
+			method:   "GET",
+			url:      "/message?name=Valeria&text=Hello&room=Keep",
+			expected: IncomingMessage{Sender: "Valeria", Message: "Hello", Scene: "Keep"},
+		},
+		{
+			name:     "generic form body",
+			config:   AppConfig{InputPreset: presetGeneric},
+			method:   "POST",
+			url:      "/message",
+			body:     "sender=Valeria&message=Hello",
+			ctype:    "application/x-www-form-urlencoded",
+			expected: IncomingMessage{Sender: "Valeria", Message: "Hello"},
+		},
+		{
+			name:   "generic without sender",
+			config: AppConfig{InputPreset: presetGeneric},
+			method: "GET",
+			url:    "/message?message=Hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.ctype != "" {
+				req.Header.Set("Content-Type", tt.ctype)
+			}
+			got := inputAdapterFor(&tt.config).Parse(req)
+			if got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestInputPresetValidation(t *testing.T) {
+	cfg := AppConfig{EnableLocalSave: true, Path: "/logs", InputPreset: "minecraft"}
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for unknown input preset")
+	}
+	cfg.InputPreset = presetARK
+	if err := cfg.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if tailPatternFor(&cfg) != arkTailPattern {
+		t.Error("expected ARK preset to provide the ARK game log pattern")
+	}
+}
+
+func TestCreateHandler_ARKPreset(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	tmpDir := t.TempDir()
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+	a.config.InputPreset = presetARK
+
+	req := httptest.NewRequest("POST", "/message", strings.NewReader(`{"CharacterName": "Survivor", "Message": "Hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	createHandler(a).ServeHTTP(rr, req)
+	waitForDeliveries(t, a)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	entries, err := readLogFile(generateLogFilename(tmpDir, "txt"), "txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Sender != "Survivor" || entries[0].Message != "Hello" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
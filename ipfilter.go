@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// parsePrefixes parses a list of IP addresses and CIDR ranges. A bare
+// address matches only itself.
+func parsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, item := range list {
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid CIDR range %q", item)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid IP address %q", item)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipAllowed reports whether addr may connect: it must not match deny and,
+// when allow is not empty, must match allow.
+func ipAllowed(allow, deny []netip.Prefix, addr netip.Addr) bool {
+	if prefixesContain(deny, addr) {
+		return false
+	}
+	return len(allow) == 0 || prefixesContain(allow, addr)
+}
+
+// remoteIP returns the address a request came from.
+func remoteIP(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// ipLists picks the allow and deny lists that apply to a server from the
+// config.
+type ipLists func(*AppConfig) (allow, deny []string)
+
+func ingestIPLists(c *AppConfig) (allow, deny []string) { return c.IngestAllowIPs, c.IngestDenyIPs }
+func webUIIPLists(c *AppConfig) (allow, deny []string)  { return c.WebUIAllowIPs, nil }
+
+// withIPFilter refuses requests to next from addresses the configured
+// lists don't allow. loopbackOK always lets the local machine through, so
+// a list can't lock the user out of the web UI.
+func (a *App) withIPFilter(lists ipLists, loopbackOK bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.configMu.RLock()
+		allowList, denyList := lists(a.config)
+		a.configMu.RUnlock()
+		if len(allowList) == 0 && len(denyList) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The lists were checked by validate, so errors can't happen here.
+		allow, _ := parsePrefixes(allowList)
+		deny, _ := parsePrefixes(denyList)
+		addr, ok := remoteIP(r)
+		if ok && ((loopbackOK && addr.IsLoopback()) || ipAllowed(allow, deny, addr)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		a.logger.Log("debug", fmt.Sprintf("Refused %s %s from %s: address not allowed", r.Method, r.URL.Path, r.RemoteAddr))
+		writeJSONError(w, http.StatusForbidden, "Your address is not allowed")
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		list    []string
+		wantErr bool
+	}{
+		{[]string{"203.0.113.7", "10.0.0.0/8", "2001:db8::/32", "::1"}, false},
+		{[]string{"10.0.0.1/8"}, false},
+		{[]string{"10.0.0.0/33"}, true},
+		{[]string{"game-server"}, true},
+	}
+	for _, tt := range tests {
+		if _, err := parsePrefixes(tt.list); (err != nil) != tt.wantErr {
+			t.Errorf("parsePrefixes(%v): unexpected error %v", tt.list, err)
+		}
+	}
+}
+
+func TestWithIPFilter(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		allow      []string
+		deny       []string
+		loopbackOK bool
+		remote     string
+		wantStatus int
+	}{
+		{"no lists", nil, nil, false, "198.51.100.1:5000", http.StatusOK},
+		{"allowed address", []string{"203.0.113.7"}, nil, false, "203.0.113.7:5000", http.StatusOK},
+		{"allowed range", []string{"10.0.0.0/8"}, nil, false, "10.1.2.3:5000", http.StatusOK},
+		{"not allowed", []string{"203.0.113.7"}, nil, false, "203.0.113.8:5000", http.StatusForbidden},
+		{"IPv4-mapped IPv6", []string{"203.0.113.7"}, nil, false, "[::ffff:203.0.113.7]:5000", http.StatusOK},
+		{"denied", nil, []string{"198.51.100.0/24"}, false, "198.51.100.9:5000", http.StatusForbidden},
+		{"deny wins over allow", []string{"10.0.0.0/8"}, []string{"10.0.0.5"}, false, "10.0.0.5:5000", http.StatusForbidden},
+		{"loopback refused", []string{"10.0.0.0/8"}, nil, false, "127.0.0.1:5000", http.StatusForbidden},
+		{"loopback always allowed", []string{"10.0.0.0/8"}, nil, true, "[::1]:5000", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lists := func(*AppConfig) ([]string, []string) { return tt.allow, tt.deny }
+			req := httptest.NewRequest("POST", "/message", nil)
+			req.RemoteAddr = tt.remote
+			rec := httptest.NewRecorder()
+			a.withIPFilter(lists, tt.loopbackOK, ok).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// languageWords are common short words of each language detectLanguage
+// knows, the ones that make up much of any chat line.
+var languageWords = map[string][]string{
+	"en": {"the", "and", "you", "is", "are", "to", "of", "it", "that", "what", "with", "have", "this", "i'm", "my", "your", "not", "was", "for", "hello", "hi", "yes", "no", "we", "they", "he", "she", "be", "do", "will", "can", "there", "here", "where", "how", "why"},
+	"es": {"el", "la", "los", "las", "que", "y", "es", "un", "una", "por", "para", "con", "no", "sí", "si", "pero", "como", "qué", "está", "estás", "eres", "soy", "tu", "yo", "mi", "hola", "gracias", "del", "al", "muy", "aquí", "dónde", "vamos", "hay", "se", "lo"},
+	"pt": {"o", "os", "as", "que", "e", "é", "um", "uma", "por", "para", "com", "não", "sim", "mas", "como", "você", "está", "eu", "meu", "olá", "oi", "obrigado", "obrigada", "do", "da", "dos", "das", "muito", "aqui", "onde", "vamos", "tem", "isso", "ele", "ela"},
+	"fr": {"le", "la", "les", "et", "est", "un", "une", "des", "que", "qui", "pour", "avec", "pas", "oui", "non", "mais", "comme", "vous", "tu", "je", "mon", "bonjour", "salut", "merci", "du", "au", "très", "ici", "où", "allons", "il", "elle", "c'est", "suis", "ce"},
+	"de": {"der", "die", "das", "und", "ist", "ein", "eine", "nicht", "ich", "du", "sie", "wir", "mit", "für", "auf", "ja", "nein", "aber", "wie", "was", "hallo", "danke", "mein", "dein", "sehr", "hier", "wo", "bin", "bist", "es", "zu", "den", "dem", "auch", "noch"},
+	"it": {"il", "lo", "la", "gli", "le", "e", "è", "un", "una", "che", "per", "con", "non", "sì", "ma", "come", "tu", "io", "mio", "ciao", "grazie", "del", "della", "molto", "qui", "dove", "andiamo", "sono", "sei", "di", "questo", "cosa", "anche", "ho", "hai"},
+}
+
+// languageLetters are letters that point to one language.
+var languageLetters = map[rune]string{
+	'ñ': "es", '¿': "es", '¡': "es",
+	'ã': "pt", 'õ': "pt",
+	'ß': "de", 'ä': "de", 'ö': "de", 'ü': "de",
+	'œ': "fr", 'ê': "fr", 'è': "fr", 'ç': "fr", 'û': "fr", 'î': "fr",
+	'ì': "it", 'ò': "it",
+}
+
+// languageWordSets indexes languageWords for lookup.
+var languageWordSets = func() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(languageWords))
+	for lang, words := range languageWords {
+		sets[lang] = make(map[string]bool, len(words))
+		for _, word := range words {
+			sets[lang][word] = true
+		}
+	}
+	return sets
+}()
+
+// minLanguageScore is the evidence detectLanguage needs before it names a
+// language: two common words, or one and a telltale letter.
+const minLanguageScore = 2
+
+// detectLanguage guesses the language of a chat line from its common words
+// and letters, returning its ISO 639-1 code (en, es, pt, fr, de or it), or
+// "" when the line is too short or ambiguous to tell. It is a heuristic
+// for routing chat, not a general-purpose detector.
+func detectLanguage(text string) string {
+	scores := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, word := range words {
+		for lang, set := range languageWordSets {
+			if set[word] {
+				scores[lang]++
+			}
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		if lang, ok := languageLetters[r]; ok {
+			scores[lang]++
+		}
+	}
+
+	best, bestScore, tie := "", 0, false
+	for lang, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = lang, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+	if bestScore < minLanguageScore || tie {
+		return ""
+	}
+	return best
+}
+
+// supportedLanguages lists the codes detectLanguage returns.
+func supportedLanguages() []string {
+	langs := make([]string, 0, len(languageWords))
+	for lang := range languageWords {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// checkLanguageRoutes reports a language detectLanguage doesn't know.
+func checkLanguageRoutes(routes map[string]string) error {
+	for lang := range routes {
+		if _, ok := languageWords[lang]; !ok {
+			return fmt.Errorf("Unknown language %q in language routes; use one of %s", lang, strings.Join(supportedLanguages(), ", "))
+		}
+	}
+	return nil
+}
+
+// languageDiscordTarget returns where a message in lang is posted, or ""
+// when its language has no route. Routes are webhook URLs, or channel IDs
+// in bot mode.
+func languageDiscordTarget(cfg *AppConfig, lang string) string {
+	route := cfg.LanguageRoutes[lang]
+	if route == "" || lang == "" {
+		return ""
+	}
+	return discordRouteURL(cfg, route)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"Where are you going with that sword?", "en"},
+		{"¿Dónde está la posada? Vamos ya", "es"},
+		{"Olá, você não vem com a gente?", "pt"},
+		{"Bonjour, je suis très content de vous voir", "fr"},
+		{"Ich bin hier und du bist nicht da", "de"},
+		{"Ciao, andiamo alla taverna, sono stanco", "it"},
+		{"lol", ""},
+		{"Conan!", ""},
+		{"no", ""},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.text); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageDiscordTarget(t *testing.T) {
+	cfg := &AppConfig{LanguageRoutes: map[string]string{"es": "https://discord.com/api/webhooks/1/es"}}
+	if got := languageDiscordTarget(cfg, "es"); got != "https://discord.com/api/webhooks/1/es" {
+		t.Errorf("es = %q", got)
+	}
+	if got := languageDiscordTarget(cfg, "en"); got != "" {
+		t.Errorf("unrouted language = %q", got)
+	}
+	if got := languageDiscordTarget(cfg, ""); got != "" {
+		t.Errorf("unknown language = %q", got)
+	}
+
+	cfg = &AppConfig{DiscordMode: discordModeBot, BotToken: "token", LanguageRoutes: map[string]string{"es": "123"}}
+	if got := languageDiscordTarget(cfg, "es"); got != botChannelURL("token", "123") {
+		t.Errorf("bot mode = %q", got)
+	}
+
+	if err := checkLanguageRoutes(map[string]string{"xx": "https://example.com"}); err == nil {
+		t.Error("unknown language accepted")
+	}
+}
+
+func TestProcessMessage_LanguageRoute(t *testing.T) {
+	bot := newFakeDiscordBot(t)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableDiscord = true
+	a.config.DiscordMode = discordModeBot
+	a.config.BotToken = "secret"
+	a.config.BotChannelID = "100"
+	a.config.LanguageRoutes = map[string]string{"es": "200"}
+
+	a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "Hello there, how are you?"})
+	a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "¿Dónde está la espada?"})
+	waitForDeliveries(t, a)
+
+	if got := bot.contents("100"); len(got) != 1 {
+		t.Errorf("main channel got %q, want the English message", got)
+	}
+	if got := bot.contents("200"); len(got) != 1 {
+		t.Errorf("Spanish channel got %q, want the Spanish message", got)
+	}
+}
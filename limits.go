@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Length policies for over-long senders and messages.
+const (
+	lengthTruncate = "truncate"
+	lengthReject   = "reject"
+)
+
+// Defaults used when no length limit is configured.
+const (
+	defaultMaxMessageLength = 2000
+	defaultMaxSenderLength  = 64
+)
+
+// checkMessageLimits enforces the configured sender and message length
+// limits and rejects text containing control characters. Lengths count
+// characters, not bytes.
+func checkMessageLimits(cfg *AppConfig, in IncomingMessage) (IncomingMessage, error) {
+	if !utf8.ValidString(in.Sender) || !utf8.ValidString(in.Message) {
+		return in, fmt.Errorf("invalid UTF-8")
+	}
+	if hasControlChars(in.Sender) || hasControlChars(in.Message) {
+		return in, fmt.Errorf("contains control characters")
+	}
+
+	maxSender := cfg.MaxSenderLength
+	if maxSender <= 0 {
+		maxSender = defaultMaxSenderLength
+	}
+	maxMessage := cfg.MaxMessageLength
+	if maxMessage <= 0 {
+		maxMessage = defaultMaxMessageLength
+	}
+
+	var err error
+	if in.Sender, err = limitLength(in.Sender, maxSender, cfg.LengthPolicy); err != nil {
+		return in, fmt.Errorf("sender %w", err)
+	}
+	if in.Message, err = limitLength(in.Message, maxMessage, cfg.LengthPolicy); err != nil {
+		return in, fmt.Errorf("message %w", err)
+	}
+	return in, nil
+}
+
+// limitLength truncates s to limit characters, or returns an error when
+// the policy is to reject.
+func limitLength(s string, limit int, policy string) (string, error) {
+	if utf8.RuneCountInString(s) <= limit {
+		return s, nil
+	}
+	if policy == lengthReject {
+		return s, fmt.Errorf("longer than %d characters", limit)
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:limit])), nil
+}
+
+// hasControlChars reports whether s contains control characters other
+// than tab.
+func hasControlChars(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return r != '\t' && unicode.IsControl(r)
+	}) >= 0
+}
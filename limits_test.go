@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckMessageLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         AppConfig
+		in          IncomingMessage
+		wantSender  string
+		wantMessage string
+		wantErr     bool
+	}{
+		{
+			name:        "within limits",
+			in:          IncomingMessage{Sender: "Alice", Message: "hello\tthere"},
+			wantSender:  "Alice",
+			wantMessage: "hello\tthere",
+		},
+		{
+			name:        "truncates by characters",
+			cfg:         AppConfig{MaxMessageLength: 3, MaxSenderLength: 2},
+			in:          IncomingMessage{Sender: "Ålice", Message: "héllo"},
+			wantSender:  "Ål",
+			wantMessage: "hél",
+		},
+		{
+			name:    "rejects long message",
+			cfg:     AppConfig{MaxMessageLength: 3, LengthPolicy: lengthReject},
+			in:      IncomingMessage{Sender: "Alice", Message: "hello"},
+			wantErr: true,
+		},
+		{
+			name:    "rejects long sender",
+			cfg:     AppConfig{MaxSenderLength: 3, LengthPolicy: lengthReject},
+			in:      IncomingMessage{Sender: "Alice", Message: "hi"},
+			wantErr: true,
+		},
+		{
+			name:    "default sender limit",
+			cfg:     AppConfig{LengthPolicy: lengthReject},
+			in:      IncomingMessage{Sender: strings.Repeat("a", defaultMaxSenderLength+1), Message: "hi"},
+			wantErr: true,
+		},
+		{
+			name:    "control character in message",
+			in:      IncomingMessage{Sender: "Alice", Message: "hi\x1b[31m"},
+			wantErr: true,
+		},
+		{
+			name:    "newline in sender",
+			in:      IncomingMessage{Sender: "Al\nice", Message: "hi"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid UTF-8",
+			in:      IncomingMessage{Sender: "Alice", Message: "hi\xff"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkMessageLimits(&tt.cfg, tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Sender != tt.wantSender || got.Message != tt.wantMessage {
+				t.Errorf("got %q/%q, want %q/%q", got.Sender, got.Message, tt.wantSender, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestProcessMessage_RejectsControlCharacters(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	tmpDir := t.TempDir()
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+
+	a.processMessage(t.Context(), IncomingMessage{Sender: "Alice", Message: "bad\x00text"})
+	a.processMessage(t.Context(), IncomingMessage{Sender: "Alice", Message: "good"})
+	waitForDeliveries(t, a)
+
+	entries, err := readLogFile(generateLogFilename(tmpDir, "txt"), "txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Message != "good" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// defaultDiscordTemplate renders a chat message for Discord. With a
+// per-character author the sender is already shown as the post's name.
+const defaultDiscordTemplate = "{{if .AsAuthor}}**[{{.Time}}]** {{else}}**[{{.Time}}] {{.Sender}}:** \n{{end}}{{.Message}}"
+
+// messageSentinel stands in for the message while a Discord template is
+// rendered, so long messages can be split between the text around it.
+const messageSentinel = "\x00message\x00"
+
+// lineData is what message templates can use. Discord templates get Time,
+// Date, Timestamp, Sender, Message and AsAuthor; text line templates get
+// everything but AsAuthor.
+type lineData struct {
+	Time      string // "15:04:05", with the date in front when not today on Discord
+	Date      string // "2006-01-02"
+	Timestamp string // "2006-01-02 15:04:05"
+	Sender    string
+	Message   string
+	Kind      string // "say", "emote" or "ooc"
+	Scene     string
+	Session   string
+	Source    string
+	AsAuthor  bool
+}
+
+// lineTemplates caches parsed templates by their text.
+var lineTemplates sync.Map
+
+// parseLineTemplate parses a message template, caching the result.
+func parseLineTemplate(text string) (*template.Template, error) {
+	if cached, ok := lineTemplates.Load(text); ok {
+		return cached.(*template.Template), nil
+	}
+	tmpl, err := template.New("line").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	lineTemplates.Store(text, tmpl)
+	return tmpl, nil
+}
+
+// renderLine executes a message template.
+func renderLine(text string, data lineData) (string, error) {
+	tmpl, err := parseLineTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// sampleLine is the message used to check and preview templates.
+func sampleLine(kind string) lineData {
+	data := lineData{
+		Time:      "21:04:05",
+		Date:      "2026-10-17",
+		Timestamp: "2026-10-17 21:04:05",
+		Sender:    "Conan",
+		Message:   "By Crom, the tavern is on fire!",
+		Kind:      kind,
+		Scene:     "Tavern",
+		Session:   "Chapter 3",
+		Source:    "Siptah",
+	}
+	switch kind {
+	case kindEmote:
+		data.Message = "draws his sword"
+	case kindOOC:
+		data.Message = "(( brb, dinner ))"
+	}
+	return data
+}
+
+// validateLineTemplate reports a template that doesn't parse or refers to
+// fields that don't exist.
+func validateLineTemplate(label, text string) error {
+	if text == "" {
+		return nil
+	}
+	if _, err := renderLine(text, sampleLine(kindSay)); err != nil {
+		return fmt.Errorf("%s is invalid: %v", label, err)
+	}
+	return nil
+}
+
+// discordTemplate returns the configured Discord template, or the default.
+func discordTemplate(config *AppConfig) string {
+	if config.DiscordTemplate == "" {
+		return defaultDiscordTemplate
+	}
+	return config.DiscordTemplate
+}
+
+// discordPostParts renders a Discord template around the message, returning
+// the text that goes before and after it in every chunk of a long
+// message. A template without {{.Message}} gets the message appended. An
+// invalid template falls back to the default.
+func discordPostParts(text string, asAuthor bool, sender string, at, now time.Time) (string, string) {
+	timestamp := at.Format("15:04:05")
+	if !truncateToDay(at).Equal(truncateToDay(now)) {
+		timestamp = at.Format("2006-01-02 15:04:05")
+	}
+	data := lineData{
+		Time:      timestamp,
+		Date:      at.Format("2006-01-02"),
+		Timestamp: at.Format(logTimestampLayout),
+		Sender:    sender,
+		Message:   messageSentinel,
+		AsAuthor:  asAuthor,
+	}
+	if text == "" {
+		text = defaultDiscordTemplate
+	}
+	rendered, err := renderLine(text, data)
+	if err != nil {
+		rendered, _ = renderLine(defaultDiscordTemplate, data)
+	}
+	prefix, suffix, ok := strings.Cut(rendered, messageSentinel)
+	if !ok {
+		return rendered, ""
+	}
+	return prefix, strings.ReplaceAll(suffix, messageSentinel, "")
+}
+
+// formatTextLineWith renders an entry as a transcript line with a custom
+// template, or with formatTextLine when text is empty or fails to render.
+func formatTextLineWith(text string, entry LogEntry) string {
+	if text == "" {
+		return formatTextLine(entry)
+	}
+	data := lineData{
+		Timestamp: entry.Timestamp,
+		Sender:    entry.Sender,
+		Message:   entry.Message,
+		Kind:      entry.Kind,
+		Scene:     entry.Scene,
+		Session:   entry.Session,
+		Source:    entry.Source,
+	}
+	if data.Kind == "" {
+		data.Kind = kindSay
+	}
+	data.Date, data.Time, _ = strings.Cut(entry.Timestamp, " ")
+	line, err := renderLine(text, data)
+	if err != nil {
+		return formatTextLine(entry)
+	}
+	return strings.TrimRight(line, "\r\n") + "\n"
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"html"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscordPostParts(t *testing.T) {
+	at := time.Date(2026, 10, 17, 15, 4, 5, 0, time.Local)
+	tests := []struct {
+		name       string
+		text       string
+		asAuthor   bool
+		now        time.Time
+		wantPrefix string
+		wantSuffix string
+	}{
+		{"default", "", false, at, "**[15:04:05] Conan:** \n", ""},
+		{"default as author", "", true, at, "**[15:04:05]** ", ""},
+		{"default other day", "", false, at.AddDate(0, 0, 1), "**[2026-10-17 15:04:05] Conan:** \n", ""},
+		{"custom with suffix", "> {{.Sender}}: {{.Message}} ({{.Date}})", false, at, "> Conan: ", " (2026-10-17)"},
+		{"no message field", "{{.Sender}} says", false, at, "Conan says", ""},
+		{"invalid falls back", "{{.Nope}}", false, at, "**[15:04:05] Conan:** \n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, suffix := discordPostParts(tt.text, tt.asAuthor, "Conan", at, tt.now)
+			if prefix != tt.wantPrefix || suffix != tt.wantSuffix {
+				t.Errorf("expected (%q, %q), got (%q, %q)", tt.wantPrefix, tt.wantSuffix, prefix, suffix)
+			}
+		})
+	}
+}
+
+func TestFormatTextLineWith(t *testing.T) {
+	entry := LogEntry{Timestamp: "2026-10-17 21:04:05", Sender: "Conan", Message: "draws his sword", Kind: kindEmote, Scene: "Tavern"}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"default", "", "[2026-10-17 21:04:05] * Conan draws his sword\n"},
+		{"custom", "{{.Time}} <{{.Sender}}> {{.Message}} [{{.Kind}} in {{.Scene}}]", "21:04:05 <Conan> draws his sword [emote in Tavern]\n"},
+		{"trailing newline kept single", "{{.Sender}}: {{.Message}}\n\n", "Conan: draws his sword\n"},
+		{"invalid falls back", "{{.Nope}}", "[2026-10-17 21:04:05] * Conan draws his sword\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatTextLineWith(tt.text, entry); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateLineTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AppConfig
+		wantErr string
+	}{
+		{"defaults", AppConfig{}, ""},
+		{"custom", AppConfig{DiscordTemplate: "{{.Sender}}: {{.Message}}", TextTemplate: "{{.Kind}} {{.Message}}"}, ""},
+		{"unclosed action", AppConfig{DiscordTemplate: "{{.Sender"}, "Discord template is invalid"},
+		{"unknown field", AppConfig{TextTemplate: "{{.Character}}"}, "Text line template is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.ListenAddr = defaultListenAddr
+			tt.cfg.EnableLocalSave = true
+			tt.cfg.Path = t.TempDir()
+			err := tt.cfg.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHandleTemplatePreview(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    url.Values
+		want    []string
+		notWant string
+	}{
+		{
+			name: "defaults",
+			form: url.Values{},
+			want: []string{"**[21:04:05] Conan:** \nBy Crom", "[2026-10-17 21:04:05] * Conan draws his sword"},
+		},
+		{
+			name: "custom",
+			form: url.Values{"discordTemplate": {"__{{.Sender}}__ {{.Message}}"}, "textTemplate": {"{{.Sender}} ({{.Kind}}): {{.Message}}"}},
+			want: []string{"__Conan__ By Crom", "Conan (ooc): (( brb, dinner ))"},
+		},
+		{
+			name:    "invalid",
+			form:    url.Values{"textTemplate": {"{{.Sender"}},
+			want:    []string{"Text line template is invalid"},
+			notWant: "Discord template is invalid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := setupTestApp()
+			defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+			req := httptest.NewRequest("POST", "/api/templates/preview", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			a.handleTemplatePreview(rec, req)
+
+			body := rec.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, html.EscapeString(want)) {
+					t.Errorf("expected %q in response, got %q", want, body)
+				}
+			}
+			if tt.notWant != "" && strings.Contains(body, tt.notWant) {
+				t.Errorf("did not expect %q in response", tt.notWant)
+			}
+		})
+	}
+}
+
+func TestImportTranscript_CustomTextTemplate(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.Path = t.TempDir()
+	a.config.TextTemplate = "{{.Sender}}: {{.Message}}"
+
+	_, err := a.importTranscript(strings.NewReader("[2026-10-17 18:00:00] Alice: hello\n"), "txt")
+	if err == nil || !strings.Contains(err.Error(), "clear the text line template") {
+		t.Errorf("expected the import to be refused, got %v", err)
+	}
+
+	a.config.FileFormat = "json"
+	if _, err := a.importTranscript(strings.NewReader("[2026-10-17 18:00:00] Alice: hello\n"), "txt"); err != nil {
+		t.Errorf("expected a json import to succeed, got %v", err)
+	}
+}
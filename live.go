@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// registerLiveRoutes adds the /live page, its chat stream and the static
+// files it needs to mux. They answer 404 while the page is switched off.
+func (a *App) registerLiveRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /live", a.handleLivePage)
+	mux.HandleFunc("GET /live/stream", a.handleLiveStream)
+	staticSub, _ := fs.Sub(staticFS, "static")
+	files := http.StripPrefix("/live/static/", http.FileServer(http.FS(staticSub)))
+	mux.HandleFunc("GET /live/static/", func(w http.ResponseWriter, r *http.Request) {
+		if !a.livePageEnabled() {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// livePageEnabled reports whether the public /live page is switched on.
+func (a *App) livePageEnabled() bool {
+	a.configMu.RLock()
+	defer a.configMu.RUnlock()
+	return a.config.EnableLivePage
+}
+
+// handleLivePage renders the read-only spectator transcript, or 404 when
+// the page is switched off.
+func (a *App) handleLivePage(w http.ResponseWriter, r *http.Request) {
+	if !a.livePageEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	tmpl, err := a.parseTemplates("templates/live.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "live", nil); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// handleLiveStream serves the chat stream to the /live page, or 404 when
+// the page is switched off.
+func (a *App) handleLiveStream(w http.ResponseWriter, r *http.Request) {
+	if !a.livePageEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	a.handleChatStream(w, r)
+}
+
+// senderHue picks a stable color hue (0-359) for a sender, so each
+// character keeps the same color on the live page.
+func senderHue(sender string) int {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(sender))))
+	return int(h.Sum32() % 360)
+}
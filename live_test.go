@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLiveRoutes(t *testing.T) {
+	a := setupTestApp()
+	a.chat = newChatFeed()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop(); a.chat.stop() }()
+	a.chat.publish(chatLine(a.config, LogEntry{Timestamp: "2026-10-17 21:04:05", Sender: "Conan", Message: "By Crom!"}))
+	mux := http.NewServeMux()
+	a.registerLiveRoutes(mux)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/live", "Live Transcript"},
+		{"/live/stream", "Conan: By Crom!"},
+		{"/live/static/style.css", ".live-viewer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			a.config.EnableLivePage = false
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil).WithContext(ctx))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("expected 404 while switched off, got %d", rec.Code)
+			}
+
+			a.config.EnableLivePage = true
+			rec = httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil).WithContext(ctx))
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("expected 200 with %q, got %d: %.200s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSenderHue(t *testing.T) {
+	if senderHue("Conan") != senderHue(" conan ") {
+		t.Error("expected the hue to ignore case and surrounding spaces")
+	}
+	for _, name := range []string{"", "Conan", "Valeria", "Bêlit"} {
+		if h := senderHue(name); h < 0 || h >= 360 {
+			t.Errorf("hue %d for %q out of range", h, name)
+		}
+	}
+}
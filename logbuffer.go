@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// maxFlushInterval bounds FlushInterval, in seconds.
+	maxFlushInterval = 300
+	// logBufferIdle is how long a buffered log file stays open unused,
+	// after a day's file is finished for example.
+	logBufferIdle = 5 * time.Minute
+	// logBufferCheck is how often runLogFlusher looks for due flushes.
+	logBufferCheck = time.Second
+)
+
+// bufferedLog is an open log file and the writes not yet flushed to it.
+type bufferedLog struct {
+	file    *os.File
+	buf     *bufio.Writer
+	head    []byte    // start of the file
+	written time.Time // last write
+	flushed time.Time // last flush
+}
+
+// logBufferPool keeps append-only log files (txt, csv and docx) open with a
+// write buffer when FlushInterval is set, so a message costs a buffered
+// write instead of opening and closing the file, which is slow on network
+// drives.
+type logBufferPool struct {
+	mu    sync.Mutex
+	files map[string]*bufferedLog
+}
+
+// logBuffers holds the buffered log files of the process.
+var logBuffers = &logBufferPool{}
+
+// writeLogEntryBuffered is writeLogEntry through logBuffers. json rewrites
+// the whole file, so it is written directly.
+func writeLogEntryBuffered(filename, format string, layout logLayout, entry LogEntry) error {
+	switch format {
+	case "csv":
+		return logBuffers.write(filename, func(head []byte) ([]byte, error) {
+			return encodeCSVEntry(head, layout, entry)
+		})
+	case "json":
+		return writeLogEntry(filename, format, layout, entry)
+	default:
+		return logBuffers.write(filename, func([]byte) ([]byte, error) {
+			return []byte(formatTextLineWith(layout.Line, entry)), nil
+		})
+	}
+}
+
+// write appends what data returns to filename's buffer, opening the file
+// first if needed. data gets the start of the file, empty for a new one.
+func (p *logBufferPool) write(filename string, data func(head []byte) ([]byte, error)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	log, ok := p.files[filename]
+	if !ok {
+		file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return fmt.Errorf("opening log file: %w", err)
+		}
+		head, err := readHead(file)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("reading log file: %w", err)
+		}
+		now := time.Now()
+		log = &bufferedLog{file: file, buf: bufio.NewWriter(file), head: head, flushed: now}
+		if p.files == nil {
+			p.files = make(map[string]*bufferedLog)
+		}
+		p.files[filename] = log
+	}
+	b, err := data(log.head)
+	if err != nil {
+		return err
+	}
+	if _, err := log.buf.Write(b); err != nil {
+		// A bufio.Writer keeps failing after an error; start over.
+		p.closeLocked(filename)
+		return fmt.Errorf("writing to log file: %w", err)
+	}
+	if len(log.head) == 0 {
+		log.head = b
+	}
+	log.written = time.Now()
+	return nil
+}
+
+// flushDue flushes the files whose oldest unflushed write is interval
+// old, and closes the ones unused for logBufferIdle.
+func (p *logBufferPool) flushDue(interval time.Duration, now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for name, log := range p.files {
+		idle := now.Sub(log.written) >= logBufferIdle
+		if log.buf.Buffered() > 0 && (now.Sub(log.flushed) >= interval || idle) {
+			if err := log.buf.Flush(); err != nil {
+				errs = append(errs, fmt.Errorf("flushing %s: %w", name, err))
+				idle = true // reopened on the next write
+			}
+			log.flushed = now
+		}
+		if idle {
+			errs = append(errs, p.closeLocked(name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flushFile writes out and closes filename's buffer, if it has one.
+func (p *logBufferPool) flushFile(filename string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.files[filename]; !ok {
+		return nil
+	}
+	return p.closeLocked(filename)
+}
+
+// flush writes out every buffer and closes the files, so they can be read,
+// moved or deleted. Later writes reopen them.
+func (p *logBufferPool) flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for name := range p.files {
+		errs = append(errs, p.closeLocked(name))
+	}
+	return errors.Join(errs...)
+}
+
+// closeLocked flushes and closes one file; p.mu must be held.
+func (p *logBufferPool) closeLocked(name string) error {
+	log := p.files[name]
+	delete(p.files, name)
+	err := log.buf.Flush()
+	if cerr := log.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("closing %s: %w", name, err)
+	}
+	return nil
+}
+
+// flushLogs writes buffered log lines out before log files are read.
+func flushLogs() {
+	if err := logBuffers.flush(); err != nil {
+		slog.Error("Flushing log files failed", "err", err)
+	}
+}
+
+// lockArchive takes archiveMu with the buffered log lines written out and
+// the files closed, for work that reads, moves or rewrites log files.
+func (a *App) lockArchive() {
+	a.archiveMu.Lock()
+	flushLogs()
+}
+
+// runLogFlusher flushes buffered log files every FlushInterval seconds.
+// The config is read on every check so changes apply without a restart;
+// turning buffering off flushes right away.
+func (a *App) runLogFlusher() {
+	ticker := time.NewTicker(logBufferCheck)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-ticker.C:
+			a.configMu.RLock()
+			interval := time.Duration(a.config.FlushInterval) * time.Second
+			a.configMu.RUnlock()
+			if err := logBuffers.flushDue(interval, now); err != nil {
+				slog.Error("Flushing log files failed", "err", err)
+				a.logger.Log("error", fmt.Sprintf("Flushing log files failed: %v", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogBufferPool_FlushDue(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.txt")
+	var p logBufferPool
+	line := func([]byte) ([]byte, error) { return []byte("line\n"), nil }
+	if err := p.write(filename, line); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filename); len(data) != 0 {
+		t.Fatalf("written before a flush: %q", data)
+	}
+
+	now := time.Now()
+	if err := p.flushDue(time.Minute, now); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filename); len(data) != 0 {
+		t.Fatalf("flushed before the interval: %q", data)
+	}
+	if err := p.flushDue(time.Minute, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "line\n" {
+		t.Fatalf("after the interval: %q", data)
+	}
+	if len(p.files) != 1 {
+		t.Fatal("file closed while in use")
+	}
+
+	if err := p.flushDue(time.Minute, now.Add(logBufferIdle)); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.files) != 0 {
+		t.Error("idle file left open")
+	}
+}
+
+func TestWriteLogEntryBuffered(t *testing.T) {
+	dir := t.TempDir()
+	entry := LogEntry{Timestamp: "2026-10-18 12:00:00", Sender: "Conan", Message: "By Crom"}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"txt", "[2026-10-18 12:00:00] Conan: By Crom\n[2026-10-18 12:00:00] Conan: By Crom\n"},
+		{"csv", "Timestamp,Sender,Message,Type,Session,Source\n2026-10-18 12:00:00,Conan,By Crom,say,,\n2026-10-18 12:00:00,Conan,By Crom,say,,\n"},
+		{"docx", "[2026-10-18 12:00:00] Conan: By Crom\n[2026-10-18 12:00:00] Conan: By Crom\n"},
+	}
+	for _, tt := range tests {
+		filename := filepath.Join(dir, "log."+tt.format)
+		// The file is closed in between, so the CSV header isn't repeated
+		// when it is reopened.
+		for range 2 {
+			if err := writeLogEntryBuffered(filename, tt.format, logLayout{}, entry); err != nil {
+				t.Fatal(err)
+			}
+			flushLogs()
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.ReplaceAll(string(data), "\r\n", "\n"); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestWriteLogEntry_FlushesBufferFirst(t *testing.T) {
+	defer flushLogs()
+	filename := filepath.Join(t.TempDir(), "log.txt")
+	first := LogEntry{Timestamp: "2026-10-18 12:00:00", Sender: "Conan", Message: "first"}
+	second := LogEntry{Timestamp: "2026-10-18 12:00:01", Sender: "Conan", Message: "second"}
+
+	// FlushInterval is turned off between the two messages
+	if err := writeLogEntryBuffered(filename, "txt", logLayout{}, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeLogEntry(filename, "txt", logLayout{}, second); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[2026-10-18 12:00:00] Conan: first\n[2026-10-18 12:00:01] Conan: second\n"
+	if got := strings.ReplaceAll(string(data), "\r\n", "\n"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
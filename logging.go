@@ -1,9 +1,11 @@
 package main
 
 import (
-	"encoding/csv"
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,55 +18,327 @@ type LogEntry struct {
 	Timestamp string `json:"timestamp"`
 	Sender    string `json:"sender"`
 	Message   string `json:"message"`
+	Kind      string `json:"kind,omitempty"`
+	Session   string `json:"session,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Scene     string `json:"scene,omitempty"`
 }
 
+// formatTextLine renders an entry as a plain-text transcript line. Emotes
+// use the familiar "* Name action" form.
+func formatTextLine(entry LogEntry) string {
+	switch entry.Kind {
+	case kindEmote:
+		return fmt.Sprintf("[%s] * %s %s\n", entry.Timestamp, entry.Sender, entry.Message)
+	case kindOOC:
+		return fmt.Sprintf("[%s] (OOC) %s: %s\n", entry.Timestamp, entry.Sender, entry.Message)
+	}
+	return fmt.Sprintf("[%s] %s: %s\n", entry.Timestamp, entry.Sender, entry.Message)
+}
+
+// defaultFilenameTemplate names log files when no template is configured.
+const defaultFilenameTemplate = "ConanExiles_log_{date}.{format}"
+
+// filenamePlaceholders are the per-entry placeholders a filename template
+// may use besides {date} and {format}. A template using them splits a day
+// across several files.
+var filenamePlaceholders = []string{"{sender}", "{scene}", "{session}", "{source}"}
+
+// Folder layouts for log files under the log path.
+const (
+	folderFlat  = "flat"  // all files in the log folder
+	folderMonth = "month" // 2024/05/
+	folderScene = "scene" // scenes/<scene>/; entries without a scene stay at the top
+)
+
+// scenesDir holds the per-scene folders of the scene layout.
+const scenesDir = "scenes"
+
 // generateLogFilename returns the full file path for today's log file
 // in the given format (e.g. "txt", "csv", "json", "docx").
 func generateLogFilename(basePath, format string) string {
-	date := time.Now().Format("2006-01-02")
-	filename := fmt.Sprintf("ConanExiles_log_%s.%s", date, format)
-	return filepath.Join(basePath, filename)
+	return logFilenameForDate(basePath, format, time.Now())
 }
 
-// logToFile writes a chat message to a local file in the format
-// specified by the config (txt, csv, json, or docx).
-func logToFile(config *AppConfig, sender, message string) error {
-	if !config.EnableLocalSave || config.Path == "" {
+// logFilenameForDate returns the daily log file path for the given date
+// using the default filename template.
+func logFilenameForDate(basePath, format string, date time.Time) string {
+	return filepath.Join(basePath, renderFilename(defaultFilenameTemplate, format, date, LogEntry{}))
+}
+
+// logFilePath returns the file an entry logged on date belongs to, using
+// the configured filename template.
+func logFilePath(config *AppConfig, basePath string, date time.Time, entry LogEntry) string {
+	name := renderFilename(filenameTemplate(config), logFormat(config), date, entry)
+	return filepath.Join(basePath, logSubdir(config, date, entry), name)
+}
+
+// logSubdir returns the folder, relative to the log path, that an entry
+// logged on date goes into under the configured folder layout.
+func logSubdir(config *AppConfig, date time.Time, entry LogEntry) string {
+	switch config.FolderLayout {
+	case folderMonth:
+		return filepath.Join(date.Format("2006"), date.Format("01"))
+	case folderScene:
+		if entry.Scene == "" {
+			return ""
+		}
+		return filepath.Join(scenesDir, filenameValue(entry.Scene))
+	default:
+		return ""
+	}
+}
+
+// logFilesForDate returns the existing log files for date under basePath.
+// Templates with per-entry placeholders and the scene layout can yield
+// several files.
+func logFilesForDate(config *AppConfig, basePath string, date time.Time) ([]string, error) {
+	pattern := renderFilename(filenameTemplate(config), logFormat(config), date, LogEntry{})
+	dir := filepath.Join(basePath, logSubdir(config, date, LogEntry{}))
+	files, err := globLogFiles(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if config.FolderLayout == folderScene {
+		scened, err := globLogFiles(filepath.Join(basePath, scenesDir, "*"), pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, scened...)
+	}
+	return files, nil
+}
+
+// globLogFiles returns the files in dir matching the rendered filename
+// pattern. A "*" at the end of dir matches any folder; the rest is literal.
+func globLogFiles(dir, pattern string) ([]string, error) {
+	if !strings.ContainsAny(dir+pattern, "*") {
+		path := filepath.Join(dir, pattern)
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+		return []string{path}, nil
+	}
+	escaped := globEscape(dir)
+	if strings.HasSuffix(dir, "*") {
+		escaped = globEscape(strings.TrimSuffix(dir, "*")) + "*"
+	}
+	matches, err := filepath.Glob(filepath.Join(escaped, pattern))
+	if err != nil {
+		return nil, fmt.Errorf("listing log files: %w", err)
+	}
+	return matches, nil
+}
+
+// filenameTemplate returns the configured template, or the default.
+func filenameTemplate(config *AppConfig) string {
+	if config.FilenameTemplate == "" {
+		return defaultFilenameTemplate
+	}
+	return config.FilenameTemplate
+}
+
+// renderFilename fills in a filename template. A zero entry renders the
+// per-entry placeholders as "*", giving a glob pattern for the whole day.
+// The format extension is appended when the template doesn't include it.
+func renderFilename(template, format string, date time.Time, entry LogEntry) string {
+	value := func(s string) string {
+		if entry == (LogEntry{}) {
+			return "*"
+		}
+		if s == "" {
+			return "none"
+		}
+		return filenameValue(s)
+	}
+	name := strings.NewReplacer(
+		"{date}", date.Format("2006-01-02"),
+		"{format}", format,
+		"{sender}", value(entry.Sender),
+		"{scene}", value(entry.Scene),
+		"{session}", value(entry.Session),
+		"{source}", value(entry.Source),
+	).Replace(template)
+	if !strings.Contains(template, "{format}") {
+		name += "." + format
+	}
+	return name
+}
+
+// filenameValue makes a placeholder value safe to use in a file name,
+// including in a glob pattern.
+func filenameValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '*', '?', '[', ']':
+			return '_'
+		}
+		return r
+	}, sanitizeFilename(s))
+}
+
+// globEscape escapes glob metacharacters in a literal path.
+func globEscape(path string) string {
+	return strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]").Replace(path)
+}
+
+// validateFilenameTemplate checks that a template names a file inside the
+// log folder and makes each day's name unique.
+func validateFilenameTemplate(template string) error {
+	if template == "" {
 		return nil
 	}
+	if !strings.Contains(template, "{date}") {
+		return fmt.Errorf("Filename template must contain {date}")
+	}
+	if strings.ContainsAny(template, `/\:*?"<>|`) {
+		return fmt.Errorf("Filename template cannot contain path separators or any of : * ? \" < > |")
+	}
+	rest := template
+	for _, p := range append([]string{"{date}", "{format}"}, filenamePlaceholders...) {
+		rest = strings.ReplaceAll(rest, p, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("Unknown placeholder in filename template; use {date}, {sender}, {scene}, {session}, {source} or {format}")
+	}
+	return nil
+}
 
-	logEntry := LogEntry{
+// newLogEntry builds a log entry for a message received now, classifying
+// it as speech, emote or OOC according to the config.
+func newLogEntry(config *AppConfig, sender, message string) LogEntry {
+	entry := LogEntry{
 		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
 		Sender:    sender,
 		Message:   message,
 	}
+	if detectOOC(config, message) {
+		entry.Kind = kindOOC
+	} else if text, ok := detectEmote(config, message); ok {
+		entry.Message = text
+		entry.Kind = kindEmote
+	}
+	return entry
+}
 
+// logFormat returns the configured file format, falling back to txt for
+// unknown values.
+func logFormat(config *AppConfig) string {
 	switch config.FileFormat {
-	case "txt":
-		return logToTxt(config.Path, logEntry)
+	case "txt", "csv", "json", "docx":
+		return config.FileFormat
+	default:
+		return "txt"
+	}
+}
+
+// logToFile writes a log entry to today's log file in the format specified
+// by the config (txt, csv, json, or docx). Entries tagged with a session are
+// also appended to that session's transcript.
+func logToFile(config *AppConfig, entry LogEntry) error {
+	if !config.EnableLocalSave || config.Path == "" {
+		return nil
+	}
+
+	format := logFormat(config)
+	basePath := config.Path
+	separate := false
+	if entry.Kind == kindOOC {
+		switch config.OOCFilePolicy {
+		case oocExclude:
+			return nil
+		case oocSeparate:
+			basePath = filepath.Join(basePath, "ooc")
+			if err := os.MkdirAll(basePath, 0755); err != nil {
+				return fmt.Errorf("creating ooc log directory: %w", err)
+			}
+			separate = true
+		}
+	}
+
+	filename := logFilePath(config, basePath, time.Now(), entry)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("creating log directory: %w", err)
+	}
+	write := writeLogEntry
+	if config.FlushInterval > 0 {
+		write = writeLogEntryBuffered
+	}
+	layout := layoutOf(config)
+	if err := write(filename, format, layout, entry); err != nil {
+		return err
+	}
+
+	if entry.Session != "" && !separate {
+		sessionDir := filepath.Join(config.Path, "sessions")
+		if err := os.MkdirAll(sessionDir, 0755); err != nil {
+			return fmt.Errorf("creating session log directory: %w", err)
+		}
+		if err := write(sessionLogFilename(config.Path, entry.Session, format), format, layout, entry); err != nil {
+			return fmt.Errorf("writing session transcript: %w", err)
+		}
+	}
+	return nil
+}
+
+// sessionLogFilename returns the transcript path for a named session.
+// Reusing a session name appends to the same transcript.
+func sessionLogFilename(basePath, session, format string) string {
+	return filepath.Join(basePath, "sessions", fmt.Sprintf("%s.%s", sanitizeFilename(session), format))
+}
+
+// sanitizeFilename replaces characters that are unsafe in file names on
+// any supported platform.
+func sanitizeFilename(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '-' || r == '_' || r == ' ' || r == '.':
+			return r
+		case r > 127:
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimSpace(name))
+	cleaned = strings.Trim(cleaned, ". ")
+	if cleaned == "" {
+		return "session"
+	}
+	return cleaned
+}
+
+// writeLogEntry appends an entry to filename using the given format. Text
+// lines use layout.Line, or the default line format when it is empty.
+func writeLogEntry(filename, format string, layout logLayout, entry LogEntry) error {
+	defer logFileLocks.lock(filename)()
+	// Lines still buffered from before FlushInterval was turned off come
+	// first.
+	if err := logBuffers.flushFile(filename); err != nil {
+		return err
+	}
+	switch format {
 	case "csv":
-		return logToCsv(config.Path, logEntry)
+		return logToCsv(filename, layout, entry)
 	case "json":
-		return logToJson(config.Path, logEntry)
+		return logToJson(filename, entry)
 	case "docx":
-		return logToDocx(config.Path, logEntry)
+		return logToDocx(filename, layout.Line, entry)
 	default:
-		return logToTxt(config.Path, logEntry)
+		return logToTxt(filename, layout.Line, entry)
 	}
 }
 
 // logToTxt appends a log entry as a plain-text line to a .txt file.
-func logToTxt(basePath string, entry LogEntry) error {
-	filename := generateLogFilename(basePath, "txt")
-
+func logToTxt(filename, lineTemplate string, entry LogEntry) error {
 	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("opening txt log file: %w", err)
 	}
 	defer file.Close()
 
-	logLine := fmt.Sprintf("[%s] %s: %s\n", entry.Timestamp, entry.Sender, entry.Message)
-	if _, err = file.WriteString(logLine); err != nil {
+	if _, err = file.WriteString(formatTextLineWith(lineTemplate, entry)); err != nil {
 		return fmt.Errorf("writing to txt log file: %w", err)
 	}
 	return nil
@@ -72,40 +346,46 @@ func logToTxt(basePath string, entry LogEntry) error {
 
 // logToCsv appends a log entry as a CSV row, creating the header row
 // if the file does not yet exist.
-func logToCsv(basePath string, entry LogEntry) error {
-	filename := generateLogFilename(basePath, "csv")
-
-	fileExists := true
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		fileExists = false
-	}
-
-	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+func logToCsv(filename string, layout logLayout, entry LogEntry) error {
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
 		return fmt.Errorf("opening csv log file: %w", err)
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	if !fileExists {
-		if err := writer.Write([]string{"Timestamp", "Sender", "Message"}); err != nil {
-			return fmt.Errorf("writing csv header: %w", err)
-		}
+	head, err := readHead(file)
+	if err != nil {
+		return fmt.Errorf("reading csv log file: %w", err)
 	}
-
-	if err := writer.Write([]string{entry.Timestamp, entry.Sender, entry.Message}); err != nil {
-		return fmt.Errorf("writing csv row: %w", err)
+	row, err := encodeCSVEntry(head, layout, entry)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(row); err != nil {
+		return fmt.Errorf("writing to csv log file: %w", err)
 	}
 	return nil
 }
 
+// csvHeader is the first row of a CSV log.
+var csvHeader = []string{"Timestamp", "Sender", "Message", "Type", "Session", "Source"}
+
+// csvRow returns the CSV log row of an entry, escaped by escapeCSVCell.
+func csvRow(entry LogEntry) []string {
+	kind := entry.Kind
+	if kind == "" {
+		kind = kindSay
+	}
+	row := []string{entry.Timestamp, entry.Sender, entry.Message, kind, entry.Session, entry.Source}
+	for i, cell := range row {
+		row[i] = escapeCSVCell(cell)
+	}
+	return row
+}
+
 // logToJson appends a log entry to a JSON array file. Existing entries
 // are read first and the new entry is appended.
-func logToJson(basePath string, entry LogEntry) error {
-	filename := generateLogFilename(basePath, "json")
-
+func logToJson(filename string, entry LogEntry) error {
 	var entries []LogEntry
 
 	if data, err := os.ReadFile(filename); err == nil {
@@ -131,19 +411,125 @@ func logToJson(basePath string, entry LogEntry) error {
 }
 
 // logToDocx appends a log entry as a plain-text line to a .docx file.
-func logToDocx(basePath string, entry LogEntry) error {
-	filename := generateLogFilename(basePath, "txt")
-	filename = strings.Replace(filename, ".txt", ".docx", 1)
-
+func logToDocx(filename, lineTemplate string, entry LogEntry) error {
 	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("opening docx log file: %w", err)
 	}
 	defer file.Close()
 
-	logLine := fmt.Sprintf("[%s] %s: %s\n", entry.Timestamp, entry.Sender, entry.Message)
-	if _, err = file.WriteString(logLine); err != nil {
+	if _, err = file.WriteString(formatTextLineWith(lineTemplate, entry)); err != nil {
 		return fmt.Errorf("writing to docx log file: %w", err)
 	}
 	return nil
 }
+
+// readLogFile reads the entries back from a log file written in the given
+// format. Plain-text lines that don't look like log entries are skipped.
+func readLogFile(filename, format string) ([]LogEntry, error) {
+	switch format {
+	case "json":
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("reading json log file: %w", err)
+		}
+		var entries []LogEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("parsing json log file: %w", err)
+		}
+		return entries, nil
+	case "csv":
+		return readCsvLog(filename)
+	default:
+		return readTextLog(filename)
+	}
+}
+
+// readCsvLog reads entries from a CSV log, tolerating files written before
+// the Type, Session and Source columns were added.
+func readCsvLog(filename string) ([]LogEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening csv log file: %w", err)
+	}
+	defer file.Close()
+
+	reader := newCSVReader(file)
+
+	var entries []LogEntry
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing csv log file: %w", err)
+		}
+		if len(record) < 3 || record[0] == "Timestamp" {
+			continue
+		}
+		for i, cell := range record {
+			record[i] = unescapeCSVCell(cell)
+		}
+		entry := LogEntry{Timestamp: record[0], Sender: record[1], Message: record[2]}
+		if len(record) > 3 && record[3] != kindSay {
+			entry.Kind = record[3]
+		}
+		if len(record) > 4 {
+			entry.Session = record[4]
+		}
+		if len(record) > 5 {
+			entry.Source = record[5]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readTextLog reads entries from a txt (or docx) log written by formatTextLine.
+func readTextLog(filename string) ([]LogEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening log file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []LogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if entry, ok := parseTextLine(scanner.Text()); ok {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading log file: %w", err)
+	}
+	return entries, nil
+}
+
+// parseTextLine is the inverse of formatTextLine. Emote lines don't mark
+// where the sender's name ends, so the first word is taken as the sender.
+func parseTextLine(line string) (LogEntry, bool) {
+	if !strings.HasPrefix(line, "[") {
+		return LogEntry{}, false
+	}
+	timestamp, rest, ok := strings.Cut(line[1:], "] ")
+	if !ok {
+		return LogEntry{}, false
+	}
+	entry := LogEntry{Timestamp: timestamp}
+
+	switch {
+	case strings.HasPrefix(rest, "* "):
+		entry.Kind = kindEmote
+		entry.Sender, entry.Message, _ = strings.Cut(rest[2:], " ")
+		return entry, entry.Sender != ""
+	case strings.HasPrefix(rest, "(OOC) "):
+		entry.Kind = kindOOC
+		rest = rest[len("(OOC) "):]
+	}
+
+	entry.Sender, entry.Message, ok = strings.Cut(rest, ": ")
+	return entry, ok
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenderFilename(t *testing.T) {
+	date := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	entry := LogEntry{Timestamp: "2026-10-17 12:00:00", Sender: "Thrall/Kara", Scene: "Tavern", Message: "hi"}
+
+	tests := []struct {
+		name     string
+		template string
+		entry    LogEntry
+		want     string
+	}{
+		{"default", defaultFilenameTemplate, entry, "ConanExiles_log_2026-10-17.txt"},
+		{"extension added", "rp_{date}", entry, "rp_2026-10-17.txt"},
+		{"sender sanitized", "{sender}_{date}.{format}", entry, "Thrall_Kara_2026-10-17.txt"},
+		{"empty value", "{session}_{scene}_{date}", entry, "none_Tavern_2026-10-17.txt"},
+		{"glob for day", "{sender}_{date}", LogEntry{}, "*_2026-10-17.txt"},
+		{"glob chars escaped", "{sender}_{date}", LogEntry{Timestamp: "x", Sender: "a*b?"}, "a_b__2026-10-17.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderFilename(tt.template, "txt", date, tt.entry); got != tt.want {
+				t.Errorf("renderFilename(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFilenameTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  bool
+	}{
+		{"", false},
+		{defaultFilenameTemplate, false},
+		{"{source}-{scene}-{sender}-{session}-{date}", false},
+		{"log_{format}", true},
+		{"logs/{date}", true},
+		{`..\{date}`, true},
+		{"{date}*", true},
+		{"{date}_{server}", true},
+		{"{date}_{sender", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			err := validateFilenameTemplate(tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateFilenameTemplate(%q) error = %v, wantErr %v", tt.template, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogToFile_FilenameTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &AppConfig{EnableLocalSave: true, Path: tmpDir, FileFormat: "txt", FilenameTemplate: "{sender}_{date}"}
+
+	for _, sender := range []string{"Kara", "Bren", "Kara"} {
+		if err := logToFile(cfg, newLogEntry(cfg, sender, "hello")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := logFilesForDate(cfg, tmpDir, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %v", files)
+	}
+	entries, err := readLogFile(filepath.Join(tmpDir, "Kara_"+time.Now().Format("2006-01-02")+".txt"), "txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected 2 entries for Kara, got %d", len(entries))
+	}
+}
+
+func TestLogToFile_FolderLayout(t *testing.T) {
+	now := time.Now()
+	day := now.Format("2006-01-02")
+	tests := []struct {
+		layout string
+		want   []string
+	}{
+		{folderFlat, []string{"ConanExiles_log_" + day + ".txt"}},
+		{folderMonth, []string{filepath.Join(now.Format("2006"), now.Format("01"), "ConanExiles_log_"+day+".txt")}},
+		{folderScene, []string{
+			"ConanExiles_log_" + day + ".txt",
+			filepath.Join(scenesDir, "Tavern", "ConanExiles_log_"+day+".txt"),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			cfg := &AppConfig{EnableLocalSave: true, Path: tmpDir, FileFormat: "txt", FolderLayout: tt.layout}
+			for _, scene := range []string{"", "Tavern"} {
+				entry := newLogEntry(cfg, "Kara", "hello")
+				entry.Scene = scene
+				if err := logToFile(cfg, entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			files, err := logFilesForDate(cfg, tmpDir, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != len(tt.want) {
+				t.Fatalf("got files %v, want %v", files, tt.want)
+			}
+			for i, want := range tt.want {
+				if files[i] != filepath.Join(tmpDir, want) {
+					t.Errorf("file %d = %s, want %s", i, files[i], want)
+				}
+			}
+
+			entries, _, err := entriesBetween(cfg, truncateToDay(now), now.Add(time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 2 {
+				t.Errorf("expected 2 entries, got %d", len(entries))
+			}
+		})
+	}
+}
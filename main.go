@@ -3,12 +3,16 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -24,19 +28,57 @@ const (
 type App struct {
 	config   *AppConfig
 	configMu sync.RWMutex
+	sceneMu  sync.Mutex
+	// charactersMu serializes edits to the characters.
+	charactersMu sync.Mutex
+	// annotationsMu serializes edits to the annotations file.
+	annotationsMu sync.Mutex
+	// archiveMu keeps live log writes out of files being rewritten by
+	// an import, pruned or backed up; live writes take the read lock. Take
+	// the write lock with lockArchive, which also flushes buffered log
+	// files.
+	archiveMu sync.RWMutex
+	backupMu  sync.Mutex
 
-	ingestionServer  *http.Server
+	session   *Session
+	sessionMu sync.RWMutex
+
+	ingestionServers []*http.Server
+	udpConn          net.PacketConn
+	tailStop         chan struct{}
 	ingestionMu      sync.Mutex
 	ingestionWg      sync.WaitGroup
 	ingestionRunning atomic.Bool
 
-	webServer     *http.Server
-	sseBroker     *SSEBroker
-	failureBroker *SSEBroker
-	logger        *SSELogger
-	discordQueue  *DiscordQueue
-	updater       *Updater
-	webAddr       string
+	webServer      *http.Server
+	sseBroker      *SSEBroker
+	failureBroker  *SSEBroker
+	chat           *chatFeed
+	logger         *SSELogger
+	discordQueue   *DiscordQueue
+	forwardQueue   *ForwardQueue
+	deliveries     deliveryLanes
+	repeats        repeatFilter
+	failover       discordFailover
+	offline        offlineBuffer
+	folderLocks    *folderLocks
+	updater        *Updater
+	rateLimiter    *rateLimiter
+	receipts       *receiptTable
+	preferences    *preferenceStore
+	tokens         *tokenStore
+	accessLog      *accessLog
+	requestMetrics *requestMetrics
+	started        time.Time
+	lastMessage    atomic.Int64 // UnixNano of the last message received
+	relayEchoes    *echoFilter
+	tunnelMu       sync.Mutex
+	tunnel         *tunnel
+	discoveryMu    sync.Mutex
+	discovery      *discovery
+	webAddr        string
+	done           chan struct{}
+	shutdownOnce   sync.Once
 }
 
 // NewApp creates a new App with the given config and web UI address.
@@ -44,40 +86,201 @@ func NewApp(config *AppConfig, webAddr string) *App {
 	broker := NewSSEBroker()
 	failureBroker := NewSSEBroker()
 	logger := NewSSELogger(broker, failureBroker)
-	logger.SetDebugMode(config.DebugMode)
-	discordQueue := NewDiscordQueue(logger)
+	setKnownSecrets(config)
+	discordCircuit.configure(config)
+	discordCircuit.setLogger(logger)
+	if err := outbound.configure(config); err != nil {
+		logger.Log("error", "HTTP client settings not applied: "+err.Error())
+	}
+	logger.SetLogLevel(config.LogLevel)
+	logger.SetHistoryLimits(config.LogHistorySize, config.FailureHistorySize)
+	if config.PersistLogHistory {
+		if err := logger.loadHistory(pendingPath(logHistoryFile)); err != nil {
+			slog.Error("Failed to restore the live log history", "err", err)
+		}
+	}
+	receipts := newReceiptTable(maxReceipts)
+	if config.PersistReceipts {
+		if err := receipts.load(pendingPath(receiptsFile)); err != nil {
+			slog.Error("Failed to restore delivery receipts", "err", err)
+		}
+	}
+	discordQueue := NewDiscordQueue(logger, receipts)
+	forwardQueue := NewForwardQueue(logger, receipts)
 	updater := NewUpdater(logger)
 
-	return &App{
-		config:        config,
-		sseBroker:     broker,
-		failureBroker: failureBroker,
-		logger:        logger,
-		discordQueue:  discordQueue,
-		updater:       updater,
-		webAddr:       webAddr,
+	app := &App{
+		config:         config,
+		sseBroker:      broker,
+		failureBroker:  failureBroker,
+		chat:           newChatFeed(),
+		logger:         logger,
+		discordQueue:   discordQueue,
+		forwardQueue:   forwardQueue,
+		folderLocks:    newFolderLocks(),
+		updater:        updater,
+		rateLimiter:    newRateLimiter(),
+		receipts:       receipts,
+		preferences:    newPreferenceStore(pendingPath(preferencesFile)),
+		tokens:         newTokenStore(pendingPath(tokensFile)),
+		accessLog:      &accessLog{},
+		requestMetrics: newRequestMetrics(),
+		started:        time.Now(),
+		relayEchoes:    newEchoFilter(),
+		webAddr:        webAddr,
+		done:           make(chan struct{}),
 	}
+	app.restoreDiscordPause()
+	app.restorePending()
+	go app.runDigestScheduler()
+	go app.runRetentionScheduler()
+	go app.runLogFlusher()
+	go app.runFolderLockRefresher()
+	go app.runBackupScheduler()
+	go app.runArchiveUploader()
+	go app.runRelay()
+	return app
 }
 
-// Shutdown gracefully shuts down both servers and the SSE broker.
+// shutdownDrainTimeout bounds how long Shutdown waits for queued messages
+// to be delivered before persisting the rest for the next start.
+const shutdownDrainTimeout = 10 * time.Second
+
+// Shutdown gracefully shuts down both servers, delivers or persists queued
+// messages, and stops the SSE brokers. It is safe to call more than once.
 func (a *App) Shutdown() {
+	a.shutdownOnce.Do(a.shutdown)
+}
+
+func (a *App) shutdown() {
+	// Stopping the ingestion server waits for in-flight handlers, and
+	// waiting for the deliveries they queued writes every accepted message
+	// to its log file, flushed below, and hands what Discord rate limits to
+	// its queue.
 	if a.ingestionRunning.Load() {
 		if err := a.StopIngestionServer(); err != nil {
-			log.Printf("Error stopping ingestion server: %v", err)
+			slog.Error("Error stopping ingestion server", "err", err)
 		}
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownDrainTimeout)
+	if err := a.deliveries.wait(ctx); err != nil {
+		slog.Error("Gave up waiting for message deliveries", "pending", a.deliveries.size(), "err", err)
+	}
+	cancel()
+	flushLogs()
+	a.unlockLogFolders()
+
+	close(a.done)
+	a.discordQueue.Stop()
+	a.forwardQueue.Stop()
+	a.drainQueues()
+	a.saveReceipts()
+	a.saveLogHistory()
+	a.accessLog.close()
 
 	if a.webServer != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := a.webServer.Shutdown(ctx); err != nil {
-			log.Printf("Error stopping web server: %v", err)
+			slog.Error("Error stopping web server", "err", err)
 		}
 	}
 
 	a.sseBroker.Stop()
 	a.failureBroker.Stop()
-	a.discordQueue.Stop()
+	a.chat.stop()
+}
+
+// drainQueues gives queued Discord and forward messages a last chance to be
+// delivered and saves whatever is left so it is retried on the next start.
+func (a *App) drainQueues() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownDrainTimeout)
+	defer cancel()
+
+	if n := a.discordQueue.QueueSize() + a.forwardQueue.QueueSize(); n > 0 {
+		slog.Info("Delivering queued messages before exit", "count", n)
+	}
+
+	var wg sync.WaitGroup
+	var discordLeft, forwardLeft []QueuedMessage
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		discordLeft = a.discordQueue.Drain(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		forwardLeft = a.forwardQueue.Drain(ctx)
+	}()
+	wg.Wait()
+
+	if err := savePendingMessages(pendingPath(pendingDiscordFile), discordLeft); err != nil {
+		slog.Error("Failed to save pending Discord messages", "err", err)
+	} else if len(discordLeft) > 0 {
+		slog.Info("Saved undelivered Discord messages for the next start", "count", len(discordLeft))
+	}
+	if err := savePendingMessages(pendingPath(pendingForwardFile), forwardLeft); err != nil {
+		slog.Error("Failed to save pending forward messages", "err", err)
+	} else if len(forwardLeft) > 0 {
+		slog.Info("Saved undelivered forward messages for the next start", "count", len(forwardLeft))
+	}
+}
+
+// saveReceipts persists the delivery receipt table when PersistReceipts
+// is set, so pending deliveries can still be checked after a restart.
+func (a *App) saveReceipts() {
+	a.configMu.RLock()
+	persist := a.config.PersistReceipts
+	a.configMu.RUnlock()
+	if !persist {
+		return
+	}
+	if err := a.receipts.save(pendingPath(receiptsFile)); err != nil {
+		slog.Error("Failed to save delivery receipts", "err", err)
+	}
+}
+
+// saveLogHistory persists the live log history when PersistLogHistory is
+// set, so the web UI still shows it after a restart.
+func (a *App) saveLogHistory() {
+	a.configMu.RLock()
+	persist := a.config.PersistLogHistory
+	a.configMu.RUnlock()
+	if !persist {
+		return
+	}
+	if err := a.logger.saveHistory(pendingPath(logHistoryFile)); err != nil {
+		slog.Error("Failed to save the live log history", "err", err)
+	}
+}
+
+// restorePending re-queues messages saved by a previous shutdown.
+func (a *App) restorePending() {
+	discord, err := loadPendingMessages(pendingPath(pendingDiscordFile))
+	if err != nil {
+		slog.Error("Failed to restore pending Discord messages", "err", err)
+	}
+	for _, msg := range discord {
+		a.discordQueue.Add(msg)
+	}
+	offline, err := loadPendingMessages(pendingPath(offlineDiscordFile))
+	if err != nil {
+		slog.Error("Failed to restore Discord messages buffered offline", "err", err)
+	}
+	a.discordQueue.AddBatch(offline)
+	discord = append(discord, offline...)
+
+	forward, err := loadPendingMessages(pendingPath(pendingForwardFile))
+	if err != nil {
+		slog.Error("Failed to restore pending forward messages", "err", err)
+	}
+	for _, msg := range forward {
+		a.forwardQueue.Add(msg)
+	}
+
+	if n := len(discord) + len(forward); n > 0 {
+		a.logger.Log("info", fmt.Sprintf("Restored %d message(s) queued before the last shutdown", n))
+	}
 }
 
 // openBrowser opens the specified URL in the default browser.
@@ -92,74 +295,224 @@ func openBrowser(url string) {
 		cmd = exec.Command("xdg-open", url)
 	}
 	if err := cmd.Start(); err != nil {
-		log.Printf("Failed to open browser: %v", err)
+		slog.Warn("Failed to open browser", "err", err)
 	}
 }
 
 func main() {
-	configPath := flag.String("config", "", "path to config file (default: ~/.config/rp-chat-logger/config.json)")
-	webAddr := flag.String("web-addr", defaultWebUIAddr, "web UI listen address")
+	configPath := flag.String("config", os.Getenv("RPCL_CONFIG"), "path to config file (default: ~/.config/rp-chat-logger/config.json) (env RPCL_CONFIG)")
+	profile := flag.String("profile", os.Getenv("RPCL_PROFILE"), "switch to this saved config profile before starting (env RPCL_PROFILE)")
+	webAddr := flag.String("web-addr", envOr("RPCL_WEB_ADDR", defaultWebUIAddr), "web UI listen address, or a comma-separated list tried in order (env RPCL_WEB_ADDR)")
+	webPortTries := flag.Int("web-port-tries", envIntOr("RPCL_WEB_PORT_TRIES", defaultWebPortTries), "how many following ports to try when the web UI port is taken (env RPCL_WEB_PORT_TRIES)")
+	noBrowser := flag.Bool("no-browser", parseEnvBool(os.Getenv("RPCL_NO_BROWSER")), "don't open the browser on startup (env RPCL_NO_BROWSER)")
+	headless := flag.Bool("headless", parseEnvBool(os.Getenv("RPCL_HEADLESS")), "run only the ingestion server, without the web UI (env RPCL_HEADLESS)")
+	tray := flag.Bool("tray", parseEnvBool(os.Getenv("RPCL_TRAY")), "run in the background with a system tray icon instead of opening the browser (Windows only) (env RPCL_TRAY)")
+	installSvc := flag.Bool("install-service", false, "install as a system service (Windows service or systemd unit) and exit")
+	uninstallSvc := flag.Bool("uninstall-service", false, "remove the installed system service and exit")
+	serviceMode := flag.Bool("service", false, "run under the service manager (set by --install-service)")
+	replayDate := flag.String("replay", "", "re-send the log file for this day (YYYY-MM-DD) to Discord and exit")
+	replayFrom := flag.String("replay-from", "", "with --replay, start at this time of day (HH:MM)")
+	replayTo := flag.String("replay-to", "", "with --replay, stop before this time of day (HH:MM)")
+	importFile := flag.String("import", "", "merge a txt, csv or json transcript into the log folder and exit")
+	importFmt := flag.String("import-format", "", "with --import, the transcript format if the file extension doesn't tell")
+	dockerCompose := flag.Bool("docker-compose", false, "print a docker-compose.yml for the current settings and exit")
+	dockerImage := flag.String("docker-image", defaultDockerImage, "with --docker-compose, the image to run")
+	var overrides ConfigOverrides
+	flag.StringVar(&overrides.ListenAddr, "listen", "", "ingestion server listen address (env RPCL_LISTEN_ADDR)")
+	flag.StringVar(&overrides.WebhookURL, "webhook", "", "Discord webhook URL; enables Discord notifications (env RPCL_WEBHOOK_URL)")
+	flag.StringVar(&overrides.Path, "path", "", "directory for log files; enables file logging (env RPCL_PATH)")
+	flag.StringVar(&overrides.FileFormat, "format", "", "log file format: txt, csv, json or docx (env RPCL_FORMAT)")
 	flag.Parse()
+	configOverrides = overrides
 
 	if *configPath != "" {
 		setConfigPath(*configPath)
 	}
 
-	config, err := loadConfiguration()
-	if err != nil {
-		log.Printf("Unable to load configuration: %v. Using default values.", err)
-		config = &AppConfig{
-			ListenAddr: defaultListenAddr,
-			FileFormat: "txt",
+	if *installSvc || *uninstallSvc {
+		action := installService
+		if *uninstallSvc {
+			action = uninstallService
+		}
+		if err := action(); err != nil {
+			log.Fatalf("Service setup failed: %v", err)
+		}
+		return
+	}
+
+	if *profile != "" {
+		if _, err := activateProfile(*profile); err != nil {
+			log.Fatalf("Switching profile failed: %v", err)
 		}
+		slog.Info("Switched config profile", "profile", *profile)
 	}
 
-	if config.ListenAddr == "" {
-		config.ListenAddr = defaultListenAddr
+	config, err := loadConfiguration(overrides)
+	fileLog, logFile, logErr := setupAppLog(appLogPath(config), config.AppLogFormat)
+	if logFile != nil {
+		defer logFile.Close()
 	}
-	if config.FileFormat == "" {
-		config.FileFormat = "txt"
+	if logErr != nil {
+		slog.Warn("App log file unavailable", "err", logErr)
+	}
+	if err != nil {
+		slog.Warn("Unable to load config file, using defaults and overrides only", "err", err)
 	}
 
-	log.Printf("Using config file: %s", getConfigPath())
+	if *dockerCompose {
+		compose, err := composeFile(config, getConfigPath(), *dockerImage, time.Now())
+		if err != nil {
+			log.Fatalf("Generating docker-compose.yml failed: %v", err)
+		}
+		fmt.Print(compose)
+		return
+	}
 
-	application := NewApp(config, *webAddr)
+	slog.Info("Using config file", "path", getConfigPath())
 
-	// Cleanup old binary from previous update (Windows)
-	CleanupOldBinary()
+	application := NewApp(config, *webAddr)
+	application.logger.SetFileLogger(fileLog)
+	go application.watchConfigFile(overrides)
 
-	// Check for updates in background
-	go func() {
-		if err := application.updater.CheckForUpdate(); err != nil {
-			log.Printf("Update check failed: %v", err)
+	if *importFile != "" {
+		result, err := runImport(application, *importFile, *importFmt)
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
 		}
-	}()
+		slog.Info("Import finished", "added", result.Added, "duplicates", result.Duplicates, "invalid", result.Invalid, "files", result.Files)
+		return
+	}
 
-	// Auto-start ingestion server if configured
-	if config.AutoStart {
-		if err := application.StartIngestionServer(); err != nil {
-			log.Printf("Auto-start failed: %v", err)
+	if *replayDate != "" {
+		if err := runReplay(application, *replayDate, *replayFrom, *replayTo); err != nil {
+			log.Fatalf("Replay failed: %v", err)
 		}
+		return
+	}
+
+	// Cleanup the binary a rollback replaced, and after an update check
+	// that the new version comes up (rolling back if it crashed before)
+	if application.updater.GetInfo().Container {
+		slog.Info("Running in a container, self-update is disabled. To update, " + containerUpdateHelp)
+	} else {
+		CleanupOldBinary()
+		application.updater.BeginTrial(time.Now())
+	}
+
+	// Check for updates in background, now and periodically
+	go application.runUpdateScheduler()
+
+	if *serviceMode {
+		runAsService(func(ctx context.Context) {
+			if err := runHeadless(ctx, func() {}, application); err != nil {
+				log.Fatal(err)
+			}
+		})
+		return
 	}
 
 	// Graceful shutdown on SIGINT/SIGTERM
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// Start web UI server in a goroutine
+	if *headless {
+		if err := runHeadless(ctx, stop, application); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Auto-start ingestion server if configured
+	if config.AutoStart {
+		if err := application.StartIngestionServer(); err != nil {
+			slog.Error("Auto-start failed", "err", err)
+		}
+	}
+
+	// Bind the web UI first, so the browser and tray get the port chosen.
+	webListener, err := application.listenWebUI(webUIAddrs(*webAddr, *webPortTries))
+	if err != nil {
+		application.updater.FailTrial(err)
+		log.Fatalf("Web UI server failed: %v", err)
+	}
+	go application.superviseUpdate(config.AutoStart)
 	go func() {
-		if err := application.StartWebUI(); err != nil && err != http.ErrServerClosed {
+		if err := application.StartWebUI(webListener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Web UI server failed: %v", err)
 		}
 	}()
 
-	// Open browser after a short delay to ensure server is ready
-	go func() {
-		time.Sleep(500 * time.Millisecond)
-		openBrowser("http://localhost:8080")
-	}()
+	// Even in the tray, a first run opens the setup wizard.
+	setupOpened := *tray && !*noBrowser && application.needsSetup()
+	if setupOpened {
+		openBrowser(webUIURL(application.webAddr) + "setup")
+	}
+
+	// In tray mode, run until Quit is chosen from the tray menu.
+	inTray := false
+	if *tray {
+		if err := runTray(ctx, application); err != nil {
+			slog.Warn("Tray unavailable, opening the browser instead", "err", err)
+		} else {
+			inTray = true
+		}
+	}
+
+	if !inTray {
+		// The listener is already bound, so the page loads right away.
+		if !*noBrowser && !setupOpened {
+			openBrowser(webUIURL(application.webAddr))
+		}
+		<-ctx.Done()
+	}
+	// Restore default signal handling so a second Ctrl-C exits immediately.
+	stop()
+	slog.Info("Shutting down... (press Ctrl-C again to force)")
+	application.Shutdown()
+}
+
+// envOr returns the value of the environment variable, or fallback if unset.
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envIntOr returns the environment variable as an integer, or fallback if
+// unset or not a number.
+func envIntOr(name string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return n
+	}
+	return fallback
+}
+
+// runHeadless runs only the ingestion server until ctx is cancelled, then
+// shuts the app down. There is no web UI, so application logs are echoed to
+// stderr instead. It returns an error, and leaves the app running, when the
+// configuration is invalid or the server doesn't start.
+func runHeadless(ctx context.Context, stop context.CancelFunc, application *App) error {
+	application.logger.SetEcho(true)
+
+	application.configMu.RLock()
+	cfg := *application.config
+	application.configMu.RUnlock()
+
+	if err := cfg.validate(); err != nil {
+		application.updater.FailTrial(err)
+		return fmt.Errorf("Invalid configuration for headless mode: %w", err)
+	}
+	if err := application.StartIngestionServer(); err != nil {
+		application.updater.FailTrial(err)
+		return fmt.Errorf("Failed to start ingestion server: %w", err)
+	}
+	go application.superviseUpdate(true)
 
 	<-ctx.Done()
-	log.Println("Shutting down...")
+	// Restore default signal handling so a second Ctrl-C exits immediately.
+	stop()
+	slog.Info("Shutting down... (press Ctrl-C again to force)")
 	application.Shutdown()
+	return nil
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvOr(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantInt int
+	}{
+		{"", "127.0.0.1:8080", 5},
+		{"0.0.0.0:9000", "0.0.0.0:9000", 5},
+		{"12", "12", 12},
+	}
+	for _, tt := range tests {
+		t.Setenv("RPCL_TEST_VALUE", tt.value)
+		if got := envOr("RPCL_TEST_VALUE", "127.0.0.1:8080"); got != tt.want {
+			t.Errorf("envOr with %q = %q, want %q", tt.value, got, tt.want)
+		}
+		if got := envIntOr("RPCL_TEST_VALUE", 5); got != tt.wantInt {
+			t.Errorf("envIntOr with %q = %d, want %d", tt.value, got, tt.wantInt)
+		}
+	}
+}
+
+// freeAddr returns a loopback address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestRunHeadless(t *testing.T) {
+	oldConfigPath := configPathOverride
+	configPathOverride = filepath.Join(t.TempDir(), "config.json")
+	defer func() { configPathOverride = oldConfigPath }()
+
+	invalid := NewApp(&AppConfig{ListenAddr: freeAddr(t), FileFormat: "txt", EnableDiscord: true}, "")
+	if err := runHeadless(context.Background(), func() {}, invalid); err == nil || !strings.Contains(err.Error(), "Invalid configuration") {
+		t.Errorf("invalid config: got %v", err)
+	}
+	invalid.Shutdown()
+
+	dir := t.TempDir()
+	addr := freeAddr(t)
+	a := NewApp(&AppConfig{ListenAddr: addr, FileFormat: "txt", EnableLocalSave: true, Path: dir}, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runHeadless(ctx, func() {}, a) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get("http://" + addr + "/message?sender=Conan&message=Headless+hello")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ingestion server not up: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("runHeadless: %v", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("runHeadless didn't return after cancelling")
+	}
+	if data, err := os.ReadFile(generateLogFilename(dir, "txt")); err != nil || !strings.Contains(string(data), "Conan: Headless hello") {
+		t.Errorf("message not logged before shutdown: %q, %v", data, err)
+	}
+	if a.ingestionRunning.Load() {
+		t.Error("ingestion server still running after shutdown")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Markdown as found in GitHub release notes, rendered by renderMarkdown.
+var (
+	mdHeading   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdListItem  = regexp.MustCompile(`^\s*(?:[-*+]|(\d+)[.)])\s+(.*)$`)
+	mdRule      = regexp.MustCompile(`^\s*(?:-\s*){3,}$|^\s*(?:\*\s*){3,}$|^\s*(?:_\s*){3,}$`)
+	mdBold      = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	mdItalic    = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`)
+	mdStrike    = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	mdLinkOrURL = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^\s)]+)\)|https?://[^\s<>"]+`)
+)
+
+// renderMarkdown converts the Markdown of a release body to HTML: headings,
+// paragraphs, lists, block quotes, code blocks, rules, code spans, bold,
+// italics, strikethrough, links and bare URLs. Everything else shows as
+// text. All text is escaped and links go to http(s) URLs only, so the
+// result is safe to embed. Headings start at <h3>, below the page's own.
+func renderMarkdown(src string) template.HTML {
+	var b strings.Builder
+	var para []string
+	list := "" // "ul" or "ol" while in a list
+	inQuote := false
+
+	flushPara := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + renderInline(strings.Join(para, " ")) + "</p>\n")
+			para = nil
+		}
+	}
+	closeBlocks := func() {
+		flushPara()
+		if list != "" {
+			b.WriteString("</" + list + ">\n")
+			list = ""
+		}
+		if inQuote {
+			b.WriteString("</blockquote>\n")
+			inQuote = false
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if fence, ok := codeFence(trimmed); ok {
+			closeBlocks()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString("<pre><code>" + template.HTMLEscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+			continue
+		}
+
+		// Quoted lines are rendered as paragraphs within the quote.
+		quoted := strings.HasPrefix(trimmed, ">")
+		if quoted != inQuote {
+			closeBlocks()
+			if quoted {
+				b.WriteString("<blockquote>\n")
+				inQuote = true
+			}
+		}
+		if quoted {
+			trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+		}
+
+		switch {
+		case trimmed == "":
+			flushPara()
+			if list != "" {
+				b.WriteString("</" + list + ">\n")
+				list = ""
+			}
+		case mdRule.MatchString(trimmed) && !quoted:
+			closeBlocks()
+			b.WriteString("<hr>\n")
+		case mdHeading.MatchString(trimmed):
+			flushPara()
+			m := mdHeading.FindStringSubmatch(trimmed)
+			tag := "h" + strconv.Itoa(min(len(m[1])+2, 6))
+			b.WriteString("<" + tag + ">" + renderInline(m[2]) + "</" + tag + ">\n")
+		case mdListItem.MatchString(trimmed):
+			flushPara()
+			m := mdListItem.FindStringSubmatch(trimmed)
+			kind := "ul"
+			if m[1] != "" {
+				kind = "ol"
+			}
+			if list != kind {
+				if list != "" {
+					b.WriteString("</" + list + ">\n")
+				}
+				b.WriteString("<" + kind + ">\n")
+				list = kind
+			}
+			b.WriteString("<li>" + renderInline(m[2]) + "</li>\n")
+		case list != "" && len(line) > len(strings.TrimLeft(line, " \t")):
+			// An indented line continues the list item before it.
+			out := strings.TrimSuffix(b.String(), "</li>\n")
+			b.Reset()
+			b.WriteString(out + " " + renderInline(trimmed) + "</li>\n")
+		default:
+			if list != "" {
+				b.WriteString("</" + list + ">\n")
+				list = ""
+			}
+			para = append(para, trimmed)
+		}
+	}
+	closeBlocks()
+	return template.HTML(b.String())
+}
+
+// codeFence reports whether line opens a fenced code block and returns the
+// fence that closes it.
+func codeFence(line string) (string, bool) {
+	for _, fence := range []string{"```", "~~~"} {
+		if strings.HasPrefix(line, fence) {
+			return fence, true
+		}
+	}
+	return "", false
+}
+
+// renderInline renders the inline Markdown of one block of text.
+func renderInline(text string) string {
+	// Code spans are taken literally; only the text between them is
+	// formatted.
+	parts := strings.Split(text, "`")
+	var b strings.Builder
+	for i, part := range parts {
+		if i%2 == 1 && i < len(parts)-1 {
+			b.WriteString("<code>" + template.HTMLEscapeString(part) + "</code>")
+			continue
+		}
+		if i%2 == 1 {
+			// Unmatched backtick
+			b.WriteString("`")
+		}
+		b.WriteString(renderLinks(part))
+	}
+	return b.String()
+}
+
+// renderLinks renders links and bare URLs, and the emphasis in the text
+// around them.
+func renderLinks(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range mdLinkOrURL.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(renderEmphasis(s[last:loc[0]]))
+		last = loc[1]
+		if loc[2] >= 0 {
+			text, url := s[loc[2]:loc[3]], s[loc[4]:loc[5]]
+			b.WriteString(mdLink(url, renderEmphasis(text)))
+			continue
+		}
+		// Bare URL: trailing punctuation belongs to the sentence.
+		url := strings.TrimRight(s[loc[0]:loc[1]], ".,;:!?)")
+		b.WriteString(mdLink(url, template.HTMLEscapeString(url)))
+		last = loc[0] + len(url)
+	}
+	b.WriteString(renderEmphasis(s[last:]))
+	return b.String()
+}
+
+func mdLink(url, html string) string {
+	return `<a href="` + template.HTMLEscapeString(url) + `" target="_blank" rel="noopener noreferrer">` + html + `</a>`
+}
+
+// renderEmphasis escapes text and renders bold, italics and strikethrough.
+func renderEmphasis(s string) string {
+	s = template.HTMLEscapeString(s)
+	s = mdBold.ReplaceAllStringFunc(s, func(m string) string {
+		return "<strong>" + m[2:len(m)-2] + "</strong>"
+	})
+	s = mdItalic.ReplaceAllString(s, "<em>$1</em>")
+	return mdStrike.ReplaceAllString(s, "<del>$1</del>")
+}
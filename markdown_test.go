@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"heading", "## What's Changed", "<h4>What&#39;s Changed</h4>\n"},
+		{"deep heading", "##### Minor", "<h6>Minor</h6>\n"},
+		{"paragraph", "First line\r\nsecond line\r\n\r\nNext", "<p>First line second line</p>\n<p>Next</p>\n"},
+		{"bullets", "* one\n- two", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"},
+		{"numbered", "1. one\n2) two", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+		{"list continuation", "- one\n  more\n- two", "<ul>\n<li>one more</li>\n<li>two</li>\n</ul>\n"},
+		{"list then paragraph", "- one\n\nafter", "<ul>\n<li>one</li>\n</ul>\n<p>after</p>\n"},
+		{"rule", "---", "<hr>\n"},
+		{"quote", "> careful\n> now\n\nok", "<blockquote>\n<p>careful now</p>\n</blockquote>\n<p>ok</p>\n"},
+		{"code block", "```go\nif a < b {\n```\nafter", "<pre><code>if a &lt; b {</code></pre>\n<p>after</p>\n"},
+		{"unterminated code block", "~~~\n# not a heading", "<pre><code># not a heading</code></pre>\n"},
+		{"emphasis", "**bold** *it* __also__ ~~gone~~", "<p><strong>bold</strong> <em>it</em> <strong>also</strong> <del>gone</del></p>\n"},
+		{"spaced asterisks", "a * b * c", "<p>a * b * c</p>\n"},
+		{"code span", "run `rm *.log` **now**", "<p>run <code>rm *.log</code> <strong>now</strong></p>\n"},
+		{"unmatched backtick", "a ` b", "<p>a ` b</p>\n"},
+		{"link", "[the **docs**](https://example.com/a?b=1&c=2)", `<p><a href="https://example.com/a?b=1&amp;c=2" target="_blank" rel="noopener noreferrer">the <strong>docs</strong></a></p>` + "\n"},
+		{"bare URL", "See https://github.com/x/y/pull/12.", `<p>See <a href="https://github.com/x/y/pull/12" target="_blank" rel="noopener noreferrer">https://github.com/x/y/pull/12</a>.</p>` + "\n"},
+		{"URL with asterisks", "https://example.com/*a*", `<p><a href="https://example.com/*a*" target="_blank" rel="noopener noreferrer">https://example.com/*a*</a></p>` + "\n"},
+		{"script link", "[x](javascript:alert(1))", "<p>[x](javascript:alert(1))</p>\n"},
+		{"html", `<img src=x onerror="alert(1)">`, "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(renderMarkdown(tt.in)); got != tt.want {
+				t.Errorf("renderMarkdown(%q) =\n%q\nwant\n%q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownEscapesLinks(t *testing.T) {
+	got := string(renderMarkdown(`[a](https://x.com/"onmouseover=alert(1))`))
+	if strings.Contains(got, `"onmouseover`) {
+		t.Errorf("quote in URL not escaped: %s", got)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		512:      "512 bytes",
+		2048:     "2.0 KB",
+		12 << 20: "12.0 MB",
+	}
+	for n, want := range tests {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
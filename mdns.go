@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mDNS constants (RFC 6762 and RFC 6763).
+const (
+	mdnsService         = "_rp-chat-logger._tcp.local"
+	mdnsServiceList     = "_services._dns-sd._udp.local"
+	defaultMDNSHostname = "rp-chat-logger"
+	mdnsTTL             = 120
+
+	dnsTypeA   = 1
+	dnsTypePTR = 12
+	dnsTypeTXT = 16
+	dnsTypeSRV = 33
+	dnsTypeANY = 255
+
+	dnsClassIN    = 1
+	dnsCacheFlush = 0x8000
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// dnsRecord is a resource record in an mDNS response.
+type dnsRecord struct {
+	name   string
+	typ    uint16
+	unique bool // sets the cache-flush bit; shared PTR records don't
+	ttl    uint32
+	data   []byte
+}
+
+// mdnsResponder answers mDNS queries for the ingestion server, so LAN
+// players can reach it as <hostname>.local and find it by browsing for
+// the _rp-chat-logger._tcp service.
+type mdnsResponder struct {
+	conn     *net.UDPConn
+	host     string // e.g. "rp-chat-logger.local"
+	instance string // e.g. "RP Chat Logger on PC._rp-chat-logger._tcp.local"
+	port     int
+	done     chan struct{}
+}
+
+// mdnsHostname returns the configured .local host name without the domain.
+func mdnsHostname(config *AppConfig) string {
+	if config.MDNSHostname == "" {
+		return defaultMDNSHostname
+	}
+	return strings.TrimSuffix(config.MDNSHostname, ".local")
+}
+
+// validMDNSHostname reports whether name is a single DNS label.
+func validMDNSHostname(name string) bool {
+	if name == "" || len(name) > 63 || name[0] == '-' || name[len(name)-1] == '-' {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// startMDNS starts answering mDNS queries for the ingestion server on port.
+func startMDNS(config *AppConfig, port int) (*mdnsResponder, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("joining mDNS group: %w", err)
+	}
+	machine, _ := os.Hostname()
+	if machine == "" {
+		machine = "unknown host"
+	}
+	r := &mdnsResponder{
+		conn:     conn,
+		host:     mdnsHostname(config) + ".local",
+		instance: "RP Chat Logger on " + strings.ReplaceAll(machine, ".", "-") + "." + mdnsService,
+		port:     port,
+		done:     make(chan struct{}),
+	}
+	go r.serve()
+	go func() {
+		// Announce twice, a second apart, as RFC 6762 section 8.3 asks.
+		for i := 0; i < 2; i++ {
+			r.send(r.records(mdnsTTL), mdnsGroup)
+			select {
+			case <-r.done:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+	return r, nil
+}
+
+// stop sends a goodbye so caches forget the records, then stops answering.
+func (r *mdnsResponder) stop() {
+	r.send(r.records(0), mdnsGroup)
+	close(r.done)
+	r.conn.Close()
+}
+
+func (r *mdnsResponder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-r.done:
+				return
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		questions, err := parseDNSQuestions(buf[:n])
+		if err != nil || len(questions) == 0 {
+			continue
+		}
+		answers := r.answer(questions)
+		if len(answers) == 0 {
+			continue
+		}
+		r.send(answers, mdnsGroup)
+		if src.Port != mdnsGroup.Port {
+			// One-shot resolvers listen on their own port for the reply.
+			r.send(answers, src)
+		}
+	}
+}
+
+func (r *mdnsResponder) send(records []dnsRecord, to *net.UDPAddr) {
+	if _, err := r.conn.WriteToUDP(buildDNSResponse(records), to); err != nil {
+		slog.Debug("mDNS send failed", "err", err)
+	}
+}
+
+// records returns every record the responder publishes.
+func (r *mdnsResponder) records(ttl uint32) []dnsRecord {
+	var srv []byte
+	srv = binary.BigEndian.AppendUint16(srv, 0) // priority
+	srv = binary.BigEndian.AppendUint16(srv, 0) // weight
+	srv = binary.BigEndian.AppendUint16(srv, uint16(r.port))
+	srv = appendDNSName(srv, r.host)
+
+	records := []dnsRecord{
+		{name: mdnsServiceList, typ: dnsTypePTR, ttl: ttl, data: appendDNSName(nil, mdnsService)},
+		{name: mdnsService, typ: dnsTypePTR, ttl: ttl, data: appendDNSName(nil, r.instance)},
+		{name: r.instance, typ: dnsTypeSRV, unique: true, ttl: ttl, data: srv},
+		{name: r.instance, typ: dnsTypeTXT, unique: true, ttl: ttl, data: appendTXT(nil, "path=/message")},
+	}
+	for _, ip := range lanIPv4s() {
+		records = append(records, dnsRecord{name: r.host, typ: dnsTypeA, unique: true, ttl: ttl, data: ip.To4()})
+	}
+	return records
+}
+
+// answer returns the records that answer questions.
+func (r *mdnsResponder) answer(questions []dnsQuestion) []dnsRecord {
+	all := r.records(mdnsTTL)
+	var answers []dnsRecord
+	seen := make(map[int]bool)
+	for _, q := range questions {
+		for i, rec := range all {
+			if seen[i] || !strings.EqualFold(q.name, rec.name) || (q.typ != rec.typ && q.typ != dnsTypeANY) {
+				continue
+			}
+			seen[i] = true
+			answers = append(answers, rec)
+		}
+	}
+	if len(answers) == 0 {
+		return nil
+	}
+	// Include the instance and address records with PTR answers so
+	// browsers don't have to ask again.
+	for i, rec := range all {
+		if !seen[i] && rec.typ != dnsTypePTR {
+			answers = append(answers, rec)
+		}
+	}
+	return answers
+}
+
+// lanIPv4s returns the IPv4 addresses of the machine's up, non-loopback
+// interfaces.
+func lanIPv4s() []net.IP {
+	var ips []net.IP
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil && !ipnet.IP.IsLinkLocalUnicast() {
+				ips = append(ips, ipnet.IP.To4())
+			}
+		}
+	}
+	return ips
+}
+
+// dnsQuestion is one entry of a query's question section.
+type dnsQuestion struct {
+	name string
+	typ  uint16
+}
+
+// parseDNSQuestions returns the questions of a DNS query. Responses are
+// ignored and return no questions.
+func parseDNSQuestions(msg []byte) ([]dnsQuestion, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("short DNS message")
+	}
+	if msg[2]&0x80 != 0 {
+		return nil, nil
+	}
+	count := int(binary.BigEndian.Uint16(msg[4:6]))
+	off := 12
+	var questions []dnsQuestion
+	for i := 0; i < count; i++ {
+		name, next, err := readDNSName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(msg) {
+			return nil, fmt.Errorf("short DNS question")
+		}
+		questions = append(questions, dnsQuestion{name: name, typ: binary.BigEndian.Uint16(msg[next : next+2])})
+		off = next + 4
+	}
+	return questions, nil
+}
+
+// readDNSName reads a possibly compressed name at off and returns it with
+// the offset just past it.
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, fmt.Errorf("DNS name out of range")
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(msg) || jumps > 10 {
+				return "", 0, fmt.Errorf("bad DNS name pointer")
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:off+2]) & 0x3FFF)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, fmt.Errorf("DNS label out of range")
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// buildDNSResponse encodes an authoritative response carrying records.
+func buildDNSResponse(records []dnsRecord) []byte {
+	msg := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(msg[2:4], 0x8400) // response, authoritative
+	binary.BigEndian.PutUint16(msg[6:8], uint16(len(records)))
+	for _, rec := range records {
+		msg = appendDNSName(msg, rec.name)
+		class := uint16(dnsClassIN)
+		if rec.unique {
+			class |= dnsCacheFlush
+		}
+		msg = binary.BigEndian.AppendUint16(msg, rec.typ)
+		msg = binary.BigEndian.AppendUint16(msg, class)
+		msg = binary.BigEndian.AppendUint32(msg, rec.ttl)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(rec.data)))
+		msg = append(msg, rec.data...)
+	}
+	return msg
+}
+
+// appendDNSName appends name as uncompressed labels.
+func appendDNSName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) > 63 {
+			label = label[:63]
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// appendTXT appends TXT record strings.
+func appendTXT(b []byte, values ...string) []byte {
+	for _, v := range values {
+		b = append(b, byte(len(v)))
+		b = append(b, v...)
+	}
+	return b
+}
+
+// listenPort returns the port of a listen address.
+func listenPort(addr string) (int, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	return strconv.Atoi(port)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// dnsQuery encodes a query with one question per name, compressing the
+// second name's suffix against the first as real resolvers do.
+func dnsQuery(typ uint16, names ...string) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[4:6], uint16(len(names)))
+	for _, name := range names {
+		msg = appendDNSName(msg, name)
+		msg = binary.BigEndian.AppendUint16(msg, typ)
+		msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+	}
+	return msg
+}
+
+func TestParseDNSQuestions(t *testing.T) {
+	compressed := make([]byte, 12)
+	binary.BigEndian.PutUint16(compressed[4:6], 2)
+	compressed = appendDNSName(compressed, "rp-chat-logger.local")
+	compressed = binary.BigEndian.AppendUint16(compressed, dnsTypeA)
+	compressed = binary.BigEndian.AppendUint16(compressed, dnsClassIN)
+	compressed = append(compressed, 3, 'w', 'w', 'w', 0xC0, 12) // www.<first name>
+	compressed = binary.BigEndian.AppendUint16(compressed, dnsTypeANY)
+	compressed = binary.BigEndian.AppendUint16(compressed, dnsClassIN)
+
+	response := buildDNSResponse(nil)
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		want    []dnsQuestion
+		wantErr bool
+	}{
+		{
+			name: "single question",
+			msg:  dnsQuery(dnsTypePTR, mdnsService),
+			want: []dnsQuestion{{name: mdnsService, typ: dnsTypePTR}},
+		},
+		{
+			name: "compressed name",
+			msg:  compressed,
+			want: []dnsQuestion{{name: "rp-chat-logger.local", typ: dnsTypeA}, {name: "www.rp-chat-logger.local", typ: dnsTypeANY}},
+		},
+		{
+			name: "response ignored",
+			msg:  response,
+		},
+		{
+			name:    "truncated",
+			msg:     dnsQuery(dnsTypeA, "rp-chat-logger.local")[:20],
+			wantErr: true,
+		},
+		{
+			name:    "pointer loop",
+			msg:     append(dnsQuery(dnsTypeA)[:4], 0, 1, 0, 0, 0, 0, 0, 0, 0xC0, 12),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDNSQuestions(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("question %d: expected %v, got %v", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMDNSAnswer(t *testing.T) {
+	r := &mdnsResponder{
+		host:     "rp-chat-logger.local",
+		instance: "RP Chat Logger on pc." + mdnsService,
+		port:     3000,
+	}
+
+	tests := []struct {
+		name      string
+		questions []dnsQuestion
+		wantFirst uint16
+		wantNone  bool
+	}{
+		{"browse", []dnsQuestion{{name: mdnsService, typ: dnsTypePTR}}, dnsTypePTR, false},
+		{"service list", []dnsQuestion{{name: mdnsServiceList, typ: dnsTypePTR}}, dnsTypePTR, false},
+		{"instance", []dnsQuestion{{name: "rp chat logger on PC." + mdnsService, typ: dnsTypeSRV}}, dnsTypeSRV, false},
+		{"other host", []dnsQuestion{{name: "printer.local", typ: dnsTypeA}}, 0, true},
+		{"wrong type", []dnsQuestion{{name: r.instance, typ: dnsTypeA}}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answers := r.answer(tt.questions)
+			if tt.wantNone {
+				if len(answers) != 0 {
+					t.Errorf("expected no answers, got %d", len(answers))
+				}
+				return
+			}
+			if len(answers) == 0 || answers[0].typ != tt.wantFirst {
+				t.Fatalf("expected a type %d answer first, got %v", tt.wantFirst, answers)
+			}
+			var srv *dnsRecord
+			for i := range answers {
+				if answers[i].typ == dnsTypeSRV {
+					srv = &answers[i]
+				}
+			}
+			if srv == nil {
+				t.Fatal("expected the SRV record as an answer or additional record")
+			}
+			if port := binary.BigEndian.Uint16(srv.data[4:6]); port != 3000 {
+				t.Errorf("expected port 3000 in SRV, got %d", port)
+			}
+			if host, _, err := readDNSName(srv.data, 6); err != nil || host != "rp-chat-logger.local" {
+				t.Errorf("expected SRV target rp-chat-logger.local, got %q (%v)", host, err)
+			}
+		})
+	}
+}
+
+func TestBuildDNSResponse(t *testing.T) {
+	msg := buildDNSResponse([]dnsRecord{
+		{name: "rp-chat-logger.local", typ: dnsTypeA, unique: true, ttl: mdnsTTL, data: []byte{192, 168, 1, 20}},
+	})
+	if flags := binary.BigEndian.Uint16(msg[2:4]); flags != 0x8400 {
+		t.Errorf("expected authoritative response flags, got %#x", flags)
+	}
+	if count := binary.BigEndian.Uint16(msg[6:8]); count != 1 {
+		t.Fatalf("expected 1 answer, got %d", count)
+	}
+	name, off, err := readDNSName(msg, 12)
+	if err != nil || name != "rp-chat-logger.local" {
+		t.Fatalf("expected the record name, got %q (%v)", name, err)
+	}
+	if class := binary.BigEndian.Uint16(msg[off+2 : off+4]); class != dnsClassIN|dnsCacheFlush {
+		t.Errorf("expected cache-flush class, got %#x", class)
+	}
+	if ttl := binary.BigEndian.Uint32(msg[off+4 : off+8]); ttl != mdnsTTL {
+		t.Errorf("expected TTL %d, got %d", mdnsTTL, ttl)
+	}
+	if ip := msg[off+10:]; len(ip) != 4 || ip[3] != 20 {
+		t.Errorf("unexpected address data %v", ip)
+	}
+}
+
+func TestValidate_Discovery(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AppConfig
+		wantErr string
+	}{
+		{"all interfaces", AppConfig{ListenAddr: "0.0.0.0:3000", EnableMDNS: true, EnableUPnP: true}, ""},
+		{"LAN address", AppConfig{ListenAddr: "192.168.1.20:3000", EnableUPnP: true}, ""},
+		{"custom hostname", AppConfig{ListenAddr: ":3000", EnableMDNS: true, MDNSHostname: "conan-logs.local"}, ""},
+		{"localhost", AppConfig{ListenAddr: "localhost:3000", EnableMDNS: true}, "LAN discovery needs a listen address other than localhost, e.g. 0.0.0.0:3000"},
+		{"loopback", AppConfig{ListenAddr: "127.0.0.1:3000", EnableUPnP: true}, "LAN discovery needs a listen address other than localhost, e.g. 0.0.0.0:3000"},
+		{"bad hostname", AppConfig{ListenAddr: ":3000", EnableMDNS: true, MDNSHostname: "my logger"}, "mDNS hostname may only contain letters, digits and hyphens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.EnableLocalSave, cfg.Path = true, "logs"
+			err := cfg.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
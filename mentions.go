@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Which mentions typed by players may ping in Discord. Pinging
+// @everyone, @here or a role takes an alert rule.
+const (
+	discordMentionsNone  = "none"
+	discordMentionsUsers = "users"
+)
+
+// allowedMentions is the allowed_mentions field of a Discord post, which
+// decides who a post may ping. Parse lists the kinds of mentions ("users",
+// "roles", "everyone") that ping whoever they name; Roles and Users list
+// the IDs that ping besides. All empty means nothing does.
+type allowedMentions struct {
+	Parse []string `json:"parse"`
+	Roles []string `json:"roles,omitempty"`
+	Users []string `json:"users,omitempty"`
+}
+
+// discordMentions returns the mentions relayed posts may make: user
+// mentions when DiscordMentions allows them, otherwise none.
+func discordMentions(cfg *AppConfig) *allowedMentions {
+	if cfg.DiscordMentions == discordMentionsUsers {
+		return &allowedMentions{Parse: []string{"users"}}
+	}
+	return &allowedMentions{Parse: []string{}}
+}
+
+// mentionTarget matches what an alert rule pings: @everyone, @here, a role
+// (<@&id>) or a user (<@id>), as Discord writes them.
+var mentionTarget = regexp.MustCompile(`^(?:@everyone|@here|<@&(\d+)>|<@!?(\d+)>)$`)
+
+// checkMentionAlerts reports the first alert rule whose mention isn't one
+// mentionTarget accepts.
+func checkMentionAlerts(alerts map[string]string) error {
+	for keyword, target := range alerts {
+		if !mentionTarget.MatchString(target) {
+			return fmt.Errorf("Alert %q must mention @everyone, @here, a role (<@&id>) or a user (<@id>), not %q", keyword, target)
+		}
+	}
+	return nil
+}
+
+// mentionAlerts returns the mentions of the alert rules whose keyword
+// appears in message as a whole word, ignoring case, in keyword order.
+func mentionAlerts(cfg *AppConfig, message string) []string {
+	keywords := make([]string, 0, len(cfg.MentionAlerts))
+	for keyword := range cfg.MentionAlerts {
+		keywords = append(keywords, keyword)
+	}
+	slices.Sort(keywords)
+
+	var targets []string
+	for _, keyword := range keywords {
+		re, err := regexp.Compile(`(?i)(?:^|\W)` + regexp.QuoteMeta(keyword) + `(?:\W|$)`)
+		if err != nil || !re.MatchString(message) {
+			continue
+		}
+		if target := cfg.MentionAlerts[keyword]; !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// withAlerts returns content with the alert mentions appended and the
+// mentions allowed extended to exactly those, leaving base unchanged.
+func withAlerts(content string, base *allowedMentions, targets []string) (string, *allowedMentions) {
+	if len(targets) == 0 {
+		return content, base
+	}
+	m := &allowedMentions{
+		Parse: slices.Clone(base.Parse),
+		Roles: slices.Clone(base.Roles),
+		Users: slices.Clone(base.Users),
+	}
+	for _, target := range targets {
+		sub := mentionTarget.FindStringSubmatch(target)
+		switch {
+		case sub == nil:
+			continue
+		case sub[1] != "":
+			if !slices.Contains(m.Roles, sub[1]) {
+				m.Roles = append(m.Roles, sub[1])
+			}
+		case sub[2] != "":
+			// Discord rejects a users list alongside parsing users.
+			if !slices.Contains(m.Parse, "users") && !slices.Contains(m.Users, sub[2]) {
+				m.Users = append(m.Users, sub[2])
+			}
+		default:
+			// "everyone" covers both @everyone and @here, so the ones
+			// in the message itself are defused.
+			if !slices.Contains(m.Parse, "everyone") {
+				m.Parse = append(m.Parse, "everyone")
+				content = everyoneMention.ReplaceAllString(content, "@\u200b$1")
+			}
+		}
+	}
+	return content + "\n" + strings.Join(targets, " "), m
+}
+
+// everyoneMention matches @everyone and @here, which a zero-width space
+// after the @ keeps from pinging.
+var everyoneMention = regexp.MustCompile(`@(everyone|here)`)
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiscordMentions(t *testing.T) {
+	tests := []struct {
+		setting string
+		want    []string
+	}{
+		{"", []string{}},
+		{discordMentionsNone, []string{}},
+		{discordMentionsUsers, []string{"users"}},
+	}
+	for _, tt := range tests {
+		got := discordMentions(&AppConfig{DiscordMentions: tt.setting})
+		if !reflect.DeepEqual(got, &allowedMentions{Parse: tt.want}) {
+			t.Errorf("discordMentions(%q) = %+v, want parse %v", tt.setting, got, tt.want)
+		}
+	}
+}
+
+func TestCheckMentionAlerts(t *testing.T) {
+	tests := []struct {
+		target string
+		ok     bool
+	}{
+		{"@everyone", true},
+		{"@here", true},
+		{"<@&123456789012345678>", true},
+		{"<@123456789012345678>", true},
+		{"<@!123456789012345678>", true},
+		{"@moderators", false},
+		{"123456789012345678", false},
+		{"<@&123> and more", false},
+	}
+	for _, tt := range tests {
+		err := checkMentionAlerts(map[string]string{"raid": tt.target})
+		if (err == nil) != tt.ok {
+			t.Errorf("checkMentionAlerts(%q) = %v, want ok %v", tt.target, err, tt.ok)
+		}
+	}
+}
+
+func TestMentionAlerts(t *testing.T) {
+	cfg := &AppConfig{MentionAlerts: map[string]string{
+		"raid":      "<@&1>",
+		"help":      "@here",
+		"gm needed": "<@2>",
+		"attack":    "<@&1>",
+	}}
+	tests := []struct {
+		message string
+		want    []string
+	}{
+		{"all quiet", nil},
+		{"RAID at the gate!", []string{"<@&1>"}},
+		{"raiders spotted", nil},
+		{"raid, attack, help", []string{"<@&1>", "@here"}},
+		{"is a GM needed here?", []string{"<@2>"}},
+	}
+	for _, tt := range tests {
+		if got := mentionAlerts(cfg, tt.message); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mentionAlerts(%q) = %v, want %v", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestWithAlerts(t *testing.T) {
+	none := &allowedMentions{Parse: []string{}}
+	users := &allowedMentions{Parse: []string{"users"}}
+	tests := []struct {
+		name        string
+		content     string
+		base        *allowedMentions
+		targets     []string
+		wantContent string
+		want        *allowedMentions
+	}{
+		{"no alerts", "hi @everyone", none, nil, "hi @everyone", none},
+		{"role", "raid!", none, []string{"<@&1>"}, "raid!\n<@&1>", &allowedMentions{Parse: []string{}, Roles: []string{"1"}}},
+		{"user", "gm?", none, []string{"<@2>"}, "gm?\n<@2>", &allowedMentions{Parse: []string{}, Users: []string{"2"}}},
+		{"user while parsing users", "gm?", users, []string{"<@!2>"}, "gm?\n<@!2>", users},
+		{"here defuses typed everyone", "help @everyone", none, []string{"@here"}, "help @\u200beveryone\n@here", &allowedMentions{Parse: []string{"everyone"}}},
+	}
+	for _, tt := range tests {
+		content, got := withAlerts(tt.content, tt.base, tt.targets)
+		if content != tt.wantContent || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q %+v, want %q %+v", tt.name, content, got, tt.wantContent, tt.want)
+		}
+	}
+	if len(none.Parse) != 0 || len(none.Roles) != 0 {
+		t.Errorf("base mentions modified: %+v", none)
+	}
+}
@@ -3,8 +3,83 @@ package main
 import (
 	"net/http"
 	"net/url"
+	"strings"
 )
 
+// Message kinds recorded with each log entry.
+const (
+	kindSay   = "say"
+	kindEmote = "emote"
+	kindOOC   = "ooc"
+)
+
+// OOC policies decide what each destination does with out-of-character
+// messages. An empty policy behaves like oocInclude.
+const (
+	oocInclude  = "include"
+	oocExclude  = "exclude"
+	oocSeparate = "separate"
+)
+
+// defaultOOCMarkers mark out-of-character chatter when none are configured.
+var defaultOOCMarkers = []string{"((", "[OOC]"}
+
+// defaultEmotePrefixes mark emote/action lines when none are configured.
+var defaultEmotePrefixes = []string{"*", "/me"}
+
+// parseEmote reports whether message starts with one of the emote prefixes
+// and returns the action text with the marker removed. Word-like prefixes
+// such as "/me" must be followed by a space so "/meow" is not an emote.
+func parseEmote(message string, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		if prefix == "" || !strings.HasPrefix(strings.ToLower(message), strings.ToLower(prefix)) {
+			continue
+		}
+		rest := message[len(prefix):]
+		last := prefix[len(prefix)-1]
+		isWord := (last >= 'a' && last <= 'z') || (last >= 'A' && last <= 'Z')
+		if isWord && rest != "" && rest[0] != ' ' {
+			continue
+		}
+		// "*draws sword*" style emotes are closed by the same marker.
+		rest = strings.TrimSuffix(strings.TrimSpace(rest), prefix)
+		text := strings.TrimSpace(rest)
+		if text == "" {
+			continue
+		}
+		return text, true
+	}
+	return message, false
+}
+
+// detectEmote applies the configured emote detection to message.
+func detectEmote(cfg *AppConfig, message string) (string, bool) {
+	if !cfg.EmoteDetection {
+		return message, false
+	}
+	prefixes := cfg.EmotePrefixes
+	if len(prefixes) == 0 {
+		prefixes = defaultEmotePrefixes
+	}
+	return parseEmote(message, prefixes)
+}
+
+// detectOOC reports whether message starts with one of the configured
+// out-of-character markers.
+func detectOOC(cfg *AppConfig, message string) bool {
+	markers := cfg.OOCMarkers
+	if len(markers) == 0 {
+		markers = defaultOOCMarkers
+	}
+	lower := strings.ToLower(strings.TrimSpace(message))
+	for _, marker := range markers {
+		if marker != "" && strings.HasPrefix(lower, strings.ToLower(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
 // parseMessage extracts the sender and message query parameters from
 // an incoming HTTP request to the /message endpoint.
 func parseMessage(r *http.Request) (string, string) {
@@ -26,3 +101,16 @@ func parseMessage(r *http.Request) (string, string) {
 
 	return sender, message
 }
+
+// parseScene extracts the optional scene (or channel) name used to group
+// messages into Discord threads. "scene" takes precedence over "channel".
+func parseScene(r *http.Request) string {
+	values, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return ""
+	}
+	if scene := strings.TrimSpace(values.Get("scene")); scene != "" {
+		return scene
+	}
+	return strings.TrimSpace(values.Get("channel"))
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// messageFilter selects the messages to delete: those sent by Sender
+// (ignoring case; any sender when empty) from From up to, but not
+// including, To. A zero bound leaves that end of the range open.
+type messageFilter struct {
+	Sender   string
+	From, To time.Time
+}
+
+// matches reports whether a message sent by sender at at is selected.
+func (f messageFilter) matches(sender string, at time.Time) bool {
+	if f.Sender != "" && !strings.EqualFold(sender, f.Sender) {
+		return false
+	}
+	return (f.From.IsZero() || !at.Before(f.From)) && (f.To.IsZero() || at.Before(f.To))
+}
+
+// senderOf returns f.Sender when name is its character's name, which
+// Discord posts and failure entries show instead of the sender, so that
+// matches takes the two for one sender; otherwise name.
+func (f messageFilter) senderOf(cfg *AppConfig, name string) string {
+	if f.Sender != "" && strings.EqualFold(name, displayName(cfg, f.Sender)) {
+		return f.Sender
+	}
+	return name
+}
+
+// matchesQueued reports whether a message waiting in a retry queue or the
+// offline buffer is selected. Messages queued without the time they were
+// received go by the time they were queued for, which is close to it.
+func (f messageFilter) matchesQueued(cfg *AppConfig, msg QueuedMessage) bool {
+	at := msg.Time
+	if at.IsZero() {
+		at = msg.RetryAt
+	}
+	return f.matches(f.senderOf(cfg, msg.Sender), at)
+}
+
+// matchesFailure reports whether a failure entry is about a selected
+// message. Entries only record the time of day, so those without a log
+// entry or delivery to retry only match a filter without a range.
+func (f messageFilter) matchesFailure(cfg *AppConfig, e FailureEntry) bool {
+	switch {
+	case e.Retry != nil && e.Retry.Entry != nil:
+		return f.matchesTimestamp(e.Retry.Entry.Sender, e.Retry.Entry.Timestamp)
+	case e.Retry != nil && e.Retry.Delivery != nil:
+		return f.matchesQueued(cfg, *e.Retry.Delivery)
+	}
+	return f.From.IsZero() && f.To.IsZero() && f.matches(f.senderOf(cfg, e.Sender), time.Time{})
+}
+
+// matchesTimestamp is matches for a log entry's timestamp. Entries whose
+// time can't be read only match a filter without a range.
+func (f messageFilter) matchesTimestamp(sender, timestamp string) bool {
+	at, err := time.ParseInLocation(logTimestampLayout, timestamp, time.Local)
+	if err != nil {
+		if !f.From.IsZero() || !f.To.IsZero() {
+			return false
+		}
+		at = time.Time{}
+	}
+	return f.matches(sender, at)
+}
+
+// deleteTimeLayouts are the times accepted for a deletion range, from the
+// web UI's datetime-local inputs or the API.
+var deleteTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04", logTimestampLayout, "2006-01-02 15:04"}
+
+// parseDeleteRange parses the bounds of a deletion range. Either may be
+// empty. A bare date as the end takes in the whole day.
+func parseDeleteRange(from, to string) (time.Time, time.Time, error) {
+	parse := func(s string, end bool) (time.Time, error) {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+			if end {
+				t = t.AddDate(0, 0, 1)
+			}
+			return t, nil
+		}
+		for _, layout := range deleteTimeLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("Invalid time %q; use 2006-01-02 15:04", s)
+	}
+	start, err := parse(from, false)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := parse(to, true)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("The end of the range must be after its start")
+	}
+	return start, end, nil
+}
+
+// DeleteResult summarizes a deletion of messages.
+type DeleteResult struct {
+	Entries     int `json:"entries"`     // log entries removed
+	Files       int `json:"files"`       // files rewritten or removed
+	Annotations int `json:"annotations"` // annotations on them removed
+	Queued      int `json:"queued"`      // posts dropped from the retry queues and offline buffer
+	Failures    int `json:"failures"`    // failed message entries removed
+	// DiscordDeleted counts the bot's Discord posts deleted, and
+	// DiscordFailed those that couldn't be.
+	DiscordDeleted int `json:"discordDeleted"`
+	DiscordFailed  int `json:"discordFailed"`
+	// Skipped counts the files in the log folders that couldn't be read or
+	// rewritten, such as other programs' json files. They are logged.
+	Skipped int `json:"skipped"`
+}
+
+func (r DeleteResult) String() string {
+	s := fmt.Sprintf("%d message(s) in %d file(s)", r.Entries, r.Files)
+	if r.Annotations > 0 {
+		s += fmt.Sprintf(", %d annotation(s)", r.Annotations)
+	}
+	if r.Queued > 0 {
+		s += fmt.Sprintf(", %d queued post(s)", r.Queued)
+	}
+	if r.Failures > 0 {
+		s += fmt.Sprintf(", %d failed message(s)", r.Failures)
+	}
+	if r.DiscordDeleted > 0 || r.DiscordFailed > 0 {
+		s += fmt.Sprintf(", %d Discord post(s)", r.DiscordDeleted)
+	}
+	if r.DiscordFailed > 0 {
+		s += fmt.Sprintf("; %d Discord post(s) could not be deleted", r.DiscordFailed)
+	}
+	if r.Skipped > 0 {
+		s += fmt.Sprintf("; %d file(s) could not be read or rewritten, see the application log", r.Skipped)
+	}
+	return s
+}
+
+// apiMessageDelete is the body of POST /api/v1/messages/delete.
+type apiMessageDelete struct {
+	Sender string `json:"sender,omitempty"`
+	// From and To bound the range, as 2006-01-02 15:04 or RFC 3339; a bare
+	// date as To takes in the whole day.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// deleteMessages removes the messages f selects, for players who ask for
+// their content to be removed: from the stored logs in every format (the
+// sources' and sender routes' folders, the OOC, per-scene and translated
+// folders and the session transcripts included), their annotations,
+// delivery receipts, retry queues, offline buffer, failure entries and
+// live log lines, and in bot mode their Discord posts. The bot only knows
+// its posts through the receipts, so older posts, and webhook posts, are
+// left on Discord. Session summaries, the application log, the chat
+// stream's recent lines, zipped archives and backups are not changed.
+func (a *App) deleteMessages(f messageFilter) (DeleteResult, error) {
+	f.Sender = strings.TrimSpace(f.Sender)
+	if f.Sender == "" && f.From.IsZero() && f.To.IsZero() {
+		return DeleteResult{}, fmt.Errorf("Enter a sender, a time range or both")
+	}
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	if cfg.Path != "" && cfg.TextTemplate != "" {
+		formats := []string{logFormat(&cfg)}
+		for name := range cfg.Sources {
+			formats = append(formats, logFormat(sourceLogConfig(&cfg, name)))
+		}
+		if slices.Contains(formats, "txt") || slices.Contains(formats, "docx") {
+			// Lines in a custom format can't be told apart from the message.
+			return DeleteResult{}, fmt.Errorf("Deleting messages from txt or docx logs needs the default line format; clear the text line template first")
+		}
+	}
+
+	var result DeleteResult
+	removed := a.receipts.remove(func(r *Receipt) bool {
+		if r.Entry != nil {
+			return f.matchesTimestamp(r.Entry.Sender, r.Entry.Timestamp)
+		}
+		return f.matches(r.Sender, r.Received)
+	})
+	// Copies of a message, such as a sender route's, share its trace.
+	ids := make(map[string]bool, len(removed))
+	traces := make(map[string]bool, len(removed))
+	for _, r := range removed {
+		ids[r.ID] = true
+		if r.Trace != "" {
+			traces[r.Trace] = true
+		}
+	}
+	selected := func(msg QueuedMessage) bool {
+		return msg.ID != "" && ids[msg.ID] || msg.Trace != "" && traces[msg.Trace] || f.matchesQueued(&cfg, msg)
+	}
+	for _, q := range a.retryQueues() {
+		q.mu.Lock()
+		kept := (*q.messages)[:0]
+		for _, msg := range *q.messages {
+			if selected(msg) {
+				result.Queued++
+				continue
+			}
+			kept = append(kept, msg)
+		}
+		*q.messages = kept
+		q.mu.Unlock()
+	}
+	n, err := a.deleteOffline(selected)
+	result.Queued += n
+	if err != nil {
+		a.logger.Log("error", fmt.Sprintf("Saving the offline buffer failed: %v", err))
+	}
+	result.Failures = a.logger.removeFailures(func(e FailureEntry) bool {
+		return e.Trace != "" && traces[e.Trace] || f.matchesFailure(&cfg, e)
+	})
+	a.logger.removeHistory(func(line string) bool {
+		for trace := range traces {
+			if strings.Contains(line, "["+trace+"] ") {
+				return true
+			}
+		}
+		return false
+	})
+	// Rewrite what is kept across restarts now rather than on exit.
+	a.saveReceipts()
+	a.saveLogHistory()
+
+	if cfg.DiscordMode == discordModeBot && cfg.BotToken != "" {
+		for _, r := range removed {
+			for _, ref := range r.DiscordMessages {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				err := deleteDiscordMessage(ctx, cfg.BotToken, ref)
+				cancel()
+				if err != nil {
+					result.DiscordFailed++
+					a.logger.Log("error", traced(r.Trace, fmt.Sprintf("Deleting a Discord post failed: %v", err)))
+					continue
+				}
+				result.DiscordDeleted++
+			}
+		}
+	}
+
+	if cfg.Path != "" {
+		a.lockArchive()
+		entries, files, skipped := deleteInArchive(&cfg, f)
+		a.archiveMu.Unlock()
+		result.Entries, result.Files, result.Skipped = entries, files, len(skipped)
+		for _, err := range skipped {
+			a.logger.Log("warning", fmt.Sprintf("Messages not deleted from a file: %v", err))
+		}
+		for _, dir := range archiveFolders(&cfg) {
+			n, err := a.deleteAnnotationsOf(annotationsPath(dir), f)
+			result.Annotations += n
+			if err != nil {
+				return result, fmt.Errorf("Messages deleted, but their annotations were not: %w", err)
+			}
+		}
+	}
+	a.logger.Log("info", fmt.Sprintf("Messages deleted on request: %s", result))
+	return result, nil
+}
+
+// deleteAnnotationsOf removes the annotations on the messages f selects
+// from the annotations file at path, returning how many it removed.
+func (a *App) deleteAnnotationsOf(path string, f messageFilter) (int, error) {
+	a.annotationsMu.Lock()
+	defer a.annotationsMu.Unlock()
+	annotations, err := loadAnnotations(path)
+	if err != nil {
+		return 0, err
+	}
+	kept := annotations[:0]
+	for _, ann := range annotations {
+		if !f.matchesTimestamp(ann.Sender, ann.Timestamp) {
+			kept = append(kept, ann)
+		}
+	}
+	n := len(annotations) - len(kept)
+	if n == 0 {
+		return 0, nil
+	}
+	return n, saveAnnotations(path, kept)
+}
+
+// deleteOffline removes the messages selected from the offline buffer and
+// its file, returning how many it removed.
+func (a *App) deleteOffline(selected func(QueuedMessage) bool) (int, error) {
+	a.offline.mu.Lock()
+	defer a.offline.mu.Unlock()
+	kept := a.offline.messages[:0]
+	for _, msg := range a.offline.messages {
+		if !selected(msg) {
+			kept = append(kept, msg)
+		}
+	}
+	n := len(a.offline.messages) - len(kept)
+	a.offline.messages = kept
+	if n == 0 {
+		return 0, nil
+	}
+	return n, savePendingMessages(pendingPath(offlineDiscordFile), kept)
+}
+
+// deleteInArchive removes the messages f selects from the log files in
+// every format under the folders cfg writes logs to (see archiveFolders),
+// skipping the retention archive, so logs written before the format was
+// changed are covered too. It returns how many entries it removed and from
+// how many files. Files and folders it can't read or rewrite, such as
+// other programs' json files, are passed by and returned with the reason,
+// so the rest is still deleted.
+func deleteInArchive(cfg *AppConfig, f messageFilter) (entries, files int, skipped []error) {
+	for _, dir := range archiveFolders(cfg) {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return filepath.SkipDir
+			}
+			if err != nil {
+				skipped = append(skipped, err)
+				return nil
+			}
+			if d.IsDir() {
+				if path != dir && d.Name() == retentionArchiveDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			var n int
+			switch format := strings.TrimPrefix(filepath.Ext(path), "."); format {
+			case "csv", "json":
+				n, err = deleteInEntries(path, format, layoutOf(cfg), f)
+			case "txt", "docx":
+				n, err = deleteInTextLog(cfg, path, f)
+			default:
+				return nil
+			}
+			if err != nil {
+				skipped = append(skipped, fmt.Errorf("%s: %w", path, err))
+				return nil
+			}
+			if n > 0 {
+				entries += n
+				files++
+			}
+			return nil
+		})
+	}
+	return entries, files, skipped
+}
+
+// deleteInEntries removes the selected entries from a csv or json log,
+// and the file once none are left, returning how many it removed.
+func deleteInEntries(filename, format string, layout logLayout, f messageFilter) (int, error) {
+	defer logFileLocks.lock(filename)()
+	entries, err := readLogFile(filename, format)
+	if err != nil {
+		return 0, err
+	}
+	var kept []LogEntry
+	for _, entry := range entries {
+		if !f.matchesTimestamp(entry.Sender, entry.Timestamp) {
+			kept = append(kept, entry)
+		}
+	}
+	n := len(entries) - len(kept)
+	switch {
+	case n == 0:
+		return 0, nil
+	case len(kept) == 0:
+		return n, os.Remove(filename)
+	}
+	return n, writeLogFile(filename, format, layout, kept)
+}
+
+// deleteInTextLog removes the selected messages from a txt or docx log
+// written by formatTextLine, along with the lines that continue them, and
+// the file once nothing is left. It returns how many messages it removed.
+func deleteInTextLog(cfg *AppConfig, filename string, f messageFilter) (int, error) {
+	defer logFileLocks.lock(filename)()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, fmt.Errorf("reading log file: %w", err)
+	}
+	known := textLogSenders(cfg, string(data))
+	var b strings.Builder
+	n := 0
+	deleting := false
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if timestamp, ok := textLineTimestamp(line, f.Sender, known); ok {
+			deleting = f.matchesTimestamp(f.Sender, timestamp)
+			if deleting {
+				n++
+			}
+		} else if _, ok := parseTextLine(line); ok {
+			deleting = false
+		}
+		if deleting {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading log file: %w", err)
+	}
+	switch {
+	case n == 0:
+		return 0, nil
+	case b.Len() == 0:
+		return n, os.Remove(filename)
+	}
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("writing log file: %w", err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("replacing log file: %w", err)
+	}
+	return n, nil
+}
+
+// textLineTimestamp returns the timestamp of a log line sent by sender, or
+// by anyone when sender is empty. known are the other senders, see
+// textLineFrom.
+func textLineTimestamp(line, sender string, known []string) (string, bool) {
+	if sender != "" {
+		timestamp, _, _, ok := textLineFrom(line, sender, known)
+		return timestamp, ok
+	}
+	entry, ok := parseTextLine(line)
+	return entry.Timestamp, ok
+}
+
+// handleDeleteMessages deletes messages from the Characters page.
+func (a *App) handleDeleteMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	from, to, err := parseDeleteRange(r.FormValue("from"), r.FormValue("to"))
+	if err == nil {
+		var result DeleteResult
+		result, err = a.deleteMessages(messageFilter{Sender: r.FormValue("sender"), From: from, To: to})
+		if err == nil {
+			fmt.Fprintf(w, `<div class="alert success">Deleted %s</div>`, template.HTMLEscapeString(result.String()))
+			return
+		}
+	}
+	fmt.Fprintf(w, `<div class="alert error">Delete failed: %s</div>`, template.HTMLEscapeString(err.Error()))
+}
+
+// handleAPIDeleteMessages deletes a sender's messages, or those in a time
+// range, everywhere the app keeps them.
+func (a *App) handleAPIDeleteMessages(w http.ResponseWriter, r *http.Request) {
+	var body apiMessageDelete
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	from, to, err := parseDeleteRange(body.From, body.To)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(body.Sender) == "" && from.IsZero() && to.IsZero() {
+		writeJSONError(w, http.StatusBadRequest, "sender, from or to is required")
+		return
+	}
+	result, err := a.deleteMessages(messageFilter{Sender: body.Sender, From: from, To: to})
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDeleteRange(t *testing.T) {
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 10, day, hour, minute, 0, 0, time.Local) }
+	tests := []struct {
+		from, to string
+		want     [2]time.Time
+		wantErr  bool
+	}{
+		{"", "", [2]time.Time{}, false},
+		{"2026-10-17T21:00", "2026-10-17T22:30", [2]time.Time{at(17, 21, 0), at(17, 22, 30)}, false},
+		{"2026-10-17", "2026-10-17", [2]time.Time{at(17, 0, 0), at(18, 0, 0)}, false},
+		{"2026-10-17 21:00", "", [2]time.Time{at(17, 21, 0), {}}, false},
+		{"yesterday", "", [2]time.Time{}, true},
+		{"2026-10-17T22:00", "2026-10-17T21:00", [2]time.Time{}, true},
+	}
+	for _, tt := range tests {
+		from, to, err := parseDeleteRange(tt.from, tt.to)
+		if (err != nil) != tt.wantErr || !from.Equal(tt.want[0]) || !to.Equal(tt.want[1]) {
+			t.Errorf("parseDeleteRange(%q, %q) = %v, %v, %v; want %v", tt.from, tt.to, from, to, err, tt.want)
+		}
+	}
+}
+
+func TestDeleteMessages(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	dir := t.TempDir()
+	daily := filepath.Join(dir, "ConanExiles_log_2026-10-17.txt")
+	session := filepath.Join(dir, "sessions", "Chapter 3.txt")
+	older := filepath.Join(dir, "ConanExiles_log_2026-10-16.txt")
+	files := map[string]string{
+		daily:   "[2026-10-17 21:04:05] conan_cimmeria: By Crom!\nthe tavern burns\n[2026-10-17 21:04:06] Valeria: Run!\n[2026-10-17 22:00:00] * Conan_Cimmeria laughs\n",
+		session: "[2026-10-17 21:04:05] * conan_cimmeria draws his sword\n",
+		older:   "[2026-10-16 20:00:00] conan_cimmeria: Hush.\n",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(name), 0755)
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := saveAnnotations(annotationsPath(dir), []Annotation{
+		{ID: "1", Timestamp: "2026-10-17 21:04:05", Sender: "conan_cimmeria", Message: "By Crom!", Kind: annotationNote, Text: "Foreshadowing"},
+		{ID: "2", Timestamp: "2026-10-17 21:04:06", Sender: "Valeria", Message: "Run!", Kind: annotationNote, Text: "Kept"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+
+	from, to, _ := parseDeleteRange("2026-10-17", "2026-10-17T21:30")
+	result, err := a.deleteMessages(messageFilter{Sender: " Conan_Cimmeria ", From: from, To: to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (DeleteResult{Entries: 2, Files: 2, Annotations: 1}) {
+		t.Errorf("result = %+v", result)
+	}
+	if data, _ := os.ReadFile(daily); string(data) != "[2026-10-17 21:04:06] Valeria: Run!\n[2026-10-17 22:00:00] * Conan_Cimmeria laughs\n" {
+		t.Errorf("daily log = %q", data)
+	}
+	if _, err := os.Stat(session); !os.IsNotExist(err) {
+		t.Errorf("emptied session transcript left behind: %v", err)
+	}
+	if data, _ := os.ReadFile(older); string(data) != files[older] {
+		t.Errorf("message outside the range deleted: %q", data)
+	}
+	if left, _ := loadAnnotations(annotationsPath(dir)); len(left) != 1 || left[0].ID != "2" {
+		t.Errorf("annotations left: %+v", left)
+	}
+
+	if _, err := a.deleteMessages(messageFilter{}); err == nil {
+		t.Error("deleting without a sender or range should fail")
+	}
+}
+
+func TestDeleteMessages_CSV(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	dir := t.TempDir()
+	cfg := &AppConfig{EnableLocalSave: true, Path: dir, FileFormat: "csv"}
+	for _, entry := range []LogEntry{
+		{Timestamp: "2026-10-17 21:04:05", Sender: "Conan", Message: "By Crom!"},
+		{Timestamp: "2026-10-17 21:04:06", Sender: "Valeria", Message: "Run!"},
+	} {
+		if err := logToFile(cfg, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config = cfg
+
+	result, err := a.deleteMessages(messageFilter{Sender: "conan"})
+	if err != nil || result.Entries != 1 {
+		t.Fatalf("deleteMessages = %+v, %v", result, err)
+	}
+	entries, err := readLogFile(generateLogFilename(dir, "csv"), "csv")
+	if err != nil || len(entries) != 1 || entries[0].Sender != "Valeria" {
+		t.Errorf("entries left: %+v, %v", entries, err)
+	}
+}
+
+func TestDeleteMessages_SkipsUnreadableFiles(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	dir := t.TempDir()
+	// Walked first: another program's files that aren't logs
+	other := filepath.Join(dir, "a-overlay-settings.json")
+	os.WriteFile(other, []byte(`{"theme": "dark"}`), 0644)
+	os.WriteFile(filepath.Join(dir, "b-export.csv"), []byte("\"unterminated\n"), 0644)
+	daily := filepath.Join(dir, "ConanExiles_log_2026-10-17.txt")
+	os.WriteFile(daily, []byte("[2026-10-17 21:04:05] Conan: By Crom!\n[2026-10-17 21:04:06] Valeria: Run!\n"), 0644)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+
+	result, err := a.deleteMessages(messageFilter{Sender: "Conan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Entries != 1 || result.Skipped != 2 {
+		t.Errorf("result = %+v", result)
+	}
+	if data, _ := os.ReadFile(daily); string(data) != "[2026-10-17 21:04:06] Valeria: Run!\n" {
+		t.Errorf("daily log = %q", data)
+	}
+	if data, _ := os.ReadFile(other); string(data) != `{"theme": "dark"}` {
+		t.Errorf("other program's file changed: %q", data)
+	}
+	if !strings.Contains(result.String(), "2 file(s) could not be read") {
+		t.Errorf("skipped files not reported: %s", result)
+	}
+}
+
+func TestDeleteMessages_KeepsLongerSendersEmotes(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	dir := t.TempDir()
+	daily := filepath.Join(dir, "ConanExiles_log_2026-10-17.txt")
+	session := filepath.Join(dir, "sessions", "Chapter 3.txt")
+	os.MkdirAll(filepath.Dir(session), 0755)
+	// Alice Smith speaks in the daily log, so her emotes are told apart
+	os.WriteFile(daily, []byte("[2026-10-17 21:00:00] Alice: Hi\n[2026-10-17 21:00:01] Alice Smith: Hello\n"+
+		"[2026-10-17 21:00:02] * Alice Smith waves\n[2026-10-17 21:00:03] * Alice waves back\n"), 0644)
+	// Only known from the characters in the session transcript
+	os.WriteFile(session, []byte("[2026-10-17 21:00:04] * Alice Smith bows\n"), 0644)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+	a.config.Characters = []Character{{Name: "Alice Smith"}}
+
+	result, err := a.deleteMessages(messageFilter{Sender: "Alice"})
+	if err != nil || result.Entries != 2 {
+		t.Fatalf("deleteMessages = %+v, %v", result, err)
+	}
+	if data, _ := os.ReadFile(daily); string(data) != "[2026-10-17 21:00:01] Alice Smith: Hello\n[2026-10-17 21:00:02] * Alice Smith waves\n" {
+		t.Errorf("daily log = %q", data)
+	}
+	if data, _ := os.ReadFile(session); string(data) != "[2026-10-17 21:00:04] * Alice Smith bows\n" {
+		t.Errorf("session transcript = %q", data)
+	}
+}
+
+func TestDeleteMessages_BotPosts(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	bot := newFakeDiscordBot(t)
+	a := setupTestApp()
+	a.receipts = newReceiptTable(maxReceipts)
+	a.discordQueue = NewDiscordQueue(a.logger, a.receipts)
+	defer func() { a.discordQueue.Stop(); a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	tmpDir := t.TempDir()
+	a.config.EnableDiscord = true
+	a.config.DiscordMode = discordModeBot
+	a.config.BotToken = "secret"
+	a.config.BotChannelID = "100"
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+
+	gone := a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "Delete me"})
+	kept := a.processMessage(context.Background(), IncomingMessage{Sender: "Valeria", Message: "Keep me"})
+	waitForDeliveries(t, a)
+
+	body, _ := json.Marshal(apiMessageDelete{Sender: "conan"})
+	rec := httptest.NewRecorder()
+	a.handleAPIDeleteMessages(rec, httptest.NewRequest("POST", "/api/v1/messages/delete", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var result DeleteResult
+	json.NewDecoder(rec.Body).Decode(&result)
+	if result.Entries != 1 || result.DiscordDeleted != 1 || result.DiscordFailed != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	bot.mu.Lock()
+	deleted := strings.Join(bot.deleted, ",")
+	bot.mu.Unlock()
+	if deleted != "m1" {
+		t.Errorf("deleted Discord posts %q, want m1", deleted)
+	}
+	if _, ok := a.receipts.get(gone); ok {
+		t.Error("the deleted message's receipt was kept")
+	}
+	if _, ok := a.receipts.get(kept); !ok {
+		t.Error("another sender's receipt was removed")
+	}
+	data, _ := os.ReadFile(generateLogFilename(tmpDir, "txt"))
+	if strings.Contains(string(data), "Delete me") || !strings.Contains(string(data), "Keep me") {
+		t.Errorf("log after deleting = %q", data)
+	}
+
+	rec = httptest.NewRecorder()
+	a.handleAPIDeleteMessages(rec, httptest.NewRequest("POST", "/api/v1/messages/delete", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("empty request: status %d", rec.Code)
+	}
+}
+
+func TestDeleteMessages_AllFolders(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	dir, sourceDir, routeDir := t.TempDir(), t.TempDir(), t.TempDir()
+	cfg := &AppConfig{EnableLocalSave: true, Path: dir, FileFormat: "txt",
+		Sources:      map[string]SourceProfile{"pvp": {Path: sourceDir, FileFormat: "csv"}},
+		SenderRoutes: map[string]SenderRoute{"Amra": {Path: routeDir}},
+	}
+	amra := LogEntry{Timestamp: "2026-10-17 21:04:05", Sender: "Amra", Message: "By Crom!"}
+	valeria := LogEntry{Timestamp: "2026-10-17 21:04:06", Sender: "Valeria", Message: "Run!"}
+	files := []struct {
+		path, format string
+	}{
+		{filepath.Join(dir, "ConanExiles_log_2026-10-17.txt"), "txt"},
+		{filepath.Join(dir, "ConanExiles_log_2026-10-16.json"), "json"}, // before the format changed
+		{filepath.Join(sourceDir, "ConanExiles_log_2026-10-17.csv"), "csv"},
+		{filepath.Join(routeDir, "ConanExiles_log_2026-10-17.txt"), "txt"},
+	}
+	for _, file := range files {
+		for _, entry := range []LogEntry{amra, valeria} {
+			if err := writeLogEntry(file.path, file.format, layoutOf(cfg), entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config = cfg
+
+	result, err := a.deleteMessages(messageFilter{Sender: "amra"})
+	if err != nil || result.Entries != 4 || result.Files != 4 {
+		t.Fatalf("deleteMessages = %+v, %v", result, err)
+	}
+	for _, file := range files {
+		entries, err := readLogFile(file.path, file.format)
+		if err != nil || len(entries) != 1 || entries[0].Sender != "Valeria" {
+			t.Errorf("%s: entries left %+v, %v", file.path, entries, err)
+		}
+	}
+}
+
+func TestDeleteMessages_QueuesAndOfflineBuffer(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	a := setupTestApp()
+	a.receipts = newReceiptTable(10)
+	a.discordQueue = NewDiscordQueue(a.logger, a.receipts)
+	defer func() { a.discordQueue.Stop(); a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.Characters = []Character{{Name: "Conan", Aliases: []string{"amra"}}}
+	a.discordQueue.SetPaused(time.Now())
+
+	// A sender route's copy has no receipt of its own, only the trace.
+	a.receipts.add("amra", "", "trace1")
+	now := time.Now()
+	a.discordQueue.Add(QueuedMessage{Trace: "trace1", Sender: "Conan", Message: "route copy", RetryAt: now})
+	a.discordQueue.Add(QueuedMessage{Trace: "trace2", Sender: "Valeria", Message: "kept", RetryAt: now})
+	a.offline.since = now
+	a.offline.messages = []QueuedMessage{
+		{Sender: "Conan", Message: "buffered", Time: now},
+		{Sender: "Valeria", Message: "kept", Time: now},
+	}
+	if err := savePendingMessages(pendingPath(offlineDiscordFile), a.offline.messages); err != nil {
+		t.Fatal(err)
+	}
+	a.logger.Log("info", traced("trace1", "Message from amra: route copy"))
+	a.logger.Log("info", traced("trace2", "Message from Valeria: kept"))
+	a.logger.LogRetryableFailure("amra", "lost", "file", "disk full", "trace0", &FailureRetry{
+		Entry: &LogEntry{Timestamp: now.Format(logTimestampLayout), Sender: "amra", Message: "lost"},
+	})
+	a.logger.LogFailure("Valeria", "kept", "discord", "timeout")
+
+	result, err := a.deleteMessages(messageFilter{Sender: "Amra", From: now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Queued != 2 || result.Failures != 1 {
+		t.Errorf("result = %+v", result)
+	}
+	a.discordQueue.mu.Lock()
+	queued := a.discordQueue.messages
+	a.discordQueue.mu.Unlock()
+	if len(queued) != 1 || queued[0].Sender != "Valeria" {
+		t.Errorf("queue = %+v", queued)
+	}
+	buffered, err := loadPendingMessages(pendingPath(offlineDiscordFile))
+	if err != nil || len(buffered) != 1 || buffered[0].Sender != "Valeria" || len(a.offline.messages) != 1 {
+		t.Errorf("offline buffer = %+v (file %+v, %v)", a.offline.messages, buffered, err)
+	}
+	if failures := a.logger.GetFailures(); len(failures) != 1 || failures[0].Sender != "Valeria" {
+		t.Errorf("failures = %+v", failures)
+	}
+	if history := a.logger.GetHistoryText(); strings.Contains(history, "route copy") || !strings.Contains(history, "Valeria: kept") {
+		t.Errorf("log history = %q", history)
+	}
+}
+
+func TestDeleteMessages_WithoutLogger(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	dir := t.TempDir()
+	daily := filepath.Join(dir, "ConanExiles_log_2026-10-17.txt")
+	os.WriteFile(daily, []byte("[2026-10-17 21:04:05] Conan: By Crom!\n"), 0644)
+	// Unreadable, so the skipped file is logged too
+	os.WriteFile(filepath.Join(dir, "other.json"), []byte("{"), 0644)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.logger = nil
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+	a.config.PersistLogHistory = true
+
+	result, err := a.deleteMessages(messageFilter{Sender: "Conan"})
+	if err != nil || result.Entries != 1 || result.Skipped != 1 {
+		t.Errorf("deleteMessages = %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseEmote(t *testing.T) {
+	tests := []struct {
+		name          string
+		message       string
+		expectedText  string
+		expectedEmote bool
+	}{
+		{name: "plain speech", message: "Hello there", expectedText: "Hello there", expectedEmote: false},
+		{name: "asterisk wrapped", message: "*draws his sword*", expectedText: "draws his sword", expectedEmote: true},
+		{name: "asterisk open only", message: "* waves", expectedText: "waves", expectedEmote: true},
+		{name: "slash me", message: "/me sits down", expectedText: "sits down", expectedEmote: true},
+		{name: "slash me uppercase", message: "/ME sits down", expectedText: "sits down", expectedEmote: true},
+		{name: "slash me prefix of word", message: "/meow", expectedText: "/meow", expectedEmote: false},
+		{name: "marker only", message: "**", expectedText: "**", expectedEmote: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, emote := parseEmote(tt.message, defaultEmotePrefixes)
+			if text != tt.expectedText || emote != tt.expectedEmote {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tt.expectedText, tt.expectedEmote, text, emote)
+			}
+		})
+	}
+}
+
+func TestDetectEmote_Disabled(t *testing.T) {
+	if _, ok := detectEmote(&AppConfig{}, "*waves*"); ok {
+		t.Error("expected emote detection to be off by default")
+	}
+	cfg := &AppConfig{EmoteDetection: true, EmotePrefixes: []string{">"}}
+	if text, ok := detectEmote(cfg, "> nods"); !ok || text != "nods" {
+		t.Errorf("expected custom prefix to match, got (%q, %v)", text, ok)
+	}
+}
+
+func TestDetectOOC(t *testing.T) {
+	tests := []struct {
+		name     string
+		markers  []string
+		message  string
+		expected bool
+	}{
+		{name: "double parens", message: "((brb, dinner))", expected: true},
+		{name: "ooc tag", message: "[ooc] lag is bad tonight", expected: true},
+		{name: "leading whitespace", message: "  ((afk))", expected: true},
+		{name: "in character", message: "I shall return at dawn.", expected: false},
+		{name: "custom marker", markers: []string{"//"}, message: "// quick question", expected: true},
+		{name: "custom marker replaces defaults", markers: []string{"//"}, message: "((afk))", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &AppConfig{OOCMarkers: tt.markers}
+			if got := detectOOC(cfg, tt.message); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// offlineDiscordFile (next to the config file) holds the Discord
+	// messages buffered while offline, so they survive a restart.
+	offlineDiscordFile = "offline-discord.json"
+	// offlineProbeInterval is how often connectivity is checked while
+	// offline.
+	offlineProbeInterval = 30 * time.Second
+)
+
+// connectivityProbeURL is requested to tell whether the internet, rather
+// than one webhook, is unreachable. Any HTTP response will do.
+var connectivityProbeURL = "https://discord.com/api/v10/gateway"
+
+// probeClient makes connectivity probes, through the configured proxy but
+// around the circuit breaker.
+var probeClient = &http.Client{Transport: outbound}
+
+// offlineBuffer holds the Discord messages received while the internet is
+// unreachable, in memory and in offlineDiscordFile, until it is back. The
+// zero value is online and ready to use.
+type offlineBuffer struct {
+	mu       sync.Mutex
+	since    time.Time // when connectivity was lost; zero while online
+	messages []QueuedMessage
+}
+
+// status returns since when the app has been offline, zero while online,
+// and how many messages are buffered.
+func (b *offlineBuffer) status() (time.Time, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.since, len(b.messages)
+}
+
+// offlineStatus describes offline mode for the status partial: Since is
+// empty while online.
+func (a *App) offlineStatus() map[string]interface{} {
+	since, buffered := a.offline.status()
+	status := map[string]interface{}{"Since": "", "Buffered": buffered}
+	if !since.IsZero() {
+		status["Since"] = since.Format("15:04")
+	}
+	return status
+}
+
+// online checks connectivity by requesting connectivityProbeURL.
+func online(ctx context.Context) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, connectivityProbeURL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := probeClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+// connectionError reports a Discord send that failed without a response,
+// as when the connection is down or the circuit breaker is open.
+func connectionError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && !errors.Is(err, context.Canceled)
+}
+
+// bufferOffline buffers msg when the app is offline, or when its send just
+// failed with sendErr and the connectivity probe fails too, which takes the
+// app offline. It reports whether msg was buffered. Only with
+// OfflineBuffer set.
+func (a *App) bufferOffline(ctx context.Context, cfg *AppConfig, msg QueuedMessage, sendErr error) bool {
+	if !cfg.OfflineBuffer {
+		return false
+	}
+	if since, _ := a.offline.status(); since.IsZero() {
+		if sendErr == nil || !connectionError(sendErr) || online(ctx) {
+			return false
+		}
+		a.goOffline(sendErr)
+	}
+
+	a.offline.mu.Lock()
+	if a.offline.since.IsZero() {
+		// Back online meanwhile.
+		a.offline.mu.Unlock()
+		return false
+	}
+	a.offline.messages = append(a.offline.messages, msg)
+	count := len(a.offline.messages)
+	err := savePendingMessages(pendingPath(offlineDiscordFile), a.offline.messages)
+	a.offline.mu.Unlock()
+	if err != nil {
+		a.logger.Log("error", traced(msg.Trace, fmt.Sprintf("Saving the offline buffer failed: %v", err)))
+	}
+	a.logger.Log("info", traced(msg.Trace, fmt.Sprintf("Offline: message buffered for Discord (%d buffered)", count)))
+	return true
+}
+
+// goOffline takes the app offline, unless another delivery just did, and
+// starts checking for connectivity to come back.
+func (a *App) goOffline(cause error) {
+	a.offline.mu.Lock()
+	if !a.offline.since.IsZero() {
+		a.offline.mu.Unlock()
+		return
+	}
+	a.offline.since = time.Now()
+	a.offline.mu.Unlock()
+	a.logger.Log("warning", fmt.Sprintf("Internet connection lost (%v); buffering Discord messages until it is back", cause))
+	go a.watchConnectivity()
+}
+
+// watchConnectivity probes every offlineProbeInterval until the internet
+// is reachable, then goes back online.
+func (a *App) watchConnectivity() {
+	ticker := time.NewTicker(offlineProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		ok := online(ctx)
+		cancel()
+		if ok {
+			a.goOnline()
+			return
+		}
+	}
+}
+
+// goOnline ends offline mode and hands the buffered messages to the
+// Discord queue, oldest first.
+func (a *App) goOnline() {
+	a.offline.mu.Lock()
+	since := a.offline.since
+	messages := a.offline.messages
+	a.offline.since, a.offline.messages = time.Time{}, nil
+	a.offline.mu.Unlock()
+
+	a.logger.Log("info", fmt.Sprintf("Internet connection back after %v; sending %d buffered message(s) to Discord", time.Since(since).Round(time.Second), len(messages)))
+	a.discordQueue.AddBatch(messages)
+	if err := savePendingMessages(pendingPath(offlineDiscordFile), nil); err != nil {
+		slog.Error("Failed to remove the offline buffer", "err", err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOfflineBuffer(t *testing.T) {
+	oldConfigPath, oldProbeURL := configPathOverride, connectivityProbeURL
+	configPathOverride = filepath.Join(t.TempDir(), "config.json")
+	defer func() { configPathOverride, connectivityProbeURL = oldConfigPath, oldProbeURL }()
+
+	var mu sync.Mutex
+	down := true
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			// Drop the connection, as a dead network would.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		got = append(got, payload["content"])
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	unreachable := httptest.NewServer(nil)
+	connectivityProbeURL = unreachable.URL
+	unreachable.Close()
+
+	a := setupTestApp()
+	a.done = make(chan struct{})
+	a.discordQueue = NewDiscordQueue(a.logger, nil)
+	defer func() { close(a.done); a.discordQueue.Stop(); a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableDiscord = true
+	a.config.WebhookURL = srv.URL
+	a.config.OfflineBuffer = true
+
+	send := func(message string) {
+		a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: message})
+		waitForDeliveries(t, a)
+	}
+	send("first")
+	send("second")
+
+	report := a.health()
+	if report.OfflineSince == nil || report.OfflineBuffered != 2 {
+		t.Fatalf("health = %+v, want offline with 2 buffered", report)
+	}
+	saved, err := os.ReadFile(pendingPath(offlineDiscordFile))
+	if err != nil || !strings.Contains(string(saved), "second") {
+		t.Fatalf("offline buffer file: %v", err)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	a.goOnline()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || !strings.Contains(got[0], "first") || !strings.Contains(got[1], "second") {
+		t.Errorf("after reconnecting, Discord got %q", got)
+	}
+	if since, _ := a.offline.status(); !since.IsZero() {
+		t.Error("still offline")
+	}
+	if _, err := os.Stat(pendingPath(offlineDiscordFile)); !os.IsNotExist(err) {
+		t.Errorf("offline buffer file left behind: %v", err)
+	}
+}
+
+func TestConnectionError(t *testing.T) {
+	refused := &url.Error{Op: "Post", URL: "https://discord.com/api/webhooks/1/abc", Err: errors.New("connection refused")}
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("sending discord request: %w", refused), true},
+		{&url.Error{Op: "Post", URL: "https://discord.com", Err: errCircuitOpen}, true},
+		{&url.Error{Op: "Post", URL: "https://discord.com", Err: context.Canceled}, false},
+		{errors.New("discord API returned status code: 500"), false},
+	}
+	for _, tt := range tests {
+		if got := connectionError(tt.err); got != tt.want {
+			t.Errorf("connectionError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	if a.bufferOffline(context.Background(), a.config, QueuedMessage{}, refused) {
+		t.Error("buffered without OfflineBuffer")
+	}
+}
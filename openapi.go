@@ -0,0 +1,225 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// openAPIPath serves the OpenAPI description of the JSON API.
+const openAPIPath = "/api/v1/openapi.json"
+
+// handleOpenAPI serves an OpenAPI 3 description of the JSON API, for
+// generating clients.
+func (a *App) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPISpec(apiV1Routes))
+}
+
+// openAPISpec describes routes as an OpenAPI 3 document. Request and
+// response schemas are derived from the Go types of the route's Body and
+// Response values and their JSON tags.
+func openAPISpec(routes []apiRoute) map[string]interface{} {
+	schemas := map[string]interface{}{
+		"Error": map[string]interface{}{
+			"type":       "object",
+			"required":   []string{"error"},
+			"properties": map[string]interface{}{"error": map[string]interface{}{"type": "string"}},
+		},
+	}
+	gen := &schemaGenerator{schemas: schemas}
+
+	paths := map[string]map[string]interface{}{}
+	for _, route := range routes {
+		op := map[string]interface{}{
+			"operationId": operationID(route),
+			"summary":     route.Summary,
+		}
+		if len(route.Params) > 0 {
+			var params []interface{}
+			for _, p := range route.Params {
+				params = append(params, map[string]interface{}{
+					"name":        p.Name,
+					"in":          p.In,
+					"required":    p.In == "path",
+					"description": p.Description,
+					"schema":      map[string]interface{}{"type": p.Type},
+				})
+			}
+			op["parameters"] = params
+		}
+		if route.Body != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": gen.schema(reflect.TypeOf(route.Body))},
+				},
+			}
+		}
+
+		success := map[string]interface{}{"description": http.StatusText(route.Status)}
+		if route.Response != nil {
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": gen.schema(reflect.TypeOf(route.Response))},
+			}
+		}
+		responses := map[string]interface{}{strconv.Itoa(route.Status): success}
+		// Every route may be refused when API tokens are required.
+		errors := append([]int{http.StatusUnauthorized, http.StatusForbidden}, route.Errors...)
+		for _, code := range errors {
+			responses[strconv.Itoa(code)] = map[string]interface{}{
+				"description": http.StatusText(code),
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaRef("Error")},
+				},
+			}
+		}
+		op["responses"] = responses
+
+		if paths[route.Path] == nil {
+			paths[route.Path] = map[string]interface{}{}
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = op
+	}
+	paths[openAPIPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "getOpenAPI",
+			"summary":     "This OpenAPI description",
+			"security":    []interface{}{},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}},
+					},
+				},
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "RP Chat Logger API",
+			"version":     Version,
+			"description": "JSON API of the RP Chat Logger web UI.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		// A token is only needed when the app is set to require one.
+		"security": []interface{}{
+			map[string]interface{}{"bearerAuth": []string{}},
+			map[string]interface{}{},
+		},
+	}
+}
+
+// operationID names an operation after its method and path, e.g.
+// getMessagesById for GET /api/v1/messages/{id}.
+func operationID(route apiRoute) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(route.Method))
+	for _, part := range strings.Split(strings.TrimPrefix(route.Path, "/api/v1/"), "/") {
+		if strings.HasPrefix(part, "{") {
+			part = "by-" + strings.Trim(part, "{}")
+		}
+		for _, word := range strings.FieldsFunc(part, func(r rune) bool { return r == '-' || r == '.' || r == '_' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// schemaGenerator derives JSON schemas from Go types, adding named structs
+// to schemas and referring to them by name.
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := schemaName(t)
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = nil // placeholder for recursive types
+			g.schemas[name] = g.structSchema(t)
+		}
+		return schemaRef(name)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema describes a struct's JSON-encoded fields. Embedded structs
+// are flattened, as encoding/json does. No field is marked required: the
+// config can be updated partially and empty fields are often omitted.
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+				addFields(field.Type)
+				continue
+			}
+			if !field.IsExported() || tag == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = g.schema(field.Type)
+		}
+	}
+	addFields(t)
+
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// schemaName turns a Go type name into a schema name: apiLogLine becomes
+// LogLine and receiptStatus ReceiptStatus.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if trimmed := strings.TrimPrefix(name, "api"); trimmed != name && trimmed != "" && unicode.IsUpper(rune(trimmed[0])) {
+		name = trimmed
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	mux := apiTestServer(a)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", openAPIPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var spec struct {
+		OpenAPI    string
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage
+			}
+		}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.OpenAPI != "3.0.3" {
+		t.Errorf("unexpected openapi version %q", spec.OpenAPI)
+	}
+
+	// Every documented operation is served by the mux under that pattern.
+	for _, route := range apiV1Routes {
+		if _, ok := spec.Paths[route.Path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s missing from the spec", route.Method, route.Path)
+		}
+		path := strings.ReplaceAll(route.Path, "{id}", "1")
+		_, pattern := mux.Handler(httptest.NewRequest(route.Method, path, nil))
+		if pattern != route.Method+" "+route.Path {
+			t.Errorf("%s %s is routed to %q", route.Method, route.Path, pattern)
+		}
+	}
+
+	// Schemas follow the JSON encoding of the Go types.
+	config := spec.Components.Schemas["AppConfig"].Properties
+	configType := reflect.TypeOf(AppConfig{})
+	for i := 0; i < configType.NumField(); i++ {
+		name, _, _ := strings.Cut(configType.Field(i).Tag.Get("json"), ",")
+		if _, ok := config[name]; !ok {
+			t.Errorf("AppConfig schema is missing %q", name)
+		}
+	}
+	status := spec.Components.Schemas["Status"].Properties
+	for _, name := range []string{"ingestion", "discordQueue", "message", "tunnel", "session"} {
+		if _, ok := status[name]; !ok {
+			t.Errorf("Status schema is missing %q", name)
+		}
+	}
+}
+
+func TestOperationID(t *testing.T) {
+	tests := []struct {
+		method, path, want string
+	}{
+		{"GET", "/api/v1/config", "getConfig"},
+		{"POST", "/api/v1/server/start", "postServerStart"},
+		{"GET", "/api/v1/messages/{id}", "getMessagesById"},
+		{"POST", "/api/v1/failures/{id}/retry", "postFailuresByIdRetry"},
+	}
+	for _, tt := range tests {
+		if got := operationID(apiRoute{Method: tt.method, Path: tt.path}); got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
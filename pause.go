@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"time"
+)
+
+// discordPausedFile, next to the config file, records since when posting
+// to Discord has been paused, so it stays paused across restarts.
+const discordPausedFile = "discord-paused.json"
+
+// discordPause is the content of discordPausedFile.
+type discordPause struct {
+	Since time.Time `json:"since"`
+}
+
+// SetPaused holds every message in the queue from since on, instead of
+// sending it, or with a zero since, sends them again. It is a no-op on a
+// nil queue.
+func (q *DiscordQueue) SetPaused(since time.Time) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	q.pausedSince = since
+	q.mu.Unlock()
+	if since.IsZero() {
+		select {
+		case q.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// PausedSince returns since when the queue has been paused, or the zero
+// time when it isn't.
+func (q *DiscordQueue) PausedSince() time.Time {
+	if q == nil {
+		return time.Time{}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pausedSince
+}
+
+// pauseDiscord stops posting to Discord: messages are still logged and
+// forwarded, and their Discord posts wait in the retry queue until
+// resumeDiscord.
+func (a *App) pauseDiscord() {
+	if !a.discordQueue.PausedSince().IsZero() {
+		return
+	}
+	since := time.Now()
+	data, _ := json.Marshal(discordPause{Since: since})
+	if err := os.WriteFile(pendingPath(discordPausedFile), data, 0600); err != nil {
+		// Paused all the same, just not after a restart.
+		a.logger.Log("error", fmt.Sprintf("Saving the Discord pause failed: %v", err))
+	}
+	a.discordQueue.SetPaused(since)
+	a.logger.Log("info", "Discord paused, messages are held in the queue until resumed")
+}
+
+// resumeDiscord posts the held messages and posts to Discord again.
+func (a *App) resumeDiscord() {
+	if a.discordQueue.PausedSince().IsZero() {
+		return
+	}
+	if err := os.Remove(pendingPath(discordPausedFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		a.logger.Log("error", fmt.Sprintf("Removing the Discord pause failed: %v", err))
+	}
+	a.discordQueue.SetPaused(time.Time{})
+	a.logger.Log("info", fmt.Sprintf("Discord resumed, posting %d held message(s)", a.discordQueue.QueueSize()))
+}
+
+// restoreDiscordPause pauses Discord again if it was paused when the app
+// last stopped.
+func (a *App) restoreDiscordPause() {
+	data, err := os.ReadFile(pendingPath(discordPausedFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	var pause discordPause
+	if err == nil {
+		err = json.Unmarshal(data, &pause)
+	}
+	if err == nil && pause.Since.IsZero() {
+		err = fmt.Errorf("no pause time in %s", discordPausedFile)
+	}
+	if err != nil {
+		a.logger.Log("error", fmt.Sprintf("Restoring the Discord pause failed: %v", err))
+		pause.Since = time.Now()
+	}
+	a.discordQueue.SetPaused(pause.Since)
+	a.logger.Log("info", fmt.Sprintf("Discord paused since %s, messages are held in the queue until resumed", pause.Since.Format("Jan 2 15:04")))
+}
+
+// pauseStatus describes the Discord pause for the status partial.
+func (a *App) pauseStatus() map[string]interface{} {
+	status := map[string]interface{}{"Since": "", "Held": 0}
+	if since := a.discordQueue.PausedSince(); !since.IsZero() {
+		status["Since"] = since.Format("15:04")
+		status["Held"] = a.discordQueue.QueueSize()
+	}
+	return status
+}
+
+// handlePauseDiscord pauses Discord from the status panel.
+func (a *App) handlePauseDiscord(w http.ResponseWriter, r *http.Request) {
+	a.pauseDiscord()
+	a.handleServerStatus(w, r)
+}
+
+// handleResumeDiscord resumes Discord from the status panel.
+func (a *App) handleResumeDiscord(w http.ResponseWriter, r *http.Request) {
+	a.resumeDiscord()
+	a.handleServerStatus(w, r)
+}
+
+// handleAPIPauseDiscord pauses Discord and returns the new status.
+func (a *App) handleAPIPauseDiscord(w http.ResponseWriter, r *http.Request) {
+	a.pauseDiscord()
+	writeJSON(w, http.StatusOK, a.status())
+}
+
+// handleAPIResumeDiscord resumes Discord and returns the new status.
+func (a *App) handleAPIResumeDiscord(w http.ResponseWriter, r *http.Request) {
+	a.resumeDiscord()
+	writeJSON(w, http.StatusOK, a.status())
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPauseDiscord(t *testing.T) {
+	oldConfigPath := configPathOverride
+	configPathOverride = filepath.Join(t.TempDir(), "config.json")
+	defer func() { configPathOverride = oldConfigPath }()
+
+	bot := newFakeDiscordBot(t)
+	a := setupTestApp()
+	a.discordQueue = NewDiscordQueue(a.logger, nil)
+	defer func() { a.discordQueue.Stop(); a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	tmpDir := t.TempDir()
+	a.config.EnableDiscord = true
+	a.config.DiscordMode = discordModeBot
+	a.config.BotToken = "secret"
+	a.config.BotChannelID = "100"
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+
+	a.pauseDiscord()
+	a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "Crom!"})
+	waitForDeliveries(t, a)
+	a.discordQueue.processMessages(context.Background())
+
+	if got := bot.contents("100"); len(got) != 0 {
+		t.Errorf("posted while paused: %q", got)
+	}
+	if n := a.discordQueue.QueueSize(); n != 1 {
+		t.Errorf("queue holds %d message(s), want 1", n)
+	}
+	if data, err := os.ReadFile(generateLogFilename(tmpDir, "txt")); err != nil || !strings.Contains(string(data), "Crom!") {
+		t.Errorf("message not logged while paused: %q, %v", data, err)
+	}
+	if report := a.health(); report.DiscordPausedSince == nil {
+		t.Error("health doesn't report the pause")
+	}
+
+	// A restart keeps the pause.
+	restarted := setupTestApp()
+	restarted.discordQueue = NewDiscordQueue(restarted.logger, nil)
+	defer func() { restarted.discordQueue.Stop(); restarted.sseBroker.Stop(); restarted.failureBroker.Stop() }()
+	restarted.restoreDiscordPause()
+	if !restarted.discordQueue.PausedSince().Equal(a.discordQueue.PausedSince()) {
+		t.Errorf("restored pause since %v, want %v", restarted.discordQueue.PausedSince(), a.discordQueue.PausedSince())
+	}
+
+	a.resumeDiscord()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(bot.contents("100")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := bot.contents("100"); len(got) != 1 || !strings.Contains(got[0], "Crom!") {
+		t.Errorf("after resuming, Discord got %q", got)
+	}
+	if _, err := os.Stat(pendingPath(discordPausedFile)); !os.IsNotExist(err) {
+		t.Errorf("pause file left after resuming: %v", err)
+	}
+}
+
+func TestDiscordQueueDrain_Paused(t *testing.T) {
+	q := NewDiscordQueue(nil, nil)
+	q.Stop()
+	q.SetPaused(time.Now())
+	q.Add(QueuedMessage{WebhookURL: "http://127.0.0.1:1/webhook", Sender: "Conan", Message: "held"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	left := q.Drain(ctx)
+	if len(left) != 1 || left[0].Message != "held" {
+		t.Errorf("Drain while paused returned %+v, want the held message", left)
+	}
+	if ctx.Err() != nil {
+		t.Error("Drain waited while paused")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Files (next to the config file) holding messages that were still queued
+// when the application last shut down.
+const (
+	pendingDiscordFile = "pending-discord.json"
+	pendingForwardFile = "pending-forward.json"
+)
+
+// drainQueue repeatedly processes a retry queue until it is empty or ctx
+// expires, sleeping until the earliest retry time in between. Whatever is
+// left is removed from the queue and returned.
+func drainQueue(ctx context.Context, mu *sync.Mutex, messages *[]QueuedMessage, process func(context.Context)) []QueuedMessage {
+	for {
+		process(ctx)
+
+		mu.Lock()
+		remaining := len(*messages)
+		var next time.Time
+		for _, msg := range *messages {
+			if next.IsZero() || msg.RetryAt.Before(next) {
+				next = msg.RetryAt
+			}
+		}
+		mu.Unlock()
+
+		if remaining == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			mu.Lock()
+			left := *messages
+			*messages = nil
+			mu.Unlock()
+			return left
+		case <-timer.C:
+		}
+	}
+}
+
+// pendingPath returns the path of a pending-messages file.
+func pendingPath(name string) string {
+	return filepath.Join(filepath.Dir(getConfigPath()), name)
+}
+
+// savePendingMessages writes undelivered messages to path so they can be
+// retried on the next start. An empty list removes the file.
+func savePendingMessages(path string, messages []QueuedMessage) error {
+	if len(messages) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("removing pending messages file: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding pending messages: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating pending messages directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("writing pending messages: %w", err)
+	}
+	return nil
+}
+
+// loadPendingMessages reads and removes a pending-messages file. A missing
+// file yields no messages.
+func loadPendingMessages(path string) ([]QueuedMessage, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading pending messages: %w", err)
+	}
+
+	var messages []QueuedMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("decoding pending messages: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("removing pending messages file: %w", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForwardQueue_Drain(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	q := NewForwardQueue(nil, nil)
+	q.Stop()
+	q.Add(QueuedMessage{WebhookURL: srv.URL, Sender: "A", Message: "B", RetryAt: time.Now().Add(100 * time.Millisecond)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if left := q.Drain(ctx); len(left) != 0 {
+		t.Errorf("expected queue to be drained, %d messages left", len(left))
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected 1 delivery, got %d", hits.Load())
+	}
+}
+
+func TestForwardQueue_DrainTimeout(t *testing.T) {
+	q := NewForwardQueue(nil, nil)
+	q.Stop()
+	q.Add(QueuedMessage{WebhookURL: "http://127.0.0.1:1/message", Sender: "A", Message: "B", RetryAt: time.Now().Add(time.Hour)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	left := q.Drain(ctx)
+	if len(left) != 1 || left[0].Message != "B" {
+		t.Errorf("expected the undelivered message back, got %+v", left)
+	}
+	if q.QueueSize() != 0 {
+		t.Errorf("expected queue to be emptied, got %d", q.QueueSize())
+	}
+}
+
+func TestPendingMessages_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), pendingDiscordFile)
+	messages := []QueuedMessage{
+		{WebhookURL: "https://a", Author: DiscordAuthor{Username: "Conan"}, Sender: "Conan", Message: "Hi", Attempts: 2},
+	}
+
+	if err := savePendingMessages(path, messages); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadPendingMessages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Author.Username != "Conan" || got[0].Attempts != 2 {
+		t.Errorf("unexpected messages: %+v", got)
+	}
+
+	// Loading consumes the file.
+	if got, err := loadPendingMessages(path); err != nil || len(got) != 0 {
+		t.Errorf("expected no messages on second load, got %+v (err %v)", got, err)
+	}
+}
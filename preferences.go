@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// preferencesFile (next to the config file) holds the web UI
+	// preferences of each browser.
+	preferencesFile = "preferences.json"
+	// preferencesCookie identifies a browser to the preferences store.
+	preferencesCookie = "rpcl_client"
+	// maxPreferenceClients bounds the store; the browsers seen longest
+	// ago are forgotten first.
+	maxPreferenceClients = 50
+	// maxCollapsedSections bounds the collapsed section list.
+	maxCollapsedSections = 32
+)
+
+// Web UI themes. An empty theme means the default dark theme.
+const (
+	themeDark  = "dark"
+	themeLight = "light"
+)
+
+// Bounds for the saved log panel height, in pixels.
+const (
+	minLogHeight = 100
+	maxLogHeight = 2000
+)
+
+// sectionKey matches the section names the web UI saves as collapsed.
+var sectionKey = regexp.MustCompile(`^[a-z0-9-]{1,64}$`)
+
+// UIPreferences are the web UI settings remembered for one browser.
+type UIPreferences struct {
+	Theme     string   `json:"theme,omitempty"`
+	Collapsed []string `json:"collapsed,omitempty"`
+	LogHeight int      `json:"logHeight,omitempty"`
+	// AutoScroll is nil until changed; the log panels scroll by default.
+	AutoScroll *bool     `json:"autoScroll,omitempty"`
+	Updated    time.Time `json:"updated"`
+}
+
+// normalize drops values the web UI couldn't have sent.
+func (p *UIPreferences) normalize() {
+	if p.Theme != themeDark && p.Theme != themeLight {
+		p.Theme = ""
+	}
+	collapsed := p.Collapsed[:0]
+	for _, key := range p.Collapsed {
+		if sectionKey.MatchString(key) && len(collapsed) < maxCollapsedSections {
+			collapsed = append(collapsed, key)
+		}
+	}
+	p.Collapsed = collapsed
+	if p.LogHeight != 0 {
+		p.LogHeight = max(minLogHeight, min(p.LogHeight, maxLogHeight))
+	}
+}
+
+// preferenceStore keeps UI preferences per browser, loading the file on
+// first use. A nil store remembers nothing.
+type preferenceStore struct {
+	mu      sync.Mutex
+	path    string
+	loaded  bool
+	clients map[string]UIPreferences
+}
+
+func newPreferenceStore(path string) *preferenceStore {
+	return &preferenceStore{path: path, clients: make(map[string]UIPreferences)}
+}
+
+// load reads the preferences file once. Callers hold s.mu.
+func (s *preferenceStore) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &s.clients)
+	}
+	if err != nil {
+		slog.Error("Failed to read UI preferences", "err", err)
+		s.clients = make(map[string]UIPreferences)
+	}
+}
+
+// get returns the preferences of a browser.
+func (s *preferenceStore) get(client string) UIPreferences {
+	if s == nil {
+		return UIPreferences{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	return s.clients[client]
+}
+
+// set replaces the preferences of a browser and saves the store.
+func (s *preferenceStore) set(client string, prefs UIPreferences) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	prefs.Updated = time.Now()
+	s.clients[client] = prefs
+	if len(s.clients) > maxPreferenceClients {
+		clients := make([]string, 0, len(s.clients))
+		for id := range s.clients {
+			clients = append(clients, id)
+		}
+		sort.Slice(clients, func(i, j int) bool {
+			return s.clients[clients[i]].Updated.Before(s.clients[clients[j]].Updated)
+		})
+		for _, id := range clients[:len(clients)-maxPreferenceClients] {
+			delete(s.clients, id)
+		}
+	}
+	return s.save()
+}
+
+// save writes the store to its file. Callers hold s.mu.
+func (s *preferenceStore) save() error {
+	data, err := json.MarshalIndent(s.clients, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding preferences: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("creating preferences directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("writing preferences: %w", err)
+	}
+	return nil
+}
+
+// preferenceClient returns the browser's ID from its cookie, setting a new
+// one when it has none.
+func preferenceClient(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(preferencesCookie); err == nil && sectionKey.MatchString(c.Value) {
+		return c.Value
+	}
+	id := newMessageID()
+	http.SetCookie(w, &http.Cookie{
+		Name:     preferencesCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   10 * 365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+// uiPreferences returns the preferences of the requesting browser, for
+// rendering pages.
+func (a *App) uiPreferences(w http.ResponseWriter, r *http.Request) UIPreferences {
+	return a.preferences.get(preferenceClient(w, r))
+}
+
+// handleGetPreferences returns the requesting browser's UI preferences as
+// JSON.
+func (a *App) handleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	prefs := a.uiPreferences(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// handleUpdatePreferences merges the fields present in a JSON body into
+// the requesting browser's UI preferences.
+func (a *App) handleUpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	client := preferenceClient(w, r)
+	prefs := a.preferences.get(client)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&prefs); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid preferences"})
+		return
+	}
+	prefs.normalize()
+	if err := a.preferences.set(client, prefs); err != nil {
+		a.logger.Log("error", fmt.Sprintf("Saving UI preferences failed: %v", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "saving preferences failed"})
+		return
+	}
+	json.NewEncoder(w).Encode(prefs)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUIPreferencesNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   UIPreferences
+		want UIPreferences
+	}{
+		{"valid", UIPreferences{Theme: themeLight, Collapsed: []string{"backup"}, LogHeight: 450}, UIPreferences{Theme: themeLight, Collapsed: []string{"backup"}, LogHeight: 450}},
+		{"unknown theme", UIPreferences{Theme: "neon"}, UIPreferences{Collapsed: []string{}}},
+		{"bad section keys", UIPreferences{Collapsed: []string{"ok", "<script>", ""}}, UIPreferences{Collapsed: []string{"ok"}}},
+		{"height too small", UIPreferences{LogHeight: 5}, UIPreferences{LogHeight: minLogHeight}},
+		{"height too large", UIPreferences{LogHeight: 90000}, UIPreferences{LogHeight: maxLogHeight}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in
+			got.normalize()
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPreferencesAPI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), preferencesFile)
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.preferences = newPreferenceStore(path)
+
+	// The first request hands out a client cookie.
+	rec := httptest.NewRecorder()
+	a.handleGetPreferences(rec, httptest.NewRequest("GET", "/api/preferences", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != preferencesCookie {
+		t.Fatalf("expected a %s cookie, got %v", preferencesCookie, cookies)
+	}
+	client := cookies[0]
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/preferences", strings.NewReader(body))
+		req.AddCookie(client)
+		rec := httptest.NewRecorder()
+		a.handleUpdatePreferences(rec, req)
+		return rec
+	}
+	if rec := update(`{"theme":"light","logHeight":500}`); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	// Fields left out keep their saved values.
+	update(`{"collapsed":["backup","announce"],"autoScroll":false}`)
+	if rec := update(`not json`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad body, got %d", rec.Code)
+	}
+
+	// A fresh store reads the same preferences back from the file.
+	a.preferences = newPreferenceStore(path)
+	req := httptest.NewRequest("GET", "/api/preferences", nil)
+	req.AddCookie(client)
+	rec = httptest.NewRecorder()
+	a.handleGetPreferences(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{`"theme":"light"`, `"logHeight":500`, `"collapsed":["backup","announce"]`, `"autoScroll":false`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in %s", want, body)
+		}
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("expected the existing cookie to be reused")
+	}
+
+	// Another browser has its own preferences.
+	rec = httptest.NewRecorder()
+	a.handleGetPreferences(rec, httptest.NewRequest("GET", "/api/preferences", nil))
+	if strings.Contains(rec.Body.String(), "light") {
+		t.Errorf("expected default preferences for a new browser, got %s", rec.Body.String())
+	}
+}
+
+func TestPreferenceStoreEvictsOldest(t *testing.T) {
+	s := newPreferenceStore(filepath.Join(t.TempDir(), preferencesFile))
+	for i := 0; i <= maxPreferenceClients; i++ {
+		if err := s.set(fmt.Sprintf("client%d", i), UIPreferences{Theme: themeLight}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.clients) != maxPreferenceClients {
+		t.Errorf("expected %d clients, got %d", maxPreferenceClients, len(s.clients))
+	}
+	if _, ok := s.clients["client0"]; ok {
+		t.Error("expected the oldest client to be evicted")
+	}
+
+	var none *preferenceStore
+	if err := none.set("x", UIPreferences{Theme: themeDark}); err != nil || none.get("x").Theme != "" {
+		t.Error("expected a nil store to remember nothing")
+	}
+}
+
+func TestIndexAppliesTheme(t *testing.T) {
+	dir := t.TempDir()
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.updater = NewUpdater(a.logger)
+	a.preferences = newPreferenceStore(filepath.Join(dir, preferencesFile))
+	a.preferences.set("abc123", UIPreferences{Theme: themeLight})
+	// A usable config, so the page isn't redirected to the setup wizard.
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: preferencesCookie, Value: "abc123"})
+	rec := httptest.NewRecorder()
+	a.handleIndex(rec, req)
+	if !strings.Contains(rec.Body.String(), `data-theme="light"`) {
+		t.Errorf("expected the light theme on the page")
+	}
+	if _, err := os.Stat(filepath.Join(dir, preferencesFile)); err != nil {
+		t.Errorf("expected the preferences file to be written: %v", err)
+	}
+}
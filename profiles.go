@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// profilesDir (next to the config file) holds one config file per
+	// profile, named after the profile.
+	profilesDir = "profiles"
+	// maxProfileName bounds a profile name.
+	maxProfileName = 64
+)
+
+// profilePath returns the config file of the named profile.
+func profilePath(name string) string {
+	return filepath.Join(filepath.Dir(getConfigPath()), profilesDir, name+".json")
+}
+
+// checkProfileName rejects names that are empty, too long or unsafe as a
+// file name: letters, digits, spaces, dashes, underscores and dots are
+// allowed, but not a leading dot.
+func checkProfileName(name string) error {
+	if name == "" || len(name) > maxProfileName {
+		return fmt.Errorf("Profile name must be 1 to %d characters", maxProfileName)
+	}
+	if strings.HasPrefix(name, ".") || strings.TrimSpace(name) != name {
+		return fmt.Errorf("Profile name %q can't start with a dot or a space", name)
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == ' ' || c == '-' || c == '_' || c == '.':
+		default:
+			return fmt.Errorf("Profile name %q may only use letters, digits, spaces, dashes, underscores and dots", name)
+		}
+	}
+	return nil
+}
+
+// listProfiles returns the saved profile names, sorted.
+func listProfiles() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(filepath.Dir(getConfigPath()), profilesDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading profiles: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if ok && !e.IsDir() && checkProfileName(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// saveProfile writes config as the named profile.
+func saveProfile(name string, config *AppConfig) error {
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+	cfg := *config
+	cfg.Profile = name
+	if err := encryptSecrets(&cfg); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding profile: %w", err)
+	}
+	path := profilePath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating profiles directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("writing profile: %w", err)
+	}
+	return nil
+}
+
+// activateProfile makes the named profile the config: its settings are
+// written to the config file, marked as coming from that profile. The new
+// config is returned; callers apply it.
+func activateProfile(name string) (*AppConfig, error) {
+	if err := checkProfileName(name); err != nil {
+		return nil, err
+	}
+	config := &AppConfig{}
+	if err := readConfigFile(profilePath(name), config); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("Profile %q not found", name)
+		}
+		return nil, fmt.Errorf("reading profile %q: %w", name, err)
+	}
+	config.Profile = name
+	if err := saveConfiguration(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// deleteProfile removes a profile that isn't active.
+func deleteProfile(name, active string) error {
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+	if name == active {
+		return errors.New("Switch to another profile before deleting this one")
+	}
+	if err := os.Remove(profilePath(name)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("Profile %q not found", name)
+		}
+		return fmt.Errorf("deleting profile: %w", err)
+	}
+	return nil
+}
+
+// switchProfile activates a profile and applies it to the running app.
+func (a *App) switchProfile(name string) error {
+	config, err := activateProfile(name)
+	if err != nil {
+		return err
+	}
+	a.applyConfig(config, fmt.Sprintf("Switched to profile %q", name))
+	return nil
+}
+
+// handleSaveProfile stores the current settings as a profile, making it
+// the active one, and returns the profile list.
+func (a *App) handleSaveProfile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if err := checkProfileName(name); err != nil {
+		a.renderProfileList(w, map[string]interface{}{"Error": err.Error()})
+		return
+	}
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	cfg.Profile = name
+	if err := saveConfiguration(&cfg); err != nil {
+		a.logger.Log("error", fmt.Sprintf("Failed to save profile: %v", err))
+		a.renderProfileList(w, map[string]interface{}{"Error": "Failed to save profile"})
+		return
+	}
+	a.configMu.Lock()
+	a.config.Profile = name
+	a.configMu.Unlock()
+	a.logger.Log("info", fmt.Sprintf("Settings saved as profile %q", name))
+	a.renderProfileList(w, map[string]interface{}{"Message": fmt.Sprintf("Saved as profile %q", name)})
+}
+
+// handleActivateProfile switches to a profile and reloads the page, so
+// the settings form shows the profile's settings.
+func (a *App) handleActivateProfile(w http.ResponseWriter, r *http.Request) {
+	if err := a.switchProfile(r.PathValue("name")); err != nil {
+		a.renderProfileList(w, map[string]interface{}{"Error": err.Error()})
+		return
+	}
+	w.Header().Set("HX-Refresh", "true")
+	a.renderProfileList(w, map[string]interface{}{})
+}
+
+// handleDeleteProfile deletes a profile and returns the profile list.
+func (a *App) handleDeleteProfile(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	a.configMu.RLock()
+	active := a.config.Profile
+	a.configMu.RUnlock()
+	if err := deleteProfile(name, active); err != nil {
+		a.renderProfileList(w, map[string]interface{}{"Error": err.Error()})
+		return
+	}
+	a.logger.Log("info", fmt.Sprintf("Profile %q deleted", name))
+	a.renderProfileList(w, map[string]interface{}{"Message": fmt.Sprintf("Profile %q deleted", name)})
+}
+
+// profileListData returns the template data for the profile list.
+func (a *App) profileListData(data map[string]interface{}) map[string]interface{} {
+	profiles, err := listProfiles()
+	if err != nil {
+		slog.Error("Failed to list profiles", "err", err)
+	}
+	a.configMu.RLock()
+	data["Active"] = a.config.Profile
+	a.configMu.RUnlock()
+	data["Profiles"] = profiles
+	return data
+}
+
+func (a *App) renderProfileList(w http.ResponseWriter, data map[string]interface{}) {
+	tmpl, err := a.parseTemplates("templates/partials/profile_list.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "profile-list", a.profileListData(data)); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
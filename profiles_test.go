@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckProfileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"Conan server A", false},
+		{"test_2.1-b", false},
+		{"", true},
+		{strings.Repeat("a", maxProfileName+1), true},
+		{".hidden", true},
+		{" padded", true},
+		{"../config", true},
+		{`a\b`, true},
+	}
+	for _, tt := range tests {
+		if err := checkProfileName(tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("checkProfileName(%q) = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	setConfigPath(configPath)
+	defer setConfigPath("")
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableLocalSave = true
+	a.config.Path = filepath.Join(dir, "server-a")
+
+	form := func(values url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/profiles", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		a.handleSaveProfile(rec, req)
+		return rec
+	}
+	if rec := form(url.Values{"name": {"Server A"}}); !strings.Contains(rec.Body.String(), "Server A") {
+		t.Fatalf("expected the new profile in the list, got %s", rec.Body.String())
+	}
+	if a.config.Profile != "Server A" {
+		t.Errorf("expected Server A to be active, got %q", a.config.Profile)
+	}
+
+	// Settings saved while a profile is active also land in its file.
+	a.config.Path = filepath.Join(dir, "test")
+	cfg := *a.config
+	cfg.Profile = "Test"
+	if err := saveConfiguration(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := listProfiles(); err != nil || !reflect.DeepEqual(names, []string{"Server A", "Test"}) {
+		t.Fatalf("unexpected profiles %v, %v", names, err)
+	}
+
+	if err := a.switchProfile("Server A"); err != nil {
+		t.Fatal(err)
+	}
+	if a.config.Path != filepath.Join(dir, "server-a") || a.config.Profile != "Server A" {
+		t.Errorf("expected Server A's settings, got %+v", a.config)
+	}
+	var saved AppConfig
+	if err := readConfigFile(configPath, &saved); err != nil || saved.Profile != "Server A" {
+		t.Errorf("expected the config file to follow the profile, got %q, %v", saved.Profile, err)
+	}
+
+	if err := a.switchProfile("Missing"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+	if err := deleteProfile("Server A", a.config.Profile); err == nil {
+		t.Error("expected the active profile not to be deleted")
+	}
+	if err := deleteProfile("Test", a.config.Profile); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := listProfiles(); !reflect.DeepEqual(names, []string{"Server A"}) {
+		t.Errorf("expected only Server A to remain, got %v", names)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queueSeq numbers queued messages across the retry queues, so a message
+// can be retried or deleted from the queue panel and API.
+var queueSeq atomic.Uint64
+
+// numberQueued gives msg its queue number, unless it has one from an
+// earlier trip through a queue.
+func numberQueued(msg *QueuedMessage) {
+	if msg.seq == 0 {
+		msg.seq = queueSeq.Add(1)
+	}
+}
+
+// retryQueue is one of the App's retry queues, for inspecting and managing
+// it.
+type retryQueue struct {
+	sink     string // sinkDiscord or sinkForward
+	mu       *sync.Mutex
+	messages *[]QueuedMessage
+	notify   chan struct{}
+}
+
+// retryQueues returns the Discord and forward queues.
+func (a *App) retryQueues() []retryQueue {
+	var queues []retryQueue
+	if q := a.discordQueue; q != nil {
+		queues = append(queues, retryQueue{sinkDiscord, &q.mu, &q.messages, q.notify})
+	}
+	if q := a.forwardQueue; q != nil {
+		queues = append(queues, retryQueue{sinkForward, &q.mu, &q.messages, q.notify})
+	}
+	return queues
+}
+
+// wake makes the queue process its messages now.
+func (q retryQueue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// apiQueuedMessage is a message waiting in a retry queue. Messages being
+// sent at the moment are not listed.
+type apiQueuedMessage struct {
+	ID        uint64     `json:"id"`
+	Queue     string     `json:"queue"` // discord or forward
+	MessageID string     `json:"messageId,omitempty"`
+	Trace     string     `json:"trace,omitempty"`
+	Sender    string     `json:"sender"`
+	Message   string     `json:"message"`
+	Source    string     `json:"source,omitempty"`
+	Attempts  int        `json:"attempts"`
+	RetryAt   *time.Time `json:"retryAt,omitempty"` // unset when due now
+}
+
+// apiQueueList is the contents of the retry queues, in the order they are
+// sent per queue.
+type apiQueueList struct {
+	Count     int                `json:"count"`
+	NextRetry *time.Time         `json:"nextRetry,omitempty"`
+	Messages  []apiQueuedMessage `json:"messages"`
+}
+
+// queueList lists the queued messages.
+func (a *App) queueList() apiQueueList {
+	list := apiQueueList{Messages: []apiQueuedMessage{}}
+	for _, q := range a.retryQueues() {
+		q.mu.Lock()
+		for _, msg := range *q.messages {
+			item := apiQueuedMessage{ID: msg.seq, Queue: q.sink, MessageID: msg.ID, Trace: msg.Trace, Sender: msg.Sender,
+				Message: msg.Message, Source: msg.Source, Attempts: msg.Attempts}
+			if !msg.RetryAt.IsZero() {
+				retryAt := msg.RetryAt
+				item.RetryAt = &retryAt
+				if list.NextRetry == nil || retryAt.Before(*list.NextRetry) {
+					list.NextRetry = &retryAt
+				}
+			}
+			list.Messages = append(list.Messages, item)
+		}
+		q.mu.Unlock()
+	}
+	list.Count = len(list.Messages)
+	return list
+}
+
+// retryQueuedNow makes queued message id due now. It reports false when no
+// message has that ID.
+func (a *App) retryQueuedNow(id uint64) bool {
+	for _, q := range a.retryQueues() {
+		q.mu.Lock()
+		found := false
+		for i := range *q.messages {
+			if (*q.messages)[i].seq == id {
+				(*q.messages)[i].RetryAt = time.Time{}
+				found = true
+				break
+			}
+		}
+		q.mu.Unlock()
+		if found {
+			q.wake()
+			return true
+		}
+	}
+	return false
+}
+
+// deleteQueued removes queued message id without sending it, marking its
+// delivery failed. It reports false when no message has that ID.
+func (a *App) deleteQueued(id uint64) bool {
+	for _, q := range a.retryQueues() {
+		q.mu.Lock()
+		var removed *QueuedMessage
+		for i, msg := range *q.messages {
+			if msg.seq == id {
+				removed = &msg
+				*q.messages = append((*q.messages)[:i:i], (*q.messages)[i+1:]...)
+				break
+			}
+		}
+		q.mu.Unlock()
+		if removed != nil {
+			a.receipts.set(removed.ID, q.sink, deliveryFailed)
+			a.logger.Log("info", traced(removed.Trace, fmt.Sprintf("Queued %s message from %s deleted", q.sink, removed.Sender)))
+			return true
+		}
+	}
+	return false
+}
+
+// purgeQueues empties the retry queues, marking their deliveries failed,
+// and returns how many messages were removed.
+func (a *App) purgeQueues() int {
+	n := 0
+	for _, q := range a.retryQueues() {
+		q.mu.Lock()
+		purged := *q.messages
+		*q.messages = nil
+		q.mu.Unlock()
+		for _, msg := range purged {
+			a.receipts.set(msg.ID, q.sink, deliveryFailed)
+		}
+		n += len(purged)
+	}
+	if n > 0 {
+		a.logger.Log("info", fmt.Sprintf("Retry queues purged, %d message(s) deleted", n))
+	}
+	return n
+}
+
+// handleQueue returns the queue panel as an HTML partial.
+func (a *App) handleQueue(w http.ResponseWriter, r *http.Request) {
+	a.renderQueueList(w, "", "")
+}
+
+// handleRetryQueued sends a queued message now and returns the updated
+// panel.
+func (a *App) handleRetryQueued(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil || !a.retryQueuedNow(id) {
+		a.renderQueueList(w, "", "Message no longer queued")
+		return
+	}
+	a.renderQueueList(w, "Message will be sent now", "")
+}
+
+// handleDeleteQueued removes a queued message and returns the updated
+// panel.
+func (a *App) handleDeleteQueued(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil || !a.deleteQueued(id) {
+		a.renderQueueList(w, "", "Message no longer queued")
+		return
+	}
+	a.renderQueueList(w, "", "")
+}
+
+// handlePurgeQueue empties the retry queues and returns the empty panel.
+func (a *App) handlePurgeQueue(w http.ResponseWriter, r *http.Request) {
+	n := a.purgeQueues()
+	a.renderQueueList(w, fmt.Sprintf("%d queued message(s) deleted", n), "")
+}
+
+func (a *App) renderQueueList(w http.ResponseWriter, message, errMsg string) {
+	tmpl, err := a.parseTemplates("templates/partials/queue_list.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "queue-list", a.queueData(message, errMsg)); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// queueData is the queue panel's data.
+func (a *App) queueData(message, errMsg string) map[string]interface{} {
+	return map[string]interface{}{
+		"Queue":   a.queueList(),
+		"Message": message,
+		"Error":   errMsg,
+	}
+}
+
+// handleAPIQueue returns the queued messages.
+func (a *App) handleAPIQueue(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.queueList())
+}
+
+// handleAPIRetryQueued makes a queued message due now.
+func (a *App) handleAPIRetryQueued(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid queue ID")
+		return
+	}
+	if !a.retryQueuedNow(id) {
+		writeJSONError(w, http.StatusNotFound, "Message not queued")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIDeleteQueued removes a queued message without sending it.
+func (a *App) handleAPIDeleteQueued(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid queue ID")
+		return
+	}
+	if !a.deleteQueued(id) {
+		writeJSONError(w, http.StatusNotFound, "Message not queued")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIPurgeQueue empties the retry queues.
+func (a *App) handleAPIPurgeQueue(w http.ResponseWriter, r *http.Request) {
+	a.purgeQueues()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupQueueApp returns an app with idle Discord and forward queues, which
+// hold messages without sending them.
+func setupQueueApp() *App {
+	a := setupTestApp()
+	a.receipts = newReceiptTable(maxReceipts)
+	a.discordQueue = &DiscordQueue{notify: make(chan struct{}, 1)}
+	a.forwardQueue = &ForwardQueue{notify: make(chan struct{}, 1)}
+	return a
+}
+
+func TestQueueManagement(t *testing.T) {
+	a := setupQueueApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	retryAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	id := a.receipts.add("Conan", "", "")
+	a.discordQueue.Add(QueuedMessage{ID: id, Sender: "Conan", Message: "By Crom!", RetryAt: retryAt, Attempts: 2})
+	a.forwardQueue.Add(QueuedMessage{Sender: "Valeria", Message: "Hold the line"})
+	<-a.discordQueue.notify
+
+	list := a.queueList()
+	if list.Count != 2 || list.NextRetry == nil || !list.NextRetry.Equal(retryAt) {
+		t.Fatalf("queue = %+v", list)
+	}
+	discord, forward := list.Messages[0], list.Messages[1]
+	if discord.Queue != sinkDiscord || discord.Attempts != 2 || forward.Queue != sinkForward || discord.ID == forward.ID {
+		t.Fatalf("queued messages = %+v", list.Messages)
+	}
+
+	if !a.retryQueuedNow(discord.ID) {
+		t.Fatal("retry now: message not found")
+	}
+	if a.queueList().Messages[0].RetryAt != nil {
+		t.Error("retry now left the retry time")
+	}
+	select {
+	case <-a.discordQueue.notify:
+	default:
+		t.Error("retry now didn't wake the queue")
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/queue/x", nil)
+	req.SetPathValue("id", strconv.FormatUint(discord.ID, 10))
+	rec := httptest.NewRecorder()
+	a.handleDeleteQueued(rec, req)
+	if body := rec.Body.String(); strings.Contains(body, "By Crom!") || !strings.Contains(body, "Hold the line") {
+		t.Errorf("queue panel after delete: %s", body)
+	}
+	if receipt, _ := a.receipts.get(id); receipt.Sinks[sinkDiscord] != deliveryFailed {
+		t.Errorf("deleted message's receipt = %+v", receipt)
+	}
+	if a.retryQueuedNow(discord.ID) || a.deleteQueued(discord.ID) {
+		t.Error("deleted message still found")
+	}
+}
+
+func TestAPIQueue(t *testing.T) {
+	a := setupQueueApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.discordQueue.Add(QueuedMessage{Sender: "Conan", Message: "By Crom!"})
+	a.forwardQueue.Add(QueuedMessage{Sender: "Valeria", Message: "Hold the line"})
+	mux := apiTestServer(a)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/queue", nil))
+	var list apiQueueList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || list.Count != 2 {
+		t.Fatalf("queue = %+v, %v", list, err)
+	}
+
+	id := strconv.FormatUint(list.Messages[0].ID, 10)
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"retry", "POST", "/api/v1/queue/" + id + "/retry", http.StatusNoContent},
+		{"retry unknown", "POST", "/api/v1/queue/999999/retry", http.StatusNotFound},
+		{"delete invalid ID", "DELETE", "/api/v1/queue/abc", http.StatusBadRequest},
+		{"delete", "DELETE", "/api/v1/queue/" + id, http.StatusNoContent},
+		{"delete again", "DELETE", "/api/v1/queue/" + id, http.StatusNotFound},
+		{"purge", "DELETE", "/api/v1/queue", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+	if n := a.queueList().Count; n != 0 {
+		t.Errorf("expected the queues to be purged, got %d", n)
+	}
+}
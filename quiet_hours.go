@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// What happens to Discord posts during quiet hours: skipped (the default),
+// or queued and posted once quiet hours end. Either way messages are still
+// logged and forwarded.
+const (
+	quietSkip  = "skip"
+	quietQueue = "queue"
+)
+
+// parseQuietHours parses a daily quiet period such as "02:00-08:00", in
+// local time, into its start and end as minutes after midnight. A period
+// ending before it starts, such as "22:00-06:00", runs past midnight.
+func parseQuietHours(s string) (start, end int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("Quiet hours must look like 02:00-08:00")
+	}
+	startTime, err1 := time.Parse("15:04", strings.TrimSpace(from))
+	endTime, err2 := time.Parse("15:04", strings.TrimSpace(to))
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("Quiet hours must look like 02:00-08:00")
+	}
+	start = startTime.Hour()*60 + startTime.Minute()
+	end = endTime.Hour()*60 + endTime.Minute()
+	if start == end {
+		return 0, 0, fmt.Errorf("Quiet hours must start and end at different times")
+	}
+	return start, end, nil
+}
+
+// checkQuietHours reports invalid quiet hours settings.
+func checkQuietHours(c *AppConfig) error {
+	if c.QuietHours != "" {
+		if _, _, err := parseQuietHours(c.QuietHours); err != nil {
+			return err
+		}
+	}
+	switch c.QuietHoursPolicy {
+	case "", quietSkip, quietQueue:
+		return nil
+	}
+	return fmt.Errorf("Unknown quiet hours policy %q", c.QuietHoursPolicy)
+}
+
+// quietUntil reports whether now falls in the configured quiet hours, and
+// if so when they end.
+func quietUntil(cfg *AppConfig, now time.Time) (time.Time, bool) {
+	if cfg.QuietHours == "" {
+		return time.Time{}, false
+	}
+	start, end, err := parseQuietHours(cfg.QuietHours)
+	if err != nil {
+		return time.Time{}, false
+	}
+	endOn := func(days int) time.Time {
+		return time.Date(now.Year(), now.Month(), now.Day()+days, end/60, end%60, 0, 0, now.Location())
+	}
+	minute := now.Hour()*60 + now.Minute()
+	switch {
+	case start < end && minute >= start && minute < end:
+		return endOn(0), true
+	case start > end && minute >= start:
+		return endOn(1), true
+	case start > end && minute < end:
+		return endOn(0), true
+	}
+	return time.Time{}, false
+}
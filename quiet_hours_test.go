@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietUntil(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2024, 3, 9, hour, minute, 0, 0, time.Local) }
+	tests := []struct {
+		hours string
+		now   time.Time
+		quiet bool
+		until time.Time
+	}{
+		{"02:00-08:00", at(1, 59), false, time.Time{}},
+		{"02:00-08:00", at(2, 0), true, at(8, 0)},
+		{"02:00-08:00", at(7, 59), true, at(8, 0)},
+		{"02:00-08:00", at(8, 0), false, time.Time{}},
+		{"22:00-06:30", at(23, 15), true, time.Date(2024, 3, 10, 6, 30, 0, 0, time.Local)},
+		{"22:00-06:30", at(3, 0), true, at(6, 30)},
+		{"22:00-06:30", at(12, 0), false, time.Time{}},
+		{"", at(3, 0), false, time.Time{}},
+	}
+	for _, tt := range tests {
+		until, quiet := quietUntil(&AppConfig{QuietHours: tt.hours}, tt.now)
+		if quiet != tt.quiet || !until.Equal(tt.until) {
+			t.Errorf("quietUntil(%q, %s) = %s, %v; want %s, %v", tt.hours, tt.now.Format("15:04"), until, quiet, tt.until, tt.quiet)
+		}
+	}
+}
+
+func TestCheckQuietHours(t *testing.T) {
+	tests := []struct {
+		cfg     AppConfig
+		wantErr bool
+	}{
+		{cfg: AppConfig{}},
+		{cfg: AppConfig{QuietHours: "02:00-08:00", QuietHoursPolicy: quietQueue}},
+		{cfg: AppConfig{QuietHours: " 22:00 - 06:00 "}},
+		{cfg: AppConfig{QuietHours: "2am-8am"}, wantErr: true},
+		{cfg: AppConfig{QuietHours: "02:00"}, wantErr: true},
+		{cfg: AppConfig{QuietHours: "02:00-02:00"}, wantErr: true},
+		{cfg: AppConfig{QuietHoursPolicy: "mute"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := checkQuietHours(&tt.cfg); (err != nil) != tt.wantErr {
+			t.Errorf("checkQuietHours(%q, %q) = %v", tt.cfg.QuietHours, tt.cfg.QuietHoursPolicy, err)
+		}
+	}
+}
+
+// quietNow returns quiet hours that started an hour ago and end in an hour.
+func quietNow() string {
+	now := time.Now()
+	return now.Add(-time.Hour).Format("15:04") + "-" + now.Add(time.Hour).Format("15:04")
+}
+
+func TestProcessMessage_QuietHours(t *testing.T) {
+	for _, policy := range []string{quietSkip, quietQueue} {
+		t.Run(policy, func(t *testing.T) {
+			bot := newFakeDiscordBot(t)
+			a := setupTestApp()
+			a.discordQueue = NewDiscordQueue(a.logger, nil)
+			defer func() { a.discordQueue.Stop(); a.sseBroker.Stop(); a.failureBroker.Stop() }()
+			tmpDir := t.TempDir()
+			a.config.EnableDiscord = true
+			a.config.DiscordMode = discordModeBot
+			a.config.BotToken = "secret"
+			a.config.BotChannelID = "100"
+			a.config.EnableLocalSave = true
+			a.config.Path = tmpDir
+			a.config.QuietHours = quietNow()
+			a.config.QuietHoursPolicy = policy
+
+			a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "Late night raid"})
+			waitForDeliveries(t, a)
+
+			if got := bot.contents("100"); len(got) != 0 {
+				t.Errorf("posted during quiet hours: %q", got)
+			}
+			data, err := os.ReadFile(generateLogFilename(tmpDir, "txt"))
+			if err != nil || !strings.Contains(string(data), "Late night raid") {
+				t.Errorf("message not logged: %q, %v", data, err)
+			}
+			queued := a.queueList()
+			if wantQueued := policy == quietQueue; (queued.Count == 1) != wantQueued {
+				t.Fatalf("queued %d message(s), want queued: %v", queued.Count, wantQueued)
+			}
+			if policy == quietQueue && (queued.NextRetry == nil || time.Until(*queued.NextRetry) < 30*time.Minute) {
+				t.Errorf("queued until %v, want the end of quiet hours", queued.NextRetry)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Rate limit policies: drop messages over the limit, or hold them until
+// the limit allows them (up to rateLimitMaxDelay) before dropping.
+const (
+	rateLimitDrop  = "drop"
+	rateLimitDelay = "delay"
+)
+
+const (
+	// defaultRateLimitBurst is how many messages may arrive back to back
+	// before the per-minute rate applies.
+	defaultRateLimitBurst = 10
+	// rateLimitMaxDelay caps how long the delay policy holds a message.
+	rateLimitMaxDelay = 5 * time.Second
+	// rateLimitIdle is how long an unused bucket is kept.
+	rateLimitIdle = 10 * time.Minute
+)
+
+// tokenBucket is the state of one rate-limited key. tokens may go negative
+// while delayed messages wait for their turn.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a set of token buckets keyed by client IP or sender.
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+	// warned records when a drop was last logged per key, so a flood
+	// produces one warning a minute rather than one per message.
+	warned map[string]time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		warned:  make(map[string]time.Time),
+	}
+}
+
+// reserve takes a token for key and returns how long the caller must wait
+// before using it. If that is longer than maxWait, nothing is taken and ok
+// is false.
+func (l *rateLimiter) reserve(key string, perMinute, burst int, maxWait time.Duration, now time.Time) (wait time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	rate := float64(perMinute) / 60 // tokens per second
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	if wait > maxWait {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// shouldWarn reports whether a drop for key should be logged now.
+func (l *rateLimiter) shouldWarn(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.warned[key]) < time.Minute {
+		return false
+	}
+	l.warned[key] = now
+	return true
+}
+
+// prune forgets buckets that have been idle long enough to be full again.
+// Called with mu held.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > rateLimitIdle {
+			delete(l.buckets, key)
+		}
+	}
+	for key, at := range l.warned {
+		if now.Sub(at) > time.Minute {
+			delete(l.warned, key)
+		}
+	}
+}
+
+// allowMessage applies the per-IP and per-sender rate limits to a message
+// from remoteAddr. With the delay policy it may block until the message is
+// allowed. It returns false if the message should be dropped.
+func (a *App) allowMessage(ctx context.Context, remoteAddr, sender string) bool {
+	a.configMu.RLock()
+	ipLimit := a.config.RateLimit
+	senderLimit := a.config.SenderRateLimit
+	burst := a.config.RateLimitBurst
+	policy := a.config.RateLimitPolicy
+	a.configMu.RUnlock()
+
+	if ipLimit <= 0 && senderLimit <= 0 {
+		return true
+	}
+	if burst <= 0 {
+		burst = defaultRateLimitBurst
+	}
+	maxWait := time.Duration(0)
+	if policy == rateLimitDelay {
+		maxWait = rateLimitMaxDelay
+	}
+
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+
+	checks := []struct {
+		key, label string
+		limit      int
+	}{
+		{"ip:" + ip, "client " + ip, ipLimit},
+		{"sender:" + sender, "sender " + sender, senderLimit},
+	}
+
+	var wait time.Duration
+	for _, check := range checks {
+		if check.limit <= 0 {
+			continue
+		}
+		now := time.Now()
+		w, ok := a.rateLimiter.reserve(check.key, check.limit, burst, maxWait, now)
+		if !ok {
+			if a.rateLimiter.shouldWarn(check.key, now) {
+				a.logger.Log("warning", fmt.Sprintf("Rate limit exceeded by %s (%d/min), dropping messages", check.label, check.limit))
+			}
+			return false
+		}
+		wait = max(wait, w)
+	}
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
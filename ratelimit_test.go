@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	l := newRateLimiter()
+	now := time.Date(2026, 10, 17, 21, 0, 0, 0, time.UTC)
+
+	// A burst of 3 is allowed immediately, then the 60/min rate applies.
+	for i := 0; i < 3; i++ {
+		if wait, ok := l.reserve("ip:a", 60, 3, 0, now); !ok || wait != 0 {
+			t.Fatalf("message %d: expected immediate allow, got wait=%v ok=%v", i, wait, ok)
+		}
+	}
+	if _, ok := l.reserve("ip:a", 60, 3, 0, now); ok {
+		t.Error("expected drop once the burst is used up")
+	}
+	if _, ok := l.reserve("ip:b", 60, 3, 0, now); !ok {
+		t.Error("other keys should have their own bucket")
+	}
+
+	// One token refills per second at 60/min.
+	if _, ok := l.reserve("ip:a", 60, 3, 0, now.Add(time.Second)); !ok {
+		t.Error("expected a token after one second")
+	}
+
+	// With a max wait, the caller is told how long to hold the message.
+	wait, ok := l.reserve("ip:a", 60, 3, 5*time.Second, now.Add(time.Second))
+	if !ok || wait != time.Second {
+		t.Errorf("expected to wait 1s, got wait=%v ok=%v", wait, ok)
+	}
+	wait, ok = l.reserve("ip:a", 60, 3, 5*time.Second, now.Add(time.Second))
+	if !ok || wait != 2*time.Second {
+		t.Errorf("expected to wait 2s behind the delayed message, got wait=%v ok=%v", wait, ok)
+	}
+}
+
+func TestAllowMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		rateLimit   int
+		senderLimit int
+		messages    []string // sender per message, all from one client
+		allowed     int
+	}{
+		{name: "disabled", messages: []string{"A", "A", "A", "A"}, allowed: 4},
+		{name: "per client", rateLimit: 1, messages: []string{"A", "B", "C"}, allowed: 2},
+		{name: "per sender", senderLimit: 1, messages: []string{"A", "A", "A", "B"}, allowed: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := setupTestApp()
+			defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+			a.rateLimiter = newRateLimiter()
+			a.config.RateLimit = tt.rateLimit
+			a.config.SenderRateLimit = tt.senderLimit
+			a.config.RateLimitBurst = 2
+
+			allowed := 0
+			for _, sender := range tt.messages {
+				if a.allowMessage(context.Background(), "192.0.2.1:5555", sender) {
+					allowed++
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("expected %d allowed, got %d", tt.allowed, allowed)
+			}
+		})
+	}
+}
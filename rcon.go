@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Source RCON packet types, as used by Conan Exiles and most Unreal and
+// Source engine servers.
+const (
+	rconAuth          = 3
+	rconAuthResponse  = 2
+	rconExecCommand   = 2
+	rconResponseValue = 0
+)
+
+const (
+	// defaultRCONCommand broadcasts a message to everyone on the server.
+	defaultRCONCommand = "broadcast {message}"
+	rconTimeout        = 10 * time.Second
+	// rconMaxPacket is the largest packet a server may send.
+	rconMaxPacket = 4096 + 10
+)
+
+// rconCommandTemplate returns the configured announcement command, or the
+// default.
+func rconCommandTemplate(config *AppConfig) string {
+	if config.RCONCommand == "" {
+		return defaultRCONCommand
+	}
+	return config.RCONCommand
+}
+
+// rconExec connects to the RCON server at addr, logs in and runs command,
+// returning the server's reply.
+func rconExec(ctx context.Context, addr, password, command string) (string, error) {
+	dialer := &net.Dialer{Timeout: rconTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("connecting to RCON: %w", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(rconTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if err := writeRCONPacket(conn, 1, rconAuth, password); err != nil {
+		return "", err
+	}
+	// Some servers send an empty response value before the auth response.
+	for {
+		id, typ, _, err := readRCONPacket(conn)
+		if err != nil {
+			return "", err
+		}
+		if typ != rconAuthResponse {
+			continue
+		}
+		if id == -1 {
+			return "", fmt.Errorf("RCON password rejected")
+		}
+		break
+	}
+
+	if err := writeRCONPacket(conn, 2, rconExecCommand, command); err != nil {
+		return "", err
+	}
+	for {
+		id, typ, body, err := readRCONPacket(conn)
+		if err != nil {
+			return "", err
+		}
+		if id == 2 && typ == rconResponseValue {
+			return body, nil
+		}
+	}
+}
+
+// writeRCONPacket sends one packet: little-endian size, ID and type, then
+// the body and two NUL bytes.
+func writeRCONPacket(w io.Writer, id, typ int32, body string) error {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, int32(len(body)+10))
+	binary.Write(&buf, binary.LittleEndian, id)
+	binary.Write(&buf, binary.LittleEndian, typ)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("sending RCON packet: %w", err)
+	}
+	return nil
+}
+
+// readRCONPacket reads one packet and returns its ID, type and body.
+func readRCONPacket(r io.Reader) (int32, int32, string, error) {
+	var size int32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return 0, 0, "", fmt.Errorf("reading RCON packet: %w", err)
+	}
+	if size < 10 || size > rconMaxPacket {
+		return 0, 0, "", fmt.Errorf("invalid RCON packet size %d", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, 0, "", fmt.Errorf("reading RCON packet: %w", err)
+	}
+	id := int32(binary.LittleEndian.Uint32(data[0:4]))
+	typ := int32(binary.LittleEndian.Uint32(data[4:8]))
+	body := strings.TrimRight(string(data[8:]), "\x00")
+	return id, typ, body, nil
+}
+
+// announce broadcasts message on the game server through RCON.
+func (a *App) announce(ctx context.Context, message string) error {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	if !cfg.EnableRCON {
+		return fmt.Errorf("RCON is not enabled")
+	}
+	message = strings.TrimSpace(strings.ReplaceAll(message, "\n", " "))
+	if message == "" {
+		return fmt.Errorf("announcement text required")
+	}
+	command := strings.ReplaceAll(rconCommandTemplate(&cfg), "{message}", message)
+	if _, err := rconExec(ctx, cfg.RCONAddr, cfg.RCONPassword, command); err != nil {
+		a.logger.Log("error", fmt.Sprintf("RCON announcement failed: %v", err))
+		return err
+	}
+	a.logger.Log("info", fmt.Sprintf("Announced on the game server: %s", message))
+	return nil
+}
+
+// announceLifecycle sends the configured start or stop announcement, if
+// any.
+func (a *App) announceLifecycle(starting bool) {
+	a.configMu.RLock()
+	enabled := a.config.EnableRCON
+	message := a.config.RCONStopMessage
+	if starting {
+		message = a.config.RCONStartMessage
+	}
+	a.configMu.RUnlock()
+
+	if !enabled || message == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rconTimeout)
+	defer cancel()
+	a.announce(ctx, message)
+}
+
+// handleAnnounce broadcasts the "message" form value on the game server and
+// reports the result as an alert.
+func (a *App) handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	if err := a.announce(r.Context(), r.FormValue("message")); err != nil {
+		fmt.Fprintf(w, `<div class="alert error">Announcement failed: %s</div>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
+	fmt.Fprint(w, `<div class="alert success">Announcement sent.</div>`)
+}
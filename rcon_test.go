@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRCON is a Source RCON server that records the commands it runs.
+type fakeRCON struct {
+	addr     string
+	password string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeRCON(t *testing.T, password string) *fakeRCON {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRCON{addr: ln.Addr().String(), password: password}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRCON) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		id, typ, body, err := readRCONPacket(conn)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case rconAuth:
+			// Like Source servers, send an empty response value first.
+			writeRCONPacket(conn, id, rconResponseValue, "")
+			if body != f.password {
+				id = -1
+			}
+			writeRCONPacket(conn, id, rconAuthResponse, "")
+		case rconExecCommand:
+			f.mu.Lock()
+			f.commands = append(f.commands, body)
+			f.mu.Unlock()
+			writeRCONPacket(conn, id, rconResponseValue, "ok")
+		}
+	}
+}
+
+func (f *fakeRCON) ran() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func TestRCONExec(t *testing.T) {
+	srv := newFakeRCON(t, "hunter2")
+
+	reply, err := rconExec(context.Background(), srv.addr, "hunter2", "broadcast hi")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply != "ok" {
+		t.Errorf("expected reply %q, got %q", "ok", reply)
+	}
+	if got := srv.ran(); len(got) != 1 || got[0] != "broadcast hi" {
+		t.Errorf("unexpected commands %q", got)
+	}
+
+	if _, err := rconExec(context.Background(), srv.addr, "wrong", "broadcast hi"); err == nil || !strings.Contains(err.Error(), "password rejected") {
+		t.Errorf("expected a rejected password, got %v", err)
+	}
+}
+
+func TestHandleAnnounce(t *testing.T) {
+	srv := newFakeRCON(t, "hunter2")
+
+	tests := []struct {
+		name    string
+		config  AppConfig
+		message string
+		want    string
+		command string
+	}{
+		{
+			name:    "default command",
+			config:  AppConfig{EnableRCON: true, RCONAddr: srv.addr, RCONPassword: "hunter2"},
+			message: "Server restart in 5 minutes",
+			want:    "Announcement sent",
+			command: "broadcast Server restart in 5 minutes",
+		},
+		{
+			name:    "custom command",
+			config:  AppConfig{EnableRCON: true, RCONAddr: srv.addr, RCONPassword: "hunter2", RCONCommand: "say [RP] {message}"},
+			message: "Hello",
+			want:    "Announcement sent",
+			command: "say [RP] Hello",
+		},
+		{
+			name:    "disabled",
+			config:  AppConfig{},
+			message: "Hello",
+			want:    "RCON is not enabled",
+		},
+		{
+			name:    "empty",
+			config:  AppConfig{EnableRCON: true, RCONAddr: srv.addr, RCONPassword: "hunter2"},
+			message: "  ",
+			want:    "announcement text required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := setupTestApp()
+			defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+			*a.config = tt.config
+			before := len(srv.ran())
+
+			form := url.Values{"message": {tt.message}}
+			req := httptest.NewRequest("POST", "/api/rcon/announce", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			a.handleAnnounce(rec, req)
+
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("expected %q in response, got %q", tt.want, rec.Body.String())
+			}
+			commands := srv.ran()[before:]
+			if tt.command == "" && len(commands) != 0 {
+				t.Errorf("expected no command, got %q", commands)
+			}
+			if tt.command != "" && (len(commands) != 1 || commands[0] != tt.command) {
+				t.Errorf("expected command %q, got %q", tt.command, commands)
+			}
+		})
+	}
+}
+
+func TestAnnounceLifecycle(t *testing.T) {
+	srv := newFakeRCON(t, "pw")
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableRCON = true
+	a.config.RCONAddr = srv.addr
+	a.config.RCONPassword = "pw"
+	a.config.RCONStopMessage = "Logging paused"
+
+	a.announceLifecycle(true)
+	a.announceLifecycle(false)
+	if got := srv.ran(); len(got) != 1 || got[0] != "broadcast Logging paused" {
+		t.Errorf("expected only the stop announcement, got %q", got)
+	}
+}
+
+func TestRelayToGame_RCON(t *testing.T) {
+	srv := newFakeRCON(t, "pw")
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	cfg := &AppConfig{EnableRCON: true, RCONAddr: srv.addr, RCONPassword: "pw"}
+
+	if err := a.relayToGame(context.Background(), cfg, "Alice", "see you\nat the gate"); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.ran(); len(got) != 1 || got[0] != "broadcast Alice: see you at the gate" {
+		t.Errorf("unexpected command %q", got)
+	}
+}
+
+func TestValidate_RCON(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AppConfig
+		wantErr string
+	}{
+		{"valid", AppConfig{EnableRCON: true, RCONAddr: "127.0.0.1:25575"}, ""},
+		{"missing port", AppConfig{EnableRCON: true, RCONAddr: "127.0.0.1"}, "RCON address must be host:port"},
+		{"command without placeholder", AppConfig{EnableRCON: true, RCONAddr: "127.0.0.1:25575", RCONCommand: "broadcast"}, "RCON command must contain {message}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.EnableLocalSave, cfg.Path = true, "logs"
+			err := cfg.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Delivery states tracked per sink for each received message.
+const (
+	deliveryPending = "pending"
+	deliverySent    = "sent"
+	deliveryFailed  = "failed"
+)
+
+// Sinks a message can be delivered to.
+const (
+	sinkDiscord = "discord"
+	sinkFile    = "file"
+	sinkForward = "forward"
+)
+
+const (
+	// maxReceipts bounds the receipt table; the oldest receipts are
+	// dropped first.
+	maxReceipts = 1000
+	// receiptsFile (next to the config file) holds the receipt table
+	// between runs when PersistReceipts is set.
+	receiptsFile = "receipts.json"
+)
+
+// Receipt records the delivery status of one received message per sink.
+type Receipt struct {
+	ID       string            `json:"id"`
+	Received time.Time         `json:"received"`
+	Sender   string            `json:"sender"`
+	Source   string            `json:"source,omitempty"`
+	Trace    string            `json:"trace,omitempty"`
+	Sinks    map[string]string `json:"sinks"`
+	// DiscordMessages are the messages the Discord bot posted for this
+	// message, used to read reactions. Webhook posts leave it empty.
+	DiscordMessages []DiscordMessageRef `json:"discordMessages,omitempty"`
+	// Entry is the message as written to the log file, for annotating it.
+	Entry *LogEntry `json:"entry,omitempty"`
+}
+
+// receiptTable keeps the most recent receipts in arrival order. A nil
+// table ignores all updates.
+type receiptTable struct {
+	mu       sync.Mutex
+	receipts map[string]*Receipt
+	order    []string
+	max      int
+}
+
+func newReceiptTable(max int) *receiptTable {
+	return &receiptTable{receipts: make(map[string]*Receipt), max: max}
+}
+
+// newMessageID returns a random 16-character hex message ID.
+func newMessageID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// add creates a receipt for a new message and returns its ID.
+func (t *receiptTable) add(sender, source, trace string) string {
+	if t == nil {
+		return ""
+	}
+	id := newMessageID()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.insert(&Receipt{ID: id, Received: time.Now(), Sender: sender, Source: source, Trace: trace, Sinks: make(map[string]string)})
+	return id
+}
+
+func (t *receiptTable) insert(r *Receipt) {
+	if len(t.order) >= t.max {
+		delete(t.receipts, t.order[0])
+		t.order = t.order[1:]
+	}
+	t.receipts[r.ID] = r
+	t.order = append(t.order, r.ID)
+}
+
+// set records the delivery status of a message for one sink. Unknown IDs
+// (evicted, or from before a restart without persistence) are ignored.
+func (t *receiptTable) set(id, sink, status string) {
+	if t == nil || id == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.receipts[id]; ok {
+		r.Sinks[sink] = status
+	}
+}
+
+// addDiscordMessages records the Discord messages posted for a message.
+func (t *receiptTable) addDiscordMessages(id string, refs []DiscordMessageRef) {
+	if t == nil || id == "" || len(refs) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.receipts[id]; ok {
+		r.DiscordMessages = append(r.DiscordMessages, refs...)
+	}
+}
+
+// setEntry records the log entry written for a message.
+func (t *receiptTable) setEntry(id string, entry LogEntry) {
+	if t == nil || id == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.receipts[id]; ok {
+		r.Entry = &entry
+	}
+}
+
+// remove drops the receipts match selects and returns them.
+func (t *receiptTable) remove(match func(r *Receipt) bool) []Receipt {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var removed []Receipt
+	kept := t.order[:0]
+	for _, id := range t.order {
+		if r := t.receipts[id]; match(r) {
+			removed = append(removed, r.copy())
+			delete(t.receipts, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	t.order = kept
+	return removed
+}
+
+// get returns a copy of the receipt with the given ID.
+func (t *receiptTable) get(id string) (Receipt, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.receipts[id]
+	if !ok {
+		return Receipt{}, false
+	}
+	return r.copy(), true
+}
+
+func (r *Receipt) copy() Receipt {
+	c := *r
+	c.DiscordMessages = append([]DiscordMessageRef(nil), r.DiscordMessages...)
+	c.Sinks = make(map[string]string, len(r.Sinks))
+	for sink, status := range r.Sinks {
+		c.Sinks[sink] = status
+	}
+	return c
+}
+
+// list returns up to limit receipts, newest first. A non-empty status
+// keeps only receipts with at least one sink in that state.
+func (t *receiptTable) list(status string, limit int) []Receipt {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]Receipt, 0)
+	for i := len(t.order) - 1; i >= 0 && len(result) < limit; i-- {
+		r := t.receipts[t.order[i]]
+		if status != "" && !r.hasStatus(status) {
+			continue
+		}
+		result = append(result, r.copy())
+	}
+	return result
+}
+
+func (r *Receipt) hasStatus(status string) bool {
+	for _, s := range r.Sinks {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// summary counts sink deliveries per status across all receipts.
+func (t *receiptTable) summary() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := map[string]int{deliveryPending: 0, deliverySent: 0, deliveryFailed: 0}
+	for _, r := range t.receipts {
+		for _, status := range r.Sinks {
+			counts[status]++
+		}
+	}
+	return counts
+}
+
+// save writes the table to path.
+func (t *receiptTable) save(path string) error {
+	t.mu.Lock()
+	receipts := make([]*Receipt, 0, len(t.order))
+	for _, id := range t.order {
+		receipts = append(receipts, t.receipts[id])
+	}
+	data, err := json.MarshalIndent(receipts, "", "  ")
+	t.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding receipts: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating receipts directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("writing receipts: %w", err)
+	}
+	return nil
+}
+
+// load adds the receipts saved at path. A missing file is not an error.
+func (t *receiptTable) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading receipts: %w", err)
+	}
+	var receipts []*Receipt
+	if err := json.Unmarshal(data, &receipts); err != nil {
+		return fmt.Errorf("decoding receipts: %w", err)
+	}
+	sort.SliceStable(receipts, func(i, j int) bool { return receipts[i].Received.Before(receipts[j].Received) })
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, r := range receipts {
+		if r.ID == "" {
+			continue
+		}
+		if r.Sinks == nil {
+			r.Sinks = make(map[string]string)
+		}
+		t.insert(r)
+	}
+	return nil
+}
+
+// handleMessageStatus returns delivery receipts as JSON: a single receipt
+// for ?id= (with its Discord reactions in bot mode), otherwise a per-status summary and the most recent receipts,
+// optionally filtered by ?status= and capped by ?limit= (default 100).
+func (a *App) handleMessageStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+
+	if id := query.Get("id"); id != "" {
+		receipt, ok := a.receipts.get(id)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "message not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(a.withReactions(r.Context(), receipt))
+		return
+	}
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > maxReceipts {
+		limit = 100
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"summary":  a.receipts.summary(),
+		"messages": a.receipts.list(query.Get("status"), limit),
+	})
+}
+
+// receiptStatus is a receipt with the reactions on its Discord messages.
+// Any reaction counts as an acknowledgement.
+type receiptStatus struct {
+	Receipt
+	Reactions    map[string]int `json:"reactions,omitempty"`
+	Acknowledged bool           `json:"acknowledged,omitempty"`
+}
+
+// withReactions adds the reactions on the receipt's bot-posted Discord
+// messages. Receipts without bot messages, or a failed lookup, are
+// returned as they are.
+func (a *App) withReactions(ctx context.Context, receipt Receipt) receiptStatus {
+	status := receiptStatus{Receipt: receipt}
+	a.configMu.RLock()
+	token := a.config.BotToken
+	a.configMu.RUnlock()
+	if token == "" || len(receipt.DiscordMessages) == 0 {
+		return status
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	status.Reactions = make(map[string]int)
+	for _, ref := range receipt.DiscordMessages {
+		reactions, err := discordReactions(ctx, token, ref)
+		if err != nil {
+			slog.Warn("Failed to read Discord reactions", "message", ref.MessageID, "err", err)
+			continue
+		}
+		for emoji, count := range reactions {
+			status.Reactions[emoji] += count
+		}
+	}
+	status.Acknowledged = len(status.Reactions) > 0
+	return status
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestReceiptTable(t *testing.T) {
+	table := newReceiptTable(2)
+	first := table.add("Alice", "", "")
+	second := table.add("Bob", "Siptah", "")
+	table.set(second, sinkDiscord, deliverySent)
+	table.set(second, sinkFile, deliveryFailed)
+	third := table.add("Carol", "", "")
+	table.set(third, sinkForward, deliveryPending)
+	table.set(first, sinkDiscord, deliverySent) // evicted, ignored
+
+	if _, ok := table.get(first); ok {
+		t.Error("oldest receipt should be evicted")
+	}
+	got, ok := table.get(second)
+	if !ok || got.Sender != "Bob" || got.Source != "Siptah" || got.Sinks[sinkFile] != deliveryFailed {
+		t.Errorf("unexpected receipt: %+v", got)
+	}
+
+	tests := []struct {
+		status string
+		want   []string
+	}{
+		{"", []string{third, second}},
+		{deliveryFailed, []string{second}},
+		{deliveryPending, []string{third}},
+	}
+	for _, tt := range tests {
+		list := table.list(tt.status, 10)
+		if len(list) != len(tt.want) {
+			t.Fatalf("status %q: got %d receipts, want %d", tt.status, len(list), len(tt.want))
+		}
+		for i, id := range tt.want {
+			if list[i].ID != id {
+				t.Errorf("status %q: receipt %d is %s, want %s", tt.status, i, list[i].ID, id)
+			}
+		}
+	}
+
+	summary := table.summary()
+	if summary[deliverySent] != 1 || summary[deliveryFailed] != 1 || summary[deliveryPending] != 1 {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+
+	path := filepath.Join(t.TempDir(), receiptsFile)
+	if err := table.save(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := newReceiptTable(10)
+	if err := restored.load(path); err != nil {
+		t.Fatal(err)
+	}
+	if list := restored.list("", 10); len(list) != 2 || list[0].ID != third {
+		t.Errorf("unexpected restored receipts: %+v", list)
+	}
+}
+
+func TestCreateHandler_ReturnsReceipt(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.receipts = newReceiptTable(10)
+	a.config.EnableLocalSave = true
+	a.config.Path = t.TempDir()
+
+	rr := httptest.NewRecorder()
+	createHandler(a).ServeHTTP(rr, httptest.NewRequest("GET", "/message?sender=Alice&message=hi", nil))
+	waitForDeliveries(t, a)
+	var response map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response["id"] == "" {
+		t.Fatalf("expected a message ID, got %v", response)
+	}
+
+	rr = httptest.NewRecorder()
+	a.handleMessageStatus(rr, httptest.NewRequest("GET", "/api/messages/status?id="+response["id"], nil))
+	var receipt Receipt
+	if err := json.NewDecoder(rr.Body).Decode(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Sender != "Alice" || receipt.Sinks[sinkFile] != deliverySent {
+		t.Errorf("unexpected receipt: %+v", receipt)
+	}
+
+	rr = httptest.NewRecorder()
+	a.handleMessageStatus(rr, httptest.NewRequest("GET", "/api/messages/status?id=missing", nil))
+	if rr.Code != 404 {
+		t.Errorf("expected 404 for unknown ID, got %d", rr.Code)
+	}
+}
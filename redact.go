@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// minKnownSecret is the shortest configured secret replaced by value;
+// shorter ones would mangle ordinary words.
+const minKnownSecret = 4
+
+// secretPatterns find credentials in log text by their shape, whether or
+// not they are configured here: the token part of Discord webhook URLs
+// (keeping the webhook ID, which helps debugging), Discord bot tokens,
+// passwords in URLs, credentials in query strings and Authorization
+// header values.
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)(discord(?:app)?\.com/api/(?:v\d+/)?webhooks/\d+/)[\w-]+`), "${1}" + redactedSecret},
+	{regexp.MustCompile(`\b[\w-]{24,}\.[\w-]{6}\.[\w-]{27,}\b`), redactedSecret},
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), "${1}" + redactedSecret + "@"},
+	{regexp.MustCompile(`(?i)([?&](?:token|access_token|api_key|key|secret|password|sig|signature|x-amz-signature|x-amz-credential|x-amz-security-token)=)[^&\s"']+`), "${1}" + redactedSecret},
+	{regexp.MustCompile(`(?i)\b(Bearer|Bot) [\w.~+/-]{16,}=*`), "${1} " + redactedSecret},
+}
+
+// knownSecrets replaces the secrets of the current config (see
+// mapSecrets) wherever they appear. It is nil until setKnownSecrets runs.
+var knownSecrets atomic.Pointer[strings.Replacer]
+
+// setKnownSecrets makes redactSecrets replace the secrets of config.
+func setKnownSecrets(config *AppConfig) {
+	cfg := *config
+	var secrets []string
+	mapSecrets(&cfg, func(_, value string) string {
+		if len(value) >= minKnownSecret && !strings.HasPrefix(value, encryptedPrefix) {
+			secrets = append(secrets, value)
+		}
+		return value
+	})
+	// Longest first, so a URL is replaced whole rather than a password
+	// that happens to occur inside it.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		pairs = append(pairs, s, redactedSecret)
+	}
+	knownSecrets.Store(strings.NewReplacer(pairs...))
+}
+
+// redactSecrets returns s with webhook URLs, tokens and passwords replaced
+// by redactedSecret. Everything that leaves the app as log text, the live
+// log, failure entries and the application log, passes through it, so a
+// screenshot of the debug log doesn't give the webhook away.
+func redactSecrets(s string) string {
+	if r := knownSecrets.Load(); r != nil {
+		s = r.Replace(s)
+	}
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// redactAttr redacts the message and the string and error attributes of
+// application log records.
+func redactAttr(a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redactSecrets(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redactSecrets(err.Error()))
+		}
+	}
+	return a
+}
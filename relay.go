@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// relayPollInterval is how often the relay channel is checked for new
+// messages.
+const relayPollInterval = 3 * time.Second
+
+// relayEchoWindow is how long a relayed message is remembered, so the game
+// echoing it back to the logger isn't posted to Discord a second time.
+const relayEchoWindow = 2 * time.Minute
+
+// relaySource is the source passed on with relayed messages.
+const relaySource = "discord"
+
+// discordMessage is the part of a Discord message object the relay uses.
+type discordMessage struct {
+	ID        string `json:"id"`
+	Content   string `json:"content"`
+	WebhookID string `json:"webhook_id"`
+	Author    struct {
+		ID         string `json:"id"`
+		Username   string `json:"username"`
+		GlobalName string `json:"global_name"`
+		Bot        bool   `json:"bot"`
+	} `json:"author"`
+}
+
+// relayChannel returns the channel relayed to the game: the configured one,
+// or the bot's posting channel.
+func relayChannel(config *AppConfig) string {
+	if config.RelayChannelID != "" {
+		return config.RelayChannelID
+	}
+	return config.BotChannelID
+}
+
+// runRelay forwards messages posted in the relay channel to the game's
+// endpoint. The config is read on every tick so changes apply without a
+// restart. Only messages posted after the relay started (or after the
+// channel last changed) are relayed.
+func (a *App) runRelay() {
+	ticker := time.NewTicker(relayPollInterval)
+	defer ticker.Stop()
+
+	var channel, after string
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+		}
+
+		a.configMu.RLock()
+		cfg := *a.config
+		a.configMu.RUnlock()
+		if !cfg.EnableRelay || cfg.BotToken == "" || relayChannel(&cfg) == "" {
+			channel, after = "", ""
+			continue
+		}
+		if relayChannel(&cfg) != channel {
+			channel, after = relayChannel(&cfg), ""
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		last, err := a.pollRelay(ctx, &cfg, channel, after)
+		cancel()
+		if err != nil {
+			slog.Warn("Discord relay poll failed", "err", err)
+			continue
+		}
+		after = last
+	}
+}
+
+// pollRelay forwards the messages in channel newer than after and returns
+// the ID to continue from. With no starting point it only finds the newest
+// message, so history is never replayed. Messages from bots and webhooks
+// (including the logger's own posts) are skipped to avoid loops.
+func (a *App) pollRelay(ctx context.Context, cfg *AppConfig, channel, after string) (string, error) {
+	messages, err := listDiscordMessages(ctx, cfg.BotToken, channel, after)
+	if err != nil {
+		return after, err
+	}
+	if after == "" {
+		if len(messages) == 0 {
+			// Empty channel: start from the beginning next time.
+			return "0", nil
+		}
+		return messages[len(messages)-1].ID, nil
+	}
+
+	for _, msg := range messages {
+		if msg.Author.Bot || msg.WebhookID != "" || strings.TrimSpace(msg.Content) == "" {
+			after = msg.ID
+			continue
+		}
+		sender := msg.Author.GlobalName
+		if sender == "" {
+			sender = msg.Author.Username
+		}
+		if ch, ok := characterByDiscordID(cfg, msg.Author.ID); ok {
+			sender = ch.Name
+		}
+		if err := a.relayToGame(ctx, cfg, sender, msg.Content); err != nil {
+			// Stop here so the message is retried on the next poll.
+			a.logger.Log("error", fmt.Sprintf("Relaying Discord message to the game failed: %v", err))
+			return after, nil
+		}
+		a.relayEchoes.add(msg.Content, time.Now())
+		a.logger.Log("info", fmt.Sprintf("Relayed Discord message from %s to the game", sender))
+		after = msg.ID
+	}
+	return after, nil
+}
+
+// relayToGame sends a Discord message to the game endpoint, or, without
+// one, announces it through RCON as "sender: message".
+func (a *App) relayToGame(ctx context.Context, cfg *AppConfig, sender, message string) error {
+	if cfg.RelayURL != "" {
+		return forwardMessage(ctx, cfg.RelayURL, sender, message, relaySource)
+	}
+	command := strings.ReplaceAll(rconCommandTemplate(cfg), "{message}", sender+": "+strings.ReplaceAll(message, "\n", " "))
+	_, err := rconExec(ctx, cfg.RCONAddr, cfg.RCONPassword, command)
+	return err
+}
+
+// listDiscordMessages returns up to 100 messages in channel newer than
+// after, oldest first. With after empty it returns only the newest message.
+func listDiscordMessages(ctx context.Context, token, channel, after string) ([]discordMessage, error) {
+	u, err := url.Parse(discordAPI)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Discord URL: %w", err)
+	}
+	u = u.JoinPath("channels", channel, "messages")
+	u.User = url.UserPassword(discordModeBot, token)
+	query := url.Values{"limit": {"100"}}
+	if after == "" {
+		query.Set("limit", "1")
+	} else {
+		query.Set("after", after)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := newDiscordRequest(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating discord request: %w", err)
+	}
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending discord request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+	}
+
+	var messages []discordMessage
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		return nil, fmt.Errorf("decoding discord messages: %w", err)
+	}
+	// Discord returns newest first; IDs are snowflakes, which sort by
+	// length and then lexically.
+	sort.Slice(messages, func(i, j int) bool {
+		a, b := messages[i].ID, messages[j].ID
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return messages, nil
+}
+
+// echoFilter remembers recently relayed messages. A nil filter remembers
+// nothing.
+type echoFilter struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // message -> expiry
+}
+
+func newEchoFilter() *echoFilter {
+	return &echoFilter{seen: make(map[string]time.Time)}
+}
+
+// add remembers message until relayEchoWindow after now.
+func (f *echoFilter) add(message string, now time.Time) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for m, expiry := range f.seen {
+		if now.After(expiry) {
+			delete(f.seen, m)
+		}
+	}
+	f.seen[strings.TrimSpace(message)] = now.Add(relayEchoWindow)
+}
+
+// echo reports whether message was relayed recently, forgetting it so only
+// the first echo is suppressed.
+func (f *echoFilter) echo(message string, now time.Time) bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimSpace(message)
+	expiry, ok := f.seen[key]
+	if !ok {
+		return false
+	}
+	delete(f.seen, key)
+	return !now.After(expiry)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPollRelay(t *testing.T) {
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/channels/100/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("after") == "" {
+			w.Write([]byte(`[{"id":"10","content":"old","author":{"username":"bob"}}]`))
+			return
+		}
+		// Newest first, as Discord returns them.
+		w.Write([]byte(`[
+			{"id":"14","content":"hello","author":{"username":"alice","global_name":"Alice"}},
+			{"id":"13","content":"from a webhook","webhook_id":"7","author":{"username":"Logger"}},
+			{"id":"12","content":"from the bot","author":{"username":"Logger","bot":true}},
+			{"id":"9999999999","content":"later","author":{"username":"bob"}}
+		]`))
+	}))
+	defer discord.Close()
+	old := discordAPI
+	discordAPI = discord.URL
+	defer func() { discordAPI = old }()
+
+	var mu sync.Mutex
+	var relayed []string
+	game := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		relayed = append(relayed, r.URL.Query().Get("sender")+": "+r.URL.Query().Get("message"))
+		mu.Unlock()
+		if r.URL.Query().Get("source") != relaySource {
+			t.Errorf("expected source %q, got %q", relaySource, r.URL.Query().Get("source"))
+		}
+	}))
+	defer game.Close()
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.relayEchoes = newEchoFilter()
+	cfg := &AppConfig{EnableRelay: true, DiscordMode: discordModeBot, BotToken: "secret", BotChannelID: "100", RelayURL: game.URL}
+
+	after, err := a.pollRelay(context.Background(), cfg, "100", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != "10" || len(relayed) != 0 {
+		t.Fatalf("first poll should only find the newest message, got after=%q relayed=%q", after, relayed)
+	}
+
+	after, err = a.pollRelay(context.Background(), cfg, "100", after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Alice: hello", "bob: later"}
+	if len(relayed) != len(want) || relayed[0] != want[0] || relayed[1] != want[1] {
+		t.Errorf("expected %q relayed in ID order, got %q", want, relayed)
+	}
+	if after != "9999999999" {
+		t.Errorf("expected to continue after the newest message, got %q", after)
+	}
+	if !a.relayEchoes.echo("hello", time.Now()) {
+		t.Error("relayed message should be remembered as a possible echo")
+	}
+}
+
+func TestEchoFilter(t *testing.T) {
+	now := time.Now()
+	f := newEchoFilter()
+	f.add("hello there ", now)
+
+	if f.echo("other", now) {
+		t.Error("unrelated message reported as an echo")
+	}
+	if !f.echo("hello there", now.Add(time.Minute)) {
+		t.Error("expected the echo to be recognised")
+	}
+	if f.echo("hello there", now.Add(time.Minute)) {
+		t.Error("only the first echo should be suppressed")
+	}
+
+	f.add("late", now)
+	if f.echo("late", now.Add(relayEchoWindow+time.Second)) {
+		t.Error("echo outside the window should not be suppressed")
+	}
+
+	var nilFilter *echoFilter
+	nilFilter.add("x", now)
+	if nilFilter.echo("x", now) {
+		t.Error("nil filter should remember nothing")
+	}
+}
+
+func TestValidate_Relay(t *testing.T) {
+	bot := AppConfig{EnableLocalSave: true, Path: "logs", DiscordMode: discordModeBot, BotToken: "secret", BotChannelID: "100"}
+	tests := []struct {
+		name    string
+		modify  func(c *AppConfig)
+		wantErr string
+	}{
+		{"valid", func(c *AppConfig) { c.RelayURL = "http://game:8000/chat" }, ""},
+		{"webhook mode", func(c *AppConfig) { c.DiscordMode = ""; c.RelayURL = "http://game:8000/chat" }, "Discord relay requires bot mode"},
+		{"no channel", func(c *AppConfig) { c.BotChannelID = ""; c.RelayURL = "http://game:8000/chat" }, "Relay channel ID required"},
+		{"bad URL", func(c *AppConfig) { c.RelayURL = "game:8000" }, "Relay URL must be an http or https URL"},
+		{"no target", func(c *AppConfig) {}, "Relay URL or RCON required"},
+		{"RCON target", func(c *AppConfig) { c.EnableRCON = true; c.RCONAddr = "127.0.0.1:25575" }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := bot
+			cfg.EnableRelay = true
+			tt.modify(&cfg)
+			err := cfg.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
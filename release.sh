@@ -12,6 +12,14 @@ fi
 VERSION=$1
 TAG="v$VERSION"
 
+# Releases are signed with minisign. The public key in minisign.pub is
+# embedded in the binary, which then only installs updates signed with it.
+if [ ! -f minisign.pub ]; then
+    echo "minisign.pub not found! Create a key pair with: minisign -G -p minisign.pub"
+    exit 1
+fi
+LDFLAGS="-X main.Version=$VERSION -X main.UpdatePublicKey=$(tail -n 1 minisign.pub)"
+
 echo "=== RP Chat Logger Release Script ==="
 echo "Version: $VERSION"
 echo "Tag: $TAG"
@@ -25,14 +33,14 @@ if command -v rsrc &> /dev/null; then
     echo "  - Embedding Windows manifest..."
     rsrc -manifest rp-chat-logger.manifest -o rsrc.syso
     if [ $? -eq 0 ]; then
-        GOOS=windows GOARCH=amd64 go build -ldflags "-X main.Version=$VERSION" -o rp-chat-logger.exe
+        GOOS=windows GOARCH=amd64 go build -ldflags "$LDFLAGS" -o rp-chat-logger.exe
         rm -f rsrc.syso
     else
         echo "  - Manifest embedding failed, building without it..."
-        GOOS=windows GOARCH=amd64 go build -ldflags "-X main.Version=$VERSION" -o rp-chat-logger.exe
+        GOOS=windows GOARCH=amd64 go build -ldflags "$LDFLAGS" -o rp-chat-logger.exe
     fi
 else
-    GOOS=windows GOARCH=amd64 go build -ldflags "-X main.Version=$VERSION" -o rp-chat-logger.exe
+    GOOS=windows GOARCH=amd64 go build -ldflags "$LDFLAGS" -o rp-chat-logger.exe
 fi
 
 if [ $? -ne 0 ]; then
@@ -42,6 +50,56 @@ fi
 echo "✓ Build successful: rp-chat-logger.exe"
 echo ""
 
+# Step 1b: Build the other platforms, then package and checksum the
+# release assets. The updater picks the archive (or bare executable) named
+# after its own OS and architecture. The bare .exe stays for updaters of
+# older versions; newer ones download the archive and check it against
+# SHA256SUMS.
+echo "Step 1b: Building other platforms and packaging release assets..."
+PLATFORMS="windows/amd64 windows/arm64 linux/amd64 linux/arm64 darwin/amd64 darwin/arm64"
+ASSETS="rp-chat-logger.exe"
+rm -f rp-chat-logger_*.zip rp-chat-logger_*.tar.gz SHA256SUMS SHA256SUMS.minisig
+for PLATFORM in $PLATFORMS; do
+    GOOS=${PLATFORM%/*}
+    GOARCH=${PLATFORM#*/}
+    STAGE=$(mktemp -d)
+    if [ "$PLATFORM" = "windows/amd64" ]; then
+        cp rp-chat-logger.exe "$STAGE/"
+    elif [ "$GOOS" = "windows" ]; then
+        GOOS=$GOOS GOARCH=$GOARCH go build -ldflags "$LDFLAGS" -o "$STAGE/rp-chat-logger.exe"
+    else
+        GOOS=$GOOS GOARCH=$GOARCH go build -ldflags "$LDFLAGS" -o "$STAGE/rp-chat-logger"
+    fi
+    if [ $? -ne 0 ]; then
+        echo "Build for $PLATFORM failed!"
+        exit 1
+    fi
+    if [ "$GOOS" = "windows" ]; then
+        ARCHIVE=rp-chat-logger_${GOOS}_${GOARCH}.zip
+        (cd "$STAGE" && zip -q - rp-chat-logger.exe) > "$ARCHIVE"
+    else
+        ARCHIVE=rp-chat-logger_${GOOS}_${GOARCH}.tar.gz
+        tar -czf "$ARCHIVE" -C "$STAGE" rp-chat-logger
+    fi
+    if [ $? -ne 0 ]; then
+        echo "Packaging $PLATFORM failed! (are zip and tar installed?)"
+        exit 1
+    fi
+    rm -rf "$STAGE"
+    ASSETS="$ASSETS $ARCHIVE"
+done
+sha256sum $ASSETS > SHA256SUMS
+# -l makes a pure Ed25519 signature, which the updater can check without
+# BLAKE2b.
+minisign -S -l -m SHA256SUMS
+if [ $? -ne 0 ]; then
+    echo "Signing failed! (is minisign installed?)"
+    exit 1
+fi
+ASSETS="$ASSETS SHA256SUMS SHA256SUMS.minisig"
+echo "✓ Packaged: $ASSETS"
+echo ""
+
 # Step 2: Commit changes
 echo "Step 2: Committing changes..."
 git add .
@@ -92,8 +150,8 @@ echo ""
 echo "=== Release Complete! ==="
 echo "Version: $VERSION"
 echo ""
-echo "Next step: Create the GitHub release manually at:"
+echo "Next step: Create the GitHub release manually, attaching $ASSETS, at:"
 echo "https://github.com/ragaz-zo/rp-chat-logger/releases/new?tag=$TAG"
 echo ""
 echo "Or run this command once gh CLI is properly authenticated:"
-echo "gh release create $TAG rp-chat-logger.exe --title \"Release $VERSION\" --notes \"Release version $VERSION of RP Chat Logger\""
+echo "gh release create $TAG $ASSETS --title \"Release $VERSION\" --notes \"Release version $VERSION of RP Chat Logger\""
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// configPollInterval is how often the config file is checked for changes.
+const configPollInterval = 2 * time.Second
+
+// watchConfigFile polls the config file and applies edits made outside the
+// web UI to the running app. overrides are re-applied on every reload so
+// flags and environment variables keep their precedence.
+func (a *App) watchConfigFile(overrides ConfigOverrides) {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(getConfigPath())
+	var lastErr string
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(getConfigPath())
+		if err != nil || (last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size()) {
+			continue
+		}
+
+		if err := a.reloadConfig(overrides); err != nil {
+			// Retry on the next tick (the file may be mid-write), but only
+			// report each distinct error once.
+			if err.Error() != lastErr {
+				a.logger.Log("error", fmt.Sprintf("Config file change ignored: %v", err))
+				lastErr = err.Error()
+			}
+			continue
+		}
+		last, lastErr = info, ""
+	}
+}
+
+// reloadConfig reads the config file and applies it if it is valid.
+func (a *App) reloadConfig(overrides ConfigOverrides) error {
+	config, err := loadConfiguration(overrides)
+	if err != nil {
+		return err
+	}
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	a.applyConfig(config, "Config file reloaded")
+	return nil
+}
+
+// applyConfig swaps in a validated config, with the environment and
+// command-line overrides applied, and applies it to the running app,
+// logging which settings changed. The ingestion server picks up the new
+// settings with the next message; it is only restarted when a listen
+// address changed.
+func (a *App) applyConfig(config *AppConfig, reason string) {
+	// Configs from a profile, an import or the API don't have the
+	// environment and command-line overrides yet.
+	applyEnv(config, os.Getenv)
+	applyOverrides(config, configOverrides)
+
+	a.configMu.Lock()
+	old := *a.config
+	if discordTarget(config) != discordTarget(&old) && reflect.DeepEqual(config.SceneThreadIDs, old.SceneThreadIDs) {
+		// Threads belong to the old webhook's channel; start fresh.
+		config.SceneThreadIDs = nil
+	}
+	changes := configChanges(&old, config)
+	if len(changes) == 0 {
+		a.configMu.Unlock()
+		return
+	}
+	a.config = config
+	a.configMu.Unlock()
+
+	setKnownSecrets(config)
+	discordCircuit.configure(config)
+	if err := outbound.configure(config); err != nil {
+		a.logger.Log("error", "HTTP client settings not applied: "+err.Error())
+	}
+	a.logger.SetLogLevel(config.LogLevel)
+	a.logger.SetHistoryLimits(config.LogHistorySize, config.FailureHistorySize)
+	a.logger.Log("info", fmt.Sprintf("%s, changed: %s", reason, strings.Join(changes, ", ")))
+
+	if listenersChanged(&old, config) && a.ingestionRunning.Load() {
+		a.logger.Log("info", fmt.Sprintf("Restarting ingestion server on %s", config.ListenAddr))
+		if err := a.StopIngestionServer(); err != nil {
+			a.logger.Log("error", err.Error())
+		} else if err := a.StartIngestionServer(); err != nil {
+			a.logger.Log("error", fmt.Sprintf("Failed to restart ingestion server: %v", err))
+		}
+	}
+}
+
+// configChanges lists the JSON names of the settings that differ between
+// two configs. Bookkeeping fields the app writes itself are ignored.
+func configChanges(old, new *AppConfig) []string {
+	var changes []string
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(new).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		field := oldValue.Type().Field(i)
+		if field.Name == "SceneThreadIDs" || field.Name == "LastDigest" || field.Name == "LastUpload" {
+			continue
+		}
+		if reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		changes = append(changes, name)
+	}
+	return changes
+}
+
+// listenersChanged reports whether the settings of any ingestion listener
+// (HTTP, source, UDP or game log) differ between two configs.
+func listenersChanged(old, new *AppConfig) bool {
+	if old.ListenAddr != new.ListenAddr || len(old.Sources) != len(new.Sources) ||
+		old.UDPListenAddr != new.UDPListenAddr || old.UDPPattern != new.UDPPattern ||
+		old.TailPath != new.TailPath || old.TailPattern != new.TailPattern ||
+		old.InputPreset != new.InputPreset {
+		return true
+	}
+	for name, profile := range new.Sources {
+		if old.Sources[name].ListenAddr != profile.ListenAddr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigChanges(t *testing.T) {
+	old := &AppConfig{WebhookURL: "https://a", Path: "/logs", LastDigest: "2026-10-16"}
+	updated := &AppConfig{WebhookURL: "https://b", Path: "/logs", LastDigest: "2026-10-17",
+		OOCMarkers: []string{"(("}}
+
+	got := configChanges(old, updated)
+	want := []string{"webhookURL", "oocMarkers"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if changes := configChanges(old, old); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	setConfigPath(path)
+	defer setConfigPath("")
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableDiscord = true
+	a.config.WebhookURL = "https://discord.com/api/webhooks/test"
+	a.config.SceneThreadIDs = map[string]string{"tavern": "123"}
+
+	tests := []struct {
+		name        string
+		file        string
+		wantErr     bool
+		wantWebhook string
+		wantThreads bool
+	}{
+		{
+			name:        "invalid JSON is ignored",
+			file:        `{"webhookURL": `,
+			wantErr:     true,
+			wantWebhook: "https://discord.com/api/webhooks/test",
+			wantThreads: true,
+		},
+		{
+			name:        "invalid config is ignored",
+			file:        `{"enableDiscord": true}`,
+			wantErr:     true,
+			wantWebhook: "https://discord.com/api/webhooks/test",
+			wantThreads: true,
+		},
+		{
+			name:        "new webhook applies and resets threads",
+			file:        `{"enableDiscord": true, "webhookURL": "https://discord.com/api/webhooks/new", "sceneThreadIDs": {"tavern": "123"}}`,
+			wantWebhook: "https://discord.com/api/webhooks/new",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+				t.Fatal(err)
+			}
+			err := a.reloadConfig(ConfigOverrides{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if a.config.WebhookURL != tt.wantWebhook {
+				t.Errorf("expected webhook %q, got %q", tt.wantWebhook, a.config.WebhookURL)
+			}
+			if hasThreads := len(a.config.SceneThreadIDs) > 0; hasThreads != tt.wantThreads {
+				t.Errorf("expected scene threads kept=%v, got %v", tt.wantThreads, a.config.SceneThreadIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// parseReplayWindow returns the time window to replay: the day given as
+// YYYY-MM-DD, optionally narrowed to [from, to) given as HH:MM.
+func parseReplayWindow(date, from, to string) (time.Time, time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(date), time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+	start, end := day, day.AddDate(0, 0, 1)
+	if from = strings.TrimSpace(from); from != "" {
+		at, err := time.Parse("15:04", from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start time %q, expected HH:MM", from)
+		}
+		start = day.Add(time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute)
+	}
+	if to = strings.TrimSpace(to); to != "" {
+		at, err := time.Parse("15:04", to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end time %q, expected HH:MM", to)
+		}
+		end = day.Add(time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute)
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start time must be before end time")
+	}
+	return start, end, nil
+}
+
+// replayToDiscord queues the logged entries in [from, to) for Discord, in
+// order and with their original timestamps, and returns how many were
+// queued. The Discord queue takes care of rate limits. Scene threads are
+// not used because log files don't record scenes.
+func (a *App) replayToDiscord(from, to time.Time) (int, error) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	if cfg.Path == "" {
+		return 0, fmt.Errorf("no log folder configured")
+	}
+	if discordTarget(&cfg) == "" {
+		return 0, fmt.Errorf("no Discord webhook or bot channel configured")
+	}
+
+	entries, files, err := entriesBetween(&cfg, from, to)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no %s log file for %s", logFormat(&cfg), from.Format("2006-01-02"))
+	}
+
+	msgs := make([]QueuedMessage, 0, len(entries))
+	for _, entry := range entries {
+		msg, ok := replayMessage(&cfg, entry)
+		if ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	a.discordQueue.AddBatch(msgs)
+	a.logger.Log("info", fmt.Sprintf("Replaying %d logged message(s) from %s to %s to Discord",
+		len(msgs), from.Format("2006-01-02 15:04"), to.Format("2006-01-02 15:04")))
+	return len(msgs), nil
+}
+
+// replayMessage builds the Discord delivery for a logged entry, applying
+// the same OOC policy and source routing as live messages.
+func replayMessage(cfg *AppConfig, entry LogEntry) (QueuedMessage, bool) {
+	webhookURL := sourceWebhookURL(cfg, entry.Source)
+	content := discordText(cfg, entry.Message)
+	switch entry.Kind {
+	case kindOOC:
+		switch cfg.OOCDiscordPolicy {
+		case oocExclude:
+			return QueuedMessage{}, false
+		case oocSeparate:
+			webhookURL = oocDiscordTarget(cfg)
+		}
+	case kindEmote:
+		content = "*" + content + "*"
+	}
+
+	at, _ := time.ParseInLocation(logTimestampLayout, entry.Timestamp, time.Local)
+	return QueuedMessage{
+		WebhookURL:  webhookURL,
+		Author:      discordAuthorFor(cfg, entry.Sender),
+		Template:    cfg.DiscordTemplate,
+		AttachAfter: cfg.DiscordAttachAfter,
+		Mentions:    discordMentions(cfg),
+		Sender:      displayName(cfg, entry.Sender),
+		Message:     content,
+		Source:      entry.Source,
+		Time:        at,
+	}, true
+}
+
+// runReplay replays a day's log to Discord from the command line and waits
+// until every message is delivered. On Ctrl-C the rest is saved and sent
+// on the next start. The app is shut down in any case, which also saves
+// the messages NewApp restored from the last run.
+func runReplay(application *App, date, from, to string) error {
+	defer application.Shutdown()
+	start, end, err := parseReplayWindow(date, from, to)
+	if err != nil {
+		return err
+	}
+
+	application.logger.SetEcho(true)
+	application.discordQueue.Stop()
+	n, err := application.replayToDiscord(start, end)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	left := application.discordQueue.Drain(ctx)
+	stop()
+	application.discordQueue.AddBatch(left)
+	slog.Info("Replay finished", "queued", n, "undelivered", len(left))
+	return nil
+}
+
+// handleReplay queues a day's log for Discord from the web UI.
+func (a *App) handleReplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	start, end, err := parseReplayWindow(r.FormValue("date"), r.FormValue("from"), r.FormValue("to"))
+	if err == nil {
+		var n int
+		if n, err = a.replayToDiscord(start, end); err == nil {
+			fmt.Fprintf(w, `<div class="alert success">Queued %d message(s) for Discord</div>`, n)
+			return
+		}
+	}
+	fmt.Fprintf(w, `<div class="alert error">Replay failed: %s</div>`, template.HTMLEscapeString(err.Error()))
+}
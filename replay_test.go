@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseReplayWindow(t *testing.T) {
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name      string
+		date      string
+		from, to  string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{name: "whole day", date: "2026-10-17", wantStart: day, wantEnd: day.AddDate(0, 0, 1)},
+		{name: "window", date: "2026-10-17", from: "18:00", to: "21:30", wantStart: day.Add(18 * time.Hour), wantEnd: day.Add(21*time.Hour + 30*time.Minute)},
+		{name: "from only", date: "2026-10-17", from: "20:15", wantStart: day.Add(20*time.Hour + 15*time.Minute), wantEnd: day.AddDate(0, 0, 1)},
+		{name: "bad date", date: "17/10/2026", wantErr: true},
+		{name: "bad time", date: "2026-10-17", from: "6pm", wantErr: true},
+		{name: "reversed", date: "2026-10-17", from: "21:00", to: "18:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := parseReplayWindow(tt.date, tt.from, tt.to)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("got %v - %v, want %v - %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestReplayToDiscord(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.discordQueue = NewDiscordQueue(nil, nil)
+	a.discordQueue.Stop()
+
+	tmpDir := t.TempDir()
+	a.config.Path = tmpDir
+	a.config.WebhookURL = "https://discord.example/main"
+	a.config.OOCWebhookURL = "https://discord.example/ooc"
+	a.config.OOCDiscordPolicy = oocSeparate
+
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)
+	filename := logFilenameForDate(tmpDir, "txt", day)
+	for _, entry := range []LogEntry{
+		{Timestamp: "2026-10-16 17:59:00", Sender: "Early", Message: "too early"},
+		{Timestamp: "2026-10-16 18:00:00", Sender: "Alice", Message: "hello"},
+		{Timestamp: "2026-10-16 18:01:00", Sender: "Bob", Message: "waves", Kind: kindEmote},
+		{Timestamp: "2026-10-16 18:02:00", Sender: "Carol", Message: "((brb))", Kind: kindOOC},
+	} {
+		if err := writeLogEntry(filename, "txt", logLayout{}, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := a.replayToDiscord(day.Add(18*time.Hour), day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 messages queued, got %d", n)
+	}
+
+	a.discordQueue.mu.Lock()
+	queued := append([]QueuedMessage(nil), a.discordQueue.messages...)
+	a.discordQueue.mu.Unlock()
+
+	want := []struct{ sender, message, webhook string }{
+		{"Alice", "hello", "https://discord.example/main"},
+		{"Bob", "*waves*", "https://discord.example/main"},
+		{"Carol", "((brb))", "https://discord.example/ooc"},
+	}
+	for i, w := range want {
+		msg := queued[i]
+		if msg.Sender != w.sender || msg.Message != w.message || msg.WebhookURL != w.webhook {
+			t.Errorf("message %d: got %+v", i, msg)
+		}
+	}
+	if !queued[0].Time.Equal(day.Add(18 * time.Hour)) {
+		t.Errorf("expected original timestamp, got %v", queued[0].Time)
+	}
+
+	if _, err := a.replayToDiscord(day.AddDate(0, 0, -1), day); err == nil {
+		t.Error("expected an error for a day without a log file")
+	}
+}
+
+func TestRunReplay_KeepsPendingMessagesOnError(t *testing.T) {
+	dir := t.TempDir()
+	setConfigPath(filepath.Join(dir, "config.json"))
+	defer setConfigPath("")
+
+	if err := os.WriteFile(pendingPath(discordPausedFile), []byte(`{"since": "2026-10-17T18:00:00Z"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	held := []QueuedMessage{{WebhookURL: "https://discord.invalid/hook", Sender: "Alice", Message: "still queued"}}
+	if err := savePendingMessages(pendingPath(pendingDiscordFile), held); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewApp(&AppConfig{FileFormat: "txt", EnableLocalSave: true, Path: filepath.Join(dir, "logs")}, "")
+	if err := runReplay(a, "yesterday", "", ""); err == nil {
+		t.Fatal("replayed an invalid date")
+	}
+	pending, err := loadPendingMessages(pendingPath(pendingDiscordFile))
+	if err != nil || len(pending) != 1 || pending[0].Message != "still queued" {
+		t.Errorf("pending messages after a failed replay = %+v, %v", pending, err)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Retention actions for log files past the retention limits.
+const (
+	retentionDelete  = "delete"
+	retentionArchive = "archive"
+)
+
+// retentionArchiveDir holds the zip files written by the archive action.
+const retentionArchiveDir = "archive"
+
+// retentionInterval is how often the retention task checks the log folder.
+const retentionInterval = time.Hour
+
+// logFileDate finds the date in a daily log file name.
+var logFileDate = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+
+// retainedFile is a daily log file considered for pruning.
+type retainedFile struct {
+	path string
+	date time.Time
+	size int64
+}
+
+// PruneResult summarizes one retention run.
+type PruneResult struct {
+	Files   []string // pruned files, relative to the log folder
+	Bytes   int64
+	Archive string // zip written by the archive action, if any
+}
+
+// runRetentionScheduler applies the retention policy at startup and then
+// once an hour. The config is read on every run so changes apply without
+// a restart.
+func (a *App) runRetentionScheduler() {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		a.applyRetention(time.Now())
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyRetention prunes old log files if a retention limit is set and
+// reports what it pruned.
+func (a *App) applyRetention(now time.Time) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	if cfg.Path == "" || (cfg.RetentionDays <= 0 && cfg.RetentionMaxSizeMB <= 0) {
+		return
+	}
+
+	a.lockArchive()
+	result, err := pruneLogs(&cfg, now)
+	a.archiveMu.Unlock()
+
+	if err != nil {
+		slog.Error("Log retention failed", "err", err)
+		if a.logger != nil {
+			a.logger.Log("error", fmt.Sprintf("Log retention failed: %v", err))
+		}
+	}
+	if len(result.Files) == 0 {
+		return
+	}
+	for _, name := range result.Files {
+		slog.Info("Pruned log file", "file", name, "action", retentionAction(&cfg))
+	}
+	msg := fmt.Sprintf("Pruned %d old log file(s), %.1f MB", len(result.Files), float64(result.Bytes)/(1<<20))
+	if result.Archive != "" {
+		msg += " into " + result.Archive
+	}
+	if a.logger != nil {
+		a.logger.Log("info", msg)
+	}
+}
+
+// retentionAction returns the configured action, defaulting to delete.
+func retentionAction(config *AppConfig) string {
+	if config.RetentionAction == "" {
+		return retentionDelete
+	}
+	return config.RetentionAction
+}
+
+// pruneLogs removes daily log files older than RetentionDays and, oldest
+// first, any files beyond RetentionMaxSizeMB. Today's files, session
+// transcripts and earlier archives are never pruned. With the archive
+// action the files are zipped into the archive folder before removal.
+func pruneLogs(config *AppConfig, now time.Time) (PruneResult, error) {
+	var result PruneResult
+
+	files, err := listRetainedFiles(config.Path)
+	if err != nil {
+		return result, err
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].date.Before(files[j].date) })
+
+	today := truncateToDay(now)
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	var prune []retainedFile
+	for _, f := range files {
+		if !f.date.Before(today) {
+			break
+		}
+		tooOld := config.RetentionDays > 0 && f.date.Before(today.AddDate(0, 0, -config.RetentionDays))
+		tooBig := config.RetentionMaxSizeMB > 0 && total > int64(config.RetentionMaxSizeMB)<<20
+		if !tooOld && !tooBig {
+			break
+		}
+		prune = append(prune, f)
+		total -= f.size
+	}
+	if len(prune) == 0 {
+		return result, nil
+	}
+
+	if retentionAction(config) == retentionArchive {
+		archive, err := archiveLogFiles(config.Path, prune, now)
+		if err != nil {
+			return result, err
+		}
+		result.Archive = archive
+	}
+
+	for _, f := range prune {
+		if err := os.Remove(f.path); err != nil {
+			return result, fmt.Errorf("removing %s: %w", filepath.Base(f.path), err)
+		}
+		rel, _ := filepath.Rel(config.Path, f.path)
+		result.Files = append(result.Files, rel)
+		result.Bytes += f.size
+		removeEmptyDirs(config.Path, filepath.Dir(f.path))
+	}
+	return result, nil
+}
+
+// listRetainedFiles returns the dated log files under basePath, skipping
+// the sessions and archive folders.
+func listRetainedFiles(basePath string) ([]retainedFile, error) {
+	var files []retainedFile
+	err := filepath.WalkDir(basePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != basePath && (d.Name() == "sessions" || d.Name() == retentionArchiveDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.TrimPrefix(filepath.Ext(d.Name()), ".")
+		if ext != "txt" && ext != "csv" && ext != "json" && ext != "docx" {
+			return nil
+		}
+		date, err := time.ParseInLocation("2006-01-02", logFileDate.FindString(d.Name()), time.Local)
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, retainedFile{path: path, date: date, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing log files: %w", err)
+	}
+	return files, nil
+}
+
+// archiveLogFiles zips files into a new archive under basePath/archive and
+// returns its path relative to basePath. A copy of the folder's
+// annotations goes along; the file itself stays for the logs left.
+func archiveLogFiles(basePath string, files []retainedFile, now time.Time) (string, error) {
+	dir := filepath.Join(basePath, retentionArchiveDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("creating archive directory: %w", err)
+	}
+	name := filepath.Join(dir, fmt.Sprintf("logs_%s.zip", now.Format("2006-01-02_150405")))
+	tmp := name + ".tmp"
+
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("creating archive: %w", err)
+	}
+	if _, err := os.Stat(annotationsPath(basePath)); err == nil {
+		files = append(files[:len(files):len(files)], retainedFile{path: annotationsPath(basePath)})
+	}
+	zw := zip.NewWriter(out)
+	for _, f := range files {
+		rel, _ := filepath.Rel(basePath, f.path)
+		if err := addToZipAs(zw, f.path, filepath.ToSlash(rel)); err != nil {
+			zw.Close()
+			out.Close()
+			os.Remove(tmp)
+			return "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("writing archive: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("writing archive: %w", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("saving archive: %w", err)
+	}
+	return filepath.Join(retentionArchiveDir, filepath.Base(name)), nil
+}
+
+// addToZipAs copies the file at path into zw under name.
+func addToZipAs(zw *zip.Writer, path, name string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents up to, but not including,
+// basePath while they are empty, so pruned month and scene folders don't
+// linger.
+func removeEmptyDirs(basePath, dir string) {
+	basePath = filepath.Clean(basePath)
+	for dir != basePath && strings.HasPrefix(dir, basePath) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeDatedLogs creates one 1 KB log file per day for the given number of
+// days up to and including now, in month folders, and returns their names.
+func writeDatedLogs(t *testing.T, dir string, now time.Time, days int) []string {
+	t.Helper()
+	cfg := &AppConfig{Path: dir, FileFormat: "txt", FolderLayout: folderMonth}
+	var names []string
+	for i := days - 1; i >= 0; i-- {
+		path := logFilePath(cfg, dir, now.AddDate(0, 0, -i), LogEntry{Timestamp: "x"})
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", 1024)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, path)
+	}
+	return names
+}
+
+func TestPruneLogs(t *testing.T) {
+	now := time.Date(2026, 3, 3, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name       string
+		days       int
+		maxSizeMB  int
+		files      int
+		wantPruned int
+	}{
+		{"no limits", 0, 0, 10, 0},
+		{"keep days", 5, 0, 10, 4},
+		{"within days", 30, 0, 10, 0},
+		{"size under limit", 0, 1, 10, 0},
+		{"size over limit", 0, 1, 1030, 6},
+		{"today kept", 0, 1, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			names := writeDatedLogs(t, dir, now, tt.files)
+			cfg := &AppConfig{Path: dir, RetentionDays: tt.days, RetentionMaxSizeMB: tt.maxSizeMB}
+
+			result, err := pruneLogs(cfg, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Files) != tt.wantPruned {
+				t.Fatalf("pruned %d files, want %d", len(result.Files), tt.wantPruned)
+			}
+			for i, name := range names {
+				_, err := os.Stat(name)
+				if pruned := i < tt.wantPruned; pruned != os.IsNotExist(err) {
+					t.Errorf("%s: pruned = %v, stat err = %v", filepath.Base(name), pruned, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneLogs_Archive(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 3, 12, 0, 0, 0, time.Local)
+	writeDatedLogs(t, dir, now, 5)
+	sessionFile := sessionLogFilename(dir, "2026-01-01 raid", "txt")
+	os.MkdirAll(filepath.Dir(sessionFile), 0755)
+	os.WriteFile(sessionFile, []byte("x"), 0644)
+	os.WriteFile(annotationsPath(dir), []byte("[]"), 0644)
+
+	cfg := &AppConfig{Path: dir, RetentionDays: 1, RetentionAction: retentionArchive}
+	result, err := pruneLogs(cfg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 3 || result.Archive == "" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	zr, err := zip.OpenReader(filepath.Join(dir, result.Archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	want := map[string]bool{
+		"2026/02/ConanExiles_log_2026-02-27.txt": true,
+		"2026/02/ConanExiles_log_2026-02-28.txt": true,
+		"2026/03/ConanExiles_log_2026-03-01.txt": true,
+		annotationsFile:                          true,
+	}
+	for _, f := range zr.File {
+		if !want[f.Name] {
+			t.Errorf("unexpected file in archive: %s", f.Name)
+		}
+		delete(want, f.Name)
+	}
+	if len(want) != 0 {
+		t.Errorf("missing from archive: %v", want)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "2026", "02")); !os.IsNotExist(err) {
+		t.Errorf("expected empty month folder to be removed, got %v", err)
+	}
+	if _, err := os.Stat(sessionFile); err != nil {
+		t.Errorf("session transcript should be kept: %v", err)
+	}
+	if _, err := os.Stat(annotationsPath(dir)); err != nil {
+		t.Errorf("annotations should be kept for the logs left: %v", err)
+	}
+
+	// A second run finds nothing new and leaves the archive alone.
+	again, err := pruneLogs(cfg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Files) != 0 {
+		t.Errorf("second run pruned %v", again.Files)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// What happens to rich-text tags such as <color=red> in chat lines: kept
+// as sent (the default), stripped, or stripped everywhere but Discord,
+// where bold, italics, underline and strikethrough become markdown.
+const (
+	richTextKeep     = "keep"
+	richTextStrip    = "strip"
+	richTextMarkdown = "markdown"
+)
+
+// richTextTag matches the Unity and TextMeshPro rich-text tags that game
+// mods put in chat lines, capturing the closing slash and the tag name.
+var richTextTag = regexp.MustCompile(`(?i)<(/?)(b|i|u|s|color|size|material|quad|font|mark|sup|sub|align|alpha|cspace|indent|line-height|link|lowercase|uppercase|smallcaps|margin|noparse|nobr|pos|rotate|space|sprite|style|voffset|width|gradient)(?:[= ][^<>]*)?/?>`)
+
+// richTextMarkers are the markdown equivalents of formatting tags.
+var richTextMarkers = map[string]string{"b": "**", "i": "*", "u": "__", "s": "~~"}
+
+// cleanRichText applies the RichText setting to an incoming message. It
+// returns the text for logs and every other sink, and the text Discord
+// converts to markdown, which is empty unless the setting asks for it.
+func cleanRichText(cfg *AppConfig, message string) (plain, rich string) {
+	switch cfg.RichText {
+	case richTextStrip:
+		return stripRichText(message), ""
+	case richTextMarkdown:
+		plain = stripRichText(message)
+		if plain == message {
+			return plain, ""
+		}
+		return plain, message
+	}
+	return message, ""
+}
+
+// stripRichText removes rich-text tags, keeping the text between them.
+func stripRichText(s string) string {
+	return richTextTag.ReplaceAllString(s, "")
+}
+
+// richTextToMarkdown converts formatting tags to Discord markdown and
+// removes the other tags. The text between tags goes through text, which
+// escapes it. Markers are only written around text, closing tags without
+// an opening one are dropped and tags left open are closed at the end, so
+// the markers always pair up.
+func richTextToMarkdown(s string, text func(string) string) string {
+	type openTag struct {
+		marker string
+		shown  bool // written since the last time it was closed
+	}
+	var b strings.Builder
+	var open []openTag
+	write := func(segment string) {
+		rest := strings.TrimLeft(segment, " ")
+		if rest == "" {
+			b.WriteString(text(segment))
+			return
+		}
+		// Markers go after leading spaces: Discord ignores "* text*".
+		b.WriteString(segment[:len(segment)-len(rest)])
+		for i := range open {
+			if !open[i].shown {
+				b.WriteString(open[i].marker)
+				open[i].shown = true
+			}
+		}
+		b.WriteString(text(rest))
+	}
+	last := 0
+	for _, m := range richTextTag.FindAllStringSubmatchIndex(s, -1) {
+		write(s[last:m[0]])
+		last = m[1]
+		marker, ok := richTextMarkers[strings.ToLower(s[m[4]:m[5]])]
+		if !ok {
+			continue
+		}
+		if m[3] == m[2] { // opening tag
+			open = append(open, openTag{marker: marker})
+			continue
+		}
+		i := len(open) - 1
+		for i >= 0 && open[i].marker != marker {
+			i--
+		}
+		if i < 0 {
+			continue
+		}
+		// Close what was opened inside it too; it reopens before the
+		// next text.
+		for j := len(open) - 1; j >= i; j-- {
+			if open[j].shown {
+				b.WriteString(open[j].marker)
+				open[j].shown = false
+			}
+		}
+		open = slices.Delete(open, i, i+1)
+	}
+	write(s[last:])
+	for j := len(open) - 1; j >= 0; j-- {
+		if open[j].shown {
+			b.WriteString(open[j].marker)
+		}
+	}
+	return b.String()
+}
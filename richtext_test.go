@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestStripRichText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain <3 text", "plain <3 text"},
+		{"<color=#ff0000>Red</color> alert", "Red alert"},
+		{`<COLOR="red">Loud</COLOR>`, "Loud"},
+		{"<b>bold</b> and <size=120%>big</size><br>", "bold and big<br>"},
+		{"<sprite name=\"coin\"/> 5 gold", " 5 gold"},
+		{"a <bold> claim", "a <bold> claim"},
+	}
+	for _, tt := range tests {
+		if got := stripRichText(tt.in); got != tt.want {
+			t.Errorf("stripRichText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRichTextToMarkdown(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"<b>By Crom</b>", "**By Crom**"},
+		{"<i>whispers</i> <color=red>*red*</color>", `*whispers* \*red\*`},
+		{"<b>bold <i>both</b> italic</i>", "**bold *both*** *italic*"},
+		{"<u>open", "__open__"},
+		{"stray</s> close", "stray close"},
+		{"<b><b>twice</b></b>", "****twice****"},
+		{"<i></i>empty", "empty"},
+		{"<b>  spaced</b>", "  **spaced**"},
+	}
+	for _, tt := range tests {
+		if got := richTextToMarkdown(tt.in, escapeDiscordMarkdown); got != tt.want {
+			t.Errorf("richTextToMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestProcessMessage_RichText(t *testing.T) {
+	tests := []struct {
+		mode        string
+		wantLog     string
+		wantDiscord string
+	}{
+		{"", "<b>Hail</b> friend", `<b>Hail</b> friend`},
+		{richTextStrip, "Hail friend", "Hail friend"},
+		{richTextMarkdown, "Hail friend", "**Hail** friend"},
+	}
+	for _, tt := range tests {
+		plain, rich := cleanRichText(&AppConfig{RichText: tt.mode}, "<b>Hail</b> friend")
+		if plain != tt.wantLog {
+			t.Errorf("%q: logged %q, want %q", tt.mode, plain, tt.wantLog)
+		}
+		text := plain
+		if rich != "" {
+			text = rich
+		}
+		cfg := &AppConfig{RichText: tt.mode, DiscordMarkdown: discordMarkdownPassthrough}
+		if got := discordContent(cfg, text); got != tt.wantDiscord {
+			t.Errorf("%q: posted %q, want %q", tt.mode, got, tt.wantDiscord)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultS3Region is used for signing when no region is configured; most
+// S3-compatible services accept it.
+const defaultS3Region = "us-east-1"
+
+// s3Sink uploads files to an S3-compatible bucket using AWS Signature
+// Version 4.
+type s3Sink struct {
+	endpoint  string
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+}
+
+// newS3Sink builds a sink from the S3 settings in config.
+func newS3Sink(config *AppConfig) *s3Sink {
+	region := config.S3Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	return &s3Sink{
+		endpoint:  strings.TrimSuffix(config.S3Endpoint, "/"),
+		region:    region,
+		bucket:    config.S3Bucket,
+		prefix:    strings.Trim(config.S3Prefix, "/"),
+		accessKey: config.S3AccessKey,
+		secretKey: config.S3SecretKey,
+		pathStyle: config.S3PathStyle,
+	}
+}
+
+func (s *s3Sink) name() string { return "S3 bucket " + s.bucket }
+
+// put uploads the file at path under key, below the configured prefix.
+func (s *s3Sink) put(ctx context.Context, key, path string) error {
+	target, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", key, err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", key, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("reading %s: %w", key, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), f)
+	if err != nil {
+		return fmt.Errorf("creating S3 request: %w", err)
+	}
+	req.ContentLength = size
+	signS3Request(req, hex.EncodeToString(hash.Sum(nil)), s.accessKey, s.secretKey, s.region, time.Now())
+
+	resp, err := backupClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading %s to S3: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("uploading %s to S3: status %d: %s", key, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// upload makes s3Sink a backup destination; backups go under "backups/".
+func (s *s3Sink) upload(ctx context.Context, file string) error {
+	return s.put(ctx, "backups/"+filepath.Base(file), file)
+}
+
+// objectURL returns the URL of key in the bucket, either path-style
+// (endpoint/bucket/key) or virtual-hosted (bucket.endpoint/key).
+func (s *s3Sink) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(s.endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", s.endpoint)
+	}
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	if s.pathStyle {
+		u.Path = "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	return u, nil
+}
+
+// signS3Request adds AWS Signature Version 4 headers to req. The host and
+// every header already set on req are signed; payloadHash is the hex
+// SHA-256 of the body.
+func signS3Request(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath URI-encodes each segment of an object path as SigV4
+// requires, keeping the slashes.
+func s3EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		var b strings.Builder
+		for _, c := range []byte(seg) {
+			if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+				b.WriteByte(c)
+			} else {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		segments[i] = b.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
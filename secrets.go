@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+// How secrets (see mapSecrets) are stored in config files. Plain text is
+// the default; the others encrypt each secret with AES-GCM under a key
+// kept in the OS keychain or derived from a passphrase.
+const (
+	secretStoragePlain      = "plain"
+	secretStorageKeychain   = "keychain"
+	secretStoragePassphrase = "passphrase"
+)
+
+const (
+	// encryptedPrefix starts an encrypted secret, followed by the storage
+	// mode, a colon and the base64 payload.
+	encryptedPrefix = "enc:"
+	// passphraseEnv holds the passphrase for passphrase encryption. It is
+	// never written anywhere.
+	passphraseEnv = "RPCL_SECRETS_PASSPHRASE"
+	// pbkdf2Iterations follows the current OWASP advice for SHA-256.
+	pbkdf2Iterations = 600000
+	saltSize         = 16
+	secretKeySize    = 32
+)
+
+// keychain keeps the key for keychain encryption in the operating
+// system's secret store. See secrets_windows.go, secrets_darwin.go and
+// secrets_other.go.
+type keychain interface {
+	// load returns the stored key, or an error wrapping fs.ErrNotExist
+	// when there is none yet.
+	load() ([]byte, error)
+	store(key []byte) error
+}
+
+// osKeychain is the keychain in use; tests replace it.
+var osKeychain keychain = systemKeychain{}
+
+// secretKeys caches keys for the life of the process, so the keychain is
+// asked once and each passphrase salt is derived once.
+var secretKeys struct {
+	mu       sync.Mutex
+	keychain []byte
+	derived  map[string][]byte // by passphrase and salt
+	salt     []byte            // salt for new passphrase encryptions
+}
+
+// keychainKey returns the keychain key, creating and storing one if create
+// is set and there is none yet.
+func keychainKey(create bool) ([]byte, error) {
+	secretKeys.mu.Lock()
+	defer secretKeys.mu.Unlock()
+	if secretKeys.keychain != nil {
+		return secretKeys.keychain, nil
+	}
+	key, err := osKeychain.load()
+	if errors.Is(err, fs.ErrNotExist) {
+		if !create {
+			// Not fs.ErrNotExist, which would read as a missing config file.
+			return nil, errors.New("OS keychain has no key for these secrets")
+		}
+		key = make([]byte, secretKeySize)
+		rand.Read(key)
+		err = osKeychain.store(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("OS keychain: %w", err)
+	}
+	if len(key) != secretKeySize {
+		return nil, errors.New("OS keychain: stored key has the wrong size")
+	}
+	secretKeys.keychain = key
+	return key, nil
+}
+
+// passphraseKey derives the key for salt from the passphrase in
+// RPCL_SECRETS_PASSPHRASE.
+func passphraseKey(salt []byte) ([]byte, error) {
+	passphrase := os.Getenv(passphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("%s is not set", passphraseEnv)
+	}
+	sum := sha256.Sum256(append([]byte(passphrase), salt...))
+	id := string(sum[:])
+
+	secretKeys.mu.Lock()
+	defer secretKeys.mu.Unlock()
+	if key, ok := secretKeys.derived[id]; ok {
+		return key, nil
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, secretKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("deriving key: %w", err)
+	}
+	if secretKeys.derived == nil {
+		secretKeys.derived = make(map[string][]byte)
+	}
+	secretKeys.derived[id] = key
+	return key, nil
+}
+
+// encryptionSalt returns the salt for passphrase encryption in this
+// process, so a config file is derived once when read back.
+func encryptionSalt() []byte {
+	secretKeys.mu.Lock()
+	defer secretKeys.mu.Unlock()
+	if secretKeys.salt == nil {
+		secretKeys.salt = make([]byte, saltSize)
+		rand.Read(secretKeys.salt)
+	}
+	return secretKeys.salt
+}
+
+// encryptSecret encrypts value for the given storage mode. Passphrase
+// payloads start with their salt.
+func encryptSecret(mode, value string) (string, error) {
+	var key, header []byte
+	var err error
+	switch mode {
+	case secretStorageKeychain:
+		key, err = keychainKey(true)
+	case secretStoragePassphrase:
+		header = encryptionSalt()
+		key, err = passphraseKey(header)
+	default:
+		return "", fmt.Errorf("unknown secret storage %q", mode)
+	}
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	payload := append(append(header, nonce...), gcm.Seal(nil, nonce, []byte(value), nil)...)
+	return encryptedPrefix + mode + ":" + base64.RawStdEncoding.EncodeToString(payload), nil
+}
+
+// decryptSecret returns the plain text of an encrypted secret. Values
+// without the encrypted prefix are returned as they are.
+func decryptSecret(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	mode, encoded, _ := strings.Cut(rest, ":")
+	payload, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decoding encrypted secret: %w", err)
+	}
+	var key []byte
+	switch mode {
+	case secretStorageKeychain:
+		key, err = keychainKey(false)
+	case secretStoragePassphrase:
+		if len(payload) < saltSize {
+			return "", errors.New("encrypted secret is truncated")
+		}
+		key, err = passphraseKey(payload[:saltSize])
+		payload = payload[saltSize:]
+	default:
+		return "", fmt.Errorf("unknown secret storage %q", mode)
+	}
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret is truncated")
+	}
+	plain, err := gcm.Open(nil, payload[:gcm.NonceSize()], payload[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("secret can't be decrypted: wrong key or passphrase")
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptSecrets encrypts the secrets of c as its SecretStorage asks. c
+// should be a copy on its way to disk. Values that are still encrypted,
+// because they couldn't be decrypted at load, are written back unchanged.
+func encryptSecrets(c *AppConfig) error {
+	mode := c.SecretStorage
+	if mode == "" || mode == secretStoragePlain {
+		return nil
+	}
+	var firstErr error
+	mapSecrets(c, func(key, value string) string {
+		if strings.HasPrefix(value, encryptedPrefix) {
+			return value
+		}
+		encrypted, err := encryptSecret(mode, value)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("encrypting %s: %w", key, err)
+			}
+			return value
+		}
+		return encrypted
+	})
+	return firstErr
+}
+
+// decryptSecrets decrypts every encrypted secret in c, whatever its
+// SecretStorage, so switching modes takes effect with the next save.
+// Secrets that can't be decrypted stay encrypted, so saving doesn't lose
+// them, and the first failure is returned.
+func decryptSecrets(c *AppConfig) error {
+	var firstErr error
+	mapSecrets(c, func(key, value string) string {
+		plain, err := decryptSecret(value)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("decrypting %s: %w", key, err)
+			}
+			return value
+		}
+		return plain
+	})
+	return firstErr
+}
+
+// checkSecretStorage reports whether secrets can be stored as mode, for
+// validate.
+func checkSecretStorage(mode string) error {
+	switch mode {
+	case "", secretStoragePlain, secretStorageKeychain:
+		return nil
+	case secretStoragePassphrase:
+		if os.Getenv(passphraseEnv) == "" {
+			return fmt.Errorf("Set %s to encrypt secrets with a passphrase", passphraseEnv)
+		}
+		return nil
+	default:
+		return fmt.Errorf("Secret storage must be plain, keychain or passphrase")
+	}
+}
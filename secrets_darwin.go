@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+)
+
+// keychainService and keychainAccount name the login keychain item that
+// holds the secrets key.
+const (
+	keychainService = "rp-chat-logger"
+	keychainAccount = "secrets-key"
+)
+
+// securityItemNotFound is the exit status of security(1) for a missing
+// item.
+const securityItemNotFound = 44
+
+// systemKeychain keeps the secrets key in the macOS login keychain.
+type systemKeychain struct{}
+
+func (systemKeychain) load() ([]byte, error) {
+	out, err := exec.Command("security", "find-generic-password",
+		"-s", keychainService, "-a", keychainAccount, "-w").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityItemNotFound {
+		return nil, fs.ErrNotExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading keychain: %w", err)
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func (systemKeychain) store(key []byte) error {
+	out, err := exec.Command("security", "add-generic-password", "-U",
+		"-s", keychainService, "-a", keychainAccount,
+		"-w", base64.StdEncoding.EncodeToString(key)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("writing keychain: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+//go:build !windows && !darwin
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+)
+
+// keychainService and keychainAccount are the attributes of the Secret
+// Service item that holds the secrets key.
+const (
+	keychainService = "rp-chat-logger"
+	keychainAccount = "secrets-key"
+)
+
+// systemKeychain keeps the secrets key in the desktop's Secret Service
+// (GNOME Keyring, KWallet) through secret-tool from libsecret.
+type systemKeychain struct{}
+
+func (systemKeychain) load() ([]byte, error) {
+	out, err := exec.Command("secret-tool", "lookup",
+		"service", keychainService, "account", keychainAccount).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(out) == 0 && len(exitErr.Stderr) == 0 {
+		// secret-tool exits with 1 and says nothing when there's no item.
+		return nil, fs.ErrNotExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading Secret Service (is secret-tool installed?): %w", err)
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func (systemKeychain) store(key []byte) error {
+	cmd := exec.Command("secret-tool", "store", "--label=RP Chat Logger secrets key",
+		"service", keychainService, "account", keychainAccount)
+	cmd.Stdin = strings.NewReader(base64.StdEncoding.EncodeToString(key))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("writing Secret Service (is secret-tool installed?): %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memoryKeychain stands in for the OS keychain in tests.
+type memoryKeychain struct{ key []byte }
+
+func (k *memoryKeychain) load() ([]byte, error) {
+	if k.key == nil {
+		return nil, fs.ErrNotExist
+	}
+	return k.key, nil
+}
+
+func (k *memoryKeychain) store(key []byte) error {
+	k.key = key
+	return nil
+}
+
+// useMemoryKeychain swaps in an empty keychain and forgets cached keys
+// until the test ends.
+func useMemoryKeychain(t *testing.T) *memoryKeychain {
+	k := &memoryKeychain{}
+	saved := osKeychain
+	osKeychain = k
+	reset := func() {
+		secretKeys.mu.Lock()
+		secretKeys.keychain, secretKeys.derived, secretKeys.salt = nil, nil, nil
+		secretKeys.mu.Unlock()
+	}
+	reset()
+	t.Cleanup(func() { osKeychain = saved; reset() })
+	return k
+}
+
+func TestSecretStorageRoundTrip(t *testing.T) {
+	for _, mode := range []string{secretStorageKeychain, secretStoragePassphrase} {
+		t.Run(mode, func(t *testing.T) {
+			useMemoryKeychain(t)
+			t.Setenv(passphraseEnv, "correct horse")
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "config.json")
+			setConfigPath(configPath)
+			defer setConfigPath("")
+
+			cfg := AppConfig{
+				EnableDiscord: true,
+				WebhookURL:    "https://discord.com/api/webhooks/1/plaintoken",
+				Sources:       map[string]SourceProfile{"Siptah": {WebhookURL: "https://discord.com/api/webhooks/2/sourcetoken"}},
+				SecretStorage: mode,
+			}
+			if err := saveConfiguration(&cfg); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.WebhookURL != "https://discord.com/api/webhooks/1/plaintoken" {
+				t.Error("saving must not change the config in memory")
+			}
+			data, _ := os.ReadFile(configPath)
+			if strings.Contains(string(data), "plaintoken") || strings.Contains(string(data), "sourcetoken") {
+				t.Fatalf("expected encrypted secrets on disk, got %s", data)
+			}
+			if !strings.Contains(string(data), encryptedPrefix+mode+":") {
+				t.Errorf("expected %s-encrypted values, got %s", mode, data)
+			}
+
+			var loaded AppConfig
+			if err := readConfigFile(configPath, &loaded); err != nil {
+				t.Fatal(err)
+			}
+			if loaded.WebhookURL != cfg.WebhookURL || loaded.Sources["Siptah"].WebhookURL != cfg.Sources["Siptah"].WebhookURL {
+				t.Errorf("expected decrypted secrets, got %+v", loaded)
+			}
+		})
+	}
+}
+
+func TestDecryptSecretErrors(t *testing.T) {
+	useMemoryKeychain(t)
+	t.Setenv(passphraseEnv, "correct horse")
+	encrypted, err := encryptSecret(secretStoragePassphrase, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := decryptSecret("https://example.com/hook"); err != nil || plain != "https://example.com/hook" {
+		t.Errorf("expected a plain value to pass through, got %q, %v", plain, err)
+	}
+
+	t.Setenv(passphraseEnv, "wrong")
+	if _, err := decryptSecret(encrypted); err == nil {
+		t.Error("expected an error for the wrong passphrase")
+	}
+	t.Setenv(passphraseEnv, "")
+	if _, err := decryptSecret(encrypted); err == nil {
+		t.Error("expected an error without a passphrase")
+	}
+	if _, err := decryptSecret(encryptedPrefix + "keychain:AAAA"); err == nil {
+		t.Error("expected an error when the keychain has no key")
+	}
+
+	// A secret that can't be decrypted is kept as it is, so saving the
+	// config again doesn't lose it.
+	cfg := AppConfig{WebhookURL: encrypted, SecretStorage: secretStorageKeychain}
+	if err := decryptSecrets(&cfg); err == nil || cfg.WebhookURL != encrypted {
+		t.Errorf("expected an error and the encrypted value, got %q, %v", cfg.WebhookURL, err)
+	}
+	if err := encryptSecrets(&cfg); err != nil || cfg.WebhookURL != encrypted {
+		t.Errorf("expected the encrypted value to be written back as it is, got %q, %v", cfg.WebhookURL, err)
+	}
+	for _, value := range []string{"enc:other:AAAA", "enc:passphrase:!!", "enc:passphrase:AAAA"} {
+		if _, err := decryptSecret(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestCheckSecretStorage(t *testing.T) {
+	t.Setenv(passphraseEnv, "")
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{"", false},
+		{secretStoragePlain, false},
+		{secretStorageKeychain, false},
+		{secretStoragePassphrase, true},
+		{"rot13", true},
+	}
+	for _, tt := range tests {
+		if err := checkSecretStorage(tt.mode); (err != nil) != tt.wantErr {
+			t.Errorf("checkSecretStorage(%q) = %v, wantErr %v", tt.mode, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// keychainFile (next to the config file) holds the secrets key, sealed
+// with DPAPI so only the current Windows user can unseal it.
+const keychainFile = "secrets.key"
+
+var (
+	crypt32                = syscall.NewLazyDLL("crypt32.dll")
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+
+	procLocalFree = kernel32.NewProc("LocalFree")
+)
+
+// dataBlob mirrors the Win32 DATA_BLOB structure.
+type dataBlob struct {
+	Size uint32
+	Data *byte
+}
+
+func newDataBlob(data []byte) *dataBlob {
+	if len(data) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
+
+// bytes copies the blob out of memory allocated by Windows and frees it.
+func (b *dataBlob) bytes() []byte {
+	data := make([]byte, b.Size)
+	copy(data, unsafe.Slice(b.Data, b.Size))
+	procLocalFree.Call(uintptr(unsafe.Pointer(b.Data)))
+	return data
+}
+
+// systemKeychain protects the secrets key with the Data Protection API.
+type systemKeychain struct{}
+
+func (systemKeychain) path() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), keychainFile)
+}
+
+func (k systemKeychain) load() ([]byte, error) {
+	sealed, err := os.ReadFile(k.path())
+	if err != nil {
+		return nil, err
+	}
+	var out dataBlob
+	r, _, callErr := procCryptUnprotectData.Call(
+		uintptr(unsafe.Pointer(newDataBlob(sealed))), 0, 0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, fmt.Errorf("CryptUnprotectData: %w", callErr)
+	}
+	return out.bytes(), nil
+}
+
+func (k systemKeychain) store(key []byte) error {
+	var out dataBlob
+	r, _, callErr := procCryptProtectData.Call(
+		uintptr(unsafe.Pointer(newDataBlob(key))), 0, 0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return fmt.Errorf("CryptProtectData: %w", callErr)
+	}
+	return os.WriteFile(k.path(), out.bytes(), 0600)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// semVersion is a parsed semantic version (https://semver.org). Build
+// metadata ("+...") is dropped, since it doesn't affect precedence.
+type semVersion struct {
+	major, minor, patch uint64
+	pre                 []string // pre-release identifiers, e.g. ["rc", "1"]
+}
+
+// parseVersion parses a release tag or version string. It accepts what
+// release tags look like in practice besides strict semver: a "v" prefix,
+// a missing minor or patch number ("1.2" is 1.2.0) and a pre-release glued
+// to the patch number ("1.2.0rc1" is 1.2.0-rc1).
+func parseVersion(s string) (semVersion, bool) {
+	var v semVersion
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	core, pre, hasPre := strings.Cut(s, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return v, false
+	}
+	// A pre-release glued to the last number
+	last := parts[len(parts)-1]
+	if i := strings.IndexFunc(last, func(r rune) bool { return r < '0' || r > '9' }); i > 0 && !hasPre {
+		parts[len(parts)-1], pre, hasPre = last[:i], last[i:], true
+	}
+	nums := []*uint64{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		n, ok := parseNumber(part)
+		if !ok {
+			return v, false
+		}
+		*nums[i] = n
+	}
+
+	if hasPre {
+		v.pre = strings.Split(pre, ".")
+		for _, id := range v.pre {
+			if id == "" {
+				return v, false
+			}
+		}
+	}
+	return v, true
+}
+
+// parseNumber parses a non-empty string of digits.
+func parseNumber(s string) (uint64, bool) {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	return n, err == nil
+}
+
+// compareVersions returns -1, 0 or 1 as a is older than, the same as or
+// newer than b.
+func compareVersions(a, b semVersion) int {
+	for _, c := range [][2]uint64{{a.major, b.major}, {a.minor, b.minor}, {a.patch, b.patch}} {
+		if c[0] != c[1] {
+			return cmpUint(c[0], c[1])
+		}
+	}
+	// A pre-release is older than the release itself
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		if c := comparePreIdentifier(a.pre[i], b.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpUint(uint64(len(a.pre)), uint64(len(b.pre)))
+}
+
+// comparePreIdentifier compares pre-release identifiers the semver way,
+// numbers numerically and below words, except that words ending in a
+// number compare that number numerically, so "rc10" is newer than "rc9"
+// (semver would order them as text).
+func comparePreIdentifier(a, b string) int {
+	an, aNum := parseNumber(a)
+	bn, bNum := parseNumber(b)
+	switch {
+	case aNum && bNum:
+		return cmpUint(an, bn)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	aWord, aSuffix := splitTrailingNumber(a)
+	bWord, bSuffix := splitTrailingNumber(b)
+	if aWord != bWord || aSuffix == "" || bSuffix == "" {
+		return strings.Compare(a, b)
+	}
+	an, _ = parseNumber(aSuffix)
+	bn, _ = parseNumber(bSuffix)
+	return cmpUint(an, bn)
+}
+
+// splitTrailingNumber splits "rc10" into "rc" and "10".
+func splitTrailingNumber(s string) (string, string) {
+	i := len(s)
+	for i > 0 && s[i-1] >= '0' && s[i-1] <= '9' {
+		i--
+	}
+	return s[:i], s[i:]
+}
+
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// isNewerVersion reports whether latest is a newer version than current.
+// Versions that don't parse are never newer, and nothing is newer than
+// them, so a malformed tag can't trigger an update.
+func isNewerVersion(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	return compareVersions(l, c) > 0
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestIsNewerVersion(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"1.2.4", "1.2.3", true},
+		{"1.2.3", "1.2.3", false},
+		{"1.2.2", "1.2.3", false},
+		{"v1.3.0", "1.2.9", true},
+		{"1.10.0", "1.9.0", true},
+		{"1.9.0", "1.10.0", false},
+		{"10.0.0", "9.9.9", true},
+		{"2.0", "1.9.9", true},
+		{"1.2", "1.2.0", false},
+		{"1.2.3", "1.2.3-rc.1", true},
+		{"1.2.3-rc.1", "1.2.3", false},
+		{"1.2.3-rc.2", "1.2.3-rc.1", true},
+		{"1.2.3-rc.10", "1.2.3-rc.9", true},
+		{"1.2.3-rc10", "1.2.3-rc9", true},
+		{"1.2.3rc2", "1.2.3-rc1", true},
+		{"1.2.3-rc.1", "1.2.3-beta.2", true},
+		{"1.2.3-beta", "1.2.3-alpha.5", true},
+		{"1.2.3-alpha.1", "1.2.3-alpha", true},
+		{"1.2.3-alpha.beta", "1.2.3-alpha.1", true},
+		{"1.2.3+build.5", "1.2.3+build.4", false},
+		{"1.2.4+build.1", "1.2.3", true},
+		{"1.2.3-rc.1+sha.abc", "1.2.3-rc.1", false},
+		{"latest", "1.2.3", false},
+		{"1.2.3", "custom", false},
+		{"1.2.3.4", "1.2.3", false},
+		{"1.2.3-", "1.2.2", false},
+		{"1.2.3-rc..1", "1.2.2", false},
+		{"99999999999999999999.0.0", "1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := isNewerVersion(tt.latest, tt.current); got != tt.want {
+			t.Errorf("isNewerVersion(%q, %q) = %v, want %v", tt.latest, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	v, ok := parseVersion("v2.5.1-rc.3+20260101")
+	if !ok || v.major != 2 || v.minor != 5 || v.patch != 1 || len(v.pre) != 2 || v.pre[0] != "rc" || v.pre[1] != "3" {
+		t.Errorf("parseVersion = %+v, %v", v, ok)
+	}
+	if _, ok := parseVersion(""); ok {
+		t.Error("empty version parsed")
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RenameResult summarizes a sender rename across the stored logs.
+type RenameResult struct {
+	Entries int `json:"entries"` // log entries whose sender was changed
+	Files   int `json:"files"`   // files rewritten
+	// Alias tells whether the old name became an alias of the new one.
+	Alias bool `json:"alias"`
+}
+
+func (r RenameResult) String() string {
+	s := fmt.Sprintf("%d message(s) in %d file(s)", r.Entries, r.Files)
+	if r.Alias {
+		s += "; the old name is now an alias"
+	}
+	return s
+}
+
+// apiSenderRename is the body of POST /api/v1/senders/rename.
+type apiSenderRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Alias also records From as an alias of To on the Characters page,
+	// so messages still sent under it are shown as To.
+	Alias bool `json:"alias,omitempty"`
+}
+
+// renameSender changes the sender of every stored log entry sent as from
+// (ignoring case) to to: the daily logs, the OOC and per-scene folders and
+// the session transcripts, and the annotations on them. Files without such
+// entries are left alone, and each file is replaced whole, so a failure
+// leaves it as it was. With alias set, from also becomes an alias of the
+// character to.
+func (a *App) renameSender(from, to string, alias bool) (RenameResult, error) {
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == "" || to == "" {
+		return RenameResult{}, fmt.Errorf("Enter the old and the new sender name")
+	}
+	if len(to) > maxCharacterName {
+		return RenameResult{}, fmt.Errorf("Sender names must be at most %d characters", maxCharacterName)
+	}
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	if cfg.Path == "" {
+		return RenameResult{}, fmt.Errorf("No log folder configured")
+	}
+	format := logFormat(&cfg)
+	if cfg.TextTemplate != "" && format != "csv" && format != "json" {
+		// Lines in a custom format can't be told apart from the message.
+		return RenameResult{}, fmt.Errorf("Renaming senders in txt or docx logs needs the default line format; clear the text line template first")
+	}
+
+	a.lockArchive()
+	result, err := renameInArchive(&cfg, format, from, to)
+	a.archiveMu.Unlock()
+	if err != nil {
+		return result, err
+	}
+	if err := a.renameAnnotations(cfg.Path, from, to); err != nil {
+		return result, fmt.Errorf("Messages renamed, but their annotations were not: %w", err)
+	}
+	if alias && !strings.EqualFold(from, to) {
+		err := a.updateCharacters(func(characters []Character) ([]Character, error) {
+			return aliasCharacter(characters, from, to), nil
+		})
+		if err != nil {
+			return result, fmt.Errorf("Messages renamed, but the alias was not saved: %w", err)
+		}
+		result.Alias = true
+	}
+	a.logger.Log("info", fmt.Sprintf("Sender %s renamed to %s: %s", from, to, result))
+	return result, nil
+}
+
+// renameInArchive rewrites the log files under cfg.Path in format,
+// skipping the retention archive.
+func renameInArchive(cfg *AppConfig, format, from, to string) (RenameResult, error) {
+	var result RenameResult
+	err := filepath.WalkDir(cfg.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != cfg.Path && d.Name() == retentionArchiveDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != "."+format {
+			return nil
+		}
+		var n int
+		if format == "csv" || format == "json" {
+			n, err = renameInEntries(path, format, layoutOf(cfg), from, to)
+		} else {
+			n, err = renameInTextLog(cfg, path, from, to)
+		}
+		if err != nil {
+			return fmt.Errorf("renaming in %s: %w", filepath.Base(path), err)
+		}
+		if n > 0 {
+			result.Entries += n
+			result.Files++
+		}
+		return nil
+	})
+	return result, err
+}
+
+// renameInEntries renames the sender in a csv or json log, returning how
+// many entries changed.
+func renameInEntries(filename, format string, layout logLayout, from, to string) (int, error) {
+	entries, err := readLogFile(filename, format)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := range entries {
+		if strings.EqualFold(entries[i].Sender, from) {
+			entries[i].Sender = to
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, writeLogFile(filename, format, layout, entries)
+}
+
+// renameInTextLog renames the sender in a txt or docx log written by
+// formatTextLine. Lines are matched by their sender prefix, since emote
+// lines don't mark where a name of several words ends; other lines, such
+// as the rest of a message over several lines, are kept as they are.
+func renameInTextLog(cfg *AppConfig, filename, from, to string) (int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, fmt.Errorf("reading log file: %w", err)
+	}
+	known := textLogSenders(cfg, string(data))
+	var b strings.Builder
+	n := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line, ok := renameTextLine(scanner.Text(), from, to, known)
+		if ok {
+			n++
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading log file: %w", err)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("writing log file: %w", err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("replacing log file: %w", err)
+	}
+	return n, nil
+}
+
+// renameTextLine returns line with from replaced by to when it is a log
+// line sent by from, and whether it was. known are the other senders, see
+// textLineFrom.
+func renameTextLine(line, from, to string, known []string) (string, bool) {
+	timestamp, prefix, rest, ok := textLineFrom(line, from, known)
+	if !ok {
+		return line, false
+	}
+	return "[" + timestamp + "] " + prefix + to + rest, true
+}
+
+// textLineFrom splits a log line written by formatTextLine and sent by
+// name (ignoring case) into its timestamp, the marker before the name and
+// the text after it. ok is false for other lines. An emote line doesn't
+// mark where its sender ends, so one that starts with a longer name among
+// known, as "* Alice Smith waves" does for Alice, is taken to be theirs.
+func textLineFrom(line, name string, known []string) (timestamp, prefix, rest string, ok bool) {
+	if !strings.HasPrefix(line, "[") {
+		return "", "", "", false
+	}
+	timestamp, rest, ok = strings.Cut(line[1:], "] ")
+	if !ok {
+		return "", "", "", false
+	}
+	end := ": "
+	switch {
+	case strings.HasPrefix(rest, "* "):
+		prefix, end = "* ", " "
+	case strings.HasPrefix(rest, "(OOC) "):
+		prefix = "(OOC) "
+	}
+	rest = rest[len(prefix):]
+	if !hasSenderPrefix(rest, name, end) {
+		return "", "", "", false
+	}
+	if prefix == "* " {
+		for _, other := range known {
+			if len(other) > len(name) && hasSenderPrefix(rest, other, end) {
+				return "", "", "", false
+			}
+		}
+	}
+	return timestamp, prefix, rest[len(name):], true
+}
+
+// hasSenderPrefix reports whether text starts with name (ignoring case)
+// followed by end.
+func hasSenderPrefix(text, name, end string) bool {
+	return len(text) >= len(name)+len(end) && strings.EqualFold(text[:len(name)], name) && strings.HasPrefix(text[len(name):], end)
+}
+
+// textLogSenders returns the names that tell emote lines in a text log
+// apart: the characters and their aliases, and the senders of the other
+// lines in data, which do mark where a name ends.
+func textLogSenders(cfg *AppConfig, data string) []string {
+	var known []string
+	for _, ch := range cfg.Characters {
+		known = append(known, ch.Name)
+		known = append(known, ch.Aliases...)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		if entry, ok := parseTextLine(strings.TrimSuffix(line, "\r")); ok && entry.Kind != kindEmote {
+			known = append(known, entry.Sender)
+		}
+	}
+	return known
+}
+
+// aliasCharacter returns characters with from recorded as an alias of the
+// character to: added to its aliases, or to a new character named to. A
+// character named from is folded into it, or, without a character to,
+// renamed.
+func aliasCharacter(characters []Character, from, to string) []Character {
+	fromIdx, toIdx := -1, -1
+	for i, ch := range characters {
+		switch {
+		case strings.EqualFold(ch.Name, from):
+			fromIdx = i
+		case strings.EqualFold(ch.Name, to):
+			toIdx = i
+		}
+	}
+	switch {
+	case toIdx < 0 && fromIdx < 0:
+		return append(characters, Character{Name: to, Aliases: []string{from}})
+	case toIdx < 0:
+		ch := &characters[fromIdx]
+		ch.Name, ch.Aliases = to, append(ch.Aliases, from)
+		return characters
+	}
+
+	ch := &characters[toIdx]
+	if !containsFold(ch.Aliases, from) {
+		ch.Aliases = append(ch.Aliases, from)
+	}
+	if fromIdx < 0 {
+		return characters
+	}
+	old := characters[fromIdx]
+	for _, alias := range old.Aliases {
+		if !containsFold(ch.Aliases, alias) && !strings.EqualFold(alias, ch.Name) {
+			ch.Aliases = append(ch.Aliases, alias)
+		}
+	}
+	if ch.DiscordID == "" {
+		ch.DiscordID = old.DiscordID
+	}
+	if ch.AvatarURL == "" {
+		ch.AvatarURL = old.AvatarURL
+	}
+	if ch.Color == "" {
+		ch.Color = old.Color
+	}
+	return append(characters[:fromIdx], characters[fromIdx+1:]...)
+}
+
+// containsFold reports whether list holds s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleRenameSender renames a sender from the Characters page.
+func (a *App) handleRenameSender(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	from, to := r.FormValue("from"), r.FormValue("to")
+	result, err := a.renameSender(from, to, r.FormValue("alias") == "on")
+	if err != nil {
+		fmt.Fprintf(w, `<div class="alert error">Rename failed: %s</div>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
+	if result.Alias {
+		// The character list changed too.
+		w.Header().Set("HX-Trigger", "characters-changed")
+	}
+	fmt.Fprintf(w, `<div class="alert success">Renamed %s to %s: %s</div>`,
+		template.HTMLEscapeString(strings.TrimSpace(from)), template.HTMLEscapeString(strings.TrimSpace(to)), result)
+}
+
+// handleAPIRenameSender renames a sender across the stored logs.
+func (a *App) handleAPIRenameSender(w http.ResponseWriter, r *http.Request) {
+	var body apiSenderRename
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	if strings.TrimSpace(body.From) == "" || strings.TrimSpace(body.To) == "" {
+		writeJSONError(w, http.StatusBadRequest, "from and to are required")
+		return
+	}
+	result, err := a.renameSender(body.From, body.To, body.Alias)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenameTextLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+		ok   bool
+	}{
+		{"[2026-10-17 21:04:05] conan_cimmeria: By Crom!", "[2026-10-17 21:04:05] Conan: By Crom!", true},
+		{"[2026-10-17 21:04:05] * Conan_Cimmeria draws his sword", "[2026-10-17 21:04:05] * Conan draws his sword", true},
+		{"[2026-10-17 21:04:05] (OOC) conan_cimmeria: brb", "[2026-10-17 21:04:05] (OOC) Conan: brb", true},
+		{"[2026-10-17 21:04:05] conan_cimmeria2: hi", "[2026-10-17 21:04:05] conan_cimmeria2: hi", false},
+		{"[2026-10-17 21:04:05] Valeria: conan_cimmeria: hi", "[2026-10-17 21:04:05] Valeria: conan_cimmeria: hi", false},
+		{"conan_cimmeria: a continuation line", "conan_cimmeria: a continuation line", false},
+		{"[2026-10-17 21:04:05] * conan_cimmeria the_younger waves", "[2026-10-17 21:04:05] * conan_cimmeria the_younger waves", false},
+	}
+	known := []string{"conan_cimmeria the_younger"}
+	for _, tt := range tests {
+		got, ok := renameTextLine(tt.line, "conan_cimmeria", "Conan", known)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("renameTextLine(%q) = %q, %v; want %q, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRenameSender(t *testing.T) {
+	oldConfigPath := configPathOverride
+	configPathOverride = filepath.Join(t.TempDir(), "config.json")
+	defer func() { configPathOverride = oldConfigPath }()
+
+	dir := t.TempDir()
+	daily := filepath.Join(dir, "ConanExiles_log_2026-10-17.txt")
+	session := filepath.Join(dir, "sessions", "Chapter 3.txt")
+	untouched := filepath.Join(dir, "ConanExiles_log_2026-10-16.txt")
+	files := map[string]string{
+		daily:     "[2026-10-17 21:04:05] conan_cimmeria: By Crom!\nthe tavern burns\n[2026-10-17 21:04:06] Valeria: Run!\n",
+		session:   "[2026-10-17 21:04:05] * conan_cimmeria draws his sword\n",
+		untouched: "[2026-10-16 20:00:00] Valeria: Hush.\n",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(name), 0755)
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, _ := os.Stat(untouched)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+	a.config.Characters = []Character{{Name: "Conan", Color: "#c0392b"}}
+
+	result, err := a.renameSender(" conan_cimmeria ", "Conan", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (RenameResult{Entries: 2, Files: 2, Alias: true}) {
+		t.Errorf("result = %+v", result)
+	}
+	if data, _ := os.ReadFile(daily); string(data) != "[2026-10-17 21:04:05] Conan: By Crom!\nthe tavern burns\n[2026-10-17 21:04:06] Valeria: Run!\n" {
+		t.Errorf("daily log = %q", data)
+	}
+	if data, _ := os.ReadFile(session); string(data) != "[2026-10-17 21:04:05] * Conan draws his sword\n" {
+		t.Errorf("session transcript = %q", data)
+	}
+	if after, _ := os.Stat(untouched); !after.ModTime().Equal(before.ModTime()) {
+		t.Error("a log without the sender was rewritten")
+	}
+	want := []Character{{Name: "Conan", Aliases: []string{"conan_cimmeria"}, Color: "#c0392b"}}
+	if !reflect.DeepEqual(a.config.Characters, want) {
+		t.Errorf("characters = %+v", a.config.Characters)
+	}
+
+	a.config.TextTemplate = "{{.Sender}} > {{.Message}}"
+	if _, err := a.renameSender("Valeria", "Val", false); err == nil {
+		t.Error("renamed in logs written with a custom line template")
+	}
+}
+
+func TestRenameSenderCSV(t *testing.T) {
+	dir := t.TempDir()
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+	a.config.FileFormat = "csv"
+	filename := filepath.Join(dir, "ConanExiles_log_2026-10-17.csv")
+	for _, entry := range []LogEntry{
+		{Timestamp: "2026-10-17 21:04:05", Sender: "Amra", Message: "By Crom, a comma"},
+		{Timestamp: "2026-10-17 21:04:06", Sender: "Valeria", Message: "Run!", Kind: kindOOC},
+	} {
+		if err := writeLogEntry(filename, "csv", layoutOf(a.config), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mux := apiTestServer(a)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/senders/rename", strings.NewReader(`{"from": "amra", "to": "Conan"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result RenameResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil || result.Entries != 1 || result.Alias {
+		t.Errorf("result = %+v, %v", result, err)
+	}
+	entries, err := readLogFile(filename, "csv")
+	if err != nil || len(entries) != 2 || entries[0].Sender != "Conan" || entries[0].Message != "By Crom, a comma" || entries[1].Kind != kindOOC {
+		t.Errorf("entries = %+v, %v", entries, err)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/senders/rename", strings.NewReader(`{"from": "Conan"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("without to: expected 400, got %d", rec.Code)
+	}
+}
+
+func TestAliasCharacter(t *testing.T) {
+	tests := []struct {
+		name       string
+		characters []Character
+		want       []Character
+	}{
+		{"new character", nil, []Character{{Name: "Conan", Aliases: []string{"amra"}}}},
+		{"existing character", []Character{{Name: "conan", Aliases: []string{"Cimmerian"}}},
+			[]Character{{Name: "conan", Aliases: []string{"Cimmerian", "amra"}}}},
+		{"old name renamed", []Character{{Name: "Amra", Color: "#c0392b"}},
+			[]Character{{Name: "Conan", Aliases: []string{"amra"}, Color: "#c0392b"}}},
+		{"both merged", []Character{
+			{Name: "Amra", Aliases: []string{"pirate"}, DiscordID: "42"},
+			{Name: "Conan", Color: "#c0392b"},
+		}, []Character{{Name: "Conan", Aliases: []string{"amra", "pirate"}, DiscordID: "42", Color: "#c0392b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := aliasCharacter(tt.characters, "amra", "Conan")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+			if err := checkCharacters(got); err != nil {
+				t.Errorf("result is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestRenameSender_KeepsAnnotations(t *testing.T) {
+	setConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	defer setConfigPath("")
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.receipts = newReceiptTable(10)
+	dir := t.TempDir()
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+	a.config.FileFormat = "json"
+
+	id := a.processMessage(context.Background(), IncomingMessage{Sender: "amra", Message: "By Crom!"})
+	waitForDeliveries(t, a)
+	if _, err := a.annotate(id, annotationNote, "First words of the saga", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.renameSender("Amra", "Conan", false); err != nil {
+		t.Fatal(err)
+	}
+	// Kept in the log folder, where renaming in json logs passes it by
+	annotations, err := loadAnnotations(annotationsPath(dir))
+	if err != nil || len(annotations) != 1 {
+		t.Fatalf("annotations after the rename = %+v, %v", annotations, err)
+	}
+	entries, err := readLogFile(generateLogFilename(dir, "json"), "json")
+	if err != nil || len(entries) != 1 || entries[0].Sender != "Conan" {
+		t.Fatalf("entries = %+v, %v", entries, err)
+	}
+	if notes := applyAnnotations(entries, annotations); len(notes[noteKey(entries[0])]) != 1 {
+		t.Errorf("annotation no longer on the renamed message: %+v", annotations)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SenderRoute sends one sender's messages somewhere else as well, on top of
+// the usual routing: a villain's lines to the GM's private channel, say.
+type SenderRoute struct {
+	// WebhookURL gets a copy of the sender's Discord posts; a channel ID in
+	// bot mode.
+	WebhookURL string `json:"webhookURL,omitempty"`
+	// Path gets a copy of the sender's log entries, in the usual format.
+	Path string `json:"path,omitempty"`
+}
+
+// senderRoute returns the route for messages from sender, matched ignoring
+// case by the sender's name or by the name of its character.
+func senderRoute(cfg *AppConfig, sender string) (SenderRoute, bool) {
+	if len(cfg.SenderRoutes) == 0 {
+		return SenderRoute{}, false
+	}
+	sender = strings.TrimSpace(sender)
+	character := displayName(cfg, sender)
+	for _, name := range sortedKeys(cfg.SenderRoutes) {
+		if strings.EqualFold(name, sender) || strings.EqualFold(name, character) {
+			return cfg.SenderRoutes[name], true
+		}
+	}
+	return SenderRoute{}, false
+}
+
+// senderDiscordTarget returns where copies of sender's Discord posts go, or
+// "" when they don't.
+func senderDiscordTarget(cfg *AppConfig, sender string) string {
+	route, ok := senderRoute(cfg, sender)
+	if !ok || route.WebhookURL == "" {
+		return ""
+	}
+	return discordRouteURL(cfg, route.WebhookURL)
+}
+
+// parseSenderRoutes parses the web UI's sender route lines, one
+// "Name = webhook=URL; path=dir" per line.
+func parseSenderRoutes(text string) (map[string]SenderRoute, error) {
+	lines := parseNameMap(text)
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	routes := make(map[string]SenderRoute, len(lines))
+	for name, options := range lines {
+		var route SenderRoute
+		for _, option := range strings.Split(options, ";") {
+			key, value, _ := strings.Cut(option, "=")
+			key = strings.TrimSpace(key)
+			value = strings.TrimSpace(value)
+			switch key {
+			case "":
+				continue
+			case "webhook":
+				route.WebhookURL = value
+			case "path":
+				route.Path = value
+			default:
+				return nil, fmt.Errorf("Unknown setting %q for sender %s", key, name)
+			}
+		}
+		routes[name] = route
+	}
+	return routes, nil
+}
+
+// formatSenderRoutes renders sender routes as sorted lines for the web UI.
+func formatSenderRoutes(routes map[string]SenderRoute) string {
+	var b strings.Builder
+	for _, name := range sortedKeys(routes) {
+		route := routes[name]
+		var options []string
+		if route.WebhookURL != "" {
+			options = append(options, "webhook="+route.WebhookURL)
+		}
+		if route.Path != "" {
+			options = append(options, "path="+route.Path)
+		}
+		fmt.Fprintf(&b, "%s = %s\n", name, strings.Join(options, "; "))
+	}
+	return b.String()
+}
+
+// checkSenderRoutes reports a route that sends nowhere, or a sender with
+// two routes.
+func checkSenderRoutes(routes map[string]SenderRoute) error {
+	seen := make(map[string]string)
+	for _, name := range sortedKeys(routes) {
+		route := routes[name]
+		if route.WebhookURL == "" && route.Path == "" {
+			return fmt.Errorf("Route for sender %s needs a webhook or a path", name)
+		}
+		key := strings.ToLower(name)
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("Senders %s and %s have the same name", other, name)
+		}
+		seen[key] = name
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSenderRoutes(t *testing.T) {
+	text := "Thulsa Doom = webhook=https://discord.com/api/webhooks/1/a; path=/logs/villain\nRexor = path=/logs/rexor\n"
+	routes, err := parseSenderRoutes(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]SenderRoute{
+		"Thulsa Doom": {WebhookURL: "https://discord.com/api/webhooks/1/a", Path: "/logs/villain"},
+		"Rexor":       {Path: "/logs/rexor"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("got %+v, want %+v", routes, want)
+	}
+	if got := formatSenderRoutes(routes); got != "Rexor = path=/logs/rexor\n"+
+		"Thulsa Doom = webhook=https://discord.com/api/webhooks/1/a; path=/logs/villain\n" {
+		t.Errorf("formatSenderRoutes = %q", got)
+	}
+
+	if _, err := parseSenderRoutes("Rexor = channel=5"); err == nil {
+		t.Error("unknown setting accepted")
+	}
+	if routes, err := parseSenderRoutes(""); err != nil || routes != nil {
+		t.Errorf("empty text = %v, %v", routes, err)
+	}
+}
+
+func TestCheckSenderRoutes(t *testing.T) {
+	if err := checkSenderRoutes(map[string]SenderRoute{"Rexor": {}}); err == nil {
+		t.Error("route to nowhere accepted")
+	}
+	if err := checkSenderRoutes(map[string]SenderRoute{"Rexor": {Path: "a"}, "rexor": {Path: "b"}}); err == nil {
+		t.Error("duplicate sender accepted")
+	}
+	if err := checkSenderRoutes(map[string]SenderRoute{"Rexor": {Path: "a"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSenderRoute(t *testing.T) {
+	cfg := &AppConfig{
+		Characters:   []Character{{Name: "Thulsa Doom", Aliases: []string{"doom_gm"}}},
+		SenderRoutes: map[string]SenderRoute{"thulsa doom": {WebhookURL: "123"}},
+		DiscordMode:  discordModeBot,
+		BotToken:     "token",
+	}
+	for _, sender := range []string{"Thulsa Doom", "THULSA DOOM", "doom_gm"} {
+		if got := senderDiscordTarget(cfg, sender); got != botChannelURL("token", "123") {
+			t.Errorf("senderDiscordTarget(%q) = %q", sender, got)
+		}
+	}
+	if _, ok := senderRoute(cfg, "Conan"); ok {
+		t.Error("unrouted sender has a route")
+	}
+}
+
+func TestProcessMessage_SenderRoute(t *testing.T) {
+	bot := newFakeDiscordBot(t)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	tmpDir := t.TempDir()
+	villainDir := filepath.Join(tmpDir, "villain")
+	a.config.EnableDiscord = true
+	a.config.DiscordMode = discordModeBot
+	a.config.BotToken = "secret"
+	a.config.BotChannelID = "100"
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+	a.config.SenderRoutes = map[string]SenderRoute{"Thulsa Doom": {WebhookURL: "300", Path: villainDir}}
+
+	a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "Crom!"})
+	a.processMessage(context.Background(), IncomingMessage{Sender: "Thulsa Doom", Message: "Come to me, my child"})
+	waitForDeliveries(t, a)
+
+	if got := bot.contents("100"); len(got) != 2 {
+		t.Errorf("main channel got %q, want both messages", got)
+	}
+	if got := bot.contents("300"); len(got) != 1 || !strings.Contains(got[0], "my child") {
+		t.Errorf("sender channel got %q, want Thulsa Doom's message", got)
+	}
+	main, err := os.ReadFile(generateLogFilename(tmpDir, "txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(main), "Crom!") || !strings.Contains(string(main), "my child") {
+		t.Errorf("main log misses a message:\n%s", main)
+	}
+	copied, err := os.ReadFile(generateLogFilename(villainDir, "txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(copied), "Crom!") || !strings.Contains(string(copied), "my child") {
+		t.Errorf("sender log should hold only Thulsa Doom's message:\n%s", copied)
+	}
+}
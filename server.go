@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -14,7 +16,8 @@ type Logger interface {
 	Log(level, message string)
 }
 
-// StartIngestionServer creates and starts the message ingestion HTTP server.
+// StartIngestionServer creates and starts the message ingestion HTTP server,
+// plus one extra listener for every source profile with a listen address.
 func (a *App) StartIngestionServer() error {
 	a.ingestionMu.Lock()
 	defer a.ingestionMu.Unlock()
@@ -27,58 +30,155 @@ func (a *App) StartIngestionServer() error {
 	addr := a.config.ListenAddr
 	enableDiscord := a.config.EnableDiscord
 	enableLocalSave := a.config.EnableLocalSave
+	enableForward := a.config.EnableForward
+	sources := a.config.Sources
+	udpAddr := a.config.UDPListenAddr
+	udpPattern := a.config.UDPPattern
+	tailPath := a.config.TailPath
+	tailPattern := a.config.TailPattern
+	if tailPattern == "" {
+		tailPattern = tailPatternFor(a.config)
+	}
+	logDirs := logFolders(a.config)
 	a.configMu.RUnlock()
 
-	// Prevent starting if neither output option is enabled
-	if !enableDiscord && !enableLocalSave {
-		return fmt.Errorf("cannot start server: no output options are enabled. Enable either Discord notifications or file logging")
+	// Prevent starting if no output option is enabled
+	if !enableDiscord && !enableLocalSave && !enableForward {
+		return fmt.Errorf("cannot start server: no output options are enabled. Enable Discord notifications, file logging or forwarding")
 	}
+	// Warn when another instance writes to the same folders
+	a.lockLogFolders(logDirs)
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/message", createHandler(a))
+	mux.Handle("/message", a.withIPFilter(ingestIPLists, false,
+		a.withCORS(a.withToken(scopeIngest, ingestTokenRequired, createHandler(a)))))
+	mux.HandleFunc("GET /healthz", a.handleHealthz)
+	mux.HandleFunc("GET /readyz", a.handleReadyz)
+	a.registerLiveRoutes(mux)
+
+	handler := a.withAccessLog("ingestion", mux)
 
-	a.ingestionServer = &http.Server{
+	primary := &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: handler,
 	}
+	a.ingestionServers = []*http.Server{primary}
 
 	a.ingestionWg.Add(1)
 	a.ingestionRunning.Store(true)
 
 	go func() {
 		defer a.ingestionWg.Done()
-		log.Printf("Ingestion server started at http://%s/", addr)
+		slog.Info("Ingestion server started", "url", "http://"+addr+"/")
 		a.logger.Log("info", fmt.Sprintf("Ingestion server started on %s", addr))
-		if err := a.ingestionServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("Could not listen on %s: %v", addr, err)
+		if err := primary.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Could not listen", "addr", addr, "err", err)
 			a.logger.Log("error", fmt.Sprintf("Server failed: %v", err))
 		}
 		a.ingestionRunning.Store(false)
 	}()
+	go a.announceLifecycle(true)
+
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	if cfg.EnableTunnel {
+		if err := a.startTunnel(&cfg); err != nil {
+			slog.Error("Tunnel failed", "err", err)
+			a.logger.Log("error", fmt.Sprintf("Tunnel failed: %v", err))
+		}
+	}
+	a.startDiscovery(&cfg)
+
+	for _, name := range sortedSourceNames(sources) {
+		if sourceAddr := sources[name].ListenAddr; sourceAddr != "" {
+			a.startSourceListener(name, sourceAddr, handler)
+		}
+	}
+
+	if udpAddr != "" {
+		if err := a.startUDPListener(udpAddr, udpPattern); err != nil {
+			slog.Error("UDP listener failed", "err", err)
+			a.logger.Log("error", fmt.Sprintf("UDP listener failed: %v", err))
+		}
+	}
+
+	if tailPath != "" {
+		if err := a.startLogTail(tailPath, tailPattern); err != nil {
+			slog.Error("Game log tailing failed", "err", err)
+			a.logger.Log("error", fmt.Sprintf("Game log tailing failed: %v", err))
+		}
+	}
 
 	return nil
 }
 
-// StopIngestionServer gracefully shuts down the message ingestion server.
+// startSourceListener serves the ingestion handler on an extra address,
+// tagging every request with the source name. A listener that fails to
+// bind is logged but does not stop the others. Call with ingestionMu held.
+func (a *App) startSourceListener(source, addr string, handler http.Handler) {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+		BaseContext: func(net.Listener) context.Context {
+			return withSource(context.Background(), source)
+		},
+	}
+	a.ingestionServers = append(a.ingestionServers, srv)
+
+	a.ingestionWg.Add(1)
+	go func() {
+		defer a.ingestionWg.Done()
+		a.logger.Log("info", fmt.Sprintf("Listener for source %s started on %s", source, addr))
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Could not listen", "addr", addr, "err", err)
+			a.logger.Log("error", fmt.Sprintf("Listener for source %s failed: %v", source, err))
+		}
+	}()
+}
+
+// StopIngestionServer gracefully shuts down the message ingestion server
+// along with any source, UDP and game log listeners.
 func (a *App) StopIngestionServer() error {
 	a.ingestionMu.Lock()
-	srv := a.ingestionServer
+	servers := a.ingestionServers
+	a.ingestionServers = nil
+	udpConn := a.udpConn
+	a.udpConn = nil
+	tailStop := a.tailStop
+	a.tailStop = nil
 	a.ingestionMu.Unlock()
 
-	if srv == nil {
+	a.stopTunnel()
+	a.stopDiscovery()
+	if len(servers) == 0 && udpConn == nil && tailStop == nil {
 		return nil
 	}
+	if udpConn != nil {
+		udpConn.Close()
+	}
+	if tailStop != nil {
+		close(tailStop)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		return fmt.Errorf("shutting down ingestion server: %w", err)
+	var shutdownErr error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil && shutdownErr == nil {
+			shutdownErr = fmt.Errorf("shutting down ingestion server: %w", err)
+		}
+	}
+	if shutdownErr != nil {
+		return shutdownErr
 	}
 
 	a.ingestionWg.Wait()
+	a.unlockLogFolders()
+	a.announceLifecycle(false)
 	a.logger.Log("info", "Ingestion server stopped")
-	log.Println("Ingestion server stopped.")
+	slog.Info("Ingestion server stopped")
 	return nil
 }
 
@@ -86,90 +186,440 @@ func (a *App) StopIngestionServer() error {
 // and routes them to Discord and/or local file logging based on the config.
 func createHandler(a *App) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
+		trace := requestTrace(r)
+		ctx := withTrace(r.Context(), trace)
 
 		// Log incoming request details
 		if a.logger != nil {
-			a.logger.Log("debug", fmt.Sprintf("HTTP %s %s from %s", r.Method, r.URL.String(), r.RemoteAddr))
-			a.logger.Log("debug", fmt.Sprintf("User-Agent: %s", r.UserAgent()))
+			a.logger.Log("debug", traced(trace, fmt.Sprintf("HTTP %s %s from %s", r.Method, r.URL.String(), r.RemoteAddr)))
+			a.logger.Log("debug", traced(trace, fmt.Sprintf("User-Agent: %s", r.UserAgent())))
 		}
 
-		// Snapshot config under read lock to avoid races with UI writes.
 		a.configMu.RLock()
-		cfg := *a.config
+		adapter := inputAdapterFor(a.config)
 		a.configMu.RUnlock()
 
+		in := adapter.Parse(r)
+		if in.Source == "" {
+			in.Source = requestSource(r)
+		}
+		in.Forwarded = r.Header.Get(forwardedHeader) != ""
+		in.Trace = trace
 		if a.logger != nil {
-			a.logger.Log("debug", fmt.Sprintf("Config: Discord=%v, LocalSave=%v, Path=%s, Format=%s",
-				cfg.EnableDiscord, cfg.EnableLocalSave, cfg.Path, cfg.FileFormat))
+			a.logger.Log("debug", traced(trace, fmt.Sprintf("Parsed: sender=%q, message=%q, scene=%q, source=%q", in.Sender, in.Message, in.Scene, in.Source)))
+		}
+		var id string
+		if in.Message == "" || a.allowMessage(ctx, r.RemoteAddr, in.Sender) {
+			id = a.processMessage(ctx, in)
 		}
 
-		sender, message := parseMessage(r)
+		// Always responds 200 OK to prevent the game from crashing, even if there are internal errors.
+		response := map[string]string{"status": "ok", "trace": trace}
+		if id != "" {
+			response["id"] = id
+		}
+
+		w.Header().Set(traceHeader, trace)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("Failed to encode response", "err", err)
+		}
+	}
+}
+
+// IncomingMessage is a chat message received by any ingestion listener.
+type IncomingMessage struct {
+	Sender  string
+	Message string
+	Scene   string
+	Source  string
+	// Rich is the message with its rich-text tags, for Discord to convert
+	// to markdown; Message has them stripped. See cleanRichText.
+	Rich string
+	// Forwarded is set when another logger instance relayed the message,
+	// so it is never forwarded again.
+	Forwarded bool
+	// Trace identifies the message in logs, failures and queues; one is
+	// assigned when empty.
+	Trace string
+}
+
+// processMessage routes a received chat message to Discord, local file
+// logging and forwarding according to the config. Failures are logged and
+// queued for retry, never returned: senders can't act on them. It returns
+// the message's receipt ID, or "" when the message was not accepted or
+// receipts are not tracked.
+func (a *App) processMessage(ctx context.Context, in IncomingMessage) string {
+	// Snapshot config under read lock to avoid races with UI writes.
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	if in.Trace == "" {
+		in.Trace = newMessageID()
+	}
+	trace := in.Trace
+	ctx = withTrace(ctx, trace)
+
+	if a.logger != nil {
+		a.logger.Log("debug", traced(trace, fmt.Sprintf("Config: Discord=%v, LocalSave=%v, Path=%s, Format=%s, Forward=%v",
+			cfg.EnableDiscord, cfg.EnableLocalSave, cfg.Path, cfg.FileFormat, cfg.EnableForward)))
+	}
+
+	if in.Message == "" {
+		if a.logger != nil {
+			a.logger.Log("debug", traced(trace, "No message content, skipping processing"))
+		}
+		return ""
+	}
+
+	a.lastMessage.Store(time.Now().UnixNano())
+
+	ignored := ignoredSender(&cfg, in.Sender)
+	if ignored && cfg.IgnoredSenderPolicy != ignoreLocal {
+		if a.logger != nil {
+			a.logger.Log("debug", traced(trace, fmt.Sprintf("Message from ignored sender %q dropped", truncateForDisplay(in.Sender, 64))))
+		}
+		return ""
+	}
+
+	in, err := checkMessageLimits(&cfg, in)
+	if err != nil {
 		if a.logger != nil {
-			a.logger.Log("debug", fmt.Sprintf("Parsed: sender=%q, message=%q", sender, message))
+			a.logger.Log("warning", traced(trace, fmt.Sprintf("Message from %q rejected: %v", truncateForDisplay(in.Sender, 64), err)))
 		}
+		return ""
+	}
+	transformed, ok := a.transformMessage(&cfg, in.Sender, in.Message, time.Now())
+	plain, rich := cleanRichText(&cfg, transformed)
+	if !ok || strings.TrimSpace(plain) == "" {
+		if a.logger != nil {
+			a.logger.Log("debug", traced(trace, fmt.Sprintf("Message from %q dropped by the transforms", truncateForDisplay(in.Sender, 64))))
+		}
+		return ""
+	}
+	in.Message, in.Rich = plain, rich
+	sender, message, scene, source := in.Sender, in.Message, in.Scene, in.Source
+	id := a.receipts.add(sender, source, trace)
 
-		if message != "" {
-			a.logger.Log("info", fmt.Sprintf("Message from %s: %s", sender, message))
+	a.logger.Log("info", traced(trace, fmt.Sprintf("Message from %s: %s", sender, message)))
+	a.publishChat(&cfg, in)
 
-			if cfg.EnableDiscord {
+	// Deliveries outlive the request that brought the message in.
+	ctx = context.WithoutCancel(ctx)
+	a.deliveries.setLimit(cfg.DeliveryQueueSize, cfg.DeliveryDropPolicy)
+	translation := a.translation(ctx, &cfg, trace, message)
+
+	ooc := detectOOC(&cfg, message)
+	_, quiet := quietUntil(&cfg, time.Now())
+	if cfg.EnableDiscord && ignored {
+		if a.logger != nil {
+			a.logger.Log("debug", traced(trace, "Message from ignored sender kept out of Discord"))
+		}
+	} else if cfg.EnableDiscord && ooc && cfg.OOCDiscordPolicy == oocExclude {
+		if a.logger != nil {
+			a.logger.Log("debug", traced(trace, "OOC message excluded from Discord"))
+		}
+	} else if cfg.EnableDiscord && quiet && cfg.QuietHoursPolicy != quietQueue {
+		if a.logger != nil {
+			a.logger.Log("debug", traced(trace, "Quiet hours, message not posted to Discord"))
+		}
+	} else if cfg.EnableDiscord && cfg.EnableRelay && a.relayEchoes.echo(message, time.Now()) {
+		// The game echoed a message relayed from Discord; it is already there.
+		if a.logger != nil {
+			a.logger.Log("debug", traced(trace, "Relayed Discord message echoed by the game, not posting it again"))
+		}
+	} else if cfg.EnableDiscord {
+		webhookURL := sourceWebhookURL(&cfg, source)
+		if len(cfg.LanguageRoutes) > 0 {
+			lang := detectLanguage(message)
+			if target := languageDiscordTarget(&cfg, lang); target != "" {
 				if a.logger != nil {
-					// Redact webhook URL for security, show only host
-					a.logger.Log("debug", "Sending to Discord webhook")
-				}
-				rateLimited, retryAfter, err := sendToDiscord(ctx, cfg.WebhookURL, sender, message)
-				if err != nil {
-					if rateLimited {
-						// Queue for retry
-						a.discordQueue.Add(QueuedMessage{
-							WebhookURL: cfg.WebhookURL,
-							Sender:     sender,
-							Message:    message,
-							RetryAt:    time.Now().Add(retryAfter),
-							Attempts:   1,
-						})
-						if a.logger != nil {
-							a.logger.Log("info", fmt.Sprintf("Discord rate limited, message queued for retry in %v", retryAfter))
-						}
-					} else {
-						log.Printf("Failed to send message to Discord: %v", err)
-						if a.logger != nil {
-							a.logger.Log("error", fmt.Sprintf("Discord send failed: %v", err))
-							a.logger.LogFailure(sender, message, "discord", err.Error())
-						}
-					}
-					// Don't return error - game crashes on non-200 responses
-				} else if a.logger != nil {
-					a.logger.Log("debug", "Discord webhook returned success")
+					a.logger.Log("debug", traced(trace, fmt.Sprintf("Message detected as %s, posting to its channel", lang)))
 				}
+				webhookURL = target
 			}
+		}
+		if ooc && cfg.OOCDiscordPolicy == oocSeparate {
+			webhookURL = oocDiscordTarget(&cfg)
+		}
+		a.receipts.set(id, sinkDiscord, deliveryPending)
+		a.deliveries.add(sinkDiscord+" "+webhookURL, a.newDelivery(id, sinkDiscord, trace, func() {
+			a.deliverToDiscord(ctx, &cfg, id, in, webhookURL, ooc, translation)
+		}))
+		if target := senderDiscordTarget(&cfg, sender); target != "" && target != webhookURL {
+			// The copy goes to the sender's channel alone: scene threads
+			// belong to the usual channel, and a failover may be public.
+			// It has no receipt of its own.
+			routeCfg := cfg
+			routeCfg.SceneThreads, routeCfg.DiscordFailover = false, nil
+			if a.logger != nil {
+				a.logger.Log("debug", traced(trace, "Sender has a Discord route, posting a copy"))
+			}
+			a.deliveries.add(sinkDiscord+" "+target, a.newDelivery("", sinkDiscord, trace, func() {
+				a.deliverToDiscord(ctx, &routeCfg, "", in, target, ooc, translation)
+			}))
+		}
+	}
 
-			if cfg.EnableLocalSave {
-				fullPath := generateLogFilename(cfg.Path, cfg.FileFormat)
-				if a.logger != nil {
-					a.logger.Log("debug", fmt.Sprintf("Writing to file: %s", fullPath))
-				}
-				err := logToFile(&cfg, sender, message)
-				if err != nil {
-					log.Printf("Failed to log message to file: %v", err)
-					if a.logger != nil {
-						a.logger.Log("error", fmt.Sprintf("File write failed: %v", err))
-						a.logger.LogFailure(sender, message, "file", err.Error())
-					}
-				} else if a.logger != nil {
-					a.logger.Log("debug", fmt.Sprintf("Wrote to %s successfully", fullPath))
-				}
+	if cfg.EnableLocalSave {
+		logCfg := sourceLogConfig(&cfg, source)
+		entry := newLogEntry(logCfg, sender, message)
+		entry.Source = source
+		entry.Scene = scene
+		if session, ok := a.CurrentSession(); ok {
+			entry.Session = session.Name
+		}
+		a.receipts.set(id, sinkFile, deliveryPending)
+		a.deliveries.add(sinkFile+" "+logCfg.Path, a.newDelivery(id, sinkFile, trace, func() {
+			a.deliverToFile(logCfg, id, in, entry, translation)
+		}))
+		if route, ok := senderRoute(&cfg, sender); ok && route.Path != "" && route.Path != logCfg.Path {
+			routeCfg := *logCfg
+			routeCfg.Path = route.Path
+			a.deliveries.add(sinkFile+" "+route.Path, a.newDelivery("", sinkFile, trace, func() {
+				a.deliverToFile(&routeCfg, "", in, entry, translation)
+			}))
+		}
+	}
+
+	// Never re-forward a message another instance already relayed to us.
+	if cfg.EnableForward && !in.Forwarded && !ignored {
+		a.receipts.set(id, sinkForward, deliveryPending)
+		a.deliveries.add(sinkForward+" "+cfg.ForwardURL, a.newDelivery(id, sinkForward, trace, func() {
+			a.deliverForward(ctx, &cfg, id, in)
+		}))
+	}
+	return id
+}
+
+// newDelivery returns the job delivering message id to sink. If the
+// delivery queue drops it, its receipt shows the sink as failed.
+func (a *App) newDelivery(id, sink, trace string, deliver func()) deliveryJob {
+	return deliveryJob{
+		deliver: deliver,
+		drop: func() {
+			a.receipts.set(id, sink, deliveryFailed)
+			slog.Debug("Delivery dropped, queue full", "sink", sink, "trace", trace)
+			if a.logger != nil && a.deliveries.shouldWarn(time.Now()) {
+				stats := a.deliveries.stats()
+				a.logger.Log("warning", traced(trace, fmt.Sprintf("Delivery queue full (%d waiting), dropping %s deliveries; %d dropped so far",
+					stats.Capacity, sink, stats.Dropped)))
 			}
-		} else if a.logger != nil {
-			a.logger.Log("debug", "No message content, skipping processing")
+		},
+	}
+}
+
+// deliverToDiscord posts a message to webhookURL, or to its scene's thread,
+// queueing it for retry when rate limited.
+func (a *App) deliverToDiscord(ctx context.Context, cfg *AppConfig, id string, in IncomingMessage, webhookURL string, ooc bool, translation func() string) {
+	trace, sender, message, scene, source := in.Trace, in.Sender, in.Message, in.Scene, in.Source
+	if cfg.SceneThreads && scene != "" && !(ooc && cfg.OOCDiscordPolicy == oocSeparate) {
+		threadURL, err := a.sceneWebhookURL(ctx, webhookURL, sceneThreadKey(cfg, source, scene), scene)
+		if err != nil {
+			// Fall back to the main channel rather than dropping the message.
+			if a.logger != nil {
+				a.logger.Log("error", traced(trace, fmt.Sprintf("Discord thread for scene %q unavailable: %v", scene, err)))
+			}
+		} else {
+			webhookURL = threadURL
+		}
+	}
+	if a.logger != nil {
+		// Redact webhook URL for security, show only host
+		a.logger.Log("debug", traced(trace, "Sending to Discord webhook"))
+	}
+	author := discordAuthorFor(cfg, sender)
+	sender = displayName(cfg, sender)
+	alerts := mentionAlerts(cfg, message)
+	if len(alerts) > 0 && a.logger != nil {
+		a.logger.Log("debug", traced(trace, fmt.Sprintf("Alert keyword matched, mentioning %s", strings.Join(alerts, " "))))
+	}
+	text := message
+	if in.Rich != "" {
+		text = in.Rich
+	}
+	content := discordContent(cfg, text)
+	if cfg.TranslateDiscord {
+		content = withTranslation(cfg, content, translation())
+	}
+	content, mentions := withAlerts(content, discordMentions(cfg), alerts)
+	// Buffered while offline, with the time it was received.
+	buffered := QueuedMessage{ID: id, Trace: trace, WebhookURL: webhookURL, Author: author, Template: cfg.DiscordTemplate, AttachAfter: cfg.DiscordAttachAfter, Mentions: mentions, Sender: sender, Message: content, Source: source, Time: time.Now()}
+	if until, quiet := quietUntil(cfg, buffered.Time); quiet && cfg.QuietHoursPolicy == quietQueue {
+		buffered.RetryAt = until
+		a.discordQueue.Add(buffered)
+		if a.logger != nil {
+			a.logger.Log("info", traced(trace, fmt.Sprintf("Quiet hours, message queued for Discord until %s", until.Format("15:04"))))
+		}
+		return
+	}
+	if !a.discordQueue.PausedSince().IsZero() {
+		a.discordQueue.Add(buffered)
+		if a.logger != nil {
+			a.logger.Log("debug", traced(trace, "Discord paused, message held in the queue"))
 		}
+		return
+	}
+	if a.bufferOffline(ctx, cfg, buffered, nil) {
+		return
+	}
+	var posted []DiscordMessageRef
+	var retryAfter time.Duration
+	webhookURL, rateLimited, err := a.sendWithFailover(cfg, trace, webhookURL, func(url string) (bool, error) {
+		var limited bool
+		var err error
+		posted, limited, retryAfter, err = sendToDiscord(ctx, url, author, cfg.DiscordTemplate, cfg.DiscordAttachAfter, mentions, sender, content)
+		return limited, err
+	})
+	if err != nil {
+		if rateLimited {
+			// Queue for retry, on the webhook that limited it
+			retry := buffered
+			retry.WebhookURL = webhookURL
+			retry.RetryAt = time.Now().Add(retryAfter)
+			retry.Attempts = 1
+			a.discordQueue.Add(retry)
+			if a.logger != nil {
+				a.logger.Log("info", traced(trace, fmt.Sprintf("Discord rate limited, message queued for retry in %v", retryAfter)))
+			}
+		} else if !a.bufferOffline(ctx, cfg, buffered, err) {
+			slog.Error("Failed to send message to Discord", "err", err)
+			a.receipts.set(id, sinkDiscord, deliveryFailed)
+			if a.logger != nil {
+				a.logger.Log("error", traced(trace, fmt.Sprintf("Discord send failed: %v", err)))
+				a.logger.LogRetryableFailure(sender, message, "discord", err.Error(), trace, &FailureRetry{
+					Delivery: &QueuedMessage{ID: id, Trace: trace, WebhookURL: webhookURL, Author: author, Template: cfg.DiscordTemplate, AttachAfter: cfg.DiscordAttachAfter, Mentions: mentions, Sender: sender, Message: content, Source: source},
+				})
+			}
+		}
+		return
+	}
+	a.discordQueue.markSent()
+	a.receipts.set(id, sinkDiscord, deliverySent)
+	a.receipts.addDiscordMessages(id, posted)
+	if a.logger != nil {
+		a.logger.Log("debug", traced(trace, "Discord webhook returned success"))
+	}
+}
 
-		// Always responds 200 OK to prevent the game from crashing, even if there are internal errors.
-		response := map[string]string{"status": "ok"}
+// deliverToFile appends a message's log entry to its log file, and to the
+// translated log when there is one.
+func (a *App) deliverToFile(logCfg *AppConfig, id string, in IncomingMessage, entry LogEntry, translation func() string) {
+	trace := in.Trace
+	fullPath := logFilePath(logCfg, logCfg.Path, time.Now(), entry)
+	if a.logger != nil {
+		a.logger.Log("debug", traced(trace, fmt.Sprintf("Writing to file: %s", fullPath)))
+	}
+	a.archiveMu.RLock()
+	err := logToFile(logCfg, entry)
+	a.archiveMu.RUnlock()
+	if err != nil {
+		slog.Error("Failed to log message to file", "err", err)
+		a.receipts.set(id, sinkFile, deliveryFailed)
+		if a.logger != nil {
+			a.logger.Log("error", traced(trace, fmt.Sprintf("File write failed: %v", err)))
+			a.logger.LogRetryableFailure(in.Sender, in.Message, "file", err.Error(), trace, &FailureRetry{LogConfig: logCfg, Entry: &entry, MessageID: id})
+		}
+		return
+	}
+	a.receipts.set(id, sinkFile, deliverySent)
+	a.receipts.setEntry(id, entry)
+	if a.logger != nil {
+		a.logger.Log("debug", traced(trace, fmt.Sprintf("Wrote to %s successfully", fullPath)))
+	}
+	if logCfg.TranslateProvider != "" && logCfg.TranslateFile {
+		a.archiveMu.RLock()
+		err := logTranslation(logCfg, entry, translation())
+		a.archiveMu.RUnlock()
+		if err != nil {
+			if a.logger != nil {
+				a.logger.Log("error", traced(trace, err.Error()))
+			}
+		}
+	}
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Printf("Failed to encode response: %v", err)
+// deliverForward forwards a message to the configured instance, queueing
+// it for retry when that fails.
+func (a *App) deliverForward(ctx context.Context, cfg *AppConfig, id string, in IncomingMessage) {
+	trace := in.Trace
+	if a.logger != nil {
+		a.logger.Log("debug", traced(trace, "Forwarding message"))
+	}
+	if err := forwardMessage(ctx, cfg.ForwardURL, in.Sender, in.Message, in.Source); err != nil {
+		if a.forwardQueue != nil {
+			a.forwardQueue.Add(QueuedMessage{
+				ID:         id,
+				Trace:      trace,
+				WebhookURL: cfg.ForwardURL,
+				Sender:     in.Sender,
+				Message:    in.Message,
+				Source:     in.Source,
+				RetryAt:    time.Now().Add(forwardBackoff(1)),
+				Attempts:   1,
+			})
+		}
+		if a.logger != nil {
+			a.logger.Log("info", traced(trace, fmt.Sprintf("Forward failed, message queued for retry: %v", err)))
+		}
+		return
+	}
+	a.receipts.set(id, sinkForward, deliverySent)
+	if a.logger != nil {
+		a.logger.Log("debug", traced(trace, "Forward target returned success"))
+	}
+}
+
+// sceneThreadKey returns the SceneThreadIDs key for a scene. Scenes from a
+// source with its own webhook live in that source's channel, so they are
+// keyed separately from same-named scenes in the main channel.
+func sceneThreadKey(config *AppConfig, source, scene string) string {
+	if sourceWebhookURL(config, source) != discordTarget(config) {
+		return source + "/" + scene
+	}
+	return scene
+}
+
+// sceneWebhookURL returns the webhook URL that posts into the scene's thread,
+// creating the thread on first use and persisting its ID in the config under
+// key.
+func (a *App) sceneWebhookURL(ctx context.Context, webhookURL, key, scene string) (string, error) {
+	// Serialize thread creation so concurrent messages for a new scene
+	// don't each create their own thread.
+	a.sceneMu.Lock()
+	defer a.sceneMu.Unlock()
+
+	a.configMu.RLock()
+	threadID := a.config.SceneThreadIDs[key]
+	a.configMu.RUnlock()
+
+	if threadID == "" {
+		id, err := createDiscordThread(ctx, webhookURL, scene)
+		if err != nil {
+			return "", err
+		}
+		threadID = id
+
+		// Copy-on-write so config snapshots taken by other handlers never
+		// observe a map that is being mutated.
+		a.configMu.Lock()
+		threads := make(map[string]string, len(a.config.SceneThreadIDs)+1)
+		for k, v := range a.config.SceneThreadIDs {
+			threads[k] = v
+		}
+		threads[key] = threadID
+		a.config.SceneThreadIDs = threads
+		cfg := *a.config
+		a.configMu.Unlock()
+
+		if err := saveConfiguration(&cfg); err != nil && a.logger != nil {
+			a.logger.Log("error", fmt.Sprintf("Failed to save scene thread mapping: %v", err))
+		}
+		if a.logger != nil {
+			a.logger.Log("info", fmt.Sprintf("Created Discord thread for scene %q", scene))
 		}
 	}
+
+	return webhookThreadURL(webhookURL, threadID)
 }
@@ -1,14 +1,28 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
+// waitForDeliveries waits until the messages handled so far have reached
+// their sinks.
+func waitForDeliveries(t *testing.T, a *App) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.deliveries.wait(ctx); err != nil {
+		t.Fatalf("waiting for deliveries: %v", err)
+	}
+}
+
 func setupTestApp() *App {
 	config := &AppConfig{
 		ListenAddr:      "127.0.0.1:3000",
@@ -41,6 +55,7 @@ func TestCreateHandler_NoMessage(t *testing.T) {
 	handlerFunc := createHandler(a)
 
 	handlerFunc.ServeHTTP(recorder, req)
+	waitForDeliveries(t, a)
 
 	if status := recorder.Code; status != http.StatusOK {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
@@ -97,6 +112,7 @@ func TestCreateHandler_MessageParams(t *testing.T) {
 			recorder := httptest.NewRecorder()
 			handlerFunc := createHandler(a)
 			handlerFunc.ServeHTTP(recorder, req)
+			waitForDeliveries(t, a)
 
 			if status := recorder.Code; status != tt.expectedStatus {
 				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
@@ -133,6 +149,7 @@ func TestCreateHandler_WithLocalSave(t *testing.T) {
 	handlerFunc := createHandler(a)
 
 	handlerFunc.ServeHTTP(recorder, req)
+	waitForDeliveries(t, a)
 
 	if status := recorder.Code; status != http.StatusOK {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
@@ -165,6 +182,7 @@ func TestCreateHandler_LocalSaveInvalidPath(t *testing.T) {
 	handlerFunc := createHandler(a)
 
 	handlerFunc.ServeHTTP(recorder, req)
+	waitForDeliveries(t, a)
 
 	// File logging failure doesn't cause HTTP error
 	if status := recorder.Code; status != http.StatusOK {
@@ -185,6 +203,7 @@ func TestCreateHandler_ResponseFields(t *testing.T) {
 	handlerFunc := createHandler(a)
 
 	handlerFunc.ServeHTTP(recorder, req)
+	waitForDeliveries(t, a)
 
 	var response map[string]interface{}
 	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
@@ -209,6 +228,7 @@ func TestCreateHandler_ContentType(t *testing.T) {
 	handlerFunc := createHandler(a)
 
 	handlerFunc.ServeHTTP(recorder, req)
+	waitForDeliveries(t, a)
 
 	contentType := recorder.Header().Get("Content-Type")
 	if contentType != "application/json" {
@@ -239,6 +259,7 @@ func TestCreateHandler_DocxFormat(t *testing.T) {
 	handlerFunc := createHandler(a)
 
 	handlerFunc.ServeHTTP(recorder, req)
+	waitForDeliveries(t, a)
 
 	if status := recorder.Code; status != http.StatusOK {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
@@ -261,3 +282,140 @@ func TestCreateHandler_DocxFormat(t *testing.T) {
 		t.Error("Expected .docx file to be created")
 	}
 }
+
+func TestCreateHandler_EmoteFormatting(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	tmpDir := t.TempDir()
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+	a.config.FileFormat = "txt"
+	a.config.EmoteDetection = true
+
+	req, err := http.NewRequest("GET", "/message?sender=Conan&message=%2Adraws+his+sword%2A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createHandler(a).ServeHTTP(httptest.NewRecorder(), req)
+	waitForDeliveries(t, a)
+
+	data, err := os.ReadFile(generateLogFilename(tmpDir, "txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "] * Conan draws his sword\n") {
+		t.Errorf("expected emote line, got %q", string(data))
+	}
+}
+
+func TestCreateHandler_OOCFilePolicy(t *testing.T) {
+	tests := []struct {
+		policy      string
+		mainWritten bool
+		oocWritten  bool
+	}{
+		{policy: "include", mainWritten: true},
+		{policy: "exclude"},
+		{policy: "separate", oocWritten: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			a := setupTestApp()
+			defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+			tmpDir := t.TempDir()
+			a.config.EnableLocalSave = true
+			a.config.Path = tmpDir
+			a.config.OOCFilePolicy = tt.policy
+
+			req, err := http.NewRequest("GET", "/message?sender=A&message=%28%28brb%29%29", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			createHandler(a).ServeHTTP(httptest.NewRecorder(), req)
+			waitForDeliveries(t, a)
+
+			_, err = os.Stat(generateLogFilename(tmpDir, "txt"))
+			if mainWritten := err == nil; mainWritten != tt.mainWritten {
+				t.Errorf("main log written: expected %v, got %v", tt.mainWritten, mainWritten)
+			}
+			_, err = os.Stat(generateLogFilename(filepath.Join(tmpDir, "ooc"), "txt"))
+			if oocWritten := err == nil; oocWritten != tt.oocWritten {
+				t.Errorf("ooc log written: expected %v, got %v", tt.oocWritten, oocWritten)
+			}
+		})
+	}
+}
+
+func TestCreateHandler_SessionTranscript(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	tmpDir := t.TempDir()
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+	a.config.FileFormat = "json"
+
+	if _, err := a.StartSession("Chapter 3: The Tower"); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "/message?sender=A&message=Hello", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createHandler(a).ServeHTTP(httptest.NewRecorder(), req)
+	waitForDeliveries(t, a)
+
+	data, err := os.ReadFile(sessionLogFilename(tmpDir, "Chapter 3: The Tower", "json"))
+	if err != nil {
+		t.Fatalf("expected session transcript: %v", err)
+	}
+	var entries []LogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Session != "Chapter 3: The Tower" {
+		t.Errorf("unexpected session entries: %+v", entries)
+	}
+
+	if _, err := a.StopSession(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.StopSession(); err == nil {
+		t.Error("expected error stopping with no session in progress")
+	}
+}
+
+func TestDeliverToDiscord_RateLimitedKeepsMessageDetails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	a := setupTestApp()
+	a.discordQueue = NewDiscordQueue(a.logger, nil)
+	a.discordQueue.Stop()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	before := time.Now()
+	in := IncomingMessage{Trace: "t-1", Sender: "Conan", Message: "By Crom", Source: "tavern"}
+	a.deliverToDiscord(context.Background(), a.config, "m-1", in, srv.URL, false, func() string { return "" })
+
+	a.discordQueue.mu.Lock()
+	queued := append([]QueuedMessage(nil), a.discordQueue.messages...)
+	a.discordQueue.mu.Unlock()
+	if len(queued) != 1 {
+		t.Fatalf("queued %d message(s), want 1", len(queued))
+	}
+	got := queued[0]
+	if got.ID != "m-1" || got.Trace != "t-1" || got.Source != "tavern" || got.Time.Before(before) || got.WebhookURL != srv.URL {
+		t.Errorf("queued message lost its details: %+v", got)
+	}
+	if got.Attempts != 1 || time.Until(got.RetryAt) < 30*time.Second {
+		t.Errorf("queued with attempts %d, retry at %v", got.Attempts, got.RetryAt)
+	}
+}
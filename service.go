@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// serviceName is the name the app is registered under with the service
+// manager (Windows SCM or systemd).
+const serviceName = "rp-chat-logger"
+
+// serviceArgs returns the command-line arguments the service manager uses
+// to start the app: headless, with an absolute config path.
+func serviceArgs(configPath string) []string {
+	return []string{"--service", "--headless", "--config", configPath}
+}
+
+// serviceConfigPath returns the absolute config path a service should use.
+// When installing through sudo, the invoking user's config is used rather
+// than root's, since the service runs as that user.
+func serviceConfigPath() (string, error) {
+	if configPathOverride == "" {
+		if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+			if u, err := user.Lookup(sudoUser); err == nil {
+				return filepath.Join(u.HomeDir, ".config", "rp-chat-logger", "config.json"), nil
+			}
+		}
+	}
+	path, err := filepath.Abs(getConfigPath())
+	if err != nil {
+		return "", fmt.Errorf("resolving config path: %w", err)
+	}
+	return path, nil
+}
+
+// systemdUnit renders a systemd unit that runs the app headless. runAs is
+// the user the service runs as; empty means root.
+func systemdUnit(execPath, configPath, runAs string) string {
+	args := append([]string{execPath}, serviceArgs(configPath)...)
+	for i, arg := range args {
+		args[i] = systemdQuote(arg)
+	}
+
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=RP Chat Logger\n")
+	b.WriteString("After=network-online.target\n")
+	b.WriteString("Wants=network-online.target\n")
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(args, " "))
+	if runAs != "" {
+		fmt.Fprintf(&b, "User=%s\n", runAs)
+	}
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=5\n")
+	// Leave time for queued messages to drain on stop.
+	b.WriteString("TimeoutStopSec=30\n")
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// systemdQuote quotes an ExecStart argument when it contains spaces or
+// characters systemd would otherwise interpret.
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$%") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$", "%", "%%")
+	return `"` + r.Replace(arg) + `"`
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+const systemdUnitPath = "/etc/systemd/system/" + serviceName + ".service"
+
+// installService writes a systemd unit for the app and enables it.
+func installService() error {
+	execPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("getting executable path: %w", err)
+	}
+	execPath, err = filepath.EvalSymlinks(execPath)
+	if err != nil {
+		return fmt.Errorf("resolving executable path: %w", err)
+	}
+	configPath, err := serviceConfigPath()
+	if err != nil {
+		return err
+	}
+
+	unit := systemdUnit(execPath, configPath, os.Getenv("SUDO_USER"))
+	if err := os.WriteFile(systemdUnitPath, []byte(unit), 0644); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("writing %s: permission denied (run with sudo)", systemdUnitPath)
+		}
+		return fmt.Errorf("writing systemd unit: %w", err)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if err := systemctl("enable", "--now", serviceName); err != nil {
+		return err
+	}
+	fmt.Printf("Installed %s (config: %s)\n", systemdUnitPath, configPath)
+	fmt.Printf("Follow logs with: journalctl -u %s -f\n", serviceName)
+	return nil
+}
+
+// uninstallService stops and disables the systemd unit and removes it.
+func uninstallService() error {
+	if _, err := os.Stat(systemdUnitPath); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("service is not installed")
+	}
+	if err := systemctl("disable", "--now", serviceName); err != nil {
+		return err
+	}
+	if err := os.Remove(systemdUnitPath); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("removing %s: permission denied (run with sudo)", systemdUnitPath)
+		}
+		return fmt.Errorf("removing systemd unit: %w", err)
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s\n", systemdUnitPath)
+	return nil
+}
+
+// systemctl runs a systemctl command, passing its output through.
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("systemctl %v: %w", args, err)
+	}
+	return nil
+}
+
+// runAsService runs the app until systemd stops it. systemd stops services
+// with SIGTERM, so this is the same as a normal run.
+func runAsService(run func(ctx context.Context)) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	run(ctx)
+}
@@ -0,0 +1,29 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+)
+
+// installService is not supported on this platform.
+func installService() error {
+	return fmt.Errorf("installing as a service is not supported on %s", runtime.GOOS)
+}
+
+// uninstallService is not supported on this platform.
+func uninstallService() error {
+	return fmt.Errorf("installing as a service is not supported on %s", runtime.GOOS)
+}
+
+// runAsService runs the app until it receives SIGINT or SIGTERM.
+func runAsService(run func(ctx context.Context)) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	run(ctx)
+}
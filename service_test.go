@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	unit := systemdUnit("/opt/rp chat/lgr", "/home/ann/.config/rp-chat-logger/config.json", "ann")
+
+	for _, want := range []string{
+		`ExecStart="/opt/rp chat/lgr" --service --headless --config /home/ann/.config/rp-chat-logger/config.json`,
+		"User=ann\n",
+		"Restart=on-failure\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+
+	if strings.Contains(systemdUnit("/usr/bin/lgr", "/etc/lgr.json", ""), "User=") {
+		t.Error("unit without user should not set User=")
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/usr/bin/lgr", "/usr/bin/lgr"},
+		{"/opt/my app/lgr", `"/opt/my app/lgr"`},
+		{`/tmp/a"b`, `"/tmp/a\"b"`},
+		{"/tmp/100%", `"/tmp/100%%"`},
+		{"", `""`},
+	}
+	for _, tt := range tests {
+		if got := systemdQuote(tt.in); got != tt.want {
+			t.Errorf("systemdQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Service Control Manager constants (winsvc.h).
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStopped      = 1
+	serviceStartPending = 2
+	serviceStopPending  = 3
+	serviceRunning      = 4
+
+	serviceAcceptStop     = 0x1
+	serviceAcceptShutdown = 0x4
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+
+	errorCallNotImplemented = 120
+)
+
+var (
+	advapi32                         = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW  = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerEx = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus             = advapi32.NewProc("SetServiceStatus")
+)
+
+// serviceStatus mirrors the Win32 SERVICE_STATUS structure.
+type serviceStatus struct {
+	ServiceType             uint32
+	CurrentState            uint32
+	ControlsAccepted        uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+	CheckPoint              uint32
+	WaitHint                uint32
+}
+
+// serviceTableEntry mirrors the Win32 SERVICE_TABLE_ENTRYW structure.
+type serviceTableEntry struct {
+	ServiceName *uint16
+	ServiceProc uintptr
+}
+
+// installService registers the app with the Service Control Manager as an
+// automatically started service. Requires an elevated prompt.
+func installService() error {
+	execPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("getting executable path: %w", err)
+	}
+	configPath, err := serviceConfigPath()
+	if err != nil {
+		return err
+	}
+
+	args := append([]string{execPath}, serviceArgs(configPath)...)
+	for i, arg := range args {
+		args[i] = syscall.EscapeArg(arg)
+	}
+	binPath := strings.Join(args, " ")
+
+	if err := sc("create", serviceName, "binPath=", binPath, "start=", "auto", "DisplayName=", "RP Chat Logger"); err != nil {
+		return err
+	}
+	if err := sc("description", serviceName, "Logs roleplay chat and relays it to Discord."); err != nil {
+		return err
+	}
+	// Restart after a crash, like Restart=on-failure under systemd.
+	if err := sc("failure", serviceName, "reset=", "86400", "actions=", "restart/5000"); err != nil {
+		return err
+	}
+	if err := sc("start", serviceName); err != nil {
+		return err
+	}
+	fmt.Printf("Installed service %s (config: %s)\n", serviceName, configPath)
+	fmt.Printf("Logs are written to %s\n", serviceLogPath())
+	return nil
+}
+
+// uninstallService stops the service and removes it from the Service
+// Control Manager.
+func uninstallService() error {
+	// Stopping fails if the service is not running; deleting still works.
+	_ = sc("stop", serviceName)
+	if err := sc("delete", serviceName); err != nil {
+		return err
+	}
+	fmt.Printf("Removed service %s\n", serviceName)
+	return nil
+}
+
+// sc runs an sc.exe command, passing its output through.
+func sc(args ...string) error {
+	cmd := exec.Command("sc.exe", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sc %s: %w (run from an administrator prompt)", args[0], err)
+	}
+	return nil
+}
+
+// serviceLogPath is where the service writes its log, since a service has
+// no console.
+func serviceLogPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), "service.log")
+}
+
+// windowsService holds the state shared with the SCM callbacks, which
+// cannot capture Go closures.
+var windowsService struct {
+	run          func(ctx context.Context)
+	statusHandle uintptr
+	stop         context.CancelFunc
+}
+
+// runAsService connects to the Service Control Manager and runs the app
+// until the service is stopped. When the process was started from a
+// console instead, it runs until Ctrl-C.
+func runAsService(run func(ctx context.Context)) {
+	if f, err := os.OpenFile(serviceLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+		consoleOutput.SetOutput(f)
+		defer f.Close()
+	}
+
+	windowsService.run = run
+	name, _ := syscall.UTF16PtrFromString(serviceName)
+	table := []serviceTableEntry{
+		{ServiceName: name, ServiceProc: syscall.NewCallback(serviceMain)},
+		{},
+	}
+
+	// Blocks until the service has stopped.
+	r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0])))
+	if r != 0 {
+		return
+	}
+	slog.Info("Not started by the service manager, running in the foreground", "err", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	run(ctx)
+}
+
+// serviceMain is the ServiceMain entry point called by the dispatcher.
+func serviceMain(argc uint32, argv **uint16) uintptr {
+	name, _ := syscall.UTF16PtrFromString(serviceName)
+	handle, _, err := procRegisterServiceCtrlHandlerEx.Call(
+		uintptr(unsafe.Pointer(name)), syscall.NewCallback(serviceHandler), 0)
+	if handle == 0 {
+		slog.Error("Failed to register service control handler", "err", err)
+		return 0
+	}
+	windowsService.statusHandle = handle
+
+	ctx, cancel := context.WithCancel(context.Background())
+	windowsService.stop = cancel
+
+	setServiceState(serviceStartPending, 0)
+	setServiceState(serviceRunning, serviceAcceptStop|serviceAcceptShutdown)
+	windowsService.run(ctx)
+	setServiceState(serviceStopped, 0)
+	return 0
+}
+
+// serviceHandler is the HandlerEx callback that receives control requests.
+func serviceHandler(control, eventType uint32, eventData, handlerContext uintptr) uintptr {
+	switch control {
+	case serviceControlStop, serviceControlShutdown:
+		// Queued messages are drained on shutdown; ask the SCM to wait.
+		setServiceState(serviceStopPending, 0)
+		windowsService.stop()
+		return 0
+	case serviceControlInterrogate:
+		return 0
+	}
+	return errorCallNotImplemented
+}
+
+// setServiceState reports the service state to the SCM.
+func setServiceState(state, accepts uint32) {
+	status := serviceStatus{
+		ServiceType:      serviceWin32OwnProcess,
+		CurrentState:     state,
+		ControlsAccepted: accepts,
+	}
+	if state == serviceStartPending || state == serviceStopPending {
+		status.WaitHint = uint32((shutdownDrainTimeout + 5*time.Second).Milliseconds())
+	}
+	procSetServiceStatus.Call(windowsService.statusHandle, uintptr(unsafe.Pointer(&status)))
+}
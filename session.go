@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Session is a named RP session. While a session is active every log
+// entry is tagged with its name and copied to a per-session transcript.
+type Session struct {
+	Name      string
+	StartedAt time.Time
+
+	// divider is the "session started" post, when the Discord bot made it.
+	divider DiscordMessageRef
+}
+
+// CurrentSession returns the active session, if any.
+func (a *App) CurrentSession() (Session, bool) {
+	a.sessionMu.RLock()
+	defer a.sessionMu.RUnlock()
+	if a.session == nil {
+		return Session{}, false
+	}
+	return *a.session, true
+}
+
+// StartSession begins a new named session, ending any session already in
+// progress, and posts a divider to Discord when enabled.
+func (a *App) StartSession(name string) (Session, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Session{}, fmt.Errorf("session name required")
+	}
+
+	if _, ok := a.CurrentSession(); ok {
+		if _, err := a.StopSession(); err != nil {
+			return Session{}, err
+		}
+	}
+
+	session := Session{Name: name, StartedAt: time.Now()}
+	a.sessionMu.Lock()
+	a.session = &session
+	a.sessionMu.Unlock()
+
+	a.logger.Log("info", fmt.Sprintf("Session started: %s", name))
+	divider := a.postSessionDivider(fmt.Sprintf("--- Session started: %s ---", name))
+	a.sessionMu.Lock()
+	if a.session == &session {
+		session.divider = divider
+	}
+	a.sessionMu.Unlock()
+	return session, nil
+}
+
+// StopSession ends the active session and returns it.
+func (a *App) StopSession() (Session, error) {
+	a.sessionMu.Lock()
+	if a.session == nil {
+		a.sessionMu.Unlock()
+		return Session{}, fmt.Errorf("no session in progress")
+	}
+	session := *a.session
+	a.session = nil
+	a.sessionMu.Unlock()
+
+	duration := time.Since(session.StartedAt).Round(time.Minute)
+	a.logger.Log("info", fmt.Sprintf("Session ended: %s (%v)", session.Name, duration))
+	a.postSessionDivider(fmt.Sprintf("--- Session ended: %s ---", session.Name))
+	if session.divider.MessageID != "" {
+		a.editSessionDivider(session.divider, fmt.Sprintf("--- Session: %s (%s to %s) ---",
+			session.Name, session.StartedAt.Format("15:04"), time.Now().Format("15:04")))
+	}
+	// The transcript is read to be uploaded and posted.
+	flushLogs()
+	go a.uploadSessionTranscript(context.Background(), session)
+	a.postSessionTranscript(session)
+	a.summarizeEndedSession(session)
+	return session, nil
+}
+
+// postSessionTranscript attaches a finished session's transcript to a
+// Discord post when DiscordSessionTranscript is set, or says it is too
+// large to attach. The post is made in the background.
+func (a *App) postSessionTranscript(session Session) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	webhookURL := discordTarget(&cfg)
+	if !cfg.DiscordSessionTranscript || !cfg.EnableDiscord || webhookURL == "" || !cfg.EnableLocalSave || cfg.Path == "" {
+		return
+	}
+	path := sessionLogFilename(cfg.Path, session.Name, logFormat(&cfg))
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		content := fmt.Sprintf("Transcript of session **%s**", session.Name)
+		var err error
+		if attachmentsSize([]string{path}) <= discordAttachmentLimit {
+			err = sendDiscordFiles(ctx, webhookURL, content, []string{path})
+		} else {
+			err = sendDiscordNotice(ctx, webhookURL, content+": _too large to attach._")
+		}
+		if err != nil {
+			a.logger.Log("error", fmt.Sprintf("Posting the session transcript to Discord failed: %v", err))
+			return
+		}
+		a.logger.Log("info", fmt.Sprintf("Posted the transcript of session %s to Discord", session.Name))
+	}()
+}
+
+// postSessionDivider posts a divider line to the main Discord channel so
+// sessions are easy to tell apart when scrolling back. It returns the post
+// when the bot made it.
+func (a *App) postSessionDivider(content string) DiscordMessageRef {
+	a.configMu.RLock()
+	enabled := a.config.EnableDiscord
+	webhookURL := discordTarget(a.config)
+	a.configMu.RUnlock()
+
+	if !enabled || webhookURL == "" {
+		return DiscordMessageRef{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	ref, err := postDiscordNotice(ctx, webhookURL, content)
+	if err != nil {
+		a.logger.Log("error", fmt.Sprintf("Failed to post session divider to Discord: %v", err))
+	}
+	return ref
+}
+
+// editSessionDivider rewrites the bot's "session started" divider once the
+// session is over, so it shows the session's full time span.
+func (a *App) editSessionDivider(ref DiscordMessageRef, content string) {
+	a.configMu.RLock()
+	token := a.config.BotToken
+	a.configMu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := editDiscordMessage(ctx, token, ref, content); err != nil {
+		a.logger.Log("error", fmt.Sprintf("Failed to update session divider on Discord: %v", err))
+	}
+}
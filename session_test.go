@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostSessionTranscript_ResolvedTarget(t *testing.T) {
+	var mu sync.Mutex
+	uploads := make(map[string]string) // webhook -> attached file name
+	posted := make(chan struct{}, 2)
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("parsing upload: %v", err)
+			}
+			_, hdr, err := r.FormFile("files[0]")
+			mu.Lock()
+			if err == nil {
+				uploads[name] = hdr.Filename
+			}
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			posted <- struct{}{}
+		}
+	}
+	before := httptest.NewServer(handler("before"))
+	defer before.Close()
+	after := httptest.NewServer(handler("after"))
+	defer after.Close()
+
+	dir := t.TempDir()
+	path := sessionLogFilename(dir, "Chapter 3", "txt")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte("[2026-10-17 21:04:05] Conan: By Crom!\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.config.EnableDiscord = true
+	a.config.WebhookURL = before.URL
+	a.config.DiscordSessionTranscript = true
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+
+	a.postSessionTranscript(Session{Name: "Chapter 3"})
+	// Settings changed right after the session ends don't redirect the
+	// transcript.
+	a.configMu.Lock()
+	a.config.WebhookURL = after.URL
+	a.configMu.Unlock()
+
+	select {
+	case <-posted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transcript not posted")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if uploads["before"] != filepath.Base(path) || len(uploads) != 1 {
+		t.Errorf("transcript uploads %v, want %s to the webhook set when the session ended", uploads, filepath.Base(path))
+	}
+}
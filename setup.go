@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// First-run setup wizard steps, in order. The format step is skipped when
+// no log folder was given.
+const (
+	setupStepDiscord = "discord"
+	setupStepFolder  = "folder"
+	setupStepFormat  = "format"
+	setupStepFinish  = "finish"
+)
+
+var setupSteps = []string{setupStepDiscord, setupStepFolder, setupStepFormat, setupStepFinish}
+
+// needsSetup reports whether the first-run wizard should be shown instead
+// of the settings form: no config file has been written yet and the
+// settings from environment variables and flags can't run on their own.
+func (a *App) needsSetup() bool {
+	if _, err := os.Stat(getConfigPath()); !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	a.configMu.RLock()
+	defer a.configMu.RUnlock()
+	return a.config.validate() != nil
+}
+
+// setupForm holds the wizard answers so far. Answers from earlier steps
+// travel along in hidden fields, so nothing is stored until the end.
+type setupForm struct {
+	WebhookURL string
+	Path       string
+	FileFormat string
+}
+
+func parseSetupForm(r *http.Request) setupForm {
+	return setupForm{
+		WebhookURL: strings.TrimSpace(r.FormValue("webhookURL")),
+		Path:       strings.TrimSpace(r.FormValue("path")),
+		FileFormat: r.FormValue("fileFormat"),
+	}
+}
+
+// apply returns cfg with the wizard answers: Discord and file logging are
+// enabled for whichever was given.
+func (f setupForm) apply(cfg AppConfig) AppConfig {
+	cfg.EnableDiscord = f.WebhookURL != ""
+	cfg.DiscordMode = discordModeWebhook
+	cfg.WebhookURL = f.WebhookURL
+	cfg.EnableLocalSave = f.Path != ""
+	cfg.Path = f.Path
+	if f.FileFormat != "" {
+		cfg.FileFormat = f.FileFormat
+	}
+	return cfg
+}
+
+// checkSetupStep validates the answer given on step before moving on.
+func checkSetupStep(step string, f setupForm) error {
+	switch step {
+	case setupStepDiscord:
+		if f.WebhookURL != "" {
+			return checkWebhookURL("Discord webhook URL", f.WebhookURL)
+		}
+	case setupStepFolder:
+		if f.WebhookURL == "" && f.Path == "" {
+			return errors.New("Choose a log folder or go back and enter a Discord webhook URL; otherwise messages go nowhere")
+		}
+	case setupStepFormat:
+		switch f.FileFormat {
+		case "txt", "csv", "json", "docx":
+		default:
+			return fmt.Errorf("Unknown file format %q", f.FileFormat)
+		}
+	}
+	return nil
+}
+
+// nextSetupStep returns the step after (or, going back, before) step.
+func nextSetupStep(step string, back bool, f setupForm) string {
+	i := 0
+	for j, s := range setupSteps {
+		if s == step {
+			i = j
+		}
+	}
+	for {
+		if back {
+			i--
+		} else {
+			i++
+		}
+		if i < 0 || i >= len(setupSteps) {
+			return step
+		}
+		if setupSteps[i] != setupStepFormat || f.Path != "" {
+			return setupSteps[i]
+		}
+	}
+}
+
+// handleSetupPage shows the first-run wizard. Once a config file exists
+// it sends the browser to the settings page instead.
+func (a *App) handleSetupPage(w http.ResponseWriter, r *http.Request) {
+	if !a.needsSetup() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	tmpl, err := a.parseTemplates(
+		"templates/layout.html",
+		"templates/setup.html",
+		"templates/partials/setup_step.html",
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := setupStepData(setupStepDiscord, setupForm{WebhookURL: cfg.WebhookURL, Path: cfg.Path, FileFormat: cfg.FileFormat})
+	data["Version"] = Version
+	data["Prefs"] = a.uiPreferences(w, r)
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// handleSetupStep checks the current step's answer and returns the next
+// (or previous) step as an HTML partial.
+func (a *App) handleSetupStep(w http.ResponseWriter, r *http.Request) {
+	step := r.FormValue("step")
+	form := parseSetupForm(r)
+	back := r.FormValue("go") == "back"
+	if !back {
+		if err := checkSetupStep(step, form); err != nil {
+			a.renderSetupStep(w, step, form, err.Error())
+			return
+		}
+	}
+	a.renderSetupStep(w, nextSetupStep(step, back, form), form, "")
+}
+
+// handleSetupTest posts a test message to the webhook and writes a test
+// line to the folder given in the wizard, before anything is saved.
+func (a *App) handleSetupTest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	form := parseSetupForm(r)
+	if form.WebhookURL != "" {
+		ctx, cancel := context.WithTimeout(r.Context(), diagnosticTimeout)
+		defer cancel()
+		err := sendDiscordNotice(ctx, form.WebhookURL, "--- RP Chat Logger test message: setup is working ---")
+		writeTestResult(w, err, "Test message posted to Discord.")
+	}
+	if form.Path != "" {
+		writeTestResult(w, checkWritable(form.Path), "Log folder "+form.Path+" is writable.")
+	}
+}
+
+// handleSetupFinish writes the config file from the wizard answers,
+// applies it and sends the browser to the settings page.
+func (a *App) handleSetupFinish(w http.ResponseWriter, r *http.Request) {
+	form := parseSetupForm(r)
+	if !a.needsSetup() {
+		a.renderSetupStep(w, setupStepFinish, form, "Setup has already been completed")
+		return
+	}
+	a.configMu.RLock()
+	cfg := form.apply(*a.config)
+	a.configMu.RUnlock()
+
+	if err := cfg.validate(); err != nil {
+		a.renderSetupStep(w, setupStepFinish, form, err.Error())
+		return
+	}
+	if err := saveConfiguration(&cfg); err != nil {
+		a.logger.Log("error", fmt.Sprintf("Failed to save config: %v", err))
+		a.renderSetupStep(w, setupStepFinish, form, "Failed to save configuration")
+		return
+	}
+	a.applyConfig(&cfg, "Setup finished")
+	a.logger.Log("info", fmt.Sprintf("Configuration saved to %s", getConfigPath()))
+	w.Header().Set("HX-Redirect", "/")
+}
+
+func setupStepData(step string, form setupForm) map[string]interface{} {
+	number := 1
+	for i, s := range setupSteps {
+		if s == step {
+			number = i + 1
+		}
+	}
+	return map[string]interface{}{
+		"Step":   step,
+		"Number": number,
+		"Total":  len(setupSteps),
+		"Form":   form,
+	}
+}
+
+func (a *App) renderSetupStep(w http.ResponseWriter, step string, form setupForm, errMsg string) {
+	tmpl, err := a.parseTemplates("templates/partials/setup_step.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := setupStepData(step, form)
+	if errMsg != "" {
+		data["Error"] = errMsg
+	}
+	if err := tmpl.ExecuteTemplate(w, "setup-step", data); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNextSetupStep(t *testing.T) {
+	withPath := setupForm{Path: "/logs"}
+	tests := []struct {
+		step string
+		back bool
+		form setupForm
+		want string
+	}{
+		{setupStepDiscord, false, withPath, setupStepFolder},
+		{setupStepFolder, false, withPath, setupStepFormat},
+		{setupStepFolder, false, setupForm{WebhookURL: "x"}, setupStepFinish},
+		{setupStepFinish, true, setupForm{WebhookURL: "x"}, setupStepFolder},
+		{setupStepFinish, true, withPath, setupStepFormat},
+		{setupStepDiscord, true, withPath, setupStepDiscord},
+		{setupStepFinish, false, withPath, setupStepFinish},
+	}
+	for _, tt := range tests {
+		if got := nextSetupStep(tt.step, tt.back, tt.form); got != tt.want {
+			t.Errorf("nextSetupStep(%q, %v, %+v) = %q, want %q", tt.step, tt.back, tt.form, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSetupStep(t *testing.T) {
+	tests := []struct {
+		step    string
+		form    setupForm
+		wantErr bool
+	}{
+		{setupStepDiscord, setupForm{}, false},
+		{setupStepDiscord, setupForm{WebhookURL: "https://discord.com/api/webhooks/1/abc"}, false},
+		{setupStepDiscord, setupForm{WebhookURL: "not a url"}, true},
+		{setupStepFolder, setupForm{}, true},
+		{setupStepFolder, setupForm{Path: "/logs"}, false},
+		{setupStepFormat, setupForm{Path: "/logs", FileFormat: "csv"}, false},
+		{setupStepFormat, setupForm{Path: "/logs", FileFormat: "pdf"}, true},
+	}
+	for _, tt := range tests {
+		if err := checkSetupStep(tt.step, tt.form); (err != nil) != tt.wantErr {
+			t.Errorf("checkSetupStep(%q, %+v) = %v, wantErr %v", tt.step, tt.form, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSetupWizard(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	setConfigPath(configPath)
+	defer setConfigPath("")
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	// Without a config file the main page leads to the wizard.
+	rec := httptest.NewRecorder()
+	a.handleIndex(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/setup" {
+		t.Fatalf("expected a redirect to the wizard, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	a.handleSetupPage(rec, httptest.NewRequest("GET", "/setup", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Step 1 of 4") {
+		t.Fatalf("expected the first wizard step, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	post := func(handler http.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec = post(a.handleSetupStep, url.Values{"step": {setupStepFolder}, "webhookURL": {""}, "path": {""}})
+	if !strings.Contains(rec.Body.String(), "alert error") || !strings.Contains(rec.Body.String(), `value="folder"`) {
+		t.Errorf("expected the folder step again with an error, got %s", rec.Body.String())
+	}
+
+	logs := filepath.Join(dir, "logs")
+	rec = post(a.handleSetupStep, url.Values{"step": {setupStepFolder}, "path": {logs}, "fileFormat": {"txt"}})
+	if !strings.Contains(rec.Body.String(), `value="format"`) {
+		t.Errorf("expected the format step, got %s", rec.Body.String())
+	}
+
+	rec = post(a.handleSetupFinish, url.Values{"path": {logs}, "fileFormat": {"json"}})
+	if rec.Header().Get("HX-Redirect") != "/" {
+		t.Fatalf("expected the wizard to finish, got %s", rec.Body.String())
+	}
+	var saved AppConfig
+	if err := readConfigFile(configPath, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if !saved.EnableLocalSave || saved.Path != logs || saved.FileFormat != "json" || saved.EnableDiscord {
+		t.Errorf("unexpected saved config: %+v", saved)
+	}
+	if a.config.Path != logs || a.needsSetup() {
+		t.Error("expected the new config to be applied")
+	}
+
+	rec = httptest.NewRecorder()
+	a.handleSetupPage(rec, httptest.NewRequest("GET", "/setup", nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("expected the wizard to be closed after setup, got %d", rec.Code)
+	}
+}
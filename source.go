@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// sourceHeader lets a client name its source when it cannot add a query
+// parameter.
+const sourceHeader = "X-RP-Source"
+
+// SourceProfile holds the settings for one named message source, such as a
+// game server. Empty fields fall back to the main settings.
+type SourceProfile struct {
+	// ListenAddr, when set, starts an extra ingestion listener whose
+	// messages are all tagged with this source.
+	ListenAddr string `json:"listenAddr,omitempty"`
+	// WebhookURL overrides the main Discord webhook for this source.
+	WebhookURL string `json:"webhookURL,omitempty"`
+	// Path and FileFormat override where and how this source is logged.
+	Path       string `json:"path,omitempty"`
+	FileFormat string `json:"fileFormat,omitempty"`
+}
+
+// sourceContextKey is the request context key holding the source name of
+// the listener a request arrived on.
+type sourceContextKey struct{}
+
+// withSource returns a context tagged with the given source name.
+func withSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceContextKey{}, source)
+}
+
+// requestSource returns the source a message request belongs to: the
+// source of the listener it arrived on, otherwise the "source" query
+// parameter or X-RP-Source header, otherwise "".
+func requestSource(r *http.Request) string {
+	if source, _ := r.Context().Value(sourceContextKey{}).(string); source != "" {
+		return source
+	}
+	if values, err := url.ParseQuery(r.URL.RawQuery); err == nil {
+		if source := strings.TrimSpace(values.Get("source")); source != "" {
+			return source
+		}
+	}
+	return strings.TrimSpace(r.Header.Get(sourceHeader))
+}
+
+// sourceWebhookURL returns the Discord webhook (or bot channel) for
+// messages from source.
+func sourceWebhookURL(config *AppConfig, source string) string {
+	if profile, ok := config.Sources[source]; ok && profile.WebhookURL != "" {
+		return profile.WebhookURL
+	}
+	return discordTarget(config)
+}
+
+// sourceLogConfig returns a copy of config with the source's path and
+// format applied, for writing that source's log files.
+func sourceLogConfig(config *AppConfig, source string) *AppConfig {
+	profile, ok := config.Sources[source]
+	if !ok {
+		return config
+	}
+	cfg := *config
+	if profile.Path != "" {
+		cfg.Path = profile.Path
+	}
+	if profile.FileFormat != "" {
+		cfg.FileFormat = profile.FileFormat
+	}
+	return &cfg
+}
+
+// parseSources parses the web UI's source lines, one
+// "Name = listen=host:port; webhook=URL; path=dir; format=csv" per line,
+// into profiles.
+func parseSources(text string) (map[string]SourceProfile, error) {
+	lines := parseNameMap(text)
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	sources := make(map[string]SourceProfile, len(lines))
+	for name, options := range lines {
+		var profile SourceProfile
+		for _, option := range strings.Split(options, ";") {
+			key, value, _ := strings.Cut(option, "=")
+			key = strings.TrimSpace(key)
+			value = strings.TrimSpace(value)
+			switch key {
+			case "":
+				continue
+			case "listen":
+				profile.ListenAddr = value
+			case "webhook":
+				profile.WebhookURL = value
+			case "path":
+				profile.Path = value
+			case "format":
+				profile.FileFormat = value
+			default:
+				return nil, fmt.Errorf("Unknown setting %q for source %s", key, name)
+			}
+		}
+		sources[name] = profile
+	}
+	return sources, nil
+}
+
+// formatSources renders source profiles as sorted lines for the web UI.
+func formatSources(sources map[string]SourceProfile) string {
+	var b strings.Builder
+	for _, name := range sortedSourceNames(sources) {
+		profile := sources[name]
+		var options []string
+		if profile.ListenAddr != "" {
+			options = append(options, "listen="+profile.ListenAddr)
+		}
+		if profile.WebhookURL != "" {
+			options = append(options, "webhook="+profile.WebhookURL)
+		}
+		if profile.Path != "" {
+			options = append(options, "path="+profile.Path)
+		}
+		if profile.FileFormat != "" {
+			options = append(options, "format="+profile.FileFormat)
+		}
+		fmt.Fprintf(&b, "%s = %s\n", name, strings.Join(options, "; "))
+	}
+	return b.String()
+}
+
+// validateSources checks that every source listener has its own address
+// and that source log formats are known.
+func validateSources(config *AppConfig) error {
+	seen := map[string]string{config.ListenAddr: "the main listener"}
+	for _, name := range sortedSourceNames(config.Sources) {
+		profile := config.Sources[name]
+		switch profile.FileFormat {
+		case "", "txt", "csv", "json", "docx":
+		default:
+			return fmt.Errorf("Source %s has unknown format %q", name, profile.FileFormat)
+		}
+		addr := profile.ListenAddr
+		if addr == "" {
+			continue
+		}
+		if other, ok := seen[addr]; ok {
+			return fmt.Errorf("Source %s listens on %s, already used by %s", name, addr, other)
+		}
+		seen[addr] = "source " + name
+	}
+	return nil
+}
+
+// sortedSourceNames returns the source names in a stable order.
+func sortedSourceNames(sources map[string]SourceProfile) []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseSources(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]SourceProfile
+		wantErr  bool
+	}{
+		{
+			name:  "listen and webhook",
+			input: "Siptah = listen=0.0.0.0:3001; webhook=https://discord.test/a; path=/logs/siptah; format=csv\nExiled = webhook=https://discord.test/b\n",
+			expected: map[string]SourceProfile{
+				"Siptah": {ListenAddr: "0.0.0.0:3001", WebhookURL: "https://discord.test/a", Path: "/logs/siptah", FileFormat: "csv"},
+				"Exiled": {WebhookURL: "https://discord.test/b"},
+			},
+		},
+		{
+			name:     "empty",
+			input:    "\n",
+			expected: nil,
+		},
+		{
+			name:    "unknown setting",
+			input:   "Siptah = port=3001",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSources(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+			if !tt.wantErr {
+				roundTrip, err := parseSources(formatSources(got))
+				if err != nil || !reflect.DeepEqual(roundTrip, tt.expected) {
+					t.Errorf("round trip: expected %v, got %v (%v)", tt.expected, roundTrip, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSources(t *testing.T) {
+	config := &AppConfig{
+		ListenAddr: "localhost:3000",
+		Sources: map[string]SourceProfile{
+			"Siptah": {ListenAddr: "localhost:3001"},
+			"Exiled": {WebhookURL: "https://discord.test/b"},
+		},
+	}
+	if err := validateSources(config); err != nil {
+		t.Errorf("expected valid sources, got %v", err)
+	}
+
+	config.Sources["Isle"] = SourceProfile{ListenAddr: "localhost:3000"}
+	if err := validateSources(config); err == nil {
+		t.Error("expected error for a source reusing the main listen address")
+	}
+
+	config.Sources["Isle"] = SourceProfile{FileFormat: "pdf"}
+	if err := validateSources(config); err == nil {
+		t.Error("expected error for an unknown source format")
+	}
+}
+
+func TestRequestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		listener string
+		url      string
+		header   string
+		expected string
+	}{
+		{name: "none", url: "/message"},
+		{name: "query", url: "/message?source=Siptah", expected: "Siptah"},
+		{name: "header", url: "/message", header: "Exiled", expected: "Exiled"},
+		{name: "query before header", url: "/message?source=Siptah", header: "Exiled", expected: "Siptah"},
+		{name: "listener wins", listener: "Isle", url: "/message?source=Siptah", expected: "Isle"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set(sourceHeader, tt.header)
+			}
+			if tt.listener != "" {
+				req = req.WithContext(withSource(req.Context(), tt.listener))
+			}
+			if got := requestSource(req); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCreateHandler_SourceLogPath(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	mainDir := t.TempDir()
+	sourceDir := t.TempDir()
+	a.config.EnableLocalSave = true
+	a.config.Path = mainDir
+	a.config.FileFormat = "txt"
+	a.config.Sources = map[string]SourceProfile{
+		"Siptah": {Path: sourceDir, FileFormat: "csv"},
+	}
+
+	req := httptest.NewRequest("GET", "/message?sender=A&message=Hello&source=Siptah", nil)
+	createHandler(a).ServeHTTP(httptest.NewRecorder(), req)
+	waitForDeliveries(t, a)
+
+	entries, err := readLogFile(generateLogFilename(sourceDir, "csv"), "csv")
+	if err != nil {
+		t.Fatalf("expected source log file: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Source != "Siptah" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+	if _, err := os.Stat(generateLogFilename(mainDir, "txt")); err == nil {
+		t.Error("source message should not be written to the main log")
+	}
+}
+
+func TestCreateHandler_SourceRouting(t *testing.T) {
+	var mainHits, sourceHits int
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.URL.Path == "/siptah" {
+			sourceHits++
+		} else {
+			mainHits++
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer discord.Close()
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	tmpDir := t.TempDir()
+	a.config.EnableDiscord = true
+	a.config.WebhookURL = discord.URL + "/main"
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+	a.config.FileFormat = "json"
+	a.config.Sources = map[string]SourceProfile{
+		"Siptah": {WebhookURL: discord.URL + "/siptah"},
+	}
+
+	for _, source := range []string{"", "Siptah"} {
+		req := httptest.NewRequest("GET", "/message?sender=A&message=Hello", nil)
+		req = req.WithContext(withSource(req.Context(), source))
+		createHandler(a).ServeHTTP(httptest.NewRecorder(), req)
+		waitForDeliveries(t, a)
+	}
+
+	if mainHits != 1 || sourceHits != 1 {
+		t.Errorf("expected one message per webhook, got main=%d source=%d", mainHits, sourceHits)
+	}
+
+	data, err := os.ReadFile(generateLogFilename(tmpDir, "json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []LogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Source != "" || entries[1].Source != "Siptah" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
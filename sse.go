@@ -1,31 +1,55 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// sseEvent is one Server-Sent Event. IDs start from the clock (see
+// firstEventID), so they keep increasing across restarts and a
+// reconnecting browser's Last-Event-ID says which events it has seen.
+type sseEvent struct {
+	ID   int64  `json:"id"`
+	Data string `json:"data"`
+}
+
+// firstEventID returns the ID before a stream's first event.
+func firstEventID() int64 {
+	return time.Now().UnixNano()
+}
+
 // SSEBroker manages SSE client connections and broadcasts log events.
 type SSEBroker struct {
-	clients    map[chan string]struct{}
+	clients    map[chan sseEvent]struct{}
 	mu         sync.RWMutex
-	register   chan chan string
-	unregister chan chan string
-	broadcast  chan string
+	register   chan chan sseEvent
+	unregister chan chan sseEvent
+	broadcast  chan sseEvent
 	done       chan struct{}
 }
 
 // NewSSEBroker creates and starts a new SSE broker.
 func NewSSEBroker() *SSEBroker {
 	b := &SSEBroker{
-		clients:    make(map[chan string]struct{}),
-		register:   make(chan chan string),
-		unregister: make(chan chan string),
-		broadcast:  make(chan string, 256),
+		clients:    make(map[chan sseEvent]struct{}),
+		register:   make(chan chan sseEvent),
+		unregister: make(chan chan sseEvent),
+		broadcast:  make(chan sseEvent, 256),
 		done:       make(chan struct{}),
 	}
 	go b.run()
@@ -40,14 +64,14 @@ func (b *SSEBroker) run() {
 			b.clients[client] = struct{}{}
 			count := len(b.clients)
 			b.mu.Unlock()
-			log.Printf("[DEBUG] SSE: client registered, total clients=%d", count)
+			slog.Log(context.Background(), levelTrace, "SSE client registered", "clients", count)
 		case client := <-b.unregister:
 			b.mu.Lock()
 			delete(b.clients, client)
 			close(client)
 			count := len(b.clients)
 			b.mu.Unlock()
-			log.Printf("[DEBUG] SSE: client unregistered, total clients=%d", count)
+			slog.Log(context.Background(), levelTrace, "SSE client unregistered", "clients", count)
 		case msg := <-b.broadcast:
 			b.mu.RLock()
 			clientCount := len(b.clients)
@@ -61,30 +85,30 @@ func (b *SSEBroker) run() {
 			}
 			b.mu.RUnlock()
 			if skipped > 0 {
-				log.Printf("[DEBUG] SSE: broadcast to %d clients, %d slow clients skipped", clientCount, skipped)
+				slog.Debug("SSE broadcast skipped slow clients", "clients", clientCount, "skipped", skipped)
 			}
 		case <-b.done:
-			log.Printf("[DEBUG] SSE: broker shutting down")
+			slog.Debug("SSE broker shutting down")
 			return
 		}
 	}
 }
 
 // Subscribe returns a channel that receives log events.
-func (b *SSEBroker) Subscribe() chan string {
-	ch := make(chan string, 64)
+func (b *SSEBroker) Subscribe() chan sseEvent {
+	ch := make(chan sseEvent, 64)
 	b.register <- ch
 	return ch
 }
 
 // Unsubscribe removes a client channel.
-func (b *SSEBroker) Unsubscribe(ch chan string) {
+func (b *SSEBroker) Unsubscribe(ch chan sseEvent) {
 	b.unregister <- ch
 }
 
-// Publish sends a message to all subscribers.
-func (b *SSEBroker) Publish(msg string) {
-	b.broadcast <- msg
+// Publish sends an event to all subscribers.
+func (b *SSEBroker) Publish(ev sseEvent) {
+	b.broadcast <- ev
 }
 
 // Stop shuts down the broker goroutine.
@@ -94,25 +118,56 @@ func (b *SSEBroker) Stop() {
 
 // FailureEntry represents a failed message processing attempt.
 type FailureEntry struct {
+	ID          int64
 	Timestamp   string
 	Sender      string
 	Message     string
-	FailureType string // "discord", "file", "other"
+	FailureType string // "discord", "forward", "file", "other"
 	Error       string
+	Trace       string // trace ID of the failed message, if any
+
+	// Retry holds what is needed to deliver the message again, or nil
+	// when the failure cannot be retried.
+	Retry *FailureRetry
 }
 
+// FailureRetry describes how to redeliver a failed message: either a
+// Discord or forward delivery to re-queue, or a log entry to write again.
+type FailureRetry struct {
+	Delivery  *QueuedMessage
+	LogConfig *AppConfig
+	Entry     *LogEntry
+	MessageID string // receipt ID for a file retry
+}
+
+const (
+	// Default history sizes, used when the config leaves them at 0.
+	defaultLogHistory     = 500
+	defaultFailureHistory = 100
+	// maxHistorySize bounds both history sizes.
+	maxHistorySize = 100000
+	// logHistoryFile (next to the config file) holds the live log history
+	// between runs when PersistLogHistory is set.
+	logHistoryFile = "log-history.json"
+)
+
 // SSELogger implements the Logger interface, broadcasting logs
 // to all connected SSE clients and keeping a ring buffer of recent history.
+// Every line is also written to the application log file.
 type SSELogger struct {
 	broker        *SSEBroker
 	failureBroker *SSEBroker
-	debugMode     atomic.Bool
-	history       []string
+	minLevel      slog.LevelVar
+	echo          atomic.Bool
+	fileLog       atomic.Pointer[slog.Logger]
+	history       []sseEvent
 	historyMu     sync.RWMutex
 	maxHistory    int
+	nextLogID     int64
 	failures      []FailureEntry
 	failuresMu    sync.RWMutex
 	maxFailures   int
+	nextFailureID int64
 }
 
 // NewSSELogger creates a new SSE-backed logger.
@@ -120,10 +175,12 @@ func NewSSELogger(broker *SSEBroker, failureBroker *SSEBroker) *SSELogger {
 	return &SSELogger{
 		broker:        broker,
 		failureBroker: failureBroker,
-		maxHistory:    500,
-		history:       make([]string, 0, 500),
-		maxFailures:   100,
-		failures:      make([]FailureEntry, 0, 100),
+		maxHistory:    defaultLogHistory,
+		history:       make([]sseEvent, 0, defaultLogHistory),
+		nextLogID:     firstEventID(),
+		maxFailures:   defaultFailureHistory,
+		failures:      make([]FailureEntry, 0, defaultFailureHistory),
+		nextFailureID: firstEventID(),
 	}
 }
 
@@ -133,10 +190,11 @@ func (l *SSELogger) Log(level, message string) {
 	if l == nil {
 		return
 	}
-	if !l.debugMode.Load() && level == "debug" {
+	if levelFor(level) < l.minLevel.Level() {
 		return
 	}
 
+	message = redactSecrets(message)
 	timestamp := time.Now().Format("15:04:05")
 	levelTag := ""
 	switch level {
@@ -148,23 +206,135 @@ func (l *SSELogger) Log(level, message string) {
 		levelTag = "[INFO] "
 	case "debug":
 		levelTag = "[DEBUG] "
+	case "trace":
+		levelTag = "[TRACE] "
 	}
 
 	logLine := fmt.Sprintf("[%s] %s%s", timestamp, levelTag, message)
 
+	if l.echo.Load() {
+		slog.Log(context.Background(), levelFor(level), message)
+	} else if fileLog := l.fileLog.Load(); fileLog != nil {
+		fileLog.Log(context.Background(), levelFor(level), message)
+	}
+
+	// Publish under the lock so events reach subscribers in ID order.
 	l.historyMu.Lock()
-	if len(l.history) >= l.maxHistory {
-		l.history = l.history[1:]
+	defer l.historyMu.Unlock()
+	l.nextLogID++
+	ev := sseEvent{ID: l.nextLogID, Data: logLine}
+	l.history = append(l.history, ev)
+	if len(l.history) > l.maxHistory {
+		l.history = l.history[len(l.history)-l.maxHistory:]
+	}
+	l.broker.Publish(ev)
+}
+
+// SetLogLevel sets the minimum level shown in the web UI and written to
+// the application log. Unknown names mean info.
+func (l *SSELogger) SetLogLevel(name string) {
+	level := levelFor(name)
+	l.minLevel.Set(level)
+	appLevel.Set(level)
+}
+
+// SetHistoryLimits sets how many log lines and failures are kept for
+// newly connected clients, dropping the oldest beyond the new limits. 0
+// means the default.
+func (l *SSELogger) SetHistoryLimits(logs, failures int) {
+	if logs <= 0 {
+		logs = defaultLogHistory
+	}
+	if failures <= 0 {
+		failures = defaultFailureHistory
+	}
+
+	l.historyMu.Lock()
+	l.maxHistory = logs
+	if len(l.history) > logs {
+		l.history = l.history[len(l.history)-logs:]
 	}
-	l.history = append(l.history, logLine)
 	l.historyMu.Unlock()
 
-	l.broker.Publish(logLine)
+	l.failuresMu.Lock()
+	l.maxFailures = failures
+	if len(l.failures) > failures {
+		l.failures = l.failures[len(l.failures)-failures:]
+	}
+	l.failuresMu.Unlock()
+}
+
+// saveHistory writes the log history to path. A nil logger has none to
+// write.
+func (l *SSELogger) saveHistory(path string) error {
+	if l == nil {
+		return nil
+	}
+	l.historyMu.RLock()
+	data, err := json.Marshal(l.history)
+	l.historyMu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("encoding log history: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating log history directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("writing log history: %w", err)
+	}
+	return nil
+}
+
+// loadHistory puts the log lines saved at path in front of the current
+// history. A missing file is not an error.
+func (l *SSELogger) loadHistory(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading log history: %w", err)
+	}
+	var saved []sseEvent
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("decoding log history: %w", err)
+	}
+
+	l.historyMu.Lock()
+	defer l.historyMu.Unlock()
+	// Saved IDs come from an earlier run; lines logged since must follow
+	// them so reconnecting browsers see one increasing sequence.
+	var last int64
+	if len(saved) > 0 {
+		last = saved[len(saved)-1].ID
+	}
+	for _, ev := range l.history {
+		if ev.ID <= last {
+			ev.ID = last + 1
+		}
+		last = ev.ID
+		saved = append(saved, ev)
+	}
+	l.history = saved
+	if len(l.history) > l.maxHistory {
+		l.history = l.history[len(l.history)-l.maxHistory:]
+	}
+	if last > l.nextLogID {
+		l.nextLogID = last
+	}
+	return nil
+}
+
+// SetFileLogger sets the logger that receives log lines when they are not
+// echoed to the console.
+func (l *SSELogger) SetFileLogger(logger *slog.Logger) {
+	l.fileLog.Store(logger)
 }
 
-// SetDebugMode updates whether debug-level messages are shown.
-func (l *SSELogger) SetDebugMode(enabled bool) {
-	l.debugMode.Store(enabled)
+// SetEcho updates whether log lines are also written to the console, for
+// running without a web UI.
+func (l *SSELogger) SetEcho(enabled bool) {
+	l.echo.Store(enabled)
 }
 
 // GetHistory returns recent log lines for newly connected clients.
@@ -172,18 +342,36 @@ func (l *SSELogger) GetHistory() []string {
 	l.historyMu.RLock()
 	defer l.historyMu.RUnlock()
 	result := make([]string, len(l.history))
-	copy(result, l.history)
+	for i, ev := range l.history {
+		result[i] = ev.Data
+	}
 	return result
 }
 
+// historySince returns the recent log events after the given ID, or all of
+// them for 0.
+func (l *SSELogger) historySince(lastID int64) []sseEvent {
+	l.historyMu.RLock()
+	defer l.historyMu.RUnlock()
+	return eventsAfter(l.history, lastID)
+}
+
 // GetHistoryText returns recent log history as a single joined string.
 func (l *SSELogger) GetHistoryText() string {
 	lines := l.GetHistory()
 	return strings.Join(lines, "\n")
 }
 
-// LogFailure records a failed message processing attempt.
+// LogFailure records a failed message processing attempt that cannot be
+// retried.
 func (l *SSELogger) LogFailure(sender, message, failureType, errMsg string) {
+	l.LogRetryableFailure(sender, message, failureType, errMsg, "", nil)
+}
+
+// LogRetryableFailure records a failed message processing attempt together
+// with what is needed to retry it from the failures page. trace is the
+// message's trace ID, or "".
+func (l *SSELogger) LogRetryableFailure(sender, message, failureType, errMsg, trace string, retry *FailureRetry) {
 	if l == nil {
 		return
 	}
@@ -191,22 +379,45 @@ func (l *SSELogger) LogFailure(sender, message, failureType, errMsg string) {
 	entry := FailureEntry{
 		Timestamp:   time.Now().Format("15:04:05"),
 		Sender:      sender,
-		Message:     message,
+		Message:     redactSecrets(message),
 		FailureType: failureType,
-		Error:       errMsg,
+		Error:       redactSecrets(errMsg),
+		Trace:       trace,
+		Retry:       retry,
 	}
 
 	l.failuresMu.Lock()
-	if len(l.failures) >= l.maxFailures {
-		l.failures = l.failures[1:]
-	}
+	l.nextFailureID++
+	entry.ID = l.nextFailureID
 	l.failures = append(l.failures, entry)
+	if len(l.failures) > l.maxFailures {
+		l.failures = l.failures[len(l.failures)-l.maxFailures:]
+	}
+	// Broadcast formatted failure to SSE clients, in ID order
+	l.failureBroker.Publish(sseEvent{ID: entry.ID, Data: failureLine(entry)})
 	l.failuresMu.Unlock()
+}
 
-	// Broadcast formatted failure to SSE clients
-	failureLine := fmt.Sprintf("[%s] %s | %s: %s | Error: %s",
-		entry.Timestamp, entry.FailureType, entry.Sender, truncateMessage(entry.Message, 100), entry.Error)
-	l.failureBroker.Publish(failureLine)
+// failureLine formats a failure for the failure stream.
+func failureLine(f FailureEntry) string {
+	line := fmt.Sprintf("[%s] %s | %s: %s | Error: %s",
+		f.Timestamp, f.FailureType, f.Sender, truncateMessage(f.Message, 100), f.Error)
+	if f.Trace != "" {
+		line += " | Trace: " + f.Trace
+	}
+	return line
+}
+
+// failureEventsSince returns the recorded failures after the given ID as
+// stream events.
+func (l *SSELogger) failureEventsSince(lastID int64) []sseEvent {
+	var events []sseEvent
+	for _, f := range l.GetFailures() {
+		if f.ID > lastID {
+			events = append(events, sseEvent{ID: f.ID, Data: failureLine(f)})
+		}
+	}
+	return events
 }
 
 // GetFailures returns recent failure entries for newly connected clients.
@@ -218,6 +429,237 @@ func (l *SSELogger) GetFailures() []FailureEntry {
 	return result
 }
 
+// GetFailure returns the failure entry with the given ID.
+func (l *SSELogger) GetFailure(id int64) (FailureEntry, bool) {
+	l.failuresMu.RLock()
+	defer l.failuresMu.RUnlock()
+	for _, f := range l.failures {
+		if f.ID == id {
+			return f, true
+		}
+	}
+	return FailureEntry{}, false
+}
+
+// DismissFailure removes the failure entry with the given ID and reports
+// whether it existed.
+func (l *SSELogger) DismissFailure(id int64) bool {
+	l.failuresMu.Lock()
+	defer l.failuresMu.Unlock()
+	for i, f := range l.failures {
+		if f.ID == id {
+			l.failures = append(l.failures[:i:i], l.failures[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// removeFailures removes the failure entries match selects and returns how
+// many it removed. A nil logger has none.
+func (l *SSELogger) removeFailures(match func(FailureEntry) bool) int {
+	if l == nil {
+		return 0
+	}
+	l.failuresMu.Lock()
+	defer l.failuresMu.Unlock()
+	kept := l.failures[:0]
+	for _, f := range l.failures {
+		if !match(f) {
+			kept = append(kept, f)
+		}
+	}
+	n := len(l.failures) - len(kept)
+	l.failures = kept
+	return n
+}
+
+// removeHistory removes the log lines match selects from the history.
+// Browsers that already received them keep them until they reload.
+func (l *SSELogger) removeHistory(match func(line string) bool) {
+	if l == nil {
+		return
+	}
+	l.historyMu.Lock()
+	defer l.historyMu.Unlock()
+	kept := l.history[:0]
+	for _, ev := range l.history {
+		if !match(ev.Data) {
+			kept = append(kept, ev)
+		}
+	}
+	l.history = kept
+}
+
+// ClearFailures removes all failure entries.
+func (l *SSELogger) ClearFailures() {
+	l.failuresMu.Lock()
+	l.failures = make([]FailureEntry, 0, l.maxFailures)
+	l.failuresMu.Unlock()
+}
+
+// sseHeartbeatInterval is how often a stream sends a comment line, so
+// proxies keep idle connections open and browsers notice dead ones.
+var sseHeartbeatInterval = 15 * time.Second
+
+// sseRetryMillis is the reconnect delay suggested to browsers.
+const sseRetryMillis = 3000
+
+// eventsAfter returns the events in history after the given ID, or all of
+// them for 0. A browser whose last event has already left the history
+// gets everything that is left.
+func eventsAfter(history []sseEvent, lastID int64) []sseEvent {
+	var events []sseEvent
+	for _, ev := range history {
+		if ev.ID > lastID {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+// lastEventID returns the ID a reconnecting browser sends in the
+// Last-Event-ID header, or in the lastEventId query parameter when the
+// page reopened the stream itself, or 0 for a new connection.
+func lastEventID(r *http.Request) int64 {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("lastEventId")
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0
+	}
+	return id
+}
+
+// streamEvents serves a Server-Sent Events stream: the backlog of events
+// after the browser's Last-Event-ID, then live events from broker, with a
+// heartbeat comment while idle, until the client goes away. render turns
+// an event into its HTML, or returns false to leave it out.
+func streamEvents(w http.ResponseWriter, r *http.Request, broker *SSEBroker, backlog func(lastID int64) []sseEvent, render func(sseEvent) (string, bool)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	// Subscribe before reading the backlog so nothing published in
+	// between is missed; events the backlog already covered are skipped.
+	ch := broker.Subscribe()
+	defer broker.Unsubscribe(ch)
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis)
+	lastID := lastEventID(r)
+	send := func(ev sseEvent) {
+		if ev.ID <= lastID {
+			return
+		}
+		lastID = ev.ID
+		if data, ok := render(ev); ok {
+			writeEvent(w, ev.ID, data)
+		}
+	}
+	for _, ev := range backlog(lastID) {
+		send(ev)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	ctx := r.Context()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			send(ev)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes one SSE event. Each line of data gets its own data
+// field, so multi-line messages arrive intact.
+func writeEvent(w io.Writer, id int64, data string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "id: %d\n", id)
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	io.WriteString(w, b.String())
+}
+
+// logLineFilter selects the log lines sent to a live log stream.
+type logLineFilter struct {
+	minLevel slog.Level
+	contains string // lower-cased; empty matches every line
+}
+
+// parseLogLineFilter reads the "level" (minimum level) and "contains"
+// (case-insensitive text) query parameters of a log stream request.
+func parseLogLineFilter(query url.Values) (logLineFilter, error) {
+	f := logLineFilter{minLevel: levelTrace}
+	if name := query.Get("level"); name != "" {
+		level, ok := parseLogLevel(name)
+		if !ok {
+			return f, fmt.Errorf("unknown log level %q", name)
+		}
+		f.minLevel = level
+	}
+	f.contains = strings.ToLower(strings.TrimSpace(query.Get("contains")))
+	return f, nil
+}
+
+// match reports whether a formatted log line passes the filter.
+func (f logLineFilter) match(line string) bool {
+	if level, _ := logLineLevel(line); level < f.minLevel {
+		return false
+	}
+	return f.contains == "" || strings.Contains(strings.ToLower(line), f.contains)
+}
+
+// logLineLevel returns the level of a line formatted by SSELogger.Log and
+// its lower-cased name, or info and "" for a line without a level tag.
+func logLineLevel(line string) (slog.Level, string) {
+	_, rest, ok := strings.Cut(line, "] [")
+	if !ok {
+		return slog.LevelInfo, ""
+	}
+	tag, _, ok := strings.Cut(rest, "] ")
+	if !ok {
+		return slog.LevelInfo, ""
+	}
+	name := strings.ToLower(tag)
+	level, ok := parseLogLevel(name)
+	if !ok {
+		return slog.LevelInfo, ""
+	}
+	return level, name
+}
+
+// logLineHTML formats a log line for the log viewer, with a class naming
+// its level.
+func logLineHTML(line string) string {
+	class := "log-line"
+	if _, name := logLineLevel(line); name != "" {
+		class += " log-" + name
+	}
+	return fmt.Sprintf(`<div class="%s">%s</div>`, class, template.HTMLEscapeString(line))
+}
+
 // truncateMessage shortens a message to maxLen characters with ellipsis.
 func truncateMessage(msg string, maxLen int) string {
 	if len(msg) <= maxLen {
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogLineFilter(t *testing.T) {
+	lines := []string{
+		"[12:00:00] [ERROR] Discord send failed",
+		"[12:00:01] [WARNING] Rate limited by Discord",
+		"[12:00:02] [INFO] Message from Conan",
+		"[12:00:03] [TRACE] Raw request",
+		"[12:00:04] untagged line",
+	}
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{0, 1, 2, 3, 4}},
+		{"level=error", []int{0}},
+		{"level=warn", []int{0, 1}},
+		{"level=info", []int{0, 1, 2, 4}},
+		{"contains=discord", []int{0, 1}},
+		{"level=error&contains=conan", nil},
+		{"contains=+CONAN+", []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			f, err := parseLogLineFilter(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for i, line := range lines {
+				if f.match(line) {
+					got = append(got, i)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected lines %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected lines %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+
+	if _, err := parseLogLineFilter(url.Values{"level": {"loud"}}); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestLogLineHTML(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"[12:00:00] [ERROR] <b>bad</b>", `<div class="log-line log-error">[12:00:00] [ERROR] &lt;b&gt;bad&lt;/b&gt;</div>`},
+		{"[12:00:00] plain", `<div class="log-line">[12:00:00] plain</div>`},
+	}
+	for _, tt := range tests {
+		if got := logLineHTML(tt.line); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestHandleSSEStream_Filter(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.logger.Log("error", "Discord send failed")
+	a.logger.Log("info", "Message from Conan")
+
+	// A cancelled request still gets the filtered history before returning.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/api/logs/stream?level=error", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	a.handleSSEStream(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Discord send failed") || strings.Contains(body, "Message from Conan") {
+		t.Errorf("expected only the error line, got %q", body)
+	}
+
+	rec = httptest.NewRecorder()
+	a.handleSSEStream(rec, httptest.NewRequest("GET", "/api/logs/stream?level=loud", nil))
+	if rec.Code != 400 {
+		t.Errorf("expected 400 for an unknown level, got %d", rec.Code)
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	var b strings.Builder
+	writeEvent(&b, 42, "<div>first\nsecond</div>")
+	want := "id: 42\ndata: <div>first\ndata: second</div>\n\n"
+	if b.String() != want {
+		t.Errorf("expected %q, got %q", want, b.String())
+	}
+}
+
+func TestStreamEvents_LastEventID(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.logger.Log("info", "first")
+	a.logger.Log("info", "second")
+	a.logger.Log("info", "third")
+	seen := a.logger.historySince(0)[1].ID
+
+	tests := []struct {
+		name    string
+		lastID  string
+		want    []string
+		notWant []string
+	}{
+		{"new connection", "", []string{"first", "second", "third"}, nil},
+		{"reconnect", fmt.Sprint(seen), []string{"third", fmt.Sprintf("id: %d\n", seen+1)}, []string{"first", "second"}},
+		{"garbage header", "abc", []string{"first", "third"}, nil},
+		{"query parameter", "?lastEventId=" + fmt.Sprint(seen), []string{"third"}, []string{"first", "second"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			target := "/api/logs/stream"
+			if strings.HasPrefix(tt.lastID, "?") {
+				target, tt.lastID = target+tt.lastID, ""
+			}
+			req := httptest.NewRequest("GET", target, nil).WithContext(ctx)
+			if tt.lastID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastID)
+			}
+			rec := httptest.NewRecorder()
+			a.handleSSEStream(rec, req)
+
+			body := rec.Body.String()
+			if !strings.HasPrefix(body, "retry: 3000\n\n") {
+				t.Errorf("expected a retry hint first, got %q", body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("expected %q in %q", want, body)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(body, notWant) {
+					t.Errorf("did not expect %q in %q", notWant, body)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamEvents_LiveAndHeartbeat(t *testing.T) {
+	orig := sseHeartbeatInterval
+	sseHeartbeatInterval = 10 * time.Millisecond
+	t.Cleanup(func() { sseHeartbeatInterval = orig })
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		a.handleFailureStream(rec, httptest.NewRequest("GET", "/api/failures/stream", nil).WithContext(ctx))
+		close(done)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	a.logger.LogFailure("Conan", "By Crom!", "discord", "boom")
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	<-done
+
+	body := rec.Body.String()
+	if !strings.Contains(body, ": heartbeat\n\n") {
+		t.Errorf("expected a heartbeat, got %q", body)
+	}
+	id := a.logger.GetFailures()[0].ID
+	if !strings.Contains(body, fmt.Sprintf("id: %d\ndata: <div class=\"failure-line\">", id)) || !strings.Contains(body, "Error: boom") {
+		t.Errorf("expected the live failure event, got %q", body)
+	}
+}
+
+func TestSSELoggerHistoryLimits(t *testing.T) {
+	broker, failureBroker := NewSSEBroker(), NewSSEBroker()
+	defer func() { broker.Stop(); failureBroker.Stop() }()
+	l := NewSSELogger(broker, failureBroker)
+
+	for i := 0; i < 10; i++ {
+		l.Log("info", fmt.Sprintf("line %d", i))
+		l.LogFailure("Conan", fmt.Sprintf("message %d", i), "discord", "boom")
+	}
+	l.SetHistoryLimits(3, 2)
+	if h := l.GetHistory(); len(h) != 3 || !strings.HasSuffix(h[0], "line 7") {
+		t.Errorf("expected the last 3 lines, got %q", h)
+	}
+	if f := l.GetFailures(); len(f) != 2 || f[0].Message != "message 8" {
+		t.Errorf("expected the last 2 failures, got %+v", f)
+	}
+
+	l.Log("info", "line 10")
+	if h := l.GetHistory(); len(h) != 3 || !strings.HasSuffix(h[2], "line 10") {
+		t.Errorf("expected the new limit to hold, got %q", h)
+	}
+
+	l.SetHistoryLimits(0, 0)
+	for i := 0; i < defaultLogHistory+10; i++ {
+		l.Log("info", "filler")
+	}
+	if n := len(l.GetHistory()); n != defaultLogHistory {
+		t.Errorf("expected the default of %d lines, got %d", defaultLogHistory, n)
+	}
+}
+
+func TestSSELoggerPersistHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), logHistoryFile)
+	broker, failureBroker := NewSSEBroker(), NewSSEBroker()
+	defer func() { broker.Stop(); failureBroker.Stop() }()
+
+	before := NewSSELogger(broker, failureBroker)
+	before.Log("info", "before the restart")
+	if err := before.saveHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	savedID := before.historySince(0)[0].ID
+
+	after := NewSSELogger(broker, failureBroker)
+	after.nextLogID = savedID - 100 // a clock that went backwards
+	after.Log("info", "starting up")
+	if err := after.loadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	after.Log("info", "after the restart")
+
+	events := after.historySince(0)
+	if len(events) != 3 || !strings.HasSuffix(events[0].Data, "before the restart") || !strings.HasSuffix(events[2].Data, "after the restart") {
+		t.Fatalf("unexpected history %+v", events)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].ID <= events[i-1].ID {
+			t.Errorf("expected increasing IDs, got %+v", events)
+		}
+	}
+
+	if err := NewSSELogger(broker, failureBroker).loadHistory(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("expected a missing file to be fine, got %v", err)
+	}
+}
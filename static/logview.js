@@ -0,0 +1,55 @@
+// Live log controls: a level and text filter applied by the server, and
+// pausing, which holds new lines back until resumed.
+(function () {
+  var paused = false
+  var held = []
+
+  // reconnect replaces the stream element so the SSE extension opens a
+  // new connection with the current filter.
+  function reconnect() {
+    var stream = document.getElementById('log-stream')
+    var params = new URLSearchParams()
+    var level = document.getElementById('log-level').value
+    var contains = document.getElementById('log-contains').value.trim()
+    if (level) params.set('level', level)
+    if (contains) params.set('contains', contains)
+    var query = params.toString()
+
+    var fresh = stream.cloneNode(false)
+    fresh.setAttribute('sse-connect', '/api/logs/stream' + (query ? '?' + query : ''))
+    stream.replaceWith(fresh)
+    document.getElementById('log-viewer').innerHTML = ''
+    held = []
+    htmx.process(fresh)
+  }
+
+  document.addEventListener('htmx:sseBeforeMessage', function (event) {
+    if (!paused || event.target.id !== 'log-stream') return
+    event.preventDefault()
+    held.push(event.detail.data)
+  })
+
+  document.addEventListener('DOMContentLoaded', function () {
+    var pause = document.getElementById('log-pause')
+    if (!pause) return
+
+    pause.addEventListener('click', function () {
+      paused = !paused
+      pause.textContent = paused ? 'Resume' : 'Pause'
+      if (paused) return
+      var viewer = document.getElementById('log-viewer')
+      viewer.insertAdjacentHTML('beforeend', held.join(''))
+      held = []
+      if (document.getElementById('log-stream').getAttribute('hx-swap').indexOf('scroll:bottom') !== -1) {
+        viewer.scrollTop = viewer.scrollHeight
+      }
+    })
+
+    var timer
+    document.getElementById('log-level').addEventListener('change', reconnect)
+    document.getElementById('log-contains').addEventListener('input', function () {
+      clearTimeout(timer)
+      timer = setTimeout(reconnect, 400)
+    })
+  })
+})()
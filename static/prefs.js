@@ -0,0 +1,88 @@
+// UI preferences: theme, collapsed sections, log panel height and
+// auto-scroll. They are saved per browser through /api/preferences and
+// arrive with the page in window.rpclPrefs.
+(function () {
+  var prefs = window.rpclPrefs || {}
+
+  function save(changes) {
+    Object.assign(prefs, changes)
+    fetch('/api/preferences', {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(changes)
+    })
+  }
+
+  // sectionKey names a section after its heading, e.g. "backfill-discord".
+  function sectionKey(section) {
+    var heading = section.querySelector(':scope > h2')
+    if (!heading) return ''
+    return heading.textContent.trim().toLowerCase().replace(/[^a-z0-9]+/g, '-').replace(/^-|-$/g, '')
+  }
+
+  function setupSections() {
+    var collapsed = prefs.collapsed || []
+    document.querySelectorAll('.container > section').forEach(function (section) {
+      var key = sectionKey(section)
+      if (!key) return
+      var heading = section.querySelector(':scope > h2')
+      heading.classList.add('collapsible')
+      section.classList.toggle('collapsed', collapsed.indexOf(key) !== -1)
+      heading.addEventListener('click', function () {
+        section.classList.toggle('collapsed')
+        var list = (prefs.collapsed || []).filter(function (k) { return k !== key })
+        if (section.classList.contains('collapsed')) list.push(key)
+        save({ collapsed: list })
+      })
+    })
+  }
+
+  function setupLogHeight() {
+    var viewer = document.getElementById('log-viewer')
+    if (!viewer) return
+    if (prefs.logHeight) viewer.style.height = prefs.logHeight + 'px'
+    if (!window.ResizeObserver) return
+    var timer
+    new ResizeObserver(function () {
+      var height = Math.round(viewer.getBoundingClientRect().height)
+      if (!height || height === prefs.logHeight) return
+      clearTimeout(timer)
+      timer = setTimeout(function () { save({ logHeight: height }) }, 500)
+    }).observe(viewer)
+  }
+
+  function applyAutoScroll(on) {
+    document.querySelectorAll('#log-stream, #failure-viewer, #chat-viewer').forEach(function (stream) {
+      stream.setAttribute('hx-swap', on ? 'beforeend scroll:bottom' : 'beforeend')
+    })
+  }
+
+  function setupAutoScroll() {
+    var box = document.getElementById('auto-scroll')
+    var on = prefs.autoScroll !== false
+    applyAutoScroll(on)
+    if (!box) return
+    box.checked = on
+    box.addEventListener('change', function () {
+      applyAutoScroll(box.checked)
+      save({ autoScroll: box.checked })
+    })
+  }
+
+  function setupThemeToggle() {
+    document.querySelectorAll('[data-theme-toggle]').forEach(function (button) {
+      button.addEventListener('click', function () {
+        var theme = document.documentElement.getAttribute('data-theme') === 'light' ? 'dark' : 'light'
+        document.documentElement.setAttribute('data-theme', theme)
+        save({ theme: theme })
+      })
+    })
+  }
+
+  document.addEventListener('DOMContentLoaded', function () {
+    setupSections()
+    setupLogHeight()
+    setupAutoScroll()
+    setupThemeToggle()
+  })
+})()
@@ -0,0 +1,18 @@
+// Resume live streams where they left off. Browsers send Last-Event-ID
+// when they reconnect by themselves, but a stream the SSE extension has to
+// reopen (after the server was away) starts over, so the last event ID
+// seen is added to its URL as lastEventId.
+(function () {
+  document.addEventListener('htmx:sseMessage', function (event) {
+    if (event.detail.lastEventId) event.target.rpclLastEventId = event.detail.lastEventId
+  })
+
+  document.addEventListener('htmx:sseError', function (event) {
+    var elt = event.target
+    var source = event.detail.source
+    if (!elt.rpclLastEventId || !source || source.readyState !== EventSource.CLOSED) return
+    var url = new URL(elt.getAttribute('sse-connect'), window.location.href)
+    url.searchParams.set('lastEventId', elt.rpclLastEventId)
+    elt.setAttribute('sse-connect', url.pathname + url.search)
+  })
+})()
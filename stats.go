@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// logTimestampLayout is the timestamp format used in every log file.
+const logTimestampLayout = "2006-01-02 15:04:05"
+
+// statsEntities is how many entries of the entity index the stats page
+// lists.
+const statsEntities = 10
+
+// SenderCount is a sender with the number of messages they sent.
+type SenderCount struct {
+	Sender string
+	Count  int
+}
+
+// LogStats aggregates a set of log entries.
+type LogStats struct {
+	Messages    int
+	TotalLength int
+	Senders     map[string]int
+	ByHour      [24]int
+	ByDay       map[string]int
+	First       time.Time
+	Last        time.Time
+}
+
+// aggregateEntries computes statistics over entries. Entries whose
+// timestamp cannot be parsed still count towards totals and senders.
+func aggregateEntries(entries []LogEntry) LogStats {
+	stats := LogStats{
+		Senders: make(map[string]int),
+		ByDay:   make(map[string]int),
+	}
+	for _, entry := range entries {
+		stats.Messages++
+		stats.TotalLength += len(entry.Message)
+		stats.Senders[entry.Sender]++
+
+		ts, err := time.ParseInLocation(logTimestampLayout, entry.Timestamp, time.Local)
+		if err != nil {
+			continue
+		}
+		stats.ByHour[ts.Hour()]++
+		stats.ByDay[ts.Format("2006-01-02")]++
+		if stats.First.IsZero() || ts.Before(stats.First) {
+			stats.First = ts
+		}
+		if ts.After(stats.Last) {
+			stats.Last = ts
+		}
+	}
+	return stats
+}
+
+// AverageLength returns the mean message length in bytes.
+func (s LogStats) AverageLength() int {
+	if s.Messages == 0 {
+		return 0
+	}
+	return s.TotalLength / s.Messages
+}
+
+// TopSenders returns up to n senders ordered by message count, then name.
+// A non-positive n returns all senders.
+func (s LogStats) TopSenders(n int) []SenderCount {
+	result := make([]SenderCount, 0, len(s.Senders))
+	for sender, count := range s.Senders {
+		result = append(result, SenderCount{Sender: sender, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Sender < result[j].Sender
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// entriesBetween reads the daily log files covering [from, to) and returns
+// the entries whose timestamps fall inside that window. Missing files are
+// skipped; the second return value lists the files that were read.
+func entriesBetween(config *AppConfig, from, to time.Time) ([]LogEntry, []string, error) {
+	flushLogs()
+	format := logFormat(config)
+	var entries []LogEntry
+	var files []string
+
+	for day := truncateToDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		dayFiles, err := logFilesForDate(config, config.Path, day)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, filename := range dayFiles {
+			dayEntries, err := readLogFile(filename, format)
+			if err != nil {
+				return nil, nil, err
+			}
+			files = append(files, filename)
+			for _, entry := range dayEntries {
+				ts, err := time.ParseInLocation(logTimestampLayout, entry.Timestamp, time.Local)
+				if err != nil || ts.Before(from) || !ts.Before(to) {
+					continue
+				}
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if len(files) > 1 {
+		// Days split across files (per sender, scene, ...) interleave.
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp < entries[j].Timestamp })
+	}
+	return entries, files, nil
+}
+
+// truncateToDay returns midnight (local time) at the start of t's day.
+func truncateToDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// StatsBar is one bar of a chart on the stats page.
+type StatsBar struct {
+	Label   string
+	Count   int
+	Percent int
+}
+
+// StatsView is the data rendered by the stats page.
+type StatsView struct {
+	Days          int
+	Messages      int
+	Senders       int
+	AverageLength int
+	ByHour        []StatsBar
+	TopSenders    []StatsBar
+	BusiestDays   []StatsBar
+	// Entities are the top of the entity index, when it was built.
+	Entities []Entity
+}
+
+// newStatsView converts aggregated stats into chart bars scaled to the
+// largest value in each chart.
+func newStatsView(stats LogStats, days int) StatsView {
+	view := StatsView{
+		Days:          days,
+		Messages:      stats.Messages,
+		Senders:       len(stats.Senders),
+		AverageLength: stats.AverageLength(),
+	}
+
+	for hour, count := range stats.ByHour {
+		view.ByHour = append(view.ByHour, StatsBar{Label: fmt.Sprintf("%02d:00", hour), Count: count})
+	}
+	for _, sc := range stats.TopSenders(10) {
+		view.TopSenders = append(view.TopSenders, StatsBar{Label: sc.Sender, Count: sc.Count})
+	}
+
+	dayCounts := make([]SenderCount, 0, len(stats.ByDay))
+	for day, count := range stats.ByDay {
+		dayCounts = append(dayCounts, SenderCount{Sender: day, Count: count})
+	}
+	sort.Slice(dayCounts, func(i, j int) bool {
+		if dayCounts[i].Count != dayCounts[j].Count {
+			return dayCounts[i].Count > dayCounts[j].Count
+		}
+		return dayCounts[i].Sender > dayCounts[j].Sender
+	})
+	for i, dc := range dayCounts {
+		if i == 7 {
+			break
+		}
+		view.BusiestDays = append(view.BusiestDays, StatsBar{Label: dc.Sender, Count: dc.Count})
+	}
+
+	scaleBars(view.ByHour)
+	scaleBars(view.TopSenders)
+	scaleBars(view.BusiestDays)
+	return view
+}
+
+// scaleBars sets each bar's Percent relative to the largest count.
+func scaleBars(bars []StatsBar) {
+	maxCount := 0
+	for _, bar := range bars {
+		maxCount = max(maxCount, bar.Count)
+	}
+	if maxCount == 0 {
+		return
+	}
+	for i := range bars {
+		bars[i].Percent = bars[i].Count * 100 / maxCount
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadLogFile_RoundTrip(t *testing.T) {
+	entries := []LogEntry{
+		{Timestamp: "2026-10-17 18:00:00", Sender: "Conan", Message: "Hello: friend"},
+		{Timestamp: "2026-10-17 18:01:00", Sender: "Valeria", Message: "draws her blade", Kind: kindEmote},
+		{Timestamp: "2026-10-17 18:02:00", Sender: "Conan", Message: "((brb))", Kind: kindOOC, Session: "Ch 1"},
+	}
+
+	for _, format := range []string{"txt", "csv", "json", "docx"} {
+		t.Run(format, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "log."+format)
+			for _, entry := range entries {
+				if err := writeLogEntry(filename, format, logLayout{}, entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := readLogFile(filename, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(entries) {
+				t.Fatalf("expected %d entries, got %d", len(entries), len(got))
+			}
+			for i := range entries {
+				want := entries[i]
+				if format == "txt" || format == "docx" {
+					// Sessions are only recorded in structured formats.
+					want.Session = ""
+				}
+				if got[i] != want {
+					t.Errorf("entry %d: expected %+v, got %+v", i, want, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAggregateEntries(t *testing.T) {
+	stats := aggregateEntries([]LogEntry{
+		{Timestamp: "2026-10-16 22:10:00", Sender: "Conan", Message: "abcd"},
+		{Timestamp: "2026-10-17 09:00:00", Sender: "Valeria", Message: "ab"},
+		{Timestamp: "2026-10-17 09:30:00", Sender: "Conan", Message: "abcdef"},
+		{Timestamp: "not a timestamp", Sender: "Subotai", Message: ""},
+	})
+
+	if stats.Messages != 4 {
+		t.Errorf("expected 4 messages, got %d", stats.Messages)
+	}
+	if avg := stats.AverageLength(); avg != 3 {
+		t.Errorf("expected average length 3, got %d", avg)
+	}
+	if stats.ByHour[9] != 2 || stats.ByHour[22] != 1 {
+		t.Errorf("unexpected hourly counts: %v", stats.ByHour)
+	}
+	if stats.ByDay["2026-10-17"] != 2 {
+		t.Errorf("expected 2 messages on 2026-10-17, got %d", stats.ByDay["2026-10-17"])
+	}
+	if got := stats.First.Format(logTimestampLayout); got != "2026-10-16 22:10:00" {
+		t.Errorf("unexpected first message time %s", got)
+	}
+	if got := stats.Last.Format(logTimestampLayout); got != "2026-10-17 09:30:00" {
+		t.Errorf("unexpected last message time %s", got)
+	}
+
+	top := stats.TopSenders(2)
+	if len(top) != 2 || top[0] != (SenderCount{"Conan", 2}) || top[1] != (SenderCount{"Subotai", 1}) {
+		t.Errorf("unexpected top senders: %+v", top)
+	}
+}
+
+func TestEntriesBetween(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &AppConfig{Path: dir, FileFormat: "txt"}
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.Local)
+
+	yesterday := logFilenameForDate(dir, "txt", now.AddDate(0, 0, -1))
+	today := logFilenameForDate(dir, "txt", now)
+	writeLogEntry(yesterday, "txt", logLayout{}, LogEntry{Timestamp: "2026-10-16 07:00:00", Sender: "A", Message: "too old"})
+	writeLogEntry(yesterday, "txt", logLayout{}, LogEntry{Timestamp: "2026-10-16 21:00:00", Sender: "A", Message: "in window"})
+	writeLogEntry(today, "txt", logLayout{}, LogEntry{Timestamp: "2026-10-17 07:59:59", Sender: "B", Message: "in window"})
+	writeLogEntry(today, "txt", logLayout{}, LogEntry{Timestamp: "2026-10-17 08:00:00", Sender: "B", Message: "too new"})
+
+	entries, files, err := entriesBetween(cfg, now.Add(-24*time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("expected 2 files, got %d", len(files))
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %+v", len(entries), entries)
+	}
+	for _, entry := range entries {
+		if entry.Message != "in window" {
+			t.Errorf("unexpected entry %+v", entry)
+		}
+	}
+}
+
+func TestNewStatsView(t *testing.T) {
+	stats := aggregateEntries([]LogEntry{
+		{Timestamp: "2026-10-16 10:00:00", Sender: "Conan", Message: "a"},
+		{Timestamp: "2026-10-17 10:00:00", Sender: "Conan", Message: "b"},
+		{Timestamp: "2026-10-17 11:00:00", Sender: "Valeria", Message: "c"},
+		{Timestamp: "2026-10-17 12:00:00", Sender: "Conan", Message: "d"},
+	})
+	view := newStatsView(stats, 7)
+
+	if len(view.ByHour) != 24 {
+		t.Fatalf("expected 24 hourly bars, got %d", len(view.ByHour))
+	}
+	if view.ByHour[10].Percent != 100 || view.ByHour[11].Percent != 50 || view.ByHour[0].Percent != 0 {
+		t.Errorf("unexpected hourly scaling: %+v", view.ByHour[9:13])
+	}
+	if len(view.TopSenders) != 2 || view.TopSenders[0].Label != "Conan" || view.TopSenders[1].Percent != 33 {
+		t.Errorf("unexpected top senders: %+v", view.TopSenders)
+	}
+	if len(view.BusiestDays) != 2 || view.BusiestDays[0].Label != "2026-10-17" {
+		t.Errorf("unexpected busiest days: %+v", view.BusiestDays)
+	}
+}
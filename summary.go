@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultSummaryPrompt is the request sent with a session's transcript
+// when SummaryPrompt is empty.
+const defaultSummaryPrompt = `You are the chronicler of a role-playing campaign. Write a narrative summary of the session "{{.Session}}" from its transcript below, in a few paragraphs and in the past tense. Name the characters involved and cover the key events, the decisions they made and the threads left open. Leave out out-of-character chatter.
+
+Transcript:
+{{.Transcript}}`
+
+// maxSummaryTranscript caps the transcript sent to the model, in bytes.
+// Longer sessions are cut at the start, so the summary covers how they
+// ended.
+const maxSummaryTranscript = 200_000
+
+// summaryClient allows for slow models; a summary is written once.
+var summaryClient = &http.Client{
+	Timeout: 3 * time.Minute,
+}
+
+// summaryPromptData is what SummaryPrompt templates can use.
+type summaryPromptData struct {
+	Session    string
+	Date       string // "2006-01-02", the day the session started
+	Transcript string
+}
+
+// summaryEndpoint returns the chat completions URL of cfg.SummaryURL,
+// which may be the API's base URL (https://api.openai.com/v1) or the
+// endpoint itself.
+func summaryEndpoint(cfg *AppConfig) string {
+	endpoint := strings.TrimRight(cfg.SummaryURL, "/")
+	if strings.HasSuffix(endpoint, "/chat/completions") {
+		return endpoint
+	}
+	return endpoint + "/chat/completions"
+}
+
+// checkSummaryPrompt reports a SummaryPrompt that isn't a valid template.
+func checkSummaryPrompt(text string) error {
+	if _, err := template.New("summary").Parse(text); err != nil {
+		return fmt.Errorf("Invalid summary prompt: %v", err)
+	}
+	return nil
+}
+
+// summaryPrompt renders the prompt for a session's transcript.
+func summaryPrompt(cfg *AppConfig, data summaryPromptData) (string, error) {
+	text := cfg.SummaryPrompt
+	if strings.TrimSpace(text) == "" {
+		text = defaultSummaryPrompt
+	}
+	tmpl, err := template.New("summary").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing summary prompt: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering summary prompt: %w", err)
+	}
+	return b.String(), nil
+}
+
+// summaryTranscript renders entries as a plain-text transcript for the
+// model, keeping its last maxSummaryTranscript bytes.
+func summaryTranscript(entries []LogEntry) string {
+	var b strings.Builder
+	for _, entry := range entries {
+		b.WriteString(formatTextLine(entry))
+	}
+	transcript := b.String()
+	if len(transcript) <= maxSummaryTranscript {
+		return transcript
+	}
+	cut := len(transcript) - maxSummaryTranscript
+	if i := strings.IndexByte(transcript[cut:], '\n'); i >= 0 {
+		cut += i + 1
+	}
+	return "(earlier lines left out)\n" + transcript[cut:]
+}
+
+// requestSummary asks the model for a completion of prompt and returns
+// its text.
+func requestSummary(ctx context.Context, cfg *AppConfig, prompt string) (string, error) {
+	data, err := json.Marshal(map[string]any{
+		"model":    cfg.SummaryModel,
+		"messages": []map[string]string{{"role": "user", "content": prompt}},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", summaryEndpoint(cfg), bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("creating summary request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rp-chat-logger/"+Version)
+	if cfg.SummaryAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.SummaryAPIKey)
+	}
+
+	resp, err := summaryClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sending summary request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("summary API returned status code %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("parsing summary response: %w", err)
+	}
+	if len(result.Choices) == 0 || strings.TrimSpace(result.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("summary API returned no text")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
+
+// sessionTranscriptFile returns the transcript of the named session and
+// the session's name. Without a name it picks the session in progress,
+// or else the most recently written transcript.
+func (a *App) sessionTranscriptFile(cfg *AppConfig, name string) (string, string, error) {
+	format := logFormat(cfg)
+	if name == "" {
+		if session, ok := a.CurrentSession(); ok {
+			name = session.Name
+		}
+	}
+	if name != "" {
+		path := sessionLogFilename(cfg.Path, name, format)
+		if _, err := os.Stat(path); err != nil {
+			return "", "", fmt.Errorf("no transcript of session %q", name)
+		}
+		return path, name, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(globEscape(filepath.Join(cfg.Path, "sessions")), "*."+format))
+	if err != nil {
+		return "", "", err
+	}
+	var latest string
+	var latestTime time.Time
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err == nil && info.ModTime().After(latestTime) {
+			latest, latestTime = path, info.ModTime()
+		}
+	}
+	if latest == "" {
+		return "", "", fmt.Errorf("no session transcripts yet")
+	}
+	return latest, strings.TrimSuffix(filepath.Base(latest), "."+format), nil
+}
+
+// summaryFilename is where the summary of a session transcript is saved.
+func summaryFilename(transcript string) string {
+	return strings.TrimSuffix(transcript, filepath.Ext(transcript)) + ".summary.md"
+}
+
+// summarizeSession writes a narrative summary of a session's transcript
+// (see sessionTranscriptFile for the session picked when name is empty),
+// saves it next to the transcript and posts it to Discord as configured.
+// It returns the summary and the session's name.
+func (a *App) summarizeSession(ctx context.Context, name string) (string, string, error) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	if cfg.SummaryURL == "" {
+		return "", "", fmt.Errorf("no summary API configured")
+	}
+	if !cfg.EnableLocalSave || cfg.Path == "" {
+		return "", "", fmt.Errorf("summaries need file logging for the session transcripts")
+	}
+	flushLogs()
+	path, name, err := a.sessionTranscriptFile(&cfg, strings.TrimSpace(name))
+	if err != nil {
+		return "", "", err
+	}
+	entries, err := readLogFile(path, logFormat(&cfg))
+	if err != nil {
+		return "", name, err
+	}
+	if len(entries) == 0 {
+		return "", name, fmt.Errorf("the transcript of session %q is empty", name)
+	}
+	date, _, _ := strings.Cut(entries[0].Timestamp, " ")
+	prompt, err := summaryPrompt(&cfg, summaryPromptData{Session: name, Date: date, Transcript: summaryTranscript(entries)})
+	if err != nil {
+		return "", name, err
+	}
+	summary, err := requestSummary(ctx, &cfg, prompt)
+	if err != nil {
+		return "", name, err
+	}
+	a.logger.Log("info", fmt.Sprintf("Summarized session %s", name))
+
+	if cfg.SummaryFile {
+		file := summaryFilename(path)
+		if err := os.WriteFile(file, []byte(fmt.Sprintf("# %s\n\n%s\n", name, summary)), 0644); err != nil {
+			a.logger.Log("error", fmt.Sprintf("Saving the summary of session %s failed: %v", name, err))
+		} else {
+			a.logger.Log("info", fmt.Sprintf("Saved the summary of session %s to %s", name, file))
+		}
+	}
+	if cfg.SummaryDiscord && cfg.EnableDiscord {
+		if webhookURL := discordTarget(&cfg); webhookURL != "" {
+			if err := postSessionSummary(ctx, webhookURL, name, summary); err != nil {
+				a.logger.Log("error", fmt.Sprintf("Posting the summary of session %s to Discord failed: %v", name, err))
+			} else {
+				a.logger.Log("info", fmt.Sprintf("Posted the summary of session %s to Discord", name))
+			}
+		}
+	}
+	return summary, name, nil
+}
+
+// postSessionSummary posts a summary to Discord, attaching it as a file
+// when it is too long for one post.
+func postSessionSummary(ctx context.Context, webhookURL, name, summary string) error {
+	heading := fmt.Sprintf("Summary of session **%s**", escapeDiscordMarkdown(name))
+	if content := heading + "\n\n" + summary; len(content) <= discordMessageLimit {
+		return sendDiscordNotice(ctx, webhookURL, content)
+	}
+	file := discordFile{name: "summary.md", data: []byte(summary)}
+	_, _, err := postDiscordFiles(ctx, webhookURL, DiscordAuthor{}, nil, heading, []discordFile{file})
+	return err
+}
+
+// summarizeEndedSession summarizes a session that just ended, in the
+// background, when SummaryOnSessionEnd is set.
+func (a *App) summarizeEndedSession(session Session) {
+	a.configMu.RLock()
+	enabled := a.config.SummaryOnSessionEnd && a.config.SummaryURL != ""
+	a.configMu.RUnlock()
+	if !enabled {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if _, _, err := a.summarizeSession(ctx, session.Name); err != nil {
+			a.logger.Log("error", fmt.Sprintf("Summarizing session %s failed: %v", session.Name, err))
+		}
+	}()
+}
+
+// handleSessionSummary summarizes a session on demand and shows the
+// summary.
+func (a *App) handleSessionSummary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	summary, name, err := a.summarizeSession(r.Context(), r.FormValue("name"))
+	if err != nil {
+		fmt.Fprintf(w, `<div class="alert error">Summary failed: %s</div>`, html.EscapeString(err.Error()))
+		return
+	}
+	fmt.Fprintf(w, `<div class="alert success">Summary of session %s</div><div class="session-summary">%s</div>`,
+		html.EscapeString(name), html.EscapeString(summary))
+}
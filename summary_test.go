@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummaryEndpoint(t *testing.T) {
+	tests := []struct{ url, want string }{
+		{"https://api.openai.com/v1", "https://api.openai.com/v1/chat/completions"},
+		{"http://localhost:11434/v1/", "http://localhost:11434/v1/chat/completions"},
+		{"https://example.com/v1/chat/completions", "https://example.com/v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		if got := summaryEndpoint(&AppConfig{SummaryURL: tt.url}); got != tt.want {
+			t.Errorf("summaryEndpoint(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSummaryTranscript(t *testing.T) {
+	entries := []LogEntry{
+		{Timestamp: "2026-01-02 20:00:00", Sender: "Conan", Message: "We ride at dawn."},
+		{Timestamp: "2026-01-02 20:01:00", Sender: "Valeria", Message: "draws her sword", Kind: kindEmote},
+	}
+	got := summaryTranscript(entries)
+	want := "[2026-01-02 20:00:00] Conan: We ride at dawn.\n[2026-01-02 20:01:00] * Valeria draws her sword\n"
+	if got != want {
+		t.Errorf("summaryTranscript = %q, want %q", got, want)
+	}
+
+	long := make([]LogEntry, maxSummaryTranscript/40)
+	for i := range long {
+		long[i] = LogEntry{Timestamp: "2026-01-02 20:00:00", Sender: "Conan", Message: strings.Repeat("x", 40)}
+	}
+	long[len(long)-1].Message = "the end"
+	got = summaryTranscript(long)
+	if len(got) > maxSummaryTranscript+len("(earlier lines left out)\n") || !strings.HasPrefix(got, "(earlier lines left out)\n[") || !strings.HasSuffix(got, "Conan: the end\n") {
+		t.Errorf("long transcript not cut at a line start: %d bytes, %q...", len(got), got[:60])
+	}
+}
+
+func TestSummarizeSession(t *testing.T) {
+	var prompt string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("request to %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Model != "test-model" || len(body.Messages) != 1 {
+			t.Errorf("body = %+v", body)
+		} else {
+			prompt = body.Messages[0].Content
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"The heroes set out for the tower."}}]}`))
+	}))
+	defer api.Close()
+	bot := newFakeDiscordBot(t)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	dir := t.TempDir()
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+	a.config.EnableDiscord = true
+	a.config.DiscordMode = discordModeBot
+	a.config.BotToken = "secret"
+	a.config.BotChannelID = "100"
+	a.config.SummaryURL = api.URL + "/v1"
+	a.config.SummaryAPIKey = "sk-test"
+	a.config.SummaryModel = "test-model"
+	a.config.SummaryPrompt = "Summarize {{.Session}}:\n{{.Transcript}}"
+	a.config.SummaryDiscord = true
+	a.config.SummaryFile = true
+
+	if _, err := a.StartSession("The Tower"); err != nil {
+		t.Fatal(err)
+	}
+	a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "To the tower!"})
+	waitForDeliveries(t, a)
+
+	summary, name, err := a.summarizeSession(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "The heroes set out for the tower." || name != "The Tower" {
+		t.Errorf("summarizeSession = %q, %q", summary, name)
+	}
+	if !strings.HasPrefix(prompt, "Summarize The Tower:\n[") || !strings.Contains(prompt, "Conan: To the tower!") {
+		t.Errorf("prompt = %q", prompt)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sessions", "The Tower.summary.md"))
+	if err != nil || string(data) != "# The Tower\n\nThe heroes set out for the tower.\n" {
+		t.Errorf("summary file = %q, %v", data, err)
+	}
+	posts := bot.contents("100")
+	if len(posts) == 0 || !strings.Contains(posts[len(posts)-1], "The heroes set out for the tower.") {
+		t.Errorf("Discord got %q", posts)
+	}
+}
+
+func TestHandleSessionSummary_NotConfigured(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	req := httptest.NewRequest("POST", "/api/session/summary", strings.NewReader(url.Values{"name": {"x"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	a.handleSessionSummary(rec, req)
+	if !strings.Contains(rec.Body.String(), "no summary API configured") {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
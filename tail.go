@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultTailPattern matches chat lines in the Conan Exiles server log, e.g.
+// "...ChatWindow: Character Conan (uid 1, player 2) said: Hello".
+const defaultTailPattern = `ChatWindow: Character (?P<sender>.+?)(?: \([^)]*\))? said: (?P<message>.*)$`
+
+// tailPollInterval is how often the tailed log is checked for new lines.
+const tailPollInterval = time.Second
+
+// logTailer follows a growing log file, starting at its current end, and
+// survives truncation and replacement (log rotation on server restart).
+type logTailer struct {
+	path    string
+	file    *os.File
+	offset  int64
+	partial []byte
+}
+
+// open opens the file and positions the tailer. When fromStart is false,
+// existing content is skipped.
+func (t *logTailer) open(fromStart bool) error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	t.offset = 0
+	if !fromStart {
+		if t.offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			return fmt.Errorf("seeking to end of %s: %w", t.path, err)
+		}
+	}
+	t.file = file
+	t.partial = nil
+	return nil
+}
+
+// close releases the tailed file.
+func (t *logTailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// readLines returns the complete lines appended since the last call.
+func (t *logTailer) readLines() ([]string, error) {
+	if t.file == nil {
+		// The file didn't exist yet (or was rotated away); a file that
+		// appears later is new, so read it from the start.
+		if err := t.open(true); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+
+	info, err := os.Stat(t.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	current, err := t.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !os.SameFile(info, current) || info.Size() < t.offset {
+		// Replaced or truncated: start over with the new content.
+		t.close()
+		if err := t.open(true); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(t.file, t.offset, 1<<62))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", t.path, err)
+	}
+	t.offset += int64(len(data))
+
+	data = append(t.partial, data...)
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		t.partial = data
+		return nil, nil
+	}
+	t.partial = append([]byte(nil), data[end+1:]...)
+
+	var lines []string
+	for _, line := range strings.Split(string(data[:end]), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// startLogTail follows the game's log file and processes chat lines that
+// match the pattern. Call with ingestionMu held.
+func (a *App) startLogTail(path, pattern string) error {
+	re, err := compileLinePattern(pattern, "")
+	if err != nil {
+		return err
+	}
+	tailer := &logTailer{path: path}
+	if err := tailer.open(false); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+
+	stop := make(chan struct{})
+	a.tailStop = stop
+
+	a.ingestionWg.Add(1)
+	go func() {
+		defer a.ingestionWg.Done()
+		defer tailer.close()
+		a.logger.Log("info", fmt.Sprintf("Following game log %s", path))
+		a.runLogTail(tailer, re, stop)
+	}()
+	return nil
+}
+
+// runLogTail polls the tailer until stop is closed.
+func (a *App) runLogTail(tailer *logTailer, re *regexp.Regexp, stop <-chan struct{}) {
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		lines, err := tailer.readLines()
+		if err != nil {
+			if err.Error() != lastErr {
+				a.logger.Log("error", fmt.Sprintf("Reading game log failed: %v", err))
+				lastErr = err.Error()
+			}
+			continue
+		}
+		lastErr = ""
+
+		for _, line := range lines {
+			if in, ok := parseLine(re, line); ok {
+				a.processMessage(context.Background(), in)
+			}
+		}
+	}
+}
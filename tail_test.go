@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultTailPattern(t *testing.T) {
+	re, err := compileLinePattern("", defaultTailPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line    string
+		sender  string
+		message string
+		ok      bool
+	}{
+		{"[2026.10.17-21.00.00:123][ 42]ChatWindow: Character Conan (uid 12, player 34) said: By Crom!", "Conan", "By Crom!", true},
+		{"[2026.10.17-21.00.00:123][ 42]ChatWindow: Character Valeria said: Hello", "Valeria", "Hello", true},
+		{"[2026.10.17-21.00.00:123][ 42]LogNet: Join succeeded: Conan", "", "", false},
+	}
+	for _, tt := range tests {
+		in, ok := parseLine(re, tt.line)
+		if ok != tt.ok || in.Sender != tt.sender || in.Message != tt.message {
+			t.Errorf("parseLine(%q) = %+v, %v", tt.line, in, ok)
+		}
+	}
+}
+
+func TestLogTailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ConanSandbox.log")
+	write := func(flag int, data string) {
+		t.Helper()
+		f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	expect := func(tailer *logTailer, want ...string) {
+		t.Helper()
+		got, err := tailer.readLines()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(want) == 0 {
+			want = nil
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+
+	write(os.O_TRUNC, "old line\n")
+	tailer := &logTailer{path: path}
+	if err := tailer.open(false); err != nil {
+		t.Fatal(err)
+	}
+	defer tailer.close()
+
+	expect(tailer)
+
+	write(os.O_APPEND, "first\r\nsecond\npart")
+	expect(tailer, "first", "second")
+
+	write(os.O_APPEND, "ial\n")
+	expect(tailer, "partial")
+
+	// Truncated in place, as happens when the server restarts.
+	write(os.O_TRUNC, "after restart\n")
+	expect(tailer, "after restart")
+
+	// Replaced by a new file.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	expect(tailer)
+	write(os.O_TRUNC, "new file\n")
+	expect(tailer, "new file")
+}
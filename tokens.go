@@ -0,0 +1,408 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tokensFile (next to the config file) holds the API tokens, hashed.
+	tokensFile = "api-tokens.json"
+	// tokenPrefix starts every API token, so they are easy to spot.
+	tokenPrefix = "rpcl_"
+	// maxTokenName bounds the label given to a token.
+	maxTokenName = 64
+	// tokenCookie keeps the admin token a browser signed in to the web UI
+	// with, while RequireAPIToken is set.
+	tokenCookie = "rpcl_token"
+)
+
+// API token scopes. An admin token may do anything; the others only what
+// their scope names.
+const (
+	scopeIngest = "ingest" // send messages to the ingestion server
+	scopeRead   = "read"   // read logs, failures, messages and status
+	scopeAdmin  = "admin"
+)
+
+// tokenScopes lists the scopes in the order the web UI offers them.
+var tokenScopes = []string{scopeIngest, scopeRead, scopeAdmin}
+
+// APIToken is an issued API token. Only a hash of the secret is kept; the
+// secret itself is shown once, when the token is created.
+type APIToken struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Scope   string    `json:"scope"`
+	Hash    string    `json:"hash"`
+	Created time.Time `json:"created"`
+}
+
+// allows reports whether the token may be used for scope.
+func (t APIToken) allows(scope string) bool {
+	return t.Scope == scopeAdmin || t.Scope == scope
+}
+
+// tokenStore keeps the issued API tokens, loading the file on first use.
+// A nil store has no tokens.
+type tokenStore struct {
+	mu     sync.Mutex
+	path   string
+	loaded bool
+	tokens []APIToken
+	// selfSecret is an admin token valid until exit, for the app's own
+	// requests such as the ingestion self-test.
+	selfSecret string
+}
+
+func newTokenStore(path string) *tokenStore {
+	return &tokenStore{path: path, selfSecret: newTokenSecret()}
+}
+
+// newTokenSecret returns a random token secret.
+func newTokenSecret() string {
+	var b [24]byte
+	rand.Read(b[:])
+	return tokenPrefix + hex.EncodeToString(b[:])
+}
+
+// self returns the app's own token secret, or "" for a nil store.
+func (s *tokenStore) self() string {
+	if s == nil {
+		return ""
+	}
+	return s.selfSecret
+}
+
+// hashToken returns the hex SHA-256 of a token secret. Secrets are random,
+// so a plain hash is enough.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// load reads the tokens file once. Callers hold s.mu.
+func (s *tokenStore) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &s.tokens)
+	}
+	if err != nil {
+		slog.Error("Failed to read API tokens", "err", err)
+		s.tokens = nil
+	}
+}
+
+// save writes the store to its file. Callers hold s.mu.
+func (s *tokenStore) save() error {
+	data, err := json.MarshalIndent(s.tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding API tokens: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("creating API tokens directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("writing API tokens: %w", err)
+	}
+	return nil
+}
+
+// list returns the issued tokens, oldest first.
+func (s *tokenStore) list() []APIToken {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	return append([]APIToken(nil), s.tokens...)
+}
+
+// create issues a token and returns it with its secret.
+func (s *tokenStore) create(name, scope string) (APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxTokenName {
+		return APIToken{}, "", fmt.Errorf("Token name must be 1 to %d characters", maxTokenName)
+	}
+	valid := false
+	for _, s := range tokenScopes {
+		valid = valid || s == scope
+	}
+	if !valid {
+		return APIToken{}, "", fmt.Errorf("Unknown token scope %q", scope)
+	}
+	if s == nil {
+		return APIToken{}, "", errors.New("API tokens are not available")
+	}
+
+	secret := newTokenSecret()
+	token := APIToken{ID: newMessageID(), Name: name, Scope: scope, Hash: hashToken(secret), Created: time.Now()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	s.tokens = append(s.tokens, token)
+	if err := s.save(); err != nil {
+		s.tokens = s.tokens[:len(s.tokens)-1]
+		return APIToken{}, "", err
+	}
+	return token, secret, nil
+}
+
+// revoke deletes a token, reporting whether it existed.
+func (s *tokenStore) revoke(id string) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	for i, t := range s.tokens {
+		if t.ID == id {
+			s.tokens = append(s.tokens[:i:i], s.tokens[i+1:]...)
+			return true, s.save()
+		}
+	}
+	return false, nil
+}
+
+// hasAdmin reports whether an admin token has been issued, so requiring
+// tokens can't lock everyone out of the web UI.
+func (s *tokenStore) hasAdmin() bool {
+	for _, t := range s.list() {
+		if t.Scope == scopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns the token with the given secret.
+func (s *tokenStore) authenticate(secret string) (APIToken, bool) {
+	if s == nil || !strings.HasPrefix(secret, tokenPrefix) {
+		return APIToken{}, false
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(s.selfSecret)) == 1 {
+		return APIToken{Name: "self-test", Scope: scopeAdmin}, true
+	}
+	hash := []byte(hashToken(secret))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) == 1 {
+			return t, true
+		}
+	}
+	return APIToken{}, false
+}
+
+// requestToken returns the API token sent with a request: as a bearer
+// token, in the X-API-Token header, as a ?token= parameter for clients
+// that can't set headers, or in the web UI's sign-in cookie.
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	if token := r.Header.Get("X-API-Token"); token != "" {
+		return token
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	if c, err := r.Cookie(tokenCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// withToken requires a token allowing scope for requests to next while
+// required returns true for the current config. The token parameter is
+// dropped from the URL so it doesn't end up in logs.
+func (a *App) withToken(scope string, required func(*AppConfig) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.configMu.RLock()
+		enforce := required(a.config)
+		a.configMu.RUnlock()
+		if !enforce {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := a.tokens.authenticate(requestToken(r))
+		if !ok {
+			a.logger.Log("debug", fmt.Sprintf("Rejected %s %s from %s: missing or invalid API token", r.Method, r.URL.Path, r.RemoteAddr))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="rp-chat-logger"`)
+			writeJSONError(w, http.StatusUnauthorized, "Missing or invalid API token")
+			return
+		}
+		if !token.allows(scope) {
+			a.logger.Log("debug", fmt.Sprintf("Rejected %s %s from %s: token %q lacks the %s scope", r.Method, r.URL.Path, r.RemoteAddr, token.Name, scope))
+			writeJSONError(w, http.StatusForbidden, fmt.Sprintf("Token lacks the %s scope", scope))
+			return
+		}
+
+		if query := r.URL.Query(); query.Has("token") {
+			query.Del("token")
+			r = r.Clone(r.Context())
+			r.URL.RawQuery = query.Encode()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ingestTokenRequired(c *AppConfig) bool { return c.RequireIngestToken }
+func apiTokenRequired(c *AppConfig) bool    { return c.RequireAPIToken }
+
+// handleCreateToken issues a token from the web UI and returns the token
+// list, with the new secret shown once.
+func (a *App) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+	token, secret, err := a.tokens.create(r.FormValue("name"), r.FormValue("scope"))
+	if err != nil {
+		a.renderTokenList(w, map[string]interface{}{"Error": err.Error()})
+		return
+	}
+	a.logger.Log("info", fmt.Sprintf("API token %q created with the %s scope", token.Name, token.Scope))
+	a.renderTokenList(w, map[string]interface{}{"Created": token, "Secret": secret})
+}
+
+// handleRevokeToken deletes a token and returns the updated list.
+func (a *App) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	ok, err := a.tokens.revoke(r.PathValue("id"))
+	switch {
+	case err != nil:
+		a.logger.Log("error", fmt.Sprintf("Failed to revoke API token: %v", err))
+		a.renderTokenList(w, map[string]interface{}{"Error": "Failed to revoke token"})
+	case !ok:
+		a.renderTokenList(w, map[string]interface{}{"Error": "Token not found"})
+	default:
+		a.logger.Log("info", "API token revoked")
+		a.renderTokenList(w, map[string]interface{}{"Message": "Token revoked"})
+	}
+}
+
+func (a *App) renderTokenList(w http.ResponseWriter, data map[string]interface{}) {
+	tmpl, err := a.parseTemplates("templates/partials/token_list.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data["Tokens"] = a.tokens.list()
+	if err := tmpl.ExecuteTemplate(w, "token-list", data); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// webUIOpenPath reports whether path stays reachable without signing in:
+// the sign-in page, static files, health checks and /api/v1, which checks
+// tokens per route.
+func webUIOpenPath(path string) bool {
+	switch path {
+	case "/login", "/logout", "/healthz", "/readyz":
+		return true
+	}
+	return strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/api/v1/")
+}
+
+// withWebUIToken puts the web UI pages and their HTMX endpoints behind an
+// admin token while RequireAPIToken is set. Browsers sign in once at
+// /login, which keeps the token in a cookie; scripts may send it like for
+// /api/v1. Pages redirect to /login, HTMX requests are told to go there.
+func (a *App) withWebUIToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.configMu.RLock()
+		enforce := a.config.RequireAPIToken
+		a.configMu.RUnlock()
+		if !enforce || webUIOpenPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := a.tokens.authenticate(requestToken(r))
+		if ok && token.allows(scopeAdmin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		a.logger.Log("debug", fmt.Sprintf("Rejected %s %s from %s: not signed in to the web UI", r.Method, r.URL.Path, r.RemoteAddr))
+		switch {
+		case r.Header.Get("HX-Request") != "":
+			w.Header().Set("HX-Redirect", "/login")
+			writeJSONError(w, http.StatusUnauthorized, "Sign in with an admin API token")
+		case r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/"):
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		default:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="rp-chat-logger"`)
+			writeJSONError(w, http.StatusUnauthorized, "Missing or invalid API token")
+		}
+	})
+}
+
+// handleLoginPage renders the web UI's sign-in form.
+func (a *App) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	a.renderLogin(w, r, http.StatusOK, "")
+}
+
+// handleLogin checks an admin token and keeps it in a cookie, so the
+// browser may use the web UI while RequireAPIToken is set.
+func (a *App) handleLogin(w http.ResponseWriter, r *http.Request) {
+	secret := strings.TrimSpace(r.FormValue("token"))
+	token, ok := a.tokens.authenticate(secret)
+	if !ok || !token.allows(scopeAdmin) {
+		a.logger.Log("info", fmt.Sprintf("Web UI sign-in from %s rejected", r.RemoteAddr))
+		a.renderLogin(w, r, http.StatusUnauthorized, "That is not an admin API token")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     tokenCookie,
+		Value:    secret,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	a.logger.Log("info", fmt.Sprintf("Web UI signed in from %s with token %q", r.RemoteAddr, token.Name))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// handleLogout forgets the browser's sign-in cookie.
+func (a *App) handleLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: tokenCookie, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (a *App) renderLogin(w http.ResponseWriter, r *http.Request, status int, errMsg string) {
+	tmpl, err := a.parseTemplates("templates/layout.html", "templates/login.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Version": Version,
+		"Prefs":   a.uiPreferences(w, r),
+		"Error":   errMsg,
+	}
+	w.WriteHeader(status)
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
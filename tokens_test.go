@@ -0,0 +1,219 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), tokensFile)
+	store := newTokenStore(path)
+
+	if _, _, err := store.create(" ", scopeRead); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+	if _, _, err := store.create("mod", "root"); err == nil {
+		t.Error("expected an error for an unknown scope")
+	}
+
+	token, secret, err := store.create("game mod", scopeIngest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, tokenPrefix) || token.Hash == "" {
+		t.Errorf("unexpected token %+v with secret %q", token, secret)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Error("the token secret must not be stored")
+	}
+
+	// A fresh store reads the tokens back from the file.
+	reloaded := newTokenStore(path)
+	if got, ok := reloaded.authenticate(secret); !ok || got.Name != "game mod" {
+		t.Errorf("expected the token to authenticate, got %+v, %v", got, ok)
+	}
+	if _, ok := reloaded.authenticate(secret + "0"); ok {
+		t.Error("a wrong secret must not authenticate")
+	}
+	if _, ok := reloaded.authenticate(store.self()); ok {
+		t.Error("another store's self token must not authenticate")
+	}
+	if got, ok := store.authenticate(store.self()); !ok || got.Scope != scopeAdmin {
+		t.Errorf("expected the self token to be admin, got %+v, %v", got, ok)
+	}
+
+	if ok, err := reloaded.revoke(token.ID); !ok || err != nil {
+		t.Fatalf("revoke: %v, %v", ok, err)
+	}
+	if ok, _ := reloaded.revoke(token.ID); ok {
+		t.Error("revoking twice should report a missing token")
+	}
+	if _, ok := newTokenStore(path).authenticate(secret); ok {
+		t.Error("a revoked token must not authenticate")
+	}
+}
+
+func TestWithToken(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.tokens = newTokenStore(filepath.Join(t.TempDir(), tokensFile))
+	_, ingest, _ := a.tokens.create("mod", scopeIngest)
+	_, admin, _ := a.tokens.create("ops", scopeAdmin)
+
+	var gotQuery string
+	handler := a.withToken(scopeIngest, ingestTokenRequired, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+	}))
+
+	tests := []struct {
+		name       string
+		required   bool
+		target     string
+		header     map[string]string
+		wantStatus int
+		wantQuery  string
+	}{
+		{"not required", false, "/message?sender=a", nil, http.StatusOK, "sender=a"},
+		{"missing", true, "/message", nil, http.StatusUnauthorized, ""},
+		{"invalid", true, "/message", map[string]string{"Authorization": "Bearer rpcl_nope"}, http.StatusUnauthorized, ""},
+		{"bearer", true, "/message", map[string]string{"Authorization": "Bearer " + ingest}, http.StatusOK, ""},
+		{"header", true, "/message", map[string]string{"X-API-Token": admin}, http.StatusOK, ""},
+		{"query is stripped", true, "/message?sender=a&token=" + ingest, nil, http.StatusOK, "sender=a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.config.RequireIngestToken = tt.required
+			gotQuery = ""
+			req := httptest.NewRequest("POST", tt.target, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if gotQuery != tt.wantQuery {
+				t.Errorf("expected query %q, got %q", tt.wantQuery, gotQuery)
+			}
+		})
+	}
+}
+
+func TestAPIv1RequiresToken(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.tokens = newTokenStore(filepath.Join(t.TempDir(), tokensFile))
+	a.config.RequireAPIToken = true
+	_, read, _ := a.tokens.create("dashboard", scopeRead)
+	_, ingest, _ := a.tokens.create("mod", scopeIngest)
+	mux := apiTestServer(a)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"no token", "GET", "/api/v1/failures", "", http.StatusUnauthorized},
+		{"ingest token can't read", "GET", "/api/v1/failures", ingest, http.StatusForbidden},
+		{"read token reads", "GET", "/api/v1/failures", read, http.StatusOK},
+		{"read token can't change", "DELETE", "/api/v1/failures", read, http.StatusForbidden},
+		{"spec stays open", "GET", openAPIPath, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestWebUIRequiresToken(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.tokens = newTokenStore(filepath.Join(t.TempDir(), tokensFile))
+	a.config.RequireAPIToken = true
+	_, read, _ := a.tokens.create("dashboard", scopeRead)
+	_, admin, _ := a.tokens.create("ops", scopeAdmin)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("POST /api/tokens", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("POST /login", a.handleLogin)
+	handler := a.withWebUIToken(mux)
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		header       map[string]string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"page redirects", "GET", "/", nil, http.StatusSeeOther, "/login"},
+		{"htmx is sent to sign in", "POST", "/api/tokens", map[string]string{"HX-Request": "true"}, http.StatusUnauthorized, ""},
+		{"script without token", "POST", "/api/tokens", nil, http.StatusUnauthorized, ""},
+		{"read token isn't enough", "POST", "/api/tokens", map[string]string{"Authorization": "Bearer " + read}, http.StatusUnauthorized, ""},
+		{"admin cookie", "POST", "/api/tokens", map[string]string{"Cookie": tokenCookie + "=" + admin}, http.StatusOK, ""},
+		{"admin header", "GET", "/", map[string]string{"X-API-Token": admin}, http.StatusOK, ""},
+		{"health stays open", "GET", "/healthz", nil, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("expected redirect to %q, got %q", tt.wantLocation, got)
+			}
+		})
+	}
+
+	t.Run("sign in", func(t *testing.T) {
+		for _, tc := range []struct {
+			token      string
+			wantStatus int
+		}{{read, http.StatusUnauthorized}, {admin, http.StatusSeeOther}} {
+			req := httptest.NewRequest("POST", "/login", strings.NewReader("token="+tc.token))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("sign in: expected %d, got %d", tc.wantStatus, rec.Code)
+			}
+			var cookie *http.Cookie
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == tokenCookie {
+					cookie = c
+				}
+			}
+			if signedIn := cookie != nil && cookie.Value == admin; signedIn != (tc.wantStatus == http.StatusSeeOther) {
+				t.Errorf("sign in with status %d set cookie %v", rec.Code, cookie)
+			}
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	// traceHeader carries a message's trace ID, both ways: a sender may
+	// supply its own, every ingestion response returns the one used, and
+	// forwarded messages keep it on the next instance.
+	traceHeader = "X-Trace-ID"
+	// maxTraceID bounds a sender-supplied trace ID.
+	maxTraceID = 64
+)
+
+// traceContextKey is the context key holding the trace ID of the message
+// being processed.
+type traceContextKey struct{}
+
+// withTrace returns a context tagged with the given trace ID.
+func withTrace(ctx context.Context, trace string) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, trace)
+}
+
+// traceFrom returns the trace ID ctx is tagged with, or "".
+func traceFrom(ctx context.Context) string {
+	trace, _ := ctx.Value(traceContextKey{}).(string)
+	return trace
+}
+
+// requestTrace returns the trace ID for an ingestion request: the one in
+// the X-Trace-ID header when it is valid, otherwise a new one.
+func requestTrace(r *http.Request) string {
+	if trace := r.Header.Get(traceHeader); validTraceID(trace) {
+		return trace
+	}
+	return newMessageID()
+}
+
+// validTraceID reports whether s is usable as a trace ID: 1 to maxTraceID
+// letters, digits, dashes, underscores or dots, so it can't forge log lines.
+func validTraceID(s string) bool {
+	if s == "" || len(s) > maxTraceID {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// traced prefixes a log line with a trace ID, when there is one.
+func traced(trace, line string) string {
+	if trace == "" {
+		return line
+	}
+	return "[" + trace + "] " + line
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidTraceID(t *testing.T) {
+	tests := []struct {
+		trace string
+		want  bool
+	}{
+		{"", false},
+		{"0123456789abcdef", true},
+		{"game-42_retry.1", true},
+		{strings.Repeat("a", maxTraceID), true},
+		{strings.Repeat("a", maxTraceID+1), false},
+		{"two words", false},
+		{"line\nbreak", false},
+		{"[forged]", false},
+	}
+	for _, tt := range tests {
+		if got := validTraceID(tt.trace); got != tt.want {
+			t.Errorf("validTraceID(%q) = %v, want %v", tt.trace, got, tt.want)
+		}
+	}
+}
+
+func TestCreateHandler_Trace(t *testing.T) {
+	var forwarded string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(traceHeader)
+	}))
+	defer srv.Close()
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.logger.SetLogLevel("debug")
+	a.receipts = newReceiptTable(10)
+	a.config.EnableForward = true
+	a.config.ForwardURL = srv.URL + "/message"
+	// A file where the log directory should be makes the file write fail.
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(blocked, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	a.config.EnableLocalSave = true
+	a.config.Path = filepath.Join(blocked, "logs")
+
+	req := httptest.NewRequest("POST", "/message?sender=Alice&message=hi", nil)
+	req.Header.Set(traceHeader, "game-42")
+	rr := httptest.NewRecorder()
+	createHandler(a).ServeHTTP(rr, req)
+	waitForDeliveries(t, a)
+
+	var response map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response["trace"] != "game-42" || rr.Header().Get(traceHeader) != "game-42" {
+		t.Errorf("expected the sender's trace ID back, got %v and header %q", response, rr.Header().Get(traceHeader))
+	}
+	if forwarded != "game-42" {
+		t.Errorf("expected the trace ID to be forwarded, got %q", forwarded)
+	}
+	if failures := a.logger.GetFailures(); len(failures) != 1 || failures[0].Trace != "game-42" {
+		t.Errorf("expected one failure with the trace ID, got %+v", failures)
+	}
+	if receipt, ok := a.receipts.get(response["id"]); !ok || receipt.Trace != "game-42" {
+		t.Errorf("expected the receipt to carry the trace ID, got %+v", receipt)
+	}
+	if !strings.Contains(a.logger.GetHistoryText(), "[game-42] Parsed:") {
+		t.Error("expected debug logs to carry the trace ID")
+	}
+
+	// Without a usable trace ID from the sender, one is assigned.
+	req = httptest.NewRequest("POST", "/message?sender=Alice&message=hi", nil)
+	req.Header.Set(traceHeader, "not valid")
+	rr = httptest.NewRecorder()
+	createHandler(a).ServeHTTP(rr, req)
+	waitForDeliveries(t, a)
+	if trace := rr.Header().Get(traceHeader); !validTraceID(trace) || trace == "not valid" {
+		t.Errorf("expected a new trace ID, got %q", trace)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transform steps, one per line of AppConfig.Transforms.
+const (
+	transformTrim     = "trim"     // trim and squeeze whitespace
+	transformQuotes   = "quotes"   // curly quotes to straight ones
+	transformColors   = "colors"   // strip <color> tags
+	transformCollapse = "collapse" // drop a sender's repeated line
+	transformReplace  = "replace"  // regular expression replacement
+)
+
+// defaultCollapseWindow is how long a repeated line is dropped by a
+// collapse step without a number of seconds.
+const defaultCollapseWindow = 10 * time.Second
+
+// transform is a parsed step of the transform pipeline.
+type transform struct {
+	kind    string
+	re      *regexp.Regexp // replace
+	with    string         // replace
+	window  time.Duration  // collapse
+	display string         // the config line, for errors
+}
+
+var (
+	whitespaceRun = regexp.MustCompile(`\s+`)
+	colorTag      = regexp.MustCompile(`(?i)</?color(?:=[^>]*)?>`)
+	curlyQuotes   = strings.NewReplacer("“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`,
+		"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'")
+)
+
+// parseTransforms parses the transform steps of the config: "trim",
+// "quotes", "colors", "collapse [seconds]" and "replace PATTERN =>
+// REPLACEMENT". Empty lines and lines starting with # are skipped.
+func parseTransforms(lines []string) ([]transform, error) {
+	var steps []transform
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kind, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		step := transform{kind: strings.ToLower(kind), display: line}
+		switch step.kind {
+		case transformTrim, transformQuotes, transformColors:
+			if arg != "" {
+				return nil, fmt.Errorf("Transform %q takes no argument", line)
+			}
+		case transformCollapse:
+			step.window = defaultCollapseWindow
+			if arg != "" {
+				seconds, err := strconv.Atoi(arg)
+				if err != nil || seconds <= 0 {
+					return nil, fmt.Errorf("Transform %q needs a number of seconds", line)
+				}
+				step.window = time.Duration(seconds) * time.Second
+			}
+		case transformReplace:
+			pattern, with, ok := strings.Cut(arg, "=>")
+			pattern = strings.TrimSpace(pattern)
+			if !ok || pattern == "" {
+				return nil, fmt.Errorf("Transform %q should read: replace PATTERN => REPLACEMENT", line)
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Transform %q: %v", line, err)
+			}
+			step.re, step.with = re, strings.TrimSpace(with)
+		default:
+			return nil, fmt.Errorf("Unknown transform %q", kind)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// transformMessage runs a message through the configured steps in order.
+// It returns false when a step drops the message: a collapsed repeat, or
+// nothing left after the other steps.
+func (a *App) transformMessage(cfg *AppConfig, sender, message string, now time.Time) (string, bool) {
+	// The steps were checked when the config was saved.
+	steps, _ := parseTransforms(cfg.Transforms)
+	for _, step := range steps {
+		switch step.kind {
+		case transformTrim:
+			message = strings.TrimSpace(whitespaceRun.ReplaceAllString(message, " "))
+		case transformQuotes:
+			message = curlyQuotes.Replace(message)
+		case transformColors:
+			message = colorTag.ReplaceAllString(message, "")
+		case transformReplace:
+			message = step.re.ReplaceAllString(message, step.with)
+		case transformCollapse:
+			if a.repeats.seen(sender, message, now, step.window) {
+				return message, false
+			}
+		}
+	}
+	return message, strings.TrimSpace(message) != ""
+}
+
+// repeatFilter remembers each sender's last line for collapse steps. The
+// zero value is ready to use.
+type repeatFilter struct {
+	mu   sync.Mutex
+	last map[string]repeatedLine
+}
+
+type repeatedLine struct {
+	message string
+	at      time.Time
+}
+
+// seen reports whether sender sent message within window before now, and
+// records it as their last line. Senders quiet for the window are
+// forgotten.
+func (f *repeatFilter) seen(sender, message string, now time.Time, window time.Duration) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.last == nil {
+		f.last = make(map[string]repeatedLine)
+	}
+	for name, line := range f.last {
+		if now.Sub(line.at) > window {
+			delete(f.last, name)
+		}
+	}
+	prev, ok := f.last[sender]
+	f.last[sender] = repeatedLine{message: message, at: now}
+	return ok && prev.message == message
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTransforms(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		steps   int
+		wantErr bool
+	}{
+		{[]string{"trim", "", "# comment", "QUOTES", "colors"}, 3, false},
+		{[]string{"collapse", "collapse 30"}, 2, false},
+		{[]string{`replace \s+$ => `}, 1, false},
+		{[]string{"collapse soon"}, 0, true},
+		{[]string{"trim now"}, 0, true},
+		{[]string{"replace [ => x"}, 0, true},
+		{[]string{"replace nothing"}, 0, true},
+		{[]string{"shout"}, 0, true},
+	}
+	for _, tt := range tests {
+		steps, err := parseTransforms(tt.lines)
+		if (err != nil) != tt.wantErr || len(steps) != tt.steps {
+			t.Errorf("parseTransforms(%q) = %d steps, %v", tt.lines, len(steps), err)
+		}
+	}
+}
+
+func TestTransformMessage(t *testing.T) {
+	tests := []struct {
+		steps []string
+		in    string
+		want  string
+		keep  bool
+	}{
+		{nil, "  as is  ", "  as is  ", true},
+		{[]string{"trim"}, "  too\t many   spaces ", "too many spaces", true},
+		{[]string{"quotes"}, "“Hello,” she said, ‘friend’", `"Hello," she said, 'friend'`, true},
+		{[]string{"colors"}, "<color=#ff0000>Red</color> <COLOR=red>alert</COLOR>", "Red alert", true},
+		{[]string{`replace \[(\d+)\] => #$1`}, "room [12]", "room #12", true},
+		{[]string{"colors", "trim"}, "<color=red> </color>", "", false},
+		// Order matters: trimming after the replacement removes its space.
+		{[]string{"replace x => ' '", "trim"}, "axb", "a' 'b", true},
+	}
+	for _, tt := range tests {
+		a := setupTestApp()
+		cfg := &AppConfig{Transforms: tt.steps}
+		got, keep := a.transformMessage(cfg, "Conan", tt.in, time.Now())
+		if got != tt.want || keep != tt.keep {
+			t.Errorf("%q on %q = %q, %v; want %q, %v", tt.steps, tt.in, got, keep, tt.want, tt.keep)
+		}
+	}
+}
+
+func TestTransformMessage_Collapse(t *testing.T) {
+	a := setupTestApp()
+	cfg := &AppConfig{Transforms: []string{"trim", "collapse 10"}}
+	now := time.Now()
+	steps := []struct {
+		sender, message string
+		after           time.Duration
+		keep            bool
+	}{
+		{"Conan", "By Crom", 0, true},
+		{"Conan", "By  Crom ", time.Second, false}, // the same once trimmed
+		{"Valeria", "By Crom", 2 * time.Second, true},
+		{"Conan", "By Crom", 5 * time.Second, false},
+		{"Conan", "By Crom", 16 * time.Second, true}, // 11s after the last repeat
+		{"Conan", "Steel", 17 * time.Second, true},
+		{"Conan", "By Crom", 18 * time.Second, true},
+	}
+	for i, s := range steps {
+		if _, keep := a.transformMessage(cfg, s.sender, s.message, now.Add(s.after)); keep != s.keep {
+			t.Errorf("step %d: %s %q kept = %v, want %v", i, s.sender, s.message, keep, s.keep)
+		}
+	}
+}
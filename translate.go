@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Translation providers.
+const (
+	translateDeepL = "deepl"
+	translateLibre = "libretranslate"
+)
+
+const (
+	deeplFreeURL = "https://api-free.deepl.com/v2/translate"
+	deeplProURL  = "https://api.deepl.com/v2/translate"
+	libreURL     = "https://libretranslate.com/translate"
+	// translatedDir is the subfolder of the log folder that holds the
+	// translated logs.
+	translatedDir = "translated"
+)
+
+var translateClient = &http.Client{
+	Timeout: 15 * time.Second,
+}
+
+// translateURL returns the endpoint of cfg's translation provider.
+// DeepL's free API keys end in ":fx" and have an endpoint of their own.
+func translateURL(cfg *AppConfig) string {
+	if cfg.TranslateURL != "" {
+		return cfg.TranslateURL
+	}
+	if cfg.TranslateProvider == translateDeepL {
+		if strings.HasSuffix(cfg.TranslateAPIKey, ":fx") {
+			return deeplFreeURL
+		}
+		return deeplProURL
+	}
+	return libreURL
+}
+
+// translateText translates text into cfg.TranslateTarget. It returns ""
+// when the text already is in that language.
+func translateText(ctx context.Context, cfg *AppConfig, text string) (string, error) {
+	target := strings.ToLower(cfg.TranslateTarget)
+	var body any
+	if cfg.TranslateProvider == translateDeepL {
+		body = map[string]any{"text": []string{text}, "target_lang": strings.ToUpper(target)}
+	} else {
+		body = map[string]string{"q": text, "source": "auto", "target": target, "format": "text", "api_key": cfg.TranslateAPIKey}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", translateURL(cfg), bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("creating translation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rp-chat-logger/"+Version)
+	if cfg.TranslateProvider == translateDeepL {
+		req.Header.Set("Authorization", "DeepL-Auth-Key "+cfg.TranslateAPIKey)
+	}
+
+	resp, err := translateClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sending translation request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("translation provider returned status code %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var translated, source string
+	if cfg.TranslateProvider == translateDeepL {
+		var result struct {
+			Translations []struct {
+				DetectedSourceLanguage string `json:"detected_source_language"`
+				Text                   string `json:"text"`
+			} `json:"translations"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || len(result.Translations) == 0 {
+			return "", fmt.Errorf("parsing translation response: %v", err)
+		}
+		translated, source = result.Translations[0].Text, result.Translations[0].DetectedSourceLanguage
+	} else {
+		var result struct {
+			TranslatedText   string `json:"translatedText"`
+			DetectedLanguage struct {
+				Language string `json:"language"`
+			} `json:"detectedLanguage"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return "", fmt.Errorf("parsing translation response: %w", err)
+		}
+		translated, source = result.TranslatedText, result.DetectedLanguage.Language
+	}
+	// DeepL's targets name variants (EN-GB); the source never does.
+	base, _, _ := strings.Cut(target, "-")
+	if strings.EqualFold(source, base) || strings.EqualFold(strings.TrimSpace(translated), strings.TrimSpace(text)) {
+		return "", nil
+	}
+	return translated, nil
+}
+
+// translation returns a function that translates message the first time
+// it is called, for the sinks that show translations to share one request.
+// It returns "" when translation is off, not needed or failed; failures
+// are logged and the message is delivered untranslated.
+func (a *App) translation(ctx context.Context, cfg *AppConfig, trace, message string) func() string {
+	if cfg.TranslateProvider == "" || !cfg.TranslateDiscord && !cfg.TranslateFile {
+		return func() string { return "" }
+	}
+	if text, ok := detectEmote(cfg, message); ok {
+		message = text
+	}
+	return sync.OnceValue(func() string {
+		base, _, _ := strings.Cut(strings.ToLower(cfg.TranslateTarget), "-")
+		if detectLanguage(message) == base {
+			return ""
+		}
+		translated, err := translateText(ctx, cfg, message)
+		if err != nil {
+			a.logger.Log("warning", traced(trace, fmt.Sprintf("Translation failed: %v", err)))
+			return ""
+		}
+		return translated
+	})
+}
+
+// withTranslation adds a translation under a Discord post, as small text.
+func withTranslation(cfg *AppConfig, content, translated string) string {
+	if translated == "" {
+		return content
+	}
+	return content + "\n-# " + strings.ToUpper(cfg.TranslateTarget) + ": " + discordText(cfg, translated)
+}
+
+// logTranslation writes entry, translated, to the parallel log under the
+// translated subfolder of the log folder. Lines that need no translation,
+// or couldn't be translated, are written as they are, so the log stays
+// complete.
+func logTranslation(logCfg *AppConfig, entry LogEntry, translated string) error {
+	if translated != "" {
+		entry.Message = translated
+	}
+	cfg := *logCfg
+	cfg.Path = filepath.Join(logCfg.Path, translatedDir)
+	if err := logToFile(&cfg, entry); err != nil {
+		return fmt.Errorf("writing translated log: %w", err)
+	}
+	return nil
+}
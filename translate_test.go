@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTranslateText_DeepL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "DeepL-Auth-Key key:fx" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var body struct {
+			Text       []string `json:"text"`
+			TargetLang string   `json:"target_lang"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.TargetLang != "EN" || len(body.Text) != 1 || body.Text[0] != "Hola amigo" {
+			t.Errorf("body = %+v", body)
+		}
+		w.Write([]byte(`{"translations":[{"detected_source_language":"ES","text":"Hello friend"}]}`))
+	}))
+	defer srv.Close()
+
+	cfg := &AppConfig{TranslateProvider: translateDeepL, TranslateURL: srv.URL, TranslateAPIKey: "key:fx", TranslateTarget: "en"}
+	got, err := translateText(context.Background(), cfg, "Hola amigo")
+	if err != nil || got != "Hello friend" {
+		t.Errorf("translateText = %q, %v", got, err)
+	}
+}
+
+func TestTranslateText_LibreTranslate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		switch body["q"] {
+		case "Bonjour":
+			w.Write([]byte(`{"translatedText":"Hello","detectedLanguage":{"language":"fr"}}`))
+		case "Hello":
+			w.Write([]byte(`{"translatedText":"Hello","detectedLanguage":{"language":"en"}}`))
+		default:
+			http.Error(w, `{"error":"bad"}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := &AppConfig{TranslateProvider: translateLibre, TranslateURL: srv.URL, TranslateTarget: "en-GB"}
+	tests := []struct {
+		text, want string
+		wantErr    bool
+	}{
+		{"Bonjour", "Hello", false},
+		{"Hello", "", false},
+		{"???", "", true},
+	}
+	for _, tt := range tests {
+		got, err := translateText(context.Background(), cfg, tt.text)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("translateText(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestTranslateURL(t *testing.T) {
+	tests := []struct {
+		cfg  AppConfig
+		want string
+	}{
+		{AppConfig{TranslateProvider: translateDeepL, TranslateAPIKey: "abc:fx"}, deeplFreeURL},
+		{AppConfig{TranslateProvider: translateDeepL, TranslateAPIKey: "abc"}, deeplProURL},
+		{AppConfig{TranslateProvider: translateLibre}, libreURL},
+		{AppConfig{TranslateProvider: translateLibre, TranslateURL: "http://localhost:5000/translate"}, "http://localhost:5000/translate"},
+	}
+	for _, tt := range tests {
+		if got := translateURL(&tt.cfg); got != tt.want {
+			t.Errorf("translateURL(%+v) = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestProcessMessage_Translation(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"translatedText":"Where is the sword?","detectedLanguage":{"language":"es"}}`))
+	}))
+	defer srv.Close()
+	bot := newFakeDiscordBot(t)
+
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	dir := t.TempDir()
+	a.config.EnableDiscord = true
+	a.config.DiscordMode = discordModeBot
+	a.config.BotToken = "secret"
+	a.config.BotChannelID = "100"
+	a.config.EnableLocalSave = true
+	a.config.Path = dir
+	a.config.TranslateProvider = translateLibre
+	a.config.TranslateURL = srv.URL
+	a.config.TranslateTarget = "en"
+	a.config.TranslateDiscord = true
+	a.config.TranslateFile = true
+
+	a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "¿Dónde está la espada?"})
+	a.processMessage(context.Background(), IncomingMessage{Sender: "Conan", Message: "Hello there, how are you?"})
+	waitForDeliveries(t, a)
+
+	// The English line never reaches the provider, and the Spanish one is
+	// translated once for both sinks.
+	if n := requests.Load(); n != 1 {
+		t.Errorf("provider got %d requests, want 1", n)
+	}
+	got := bot.contents("100")
+	if len(got) != 2 || !strings.Contains(got[0], "\n-# EN: Where is the sword?") || strings.Contains(got[1], "-# EN") {
+		t.Errorf("Discord got %q", got)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, translatedDir, "*"))
+	if len(files) != 1 {
+		t.Fatalf("translated logs = %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "Where is the sword?") || !strings.Contains(string(data), "Hello there, how are you?") {
+		t.Errorf("translated log = %q", data)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net"
+	"strings"
+)
+
+// webUIURL returns the URL a local browser should use to reach the web UI
+// listening on addr. Wildcard hosts are replaced with localhost.
+func webUIURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr + "/"
+	}
+	switch host {
+	case "", "0.0.0.0", "::":
+		host = "localhost"
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return "http://" + host + ":" + port + "/"
+}
+
+// toggleIngestion starts the ingestion server if it is stopped and stops it
+// if it is running, logging the outcome. Used by the tray menu.
+func (a *App) toggleIngestion() {
+	if a.ingestionRunning.Load() {
+		if err := a.StopIngestionServer(); err != nil {
+			a.logger.Log("error", err.Error())
+		}
+		return
+	}
+
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	if err := cfg.validate(); err != nil {
+		a.logger.Log("error", "Cannot start server: "+err.Error())
+		return
+	}
+	if err := a.StartIngestionServer(); err != nil {
+		a.logger.Log("error", err.Error())
+	}
+}
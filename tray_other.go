@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// runTray is only implemented on Windows.
+func runTray(ctx context.Context, a *App) error {
+	return fmt.Errorf("tray mode is not supported on %s", runtime.GOOS)
+}
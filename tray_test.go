@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestWebUIURL(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{"127.0.0.1:8080", "http://127.0.0.1:8080/"},
+		{":8080", "http://localhost:8080/"},
+		{"0.0.0.0:9000", "http://localhost:9000/"},
+		{"[::]:8080", "http://localhost:8080/"},
+		{"[::1]:8080", "http://[::1]:8080/"},
+		{"example.local:8080", "http://example.local:8080/"},
+	}
+	for _, tt := range tests {
+		if got := webUIURL(tt.addr); got != tt.want {
+			t.Errorf("webUIURL(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
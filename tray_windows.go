@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Win32 constants used by the tray icon (winuser.h, shellapi.h).
+const (
+	wmDestroy      = 0x0002
+	wmClose        = 0x0010
+	wmCommand      = 0x0111
+	wmLButtonUp    = 0x0202
+	wmRButtonUp    = 0x0205
+	wmTrayCallback = 0x8001 // WM_APP + 1
+
+	nimAdd    = 0
+	nimDelete = 2
+
+	nifMessage = 0x1
+	nifIcon    = 0x2
+	nifTip     = 0x4
+
+	mfString    = 0x0
+	mfSeparator = 0x800
+
+	tpmRightButton = 0x2
+	tpmNoNotify    = 0x80
+	tpmReturnCmd   = 0x100
+
+	idiApplication = 32512
+)
+
+// Tray menu command IDs.
+const (
+	trayCmdOpen = iota + 1
+	trayCmdToggle
+	trayCmdQuit
+)
+
+var (
+	user32                  = syscall.NewLazyDLL("user32.dll")
+	procRegisterClassExW    = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW     = user32.NewProc("CreateWindowExW")
+	procDefWindowProcW      = user32.NewProc("DefWindowProcW")
+	procDestroyWindow       = user32.NewProc("DestroyWindow")
+	procGetMessageW         = user32.NewProc("GetMessageW")
+	procTranslateMessage    = user32.NewProc("TranslateMessage")
+	procDispatchMessageW    = user32.NewProc("DispatchMessageW")
+	procPostMessageW        = user32.NewProc("PostMessageW")
+	procPostQuitMessage     = user32.NewProc("PostQuitMessage")
+	procCreatePopupMenu     = user32.NewProc("CreatePopupMenu")
+	procAppendMenuW         = user32.NewProc("AppendMenuW")
+	procTrackPopupMenu      = user32.NewProc("TrackPopupMenu")
+	procDestroyMenu         = user32.NewProc("DestroyMenu")
+	procSetForegroundWindow = user32.NewProc("SetForegroundWindow")
+	procGetCursorPos        = user32.NewProc("GetCursorPos")
+	procLoadIconW           = user32.NewProc("LoadIconW")
+
+	shell32              = syscall.NewLazyDLL("shell32.dll")
+	procShellNotifyIconW = shell32.NewProc("Shell_NotifyIconW")
+
+	kernel32             = syscall.NewLazyDLL("kernel32.dll")
+	procGetModuleHandleW = kernel32.NewProc("GetModuleHandleW")
+	procFreeConsole      = kernel32.NewProc("FreeConsole")
+)
+
+// wndClassEx mirrors the Win32 WNDCLASSEXW structure.
+type wndClassEx struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   uintptr
+	Icon       uintptr
+	Cursor     uintptr
+	Background uintptr
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSm     uintptr
+}
+
+// point mirrors the Win32 POINT structure.
+type point struct {
+	X, Y int32
+}
+
+// msg mirrors the Win32 MSG structure.
+type msg struct {
+	Hwnd    uintptr
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      point
+	Private uint32
+}
+
+// notifyIconData mirrors the Win32 NOTIFYICONDATAW structure.
+type notifyIconData struct {
+	Size            uint32
+	Hwnd            uintptr
+	ID              uint32
+	Flags           uint32
+	CallbackMessage uint32
+	Icon            uintptr
+	Tip             [128]uint16
+	State           uint32
+	StateMask       uint32
+	Info            [256]uint16
+	Version         uint32
+	InfoTitle       [64]uint16
+	InfoFlags       uint32
+	GUID            [16]byte
+	BalloonIcon     uintptr
+}
+
+// trayApp is the app the tray window procedure acts on. Window procedures
+// are plain callbacks, so the state lives in a package variable.
+var trayApp *App
+
+// runTray shows a notification-area icon with a menu to open the web UI,
+// start or stop the ingestion server and quit. It detaches from the console
+// so the app keeps running in the background, and returns when Quit is
+// chosen or ctx is cancelled.
+func runTray(ctx context.Context, a *App) error {
+	// The window and its message loop must stay on one OS thread.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	trayApp = a
+	instance, _, _ := procGetModuleHandleW.Call(0)
+	className, _ := syscall.UTF16PtrFromString("RPChatLoggerTray")
+
+	wc := wndClassEx{
+		WndProc:   syscall.NewCallback(trayWndProc),
+		Instance:  instance,
+		ClassName: className,
+	}
+	wc.Size = uint32(unsafe.Sizeof(wc))
+	if r, _, err := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&wc))); r == 0 {
+		return fmt.Errorf("registering tray window class: %w", err)
+	}
+
+	// A hidden top-level window: it never shows, but can own the popup menu.
+	hwnd, _, err := procCreateWindowExW.Call(0, uintptr(unsafe.Pointer(className)), 0, 0,
+		0, 0, 0, 0, 0, 0, instance, 0)
+	if hwnd == 0 {
+		return fmt.Errorf("creating tray window: %w", err)
+	}
+
+	icon, _, _ := procLoadIconW.Call(0, idiApplication)
+	nid := notifyIconData{
+		Hwnd:            hwnd,
+		ID:              1,
+		Flags:           nifMessage | nifIcon | nifTip,
+		CallbackMessage: wmTrayCallback,
+		Icon:            icon,
+	}
+	nid.Size = uint32(unsafe.Sizeof(nid))
+	tip, _ := syscall.UTF16FromString("RP Chat Logger")
+	copy(nid.Tip[:len(nid.Tip)-1], tip)
+	if r, _, err := procShellNotifyIconW.Call(nimAdd, uintptr(unsafe.Pointer(&nid))); r == 0 {
+		procDestroyWindow.Call(hwnd)
+		return fmt.Errorf("adding tray icon: %w", err)
+	}
+	defer procShellNotifyIconW.Call(nimDelete, uintptr(unsafe.Pointer(&nid)))
+
+	procFreeConsole.Call()
+	a.logger.Log("info", "Running in the system tray")
+
+	stop := context.AfterFunc(ctx, func() {
+		procPostMessageW.Call(hwnd, wmClose, 0, 0)
+	})
+	defer stop()
+
+	var m msg
+	for {
+		r, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+		if int32(r) <= 0 {
+			return nil
+		}
+		procTranslateMessage.Call(uintptr(unsafe.Pointer(&m)))
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+	}
+}
+
+// trayWndProc handles messages for the hidden tray window.
+func trayWndProc(hwnd uintptr, message uint32, wParam, lParam uintptr) uintptr {
+	switch message {
+	case wmTrayCallback:
+		switch lParam {
+		case wmLButtonUp:
+			openBrowser(webUIURL(trayApp.webAddr))
+		case wmRButtonUp:
+			showTrayMenu(hwnd)
+		}
+		return 0
+	case wmClose:
+		procDestroyWindow.Call(hwnd)
+		return 0
+	case wmDestroy:
+		procPostQuitMessage.Call(0)
+		return 0
+	}
+	r, _, _ := procDefWindowProcW.Call(hwnd, uintptr(message), wParam, lParam)
+	return r
+}
+
+// showTrayMenu shows the tray menu at the cursor and runs the chosen command.
+func showTrayMenu(hwnd uintptr) {
+	menu, _, _ := procCreatePopupMenu.Call()
+	if menu == 0 {
+		return
+	}
+	defer procDestroyMenu.Call(menu)
+
+	toggle := "Start server"
+	if trayApp.ingestionRunning.Load() {
+		toggle = "Stop server"
+	}
+	appendTrayItem(menu, trayCmdOpen, "Open web UI")
+	appendTrayItem(menu, trayCmdToggle, toggle)
+	procAppendMenuW.Call(menu, mfSeparator, 0, 0)
+	appendTrayItem(menu, trayCmdQuit, "Quit")
+
+	var pt point
+	procGetCursorPos.Call(uintptr(unsafe.Pointer(&pt)))
+	// Required so the menu closes when the user clicks elsewhere.
+	procSetForegroundWindow.Call(hwnd)
+	cmd, _, _ := procTrackPopupMenu.Call(menu, tpmRightButton|tpmNoNotify|tpmReturnCmd,
+		uintptr(pt.X), uintptr(pt.Y), 0, hwnd, 0)
+
+	switch cmd {
+	case trayCmdOpen:
+		openBrowser(webUIURL(trayApp.webAddr))
+	case trayCmdToggle:
+		go trayApp.toggleIngestion()
+	case trayCmdQuit:
+		procDestroyWindow.Call(hwnd)
+	}
+}
+
+// appendTrayItem adds a text item to a popup menu.
+func appendTrayItem(menu uintptr, id int, text string) {
+	label, _ := syscall.UTF16PtrFromString(text)
+	procAppendMenuW.Call(menu, mfString, uintptr(id), uintptr(unsafe.Pointer(label)))
+}
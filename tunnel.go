@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tunnel providers. ngrok and cloudflared are run as installed commands;
+// custom runs TunnelCommand.
+const (
+	tunnelNgrok       = "ngrok"
+	tunnelCloudflared = "cloudflared"
+	tunnelCustom      = "custom"
+)
+
+// tunnelURLPatterns find the public URL in each provider's output.
+var tunnelURLPatterns = map[string]*regexp.Regexp{
+	tunnelNgrok:       regexp.MustCompile(`url=(https://\S+)`),
+	tunnelCloudflared: regexp.MustCompile(`(https://[a-z0-9-]+\.trycloudflare\.com)`),
+	tunnelCustom:      regexp.MustCompile(`(https?://[^\s"'<>]+)`),
+}
+
+// tunnel is a running tunnel process.
+type tunnel struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu  sync.Mutex
+	url string
+}
+
+// tunnelProvider returns the configured provider, defaulting to ngrok.
+func tunnelProvider(config *AppConfig) string {
+	if config.TunnelProvider == "" {
+		return tunnelNgrok
+	}
+	return config.TunnelProvider
+}
+
+// tunnelLocalAddr returns the address the tunnel should connect to for a
+// listen address, using localhost when the listener binds every interface.
+func tunnelLocalAddr(listenAddr string) (string, error) {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", listenAddr, err)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// tunnelCommand returns the command line that exposes the ingestion server.
+// In a custom command, {addr} is replaced with the local host:port and
+// {port} with the port alone.
+func tunnelCommand(config *AppConfig) ([]string, error) {
+	addr, err := tunnelLocalAddr(config.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	switch tunnelProvider(config) {
+	case tunnelNgrok:
+		return []string{"ngrok", "http", addr, "--log", "stdout"}, nil
+	case tunnelCloudflared:
+		return []string{"cloudflared", "tunnel", "--no-autoupdate", "--url", "http://" + addr}, nil
+	case tunnelCustom:
+		_, port, _ := net.SplitHostPort(addr)
+		command := strings.NewReplacer("{addr}", addr, "{port}", port).Replace(config.TunnelCommand)
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("tunnel command required")
+		}
+		return args, nil
+	default:
+		return nil, fmt.Errorf("unknown tunnel provider %q", config.TunnelProvider)
+	}
+}
+
+// startTunnel starts the configured tunnel in the background, replacing
+// any tunnel already running. The public URL becomes available through
+// TunnelURL once the tunnel reports it.
+func (a *App) startTunnel(config *AppConfig) error {
+	args, err := tunnelCommand(config)
+	if err != nil {
+		return err
+	}
+	a.stopTunnel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	if config.TunnelAuthToken != "" && tunnelProvider(config) == tunnelNgrok {
+		// Passed in the environment so it doesn't show in process lists.
+		cmd.Env = append(cmd.Env, "NGROK_AUTHTOKEN="+config.TunnelAuthToken)
+	}
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	// Don't hang on Wait if the tunnel leaves a child holding the output.
+	cmd.WaitDelay = 2 * time.Second
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("starting %s: %w", args[0], err)
+	}
+
+	t := &tunnel{cancel: cancel, done: make(chan struct{})}
+	a.tunnelMu.Lock()
+	a.tunnel = t
+	a.tunnelMu.Unlock()
+
+	pattern := tunnelURLPatterns[tunnelProvider(config)]
+	go func() {
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			slog.Debug("Tunnel output", "line", line)
+			if m := pattern.FindStringSubmatch(line); m != nil && t.publicURL() == "" {
+				t.mu.Lock()
+				t.url = strings.TrimRight(m[1], "/")
+				t.mu.Unlock()
+				a.logger.Log("info", fmt.Sprintf("Tunnel ready: %s/message", t.publicURL()))
+			}
+		}
+		io.Copy(io.Discard, pr)
+	}()
+	go func() {
+		err := cmd.Wait()
+		pw.Close()
+		if ctx.Err() == nil {
+			a.logger.Log("error", fmt.Sprintf("Tunnel exited: %v", err))
+		}
+		t.mu.Lock()
+		t.url = ""
+		t.mu.Unlock()
+		close(t.done)
+	}()
+	a.logger.Log("info", fmt.Sprintf("Starting %s tunnel", tunnelProvider(config)))
+	return nil
+}
+
+// stopTunnel stops the running tunnel, if any, and waits for it to exit.
+func (a *App) stopTunnel() {
+	a.tunnelMu.Lock()
+	t := a.tunnel
+	a.tunnel = nil
+	a.tunnelMu.Unlock()
+	if t == nil {
+		return
+	}
+	t.cancel()
+	<-t.done
+}
+
+// TunnelURL returns the public base URL of the running tunnel, and whether
+// a tunnel is running but hasn't reported its URL yet.
+func (a *App) TunnelURL() (string, bool) {
+	a.tunnelMu.Lock()
+	t := a.tunnel
+	a.tunnelMu.Unlock()
+	if t == nil {
+		return "", false
+	}
+	select {
+	case <-t.done:
+		return "", false
+	default:
+	}
+	url := t.publicURL()
+	return url, url == ""
+}
+
+func (t *tunnel) publicURL() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.url
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTunnelCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  AppConfig
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "ngrok default",
+			config: AppConfig{ListenAddr: "127.0.0.1:3000"},
+			want:   []string{"ngrok", "http", "127.0.0.1:3000", "--log", "stdout"},
+		},
+		{
+			name:   "cloudflared on all interfaces",
+			config: AppConfig{ListenAddr: ":3000", TunnelProvider: tunnelCloudflared},
+			want:   []string{"cloudflared", "tunnel", "--no-autoupdate", "--url", "http://localhost:3000"},
+		},
+		{
+			name:   "custom",
+			config: AppConfig{ListenAddr: "0.0.0.0:3000", TunnelProvider: tunnelCustom, TunnelCommand: "ssh -R 80:{addr} tunnel@example.com -p {port}"},
+			want:   []string{"ssh", "-R", "80:localhost:3000", "tunnel@example.com", "-p", "3000"},
+		},
+		{
+			name:    "custom without command",
+			config:  AppConfig{ListenAddr: "127.0.0.1:3000", TunnelProvider: tunnelCustom},
+			wantErr: true,
+		},
+		{
+			name:    "bad listen address",
+			config:  AppConfig{ListenAddr: "3000"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tunnelCommand(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestTunnelHelperProcess stands in for a tunnel command in
+// TestStartTunnel; it does nothing when run as a normal test.
+func TestTunnelHelperProcess(t *testing.T) {
+	if os.Getenv("RPCL_TUNNEL_HELPER") != "1" {
+		return
+	}
+	os.Stdout.WriteString("connecting...\nforwarding https://abc.tunnel.example/ -> localhost:3000\n")
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+func TestStartTunnel(t *testing.T) {
+	t.Setenv("RPCL_TUNNEL_HELPER", "1")
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	cfg := &AppConfig{
+		ListenAddr:     "127.0.0.1:3000",
+		TunnelProvider: tunnelCustom,
+		TunnelCommand:  os.Args[0] + " -test.run=^TestTunnelHelperProcess$",
+	}
+	if strings.ContainsAny(os.Args[0], " \t") {
+		t.Skip("test binary path contains spaces")
+	}
+	if err := a.startTunnel(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	url, pending := a.TunnelURL()
+	for url == "" && time.Now().Before(deadline) {
+		if !pending {
+			t.Fatal("tunnel exited before reporting a URL")
+		}
+		time.Sleep(20 * time.Millisecond)
+		url, pending = a.TunnelURL()
+	}
+	if url != "https://abc.tunnel.example" {
+		t.Errorf("expected the printed URL, got %q", url)
+	}
+
+	a.stopTunnel()
+	if url, pending := a.TunnelURL(); url != "" || pending {
+		t.Errorf("expected no tunnel after stopping, got %q (pending %v)", url, pending)
+	}
+}
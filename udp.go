@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// defaultUDPPattern matches simple "Sender: message" lines.
+const defaultUDPPattern = `^(?P<sender>[^:]+):\s*(?P<message>.+)$`
+
+// compileLinePattern compiles a line regex, which must capture the
+// "sender" and "message" groups and may capture "scene" and "source".
+// An empty pattern uses fallback.
+func compileLinePattern(pattern, fallback string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = fallback
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid line pattern: %v", err)
+	}
+	if re.SubexpIndex("sender") < 0 || re.SubexpIndex("message") < 0 {
+		return nil, fmt.Errorf("Line pattern must capture (?P<sender>...) and (?P<message>...)")
+	}
+	return re, nil
+}
+
+// parseLine applies a line pattern to a received line. Lines that don't
+// match are skipped.
+func parseLine(re *regexp.Regexp, line string) (IncomingMessage, bool) {
+	match := re.FindStringSubmatch(line)
+	if match == nil {
+		return IncomingMessage{}, false
+	}
+	group := func(name string) string {
+		if i := re.SubexpIndex(name); i >= 0 {
+			return strings.TrimSpace(match[i])
+		}
+		return ""
+	}
+	return IncomingMessage{
+		Sender:  group("sender"),
+		Message: group("message"),
+		Scene:   group("scene"),
+		Source:  group("source"),
+	}, true
+}
+
+// stripSyslogHeader returns the message part of an RFC 5424 syslog line.
+// Lines that only carry a "<PRI>" prefix (RFC 3164 and many embedded
+// senders) lose just that prefix; other lines are returned unchanged.
+func stripSyslogHeader(line string) string {
+	if !strings.HasPrefix(line, "<") {
+		return line
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return line
+	}
+	rest := line[end+1:]
+	if !strings.HasPrefix(rest, "1 ") {
+		return rest
+	}
+
+	// VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID
+	fields := rest
+	for i := 0; i < 6; i++ {
+		_, after, ok := strings.Cut(fields, " ")
+		if !ok {
+			return ""
+		}
+		fields = after
+	}
+	return strings.TrimPrefix(skipStructuredData(fields), "\ufeff")
+}
+
+// skipStructuredData skips the RFC 5424 STRUCTURED-DATA field ("-" or one
+// or more [id param="value"] elements) and the space after it.
+func skipStructuredData(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return strings.TrimPrefix(s[1:], " ")
+	}
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			inQuotes = !inQuotes
+		case ']':
+			if !inQuotes && (i+1 == len(s) || s[i+1] != '[') {
+				return strings.TrimPrefix(s[i+1:], " ")
+			}
+		}
+	}
+	return ""
+}
+
+// startUDPListener receives chat lines over UDP, one or more per datagram,
+// and processes those matching the configured pattern. Call with
+// ingestionMu held.
+func (a *App) startUDPListener(addr, pattern string) error {
+	re, err := compileLinePattern(pattern, defaultUDPPattern)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on udp %s: %w", addr, err)
+	}
+	a.udpConn = conn
+
+	a.ingestionWg.Add(1)
+	go func() {
+		defer a.ingestionWg.Done()
+		a.logger.Log("info", fmt.Sprintf("UDP listener started on %s", addr))
+
+		buf := make([]byte, 64*1024)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					slog.Error("UDP listener failed", "err", err)
+					a.logger.Log("error", fmt.Sprintf("UDP listener failed: %v", err))
+				}
+				return
+			}
+			for _, line := range strings.Split(string(buf[:n]), "\n") {
+				line = strings.TrimSpace(stripSyslogHeader(strings.TrimRight(line, "\r")))
+				if line == "" {
+					continue
+				}
+				in, ok := parseLine(re, line)
+				if !ok {
+					a.logger.Log("debug", fmt.Sprintf("UDP line from %s did not match pattern: %q", from, line))
+					continue
+				}
+				if a.allowMessage(context.Background(), from.String(), in.Sender) {
+					a.processMessage(context.Background(), in)
+				}
+			}
+		}
+	}()
+	return nil
+}
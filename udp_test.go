@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStripSyslogHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain line", "Conan: Hello", "Conan: Hello"},
+		{"rfc5424 without structured data", "<134>1 2026-10-17T21:00:00Z host conan 123 chat - Conan: Hello", "Conan: Hello"},
+		{"rfc5424 with structured data", `<134>1 2026-10-17T21:00:00Z host conan - - [meta a="x]y"][b c="d"] Conan: Hello`, "Conan: Hello"},
+		{"rfc5424 with BOM", "<134>1 - host conan - - - \ufeffConan: Hello", "Conan: Hello"},
+		{"rfc3164 prefix only", "<13>Conan: Hello", "Conan: Hello"},
+		{"not a priority", "<Conan>: Hello", "<Conan>: Hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripSyslogHeader(tt.input); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	re, err := compileLinePattern("", defaultUDPPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, ok := parseLine(re, "Conan of Cimmeria: By Crom!")
+	if !ok || in.Sender != "Conan of Cimmeria" || in.Message != "By Crom!" {
+		t.Errorf("unexpected parse: %+v, %v", in, ok)
+	}
+	if _, ok := parseLine(re, "no separator here"); ok {
+		t.Error("expected line without a sender to be skipped")
+	}
+
+	re, err = compileLinePattern(`^\[(?P<scene>[^\]]+)\] (?P<sender>\S+) says (?P<message>.+)$`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, ok = parseLine(re, "[Tavern] Valeria says Hello")
+	if !ok || in.Scene != "Tavern" || in.Sender != "Valeria" || in.Message != "Hello" {
+		t.Errorf("unexpected parse: %+v, %v", in, ok)
+	}
+
+	for _, pattern := range []string{`(?P<sender>.+`, `^(?P<message>.+)$`} {
+		if _, err := compileLinePattern(pattern, ""); err == nil {
+			t.Errorf("expected error for pattern %q", pattern)
+		}
+	}
+}
+
+func TestUDPListener(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	tmpDir := t.TempDir()
+	a.config.EnableLocalSave = true
+	a.config.Path = tmpDir
+	a.config.FileFormat = "txt"
+
+	a.ingestionMu.Lock()
+	err := a.startUDPListener("127.0.0.1:0", "")
+	addr := a.udpConn.LocalAddr().String()
+	a.ingestionMu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.StopIngestionServer()
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("<134>1 - host conan - - - Conan: Hello\nnot a chat line\nValeria: Hi\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := generateLogFilename(tmpDir, "txt")
+	var entries []LogEntry
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if _, err := os.Stat(filename); err != nil {
+			continue
+		}
+		if entries, err = readLogFile(filename, "txt"); err == nil && len(entries) == 2 {
+			break
+		}
+	}
+	if len(entries) != 2 || entries[0].Sender != "Conan" || entries[1].Message != "Hi" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"os/exec"
@@ -19,14 +18,24 @@ const (
 	githubRepo  = "rp-chat-logger"
 )
 
+// Update channels. Beta also offers prereleases.
+const (
+	updateChannelStable = "stable"
+	updateChannelBeta   = "beta"
+)
+
+// githubAPI is the GitHub API base URL; tests point it elsewhere.
+var githubAPI = "https://api.github.com"
+
 // GitHubRelease represents a GitHub release from the API.
 type GitHubRelease struct {
-	TagName     string        `json:"tag_name"`
-	Name        string        `json:"name"`
-	Prerelease  bool          `json:"prerelease"`
-	Draft       bool          `json:"draft"`
-	PublishedAt string        `json:"published_at"`
-	HTMLURL     string        `json:"html_url"`
+	TagName     string         `json:"tag_name"`
+	Name        string         `json:"name"`
+	Prerelease  bool           `json:"prerelease"`
+	Draft       bool           `json:"draft"`
+	PublishedAt string         `json:"published_at"`
+	HTMLURL     string         `json:"html_url"`
+	Body        string         `json:"body"`
 	Assets      []ReleaseAsset `json:"assets"`
 }
 
@@ -42,17 +51,30 @@ type UpdateInfo struct {
 	Available      bool
 	CurrentVersion string
 	LatestVersion  string
+	Prerelease     bool
 	ReleaseURL     string
+	Notes          string    // release notes, in Markdown
+	PublishedAt    time.Time // zero if unknown
+	AssetSize      int64     // download size in bytes, 0 if unknown
 	DownloadURL    string
 	AssetName      string
+	ChecksumsURL   string // SHA256SUMS of the release, if published
+	SignatureURL   string // signature of SHA256SUMS, if published
 	LastChecked    time.Time
+	Container      bool // running in a container, which updates by image
+	Unsigned       bool // no UpdatePublicKey, so updates are installed by hand
+	// MissingPlatform is the os/arch of this build when LatestVersion is
+	// newer but has no asset for it, so there is no update to install.
+	MissingPlatform string
 }
 
 // Updater handles checking for and applying updates.
 type Updater struct {
-	info   UpdateInfo
-	mu     sync.RWMutex
-	logger *SSELogger
+	info     UpdateInfo
+	mu       sync.RWMutex
+	logger   *SSELogger
+	execPath string // the managed binary; "" means the running one
+	trial    bool   // first start after an update, see BeginTrial
 }
 
 // NewUpdater creates a new Updater instance.
@@ -61,6 +83,8 @@ func NewUpdater(logger *SSELogger) *Updater {
 		logger: logger,
 		info: UpdateInfo{
 			CurrentVersion: Version,
+			Container:      detectContainer(),
+			Unsigned:       UpdatePublicKey == "",
 		},
 	}
 }
@@ -72,8 +96,9 @@ func (u *Updater) GetInfo() UpdateInfo {
 	return u.info
 }
 
-// CheckForUpdate queries GitHub for the latest release and updates the info.
-func (u *Updater) CheckForUpdate() error {
+// CheckForUpdate queries GitHub for the latest release on the given
+// channel and updates the info.
+func (u *Updater) CheckForUpdate(channel string) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
@@ -81,23 +106,12 @@ func (u *Updater) CheckForUpdate() error {
 		u.logger.Log("info", "Checking for updates...")
 	}
 
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", githubOwner, githubRepo)
-
 	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
+	release, err := fetchLatestRelease(client, channel)
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		return err
 	}
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "rp-chat-logger/"+Version)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("fetching release: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
+	if release == nil {
 		// No releases yet
 		u.info.Available = false
 		u.info.LastChecked = time.Now()
@@ -107,45 +121,41 @@ func (u *Updater) CheckForUpdate() error {
 		return nil
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
-	}
-
-	var release GitHubRelease
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return fmt.Errorf("decoding release: %w", err)
-	}
-
-	// Skip prereleases and drafts
-	if release.Prerelease || release.Draft {
-		u.info.Available = false
-		u.info.LastChecked = time.Now()
-		return nil
-	}
-
 	latestVersion := strings.TrimPrefix(release.TagName, "v")
 	u.info.LatestVersion = latestVersion
+	u.info.Prerelease = release.Prerelease
 	u.info.ReleaseURL = release.HTMLURL
+	u.info.Notes = release.Body
+	u.info.PublishedAt, _ = time.Parse(time.RFC3339, release.PublishedAt)
 	u.info.LastChecked = time.Now()
+	u.info.MissingPlatform = ""
 
 	// Compare versions
 	if Version == "dev" || isNewerVersion(latestVersion, Version) {
 		// Find the appropriate asset for this platform
-		assetName := getAssetName()
-		for _, asset := range release.Assets {
-			if asset.Name == assetName {
-				u.info.Available = true
-				u.info.DownloadURL = asset.BrowserDownloadURL
-				u.info.AssetName = asset.Name
-				if u.logger != nil {
-					u.logger.Log("info", fmt.Sprintf("Update available: %s -> %s", Version, latestVersion))
-				}
-				return nil
+		if asset, ok := pickAsset(release.Assets, runtime.GOOS, runtime.GOARCH); ok {
+			u.info.Available = true
+			u.info.DownloadURL = asset.BrowserDownloadURL
+			u.info.AssetName = asset.Name
+			u.info.AssetSize = asset.Size
+			u.info.ChecksumsURL, u.info.SignatureURL = "", ""
+			if sums, ok := findAsset(release.Assets, checksumsAsset); ok {
+				u.info.ChecksumsURL = sums.BrowserDownloadURL
+			}
+			if sig, ok := findAsset(release.Assets, signatureAsset); ok {
+				u.info.SignatureURL = sig.BrowserDownloadURL
+			}
+			if u.logger != nil {
+				u.logger.Log("info", fmt.Sprintf("Update available: %s -> %s", Version, latestVersion))
 			}
+			return nil
 		}
 		// Asset not found for this platform
+		u.info.Available = false
+		u.info.MissingPlatform = runtime.GOOS + "/" + runtime.GOARCH
 		if u.logger != nil {
-			u.logger.Log("info", fmt.Sprintf("Update %s available but no binary for %s/%s", latestVersion, runtime.GOOS, runtime.GOARCH))
+			u.logger.Log("warning", fmt.Sprintf("Version %s is out but has no build for %s (looked for %s); update by hand from %s",
+				latestVersion, u.info.MissingPlatform, strings.Join(platformAssetNames(runtime.GOOS, runtime.GOARCH), ", "), release.HTMLURL))
 		}
 	} else {
 		u.info.Available = false
@@ -157,32 +167,68 @@ func (u *Updater) CheckForUpdate() error {
 	return nil
 }
 
-// getAssetName returns the expected asset name for the current platform.
-func getAssetName() string {
-	if runtime.GOOS == "windows" {
-		return "rp-chat-logger.exe"
+// fetchLatestRelease returns the newest release on channel: the latest
+// stable release, or for beta the newest release including prereleases
+// (by version, skipping tags that aren't one). It returns nil when there
+// is none.
+func fetchLatestRelease(client *http.Client, channel string) (*GitHubRelease, error) {
+	path := "/releases/latest"
+	if channel == updateChannelBeta {
+		path = "/releases?per_page=20"
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s%s", githubAPI, githubOwner, githubRepo, path)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("User-Agent", "rp-chat-logger/"+Version)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching release: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
 	}
-	return "rp-chat-logger"
-}
 
-// isNewerVersion returns true if latest is newer than current.
-// Uses simple string comparison; assumes semver format (e.g., "1.2.3").
-func isNewerVersion(latest, current string) bool {
-	// Strip v prefix if present
-	latest = strings.TrimPrefix(latest, "v")
-	current = strings.TrimPrefix(current, "v")
-
-	latestParts := strings.Split(latest, ".")
-	currentParts := strings.Split(current, ".")
-
-	for i := 0; i < len(latestParts) && i < len(currentParts); i++ {
-		if latestParts[i] > currentParts[i] {
-			return true
-		} else if latestParts[i] < currentParts[i] {
-			return false
+	if channel != updateChannelBeta {
+		var release GitHubRelease
+		if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+			return nil, fmt.Errorf("decoding release: %w", err)
 		}
+		// The latest release is never a prerelease, but drafts can
+		// show up for authorized requests.
+		if release.Prerelease || release.Draft {
+			return nil, nil
+		}
+		return &release, nil
+	}
+
+	var releases []GitHubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("decoding releases: %w", err)
 	}
-	return len(latestParts) > len(currentParts)
+	var newest *GitHubRelease
+	for i, r := range releases {
+		if _, ok := parseVersion(r.TagName); r.Draft || !ok {
+			continue
+		}
+		if newest == nil || isNewerVersion(r.TagName, newest.TagName) {
+			newest = &releases[i]
+		}
+	}
+	return newest, nil
+}
+
+// getAssetName returns the executable's name for the current platform.
+func getAssetName() string {
+	return binaryName(runtime.GOOS)
 }
 
 // PerformUpdate downloads and applies the update, then restarts the application.
@@ -191,50 +237,38 @@ func (u *Updater) PerformUpdate() error {
 	info := u.info
 	u.mu.RUnlock()
 
+	if info.MissingPlatform != "" {
+		return fmt.Errorf("version %s has no build for %s", info.LatestVersion, info.MissingPlatform)
+	}
 	if !info.Available || info.DownloadURL == "" {
 		return fmt.Errorf("no update available")
 	}
+	if info.Container {
+		return fmt.Errorf("running in a container, so the binary isn't replaced: %s", containerUpdateHelp)
+	}
+	if info.Unsigned {
+		return fmt.Errorf("this build can't verify updates, so the binary isn't replaced: %s", unsignedUpdateHelp)
+	}
 
 	if u.logger != nil {
 		u.logger.Log("info", fmt.Sprintf("Downloading update from %s...", info.DownloadURL))
 	}
 
 	// Get the current executable path
-	execPath, err := os.Executable()
-	if err != nil {
-		return fmt.Errorf("getting executable path: %w", err)
-	}
-	execPath, err = filepath.EvalSymlinks(execPath)
+	execPath, err := u.executable()
 	if err != nil {
-		return fmt.Errorf("resolving executable path: %w", err)
+		return err
 	}
 
-	// Download the new binary to a temp file
+	// Download, verify and unpack the new binary next to the current one
+	// (for atomic rename)
 	client := &http.Client{Timeout: 5 * time.Minute}
-	resp, err := client.Get(info.DownloadURL)
-	if err != nil {
-		return fmt.Errorf("downloading update: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download returned status %d", resp.StatusCode)
-	}
-
-	// Create temp file in same directory (for atomic rename)
-	dir := filepath.Dir(execPath)
-	tmpFile, err := os.CreateTemp(dir, "rp-chat-logger-update-*")
+	tmpPath, err := downloadUpdate(client, info, filepath.Dir(execPath), UpdatePublicKey)
 	if err != nil {
-		return fmt.Errorf("creating temp file: %w", err)
+		return err
 	}
-	tmpPath := tmpFile.Name()
-
-	// Download to temp file
-	_, err = io.Copy(tmpFile, resp.Body)
-	tmpFile.Close()
-	if err != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("writing update: %w", err)
+	if u.logger != nil {
+		u.logger.Log("info", "Update signature verified")
 	}
 
 	// Make executable (Unix only)
@@ -255,6 +289,12 @@ func (u *Updater) PerformUpdate() error {
 		return fmt.Errorf("applying update: %w", err)
 	}
 
+	// The first start of the new version confirms it works, or rolls back
+	rec := updateRecord{From: Version, To: info.LatestVersion, Applied: time.Now()}
+	if err := writeUpdateRecord(execPath, rec); err != nil && u.logger != nil {
+		u.logger.Log("warning", fmt.Sprintf("%v; the update can still be rolled back by hand", err))
+	}
+
 	if u.logger != nil {
 		u.logger.Log("info", "Update applied, restarting...")
 	}
@@ -263,32 +303,27 @@ func (u *Updater) PerformUpdate() error {
 	return restartApplication(execPath)
 }
 
-// applyUpdate replaces the current executable with the new one.
+// applyUpdate replaces the current executable with the new one, keeping
+// the current one as .old for rolling back.
 func applyUpdate(currentPath, newPath string) error {
-	if runtime.GOOS == "windows" {
-		// Windows: rename current exe to .old, then rename new to current
-		oldPath := currentPath + ".old"
+	oldPath := currentPath + oldBinarySuffix
 
-		// Remove any existing .old file
-		os.Remove(oldPath)
-
-		// Rename current to .old (Windows allows renaming running exe)
-		if err := os.Rename(currentPath, oldPath); err != nil {
-			return fmt.Errorf("renaming current executable: %w", err)
-		}
+	// Remove any existing .old file
+	os.Remove(oldPath)
 
-		// Rename new to current
-		if err := os.Rename(newPath, currentPath); err != nil {
-			// Try to restore old
-			os.Rename(oldPath, currentPath)
-			return fmt.Errorf("renaming new executable: %w", err)
-		}
+	// Rename current to .old (Windows allows renaming running exe)
+	if err := os.Rename(currentPath, oldPath); err != nil {
+		return fmt.Errorf("renaming current executable: %w", err)
+	}
 
-		return nil
+	// Rename new to current
+	if err := os.Rename(newPath, currentPath); err != nil {
+		// Try to restore old
+		os.Rename(oldPath, currentPath)
+		return fmt.Errorf("renaming new executable: %w", err)
 	}
 
-	// Unix: just replace the file
-	return os.Rename(newPath, currentPath)
+	return nil
 }
 
 // restartApplication restarts the application by spawning a new process.
@@ -307,19 +342,15 @@ func restartApplication(execPath string) error {
 	return nil
 }
 
-// CleanupOldBinary removes the .old backup file on startup (Windows).
+// CleanupOldBinary removes the binary a rollback replaced, which Windows
+// couldn't delete while it was running. The .old binary an update
+// replaced is kept for rolling back.
 func CleanupOldBinary() {
-	if runtime.GOOS != "windows" {
-		return
-	}
-
-	execPath, err := os.Executable()
+	execPath, err := executablePath()
 	if err != nil {
 		return
 	}
-	execPath, _ = filepath.EvalSymlinks(execPath)
-	oldPath := execPath + ".old"
 
 	// Try to remove, ignore errors (file might not exist)
-	os.Remove(oldPath)
+	os.Remove(execPath + failedBinarySuffix)
 }
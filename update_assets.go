@@ -0,0 +1,272 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	// checksumsAsset lists the SHA-256 of every other release asset, one
+	// "<hex>  <name>" line each, as written by sha256sum.
+	checksumsAsset = "SHA256SUMS"
+	// maxUpdateSize bounds a downloaded asset and the binary unpacked from
+	// it.
+	maxUpdateSize = 256 << 20
+	// maxChecksumsSize bounds the checksums file.
+	maxChecksumsSize = 64 << 10
+)
+
+// archiveAssetName returns the name of the compressed release asset for a
+// platform: rp-chat-logger_<os>_<arch>.zip on Windows, .tar.gz elsewhere.
+func archiveAssetName(goos, goarch string) string {
+	ext := ".tar.gz"
+	if goos == "windows" {
+		ext = ".zip"
+	}
+	return fmt.Sprintf("rp-chat-logger_%s_%s%s", goos, goarch, ext)
+}
+
+// findAsset returns the asset with the given name.
+func findAsset(assets []ReleaseAsset, name string) (ReleaseAsset, bool) {
+	for _, asset := range assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+// platformBinaryName returns the name of the bare executable for a
+// platform: rp-chat-logger_<os>_<arch>, with .exe on Windows.
+func platformBinaryName(goos, goarch string) string {
+	name := fmt.Sprintf("rp-chat-logger_%s_%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// platformAssetNames returns the assets a goos/goarch build can update
+// from, best first: the platform's archive, its bare executable, and on
+// windows/amd64 the rp-chat-logger.exe older releases published, the only
+// build they had.
+func platformAssetNames(goos, goarch string) []string {
+	names := []string{archiveAssetName(goos, goarch), platformBinaryName(goos, goarch)}
+	if goos == "windows" && goarch == "amd64" {
+		names = append(names, binaryName(goos))
+	}
+	return names
+}
+
+// pickAsset returns the asset to update a goos/goarch build from, see
+// platformAssetNames. Never one for another architecture: without a
+// matching asset there is no update.
+func pickAsset(assets []ReleaseAsset, goos, goarch string) (ReleaseAsset, bool) {
+	for _, name := range platformAssetNames(goos, goarch) {
+		if asset, ok := findAsset(assets, name); ok {
+			return asset, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+// binaryName returns the executable's name in releases and archives.
+func binaryName(goos string) string {
+	if goos == "windows" {
+		return "rp-chat-logger.exe"
+	}
+	return "rp-chat-logger"
+}
+
+// parseChecksums reads a SHA256SUMS file into a map from asset name to
+// lower-case hex digest. Binary-mode markers ("*name") are accepted.
+func parseChecksums(data []byte) map[string]string {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums
+}
+
+// fetchChecksum downloads the release's checksums file and returns the
+// digest listed for the asset. The file must carry a valid signature from
+// publicKey.
+func fetchChecksum(client *http.Client, info UpdateInfo, publicKey string) (string, error) {
+	data, err := fetchAsset(client, info.ChecksumsURL, maxChecksumsSize)
+	if err != nil {
+		return "", fmt.Errorf("downloading %s: %w", checksumsAsset, err)
+	}
+	if info.SignatureURL == "" {
+		return "", fmt.Errorf("release has no %s", signatureAsset)
+	}
+	sig, err := fetchAsset(client, info.SignatureURL, maxSignatureSize)
+	if err != nil {
+		return "", fmt.Errorf("downloading %s: %w", signatureAsset, err)
+	}
+	if err := verifyMinisign(publicKey, data, sig); err != nil {
+		return "", fmt.Errorf("verifying %s: %w", checksumsAsset, err)
+	}
+	sum, ok := parseChecksums(data)[info.AssetName]
+	if !ok {
+		return "", fmt.Errorf("%s has no entry for %s", checksumsAsset, info.AssetName)
+	}
+	return sum, nil
+}
+
+// fetchAsset downloads a small release asset into memory.
+func fetchAsset(client *http.Client, url string, limit int64) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("larger than %d bytes", limit)
+	}
+	return data, nil
+}
+
+// downloadUpdate downloads the update described by info into a new file
+// in dir, checks it against the release's SHA256SUMS, whose signature must
+// verify with publicKey (see UpdatePublicKey), unpacks the binary from
+// archives and returns the binary's path. The caller removes it if it
+// isn't used. Without a public key nothing is downloaded: an unverified
+// binary is never installed.
+func downloadUpdate(client *http.Client, info UpdateInfo, dir, publicKey string) (string, error) {
+	if publicKey == "" {
+		return "", fmt.Errorf("this build has no update signing key to verify the update with")
+	}
+	if info.ChecksumsURL == "" {
+		return "", fmt.Errorf("release has no %s to verify the update with", checksumsAsset)
+	}
+	want, err := fetchChecksum(client, info, publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Get(info.DownloadURL)
+	if err != nil {
+		return "", fmt.Errorf("downloading update: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+
+	download, err := os.CreateTemp(dir, "rp-chat-logger-download-*")
+	if err != nil {
+		return "", fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(download.Name())
+	defer download.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(download, hash), io.LimitReader(resp.Body, maxUpdateSize+1))
+	if err != nil {
+		return "", fmt.Errorf("writing update: %w", err)
+	}
+	if n > maxUpdateSize {
+		return "", fmt.Errorf("update is larger than %d bytes", maxUpdateSize)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return "", fmt.Errorf("checksum mismatch for %s: got %s, want %s", info.AssetName, got, want)
+	}
+
+	binary, err := os.CreateTemp(dir, "rp-chat-logger-update-*")
+	if err != nil {
+		return "", fmt.Errorf("creating temp file: %w", err)
+	}
+	_, err = download.Seek(0, io.SeekStart)
+	if err == nil {
+		err = unpackBinary(download, n, info.AssetName, getAssetName(), binary)
+	}
+	if closeErr := binary.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(binary.Name())
+		return "", err
+	}
+	return binary.Name(), nil
+}
+
+// unpackBinary copies the executable named want out of the downloaded
+// asset named assetName to dst. Bare binaries are copied as they are.
+func unpackBinary(src *os.File, size int64, assetName, want string, dst io.Writer) error {
+	switch {
+	case strings.HasSuffix(assetName, ".zip"):
+		zr, err := zip.NewReader(src, size)
+		if err != nil {
+			return fmt.Errorf("opening zip: %w", err)
+		}
+		for _, f := range zr.File {
+			if path.Base(f.Name) != want || f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("opening %s in zip: %w", f.Name, err)
+			}
+			defer rc.Close()
+			return copyLimited(dst, rc)
+		}
+	case strings.HasSuffix(assetName, ".tar.gz"):
+		gz, err := gzip.NewReader(src)
+		if err != nil {
+			return fmt.Errorf("opening tar.gz: %w", err)
+		}
+		defer gz.Close()
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("reading tar.gz: %w", err)
+			}
+			if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == want {
+				return copyLimited(dst, tr)
+			}
+		}
+	default:
+		return copyLimited(dst, src)
+	}
+	return fmt.Errorf("%s not found in %s", want, assetName)
+}
+
+// copyLimited copies at most maxUpdateSize bytes, failing beyond that.
+func copyLimited(dst io.Writer, src io.Reader) error {
+	n, err := io.Copy(dst, io.LimitReader(src, maxUpdateSize+1))
+	if err != nil {
+		return fmt.Errorf("unpacking update: %w", err)
+	}
+	if n > maxUpdateSize {
+		return fmt.Errorf("unpacked update is larger than %d bytes", maxUpdateSize)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// Files next to the executable: the binary an update replaced, the
+	// binary a rollback replaced (removed on the next start) and the
+	// record of the last update.
+	oldBinarySuffix    = ".old"
+	failedBinarySuffix = ".failed"
+	updateRecordSuffix = ".update.json"
+
+	// updateTrialTimeout is how long the first start after an update has
+	// to bind its ports before the update is rolled back.
+	updateTrialTimeout = 2 * time.Minute
+	// updateTrialSettle is how long the servers must stay up before the
+	// update counts as working; a failed listen shows up well within it.
+	updateTrialSettle = 10 * time.Second
+)
+
+// updateRecord remembers the last update until it is confirmed or rolled
+// back. Started is set by the first start of the new version, so a start
+// that finds it set knows the previous one never got its servers up.
+type updateRecord struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Applied   time.Time `json:"applied"`
+	Started   time.Time `json:"started,omitempty"`
+	Confirmed bool      `json:"confirmed,omitempty"`
+}
+
+// restartProcess replaces the running process; tests replace it.
+var restartProcess = restartApplication
+
+// executablePath returns the running executable with symlinks resolved.
+func executablePath() (string, error) {
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("getting executable path: %w", err)
+	}
+	execPath, err = filepath.EvalSymlinks(execPath)
+	if err != nil {
+		return "", fmt.Errorf("resolving executable path: %w", err)
+	}
+	return execPath, nil
+}
+
+func readUpdateRecord(execPath string) (updateRecord, error) {
+	var rec updateRecord
+	data, err := os.ReadFile(execPath + updateRecordSuffix)
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("decoding update record: %w", err)
+	}
+	return rec, nil
+}
+
+func writeUpdateRecord(execPath string, rec updateRecord) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding update record: %w", err)
+	}
+	if err := os.WriteFile(execPath+updateRecordSuffix, data, 0644); err != nil {
+		return fmt.Errorf("writing update record: %w", err)
+	}
+	return nil
+}
+
+// rollbackBinary puts the binary the last update replaced back in place of
+// execPath. The rolled-back binary is kept as .failed until the next start,
+// since Windows can't delete a running executable.
+func rollbackBinary(execPath string) error {
+	oldPath := execPath + oldBinarySuffix
+	if _, err := os.Stat(oldPath); err != nil {
+		return errors.New("no previous version to roll back to")
+	}
+	failedPath := execPath + failedBinarySuffix
+	os.Remove(failedPath)
+	if err := os.Rename(execPath, failedPath); err != nil {
+		return fmt.Errorf("renaming current executable: %w", err)
+	}
+	if err := os.Rename(oldPath, execPath); err != nil {
+		os.Rename(failedPath, execPath)
+		return fmt.Errorf("restoring previous executable: %w", err)
+	}
+	os.Remove(execPath + updateRecordSuffix)
+	return nil
+}
+
+// executable returns the binary the updater manages.
+func (u *Updater) executable() (string, error) {
+	if u.execPath != "" {
+		return u.execPath, nil
+	}
+	return executablePath()
+}
+
+// RollbackVersion returns the version a rollback would go back to ("" if
+// unknown) and whether a previous binary is available.
+func (u *Updater) RollbackVersion() (string, bool) {
+	execPath, err := u.executable()
+	if err != nil {
+		return "", false
+	}
+	if _, err := os.Stat(execPath + oldBinarySuffix); err != nil {
+		return "", false
+	}
+	rec, _ := readUpdateRecord(execPath)
+	return rec.From, true
+}
+
+// Rollback restores the previous binary and restarts into it.
+func (u *Updater) Rollback(reason string) error {
+	execPath, err := u.executable()
+	if err != nil {
+		return err
+	}
+	if err := rollbackBinary(execPath); err != nil {
+		return err
+	}
+	if u.logger != nil {
+		u.logger.Log("warning", fmt.Sprintf("Rolled back to the previous version: %s. Restarting...", reason))
+	}
+	return restartProcess(execPath)
+}
+
+// BeginTrial runs at startup. After an update that hasn't been confirmed
+// yet it starts the trial, which the app ends with ConfirmUpdate once its
+// servers are up. If an earlier start already began the trial and never
+// confirmed it, that start crashed, so the update is rolled back now.
+func (u *Updater) BeginTrial(now time.Time) {
+	execPath, err := u.executable()
+	if err != nil {
+		return
+	}
+	rec, err := readUpdateRecord(execPath)
+	if err != nil || rec.Confirmed {
+		return
+	}
+	if !rec.Started.IsZero() {
+		if err := u.Rollback(fmt.Sprintf("version %s didn't start after the update", rec.To)); err != nil && u.logger != nil {
+			u.logger.Log("error", fmt.Sprintf("Rollback failed: %v", err))
+		}
+		return
+	}
+	rec.Started = now
+	if err := writeUpdateRecord(execPath, rec); err != nil {
+		if u.logger != nil {
+			u.logger.Log("error", err.Error())
+		}
+		return
+	}
+	u.mu.Lock()
+	u.trial = true
+	u.mu.Unlock()
+}
+
+// InTrial reports whether this is the first start after an unconfirmed
+// update.
+func (u *Updater) InTrial() bool {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.trial
+}
+
+// ConfirmUpdate ends the trial: the update works and is kept.
+func (u *Updater) ConfirmUpdate() {
+	u.mu.Lock()
+	trial := u.trial
+	u.trial = false
+	u.mu.Unlock()
+	if !trial {
+		return
+	}
+	execPath, err := u.executable()
+	if err != nil {
+		return
+	}
+	rec, err := readUpdateRecord(execPath)
+	if err != nil {
+		return
+	}
+	rec.Confirmed = true
+	if err := writeUpdateRecord(execPath, rec); err != nil && u.logger != nil {
+		u.logger.Log("error", err.Error())
+	}
+	if u.logger != nil {
+		u.logger.Log("info", fmt.Sprintf("Update to %s confirmed", rec.To))
+	}
+}
+
+// FailTrial rolls the update back when this start is its trial and the
+// servers couldn't start. Outside a trial it does nothing, and callers go
+// on to report err.
+func (u *Updater) FailTrial(err error) {
+	if !u.InTrial() {
+		return
+	}
+	if rbErr := u.Rollback(fmt.Sprintf("the new version failed to start: %v", err)); rbErr != nil && u.logger != nil {
+		u.logger.Log("error", fmt.Sprintf("Rollback failed: %v", rbErr))
+	}
+}
+
+// superviseUpdate confirms a trial once the servers have stayed up for
+// updateTrialSettle, or rolls the update back if that hasn't happened
+// within updateTrialTimeout. The web UI, if any, is bound before it runs;
+// wantIngestion says whether the ingestion server should be running too.
+func (a *App) superviseUpdate(wantIngestion bool) {
+	if !a.updater.InTrial() {
+		return
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	deadline := time.Now().Add(updateTrialTimeout)
+	var upSince time.Time
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-ticker.C:
+			if !wantIngestion || a.ingestionRunning.Load() {
+				if upSince.IsZero() {
+					upSince = now
+				}
+				if now.Sub(upSince) >= updateTrialSettle {
+					a.updater.ConfirmUpdate()
+					return
+				}
+			} else {
+				upSince = time.Time{}
+			}
+			if now.After(deadline) {
+				a.updater.FailTrial(fmt.Errorf("servers not up within %s", updateTrialTimeout))
+				return
+			}
+		}
+	}
+}
+
+// handleUpdateRollback restores the previous version and restarts.
+func (a *App) handleUpdateRollback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	version, ok := a.updater.RollbackVersion()
+	if !ok {
+		w.Write([]byte(`<div class="alert error">No previous version to roll back to</div>`))
+		return
+	}
+	if version == "" {
+		version = "the previous version"
+	} else {
+		version = "v" + version
+	}
+	fmt.Fprintf(w, `<div class="alert success">Rolling back to %s and restarting. Reload this page in a few seconds.</div>`, template.HTMLEscapeString(version))
+
+	// Roll back after the response is sent
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		if err := a.updater.Rollback("requested from the web UI"); err != nil {
+			a.logger.Log("error", fmt.Sprintf("Rollback failed: %v", err))
+		}
+	}()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// installedBinary sets up an executable updated from "old" to "new", as
+// applyUpdate and PerformUpdate leave it, and returns its path.
+func installedBinary(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	execPath := filepath.Join(dir, "rp-chat-logger")
+	newPath := filepath.Join(dir, "download")
+	if err := os.WriteFile(execPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newPath, []byte("new"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyUpdate(execPath, newPath); err != nil {
+		t.Fatalf("applyUpdate: %v", err)
+	}
+	rec := updateRecord{From: "1.0.0", To: "1.1.0", Applied: time.Now()}
+	if err := writeUpdateRecord(execPath, rec); err != nil {
+		t.Fatal(err)
+	}
+	return execPath
+}
+
+// stubRestart records restarts instead of replacing the test process.
+func stubRestart(t *testing.T) *[]string {
+	t.Helper()
+	var restarts []string
+	restartProcess = func(execPath string) error {
+		restarts = append(restarts, execPath)
+		return nil
+	}
+	t.Cleanup(func() { restartProcess = restartApplication })
+	return &restarts
+}
+
+func fileContents(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRollbackBinary(t *testing.T) {
+	execPath := installedBinary(t)
+	if got := fileContents(t, execPath+oldBinarySuffix); got != "old" {
+		t.Fatalf("applyUpdate kept %q as .old, want old", got)
+	}
+
+	if err := rollbackBinary(execPath); err != nil {
+		t.Fatalf("rollbackBinary: %v", err)
+	}
+	if got := fileContents(t, execPath); got != "old" {
+		t.Errorf("executable = %q after rollback, want old", got)
+	}
+	if got := fileContents(t, execPath+failedBinarySuffix); got != "new" {
+		t.Errorf(".failed = %q, want new", got)
+	}
+	if _, err := readUpdateRecord(execPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("update record not removed: %v", err)
+	}
+	if err := rollbackBinary(execPath); err == nil {
+		t.Error("second rollback succeeded without a previous version")
+	}
+}
+
+func TestUpdateTrial(t *testing.T) {
+	restarts := stubRestart(t)
+	execPath := installedBinary(t)
+	u := &Updater{execPath: execPath}
+
+	if version, ok := u.RollbackVersion(); !ok || version != "1.0.0" {
+		t.Errorf("RollbackVersion() = %q, %v, want 1.0.0, true", version, ok)
+	}
+
+	u.BeginTrial(time.Now())
+	if !u.InTrial() {
+		t.Fatal("first start after an update is not a trial")
+	}
+	rec, err := readUpdateRecord(execPath)
+	if err != nil || rec.Started.IsZero() {
+		t.Fatalf("trial start not recorded: %+v, %v", rec, err)
+	}
+
+	u.ConfirmUpdate()
+	if u.InTrial() {
+		t.Error("still in trial after ConfirmUpdate")
+	}
+	if rec, _ := readUpdateRecord(execPath); !rec.Confirmed {
+		t.Error("confirmation not recorded")
+	}
+
+	// Later starts neither begin a trial nor roll back, but keep the
+	// previous version available.
+	next := &Updater{execPath: execPath}
+	next.BeginTrial(time.Now())
+	next.FailTrial(errors.New("bind failed"))
+	if next.InTrial() || len(*restarts) != 0 {
+		t.Errorf("confirmed update: trial %v, restarts %v", next.InTrial(), *restarts)
+	}
+	if _, ok := next.RollbackVersion(); !ok {
+		t.Error("previous version no longer available after confirming")
+	}
+}
+
+func TestUpdateTrialRollsBack(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(u *Updater)
+	}{
+		{"servers failed", func(u *Updater) {
+			u.BeginTrial(time.Now())
+			u.FailTrial(errors.New("bind failed"))
+		}},
+		{"crashed before confirming", func(u *Updater) {
+			u.BeginTrial(time.Now())
+			// The crashed process never confirms; the next start finds
+			// the trial it began.
+			(&Updater{execPath: u.execPath}).BeginTrial(time.Now())
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restarts := stubRestart(t)
+			execPath := installedBinary(t)
+			tt.run(&Updater{execPath: execPath})
+
+			if len(*restarts) != 1 || (*restarts)[0] != execPath {
+				t.Errorf("restarts = %v, want one of %s", *restarts, execPath)
+			}
+			if got := fileContents(t, execPath); got != "old" {
+				t.Errorf("executable = %q, want old", got)
+			}
+		})
+	}
+}
+
+func TestBeginTrialWithoutUpdate(t *testing.T) {
+	restarts := stubRestart(t)
+	execPath := filepath.Join(t.TempDir(), "rp-chat-logger")
+	if err := os.WriteFile(execPath, []byte("current"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	u := &Updater{execPath: execPath}
+
+	u.BeginTrial(time.Now())
+	u.FailTrial(errors.New("bind failed"))
+	if u.InTrial() || len(*restarts) != 0 {
+		t.Errorf("trial %v, restarts %v without an update", u.InTrial(), *restarts)
+	}
+	if _, ok := u.RollbackVersion(); ok {
+		t.Error("rollback offered without a previous version")
+	}
+}
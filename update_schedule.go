@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// defaultUpdateCheckHours is the check interval when the config
+	// leaves it at 0.
+	defaultUpdateCheckHours = 24
+	// maxUpdateCheckHours bounds the check interval to 30 days.
+	maxUpdateCheckHours = 720
+	// updateIdleTime is how long no message may have arrived before an
+	// automatic update is installed.
+	updateIdleTime = 15 * time.Minute
+)
+
+// runUpdateScheduler checks for updates right away and then once every
+// UpdateCheckHours, and installs an available update once the server is
+// idle when AutoUpdate is set (but not in a container, which updates by
+// image, or in a build that can't verify updates). The config is read on
+// every tick so changes apply without a restart.
+func (a *App) runUpdateScheduler() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	var lastCheck time.Time
+	var failed string // version whose automatic install failed
+	tick := func(now time.Time) {
+		a.configMu.RLock()
+		cfg := *a.config
+		a.configMu.RUnlock()
+
+		if updateCheckDue(&cfg, lastCheck, now) {
+			lastCheck = now
+			if err := a.updater.CheckForUpdate(cfg.UpdateChannel); err != nil {
+				slog.Warn("Update check failed", "err", err)
+			}
+		}
+		info := a.updater.GetInfo()
+		if cfg.AutoUpdate && info.Available && !info.Container && !info.Unsigned && info.LatestVersion != failed && a.idle(now) {
+			a.logger.Log("info", fmt.Sprintf("Server idle, installing update %s automatically", info.LatestVersion))
+			if err := a.updater.PerformUpdate(); err != nil {
+				// Leave it to the web UI rather than retrying every minute.
+				failed = info.LatestVersion
+				a.logger.Log("error", fmt.Sprintf("Automatic update failed: %v", err))
+			}
+		}
+	}
+
+	tick(time.Now())
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-ticker.C:
+			tick(now)
+		}
+	}
+}
+
+// updateCheckDue reports whether the periodic update check should run,
+// given when it last ran (zero for never).
+func updateCheckDue(cfg *AppConfig, lastCheck, now time.Time) bool {
+	if cfg.DisableUpdateCheck {
+		return false
+	}
+	hours := cfg.UpdateCheckHours
+	if hours <= 0 {
+		hours = defaultUpdateCheckHours
+	}
+	return lastCheck.IsZero() || now.Sub(lastCheck) >= time.Duration(hours)*time.Hour
+}
+
+// idle reports whether no message has arrived for updateIdleTime (or
+// since starting) and nothing is waiting to be delivered, so restarting
+// loses nothing.
+func (a *App) idle(now time.Time) bool {
+	last := a.started
+	if nanos := a.lastMessage.Load(); nanos != 0 {
+		last = time.Unix(0, nanos)
+	}
+	if now.Sub(last) < updateIdleTime {
+		return false
+	}
+	if a.discordQueue != nil && a.discordQueue.QueueSize() > 0 {
+		return false
+	}
+	if a.forwardQueue != nil && a.forwardQueue.QueueSize() > 0 {
+		return false
+	}
+	return true
+}
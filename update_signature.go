@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// signatureAsset is the minisign signature of checksumsAsset.
+	signatureAsset = checksumsAsset + ".minisig"
+	// maxSignatureSize bounds the signature file.
+	maxSignatureSize = 4 << 10
+	// unsignedUpdateHelp tells users of a build without UpdatePublicKey how
+	// to update, since it can't verify a download.
+	unsignedUpdateHelp = "download the new version from the release page and replace the executable by hand"
+)
+
+// minisign algorithm IDs. Only pure Ed25519 signatures (minisign -l) can
+// be checked with the standard library; the default prehashed ones need
+// BLAKE2b.
+const (
+	minisignEd25519   = "Ed"
+	minisignPrehashed = "ED"
+)
+
+// minisignKey is a decoded minisign public key.
+type minisignKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// parseMinisignKey decodes a minisign public key, either the base64 line
+// alone or the whole .pub file.
+func parseMinisignKey(s string) (minisignKey, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil {
+		return minisignKey{}, fmt.Errorf("decoding public key: %w", err)
+	}
+	if len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != minisignEd25519 {
+		return minisignKey{}, errors.New("not a minisign public key")
+	}
+	return minisignKey{id: raw[2:10], key: ed25519.PublicKey(raw[10:])}, nil
+}
+
+// verifyMinisign checks a minisign signature file over data against the
+// public key, including the signature of its trusted comment.
+func verifyMinisign(publicKey string, data, signature []byte) error {
+	key, err := parseMinisignKey(publicKey)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(string(signature)), "\r\n", "\n"), "\n")
+	if len(lines) < 4 {
+		return errors.New("signature file is truncated")
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return errors.New("signature file is malformed")
+	}
+	switch string(sig[:2]) {
+	case minisignEd25519:
+	case minisignPrehashed:
+		return errors.New("prehashed signatures aren't supported; sign with minisign -l")
+	default:
+		return fmt.Errorf("unknown signature algorithm %q", sig[:2])
+	}
+	if !bytes.Equal(sig[2:10], key.id) {
+		return errors.New("signed with a different key")
+	}
+	if !ed25519.Verify(key.key, data, sig[10:]) {
+		return errors.New("signature doesn't match")
+	}
+
+	comment, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return errors.New("signature file has no trusted comment")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return errors.New("signature file is malformed")
+	}
+	signed := append(append([]byte{}, sig[10:]...), comment...)
+	if !ed25519.Verify(key.key, signed, global) {
+		return errors.New("trusted comment signature doesn't match")
+	}
+	return nil
+}
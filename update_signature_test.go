@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testSigner makes minisign keys and signatures in the format of
+// minisign -l.
+type testSigner struct {
+	id   []byte
+	priv ed25519.PrivateKey
+	pub  string
+}
+
+func newTestSigner(t *testing.T, id string) testSigner {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := append(append([]byte(minisignEd25519), id...), pub...)
+	return testSigner{
+		id:   []byte(id),
+		priv: priv,
+		pub:  "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(raw) + "\n",
+	}
+}
+
+func (s testSigner) sign(data []byte) []byte {
+	return s.signAs(minisignEd25519, data, "timestamp:1760000000\tfile:SHA256SUMS")
+}
+
+func (s testSigner) signAs(alg string, data []byte, comment string) []byte {
+	sig := ed25519.Sign(s.priv, data)
+	global := ed25519.Sign(s.priv, append(append([]byte{}, sig...), comment...))
+	blob := append(append([]byte(alg), s.id...), sig...)
+	return []byte("untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(blob) + "\n" +
+		"trusted comment: " + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	signer := newTestSigner(t, "KEYID001")
+	other := newTestSigner(t, "KEYID002")
+	data := []byte("abc123  rp-chat-logger.exe\n")
+	valid := signer.sign(data)
+
+	tests := []struct {
+		name    string
+		key     string
+		data    []byte
+		sig     []byte
+		wantErr string
+	}{
+		{"valid", signer.pub, data, valid, ""},
+		{"key line only", strings.Split(signer.pub, "\n")[1], data, valid, ""},
+		{"tampered data", signer.pub, []byte("000000  rp-chat-logger.exe\n"), valid, "doesn't match"},
+		{"other key", other.pub, data, valid, "different key"},
+		{"tampered comment", signer.pub, data, bytes.Replace(valid, []byte("timestamp"), []byte("tImestamp"), 1), "trusted comment"},
+		{"prehashed", signer.pub, data, signer.signAs(minisignPrehashed, data, "x"), "minisign -l"},
+		{"truncated", signer.pub, data, valid[:40], "truncated"},
+		{"bad key", "RWQ", data, valid, "public key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyMinisign(tt.key, tt.data, tt.sig)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDownloadSignedUpdate(t *testing.T) {
+	signer := newTestSigner(t, "KEYID001")
+	binary := []byte("new binary")
+	name := getAssetName()
+	signed := releaseServer(t, map[string][]byte{name: binary}, nil, signer.sign)
+	forged := releaseServer(t, map[string][]byte{name: binary}, nil, newTestSigner(t, "KEYID001").sign)
+
+	tests := []struct {
+		name      string
+		base      string
+		signature bool
+		checksums bool
+		wantErr   string
+	}{
+		{"signed", signed.URL, true, true, ""},
+		{"forged signature", forged.URL, true, true, "signature doesn't match"},
+		{"no signature", signed.URL, false, true, "no " + signatureAsset},
+		{"no checksums", signed.URL, false, false, "no " + checksumsAsset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := UpdateInfo{AssetName: name, DownloadURL: tt.base + "/" + name}
+			if tt.checksums {
+				info.ChecksumsURL = tt.base + "/" + checksumsAsset
+			}
+			if tt.signature {
+				info.SignatureURL = tt.base + "/" + signatureAsset
+			}
+			path, err := downloadUpdate(signed.Client(), info, t.TempDir(), signer.pub)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(path); !bytes.Equal(got, binary) {
+				t.Errorf("got %q, want %q", got, binary)
+			}
+		})
+	}
+}
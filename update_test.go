@@ -0,0 +1,337 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPickAsset(t *testing.T) {
+	assets := []ReleaseAsset{
+		{Name: "rp-chat-logger.exe"},
+		{Name: "rp-chat-logger_windows_amd64.zip"},
+		{Name: "rp-chat-logger"},
+		{Name: "rp-chat-logger_linux_arm64"},
+		{Name: "rp-chat-logger_darwin_arm64.tar.gz"},
+		{Name: checksumsAsset},
+	}
+	tests := []struct {
+		goos, goarch string
+		want         string
+		ok           bool
+	}{
+		{"windows", "amd64", "rp-chat-logger_windows_amd64.zip", true},
+		{"linux", "arm64", "rp-chat-logger_linux_arm64", true},
+		{"darwin", "arm64", "rp-chat-logger_darwin_arm64.tar.gz", true},
+		// Assets without a platform in their name are Windows amd64 builds.
+		{"windows", "arm64", "", false},
+		{"linux", "amd64", "", false},
+		{"darwin", "amd64", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := pickAsset(assets, tt.goos, tt.goarch)
+		if ok != tt.ok || got.Name != tt.want {
+			t.Errorf("pickAsset(%s/%s) = %q, %v; want %q", tt.goos, tt.goarch, got.Name, ok, tt.want)
+		}
+	}
+	if asset, ok := pickAsset(assets[:1], "windows", "amd64"); !ok || asset.Name != "rp-chat-logger.exe" {
+		t.Errorf("older release: got %q, %v; want rp-chat-logger.exe", asset.Name, ok)
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	sums := parseChecksums([]byte("ABC123  rp-chat-logger.exe\ndef456 *rp-chat-logger_linux_amd64.tar.gz\n\nnot a checksum line here\n"))
+	if len(sums) != 2 || sums["rp-chat-logger.exe"] != "abc123" || sums["rp-chat-logger_linux_amd64.tar.gz"] != "def456" {
+		t.Errorf("unexpected checksums %v", sums)
+	}
+}
+
+// releaseServer serves release assets by name, with a SHA256SUMS listing
+// sums for them, unless overridden, signed with sign when it isn't nil.
+func releaseServer(t *testing.T, assets map[string][]byte, sums map[string]string, sign func([]byte) []byte) *httptest.Server {
+	t.Helper()
+	var list strings.Builder
+	for name, data := range assets {
+		sum := sha256.Sum256(data)
+		if s, ok := sums[name]; ok {
+			list.WriteString(s + "  " + name + "\n")
+		} else {
+			list.WriteString(hex.EncodeToString(sum[:]) + "  " + name + "\n")
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name == checksumsAsset {
+			w.Write([]byte(list.String()))
+			return
+		}
+		if name == signatureAsset && sign != nil {
+			w.Write(sign([]byte(list.String())))
+			return
+		}
+		data, ok := assets[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestUnpackBinary(t *testing.T) {
+	binary := []byte("new binary")
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	fw, _ := zw.Create("rp-chat-logger/rp-chat-logger.exe")
+	fw.Write(binary)
+	zw.Close()
+
+	var tgz bytes.Buffer
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "README.md", Mode: 0644, Size: 2, Typeflag: tar.TypeReg})
+	tw.Write([]byte("hi"))
+	tw.WriteHeader(&tar.Header{Name: "rp-chat-logger", Mode: 0755, Size: int64(len(binary)), Typeflag: tar.TypeReg})
+	tw.Write(binary)
+	tw.Close()
+	gz.Close()
+
+	tests := []struct {
+		name    string
+		asset   string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{"zip", "rp-chat-logger_windows_amd64.zip", zipped.Bytes(), "rp-chat-logger.exe", false},
+		{"tar.gz", "rp-chat-logger_linux_amd64.tar.gz", tgz.Bytes(), "rp-chat-logger", false},
+		{"bare binary", "rp-chat-logger", binary, "rp-chat-logger", false},
+		{"missing from archive", "rp-chat-logger_linux_amd64.tar.gz", tgz.Bytes(), "rp-chat-logger.exe", true},
+		{"corrupt zip", "rp-chat-logger_windows_amd64.zip", []byte("not a zip"), "rp-chat-logger.exe", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := os.CreateTemp(t.TempDir(), "asset-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.Close()
+			src.Write(tt.data)
+			src.Seek(0, 0)
+
+			var out bytes.Buffer
+			err = unpackBinary(src, int64(len(tt.data)), tt.asset, tt.want, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !tt.wantErr && !bytes.Equal(out.Bytes(), binary) {
+				t.Errorf("got %q, want %q", out.Bytes(), binary)
+			}
+		})
+	}
+}
+
+func TestDownloadUpdate(t *testing.T) {
+	signer := newTestSigner(t, "KEYID001")
+	binary := []byte("new binary")
+	name := getAssetName()
+	srv := releaseServer(t, map[string][]byte{
+		name:           binary,
+		"tampered.bin": []byte("evil"),
+	}, map[string]string{"tampered.bin": strings.Repeat("0", 64)}, signer.sign)
+
+	tests := []struct {
+		name      string
+		asset     string
+		checksums bool
+		key       string
+		wantErr   string
+	}{
+		{"verified", name, true, signer.pub, ""},
+		{"unverified", name, false, signer.pub, "no " + checksumsAsset},
+		{"unsigned build", name, true, "", "no update signing key"},
+		{"tampered", "tampered.bin", true, signer.pub, "checksum mismatch"},
+		{"not listed", "missing.bin", true, signer.pub, "no entry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := UpdateInfo{AssetName: tt.asset, DownloadURL: srv.URL + "/" + tt.asset}
+			if tt.checksums {
+				info.ChecksumsURL = srv.URL + "/" + checksumsAsset
+				info.SignatureURL = srv.URL + "/" + signatureAsset
+			}
+			dir := t.TempDir()
+			path, err := downloadUpdate(srv.Client(), info, dir, tt.key)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+					t.Errorf("expected temp files to be removed, found %d", len(entries))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(path); !bytes.Equal(got, binary) {
+				t.Errorf("got %q, want %q", got, binary)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("expected only the unpacked binary to remain, found %d files", len(entries))
+			}
+		})
+	}
+}
+
+func TestFetchLatestRelease(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/ragaz-zo/rp-chat-logger/releases/latest":
+			w.Write([]byte(`{"tag_name": "v1.2.0"}`))
+		case "/repos/ragaz-zo/rp-chat-logger/releases":
+			w.Write([]byte(`[
+				{"tag_name": "v1.4.0", "draft": true},
+				{"tag_name": "v1.3.0-beta.1", "prerelease": true, "body": "## Fixes", "published_at": "2026-10-01T12:00:00Z"},
+				{"tag_name": "v1.2.0"}
+			]`))
+		}
+	}))
+	defer srv.Close()
+	saved := githubAPI
+	githubAPI = srv.URL
+	defer func() { githubAPI = saved }()
+
+	tests := []struct {
+		channel string
+		want    string
+	}{
+		{"", "v1.2.0"},
+		{updateChannelStable, "v1.2.0"},
+		{updateChannelBeta, "v1.3.0-beta.1"},
+	}
+	for _, tt := range tests {
+		release, err := fetchLatestRelease(srv.Client(), tt.channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if release == nil || release.TagName != tt.want {
+			t.Errorf("channel %q: got %+v, want %s", tt.channel, release, tt.want)
+		}
+	}
+
+	u := NewUpdater(nil)
+	if err := u.CheckForUpdate(updateChannelBeta); err != nil {
+		t.Fatal(err)
+	}
+	info := u.GetInfo()
+	if info.LatestVersion != "1.3.0-beta.1" || !info.Prerelease || info.Notes != "## Fixes" ||
+		!info.PublishedAt.Equal(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected update info %+v", info)
+	}
+	// The release has no assets at all, so none for this platform.
+	if info.Available || info.MissingPlatform != runtime.GOOS+"/"+runtime.GOARCH {
+		t.Errorf("missing platform not reported: %+v", info)
+	}
+	if err := u.PerformUpdate(); err == nil || !strings.Contains(err.Error(), "no build for") {
+		t.Errorf("PerformUpdate() = %v, want a missing build error", err)
+	}
+}
+
+func TestHandleUpdateNotes(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	a.updater = &Updater{info: UpdateInfo{
+		Available:      true,
+		CurrentVersion: "1.2.0",
+		LatestVersion:  "1.3.0",
+		AssetName:      "rp-chat-logger_linux_amd64.tar.gz",
+		DownloadURL:    "https://example.invalid/rp-chat-logger_linux_amd64.tar.gz",
+		AssetSize:      3 << 19,
+		PublishedAt:    time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		Notes:          "## Fixes\n- Reconnects <b>faster</b>",
+	}}
+
+	rec := httptest.NewRecorder()
+	a.handleUpdateNotes(rec, httptest.NewRequest("GET", "/api/update/notes", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"v1.3.0", "Published 2026-10-0", "1.5 MB", "<h4>Fixes</h4>", "<li>Reconnects &lt;b&gt;faster&lt;/b&gt;</li>", `hx-post="/api/update/apply"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("notes missing %q:\n%s", want, body)
+		}
+	}
+
+	a.updater.info.Unsigned = true
+	rec = httptest.NewRecorder()
+	a.handleUpdateNotes(rec, httptest.NewRequest("GET", "/api/update/notes", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "replace the executable by hand") || strings.Contains(body, `hx-post="/api/update/apply"`) {
+		t.Errorf("unsigned build offers to install the update:\n%s", body)
+	}
+	rec = httptest.NewRecorder()
+	a.handleUpdateApply(rec, httptest.NewRequest("POST", "/api/update/apply", nil))
+	if !strings.Contains(rec.Body.String(), "can't verify updates") {
+		t.Errorf("unsigned build applied the update: %s", rec.Body.String())
+	}
+	if err := a.updater.PerformUpdate(); err == nil || !strings.Contains(err.Error(), unsignedUpdateHelp) {
+		t.Errorf("PerformUpdate on an unsigned build = %v", err)
+	}
+
+	a.updater.info.Available = false
+	rec = httptest.NewRecorder()
+	a.handleUpdateNotes(rec, httptest.NewRequest("GET", "/api/update/notes", nil))
+	if !strings.Contains(rec.Body.String(), "No update available") {
+		t.Errorf("unexpected body without an update: %s", rec.Body.String())
+	}
+}
+
+func TestUpdateCheckDue(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		cfg       AppConfig
+		lastCheck time.Time
+		want      bool
+	}{
+		{"never checked", AppConfig{}, time.Time{}, true},
+		{"default interval not passed", AppConfig{}, now.Add(-23 * time.Hour), false},
+		{"default interval passed", AppConfig{}, now.Add(-24 * time.Hour), true},
+		{"custom interval", AppConfig{UpdateCheckHours: 6}, now.Add(-6 * time.Hour), true},
+		{"disabled", AppConfig{DisableUpdateCheck: true}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		if got := updateCheckDue(&tt.cfg, tt.lastCheck, now); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIdle(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+	now := time.Now()
+	a.started = now.Add(-time.Minute)
+	if a.idle(now) {
+		t.Error("expected a freshly started app not to be idle")
+	}
+	a.started = now.Add(-time.Hour)
+	if !a.idle(now) {
+		t.Error("expected an app without messages for an hour to be idle")
+	}
+	a.lastMessage.Store(now.Add(-5 * time.Minute).UnixNano())
+	if a.idle(now) {
+		t.Error("expected a recent message to keep the app busy")
+	}
+}
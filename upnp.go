@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ssdpAddr          = "239.255.255.250:1900"
+	ssdpSearchTarget  = "urn:schemas-upnp-org:device:InternetGatewayDevice:1"
+	ssdpTimeout       = 3 * time.Second
+	upnpLease         = time.Hour
+	upnpRenewInterval = 30 * time.Minute
+	upnpDescription   = "RP Chat Logger"
+)
+
+var upnpClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// upnpDiscover finds the router's device description URL; tests replace it.
+var upnpDiscover = discoverGateway
+
+// upnpMapping is a TCP port forwarded on the router with UPnP IGD. The
+// lease is renewed while the mapping is active.
+type upnpMapping struct {
+	controlURL  string
+	serviceType string
+	port        int
+	client      string
+	externalIP  string
+	done        chan struct{}
+}
+
+// startUPnP forwards port on the router to the same port on this machine.
+func startUPnP(ctx context.Context, port int) (*upnpMapping, error) {
+	location, err := upnpDiscover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	controlURL, serviceType, err := gatewayService(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	client, err := localAddrFor(controlURL)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &upnpMapping{
+		controlURL:  controlURL,
+		serviceType: serviceType,
+		port:        port,
+		client:      client,
+		done:        make(chan struct{}),
+	}
+	if err := m.add(ctx); err != nil {
+		return nil, err
+	}
+	if reply, err := upnpSOAP(ctx, controlURL, serviceType, "GetExternalIPAddress", nil); err == nil {
+		m.externalIP = reply["NewExternalIPAddress"]
+	}
+	go m.renew()
+	return m, nil
+}
+
+// add creates or refreshes the mapping.
+func (m *upnpMapping) add(ctx context.Context) error {
+	_, err := upnpSOAP(ctx, m.controlURL, m.serviceType, "AddPortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(m.port)},
+		{"NewProtocol", "TCP"},
+		{"NewInternalPort", strconv.Itoa(m.port)},
+		{"NewInternalClient", m.client},
+		{"NewEnabled", "1"},
+		{"NewPortMappingDescription", upnpDescription},
+		{"NewLeaseDuration", strconv.Itoa(int(upnpLease.Seconds()))},
+	})
+	if err != nil {
+		return fmt.Errorf("adding port mapping: %w", err)
+	}
+	return nil
+}
+
+func (m *upnpMapping) renew() {
+	ticker := time.NewTicker(upnpRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			m.add(ctx)
+			cancel()
+		}
+	}
+}
+
+// stop removes the mapping from the router.
+func (m *upnpMapping) stop(ctx context.Context) error {
+	close(m.done)
+	_, err := upnpSOAP(ctx, m.controlURL, m.serviceType, "DeletePortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(m.port)},
+		{"NewProtocol", "TCP"},
+	})
+	if err != nil {
+		return fmt.Errorf("removing port mapping: %w", err)
+	}
+	return nil
+}
+
+// discoverGateway sends an SSDP search for an Internet gateway and returns
+// the description URL of the first one that answers.
+func discoverGateway(ctx context.Context) (string, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", fmt.Errorf("opening SSDP socket: %w", err)
+	}
+	defer conn.Close()
+	dest, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return "", err
+	}
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: " + ssdpSearchTarget + "\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), dest); err != nil {
+		return "", fmt.Errorf("sending SSDP search: %w", err)
+	}
+
+	deadline := time.Now().Add(ssdpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", fmt.Errorf("no UPnP gateway found")
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if location := resp.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
+
+// upnpDevice is a device in a UPnP description, with its embedded devices.
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// gatewayService fetches the device description at location and returns
+// the control URL and type of its WAN IP (or PPP) connection service.
+func gatewayService(ctx context.Context, location string) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid gateway location: %w", err)
+	}
+	resp, err := upnpClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("fetching gateway description: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("fetching gateway description: status %d", resp.StatusCode)
+	}
+
+	var root struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return "", "", fmt.Errorf("parsing gateway description: %w", err)
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return "", "", err
+	}
+	if root.URLBase != "" {
+		if u, err := url.Parse(root.URLBase); err == nil {
+			base = u
+		}
+	}
+
+	var find func(d upnpDevice) (string, string, bool)
+	find = func(d upnpDevice) (string, string, bool) {
+		for _, s := range d.Services {
+			if strings.Contains(s.ServiceType, ":WANIPConnection:") || strings.Contains(s.ServiceType, ":WANPPPConnection:") {
+				return s.ControlURL, s.ServiceType, true
+			}
+		}
+		for _, child := range d.Devices {
+			if control, typ, ok := find(child); ok {
+				return control, typ, true
+			}
+		}
+		return "", "", false
+	}
+	control, typ, ok := find(root.Device)
+	if !ok {
+		return "", "", fmt.Errorf("gateway does not support port mapping")
+	}
+	ref, err := url.Parse(control)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid control URL: %w", err)
+	}
+	return base.ResolveReference(ref).String(), typ, nil
+}
+
+// upnpSOAP calls action on a UPnP service and returns the response's
+// arguments by name.
+func upnpSOAP(ctx context.Context, controlURL, serviceType, action string, args [][2]string) (map[string]string, error) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, html.EscapeString(serviceType))
+	for _, arg := range args {
+		fmt.Fprintf(&body, "<%s>%s</%s>", arg[0], html.EscapeString(arg[1]), arg[0])
+	}
+	fmt.Fprintf(&body, "</u:%s></s:Body></s:Envelope>", action)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, controlURL, strings.NewReader(body.String()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+serviceType+"#"+action+`"`)
+	resp, err := upnpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	values := soapValues(data)
+	if resp.StatusCode != http.StatusOK {
+		if desc := values["errorDescription"]; desc != "" {
+			return nil, fmt.Errorf("%s: %s (%s)", action, desc, values["errorCode"])
+		}
+		return nil, fmt.Errorf("%s: status %d", action, resp.StatusCode)
+	}
+	return values, nil
+}
+
+// soapValues collects the text of every leaf element in a SOAP response,
+// keyed by local name.
+func soapValues(data []byte) map[string]string {
+	values := make(map[string]string)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var name string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return values
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name = t.Name.Local
+		case xml.CharData:
+			if name != "" {
+				values[name] += string(t)
+			}
+		case xml.EndElement:
+			name = ""
+		}
+	}
+}
+
+// localAddrFor returns this machine's IP address on the route to target's
+// host.
+func localAddrFor(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+	conn, err := net.Dial("udp4", host)
+	if err != nil {
+		return "", fmt.Errorf("finding local address: %w", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
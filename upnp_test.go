@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGateway is a UPnP Internet gateway that records the SOAP actions it
+// receives.
+type fakeGateway struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	actions []string
+	bodies  []string
+}
+
+const fakeGatewayDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <serviceList>
+      <service><serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType><controlURL>/l3f</controlURL></service>
+    </serviceList>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service><serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType><controlURL>/ctl/IPConn</controlURL></service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+func newFakeGateway(t *testing.T) *fakeGateway {
+	t.Helper()
+	g := &fakeGateway{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, fakeGatewayDescription)
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		action := r.Header.Get("SOAPAction")
+		action = strings.Trim(action[strings.Index(action, "#")+1:], `"`)
+		g.mu.Lock()
+		g.actions = append(g.actions, action)
+		g.bodies = append(g.bodies, string(body))
+		g.mu.Unlock()
+
+		if action == "AddPortMapping" && strings.Contains(string(body), "<NewExternalPort>80<") {
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>718</errorCode><errorDescription>ConflictInMappingEntry</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`)
+			return
+		}
+		fmt.Fprintf(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:%sResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">`, action)
+		if action == "GetExternalIPAddress" {
+			io.WriteString(w, "<NewExternalIPAddress>203.0.113.7</NewExternalIPAddress>")
+		}
+		fmt.Fprintf(w, "</u:%sResponse></s:Body></s:Envelope>", action)
+	})
+	g.Server = httptest.NewServer(mux)
+	t.Cleanup(g.Close)
+
+	orig := upnpDiscover
+	upnpDiscover = func(context.Context) (string, error) { return g.URL + "/rootDesc.xml", nil }
+	t.Cleanup(func() { upnpDiscover = orig })
+	return g
+}
+
+func (g *fakeGateway) calls() ([]string, []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.actions...), append([]string(nil), g.bodies...)
+}
+
+func TestGatewayService(t *testing.T) {
+	g := newFakeGateway(t)
+
+	control, typ, err := gatewayService(context.Background(), g.URL+"/rootDesc.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if control != g.URL+"/ctl/IPConn" {
+		t.Errorf("expected the nested WANIPConnection control URL, got %q", control)
+	}
+	if typ != "urn:schemas-upnp-org:service:WANIPConnection:1" {
+		t.Errorf("unexpected service type %q", typ)
+	}
+
+	if _, _, err := gatewayService(context.Background(), g.URL+"/missing"); err == nil {
+		t.Error("expected an error for a missing description")
+	}
+}
+
+func TestStartUPnP(t *testing.T) {
+	g := newFakeGateway(t)
+
+	m, err := startUPnP(context.Background(), 3000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.externalIP != "203.0.113.7" {
+		t.Errorf("expected the external IP, got %q", m.externalIP)
+	}
+	if err := m.stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	actions, bodies := g.calls()
+	want := []string{"AddPortMapping", "GetExternalIPAddress", "DeletePortMapping"}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected actions %v, got %v", want, actions)
+	}
+	for _, arg := range []string{"<NewExternalPort>3000</NewExternalPort>", "<NewInternalPort>3000</NewInternalPort>", "<NewProtocol>TCP</NewProtocol>", "<NewInternalClient>127.0.0.1</NewInternalClient>"} {
+		if !strings.Contains(bodies[0], arg) {
+			t.Errorf("expected %s in AddPortMapping, got %s", arg, bodies[0])
+		}
+	}
+}
+
+func TestStartUPnP_Conflict(t *testing.T) {
+	newFakeGateway(t)
+
+	_, err := startUPnP(context.Background(), 80)
+	if err == nil || !strings.Contains(err.Error(), "ConflictInMappingEntry (718)") {
+		t.Errorf("expected the router's error, got %v", err)
+	}
+}
+
+func TestDiscoveryStatus(t *testing.T) {
+	newFakeGateway(t)
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	if s := a.discoveryStatus(); s["Pending"] != false || s["InternetURL"] != "" {
+		t.Errorf("expected empty status before starting, got %v", s)
+	}
+	a.startDiscovery(&AppConfig{ListenAddr: "0.0.0.0:3000", EnableUPnP: true})
+	a.discoveryMu.Lock()
+	d := a.discovery
+	a.discoveryMu.Unlock()
+	<-d.done
+	if s := a.discoveryStatus(); s["InternetURL"] != "http://203.0.113.7:3000/message" {
+		t.Errorf("expected the internet URL, got %v", s)
+	}
+	a.stopDiscovery()
+	if s := a.discoveryStatus(); s["InternetURL"] != "" {
+		t.Errorf("expected empty status after stopping, got %v", s)
+	}
+}
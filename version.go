@@ -6,3 +6,8 @@ var Version = "dev"
 
 // GitCommit is set at build time via -ldflags "-X main.GitCommit=abc123"
 var GitCommit = ""
+
+// UpdatePublicKey is the minisign public key that release checksums are
+// signed with, set at build time via -ldflags "-X main.UpdatePublicKey=RW...".
+// Builds with a key only install updates whose signature checks out.
+var UpdatePublicKey = ""
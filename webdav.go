@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// webdavSink uploads files into a WebDAV folder (Nextcloud, ownCloud,
+// Synology and the like), creating subfolders as needed.
+type webdavSink struct {
+	base     string
+	user     string
+	password string
+}
+
+// newWebDAVSink builds a sink from the WebDAV settings in config.
+func newWebDAVSink(config *AppConfig) *webdavSink {
+	return &webdavSink{
+		base:     strings.TrimSuffix(config.WebDAVURL, "/"),
+		user:     config.WebDAVUser,
+		password: config.WebDAVPassword,
+	}
+}
+
+func (s *webdavSink) name() string { return "WebDAV" }
+
+// put creates the folders in key below the base URL, then uploads the file.
+func (s *webdavSink) put(ctx context.Context, key, path string) error {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	for i := 1; i < len(segments); i++ {
+		if err := s.mkcol(ctx, s.base+"/"+strings.Join(segments[:i], "/")+"/"); err != nil {
+			return err
+		}
+	}
+
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	if s.user != "" {
+		header.Set("Authorization", s.basicAuth())
+	}
+	return putFile(ctx, s.base+"/"+strings.Join(segments, "/"), path, header)
+}
+
+// upload makes webdavSink a backup destination; backups go under "backups/".
+func (s *webdavSink) upload(ctx context.Context, file string) error {
+	return s.put(ctx, "backups/"+filepath.Base(file), file)
+}
+
+// mkcol creates a collection. A 405 means it already exists.
+func (s *webdavSink) mkcol(ctx context.Context, target string) error {
+	req, err := http.NewRequestWithContext(ctx, "MKCOL", target, nil)
+	if err != nil {
+		return fmt.Errorf("creating WebDAV request: %w", err)
+	}
+	if s.user != "" {
+		req.Header.Set("Authorization", s.basicAuth())
+	}
+	resp, err := backupClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("creating WebDAV folder: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+		return fmt.Errorf("creating WebDAV folder: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *webdavSink) basicAuth() string {
+	req := http.Request{Header: http.Header{}}
+	req.SetBasicAuth(s.user, s.password)
+	return req.Header.Get("Authorization")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWebDAVSink_Put(t *testing.T) {
+	var requests []string
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "gm" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		switch r.Method {
+		case "MKCOL":
+			if r.URL.Path == "/dav/logs/2026/" {
+				w.WriteHeader(http.StatusMethodNotAllowed) // already exists
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "log.txt")
+	os.WriteFile(file, []byte("hello"), 0644)
+
+	s := newWebDAVSink(&AppConfig{WebDAVURL: srv.URL + "/dav/logs/", WebDAVUser: "gm", WebDAVPassword: "secret"})
+	if err := s.put(context.Background(), "2026/10/Kara & Bren.txt", file); err != nil {
+		t.Fatal(err)
+	}
+	want := "MKCOL /dav/logs/2026/,MKCOL /dav/logs/2026/10/,PUT /dav/logs/2026/10/Kara%20&%20Bren.txt"
+	if got := strings.Join(requests, ","); got != want {
+		t.Errorf("requests\n%s\nwant\n%s", got, want)
+	}
+	if body != "hello" {
+		t.Errorf("uploaded %q", body)
+	}
+
+	s.password = "wrong"
+	if err := s.put(context.Background(), "x.txt", file); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected a 401 error, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// webhookCheckTimeout bounds the Discord lookups made when saving.
+const webhookCheckTimeout = 10 * time.Second
+
+// webhookPath matches the path of a Discord webhook URL, with or without
+// an API version.
+var webhookPath = regexp.MustCompile(`^/api(/v\d+)?/webhooks/\d+/[\w-]+/?$`)
+
+// checkWebhookURL reports whether raw looks like a Discord webhook URL, to
+// catch pasting a channel link or a truncated URL. label names the field
+// in the error.
+func checkWebhookURL(label, raw string) error {
+	u, err := url.Parse(raw)
+	host := ""
+	if err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	discordHost := host == "discord.com" || host == "discordapp.com" ||
+		strings.HasSuffix(host, ".discord.com") || strings.HasSuffix(host, ".discordapp.com")
+	if err != nil || u.Scheme != "https" || !discordHost || !webhookPath.MatchString(u.Path) {
+		return fmt.Errorf("%s must look like https://discord.com/api/webhooks/<id>/<token>", label)
+	}
+	return nil
+}
+
+// checkWebhookURLs checks the format of every Discord webhook URL in use.
+func checkWebhookURLs(config *AppConfig) error {
+	if config.EnableDiscord && config.DiscordMode != discordModeBot {
+		if err := checkWebhookURL("Discord webhook URL", config.WebhookURL); err != nil {
+			return err
+		}
+		if config.OOCDiscordPolicy == oocSeparate {
+			if err := checkWebhookURL("OOC webhook URL", config.OOCWebhookURL); err != nil {
+				return err
+			}
+		}
+		for i, route := range config.DiscordFailover {
+			if err := checkWebhookURL(fmt.Sprintf("Failover webhook URL %d", i+1), route); err != nil {
+				return err
+			}
+		}
+		for lang, route := range config.LanguageRoutes {
+			if err := checkWebhookURL(fmt.Sprintf("Webhook URL for %s", lang), route); err != nil {
+				return err
+			}
+		}
+		for name, route := range config.SenderRoutes {
+			if route.WebhookURL == "" {
+				continue
+			}
+			if err := checkWebhookURL(fmt.Sprintf("Webhook URL for sender %s", name), route.WebhookURL); err != nil {
+				return err
+			}
+		}
+	}
+	if config.DigestWebhookURL != "" {
+		if err := checkWebhookURL("Digest webhook URL", config.DigestWebhookURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discordDestination describes where messages will be posted, as far as
+// Discord tells us.
+type discordDestination struct {
+	WebhookName string
+	ChannelID   string
+	ChannelName string
+	GuildID     string
+	GuildName   string
+}
+
+// String returns a one-line description such as
+// `"Captain Hook" webhook in #tavern on My Server`.
+func (d discordDestination) String() string {
+	var b strings.Builder
+	if d.WebhookName != "" {
+		fmt.Fprintf(&b, "%q webhook in ", d.WebhookName)
+	}
+	if d.ChannelName != "" {
+		b.WriteString("#" + d.ChannelName)
+	} else {
+		b.WriteString("channel " + d.ChannelID)
+	}
+	if d.GuildName != "" {
+		b.WriteString(" on " + d.GuildName)
+	}
+	return b.String()
+}
+
+// verifyDiscordTarget asks Discord about the configured webhook, or the
+// bot's channel in bot mode, failing if it doesn't exist. Channel and
+// server names are only visible to a bot, so they are looked up when a bot
+// token is configured.
+func verifyDiscordTarget(ctx context.Context, config *AppConfig) (discordDestination, error) {
+	var dest discordDestination
+	if config.DiscordMode == discordModeBot {
+		dest.ChannelID = config.BotChannelID
+	} else {
+		var hook struct {
+			Name      string `json:"name"`
+			ChannelID string `json:"channel_id"`
+			GuildID   string `json:"guild_id"`
+		}
+		if err := discordGet(ctx, config.WebhookURL, &hook); err != nil {
+			return dest, fmt.Errorf("Discord webhook check failed: %w", err)
+		}
+		dest.WebhookName, dest.ChannelID, dest.GuildID = hook.Name, hook.ChannelID, hook.GuildID
+	}
+	if config.BotToken == "" {
+		return dest, nil
+	}
+
+	var channel struct {
+		Name    string `json:"name"`
+		GuildID string `json:"guild_id"`
+	}
+	if err := discordGet(ctx, botAPIURL(config.BotToken, "channels", dest.ChannelID), &channel); err != nil {
+		if config.DiscordMode == discordModeBot {
+			return dest, fmt.Errorf("Discord channel check failed: %w", err)
+		}
+		// The webhook works; the bot just can't see its channel.
+		return dest, nil
+	}
+	dest.ChannelName, dest.GuildID = channel.Name, channel.GuildID
+	var guild struct {
+		Name string `json:"name"`
+	}
+	if dest.GuildID != "" && discordGet(ctx, botAPIURL(config.BotToken, "guilds", dest.GuildID), &guild) == nil {
+		dest.GuildName = guild.Name
+	}
+	return dest, nil
+}
+
+// botAPIURL returns a bot-authenticated URL for a Discord API path.
+func botAPIURL(token string, elem ...string) string {
+	u, err := url.Parse(discordAPI)
+	if err != nil {
+		return ""
+	}
+	u = u.JoinPath(elem...)
+	u.User = url.UserPassword(discordModeBot, token)
+	return u.String()
+}
+
+// discordGet fetches a Discord API object into v.
+func discordGet(ctx context.Context, target string, v interface{}) error {
+	req, err := newDiscordRequest(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending discord request: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusNotFound:
+		return fmt.Errorf("not found, or the token is wrong (status %d)", resp.StatusCode)
+	case http.StatusForbidden:
+		return fmt.Errorf("the bot has no access to it (status %d)", resp.StatusCode)
+	default:
+		return fmt.Errorf("discord API returned status code: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding discord response: %w", err)
+	}
+	return nil
+}
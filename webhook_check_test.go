@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://discord.com/api/webhooks/123456789/abc-DEF_123", false},
+		{"https://discordapp.com/api/webhooks/123456789/abc", false},
+		{"https://canary.discord.com/api/v10/webhooks/123456789/abc/", false},
+		{"http://discord.com/api/webhooks/123456789/abc", true},
+		{"https://discord.com/channels/111/222", true},
+		{"https://discord.com/api/webhooks/123456789", true},
+		{"https://discord.com.evil.example/api/webhooks/1/abc", true},
+		{"discord.com/api/webhooks/1/abc", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := checkWebhookURL("Discord webhook URL", tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckWebhookURLs(t *testing.T) {
+	valid := "https://discord.com/api/webhooks/1/abc"
+	tests := []struct {
+		name    string
+		cfg     AppConfig
+		wantErr string
+	}{
+		{"valid", AppConfig{EnableDiscord: true, WebhookURL: valid}, ""},
+		{"discord disabled", AppConfig{WebhookURL: "https://example.com"}, ""},
+		{"bot mode", AppConfig{EnableDiscord: true, DiscordMode: discordModeBot, WebhookURL: "leftover"}, ""},
+		{"bad main", AppConfig{EnableDiscord: true, WebhookURL: "https://example.com/hook"}, "Discord webhook URL must look like"},
+		{"bad OOC", AppConfig{EnableDiscord: true, WebhookURL: valid, OOCDiscordPolicy: oocSeparate, OOCWebhookURL: "x"}, "OOC webhook URL must look like"},
+		{"bad digest", AppConfig{DigestWebhookURL: "https://discord.com/channels/1/2"}, "Digest webhook URL must look like"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkWebhookURLs(&tt.cfg)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)):
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// newFakeDiscordLookup serves the webhook, channel and guild objects used
+// by verifyDiscordTarget.
+func newFakeDiscordLookup(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bot := r.Header.Get("Authorization") == "Bot secret"
+		switch {
+		case r.URL.Path == "/api/webhooks/1/good":
+			io.WriteString(w, `{"name":"Captain Hook","channel_id":"200","guild_id":"300"}`)
+		case r.URL.Path == "/channels/200" && bot:
+			io.WriteString(w, `{"name":"tavern","guild_id":"300"}`)
+		case r.URL.Path == "/channels/201" && bot:
+			w.WriteHeader(http.StatusForbidden)
+		case r.URL.Path == "/guilds/300" && bot:
+			io.WriteString(w, `{"name":"Hyborian Age RP"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	orig := discordAPI
+	discordAPI = srv.URL
+	t.Cleanup(func() { discordAPI = orig })
+	return srv
+}
+
+func TestVerifyDiscordTarget(t *testing.T) {
+	srv := newFakeDiscordLookup(t)
+
+	tests := []struct {
+		name    string
+		cfg     AppConfig
+		want    string
+		wantErr string
+	}{
+		{
+			name: "webhook",
+			cfg:  AppConfig{WebhookURL: srv.URL + "/api/webhooks/1/good"},
+			want: `"Captain Hook" webhook in channel 200`,
+		},
+		{
+			name: "webhook with bot token for names",
+			cfg:  AppConfig{WebhookURL: srv.URL + "/api/webhooks/1/good", BotToken: "secret"},
+			want: `"Captain Hook" webhook in #tavern on Hyborian Age RP`,
+		},
+		{
+			name:    "deleted webhook",
+			cfg:     AppConfig{WebhookURL: srv.URL + "/api/webhooks/1/gone"},
+			wantErr: "Discord webhook check failed: not found, or the token is wrong (status 404)",
+		},
+		{
+			name: "bot channel",
+			cfg:  AppConfig{DiscordMode: discordModeBot, BotToken: "secret", BotChannelID: "200"},
+			want: "#tavern on Hyborian Age RP",
+		},
+		{
+			name:    "bot without access",
+			cfg:     AppConfig{DiscordMode: discordModeBot, BotToken: "secret", BotChannelID: "201"},
+			wantErr: "Discord channel check failed: the bot has no access to it (status 403)",
+		},
+		{
+			name:    "wrong bot token",
+			cfg:     AppConfig{DiscordMode: discordModeBot, BotToken: "wrong", BotChannelID: "200"},
+			wantErr: "Discord channel check failed: not found, or the token is wrong (status 404)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest, err := verifyDiscordTarget(context.Background(), &tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := dest.String(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discordStats counts the requests discordClient sends to each webhook and
+// bot channel.
+var discordStats = &webhookStats{}
+
+// WebhookStats counts the requests to one Discord webhook or bot channel
+// since start. A message split into chunks makes a request per chunk.
+type WebhookStats struct {
+	// Target is "webhook <id>" or "channel <id>"; the webhook's token is
+	// never shown.
+	Target string `json:"target"`
+	// Roles are what the config uses the target for, such as "main",
+	// "OOC" or "language es".
+	Roles       []string   `json:"roles,omitempty"`
+	Sent        int64      `json:"sent"`
+	Failed      int64      `json:"failed"`
+	RateLimited int64      `json:"rateLimited"`
+	AvgMillis   float64    `json:"avgMillis"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+
+	total time.Duration
+}
+
+// webhookStats holds WebhookStats by target. The zero value is ready to
+// use.
+type webhookStats struct {
+	mu      sync.Mutex
+	targets map[string]*WebhookStats
+}
+
+// discordTargetName names the webhook or bot channel u posts to, without
+// its token: "webhook <id>", "channel <id>", or the host and path of other
+// URLs.
+func discordTargetName(u *url.URL) string {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		switch segments[i] {
+		case "webhooks":
+			return "webhook " + segments[i+1]
+		case "channels":
+			return "channel " + segments[i+1]
+		}
+	}
+	return u.Host + u.Path
+}
+
+// record adds a request to target that took elapsed and got resp or err.
+func (s *webhookStats) record(target string, resp *http.Response, err error, elapsed time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.targets == nil {
+		s.targets = make(map[string]*WebhookStats)
+	}
+	ws := s.targets[target]
+	if ws == nil {
+		ws = &WebhookStats{Target: target}
+		s.targets[target] = ws
+	}
+	switch {
+	case err == nil && resp.StatusCode < 300:
+		ws.Sent++
+	case err == nil && resp.StatusCode == http.StatusTooManyRequests:
+		ws.RateLimited++
+	default:
+		ws.Failed++
+		ws.LastError = requestFailure(resp, err)
+		ws.LastFailure = &now
+	}
+	ws.total += elapsed
+	ws.AvgMillis = millis(ws.total / time.Duration(ws.Sent+ws.Failed+ws.RateLimited))
+}
+
+// requestFailure describes a failed request without its URL, which may
+// hold a webhook token.
+func requestFailure(resp *http.Response, err error) string {
+	if err == nil {
+		return fmt.Sprintf("status %d", resp.StatusCode)
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return err.Error()
+}
+
+// snapshot returns a copy of the stats, busiest first, with each target's
+// roles in cfg.
+func (s *webhookStats) snapshot(cfg *AppConfig) []WebhookStats {
+	roles := discordTargetRoles(cfg)
+	s.mu.Lock()
+	result := make([]WebhookStats, 0, len(s.targets))
+	for _, ws := range s.targets {
+		c := *ws
+		c.Roles = roles[ws.Target]
+		result = append(result, c)
+	}
+	s.mu.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		ti := result[i].Sent + result[i].Failed + result[i].RateLimited
+		tj := result[j].Sent + result[j].Failed + result[j].RateLimited
+		if ti != tj {
+			return ti > tj
+		}
+		return result[i].Target < result[j].Target
+	})
+	return result
+}
+
+// discordTargetRoles maps the Discord targets configured in cfg, by
+// discordTargetName, to what they are used for.
+func discordTargetRoles(cfg *AppConfig) map[string][]string {
+	roles := make(map[string][]string)
+	add := func(target, role string) {
+		if u, err := url.Parse(target); err == nil && target != "" {
+			name := discordTargetName(u)
+			roles[name] = append(roles[name], role)
+		}
+	}
+	add(discordTarget(cfg), "main")
+	add(oocDiscordTarget(cfg), "OOC")
+	add(cfg.DigestWebhookURL, "digest")
+	for _, lang := range sortedKeys(cfg.LanguageRoutes) {
+		add(languageDiscordTarget(cfg, lang), "language "+lang)
+	}
+	for _, name := range sortedKeys(cfg.SenderRoutes) {
+		if route := cfg.SenderRoutes[name].WebhookURL; route != "" {
+			add(discordRouteURL(cfg, route), "sender "+name)
+		}
+	}
+	for i, target := range failoverTargets(cfg) {
+		add(target, fmt.Sprintf("failover %d", i+1))
+	}
+	for _, name := range sortedKeys(cfg.Sources) {
+		add(cfg.Sources[name].WebhookURL, "source "+name)
+	}
+	return roles
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// statsTransport is an http.RoundTripper that records each request's
+// outcome in a webhookStats.
+type statsTransport struct {
+	stats *webhookStats
+	base  http.RoundTripper
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	t.stats.record(discordTargetName(req.URL), resp, err, time.Since(start), time.Now())
+	return resp, err
+}
+
+// handleDiscordStats returns the per-target Discord stats as an HTML
+// partial.
+func (a *App) handleDiscordStats(w http.ResponseWriter, r *http.Request) {
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+	tmpl, err := a.parseTemplates("templates/partials/webhook_stats.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "webhook-stats", discordStats.snapshot(&cfg)); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// defaultWebPortTries is how many ports after the web UI port are tried
+// when it is taken.
+const defaultWebPortTries = 10
+
+// webUIAddrs returns the addresses to try for the web UI, in order. A
+// comma-separated list is tried as given; a single address is followed by
+// the next tries ports on the same host.
+func webUIAddrs(addr string, tries int) []string {
+	list := parseList(addr)
+	if len(list) != 1 {
+		return list
+	}
+	addrs := []string{list[0]}
+	host, portStr, err := net.SplitHostPort(list[0])
+	if err != nil {
+		return addrs
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port == 0 {
+		return addrs
+	}
+	for i := 1; i <= tries && port+i <= 65535; i++ {
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(port+i)))
+	}
+	return addrs
+}
+
+// listenWebUI binds the first of addrs that is free and records it as the
+// web UI address, so logs, the tray and the browser use the port actually
+// chosen.
+func (a *App) listenWebUI(addrs []string) (net.Listener, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no web UI address given")
+	}
+	var firstErr error
+	for _, addr := range addrs {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			slog.Debug("Web UI address unavailable", "addr", addr, "err", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		a.webAddr = ln.Addr().String()
+		if addr != addrs[0] {
+			slog.Warn("Web UI address in use, using another", "wanted", addrs[0], "addr", a.webAddr)
+			a.logger.Log("warning", fmt.Sprintf("Web UI address %s is in use, using %s instead", addrs[0], a.webAddr))
+		}
+		return ln, nil
+	}
+	return nil, fmt.Errorf("no free web UI address among %s: %w", strings.Join(addrs, ", "), firstErr)
+}
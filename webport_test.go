@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestWebUIAddrs(t *testing.T) {
+	tests := []struct {
+		addr  string
+		tries int
+		want  []string
+	}{
+		{"127.0.0.1:8080", 2, []string{"127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8082"}},
+		{"127.0.0.1:8080", 0, []string{"127.0.0.1:8080"}},
+		{":65535", 3, []string{":65535"}},
+		{"127.0.0.1:0", 3, []string{"127.0.0.1:0"}},
+		{"[::1]:8080", 1, []string{"[::1]:8080", "[::1]:8081"}},
+		{"127.0.0.1:8080, 127.0.0.1:9090", 5, []string{"127.0.0.1:8080", "127.0.0.1:9090"}},
+	}
+	for _, tt := range tests {
+		if got := webUIAddrs(tt.addr, tt.tries); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("webUIAddrs(%q, %d) = %v, want %v", tt.addr, tt.tries, got, tt.want)
+		}
+	}
+}
+
+func TestListenWebUI_SkipsTakenPort(t *testing.T) {
+	a := setupTestApp()
+	defer func() { a.sseBroker.Stop(); a.failureBroker.Stop() }()
+
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	ln, err := a.listenWebUI([]string{taken.Addr().String(), "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if a.webAddr != ln.Addr().String() || a.webAddr == taken.Addr().String() {
+		t.Errorf("expected the free address to be recorded, got %q", a.webAddr)
+	}
+
+	if _, err := a.listenWebUI([]string{taken.Addr().String()}); err == nil {
+		t.Error("expected an error when every address is taken")
+	}
+}
@@ -1,22 +1,26 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
 
-// StartWebUI starts the web UI HTTP server. This blocks until the server
-// is shut down or encounters an error.
-func (a *App) StartWebUI() error {
+// StartWebUI serves the web UI on ln, from listenWebUI. This blocks until
+// the server is shut down or encounters an error.
+func (a *App) StartWebUI(ln net.Listener) error {
 	mux := http.NewServeMux()
 
 	// Serve embedded static files
@@ -28,41 +32,150 @@ func (a *App) StartWebUI() error {
 
 	// Page routes
 	mux.HandleFunc("GET /", a.handleIndex)
+	mux.HandleFunc("GET /stats", a.handleStatsPage)
+	mux.HandleFunc("GET /failures", a.handleFailuresPage)
+	mux.HandleFunc("GET /characters", a.handleCharactersPage)
+	mux.HandleFunc("GET /setup", a.handleSetupPage)
+
+	// Sign-in, when RequireAPIToken covers the web UI
+	mux.HandleFunc("GET /login", a.handleLoginPage)
+	mux.HandleFunc("POST /login", a.handleLogin)
+	mux.HandleFunc("POST /logout", a.handleLogout)
+
+	// Health checks for Docker and uptime monitors
+	mux.HandleFunc("GET /healthz", a.handleHealthz)
+	mux.HandleFunc("GET /readyz", a.handleReadyz)
 
 	// API routes for HTMX
 	mux.HandleFunc("GET /api/config", a.handleGetConfig)
 	mux.HandleFunc("PUT /api/config", a.handleUpdateConfig)
+	mux.HandleFunc("GET /api/config/export", a.handleExportConfig)
+	mux.HandleFunc("POST /api/config/import", a.handleImportConfig)
+	mux.HandleFunc("POST /api/templates/preview", a.handleTemplatePreview)
+	mux.HandleFunc("GET /api/preferences", a.handleGetPreferences)
+	mux.HandleFunc("PUT /api/preferences", a.handleUpdatePreferences)
 	mux.HandleFunc("POST /api/server/start", a.handleStartServer)
 	mux.HandleFunc("POST /api/server/stop", a.handleStopServer)
 	mux.HandleFunc("GET /api/server/status", a.handleServerStatus)
 
+	// Statistics
+	mux.HandleFunc("GET /api/stats", a.handleStats)
+	mux.HandleFunc("GET /api/discord/stats", a.handleDiscordStats)
+	mux.HandleFunc("GET /api/export/epub", a.handleExportEPUB)
+
+	// Session endpoints
+	mux.HandleFunc("POST /api/session/start", a.handleSessionStart)
+	mux.HandleFunc("POST /api/session/stop", a.handleSessionStop)
+	mux.HandleFunc("GET /api/session/status", a.handleSessionStatus)
+	mux.HandleFunc("POST /api/session/summary", a.handleSessionSummary)
+
 	// SSE endpoints
 	mux.HandleFunc("GET /api/logs/stream", a.handleSSEStream)
 	mux.HandleFunc("GET /api/failures/stream", a.handleFailureStream)
+	mux.HandleFunc("GET /api/chat/stream", a.handleChatStream)
+	a.registerLiveRoutes(mux)
+
+	// Backfill and import
+	mux.HandleFunc("POST /api/replay", a.handleReplay)
+	mux.HandleFunc("POST /api/import", a.handleImport)
+	mux.HandleFunc("POST /api/backup", a.handleBackup)
+	mux.HandleFunc("POST /api/rcon/announce", a.handleAnnounce)
+
+	// Self-tests
+	mux.HandleFunc("POST /api/test/discord", a.handleTestDiscord)
+	mux.HandleFunc("POST /api/test/file", a.handleTestFile)
+	mux.HandleFunc("POST /api/test/ingestion", a.handleTestIngestion)
+
+	// Delivery receipts
+	mux.HandleFunc("GET /api/messages/status", a.handleMessageStatus)
+
+	// Failure actions
+	mux.HandleFunc("GET /api/failures", a.handleFailures)
+	mux.HandleFunc("DELETE /api/failures", a.handleClearFailures)
+	mux.HandleFunc("POST /api/failures/{id}/retry", a.handleRetryFailure)
+	mux.HandleFunc("DELETE /api/failures/{id}", a.handleDismissFailure)
+	mux.HandleFunc("GET /api/queue", a.handleQueue)
+	mux.HandleFunc("DELETE /api/queue", a.handlePurgeQueue)
+	mux.HandleFunc("POST /api/queue/{id}/retry", a.handleRetryQueued)
+	mux.HandleFunc("DELETE /api/queue/{id}", a.handleDeleteQueued)
+	mux.HandleFunc("POST /api/discord/pause", a.handlePauseDiscord)
+	mux.HandleFunc("POST /api/discord/resume", a.handleResumeDiscord)
+
+	// API tokens
+	mux.HandleFunc("POST /api/tokens", a.handleCreateToken)
+	mux.HandleFunc("DELETE /api/tokens/{id}", a.handleRevokeToken)
+
+	// Config profiles
+	mux.HandleFunc("POST /api/profiles", a.handleSaveProfile)
+	mux.HandleFunc("POST /api/profiles/{name}/activate", a.handleActivateProfile)
+	mux.HandleFunc("DELETE /api/profiles/{name}", a.handleDeleteProfile)
+
+	// Characters
+	mux.HandleFunc("GET /api/characters", a.handleCharacters)
+	mux.HandleFunc("POST /api/characters", a.handleSaveCharacter)
+	mux.HandleFunc("DELETE /api/characters/{name}", a.handleDeleteCharacter)
+	mux.HandleFunc("POST /api/senders/rename", a.handleRenameSender)
+	mux.HandleFunc("POST /api/messages/delete", a.handleDeleteMessages)
+
+	// Annotations
+	mux.HandleFunc("GET /api/annotations", a.handleAnnotations)
+	mux.HandleFunc("POST /api/annotations", a.handleAnnotate)
+	mux.HandleFunc("DELETE /api/annotations/{id}", a.handleDeleteAnnotation)
 
 	// Shutdown endpoint
 	mux.HandleFunc("POST /api/shutdown", a.handleShutdown)
 
 	// Update endpoints
 	mux.HandleFunc("GET /api/update/info", a.handleUpdateInfo)
+	mux.HandleFunc("GET /api/update/notes", a.handleUpdateNotes)
 	mux.HandleFunc("POST /api/update/check", a.handleUpdateCheck)
 	mux.HandleFunc("POST /api/update/apply", a.handleUpdateApply)
+	mux.HandleFunc("POST /api/update/rollback", a.handleUpdateRollback)
+
+	// First-run setup wizard
+	mux.HandleFunc("POST /api/setup/step", a.handleSetupStep)
+	mux.HandleFunc("POST /api/setup/test", a.handleSetupTest)
+	mux.HandleFunc("POST /api/setup/finish", a.handleSetupFinish)
 
 	// Dialog endpoints
 	mux.HandleFunc("GET /api/dialog/select-folder", a.handleSelectFolder)
 
+	// JSON API for scripts and dashboards
+	a.registerAPIv1(mux)
+
 	a.webServer = &http.Server{
 		Addr:    a.webAddr,
-		Handler: mux,
+		Handler: a.withAccessLog("webui", a.withIPFilter(webUIIPLists, true, a.withWebUIToken(mux))),
 	}
 
-	log.Printf("Web UI started at http://%s/", a.webAddr)
+	slog.Info("Web UI started", "url", "http://"+a.webAddr+"/")
 	a.logger.Log("info", fmt.Sprintf("Web UI available at http://%s/", a.webAddr))
-	return a.webServer.ListenAndServe()
+	return a.webServer.Serve(ln)
+}
+
+// templateFuncs are the helper functions available to all templates.
+var templateFuncs = template.FuncMap{
+	"nameMap":      formatNameMap,
+	"sources":      formatSources,
+	"senderRoutes": formatSenderRoutes,
+	"join":         strings.Join,
+	"markdown":     renderMarkdown,
+	"size":         formatSize,
+}
+
+// formatSize formats a byte count for display, e.g. "12.3 MB".
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
 }
 
 func (a *App) parseTemplates(files ...string) (*template.Template, error) {
-	return template.ParseFS(templateFS, files...)
+	return template.New("").Funcs(templateFuncs).ParseFS(templateFS, files...)
 }
 
 // handleIndex renders the main page.
@@ -71,19 +184,34 @@ func (a *App) handleIndex(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
+	if r.URL.Query().Get("setup") != "skip" && a.needsSetup() {
+		http.Redirect(w, r, "/setup", http.StatusSeeOther)
+		return
+	}
 
 	a.configMu.RLock()
 	cfg := *a.config
 	a.configMu.RUnlock()
 
 	updateInfo := a.updater.GetInfo()
+	rollbackVersion, canRollback := a.updater.RollbackVersion()
 	data := map[string]interface{}{
 		"Config":          cfg,
+		"Session":         a.sessionData(),
 		"Running":         a.ingestionRunning.Load(),
 		"Message":         a.statusMessage(),
+		"Tunnel":          a.tunnelStatus(),
+		"Discovery":       a.discoveryStatus(),
+		"Offline":         a.offlineStatus(),
+		"Paused":          a.pauseStatus(),
 		"Version":         Version,
 		"UpdateAvailable": updateInfo.Available,
 		"UpdateInfo":      updateInfo,
+		"CanRollback":     canRollback,
+		"RollbackVersion": rollbackVersion,
+		"Prefs":           a.uiPreferences(w, r),
+		"TokenList":       map[string]interface{}{"Tokens": a.tokens.list()},
+		"ProfileList":     a.profileListData(map[string]interface{}{}),
 	}
 
 	tmpl, err := a.parseTemplates(
@@ -91,14 +219,87 @@ func (a *App) handleIndex(w http.ResponseWriter, r *http.Request) {
 		"templates/index.html",
 		"templates/partials/config_form.html",
 		"templates/partials/status.html",
+		"templates/partials/session.html",
+		"templates/partials/token_list.html",
+		"templates/partials/profile_list.html",
+		"templates/partials/update.html",
 	)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
 		return
 	}
 	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
-		log.Printf("Template render error: %v", err)
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// handleStatsPage renders the statistics page.
+func (a *App) handleStatsPage(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := a.parseTemplates(
+		"templates/layout.html",
+		"templates/stats.html",
+		"templates/partials/stats_charts.html",
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := a.statsData(r)
+	data["Prefs"] = a.uiPreferences(w, r)
+	now := time.Now()
+	data["BookFrom"] = now.AddDate(0, 0, -29).Format("2006-01-02")
+	data["BookTo"] = now.Format("2006-01-02")
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// handleStats returns the statistics charts as an HTML partial.
+func (a *App) handleStats(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := a.parseTemplates("templates/partials/stats_charts.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "stats-charts", a.statsData(r)); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// statsData aggregates the stored logs for the window selected by the
+// "days" query parameter (default 7).
+func (a *App) statsData(r *http.Request) map[string]interface{} {
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days <= 0 || days > 366 {
+		days = 7
+	}
+
+	a.configMu.RLock()
+	cfg := *a.config
+	a.configMu.RUnlock()
+
+	data := map[string]interface{}{
+		"Stats": StatsView{Days: days},
+	}
+	if !cfg.EnableLocalSave || cfg.Path == "" {
+		data["StatsError"] = "Statistics are computed from the stored logs. Enable file logging to collect them."
+		return data
+	}
+
+	now := time.Now()
+	entries, _, err := entriesBetween(&cfg, truncateToDay(now).AddDate(0, 0, 1-days), now.Add(time.Second))
+	if err != nil {
+		a.logger.Log("error", fmt.Sprintf("Reading logs for statistics failed: %v", err))
+		data["StatsError"] = "Failed to read log files"
+		return data
+	}
+	view := newStatsView(aggregateEntries(entries), days)
+	view.Entities = buildEntityIndex(entries)
+	if len(view.Entities) > statsEntities {
+		view.Entities = view.Entities[:statsEntities]
 	}
+	data["Stats"] = view
+	return data
 }
 
 // handleGetConfig returns the current config as an HTML partial.
@@ -117,7 +318,7 @@ func (a *App) handleGetConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := tmpl.ExecuteTemplate(w, "config-form", data); err != nil {
-		log.Printf("Template render error: %v", err)
+		slog.Error("Template render error", "err", err)
 	}
 }
 
@@ -131,37 +332,212 @@ func (a *App) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sources, sourcesErr := parseSources(r.FormValue("sources"))
+	senderRoutes, senderRoutesErr := parseSenderRoutes(r.FormValue("senderRoutes"))
+	requireAPIToken := r.FormValue("requireAPIToken") == "on"
+	var tokenErr error
+	if requireAPIToken && !a.tokens.hasAdmin() {
+		tokenErr = errors.New("Create an API token with the admin scope before requiring tokens, or you can't sign in to the web UI")
+	}
+
 	a.configMu.Lock()
+	oldTarget := discordTarget(a.config)
 	a.config.WebhookURL = r.FormValue("webhookURL")
+	a.config.DiscordMode = r.FormValue("discordMode")
+	a.config.BotToken = strings.TrimSpace(r.FormValue("botToken"))
+	a.config.BotChannelID = strings.TrimSpace(r.FormValue("botChannelID"))
+	a.config.BotOOCChannelID = strings.TrimSpace(r.FormValue("botOOCChannelID"))
+	a.config.VerifyWebhook = r.FormValue("verifyWebhook") == "on"
+	if discordTarget(a.config) != oldTarget {
+		// Threads belong to the old channel; start fresh.
+		a.config.SceneThreadIDs = nil
+	}
 	a.config.EnableDiscord = r.FormValue("enableDiscord") == "on"
+	a.config.SceneThreads = r.FormValue("sceneThreads") == "on"
+	a.config.SenderAsAuthor = r.FormValue("senderAsAuthor") == "on"
+	a.config.Avatars = parseNameMap(r.FormValue("avatars"))
+	a.config.DiscordAttachAfter = formInt(r, "discordAttachAfter")
+	a.config.DiscordSessionTranscript = r.FormValue("discordSessionTranscript") == "on"
+	a.config.DiscordMarkdown = r.FormValue("discordMarkdown")
+	a.config.DiscordMentions = r.FormValue("discordMentions")
+	a.config.MentionAlerts = parseNameMap(r.FormValue("mentionAlerts"))
+	a.config.LanguageRoutes = parseNameMap(r.FormValue("languageRoutes"))
+	a.config.DiscordFailover = parseLines(r.FormValue("discordFailover"))
+	a.config.DiscordFailoverAfter = formInt(r, "discordFailoverAfter")
+	a.config.DiscordBreakerAfter = formInt(r, "discordBreakerAfter")
+	a.config.DiscordBreakerCooldown = formInt(r, "discordBreakerCooldown")
+	a.config.OfflineBuffer = r.FormValue("offlineBuffer") == "on"
 	a.config.EnableLocalSave = r.FormValue("enableLocalSave") == "on"
 	a.config.Path = r.FormValue("path")
 	a.config.FileFormat = r.FormValue("fileFormat")
+	a.config.FilenameTemplate = strings.TrimSpace(r.FormValue("filenameTemplate"))
+	a.config.DiscordTemplate = strings.TrimSpace(r.FormValue("discordTemplate"))
+	a.config.TextTemplate = strings.TrimSpace(r.FormValue("textTemplate"))
+	a.config.CSVDelimiter = r.FormValue("csvDelimiter")
+	a.config.CSVBOM = r.FormValue("csvBom") == "on"
+	a.config.FolderLayout = r.FormValue("folderLayout")
+	a.config.FlushInterval = formInt(r, "flushInterval")
+	a.config.RetentionDays = formInt(r, "retentionDays")
+	a.config.RetentionMaxSizeMB = formInt(r, "retentionMaxSizeMB")
+	a.config.RetentionAction = r.FormValue("retentionAction")
 	a.config.ListenAddr = r.FormValue("listenAddr")
 	a.config.AutoStart = r.FormValue("autoStart") == "on"
-	a.config.DebugMode = r.FormValue("debugMode") == "on"
+	a.config.LogLevel = r.FormValue("logLevel")
+	a.config.AppLogPath = strings.TrimSpace(r.FormValue("appLogPath"))
+	a.config.AppLogFormat = r.FormValue("appLogFormat")
+	a.config.EnableAccessLog = r.FormValue("enableAccessLog") == "on"
+	a.config.AccessLogPath = strings.TrimSpace(r.FormValue("accessLogPath"))
+	a.config.LogHistorySize = formInt(r, "logHistorySize")
+	a.config.FailureHistorySize = formInt(r, "failureHistorySize")
+	a.config.PersistLogHistory = r.FormValue("persistLogHistory") == "on"
+	a.config.UpdateChannel = r.FormValue("updateChannel")
+	a.config.UpdateCheckHours = formInt(r, "updateCheckHours")
+	a.config.DisableUpdateCheck = r.FormValue("disableUpdateCheck") == "on"
+	a.config.AutoUpdate = r.FormValue("autoUpdate") == "on"
+	a.config.PersistReceipts = r.FormValue("persistReceipts") == "on"
+	a.config.DeliveryQueueSize = formInt(r, "deliveryQueueSize")
+	a.config.DeliveryDropPolicy = r.FormValue("deliveryDropPolicy")
+	a.config.EmoteDetection = r.FormValue("emoteDetection") == "on"
+	a.config.EmotePrefixes = parseList(r.FormValue("emotePrefixes"))
+	a.config.Transforms = parseLines(r.FormValue("transforms"))
+	a.config.RichText = r.FormValue("richText")
+	a.config.TranslateProvider = r.FormValue("translateProvider")
+	a.config.TranslateTarget = strings.TrimSpace(r.FormValue("translateTarget"))
+	a.config.TranslateAPIKey = r.FormValue("translateAPIKey")
+	a.config.TranslateURL = strings.TrimSpace(r.FormValue("translateURL"))
+	a.config.TranslateDiscord = r.FormValue("translateDiscord") == "on"
+	a.config.TranslateFile = r.FormValue("translateFile") == "on"
+	a.config.SummaryURL = strings.TrimSpace(r.FormValue("summaryURL"))
+	a.config.SummaryAPIKey = r.FormValue("summaryAPIKey")
+	a.config.SummaryModel = strings.TrimSpace(r.FormValue("summaryModel"))
+	a.config.SummaryPrompt = strings.TrimSpace(r.FormValue("summaryPrompt"))
+	a.config.SummaryOnSessionEnd = r.FormValue("summaryOnSessionEnd") == "on"
+	a.config.SummaryDiscord = r.FormValue("summaryDiscord") == "on"
+	a.config.SummaryFile = r.FormValue("summaryFile") == "on"
+	a.config.OOCMarkers = parseList(r.FormValue("oocMarkers"))
+	a.config.OOCDiscordPolicy = r.FormValue("oocDiscordPolicy")
+	a.config.OOCWebhookURL = r.FormValue("oocWebhookURL")
+	a.config.OOCFilePolicy = r.FormValue("oocFilePolicy")
+	a.config.IgnoredSenders = parseLines(r.FormValue("ignoredSenders"))
+	a.config.IgnoredSenderPolicy = r.FormValue("ignoredSenderPolicy")
+	a.config.QuietHours = strings.TrimSpace(r.FormValue("quietHours"))
+	a.config.QuietHoursPolicy = r.FormValue("quietHoursPolicy")
+	a.config.EnableDigest = r.FormValue("enableDigest") == "on"
+	a.config.EnableEmailDigest = r.FormValue("enableEmailDigest") == "on"
+	a.config.EmailTo = parseList(r.FormValue("emailTo"))
+	a.config.EmailAttachTranscripts = r.FormValue("emailAttachTranscripts") == "on"
+	a.config.SMTPHost = strings.TrimSpace(r.FormValue("smtpHost"))
+	a.config.SMTPPort = formInt(r, "smtpPort")
+	a.config.SMTPSecurity = r.FormValue("smtpSecurity")
+	a.config.SMTPUser = strings.TrimSpace(r.FormValue("smtpUser"))
+	a.config.SMTPPassword = r.FormValue("smtpPassword")
+	a.config.SMTPFrom = strings.TrimSpace(r.FormValue("smtpFrom"))
+	a.config.EnableBackup = r.FormValue("enableBackup") == "on"
+	a.config.BackupSchedule = strings.TrimSpace(r.FormValue("backupSchedule"))
+	a.config.BackupPath = strings.TrimSpace(r.FormValue("backupPath"))
+	a.config.BackupKeep = formInt(r, "backupKeep")
+	a.config.BackupUploadURL = strings.TrimSpace(r.FormValue("backupUploadURL"))
+	a.config.EnableS3 = r.FormValue("enableS3") == "on"
+	a.config.S3Endpoint = strings.TrimSpace(r.FormValue("s3Endpoint"))
+	a.config.S3Bucket = strings.TrimSpace(r.FormValue("s3Bucket"))
+	a.config.S3Region = strings.TrimSpace(r.FormValue("s3Region"))
+	a.config.S3Prefix = strings.TrimSpace(r.FormValue("s3Prefix"))
+	a.config.S3AccessKey = strings.TrimSpace(r.FormValue("s3AccessKey"))
+	a.config.S3SecretKey = strings.TrimSpace(r.FormValue("s3SecretKey"))
+	a.config.S3PathStyle = r.FormValue("s3PathStyle") == "on"
+	a.config.EnableWebDAV = r.FormValue("enableWebDAV") == "on"
+	a.config.WebDAVURL = strings.TrimSpace(r.FormValue("webdavURL"))
+	a.config.WebDAVUser = strings.TrimSpace(r.FormValue("webdavUser"))
+	a.config.WebDAVPassword = r.FormValue("webdavPassword")
+	a.config.EnableGDrive = r.FormValue("enableGDrive") == "on"
+	a.config.GDriveCredentials = strings.TrimSpace(r.FormValue("gdriveCredentials"))
+	a.config.GDriveFolderID = strings.TrimSpace(r.FormValue("gdriveFolderID"))
+	a.config.UploadDeleteLocal = r.FormValue("uploadDeleteLocal") == "on"
+	a.config.DigestTime = strings.TrimSpace(r.FormValue("digestTime"))
+	a.config.DigestWebhookURL = r.FormValue("digestWebhookURL")
+	a.config.EnableForward = r.FormValue("enableForward") == "on"
+	a.config.ForwardURL = r.FormValue("forwardURL")
+	a.config.HTTPTimeout = formInt(r, "httpTimeout")
+	a.config.HTTPProxy = strings.TrimSpace(r.FormValue("httpProxy"))
+	a.config.HTTPCABundle = strings.TrimSpace(r.FormValue("httpCABundle"))
+	a.config.EnableTunnel = r.FormValue("enableTunnel") == "on"
+	a.config.TunnelProvider = r.FormValue("tunnelProvider")
+	a.config.TunnelAuthToken = strings.TrimSpace(r.FormValue("tunnelAuthToken"))
+	a.config.TunnelCommand = strings.TrimSpace(r.FormValue("tunnelCommand"))
+	a.config.EnableMDNS = r.FormValue("enableMDNS") == "on"
+	a.config.MDNSHostname = strings.TrimSpace(r.FormValue("mdnsHostname"))
+	a.config.EnableUPnP = r.FormValue("enableUPnP") == "on"
+	a.config.EnableLivePage = r.FormValue("enableLivePage") == "on"
+	a.config.RequireIngestToken = r.FormValue("requireIngestToken") == "on"
+	if tokenErr == nil {
+		a.config.RequireAPIToken = requireAPIToken
+	}
+	a.config.SecretStorage = r.FormValue("secretStorage")
+	a.config.CORSOrigins = parseList(r.FormValue("corsOrigins"))
+	a.config.IngestAllowIPs = parseList(r.FormValue("ingestAllowIPs"))
+	a.config.IngestDenyIPs = parseList(r.FormValue("ingestDenyIPs"))
+	a.config.WebUIAllowIPs = parseList(r.FormValue("webUIAllowIPs"))
+	a.config.EnableRCON = r.FormValue("enableRCON") == "on"
+	a.config.RCONAddr = strings.TrimSpace(r.FormValue("rconAddr"))
+	a.config.RCONPassword = r.FormValue("rconPassword")
+	a.config.RCONCommand = strings.TrimSpace(r.FormValue("rconCommand"))
+	a.config.RCONStartMessage = strings.TrimSpace(r.FormValue("rconStartMessage"))
+	a.config.RCONStopMessage = strings.TrimSpace(r.FormValue("rconStopMessage"))
+	a.config.EnableRelay = r.FormValue("enableRelay") == "on"
+	a.config.RelayChannelID = strings.TrimSpace(r.FormValue("relayChannelID"))
+	a.config.RelayURL = strings.TrimSpace(r.FormValue("relayURL"))
+	a.config.UDPListenAddr = strings.TrimSpace(r.FormValue("udpListenAddr"))
+	a.config.UDPPattern = r.FormValue("udpPattern")
+	a.config.TailPath = strings.TrimSpace(r.FormValue("tailPath"))
+	a.config.TailPattern = r.FormValue("tailPattern")
+	a.config.InputPreset = r.FormValue("inputPreset")
+	a.config.InputFields = parseList(r.FormValue("inputFields"))
+	a.config.RateLimit = formInt(r, "rateLimit")
+	a.config.SenderRateLimit = formInt(r, "senderRateLimit")
+	a.config.RateLimitBurst = formInt(r, "rateLimitBurst")
+	a.config.RateLimitPolicy = r.FormValue("rateLimitPolicy")
+	a.config.MaxMessageLength = formInt(r, "maxMessageLength")
+	a.config.MaxSenderLength = formInt(r, "maxSenderLength")
+	a.config.LengthPolicy = r.FormValue("lengthPolicy")
+	if sourcesErr == nil {
+		a.config.Sources = sources
+	}
+	if senderRoutesErr == nil {
+		a.config.SenderRoutes = senderRoutes
+	}
 	cfg := *a.config
 	a.configMu.Unlock()
 
-	a.logger.SetDebugMode(cfg.DebugMode)
+	setKnownSecrets(&cfg)
+	discordCircuit.configure(&cfg)
+	if err := outbound.configure(&cfg); err != nil {
+		a.logger.Log("error", "HTTP client settings not applied: "+err.Error())
+	}
+	a.logger.SetLogLevel(cfg.LogLevel)
+	a.logger.SetHistoryLimits(cfg.LogHistorySize, cfg.FailureHistorySize)
 
-	a.logger.Log("debug", fmt.Sprintf("Config values: Discord=%v, LocalSave=%v (Path=%s, Format=%s), Listen=%s, AutoStart=%v, Debug=%v",
-		cfg.EnableDiscord, cfg.EnableLocalSave, cfg.Path, cfg.FileFormat, cfg.ListenAddr, cfg.AutoStart, cfg.DebugMode))
+	a.logger.Log("debug", fmt.Sprintf("Config values: Discord=%v, LocalSave=%v (Path=%s, Format=%s), Forward=%v, Listen=%s, AutoStart=%v, LogLevel=%s",
+		cfg.EnableDiscord, cfg.EnableLocalSave, cfg.Path, cfg.FileFormat, cfg.EnableForward, cfg.ListenAddr, cfg.AutoStart, cfg.LogLevel))
 
 	data := map[string]interface{}{
 		"Config": cfg,
 	}
 
 	// Validate configuration
-	if !cfg.EnableDiscord && !cfg.EnableLocalSave {
-		a.logger.Log("debug", "Config validation failed: no output options enabled")
-		data["SaveError"] = "Enable at least one output option"
-	} else if cfg.EnableDiscord && cfg.WebhookURL == "" {
-		a.logger.Log("debug", "Config validation failed: Discord enabled but no webhook URL")
-		data["SaveError"] = "Discord webhook URL required"
-	} else if cfg.EnableLocalSave && cfg.Path == "" {
-		a.logger.Log("debug", "Config validation failed: Local save enabled but no path")
-		data["SaveError"] = "File path required for local save"
+	if sourcesErr != nil {
+		data["SaveError"] = sourcesErr.Error()
+	} else if senderRoutesErr != nil {
+		data["SaveError"] = senderRoutesErr.Error()
+	} else if tokenErr != nil {
+		data["SaveError"] = tokenErr.Error()
+	} else if err := cfg.validate(); err != nil {
+		a.logger.Log("debug", fmt.Sprintf("Config validation failed: %v", err))
+		data["SaveError"] = err.Error()
+	} else if err := checkWebhookURLs(&cfg); err != nil {
+		data["SaveError"] = err.Error()
+	} else if err := a.previewDiscordTarget(r.Context(), &cfg, data); err != nil {
+		a.logger.Log("debug", fmt.Sprintf("Discord check failed: %v", err))
+		data["SaveError"] = err.Error()
 	} else if err := saveConfiguration(&cfg); err != nil {
 		a.logger.Log("error", fmt.Sprintf("Failed to save config: %v", err))
 		data["SaveError"] = "Failed to save configuration"
@@ -177,7 +553,77 @@ func (a *App) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := tmpl.ExecuteTemplate(w, "config-form", data); err != nil {
-		log.Printf("Template render error: %v", err)
+		slog.Error("Template render error", "err", err)
+	}
+}
+
+// previewDiscordTarget verifies the Discord destination when the config
+// asks for it, adding a description of it to the form data.
+func (a *App) previewDiscordTarget(ctx context.Context, cfg *AppConfig, data map[string]interface{}) error {
+	if !cfg.EnableDiscord || !cfg.VerifyWebhook || discordTarget(cfg) == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookCheckTimeout)
+	defer cancel()
+	dest, err := verifyDiscordTarget(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	data["DiscordPreview"] = dest.String()
+	return nil
+}
+
+// formInt returns a numeric form field, or 0 when it is empty or invalid.
+func formInt(r *http.Request, name string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(r.FormValue(name)))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// handleTemplatePreview renders sample messages with the message templates
+// being edited, as an HTML partial.
+func (a *App) handleTemplatePreview(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{}
+
+	discordText := strings.TrimSpace(r.FormValue("discordTemplate"))
+	if err := validateLineTemplate("Discord template", discordText); err != nil {
+		data["DiscordError"] = err.Error()
+	} else {
+		sample := sampleLine(kindSay)
+		at, _ := time.ParseInLocation(logTimestampLayout, sample.Timestamp, time.Local)
+		prefix, suffix := discordPostParts(discordText, false, sample.Sender, at, at)
+		data["Discord"] = prefix + sample.Message + suffix
+	}
+
+	textText := strings.TrimSpace(r.FormValue("textTemplate"))
+	if err := validateLineTemplate("Text line template", textText); err != nil {
+		data["TextError"] = err.Error()
+	} else {
+		var b strings.Builder
+		for _, kind := range []string{kindSay, kindEmote, kindOOC} {
+			sample := sampleLine(kind)
+			b.WriteString(formatTextLineWith(textText, LogEntry{
+				Timestamp: sample.Timestamp,
+				Sender:    sample.Sender,
+				Message:   sample.Message,
+				Kind:      kind,
+				Scene:     sample.Scene,
+				Session:   sample.Session,
+				Source:    sample.Source,
+			}))
+		}
+		data["Text"] = b.String()
+	}
+
+	tmpl, err := a.parseTemplates("templates/partials/template_preview.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "template-preview", data); err != nil {
+		slog.Error("Template render error", "err", err)
 	}
 }
 
@@ -195,19 +641,9 @@ func (a *App) handleStartServer(w http.ResponseWriter, r *http.Request) {
 	cfg := *a.config
 	a.configMu.RUnlock()
 
-	if !cfg.EnableDiscord && !cfg.EnableLocalSave {
-		a.logger.Log("debug", "Start rejected: no output options enabled")
-		a.renderStatus(w, false, "Enable at least one output option")
-		return
-	}
-	if cfg.EnableDiscord && cfg.WebhookURL == "" {
-		a.logger.Log("debug", "Start rejected: Discord enabled but no webhook URL")
-		a.renderStatus(w, false, "Discord webhook URL required")
-		return
-	}
-	if cfg.EnableLocalSave && cfg.Path == "" {
-		a.logger.Log("debug", "Start rejected: Local save enabled but no path")
-		a.renderStatus(w, false, "File path required for local save")
+	if err := cfg.validate(); err != nil {
+		a.logger.Log("debug", fmt.Sprintf("Start rejected: %v", err))
+		a.renderStatus(w, false, err.Error())
 		return
 	}
 
@@ -256,11 +692,25 @@ func (a *App) statusMessage() string {
 	return "Stopped"
 }
 
+// tunnelStatus describes the tunnel for the status partial: its public
+// ingestion URL, or Pending while it is starting.
+func (a *App) tunnelStatus() map[string]interface{} {
+	url, pending := a.TunnelURL()
+	if url != "" {
+		url += "/message"
+	}
+	return map[string]interface{}{"URL": url, "Pending": pending}
+}
+
 // renderStatus renders the status partial for HTMX.
 func (a *App) renderStatus(w http.ResponseWriter, running bool, message string) {
 	data := map[string]interface{}{
-		"Running": running,
-		"Message": message,
+		"Running":   running,
+		"Message":   message,
+		"Tunnel":    a.tunnelStatus(),
+		"Discovery": a.discoveryStatus(),
+		"Offline":   a.offlineStatus(),
+		"Paused":    a.pauseStatus(),
 	}
 
 	tmpl, err := a.parseTemplates("templates/partials/status.html")
@@ -269,95 +719,86 @@ func (a *App) renderStatus(w http.ResponseWriter, running bool, message string)
 		return
 	}
 	if err := tmpl.ExecuteTemplate(w, "status-indicator", data); err != nil {
-		log.Printf("Template render error: %v", err)
+		slog.Error("Template render error", "err", err)
 	}
 }
 
-// handleSSEStream serves a Server-Sent Events stream of log messages.
-func (a *App) handleSSEStream(w http.ResponseWriter, r *http.Request) {
-	a.logger.Log("debug", fmt.Sprintf("SSE client connected from %s", r.RemoteAddr))
+// sessionData returns the active session for templates, or nil.
+func (a *App) sessionData() *Session {
+	if session, ok := a.CurrentSession(); ok {
+		return &session
+	}
+	return nil
+}
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		a.logger.Log("debug", "SSE streaming not supported by client")
-		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+// handleSessionStart starts a named session.
+func (a *App) handleSessionStart(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
 		return
 	}
+	if _, err := a.StartSession(r.FormValue("name")); err != nil {
+		a.renderSession(w, err.Error())
+		return
+	}
+	a.renderSession(w, "")
+}
 
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
-
-	// Send recent history first
-	history := a.logger.GetHistory()
-	a.logger.Log("debug", fmt.Sprintf("Sending %d history lines to SSE client", len(history)))
-	for _, line := range history {
-		fmt.Fprintf(w, "data: <div class=\"log-line\">%s</div>\n\n", template.HTMLEscapeString(line))
+// handleSessionStop ends the active session.
+func (a *App) handleSessionStop(w http.ResponseWriter, r *http.Request) {
+	if _, err := a.StopSession(); err != nil {
+		a.renderSession(w, err.Error())
+		return
 	}
-	flusher.Flush()
+	a.renderSession(w, "")
+}
 
-	// Subscribe to live updates
-	ch := a.sseBroker.Subscribe()
-	defer func() {
-		a.sseBroker.Unsubscribe(ch)
-		a.logger.Log("debug", fmt.Sprintf("SSE client disconnected: %s", r.RemoteAddr))
-	}()
+// handleSessionStatus returns the current session as an HTML partial.
+func (a *App) handleSessionStatus(w http.ResponseWriter, r *http.Request) {
+	a.renderSession(w, "")
+}
 
-	ctx := r.Context()
-	for {
-		select {
-		case msg, ok := <-ch:
-			if !ok {
-				return
-			}
-			fmt.Fprintf(w, "data: <div class=\"log-line\">%s</div>\n\n", template.HTMLEscapeString(msg))
-			flusher.Flush()
-		case <-ctx.Done():
-			return
-		}
+// renderSession renders the session partial for HTMX.
+func (a *App) renderSession(w http.ResponseWriter, errMsg string) {
+	data := map[string]interface{}{
+		"Session":      a.sessionData(),
+		"SessionError": errMsg,
+	}
+
+	tmpl, err := a.parseTemplates("templates/partials/session.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "session-status", data); err != nil {
+		slog.Error("Template render error", "err", err)
 	}
 }
 
-// handleFailureStream serves a Server-Sent Events stream of failure messages.
-func (a *App) handleFailureStream(w http.ResponseWriter, r *http.Request) {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+// handleSSEStream serves a Server-Sent Events stream of log messages,
+// optionally filtered by the "level" and "contains" query parameters.
+func (a *App) handleSSEStream(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseLogLineFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	a.logger.Log("debug", fmt.Sprintf("SSE client connected from %s", r.RemoteAddr))
+	defer a.logger.Log("debug", fmt.Sprintf("SSE client disconnected: %s", r.RemoteAddr))
 
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
-
-	// Send recent failure history first
-	failures := a.logger.GetFailures()
-	for _, f := range failures {
-		line := fmt.Sprintf("[%s] %s | %s: %s | Error: %s",
-			f.Timestamp, f.FailureType, f.Sender, truncateForDisplay(f.Message, 100), f.Error)
-		fmt.Fprintf(w, "data: <div class=\"failure-line\">%s</div>\n\n", template.HTMLEscapeString(line))
-	}
-	flusher.Flush()
-
-	// Subscribe to live failure updates
-	ch := a.failureBroker.Subscribe()
-	defer a.failureBroker.Unsubscribe(ch)
-
-	ctx := r.Context()
-	for {
-		select {
-		case msg, ok := <-ch:
-			if !ok {
-				return
-			}
-			fmt.Fprintf(w, "data: <div class=\"failure-line\">%s</div>\n\n", template.HTMLEscapeString(msg))
-			flusher.Flush()
-		case <-ctx.Done():
-			return
+	streamEvents(w, r, a.sseBroker, a.logger.historySince, func(ev sseEvent) (string, bool) {
+		if !filter.match(ev.Data) {
+			return "", false
 		}
-	}
+		return logLineHTML(ev.Data), true
+	})
+}
+
+// handleFailureStream serves a Server-Sent Events stream of failure messages.
+func (a *App) handleFailureStream(w http.ResponseWriter, r *http.Request) {
+	streamEvents(w, r, a.failureBroker, a.logger.failureEventsSince, func(ev sseEvent) (string, bool) {
+		return fmt.Sprintf(`<div class="failure-line">%s</div>`, template.HTMLEscapeString(ev.Data)), true
+	})
 }
 
 // truncateForDisplay shortens a string for display purposes.
@@ -427,25 +868,55 @@ func (a *App) handleShutdown(w http.ResponseWriter, r *http.Request) {
 	}()
 }
 
-// handleUpdateInfo returns the current update information as an HTML partial.
+// handleUpdateInfo returns the current update information as an HTML
+// partial. With ?badge, as polled by the page header, it is empty unless
+// there is a newer version.
 func (a *App) handleUpdateInfo(w http.ResponseWriter, r *http.Request) {
 	info := a.updater.GetInfo()
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	if info.Available {
-		fmt.Fprintf(w, `<div class="update-badge">
-			<span>v%s available</span>
-			<button class="btn btn-update btn-small" hx-post="/api/update/apply" hx-swap="innerHTML" hx-target="body" hx-confirm="This will download and apply the update, then restart the application. Continue?">Update</button>
-		</div>`, info.LatestVersion)
-	} else {
+		a.renderUpdatePartial(w, "update-badge", info)
+	} else if info.MissingPlatform != "" {
+		fmt.Fprintf(w, `<span class="update-check-result">v%s has no build for %s, <a href="%s" target="_blank" rel="noopener noreferrer">see the release</a></span>`,
+			template.HTMLEscapeString(info.LatestVersion), template.HTMLEscapeString(info.MissingPlatform), template.HTMLEscapeString(info.ReleaseURL))
+	} else if r.URL.Query().Get("badge") == "" {
 		fmt.Fprintf(w, `<span class="update-check-result">Up to date (v%s)</span>`, info.CurrentVersion)
 	}
 }
 
+// handleUpdateNotes returns the update dialog: the release notes, the
+// publication date and the download size of the available update.
+func (a *App) handleUpdateNotes(w http.ResponseWriter, r *http.Request) {
+	info := a.updater.GetInfo()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if !info.Available {
+		w.Write([]byte(`<div class="alert error">No update available</div><form method="dialog"><button class="btn btn-small">Close</button></form>`))
+		return
+	}
+	a.renderUpdatePartial(w, "update-notes", info)
+}
+
+// renderUpdatePartial renders a template from the update partials.
+func (a *App) renderUpdatePartial(w http.ResponseWriter, name string, info UpdateInfo) {
+	tmpl, err := a.parseTemplates("templates/partials/update.html")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("template error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, name, info); err != nil {
+		slog.Error("Template render error", "err", err)
+	}
+}
+
 // handleUpdateCheck triggers a check for updates and returns the result.
 func (a *App) handleUpdateCheck(w http.ResponseWriter, r *http.Request) {
-	if err := a.updater.CheckForUpdate(); err != nil {
+	a.configMu.RLock()
+	channel := a.config.UpdateChannel
+	a.configMu.RUnlock()
+	if err := a.updater.CheckForUpdate(channel); err != nil {
 		a.logger.Log("error", fmt.Sprintf("Update check failed: %v", err))
 	}
 
@@ -461,6 +932,16 @@ func (a *App) handleUpdateApply(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<div class="alert error">No update available</div>`))
 		return
 	}
+	if info.Container {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<div class="alert error">Running in a container: %s</div>`, template.HTMLEscapeString(containerUpdateHelp))
+		return
+	}
+	if info.Unsigned {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<div class="alert error">This build can't verify updates: %s</div>`, template.HTMLEscapeString(unsignedUpdateHelp))
+		return
+	}
 
 	// Return updating page HTML first
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")